@@ -8,12 +8,15 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/apex/gateway"
 	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/hashops"
 	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/searchops"
+	"github.com/tpillz-presents/service/util/timeops"
 )
 
 const route = "/admin/inventory/add_new_item" // PUT
@@ -37,10 +40,14 @@ var tables = []dbops.Table{
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Search is used to update the catalog search index
+var Search searchops.Snapshots = searchops.NewS3SnapshotsFromEnv()
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
-
 	// verify content-type
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "application/json" {
@@ -66,18 +73,21 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 
 	// generate itemID / root SKU (additional SKU's per size used for units sold and other sales metrics)
 	generateRootSKU(data)
+	if data.DateAdded == "" {
+		data.DateAdded = timeops.ConvertToTimestampString(time.Now())
+	}
 
 	summary := createSummary(data)
 
 	// get put new store item to DB
-	err = dbops.PutStoreItem(DB, data)
+	err = DB.PutStoreItem(data)
 	if err != nil {
 		log.Printf("RootHandler failed - put item: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	err = dbops.PutStoreItemSummary(DB, summary)
+	err = DB.PutStoreItemSummary(summary)
 	if err != nil {
 		log.Printf("RootHandler failed - put summary: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -85,7 +95,7 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// update store item index
-	index, err := dbops.GetStoreItemIndex(DB, data.Subcategory)
+	index, err := DB.GetStoreItemIndex(data.Subcategory)
 	if err != nil {
 		log.Printf("RootHandler failed - get index: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -97,13 +107,19 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	index.Push(data.ItemID)
 
-	err = dbops.PutStoreItemIndex(DB, index)
+	err = DB.PutStoreItemIndex(index)
 	if err != nil {
 		log.Printf("RootHandler failed - put index: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
+	// update search index; index is repaired by the rebuildIndex API if update fails
+	err = searchops.Update(Search, func(idx *searchops.Index) { idx.Add(searchops.NewDocument(data)) })
+	if err != nil {
+		log.Printf("RootHandler failed - update search index: %v", err)
+	}
+
 	// return order to admin
 	httpops.ErrResponse(w, "Success! Item added!", data.ItemID, http.StatusOK)
 	return
@@ -129,6 +145,7 @@ func createSummary(item *store.StoreItem) *store.StoreItemSummary {
 		Subcategory: item.Subcategory,
 		Name:        item.Name,
 		Price:       item.Price,
+		DateAdded:   item.DateAdded,
 	}
 	if len(item.ImageUrls) > 0 {
 		sum.ThumbnailUrl = item.ImageUrls[0]
@@ -9,6 +9,7 @@ import (
 	"github.com/apex/gateway"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/searchops"
 )
 
 const route = "/admin/inventory/delete_item" // DELETE
@@ -28,17 +29,21 @@ var tables = []dbops.Table{
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Search is used to update the catalog search index
+var Search searchops.Snapshots = searchops.NewS3SnapshotsFromEnv()
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
-
 	// get query strings from GET call
 	params := httpops.GetQueryStringParams(r)
 	subcat := params["sub_category"]
 	itemID := params["item_id"]
 
 	// remove from index
-	index, err := dbops.GetStoreItemIndex(DB, subcat)
+	index, err := DB.GetStoreItemIndex(subcat)
 	if err != nil {
 		log.Printf("RootHandler failed - get index: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -47,7 +52,7 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 
 	index.Remove(itemID)
 
-	err = dbops.PutStoreItemIndex(DB, index)
+	err = DB.PutStoreItemIndex(index)
 	if err != nil {
 		log.Printf("RootHandler failed - put index: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -55,7 +60,7 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// delete item summary
-	err = dbops.DeleteStoreItemSummary(DB, subcat, itemID)
+	err = DB.DeleteStoreItemSummary(subcat, itemID)
 	if err != nil {
 		log.Printf("RootHandler failed - delete summary: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -63,13 +68,19 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// delete item
-	err = dbops.DeleteStoreItem(DB, subcat, itemID)
+	err = DB.DeleteStoreItem(subcat, itemID)
 	if err != nil {
 		log.Printf("RootHandler failed - delete item: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
+	// update search index; index is repaired by the rebuildIndex API if update fails
+	err = searchops.Update(Search, func(idx *searchops.Index) { idx.Remove(itemID) })
+	if err != nil {
+		log.Printf("RootHandler failed - update search index: %v", err)
+	}
+
 	// return order to admin
 	httpops.ErrResponse(w, "Success! Item deleted!", itemID, http.StatusOK)
 	return
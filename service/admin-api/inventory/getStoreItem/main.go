@@ -1,12 +1,14 @@
 package main
 
-/* getStoreItem retrieves a StoreItem and returns it to the admin. */
+/* getStoreItem retrieves a StoreItem and returns it to the admin with the units of each size available
+   to sell. Units on hand (units_available) include units reserved by active inventory holds. */
 
 import (
 	"log"
 	"net/http"
 
 	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
 )
@@ -14,6 +16,12 @@ import (
 const route = "/admin/inventory/get_item" // GET
 const failMsg = "Request failed!"
 
+// itemInventory contains the StoreItem and the units of each size available to sell.
+type itemInventory struct {
+	*store.StoreItem
+	UnitsAvailableToSell map[string]int `json:"units_available_to_sell"`
+}
+
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // orders table
@@ -23,25 +31,27 @@ var tables = []dbops.Table{
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
-
 	// get query strings from GET call
 	params := httpops.GetQueryStringParams(r)
 	subcat := params["sub_category"]
 	itemID := params["item_id"]
 
 	// get item
-	item, err := dbops.GetStoreItem(DB, subcat, itemID)
+	item, err := DB.GetStoreItem(subcat, itemID)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	// return order to admin
-	httpops.ErrResponse(w, "Success! Returning item...", item, http.StatusOK)
+	// return item to admin
+	inv := itemInventory{StoreItem: item, UnitsAvailableToSell: item.AvailableToSellCounts()}
+	httpops.ErrResponse(w, "Success! Returning item...", inv, http.StatusOK)
 	return
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/apex/gateway"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/searchops"
 )
 
 type updateReq struct {
@@ -38,10 +39,14 @@ var tables = []dbops.Table{
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Search is used to update the catalog search index
+var Search searchops.Snapshots = searchops.NewS3SnapshotsFromEnv()
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
-
 	// verify content-type
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "application/json" {
@@ -66,7 +71,7 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get put new store item to DB
-	err = dbops.UpdateStoreItem(DB, data.Subcategory, data.ItemID, data.FieldName, data.Value)
+	err = DB.UpdateStoreItem(data.Subcategory, data.ItemID, data.FieldName, data.Value)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -75,12 +80,14 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 
 	// update ItemSummary object if needed
 	updateSummary := map[string]bool{
-		"name":        true,
-		"subcategory": true,
-		"price":       true,
+		"name":          true,
+		"subcategory":   true,
+		"price":         true,
+		"date_added":    true,
+		"product_views": true,
 	}
 	if updateSummary[data.FieldName] {
-		err = dbops.UpdateStoreItemSummary(DB, data.Subcategory, data.ItemID, data.FieldName, data.Value)
+		err = DB.UpdateStoreItemSummary(data.Subcategory, data.ItemID, data.FieldName, data.Value)
 		if err != nil {
 			log.Printf("RootHandler failed: %v", err)
 			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -88,6 +95,15 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// update search index; index is repaired by the rebuildIndex API if update fails
+	item, err := DB.GetStoreItem(data.Subcategory, data.ItemID)
+	if err == nil && item.ItemID != "" {
+		err = searchops.Update(Search, func(idx *searchops.Index) { idx.Add(searchops.NewDocument(item)) })
+	}
+	if err != nil {
+		log.Printf("RootHandler failed - update search index: %v", err)
+	}
+
 	// return order to admin
 	httpops.ErrResponse(w, "Success! Item added!", data.Value, http.StatusOK)
 	return
@@ -1,12 +1,14 @@
 package main
 
-/* viewStoreItems returns a list of items for the given category, similar as if browsing as a customer. */
+/* viewStoreItems returns a page of items for the given category, similar as if browsing as a customer.
+   Accepts the same sorting, filtering and cursor query strings as the browseItems API. */
 
 import (
 	"log"
 	"net/http"
 
 	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
 )
@@ -21,40 +23,36 @@ var tables = []dbops.Table{
 		PrimaryKey: dbops.StoreItemSummaryPK,
 		SortKey:    dbops.StoreItemSummarySK,
 	},
-	dbops.Table{
-		Name:       dbops.StoreItemsIndexTable(),
-		PrimaryKey: dbops.StoreItemsIndexPK,
-	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
-
 	// get query strings from GET call
 	params := httpops.GetQueryStringParams(r)
 	subcat := params["sub_category"]
-
-	tn := dbops.StoreItemsIndexTable()
-	log.Println("tablename: ", tn)
-
-	index, err := dbops.GetStoreItemIndex(DB, subcat)
+	opts, err := store.ParseBrowseOptions(params)
 	if err != nil {
-		log.Printf("RootHandler failed - get index: %v", err)
-		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
 		return
 	}
 
-	// get open order
-	items, err := dbops.BatchGetStoreItemSummary(DB, subcat, index.ItemIDs)
+	// get page of items
+	page, err := dbops.BrowseItems(DB, subcat, opts)
 	if err != nil {
-		log.Printf("RootHandler failed - batch get: %v", err)
+		log.Printf("RootHandler failed - browse items: %v", err)
+		if err.Error() == store.ErrInvalidCursor || err.Error() == store.ErrInvalidBrowseOptions {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+			return
+		}
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	// return order to admin
-	httpops.ErrResponse(w, "Success! Returning items...", items, http.StatusOK)
+	// return items to admin
+	httpops.ErrResponse(w, "Success! Returning items...", page, http.StatusOK)
 	return
 }
 
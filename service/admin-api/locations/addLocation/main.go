@@ -0,0 +1,90 @@
+package main
+
+/* addLocation creates a new Location. Orders are shipped from active locations that stock
+   their items (see store.AllocateItems). */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/locations/add_location" // POST
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // locations table
+		Name:       dbops.LocationsTable(),
+		PrimaryKey: dbops.LocationsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := store.Location{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+	if err := data.Validate(); err != nil {
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// verify location ID is not in use
+	existing, err := DB.GetLocation(data.LocationID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if existing.LocationID != "" {
+		httpops.ErrResponse(w, "Location already exists: "+data.LocationID, failMsg, http.StatusConflict)
+		return
+	}
+
+	// put new location to DB
+	err = DB.PutLocation(&data)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return location to admin
+	httpops.ErrResponse(w, "Success! Location added!", data, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
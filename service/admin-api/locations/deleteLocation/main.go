@@ -0,0 +1,57 @@
+package main
+
+/* deleteLocation deletes a Location. Set the location inactive instead to keep its inventory
+   while orders are not shipped from it. */
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/locations/delete_location" // DELETE
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // locations table
+		Name:       dbops.LocationsTable(),
+		PrimaryKey: dbops.LocationsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	locationID := strings.TrimSpace(params["location_id"])
+	if locationID == "" {
+		httpops.ErrResponse(w, "Bad Request: location_id required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// delete location
+	err := DB.DeleteLocation(locationID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return location ID to admin
+	httpops.ErrResponse(w, "Success! Location deleted!", locationID, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
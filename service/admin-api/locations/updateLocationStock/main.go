@@ -0,0 +1,98 @@
+package main
+
+/* updateLocationStock adds units of a size to a location's inventory, or removes them if the
+   count is negative (ex: after a stock count). Units shipped from the location are removed when
+   the shipping label is purchased (see purchaseLabel). */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/locations/update_location_stock" // PUT
+const failMsg = "Request failed!"
+
+// http request data
+type request struct {
+	LocationID string `json:"location_id"`
+	SizeID     string `json:"size_id"`
+	Count      int    `json:"count"` // units added; negative counts remove units
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // locations table
+		Name:       dbops.LocationsTable(),
+		PrimaryKey: dbops.LocationsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := request{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+	data.LocationID = strings.TrimSpace(data.LocationID)
+	data.SizeID = strings.TrimSpace(data.SizeID)
+	if data.LocationID == "" || data.SizeID == "" || data.Count == 0 {
+		httpops.ErrResponse(w, "Bad Request: location_id, size_id and count required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// update location inventory
+	err = DB.UpdateLocationStock(data.LocationID, data.SizeID, data.Count)
+	if err != nil {
+		if err.Error() == dbops.ErrConditionalCheck {
+			httpops.ErrResponse(w, "Location not found or insufficient stock: "+data.LocationID, failMsg, http.StatusConflict)
+			return
+		}
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return updated location to admin
+	location, err := DB.GetLocation(data.LocationID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	httpops.ErrResponse(w, "Success! Location stock updated!", location, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,47 @@
+package main
+
+/* viewLocations returns a list of all locations and their inventory, including inactive
+   locations. */
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/locations/view_locations" // GET
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // locations table
+		Name:       dbops.LocationsTable(),
+		PrimaryKey: dbops.LocationsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	locations, err := DB.ScanLocations()
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return locations to admin
+	httpops.ErrResponse(w, "Success! Returning locations...", locations, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
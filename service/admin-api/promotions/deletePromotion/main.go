@@ -0,0 +1,53 @@
+package main
+
+/* deletePromotion deletes a Promotion. Orders created with the promotion's coupon code can no
+   longer be paid once the promotion is deleted. */
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/promotions/delete_promotion" // DELETE
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // promotions table
+		Name:       dbops.PromotionsTable(),
+		PrimaryKey: dbops.PromotionsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	code := store.NormalizeCode(params["code"])
+
+	// delete promotion
+	err := DB.DeletePromotion(code)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return code to admin
+	httpops.ErrResponse(w, "Success! Promotion deleted!", code, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,56 @@
+package main
+
+/* getPromotion retrieves a Promotion and returns it to the admin. */
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/promotions/get_promotion" // GET
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // promotions table
+		Name:       dbops.PromotionsTable(),
+		PrimaryKey: dbops.PromotionsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	code := store.NormalizeCode(params["code"])
+
+	// get promotion
+	promo, err := DB.GetPromotion(code)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if promo.Code == "" {
+		httpops.ErrResponse(w, "Promotion not found: "+code, failMsg, http.StatusNotFound)
+		return
+	}
+
+	// return promotion to admin
+	httpops.ErrResponse(w, "Success! Returning promotion...", promo, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
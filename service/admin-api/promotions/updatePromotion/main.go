@@ -0,0 +1,156 @@
+package main
+
+/* updatePromotion replaces the rules of an existing Promotion. The promotion's redemption counts
+   are carried over from the existing promotion, and the remaining redemptions are adjusted by
+   the change in the global usage limit. The update is conditional on the redemption count read,
+   and is retried with the latest counts if the promotion is redeemed concurrently. */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/promotions/update_promotion" // PUT
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // promotions table
+		Name:       dbops.PromotionsTable(),
+		PrimaryKey: dbops.PromotionsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := store.Promotion{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+	if err := data.Validate(); err != nil {
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// get existing promotion
+	existing, err := DB.GetPromotion(data.Code)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if existing.Code == "" {
+		httpops.ErrResponse(w, "Promotion not found: "+data.Code, failMsg, http.StatusNotFound)
+		return
+	}
+
+	// update promotion rules & remaining redemptions - retried with the latest counts if the
+	// promotion is redeemed concurrently
+	reread := false
+	err = dbops.RetryOnConflict(func() error {
+		if reread {
+			existing, err = DB.GetPromotion(data.Code)
+			if err != nil {
+				return err
+			}
+			if existing.Code == "" {
+				return fmt.Errorf(store.ErrPromotionNotFound)
+			}
+		}
+		reread = true
+		updateRedemptions(&data, existing)
+		err := DB.UpdateItem(promotionUpdate(&data).If(dbops.Equal("redemptions", existing.Redemptions)))
+		if err != nil && err.Error() == dbops.ErrConditionalCheck {
+			return &dbops.VersionConflictError{Table: dbops.PromotionsTable(), Key: data.Code, Version: existing.Redemptions}
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		if err.Error() == store.ErrPromotionNotFound {
+			httpops.ErrResponse(w, "Promotion not found: "+data.Code, failMsg, http.StatusNotFound)
+			return
+		}
+		if dbops.IsVersionConflict(err) {
+			httpops.ErrResponse(w, "Promotion is being redeemed; try again", failMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return promotion to admin
+	httpops.ErrResponse(w, "Success! Promotion updated!", data, http.StatusOK)
+	return
+}
+
+// updateRedemptions sets the redemption counts of the updated promotion from the existing
+// promotion. Remaining redemptions are not set below zero.
+func updateRedemptions(promo, existing *store.Promotion) {
+	promo.Redemptions = existing.Redemptions
+	promo.RedemptionsRemaining = promo.MaxRedemptions - existing.Redemptions
+	if existing.MaxRedemptions > 0 {
+		promo.RedemptionsRemaining = existing.RedemptionsRemaining + promo.MaxRedemptions - existing.MaxRedemptions
+	}
+	if promo.RedemptionsRemaining < 0 {
+		promo.RedemptionsRemaining = 0
+	}
+}
+
+// promotionUpdate returns the Update setting the promotion's rules and remaining redemptions.
+// The redemption count is left as stored.
+func promotionUpdate(p *store.Promotion) *dbops.Update {
+	return dbops.NewPromotionUpdate(p.Code).
+		Set("description", p.Description).
+		Set("promotion_type", p.PromotionType).
+		Set("percent_off", p.PercentOff).
+		Set("amount_off", p.AmountOff).
+		Set("buy_quantity", p.BuyQuantity).
+		Set("get_quantity", p.GetQuantity).
+		Set("sub_categories", p.Subcategories).
+		Set("item_ids", p.ItemIDs).
+		Set("min_subtotal", p.MinSubtotal).
+		Set("start_date", p.StartDate).
+		Set("end_date", p.EndDate).
+		Set("max_redemptions", p.MaxRedemptions).
+		Set("redemptions_remaining", p.RedemptionsRemaining).
+		Set("max_per_customer", p.MaxPerCustomer).
+		Set("stackable", p.Stackable).
+		Set("active", p.Active).
+		IfExists()
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,134 @@
+package main
+
+/* receiveReturn marks the items of an approved return as received by the store and restocks the
+   returned items. The return is completed if the refund has been issued. */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/sesops"
+)
+
+const route = "/admin/returns/receive" // PUT
+const failMsg = "Request failed!"
+
+const from = "dg.dev.test510@gmail.com" // test only - move to admin settings db table in prod
+
+// http request data
+type request struct {
+	UserID   string `json:"user_id"`
+	ReturnID string `json:"return_id"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK,
+	},
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK,
+	},
+	dbops.Table{ // returns table
+		Name:       dbops.ReturnsTable(),
+		PrimaryKey: dbops.ReturnsPK,
+		SortKey:    dbops.ReturnsSK,
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := request{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// get return
+	ret, err := DB.GetReturn(data.UserID, data.ReturnID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if ret.ReturnID == "" {
+		httpops.ErrResponse(w, "Return not found: "+data.ReturnID, failMsg, http.StatusNotFound)
+		return
+	}
+
+	// update return & order status
+	err = dbops.TransitionReturn(DB, ret, store.ReturnEventReceiveItems)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		if err.Error() == store.ErrInvalidReturnTransition || err.Error() == dbops.ErrConditionalCheck {
+			httpops.ErrResponse(w, "Return cannot be updated: "+ret.ReturnStatus, failMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// restock returned items; the order's conditional status transition ensures items are
+	// received once
+	order, err := DB.GetOrder(ret.UserID, ret.OrderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	restock(ret, order)
+
+	// notify customer
+	if err := sesops.SendReturnNotification(sesops.InitSesh(), from, ret, order); err != nil {
+		log.Printf("RootHandler failed: %v", err)
+	}
+
+	httpops.ErrResponse(w, "Return updated: ", ret, http.StatusOK)
+	return
+}
+
+// restock returns the return's items to inventory. Items that cannot be restocked are logged
+// for manual adjustment, as the return has already been received.
+func restock(ret *store.Return, order *store.Order) {
+	for _, item := range ret.Items(order) {
+		err := DB.RestockItem(item.Subcategory, item.ItemID, item.Size, item.Quantity)
+		if err != nil {
+			log.Printf("restock failed: %s (%s) x%d: %v", item.ItemID, item.Size, item.Quantity, err)
+		}
+	}
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,232 @@
+package main
+
+/* refundReturn refunds the returned items of an approved return to the customer's original
+   payment method. The refund is recorded as a new Transaction linked to the order's original
+   transaction. The return is claimed before the refund is issued, and refunds are issued with the
+   return ID as the idempotency key, so retried requests are refunded once. Returned items are
+   restocked when they are received (see receiveReturn). */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/paymentops"
+	"github.com/tpillz-presents/service/util/sesops"
+	"github.com/tpillz-presents/service/util/timeops"
+)
+
+const route = "/admin/returns/refund" // PUT
+const failMsg = "Request failed!"
+
+const from = "dg.dev.test510@gmail.com" // test only - move to admin settings db table in prod
+
+// http request data
+type request struct {
+	UserID   string `json:"user_id"`
+	ReturnID string `json:"return_id"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK,
+	},
+	dbops.Table{ // transactions table
+		Name:       dbops.TransactionsTable(),
+		PrimaryKey: dbops.TransactionsPK,
+		SortKey:    dbops.TransactionsSK,
+	},
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK,
+	},
+	dbops.Table{ // returns table
+		Name:       dbops.ReturnsTable(),
+		PrimaryKey: dbops.ReturnsPK,
+		SortKey:    dbops.ReturnsSK,
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Payments is used to refund customer payments
+var Payments paymentops.Provider = paymentops.NewStripeFromEnv()
+
+// Notify sends the return notification email to the customer
+var Notify = func(ret *store.Return, order *store.Order) error {
+	return sesops.SendReturnNotification(sesops.InitSesh(), from, ret, order)
+}
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := request{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// get return
+	ret, err := DB.GetReturn(data.UserID, data.ReturnID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if ret.ReturnID == "" {
+		httpops.ErrResponse(w, "Return not found: "+data.ReturnID, failMsg, http.StatusNotFound)
+		return
+	}
+	if _, err := store.NextReturnStatus(ret.ReturnStatus, store.ReturnEventRefund); err != nil {
+		httpops.ErrResponse(w, "Return cannot be refunded: "+ret.ReturnStatus, failMsg, http.StatusConflict)
+		return
+	}
+
+	// claim return; claimed returns are retried with the claimed refund transaction ID
+	if !ret.Refunding {
+		err = claimRefund(ret)
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			if err.Error() == dbops.ErrConditionalCheck {
+				httpops.ErrResponse(w, "Return cannot be refunded: return was modified", failMsg, http.StatusConflict)
+				return
+			}
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// get order and original transaction
+	order, err := DB.GetOrder(ret.UserID, ret.OrderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	origTx, err := DB.GetTransaction(ret.UserID, ret.OriginalTxID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// issue refund
+	tx, err := refund(ret, order, origTx)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		switch err.Error() {
+		case paymentops.ErrInvalidPaymentState, paymentops.ErrInvalidRequest, paymentops.ErrPaymentNotFound:
+			httpops.ErrResponse(w, "Refund failed: "+err.Error(), failMsg, http.StatusConflict)
+		default:
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// update return & order status
+	err = dbops.TransitionReturn(DB, ret, store.ReturnEventRefund)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), tx, http.StatusInternalServerError)
+		return
+	}
+
+	// notify customer
+	err = Notify(ret, order)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+	}
+
+	httpops.ErrResponse(w, "Refund issued: ", tx, http.StatusOK)
+	return
+}
+
+// claimRefund claims the return for refund with a conditional write on the return's status, and
+// sets the ID of the refund Transaction. Returns ErrConditionalCheck if the return was modified or
+// claimed by another request.
+func claimRefund(ret *store.Return) error {
+	tx := &store.Transaction{Timestamp: timeops.ConvertToTimestampString(time.Now())}
+	tx.SetHashID()
+	err := DB.UpdateItem(dbops.NewReturnUpdate(ret.UserID, ret.ReturnID).
+		Set("refunding", true).
+		Set("return_tx_id", tx.TransactionID).
+		If(dbops.Equal("return_status", ret.ReturnStatus)).
+		If(dbops.NotExists("refunding"), dbops.Equal("refunding", false)))
+	if err != nil {
+		log.Printf("claimRefund failed: %v", err)
+		return err
+	}
+	ret.Refunding = true
+	ret.ReturnTxID = tx.TransactionID
+	return nil
+}
+
+// refund refunds the return's items and sales tax with the payment provider and records the
+// refund as a Transaction with negative amounts, linked to the original transaction by
+// CorrespondingTxID. The refund is issued with the return ID as the idempotency key and recorded
+// with the claimed ret.ReturnTxID, so retries do not refund or record the return twice. The
+// ret.RefundAmount field is set.
+func refund(ret *store.Return, order *store.Order, origTx *store.Transaction) (*store.Transaction, error) {
+	subtotal, tax := ret.RefundTotal(order)
+	amount := subtotal.Add(tax)
+
+	r, err := Payments.Refund(origTx.PaymentTxID, amount, ret.ReturnID)
+	if err != nil {
+		log.Printf("refund failed: %v", err)
+		return &store.Transaction{}, err
+	}
+
+	tx := &store.Transaction{
+		UserID:            ret.UserID,
+		OrderID:           ret.OrderID,
+		Timestamp:         timeops.ConvertToTimestampString(time.Now()),
+		PaymentMethod:     origTx.PaymentMethod,
+		PaymentTxID:       r.ID,
+		SalesSubtotal:     subtotal.Neg(),
+		SalesTax:          tax.Neg(),
+		TotalAmount:       amount.Neg(),
+		PaymentStatus:     r.Status,
+		CorrespondingTxID: origTx.TransactionID,
+		TransactionID:     ret.ReturnTxID,
+	}
+	err = DB.PutTransaction(tx)
+	if err != nil {
+		log.Printf("refund failed: %v", err)
+		return tx, err
+	}
+
+	ret.RefundAmount = amount
+	return tx, nil
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/paymentops"
+)
+
+func TestRootHandler(t *testing.T) {
+	DB = dbops.NewMemStore()
+	fake := paymentops.NewFake()
+	Payments = fake
+	notified := []string{}
+	Notify = func(ret *store.Return, order *store.Order) error {
+		notified = append(notified, ret.ReturnStatus)
+		return nil
+	}
+
+	// paid & shipped order of 2 shirts; 1 shirt returned
+	payment, err := fake.Authorize(paymentops.AuthorizeRequest{Token: "tok_visa", Amount: store.USD(7233)})
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	fake.Capture(payment.ID, store.USD(7233))
+	DB.PutStoreItem(&store.StoreItem{ItemID: "005", Subcategory: "shirts", UnitsAvailable: map[string]int{"L": 4}})
+	DB.PutTransaction(&store.Transaction{UserID: "user001", TransactionID: "tx001", OrderID: "user001-1", PaymentMethod: paymentops.ProviderFake, PaymentTxID: payment.ID})
+	order := &store.Order{
+		UserID:        "user001",
+		OrderID:       "user001-1",
+		TransactionID: "tx001",
+		OrderStatus:   store.OrderStatusShipped,
+		Items:         []*store.CartItem{{ItemID: "005", SizeID: "005-L", Size: "L", Subcategory: "shirts", Quantity: 2, Price: store.USD(2995)}},
+		SalesSubtotal: store.USD(5990),
+		SalesTax:      store.USD(434),
+		ShippingCost:  store.USD(809),
+		OrderTotal:    store.USD(7233),
+	}
+	ret, _ := store.NewReturn(order, map[string]int{"005-L": 1}, "too small")
+	order.Apply(store.OrderEventRequestReturn)
+	DB.PutOrder(order)
+	DB.PutReturn(ret)
+	if err := dbops.TransitionReturn(DB, ret, store.ReturnEventApprove); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+
+	var tests = []struct {
+		returnID   string
+		wantStatus int
+	}{
+		{returnID: ret.ReturnID, wantStatus: http.StatusOK},
+		{returnID: ret.ReturnID, wantStatus: http.StatusConflict}, // already refunded
+		{returnID: "user001-9-r", wantStatus: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		js, _ := json.Marshal(request{UserID: "user001", ReturnID: test.returnID})
+		req := httptest.NewRequest(http.MethodPut, route, bytes.NewReader(js))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		RootHandler(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("FAIL - status: %d; want: %d", w.Code, test.wantStatus)
+		}
+	}
+
+	// refund = 1 shirt + prorated sales tax (434 / 2); shipping not refunded
+	want := store.USD(2995 + 217)
+	got, _ := DB.GetReturn("user001", ret.ReturnID)
+	if got.ReturnStatus != store.ReturnStatusRefunded || !got.Refunded || got.RefundAmount != want {
+		t.Errorf("FAIL - return: %v", got)
+	}
+	tx, _ := DB.GetTransaction("user001", got.ReturnTxID)
+	if tx.CorrespondingTxID != "tx001" || tx.TotalAmount != want.Neg() || tx.PaymentStatus != store.RefundStatusSuccess {
+		t.Errorf("FAIL - refund tx: %v", tx)
+	}
+	p, _ := fake.Retrieve(payment.ID)
+	if p.Refunded != want {
+		t.Errorf("FAIL - refunded: %v; want: %v", p.Refunded, want)
+	}
+	item, _ := DB.GetStoreItem("shirts", "005")
+	if item.UnitsAvailable["L"] != 4 {
+		t.Errorf("FAIL - units available: %d; want: 4", item.UnitsAvailable["L"])
+	}
+	gotOrder, _ := DB.GetOrder("user001", "user001-1")
+	if gotOrder.OrderStatus != store.OrderStatusRefunded {
+		t.Errorf("FAIL: %s; want: %s", gotOrder.OrderStatus, store.OrderStatusRefunded)
+	}
+	if len(notified) != 1 || notified[0] != store.ReturnStatusRefunded {
+		t.Errorf("FAIL - notifications: %v", notified)
+	}
+}
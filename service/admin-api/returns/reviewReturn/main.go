@@ -0,0 +1,144 @@
+package main
+
+/* reviewReturn approves or rejects a customer's return request. Approving a return purchases a
+   return shipping label, which is emailed to the customer. Rejecting a return closes the order. */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/sesops"
+	"github.com/tpillz-presents/service/util/shipops"
+)
+
+const route = "/admin/returns/review" // PUT
+const failMsg = "Request failed!"
+
+const from = "dg.dev.test510@gmail.com" // test only - move to admin settings db table in prod
+
+// http request data
+type request struct {
+	UserID   string `json:"user_id"`
+	ReturnID string `json:"return_id"`
+	Approve  bool   `json:"approve"`
+	Message  string `json:"message"` // message to customer
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK,
+	},
+	dbops.Table{ // returns table
+		Name:       dbops.ReturnsTable(),
+		PrimaryKey: dbops.ReturnsPK,
+		SortKey:    dbops.ReturnsSK,
+	},
+	dbops.Table{ // shipments table
+		Name:       dbops.ShipmentsTable(),
+		PrimaryKey: dbops.ShipmentsPK,
+		SortKey:    dbops.ShipmentsSK,
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Shipping is used to purchase return labels
+var Shipping shipops.Carrier = shipops.NewShippoFromEnv()
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := request{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// get return
+	ret, err := DB.GetReturn(data.UserID, data.ReturnID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if ret.ReturnID == "" {
+		httpops.ErrResponse(w, "Return not found: "+data.ReturnID, failMsg, http.StatusNotFound)
+		return
+	}
+
+	event := store.ReturnEventReject
+	if data.Approve {
+		event = store.ReturnEventApprove
+	}
+	if _, err := store.NextReturnStatus(ret.ReturnStatus, event); err != nil {
+		httpops.ErrResponse(w, "Return cannot be updated: "+ret.ReturnStatus, failMsg, http.StatusConflict)
+		return
+	}
+	ret.Message = data.Message
+
+	// purchase return label
+	if data.Approve {
+		shipment, err := DB.GetShipment(ret.UserID, ret.OrderID)
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			return
+		}
+		err = shipops.PurchaseReturnLabel(Shipping, ret, shipment)
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// update return & order status
+	err = dbops.TransitionReturn(DB, ret, event)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// notify customer
+	order, err := DB.GetOrder(ret.UserID, ret.OrderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+	} else if err := sesops.SendReturnNotification(sesops.InitSesh(), from, ret, order); err != nil {
+		log.Printf("RootHandler failed: %v", err)
+	}
+
+	httpops.ErrResponse(w, "Return updated: ", ret, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
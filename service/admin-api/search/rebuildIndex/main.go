@@ -0,0 +1,61 @@
+package main
+
+/* rebuildIndex rebuilds the catalog search index from the StoreItems table and replaces the saved
+   index snapshot. Used to create the initial index and to repair the index if an inventory update
+   fails to update it. */
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/searchops"
+)
+
+const route = "/admin/search/rebuild_index" // POST
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK,
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Search is used to save the catalog search index
+var Search searchops.Snapshots = searchops.NewS3SnapshotsFromEnv()
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get all store items
+	items, err := DB.ScanStoreItems()
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// build & save index
+	idx := searchops.Build(items)
+	err = Search.Save(idx)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Success! Search index rebuilt!", idx.Len(), http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
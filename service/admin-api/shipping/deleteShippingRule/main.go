@@ -0,0 +1,56 @@
+package main
+
+/* deleteShippingRule deletes a ShippingRule. */
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/admin/shipping/delete_shipping_rule" // DELETE
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // shipping rules table
+		Name:       dbops.ShippingRulesTable(),
+		PrimaryKey: dbops.ShippingRulesPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	ruleID := strings.TrimSpace(params["rule_id"])
+	if ruleID == "" {
+		httpops.ErrResponse(w, "Bad Request: rule_id required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// delete shipping rule
+	err := DB.DeleteShippingRule(ruleID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return rule ID to admin
+	httpops.ErrResponse(w, "Success! Shipping rule deleted!", ruleID, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
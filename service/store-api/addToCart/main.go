@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/apex/gateway"
 	"github.com/tpillz-presents/service/store-api/store"
@@ -16,6 +17,7 @@ const route = "/add-to-cart" // PUT
 
 const failMsg = "Request failed!"
 const successMsg = "Request succeeded!"
+const stockFailMsg = "Item is out of stock!"
 
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
@@ -24,21 +26,21 @@ var tables = []dbops.Table{
 		PrimaryKey: dbops.CustomersPK,
 		SortKey:    ""},
 	dbops.Table{ // store items table
-		Name:       dbops.StoreItemsTable,
+		Name:       dbops.StoreItemsTable(),
 		PrimaryKey: dbops.StoreItemPK,
 		SortKey:    dbops.StoreItemSK},
 	dbops.Table{ // shopping carts table
-		Name:       dbops.ShoppingCartsTable,
+		Name:       dbops.ShoppingCartsTable(),
 		PrimaryKey: dbops.ShoppingCartsPK,
 		SortKey:    ""},
 	dbops.Table{ // transactions table
-		Name:       dbops.TransactionsTable,
+		Name:       dbops.TransactionsTable(),
 		PrimaryKey: dbops.TransactionsPK,
 		SortKey:    dbops.TransactionsSK},
 }
 
 // / DB is used to make DynamoDB API calls
-var DB = dbops.InitDB(tables)
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
 
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -65,37 +67,54 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// get user cart
-	cart, err := dbops.GetShoppingCart(DB, data.UserID)
+	// anonymous sessions hold a cart under a generated guest ID, returned with the cart
+	if data.UserID == "" {
+		data.UserID, err = store.NewGuestID()
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			return
+		}
+	}
+	data.AddedAt = time.Now().Unix()
+
+	if data.Quantity <= 0 {
+		httpops.ErrResponse(w, "Bad Request: invalid quantity", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// get authoritative item record
+	item, err := DB.GetStoreItem(data.Subcategory, data.ItemID)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
-
-	// add item to cart
-	if cart.Items == nil {
-		cart.Items = make(map[string]*store.CartItem)
-	}
-	if cart.Items[data.SizeID] == nil {
-		cart.Items[data.SizeID] = &data
-	} else {
-		item := cart.Items[data.SizeID]
-		item.Quantity += data.Quantity
-		item.ItemSubtotal += (data.Price * float32(data.Quantity))
+	if item.ItemID == "" {
+		httpops.ErrResponse(w, "Item not found: "+data.ItemID, failMsg, http.StatusNotFound)
+		return
 	}
-	cart.TotalItems += data.Quantity
-	cart.Subtotal += (float32(data.Quantity) * data.Price)
 
-	// update cart db record
-	err = dbops.PutShoppingCart(DB, cart)
+	// add item to user cart - retried if the cart is updated concurrently
+	cart, err := dbops.UpdateCart(DB, data.UserID, func(cart *store.ShoppingCart) error {
+		return cart.AddItem(item, data, data.Quantity)
+	})
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
-		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		switch {
+		case err.Error() == store.ErrItemNotFound:
+			httpops.ErrResponse(w, "Item size not found: "+data.Size, failMsg, http.StatusNotFound)
+		case err.Error() == store.ErrInsufficientStock:
+			httpops.ErrResponse(w, "Insufficient stock: "+store.NewSizeID(item.ItemID, data.Size), stockFailMsg, http.StatusConflict)
+		case dbops.IsVersionConflict(err):
+			httpops.ErrResponse(w, "Cart is being updated by another request; try again", failMsg, http.StatusConflict)
+		default:
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		}
 		return
 	}
 
-	httpops.ErrResponse(w, "Successfully retreived site info: ", successMsg, http.StatusOK)
+	httpops.ErrResponse(w, "Item added to cart: ", cart, http.StatusOK)
 	return
 }
 
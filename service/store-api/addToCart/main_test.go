@@ -1,7 +1,10 @@
 package main
 
 import (
-	"log"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/tpillz-presents/service/store-api/store"
@@ -10,7 +13,10 @@ import (
 
 func TestRootHandler(t *testing.T) {
 	var tests = []struct {
-		item *store.CartItem
+		item         *store.CartItem
+		wantStatus   int
+		wantItems    int         // cart.TotalItems after request
+		wantSubtotal store.Money // cart.Subtotal after request
 	}{
 		{item: &store.CartItem{ // in stock
 			UserID:      "user001",
@@ -20,8 +26,8 @@ func TestRootHandler(t *testing.T) {
 			Name:        "PawnWars Game Set",
 			Size:        "OS",
 			Quantity:    1,
-			Price:       29.95,
-		}},
+			Price:       store.USD(100), // client price ignored
+		}, wantStatus: http.StatusOK, wantItems: 1, wantSubtotal: store.USD(2995)},
 		{item: &store.CartItem{
 			UserID:      "user002",
 			Subcategory: "shirts",
@@ -30,8 +36,8 @@ func TestRootHandler(t *testing.T) {
 			Name:        "ACamoPrjct Logo T-Shirt",
 			Size:        "M",
 			Quantity:    1,
-			Price:       22.95,
-		}},
+			Price:       store.USD(2295),
+		}, wantStatus: http.StatusOK, wantItems: 1, wantSubtotal: store.USD(2295)},
 		{item: &store.CartItem{ // Insufficient stock
 			UserID:      "user001",
 			Subcategory: "shirts",
@@ -40,8 +46,8 @@ func TestRootHandler(t *testing.T) {
 			Name:        "ACamoPrjct Logo T-Shirt",
 			Size:        "L",
 			Quantity:    3,
-			Price:       22.95,
-		}},
+			Price:       store.USD(2295),
+		}, wantStatus: http.StatusConflict, wantItems: 1, wantSubtotal: store.USD(2995)},
 		{item: &store.CartItem{ // Non existent partition
 			UserID:      "user002",
 			Subcategory: "pants",
@@ -50,50 +56,40 @@ func TestRootHandler(t *testing.T) {
 			Name:        "ACamoPrjct Baller Pants",
 			Size:        "32",
 			Quantity:    1,
-			Price:       44.95,
-		}},
+			Price:       store.USD(4495),
+		}, wantStatus: http.StatusNotFound, wantItems: 1, wantSubtotal: store.USD(2295)},
 	}
+
+	DB = dbops.NewMemStore()
+	DB.PutStoreItem(&store.StoreItem{Subcategory: "game_sets", ItemID: "001", Price: store.USD(2995), UnitWeightOzs: 40, UnitsAvailable: map[string]int{"OS": 5}})
+	DB.PutStoreItem(&store.StoreItem{Subcategory: "shirts", ItemID: "005", Price: store.USD(2295), UnitWeightOzs: 6, UnitsAvailable: map[string]int{"M": 2, "L": 2}})
+
 	for _, test := range tests {
-		err := rootHandlerSim(test.item)
+		js, err := json.Marshal(test.item)
 		if err != nil {
-			t.Errorf("FAIL: %v", err)
+			t.Fatalf("FAIL: %v", err)
 		}
-	}
-}
+		req := httptest.NewRequest(http.MethodPut, route, bytes.NewReader(js))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
 
-func rootHandlerSim(data *store.CartItem) error {
-	// get user cart
-	cart, err := dbops.GetShoppingCart(DB, data.UserID)
-	if err != nil {
-		log.Printf("RootHandler failed: %v", err)
-		return err
-	}
-
-	if cart.UserID == "" {
-		cart.UserID = data.UserID
-	}
-
-	// add item to cart
-	if cart.Items == nil {
-		cart.Items = make(map[string]*store.CartItem)
-	}
-	if cart.Items[data.SizeID] == nil {
-		data.ItemSubtotal = data.Price * float32(data.Quantity)
-		cart.Items[data.SizeID] = data
-	} else {
-		item := cart.Items[data.SizeID]
-		item.Quantity += data.Quantity
-		item.ItemSubtotal += (data.Price * float32(data.Quantity))
-	}
-	cart.TotalItems += data.Quantity
-	cart.Subtotal += (float32(data.Quantity) * data.Price)
+		RootHandler(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("FAIL - status: %d; want: %d", w.Code, test.wantStatus)
+		}
 
-	// update cart db record
-	err = dbops.PutShoppingCart(DB, cart)
-	if err != nil {
-		log.Printf("RootHandler failed: %v", err)
-		return err
+		cart, err := DB.GetShoppingCart(test.item.UserID)
+		if err != nil {
+			t.Errorf("FAIL: %v", err)
+		}
+		if cart.TotalItems != test.wantItems {
+			t.Errorf("FAIL - total items: %d; want: %d", cart.TotalItems, test.wantItems)
+		}
+		if cart.Subtotal.Cmp(test.wantSubtotal) != 0 {
+			t.Errorf("FAIL - subtotal: %v; want: %v", cart.Subtotal, test.wantSubtotal)
+		}
+		if inCart := cart.Items[test.item.SizeID] != nil; inCart != (test.wantStatus == http.StatusOK) {
+			t.Errorf("FAIL - item %s in cart: %v", test.item.SizeID, inCart)
+		}
 	}
-
-	return nil
 }
@@ -1,12 +1,15 @@
 package main
 
-/* getOpenOrder API gets an open order from the OpenOrders table and returns it to the admin. */
+/* browseItems returns a page of StoreItemSummary objects for the given subcategory. Items are sorted
+   by price, name, date added or popularity and may be filtered by price range. The next_cursor value
+   of the response is passed as the 'cursor' query string to retrieve the following page. */
 
 import (
 	"log"
 	"net/http"
 
 	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
 )
@@ -16,31 +19,41 @@ const failMsg = "Request failed!"
 
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
-	dbops.Table{ // orders table
-		Name:       dbops.StoreItemsSummaryTable,
+	dbops.Table{ // store items summary table
+		Name:       dbops.StoreItemsSummaryTable(),
 		PrimaryKey: dbops.StoreItemSummaryPK,
 		SortKey:    dbops.StoreItemSummarySK,
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
-
 	// get query strings from GET call
 	params := httpops.GetQueryStringParams(r)
 	subcat := params["subcategory"]
+	opts, err := store.ParseBrowseOptions(params)
+	if err != nil {
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
 
-	// get open order
-	items, err := dbops.ScanItemsForCategory(DB, subcat)
+	// get page of items
+	page, err := dbops.BrowseItems(DB, subcat, opts)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
+		if err.Error() == store.ErrInvalidCursor || err.Error() == store.ErrInvalidBrowseOptions {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+			return
+		}
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	// return order to admin
-	httpops.ErrResponse(w, "Success! Returning items...", items, http.StatusOK)
+	// return items to user
+	httpops.ErrResponse(w, "Success! Returning items...", page, http.StatusOK)
 	return
 }
 
@@ -0,0 +1,85 @@
+package main
+
+/* getPreview API returns a signed, time-limited URL for the mp3 preview of a digital item.
+Previews of items whose exclusive license was sold are removed from the store. */
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/s3ops"
+)
+
+const route = "/browse/preview" // GET
+
+const failMsg = "Request failed!"
+const notFoundMsg = "Preview not found."
+
+// previewExpiry is the duration signed preview URLs are valid.
+const previewExpiry = 30 * time.Minute
+
+// preview contains the signed preview URL returned to the user.
+type preview struct {
+	ItemID    string `json:"item_id"`
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"` // unix timestamp (s)
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Assets is used to sign preview URLs
+var Assets s3ops.Signer = s3ops.NewS3Assets()
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	subcat, itemID := params["sub_category"], params["item_id"]
+	if subcat == "" || itemID == "" {
+		httpops.ErrResponse(w, "Bad Request: sub_category and item_id required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	item, err := DB.GetStoreItem(subcat, itemID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if !item.IsDigital() || item.PreviewKey == "" || item.SoldExclusive {
+		httpops.ErrResponse(w, "Preview not found: "+itemID, notFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	url, err := Assets.SignGetURL(s3ops.DigitalAssetsBucket(), item.PreviewKey, previewExpiry)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Preview: ", preview{
+		ItemID:    itemID,
+		URL:       url,
+		ExpiresAt: time.Now().Add(previewExpiry).Unix(),
+	}, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
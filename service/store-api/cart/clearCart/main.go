@@ -0,0 +1,89 @@
+package main
+
+/* clearCart API removes all items from the user's shopping cart. */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/cart/clear" // PUT
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+
+// request contains the ID of the user whose cart is cleared
+type request struct {
+	UserID string `json:"user_id"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK},
+	dbops.Table{ // shopping carts table
+		Name:       dbops.ShoppingCartsTable(),
+		PrimaryKey: dbops.ShoppingCartsPK,
+		SortKey:    ""},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := request{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// clear cart - retried if the cart is updated concurrently
+	cart, err := dbops.UpdateCart(DB, data.UserID, func(cart *store.ShoppingCart) error {
+		cart.Clear()
+		return nil
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		if dbops.IsVersionConflict(err) {
+			httpops.ErrResponse(w, "Cart is being updated by another request; try again", failMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Cart cleared: ", cart, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
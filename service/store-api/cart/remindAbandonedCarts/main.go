@@ -0,0 +1,141 @@
+package main
+
+/* remindAbandonedCarts runs on a schedule to send reminder emails for carts that have been idle
+   longer than CART_IDLE_HOURS and still have items in stock. Each cart is reminded at most
+   CART_MAX_REMINDERS times, once per idle period. If CART_REMINDER_DISCOUNT_PCT is set, each
+   reminder offers a one-time discount on the cart's items. Reminders are recorded on the cart;
+   idle carts are expired by the ShoppingCarts table's TTL (see dbops.CartTTL). */
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/sesops"
+)
+
+const route = "/cart/remind_abandoned" // POST
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+
+const from = "dg.dev.test510@gmail.com" // test only - move to admin settings db table in prod
+
+// reminder environment variables
+const (
+	envarIdleHours    = "CART_IDLE_HOURS"             // default 24
+	envarMaxReminders = "CART_MAX_REMINDERS"          // default 2
+	envarDiscountPct  = "CART_REMINDER_DISCOUNT_PCT"  // default 0 - no discount
+	envarDiscountDays = "CART_REMINDER_DISCOUNT_DAYS" // default 7
+)
+
+// reminderSummary contains the user IDs of the reminded carts.
+type reminderSummary struct {
+	Message string   `json:"message"`
+	UserIDs []string `json:"user_ids"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK,
+	},
+	dbops.Table{ // shopping carts table
+		Name:       dbops.ShoppingCartsTable(),
+		PrimaryKey: dbops.ShoppingCartsPK,
+		SortKey:    "",
+	},
+	dbops.Table{ // promotions table
+		Name:       dbops.PromotionsTable(),
+		PrimaryKey: dbops.PromotionsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	idle := time.Duration(envInt(envarIdleHours, 24)) * time.Hour
+	maxReminders := envInt(envarMaxReminders, 2)
+	discountPct := float64(envInt(envarDiscountPct, 0))
+	discountFor := time.Duration(envInt(envarDiscountDays, 7)) * 24 * time.Hour
+
+	carts, err := DB.ScanIdleCarts(now.Add(-idle).Unix())
+	if err != nil {
+		log.Printf("remindAbandonedCarts failed: %v", err)
+		httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	svc := sesops.InitSesh()
+	reminded := []string{}
+	for _, cart := range carts {
+		if !cart.ReminderDue(now, idle, maxReminders) {
+			continue
+		}
+		items, err := dbops.GetCartStoreItems(DB, cart)
+		if err != nil {
+			log.Printf("remindAbandonedCarts failed: %v", err)
+			continue
+		}
+		cart.Recalculate(items)
+		if len(cart.InStockItems(items)) == 0 {
+			continue
+		}
+
+		// record reminder before sending; skip carts updated since they were scanned
+		cart.Reminders = append(cart.Reminders, now.Unix())
+		if err := DB.PutShoppingCart(cart); err != nil {
+			log.Printf("remindAbandonedCarts: cart %s not reminded: %v", cart.UserID, err)
+			continue
+		}
+
+		var promo *store.Promotion
+		if discountPct > 0 {
+			promo, err = store.NewCartPromotion(cart, discountPct, now, discountFor)
+			if err == nil {
+				err = DB.PutPromotion(promo)
+			}
+			if err != nil {
+				log.Printf("remindAbandonedCarts: cart %s discount not created: %v", cart.UserID, err)
+				promo = nil
+			}
+		}
+
+		if err := sesops.SendCartReminder(svc, from, cart, items, promo); err != nil {
+			log.Printf("remindAbandonedCarts failed: %v", err)
+			continue
+		}
+		reminded = append(reminded, cart.UserID)
+	}
+	log.Printf("sent %d abandoned cart reminders", len(reminded))
+
+	summary := reminderSummary{Message: successMsg, UserIDs: reminded}
+	httpops.ErrResponse(w, "Sent abandoned cart reminders: ", summary, http.StatusOK)
+	return
+}
+
+// envInt returns the integer value of the environment variable, or def if it is unset or invalid.
+func envInt(name string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,113 @@
+package main
+
+/* updateCartItem API sets the quantity of an item size in the user's shopping cart. Setting the
+quantity to 0 removes the item. The quantity is validated against the units available to sell
+and the cart totals are recalculated from the current StoreItems. */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/cart/update-item" // PUT
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+const stockFailMsg = "Item is out of stock!"
+
+// request contains the item size and its new quantity
+type request struct {
+	UserID      string `json:"user_id"`
+	Subcategory string `json:"sub_category"`
+	ItemID      string `json:"item_id"`
+	Size        string `json:"size"`
+	Quantity    int    `json:"quantity"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK},
+	dbops.Table{ // shopping carts table
+		Name:       dbops.ShoppingCartsTable(),
+		PrimaryKey: dbops.ShoppingCartsPK,
+		SortKey:    ""},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := request{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// get authoritative item record
+	item, err := DB.GetStoreItem(data.Subcategory, data.ItemID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if item.ItemID == "" {
+		item.ItemID = data.ItemID // item removed from store - may still be removed from cart
+	}
+
+	// set quantity - retried if the cart is updated concurrently
+	cart, err := dbops.UpdateCart(DB, data.UserID, func(cart *store.ShoppingCart) error {
+		return cart.SetQuantity(item, data.Size, data.Quantity)
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		switch {
+		case err.Error() == store.ErrInvalidQuantity:
+			httpops.ErrResponse(w, "Bad Request: invalid quantity", failMsg, http.StatusBadRequest)
+		case err.Error() == store.ErrCartItemNotFound:
+			httpops.ErrResponse(w, "Item not in cart: "+store.NewSizeID(data.ItemID, data.Size), failMsg, http.StatusNotFound)
+		case err.Error() == store.ErrInsufficientStock:
+			httpops.ErrResponse(w, "Insufficient stock: "+store.NewSizeID(data.ItemID, data.Size), stockFailMsg, http.StatusConflict)
+		case dbops.IsVersionConflict(err):
+			httpops.ErrResponse(w, "Cart is being updated by another request; try again", failMsg, http.StatusConflict)
+		default:
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	httpops.ErrResponse(w, "Cart updated: ", cart, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,67 @@
+package main
+
+/* viewCart API returns the user's shopping cart with prices, totals and weights recalculated
+from the current StoreItems. Items whose price changed since they were added, or whose quantity
+exceeds the units available to sell, are flagged. */
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/cart/view" // GET
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+
+// cartView contains the recalculated cart and the size IDs of its flagged items.
+type cartView struct {
+	Cart    *store.ShoppingCart `json:"cart"`
+	Flagged []string            `json:"flagged"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK},
+	dbops.Table{ // shopping carts table
+		Name:       dbops.ShoppingCartsTable(),
+		PrimaryKey: dbops.ShoppingCartsPK,
+		SortKey:    ""},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	userID := params["user_id"]
+	if userID == "" {
+		httpops.ErrResponse(w, "Bad Request: user_id required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	cart, err := dbops.GetRecalculatedCart(DB, userID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Shopping cart: ", cartView{Cart: cart, Flagged: cart.Flagged()}, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -1,15 +1,19 @@
 package main
 
 // createOrder generates a new order after receiving user input shipping information.
-// Order total price is calculated after receiving user input for shipping option.
+// Order total price is calculated after receiving user input for shipping option. Sales tax
+// is calculated once the shipping address is known (see getShippingMethods). The order's
+// items are reserved with inventory holds that expire with the order (see releaseHolds).
+// Guests check out with a guest_id and email; the guest cart is merged into the cart of an
+// existing customer with the email, or checked out under a new guest customer record.
 
 import (
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/apex/gateway"
@@ -24,34 +28,43 @@ const route = "/checkout/new-order" // PUT
 
 const failMsg = "Request failed!"
 const successMsg = "Request succeeded!"
+const promoFailMsg = "Coupon code could not be applied!"
+const stockFailMsg = "Items are out of stock!"
+const conflictMsg = "Order could not be created; try again"
+const sizeFailMsg = "Too many items in cart! Please remove some items and try again."
 
-const CASalesTaxRate = .0725 // 7.25 % CA State sales tax rate
-const FeesTotal = 0.00
+const FeesTotal = 0 // fees in cents
 
 // customerInfo represents the form info submitted to the checkout page
 // IN-PROGRESS - get shipping cost (shippo api)
 type customerInfo struct {
-	UserID         string  `json:"user_id"`
-	UserEmail      string  `json:"user_email"`
-	FirstName      string  `json:"first_name"`
-	LastName       string  `json:"last_name"`
-	Company        string  `json:"company"`
-	AddressLine1   string  `json:"address_line_1"`
-	AddressLine2   string  `json:"address_line_2"`
-	City           string  `json:"city"`
-	State          string  `json:"state"`
-	Country        string  `json:"country"`
-	Zip            string  `json:"zip"`
-	ShippingMethod string  `json:"shipping_method"`
-	PhoneNumber    string  `json:"phone_number"`
-	ShippingCost   float32 `json:"shipping_cost"`
+	UserID         string      `json:"user_id"`
+	UserEmail      string      `json:"user_email"`
+	GuestID        string      `json:"guest_id"` // guest session cart to check out or merge
+	FirstName      string      `json:"first_name"`
+	LastName       string      `json:"last_name"`
+	Company        string      `json:"company"`
+	AddressLine1   string      `json:"address_line_1"`
+	AddressLine2   string      `json:"address_line_2"`
+	City           string      `json:"city"`
+	State          string      `json:"state"`
+	Country        string      `json:"country"`
+	Zip            string      `json:"zip"`
+	ShippingMethod string      `json:"shipping_method"`
+	PhoneNumber    string      `json:"phone_number"`
+	ShippingCost   store.Money `json:"shipping_cost"`
+	CouponCodes    []string    `json:"coupon_codes"`
 }
 
 type orderSummary struct {
-	Message    string            `json:"message"`
-	Items      []*store.CartItem `json:"items"`
-	TotalItems int               `json:"total_items"`
-	Subtotal   float32           `json:"subtotal"`
+	Message       string            `json:"message"`
+	Items         []*store.CartItem `json:"items"`
+	TotalItems    int               `json:"total_items"`
+	Subtotal      store.Money       `json:"subtotal"`
+	Discounts     []store.Discount  `json:"discounts"`
+	DiscountTotal store.Money       `json:"discount_total"`
+	SalesTax      store.Money       `json:"sales_tax"`
+	OrderTotal    store.Money       `json:"order_total"`
 }
 
 // list of tables function makes r/w calls to
@@ -61,25 +74,37 @@ var tables = []dbops.Table{
 		PrimaryKey: dbops.CustomersPK,
 		SortKey:    ""},
 	dbops.Table{ // store items table
-		Name:       dbops.StoreItemsTable,
+		Name:       dbops.StoreItemsTable(),
 		PrimaryKey: dbops.StoreItemPK,
 		SortKey:    dbops.StoreItemSK},
 	dbops.Table{ // shopping carts table
-		Name:       dbops.ShoppingCartsTable,
+		Name:       dbops.ShoppingCartsTable(),
 		PrimaryKey: dbops.ShoppingCartsPK,
 		SortKey:    ""},
 	dbops.Table{ // transactions table
-		Name:       dbops.OrdersTable,
+		Name:       dbops.OrdersTable(),
 		PrimaryKey: dbops.OrdersPK,
 		SortKey:    dbops.OrdersSK},
 	dbops.Table{ // transactions table
-		Name:       dbops.TransactionsTable,
+		Name:       dbops.TransactionsTable(),
 		PrimaryKey: dbops.TransactionsPK,
 		SortKey:    dbops.TransactionsSK},
+	dbops.Table{ // promotions table
+		Name:       dbops.PromotionsTable(),
+		PrimaryKey: dbops.PromotionsPK,
+		SortKey:    ""},
+	dbops.Table{ // promotion redemptions table
+		Name:       dbops.RedemptionsTable(),
+		PrimaryKey: dbops.RedemptionsPK,
+		SortKey:    dbops.RedemptionsSK},
+	dbops.Table{ // inventory holds table
+		Name:       dbops.HoldsTable(),
+		PrimaryKey: dbops.HoldsPK,
+		SortKey:    dbops.HoldsSK},
 }
 
 // / DB is used to make DynamoDB API calls
-var DB = dbops.InitDB(tables)
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
 
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -109,61 +134,156 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get customer
-	cust, err := dbops.GetCustomer(DB, data.UserEmail) // change to user_id
+	cust, err := DB.GetCustomer(data.UserEmail) // change to user_id
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	// get user cart
-	cart, err := dbops.GetShoppingCart(DB, cust.UserID)
+	// guest checkout - no account required
+	if data.GuestID != "" {
+		if data.UserEmail == "" || !store.IsGuestID(data.GuestID) {
+			httpops.ErrResponse(w, "Bad Request: invalid guest_id or user_email", failMsg, http.StatusBadRequest)
+			return
+		}
+		cust, err = guestCheckout(data, cust)
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// get user cart, recalculated from current item prices; the checkout email receives
+	// abandoned cart reminders
+	cart, err := dbops.UpdateCart(DB, cust.UserID, func(cart *store.ShoppingCart) error {
+		cart.UserEmail = cust.Email
+		return nil
+	})
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
+	// digital items are not held; licenses no longer sold (ex: beats sold exclusively) are
+	// rejected at checkout
+	if unavailable := cart.UnavailableLicenses(); len(unavailable) > 0 {
+		httpops.ErrResponse(w, "License not available: "+strings.Join(unavailable, ", "), stockFailMsg, http.StatusConflict)
+		return
+	}
+
+	// get promotions for coupon codes
+	promos, err := dbops.GetCustomerPromotions(DB, cust.UserID, data.CouponCodes)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		if promotionErr(err) {
+			httpops.ErrResponse(w, "Coupon code not applied: "+err.Error(), promoFailMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
 	// create order
-	order := createOrder(cust, cart)
+	order, err := createOrder(cust, cart, promos)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Coupon code not applied: "+err.Error(), promoFailMsg, http.StatusConflict)
+		return
+	}
 
-	// put order
-	err = dbops.PutOrder(DB, order)
+	// reserve order items until the order expires
+	holds := store.NewHolds(order, time.Now())
+	outOfStock, err := DB.ReserveItems(holds)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
+		if err.Error() == store.ErrInsufficientStock {
+			httpops.ErrResponse(w, "Out of stock: "+strings.Join(outOfStock, ", "), stockFailMsg, http.StatusConflict)
+			return
+		}
+		if err.Error() == dbops.ErrTransactionTooLarge {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), sizeFailMsg, http.StatusBadRequest)
+			return
+		}
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	// update customer record
-	// TO DO: update fields only with expression
-	err = dbops.PutCustomer(DB, cust)
+	// put order - fails with a version conflict if an order with the same ID was created
+	// by a concurrent request
+	err = DB.PutOrder(order)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
+		if rerr := DB.ReleaseHolds(holds); rerr != nil {
+			log.Printf("RootHandler failed: %v", rerr)
+		}
+		if dbops.IsVersionConflict(err) {
+			httpops.ErrResponse(w, "Order is being created by another request", conflictMsg, http.StatusConflict)
+			return
+		}
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	summary := orderSummary{Message: successMsg, Items: order.Items, TotalItems: order.TotalItems, Subtotal: order.SalesSubtotal}
+	// update customer record - retried with the latest record if updated concurrently
+	err = dbops.RetryOnConflict(func() error {
+		c, err := DB.GetCustomer(data.UserEmail)
+		if err != nil {
+			return err
+		}
+		openOrder(c, order.OrderID)
+		return DB.PutCustomer(c)
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	summary := orderSummary{
+		Message:       successMsg,
+		Items:         order.Items,
+		TotalItems:    order.TotalItems,
+		Subtotal:      order.SalesSubtotal,
+		Discounts:     order.Discounts,
+		DiscountTotal: order.DiscountTotal,
+		SalesTax:      order.SalesTax,
+		OrderTotal:    order.OrderTotal,
+	}
 
 	httpops.ErrResponse(w, "Successfully retreived site info: ", summary, http.StatusOK)
 	return
 }
 
-func createOrder(cust *store.Customer, cart *store.ShoppingCart) *store.Order {
+// createOrder creates a new order from the customer's cart and applies the promotions to the
+// order. Promotion redemptions are recorded once the order is paid.
+func createOrder(cust *store.Customer, cart *store.ShoppingCart, promos []*store.Promotion) (*store.Order, error) {
 	// crate order & set intitial fields
 	order := &store.Order{}
 	order.OrderID = generateOrderID(cust.UserID, cust.Orders)
 	order.UserID = cust.UserID
+	initTime := time.Now()
 
 	for _, item := range cart.Items {
 		order.Items = append(order.Items, item)
 	}
-	order.SalesSubtotal = round(cart.Subtotal)
-	// order.ShippingCost = round(info.ShippingCost)
-	order.SalesTax = round(order.SalesSubtotal * CASalesTaxRate)
-	order.ChargesAndFees = round(FeesTotal)
-	order.OrderTotal = order.SalesSubtotal + order.ShippingCost + order.SalesTax + order.ChargesAndFees
+	order.SalesSubtotal = cart.Subtotal
+	// order.ShippingCost = info.ShippingCost
+
+	// apply promotions
+	discounts, err := store.ApplyPromotions(promos, order.Items, order.SalesSubtotal, order.ShippingCost, initTime)
+	if err != nil {
+		log.Printf("createOrder failed: %v", err)
+		return order, err
+	}
+	itemDiscount, shippingDiscount := store.DiscountTotals(discounts)
+	order.Discounts = discounts
+	order.DiscountTotal = itemDiscount.Add(shippingDiscount)
+
+	order.ChargesAndFees = store.USD(FeesTotal)
+	order.UpdateTotal()
 	order.TotalItems = cart.TotalItems
 
 	// addr := createAddress(info)
@@ -174,7 +294,6 @@ func createOrder(cust *store.Customer, cart *store.ShoppingCart) *store.Order {
 	order.OrderWeightKgs = cart.CartWeightKgs
 
 	order.TtlMs = 600000 // 10 minutes
-	initTime := time.Now()
 	initTimeStr := timeops.ConvertToTimestampString(initTime)
 	orderDateStr := timeops.ConvertToDateString(initTime)
 	order.InitTime = initTimeStr
@@ -182,14 +301,64 @@ func createOrder(cust *store.Customer, cart *store.ShoppingCart) *store.Order {
 
 	order.OrderStatus = store.OrderStatusOpen
 
-	// update customer object
+	return order, nil
+}
+
+// openOrder records the new open order on the customer object.
+func openOrder(cust *store.Customer, orderID string) {
 	if !cust.OpenOrder {
 		cust.OpenOrder = true
 	}
 	cust.Orders += 1
-	cust.OpenOrderIDs = append(cust.OpenOrderIDs, order.OrderID)
+	cust.OpenOrderIDs = append(cust.OpenOrderIDs, orderID)
+}
 
-	return order
+// guestCheckout returns the customer checking out the guest cart. The guest cart is merged into
+// the cart of an existing customer with the checkout email; otherwise a guest customer is
+// created with the guest ID, so the guest cart is checked out as is.
+func guestCheckout(data customerInfo, cust *store.Customer) (*store.Customer, error) {
+	if cust.Email != "" {
+		if cust.UserID == data.GuestID {
+			return cust, nil // returning guest
+		}
+		_, err := dbops.MergeGuestCart(DB, data.GuestID, cust.UserID, dbops.MergeRulesFromEnv())
+		if err != nil {
+			log.Printf("guestCheckout failed: %v", err)
+			return cust, err
+		}
+		return cust, nil
+	}
+
+	guest := &store.Customer{
+		UserID:    data.GuestID,
+		Email:     data.UserEmail,
+		FirstName: data.FirstName,
+		LastName:  data.LastName,
+		Country:   data.Country,
+		City:      data.City,
+		JoinDate:  timeops.ConvertToDateString(time.Now()),
+		Guest:     true,
+	}
+	err := DB.PutCustomer(guest)
+	if dbops.IsVersionConflict(err) {
+		// created by a concurrent request
+		return DB.GetCustomer(data.UserEmail)
+	}
+	if err != nil {
+		log.Printf("guestCheckout failed: %v", err)
+		return guest, err
+	}
+	return guest, nil
+}
+
+// promotionErr returns true if err is caused by a coupon code that cannot be applied.
+func promotionErr(err error) bool {
+	switch err.Error() {
+	case store.ErrPromotionNotFound, store.ErrPromotionInactive, store.ErrPromotionNotApplicable,
+		store.ErrPromotionNotStackable, store.ErrPromotionLimitReached, store.ErrPromotionCustomerLimit:
+		return true
+	}
+	return false
 }
 
 func generateOrderID(userID string, orderCt int) string {
@@ -213,12 +382,6 @@ func createAddress(info customerInfo) store.Address {
 	return addr
 }
 
-func round(x float32) float32 {
-	price := float64(x)
-	unit := 0.01 // round float values to next cent
-	return float32(math.Ceil(price/unit) * unit)
-}
-
 func main() {
 	httpops.RegisterRoutes(route, RootHandler)
 	log.Fatal(gateway.ListenAndServe(":3000", nil))
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/shipops"
+)
+
+func TestRootHandler(t *testing.T) {
+	oakland := customerInfo{FirstName: "Jane", LastName: "Doe", AddressLine1: "3250 Hollis St", City: "Oakland", State: "CA", Country: "US", Zip: "94608"}
+	order := func(orderID string) customerInfo {
+		info := oakland
+		info.UserID, info.OrderID = "u01", orderID
+		return info
+	}
+	var tests = []struct {
+		info        customerInfo
+		wantStatus  int
+		wantRates   int
+		wantOrigins string // location IDs
+	}{
+		{info: order("u01-1"), wantStatus: http.StatusOK, wantRates: 3, wantOrigins: "[mod]"},
+		{info: customerInfo{UserID: "u01", OrderID: "u01-1", FirstName: "Jane", LastName: "Doe",
+			City: "Oakland", State: "CA", Country: "US", Zip: "94608"}, wantStatus: http.StatusBadRequest}, // no street
+		{info: customerInfo{UserID: "u01", OrderID: "u01-1", FirstName: "Jane", LastName: "Doe", AddressLine1: "2500 Kalakaua Ave",
+			City: "Honolulu", State: "HI", Country: "US", Zip: "96815"}, wantStatus: http.StatusConflict}, // rates hidden
+		{info: customerInfo{UserID: "u01", OrderID: "u01-2"}, wantStatus: http.StatusNotFound},
+		{info: order("u01-3"), wantStatus: http.StatusOK, wantRates: 3, wantOrigins: "[mod nyc]"}, // split shipment
+		{info: order("u01-4"), wantStatus: http.StatusConflict},                                   // not stocked
+		{info: order("u01-5"), wantStatus: http.StatusConflict},                                   // paid
+	}
+
+	DB = dbops.NewMemStore()
+	fake := shipops.NewFake()
+	Shipping = fake
+	DB.PutParcel(&store.Parcel{Carrier: store.CarriersUsps, ParcelID: "box-12x10x6", Name: "Medium Box", ParcelDimensions: store.Dimensions{
+		Length: "12", Width: "10", Height: "6", DistanceUnit: "in", Weight: "0.4", MassUnit: "lb", Volume: 720}})
+	shirt := func(sizeID string, qty int) *store.CartItem {
+		return &store.CartItem{ItemID: sizeID[:3], SizeID: sizeID, Subcategory: "shirts", Size: sizeID[4:], Quantity: qty, Price: store.USD(2295),
+			ItemSubtotal: store.USD(2295 * int64(qty)), ShippingDimensions: store.Dimensions{Length: "10", Width: "8", Height: "1", DistanceUnit: "in", Weight: "0.6", MassUnit: "lb"}}
+	}
+	DB.PutOrder(&store.Order{UserID: "u01", OrderID: "u01-1", OrderStatus: store.OrderStatusOpen, Items: []*store.CartItem{shirt("005-M", 2)},
+		SalesSubtotal: store.USD(4590)})
+	DB.PutOrder(&store.Order{UserID: "u01", OrderID: "u01-3", OrderStatus: store.OrderStatusOpen, Items: []*store.CartItem{shirt("005-M", 2), shirt("007-S", 1)},
+		SalesSubtotal: store.USD(6885)})
+	DB.PutOrder(&store.Order{UserID: "u01", OrderID: "u01-4", OrderStatus: store.OrderStatusOpen, Items: []*store.CartItem{shirt("008-L", 1)},
+		SalesSubtotal: store.USD(2295)})
+	DB.PutOrder(&store.Order{UserID: "u01", OrderID: "u01-5", OrderStatus: store.OrderStatusPaid, Items: []*store.CartItem{shirt("005-M", 1)},
+		SalesSubtotal: store.USD(2295)})
+
+	// the nearest location stocking each unit ships the order; inactive locations are not used
+	DB.PutLocation(&store.Location{LocationID: "mod", LocationType: store.LocationWarehouse, Active: true,
+		Address:   store.Address{Company: "Modesto Warehouse", AddressLine1: "100 Test Way", City: "Modesto", State: "CA", Country: "US", Zip: "95355"},
+		Inventory: map[string]int{"005-M": 5, "007-S": 0}})
+	DB.PutLocation(&store.Location{LocationID: "nyc", LocationType: store.LocationStudio, Active: true,
+		Address:   store.Address{Company: "NYC Studio", AddressLine1: "1 Main St", City: "New York", State: "NY", Country: "US", Zip: "10001"},
+		Inventory: map[string]int{"005-M": 1, "007-S": 3}})
+	DB.PutLocation(&store.Location{LocationID: "old", LocationType: store.LocationWarehouse,
+		Address:   store.Address{AddressLine1: "1 Old Rd", City: "Oakland", State: "CA", Country: "US", Zip: "94607"},
+		Inventory: map[string]int{"005-M": 100, "007-S": 100, "008-L": 100}})
+	DB.PutShippingRule(&store.ShippingRule{RuleID: "handling", RuleType: store.RuleMarkup, Amount: store.USD(100), Active: true})
+	DB.PutShippingRule(&store.ShippingRule{RuleID: "no-hawaii", RuleType: store.RuleHideService, States: []string{"HI"}, Active: true})
+
+	for _, test := range tests {
+		js, err := json.Marshal(test.info)
+		if err != nil {
+			t.Fatalf("FAIL: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPut, route, bytes.NewReader(js))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		RootHandler(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("FAIL - status: %d; want: %d", w.Code, test.wantStatus)
+		}
+		if test.wantStatus != http.StatusOK {
+			continue
+		}
+
+		shipment, err := DB.GetShipment(test.info.UserID, test.info.OrderID)
+		if err != nil || len(shipment.Rates) != test.wantRates {
+			t.Fatalf("FAIL: %v, %v; want: %d rates", err, shipment, test.wantRates)
+		}
+		origins := []string{}
+		for _, o := range shipment.Origins {
+			origins = append(origins, o.LocationID)
+		}
+		if fmt.Sprint(origins) != test.wantOrigins || len(shipment.Packages) != len(origins) {
+			t.Errorf("FAIL - origins: %v, %d packages; want: %s", origins, len(shipment.Packages), test.wantOrigins)
+		}
+		if shipment.AddressFrom != shipment.Origins[0].AddressFrom {
+			t.Errorf("FAIL - address from: %v; want: %v", shipment.AddressFrom, shipment.Origins[0].AddressFrom)
+		}
+		if shipment.Packages[0].Dimensions.Weight != "1.60" {
+			t.Errorf("FAIL - package weight: %s; want: 1.60", shipment.Packages[0].Dimensions.Weight)
+		}
+		if items := shipment.Packages[0].Items; len(items) != 1 || items[0].SizeID != "005-M" || items[0].Quantity != 2 {
+			t.Errorf("FAIL - package items: %v; want: 2 x 005-M", items)
+		}
+
+		// purchase a label at the selected rate
+		shipment.SelectedRate = shipment.Rates[0]
+		if err := shipops.PurchaseShippingLabel(Shipping, shipment, nil); err != nil {
+			t.Fatalf("FAIL: %v", err)
+		}
+		if len(shipment.Labels) != len(shipment.Origins) {
+			t.Fatalf("FAIL - labels: %v; want: %d", shipment.Labels, len(shipment.Origins))
+		}
+		labelPrice := store.USD(0)
+		for _, l := range shipment.Labels {
+			price, _ := store.ParseMoney(l.Price, l.Currency)
+			labelPrice = labelPrice.Add(price)
+		}
+		if labelPrice.String() != shipment.Rates[0].CarrierPrice {
+			t.Errorf("FAIL - label price: %s; want: %s", labelPrice, shipment.Rates[0].CarrierPrice)
+		}
+		label := shipment.Labels[0]
+		carrier, _ := store.ParseMoney(shipment.Rates[0].CarrierPrice, shipment.Rates[0].Currency)
+		if want := carrier.Add(store.USD(100)).String(); shipment.Rates[0].Price != want {
+			t.Errorf("FAIL - rate price: %s; want: %s", shipment.Rates[0].Price, want)
+		}
+		if tracking, err := fake.Track(label.Carrier, label.TrackingNumber); err != nil || tracking.Status != shipops.TrackingPreTransit {
+			t.Errorf("FAIL: %v, %v; want: %s", err, tracking, shipops.TrackingPreTransit)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
-/* payment API processes a customer's payment during the order checkout process. Inventory is updated on
-   receipt of payment, and the processOrder service is notified of the sucessful payment. A receipt is returned
-   to the customer upon completion. */
+/* payment API processes a customer's payment during the order checkout process. The order's inventory holds
+   are converted to sales once the payment is authorized, and the order is staged before the payment is captured.
+   Digital-only orders are not shipped; their sales tax is calculated for the billing address.
+   The lease contract of each digital item must be signed before payment is processed.
+   A receipt is returned to the customer upon completion. */
 
 import (
 	"encoding/json"
@@ -10,13 +12,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/apex/gateway"
 	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/paymentops"
 	"github.com/tpillz-presents/service/util/queueops"
+	"github.com/tpillz-presents/service/util/taxops"
 	"github.com/tpillz-presents/service/util/timeops"
 )
 
@@ -24,9 +29,13 @@ const route = "/checkout/payment" // PUT
 
 const failMsg = "Request failed!"
 const successMsg = "Request succeeded!"
+const shippingAddressMsg = "Please enter your shipping address before submitting payment."
+const shippingRateMsg = "Please select a shipping option before submitting payment."
+const billingAddressMsg = "Please enter a valid billing address before submitting payment."
 const orderTimeoutMsg = "Order expired! Please restart the checkout process and try again."
-
-const CASalesTaxRate = .0725 // 7.25 % CA State sales tax rate
+const paymentFailMsg = "Payment failed! Please check your payment info and try again."
+const contractMsg = "Please sign the license agreement of each beat in your order before submitting payment."
+const promoFailMsg = "A coupon code applied to your order is no longer available. Please restart the checkout process and try again."
 
 type customerInfo struct {
 	UserID          string `json:"user_id"`
@@ -59,6 +68,11 @@ type billingInfo struct {
 	SaveInfo       bool   `json:"save_info"`
 }
 
+// paymentAction is returned to the client when the payment requires customer authentication (3DS).
+type paymentAction struct {
+	ClientSecret string `json:"client_secret"`
+}
+
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // customers table
@@ -67,29 +81,55 @@ var tables = []dbops.Table{
 		SortKey:    "",
 	},
 	dbops.Table{ // store items table
-		Name:       dbops.StoreItemsTable,
+		Name:       dbops.StoreItemsTable(),
 		PrimaryKey: dbops.StoreItemPK,
 		SortKey:    dbops.StoreItemSK,
 	},
 	dbops.Table{ // shopping carts table
-		Name:       dbops.ShoppingCartsTable,
+		Name:       dbops.ShoppingCartsTable(),
 		PrimaryKey: dbops.ShoppingCartsPK,
 		SortKey:    "",
 	},
 	dbops.Table{ // transactions table
-		Name:       dbops.TransactionsTable,
+		Name:       dbops.TransactionsTable(),
 		PrimaryKey: dbops.TransactionsPK,
 		SortKey:    dbops.TransactionsSK,
 	},
 	dbops.Table{ // orders table
-		Name:       dbops.OrdersTable,
+		Name:       dbops.OrdersTable(),
 		PrimaryKey: dbops.OrdersPK,
 		SortKey:    dbops.OrdersSK,
 	},
+	dbops.Table{ // promotions table
+		Name:       dbops.PromotionsTable(),
+		PrimaryKey: dbops.PromotionsPK,
+		SortKey:    "",
+	},
+	dbops.Table{ // promotion redemptions table
+		Name:       dbops.RedemptionsTable(),
+		PrimaryKey: dbops.RedemptionsPK,
+		SortKey:    dbops.RedemptionsSK,
+	},
+	dbops.Table{ // shipments table
+		Name:       dbops.ShipmentsTable(),
+		PrimaryKey: dbops.ShipmentsPK,
+		SortKey:    dbops.ShipmentsSK,
+	},
+	dbops.Table{ // inventory holds table
+		Name:       dbops.HoldsTable(),
+		PrimaryKey: dbops.HoldsPK,
+		SortKey:    dbops.HoldsSK,
+	},
 }
 
 // / DB is used to make DynamoDB API calls
-var DB = dbops.InitDB(tables)
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Payments is used to process customer payments
+var Payments paymentops.Provider = paymentops.NewStripeFromEnv()
+
+// Tax is used to calculate the sales tax of digital-only orders
+var Tax taxops.Calculator = taxops.NewCalculatorFromEnv()
 
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -119,7 +159,7 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get customer
-	cust, err := dbops.GetCustomer(DB, data.UserEmail)
+	cust, err := DB.GetCustomer(data.UserEmail)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -128,7 +168,7 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 
 	// get order
 	orderID := generateOrderID(cust.UserID, cust.Orders)
-	order, err := dbops.GetOrder(DB, cust.UserID, orderID)
+	order, err := DB.GetOrder(cust.UserID, orderID)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
@@ -151,13 +191,106 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// digital items require a signed lease contract
+	if unsigned := order.UnsignedItems(); len(unsigned) > 0 {
+		log.Printf("RootHandler failed: %v: %v", store.ErrContractRequired, unsigned)
+		httpops.ErrResponse(w, "Contract required: "+strings.Join(unsigned, ", "), contractMsg, http.StatusConflict)
+		return
+	}
+
+	// sales tax is calculated once the shipping address is entered; digital-only orders
+	// are taxed at the billing address
+	if !order.RequiresShipping() {
+		err = taxops.ApplyTax(Tax, order, billingAddress(data))
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			if err.Error() == taxops.ErrInvalidAddress {
+				httpops.ErrResponse(w, "Invalid billing address: "+err.Error(), billingAddressMsg, http.StatusBadRequest)
+				return
+			}
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			return
+		}
+	}
+	if order.TaxBreakdown.Jurisdiction == "" {
+		httpops.ErrResponse(w, "Shipping address required", shippingAddressMsg, http.StatusConflict)
+		return
+	}
+
+	// physical orders are shipped with the rate selected by the customer (see selectShippingRate)
+	if order.RequiresShipping() {
+		shipment, err := DB.GetShipment(order.UserID, order.OrderID)
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			return
+		}
+		if shipment.SelectedRate.Price == "" {
+			httpops.ErrResponse(w, "Shipping rate required", shippingRateMsg, http.StatusConflict)
+			return
+		}
+	}
+
 	// create transaction object
 	tx := createTx(cust.UserID, order)
 
 	// update order
-	updateOrder(data, cust, tx, order)
+	err = updateOrder(data, cust, tx, order)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Order is not open for payment: "+err.Error(), failMsg, http.StatusConflict)
+		return
+	}
+
+	// redeem promotions applied to order
+	promos, err := redeemPromotions(order)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		switch err.Error() {
+		case store.ErrPromotionNotFound, store.ErrPromotionCustomerLimit, store.ErrPromotionLimitReached:
+			httpops.ErrResponse(w, "Promotion not available: "+err.Error(), promoFailMsg, http.StatusConflict)
+		default:
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// authorize payment; the order is staged once the payment is authorized, and the payment
+	// is captured once the order is staged
+	payment, err := authorizePayment(data.PaymentToken, cust, order, tx)
+	authorized := err == nil && payment.Status == paymentops.StatusAuthorized
+	pending := err == nil && payment.Status == paymentops.StatusRequiresAction
+
+	// update transaction object with payment info
+	updateTx(tx, payment, err)
+
+	// order is not staged if payment is not authorized; inventory holds remain active until
+	// the order expires, so the customer can retry payment
+	if !authorized && !pending {
+		releasePromotions(order, promos)
+		httpops.ErrResponse(w, "Payment failed: "+tx.PaymentMessage, paymentFailMsg, http.StatusPaymentRequired)
+		return
+	}
 
-	// stage objects for processing
+	// convert inventory holds placed at order creation to sales; digital items are not held
+	if order.RequiresShipping() {
+		err = dbops.ConvertOrderHolds(DB, order.OrderID, time.Now().Unix())
+		if err != nil {
+			log.Printf("RootHandler failed: %v", err)
+			voidPayment(payment)
+			releasePromotions(order, promos)
+			switch err.Error() {
+			case store.ErrHoldNotActive, dbops.ErrConditionalCheck:
+				httpops.ErrResponse(w, "Inventory hold expired: "+err.Error(), orderTimeoutMsg, http.StatusConflict)
+			default:
+				httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	// stage objects for processing; inventory holds are converted before the order is staged,
+	// so staging does not update the held items' counts
 	stage := queueops.Staging{
 		Order:       order,
 		Customer:    cust,
@@ -168,6 +301,8 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	url, err := queueops.GetQueueURL(sqs, queueops.StagingFifoQueue)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
+		voidPayment(payment)
+		cancelCheckout(order, promos)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
@@ -175,113 +310,38 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		log.Printf("staged order: %v", stage)
+		voidPayment(payment)
+		cancelCheckout(order, promos)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 	log.Printf("staging message sent: %v", msgID)
 
-	// verify item in stock
-	out := []string{}
-	rollback := []*store.CartItem{}
-	stockOk := true
-	for _, item := range order.Items {
-		_, err := dbops.UpdateInventoryCount(DB, item.Subcategory, item.ItemID, item.Size, item.Quantity)
-		if err != nil {
-			if err.Error() == dbops.ErrConditionalCheck {
-				stockOk = false
-				out = append(out, item.Name)
-				log.Printf("RootHandler: item %s out of stock", item.ItemID)
-			}
-			log.Printf("RootHandler failed: %v", err)
-			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
-			break
-		}
-		rollback = append(rollback, item)
-	}
-	if !stockOk {
-		// send rollback message
-		update := queueops.InventoryUpdate{
-			UserEmail: order.UserID,
-			OrderID:   order.OrderID,
-			Items:     rollback,
-		}
-		url, err := queueops.GetQueueURL(sqs, queueops.InventoryUpdateFifoQueue)
-		if err != nil {
-			log.Printf("RootHandler failed: %v", err)
-			msg := "Request failed! Order not processed."
-			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), msg, http.StatusInternalServerError)
-			return
-		}
-		msgId, err := queueops.SendInventoryUpdateMessage(sqs, url, update)
-		if err != nil {
-			msg := "Request failed! Order not processed."
-			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), msg, http.StatusInternalServerError)
-			return
-		}
-		log.Printf("rollback msgId: %s", msgId)
-		// return to user
-		httpops.ErrResponse(w, "ITEM_OUT_OF_STOCK", out, http.StatusOK)
+	// payments requiring customer authentication are completed by the client and captured
+	// by the payment webhook
+	if pending {
+		httpops.ErrResponse(w, "Payment requires authentication: ", paymentAction{ClientSecret: payment.ClientSecret}, http.StatusAccepted)
 		return
 	}
 
-	// process payment
-	// IN PROGRESS
-	paymentOk := true
-	// rollback db updates if payment fails
-	if !paymentOk {
-		// send rollback message
-		update := queueops.InventoryUpdate{
-			UserEmail: order.UserID,
-			OrderID:   order.OrderID,
-			Items:     order.Items,
-		}
-		url, err := queueops.GetQueueURL(sqs, queueops.InventoryUpdateFifoQueue)
-		if err != nil {
-			log.Printf("RootHandler failed: %v", err)
-			msg := "Request failed! Order not processed."
-			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), msg, http.StatusInternalServerError)
-			return
-		}
-		msgId, err := queueops.SendInventoryUpdateMessage(sqs, url, update)
-		if err != nil {
-			msg := "Request failed! Order not processed."
-			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), msg, http.StatusInternalServerError)
-			return
-		}
-		log.Printf("rollback msgId: %s", msgId)
-		/* for _, item := range order.Items {
-			neg := item.Quantity * -1
-			err := dbops.UpdateInventoryCount(DB, item.Subcategory, item.ItemID, item.Size, neg)
-			if err != nil {
-				if err.Error() == dbops.ErrConditionalCheck {
-					log.Printf("RootHandler failed: item %s out of stock", item.ItemID)
-					msg := fmt.Sprintf("ITEM_OUT_OF_STOCK-%s", item.ItemID
-				}
-				log.Printf("RootHandler failed: %v", err)
-				httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
-			}
-		} */
-	}
-
-	// update transaction object with payment info on payment completion
-	// IN PROGRESS
-	updateTx(tx, order)
-
-	// send payment confirmation message
-	status := createPaymentStatus(cust, order, tx)
-	url, err = queueops.GetQueueURL(sqs, queueops.PaymentStatusFifoQueue)
+	// capture payment; payment status messages are sent to the Payment Status queue by the
+	// payment webhook, and the staged order is failed, restocked and its promotions released by
+	// processOrder when the authorization is voided
+	captured, err := capturePayment(payment, order)
 	if err != nil {
-		log.Printf("RootHandler failed: %v", err)
-		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		updateTx(tx, captured, err)
+		httpops.ErrResponse(w, "Payment failed: "+tx.PaymentMessage, paymentFailMsg, http.StatusPaymentRequired)
 		return
 	}
-	msgID, err = queueops.SendPaymentStatusMessage(sqs, url, status)
+
+	// remove purchased items from cart
+	_, err = dbops.UpdateCart(DB, order.UserID, func(cart *store.ShoppingCart) error {
+		cart.RemoveOrdered(order)
+		return nil
+	})
 	if err != nil {
-		log.Printf("RootHandler failed: %v", err)
-		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
-		return
+		log.Printf("RootHandler failed: %v", err) // order succeeded; cart is stale
 	}
-	log.Printf("payment status message sent: %v", msgID)
 
 	// generate customer receipt to return to user
 	receipt := order.NewReceipt()
@@ -301,6 +361,8 @@ func createTx(custID string, order *store.Order) *store.Transaction {
 		OrderID:        order.OrderID,
 		Timestamp:      timeops.ConvertToTimestampString(time.Now()),
 		SalesSubtotal:  order.SalesSubtotal,
+		Discounts:      order.Discounts,
+		DiscountTotal:  order.DiscountTotal,
 		SalesTax:       order.SalesTax,
 		ShippingCost:   order.ShippingCost,
 		ChargesAndFees: order.ChargesAndFees,
@@ -311,30 +373,20 @@ func createTx(custID string, order *store.Order) *store.Transaction {
 	return tx
 }
 
-func updateOrder(info billingInfo, cust *store.Customer, tx *store.Transaction, order *store.Order) {
-	// order.Paid = true
-	order.OrderStatus = store.OrderStatusPaymentInProgress
+func updateOrder(info billingInfo, cust *store.Customer, tx *store.Transaction, order *store.Order) error {
+	_, err := order.Apply(store.OrderEventCheckout)
+	if err != nil {
+		return err
+	}
 
 	order.TransactionID = tx.TransactionID
 	order.TxTimestamp = tx.Timestamp
+	order.PromotionsReleased = false // promotions are redeemed again for this payment
 
-	// set shipping address
-	if info.SameAsShipping {
+	// set billing address
+	order.BillingAddress = billingAddress(info)
+	if info.SameAsShipping && order.RequiresShipping() {
 		order.BillingAddress = order.ShippingAddress
-	} else {
-		address := store.Address{
-			FirstName:    info.FirstName,
-			LastName:     info.LastName,
-			Company:      info.Company,
-			AddressLine1: info.AddressLine1,
-			AddressLine2: info.AddressLine2,
-			City:         info.City,
-			State:        info.State,
-			Country:      info.Country,
-			Zip:          info.Zip,
-			PhoneNumber:  info.PhoneNumber,
-		}
-		order.BillingAddress = address
 	}
 
 	// update customer info
@@ -342,10 +394,27 @@ func updateOrder(info billingInfo, cust *store.Customer, tx *store.Transaction,
 		cust.ShippingAddress = order.ShippingAddress
 		cust.BillingAddress = order.BillingAddress
 	}
-	cust.Purchases += order.TotalItems
+	// purchases are counted when the order is staged (see stageOrder)
 	// update following after payment confirmed
 	// cust.TotalSpent += tx.TotalAmount
 	// cust.OpenOrder = false
+	return nil
+}
+
+// billingAddress returns the billing address entered by the customer.
+func billingAddress(info billingInfo) store.Address {
+	return store.Address{
+		FirstName:    info.FirstName,
+		LastName:     info.LastName,
+		Company:      info.Company,
+		AddressLine1: info.AddressLine1,
+		AddressLine2: info.AddressLine2,
+		City:         info.City,
+		State:        info.State,
+		Country:      info.Country,
+		Zip:          info.Zip,
+		PhoneNumber:  info.PhoneNumber,
+	}
 }
 
 func createReceipt(cust *store.Customer, order *store.Order) store.Receipt {
@@ -356,6 +425,8 @@ func createReceipt(cust *store.Customer, order *store.Order) store.Receipt {
 		UserEmail:       cust.Email,
 		OrderSummary:    order.Items,
 		SalesSubtotal:   order.SalesSubtotal,
+		Discounts:       order.Discounts,
+		DiscountTotal:   order.DiscountTotal,
 		ShippingCost:    order.ShippingCost,
 		SalesTax:        order.SalesTax,
 		ChargesAndFees:  order.ChargesAndFees,
@@ -366,25 +437,116 @@ func createReceipt(cust *store.Customer, order *store.Order) store.Receipt {
 	return receipt
 }
 
-func createPaymentStatus(cust *store.Customer, order *store.Order, tx *store.Transaction) store.PaymentStatus {
-	status := store.PaymentStatus{
-		CustomerEmail: cust.Email,
-		CustomerID:    cust.UserID,
-		OrderID:       order.OrderID,
-		TransactionID: tx.TransactionID,
-		PaymentMethod: tx.PaymentMethod,
-		PaymentTxID:   tx.PaymentTxID,
-		TxStatus:      tx.PaymentStatus,
-		TxMessage:     tx.PaymentMessage,
-	}
-	return status
+// redeemPromotions records the customer's redemption of the promotions applied to the order.
+// Returns the redeemed promotions, or ErrPromotionLimitReached / ErrPromotionCustomerLimit if a
+// promotion's usage limit was reached after the order was created.
+func redeemPromotions(order *store.Order) ([]*store.Promotion, error) {
+	codes := []string{}
+	for _, d := range order.Discounts {
+		codes = append(codes, d.Code)
+	}
+	if len(codes) == 0 {
+		return []*store.Promotion{}, nil
+	}
+	promos, err := dbops.GetCustomerPromotions(DB, order.UserID, codes)
+	if err != nil {
+		log.Printf("redeemPromotions failed: %v", err)
+		return promos, err
+	}
+	err = DB.RedeemPromotions(order.UserID, promos)
+	if err != nil {
+		log.Printf("redeemPromotions failed: %v", err)
+		return promos, err
+	}
+	return promos, nil
+}
+
+// releasePromotions reverses the redemption of the order's promotions when the order is not
+// paid. Failures are logged for manual adjustment.
+func releasePromotions(order *store.Order, promos []*store.Promotion) {
+	err := DB.ReleasePromotions(order.UserID, promos)
+	if err != nil {
+		log.Printf("releasePromotions failed: %s: %v", order.OrderID, err)
+	}
+}
+
+// cancelCheckout reverses the redemption of the order's promotions and the conversion of its
+// inventory holds when the order is not paid. Failures are logged for manual adjustment.
+func cancelCheckout(order *store.Order, promos []*store.Promotion) {
+	releasePromotions(order, promos)
+	if err := rollbackInventory(order); err != nil {
+		log.Printf("cancelCheckout failed: %s: %v", order.OrderID, err)
+	}
+}
+
+// rollbackInventory restocks the units of the order's converted inventory holds.
+func rollbackInventory(order *store.Order) error {
+	if !order.RequiresShipping() {
+		return nil // digital items are not held
+	}
+	if err := dbops.RestockOrderHolds(DB, order.OrderID); err != nil {
+		log.Printf("rollbackInventory failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// authorizePayment authorizes the order total with the customer's payment token.
+func authorizePayment(token string, cust *store.Customer, order *store.Order, tx *store.Transaction) (*paymentops.Payment, error) {
+	req := paymentops.AuthorizeRequest{
+		Token:          token,
+		Amount:         order.OrderTotal,
+		OrderID:        order.OrderID,
+		CustomerID:     cust.UserID,
+		CustomerEmail:  cust.Email,
+		TransactionID:  tx.TransactionID,
+		IdempotencyKey: tx.TransactionID,
+	}
+	payment, err := Payments.Authorize(req)
+	if err != nil {
+		log.Printf("authorizePayment failed: %v", err)
+		return payment, err
+	}
+	return payment, nil
+}
+
+// capturePayment captures the order total from the authorized payment. Authorizations that
+// fail to capture are voided.
+func capturePayment(payment *paymentops.Payment, order *store.Order) (*paymentops.Payment, error) {
+	captured, err := Payments.Capture(payment.ID, order.OrderTotal)
+	if err != nil {
+		// the capture may have succeeded before the error was returned (ex: timeout)
+		if p, rerr := Payments.Retrieve(payment.ID); rerr == nil && p.Status == paymentops.StatusCaptured {
+			return p, nil
+		}
+		log.Printf("capturePayment failed: %v", err)
+		voidPayment(payment)
+		return payment, err
+	}
+	return captured, nil
+}
+
+// voidPayment cancels the authorized payment of an order that was not completed. Failures are
+// logged for manual adjustment; uncaptured authorizations expire after 7 days.
+func voidPayment(payment *paymentops.Payment) {
+	if _, err := Payments.Void(payment.ID); err != nil {
+		log.Printf("voidPayment failed: %s: %v", payment.ID, err)
+	}
 }
 
-func updateTx(tx *store.Transaction, order *store.Order) {
-	tx.PaymentMethod = "TEST"
-	tx.PaymentTxID = "TEST0001"
-	// payment tx timestamp?
-	tx.PaymentStatus = store.PaymentStatusSuccess
+// updateTx updates the transaction with the payment's provider data.
+func updateTx(tx *store.Transaction, payment *paymentops.Payment, err error) {
+	tx.PaymentMethod = Payments.Name()
+	tx.PaymentTxID = payment.ID
+	tx.PaymentStatus = payment.PaymentStatus()
+	tx.ChargesAndFees = payment.Fee
+	tx.PaymentMessage = payment.Message
+	if err != nil {
+		tx.PaymentStatus = store.PaymentStatusFail
+		if tx.PaymentMessage == "" {
+			tx.PaymentMessage = err.Error()
+		}
+	}
 }
 
 func main() {
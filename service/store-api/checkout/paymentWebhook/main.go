@@ -0,0 +1,168 @@
+package main
+
+/* paymentWebhook receives Stripe webhook events and forwards payment outcomes to the Payment Status
+   queue, where they are processed by the processOrder service. Events are verified with the
+   endpoint's signing secret and deduplicated by event ID. */
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/paymentops"
+	"github.com/tpillz-presents/service/util/queueops"
+	"github.com/tpillz-presents/service/util/timeops"
+)
+
+const route = "/checkout/payment/webhook" // POST
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+
+// eventTTL is the retention period of processed event records. Stripe retries
+// undelivered events for up to 3 days.
+const eventTTL = 30 * 24 * time.Hour
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // webhook events table
+		Name:       dbops.WebhookEventsTable(),
+		PrimaryKey: dbops.WebhookEventsPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Payments is used to retrieve payment info
+var Payments paymentops.Provider = paymentops.NewStripeFromEnv()
+
+// SendStatus enqueues payment statuses for processing
+var SendStatus = sendPaymentStatus
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// verify signature
+	sig := r.Header.Get(paymentops.StripeSignatureHeader)
+	err = paymentops.VerifyStripeSignature(payload, sig, paymentops.StripeWebhookSecret(), paymentops.DefaultSignatureTolerance)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	event, err := paymentops.ParseStripeEvent(payload)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// deduplicate events
+	now := time.Now()
+	record := &store.WebhookEvent{
+		EventID:    event.ID,
+		Provider:   Payments.Name(),
+		Type:       event.Type,
+		ReceivedAt: timeops.ConvertToTimestampString(now),
+		TTL:        now.Add(eventTTL).Unix(),
+	}
+	err = DB.PutWebhookEvent(record)
+	if err != nil {
+		if err.Error() == dbops.ErrConditionalCheck {
+			log.Printf("duplicate event: %s", event.ID)
+			httpops.ErrResponse(w, "Duplicate event: "+event.ID, successMsg, http.StatusOK)
+			return
+		}
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	err = handleEvent(event)
+	if err != nil {
+		// release event so it is processed when Stripe retries delivery
+		if dErr := DB.DeleteWebhookEvent(event.ID); dErr != nil {
+			log.Printf("RootHandler failed: %v", dErr)
+		}
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Event received: "+event.ID, successMsg, http.StatusOK)
+	return
+}
+
+// handleEvent captures payments authorized after customer authentication, and sends the
+// payment status of events that change a payment's status to the Payment Status queue.
+func handleEvent(event *paymentops.StripeEvent) error {
+	if event.Type == paymentops.EventPaymentCapturable {
+		paymentID, err := event.PaymentID()
+		if err != nil {
+			log.Printf("handleEvent failed: %v", err)
+			return err
+		}
+		payment, err := Payments.Retrieve(paymentID)
+		if err != nil {
+			log.Printf("handleEvent failed: %v", err)
+			return err
+		}
+		// payments that did not require authentication are captured by the payment API
+		if payment.Status != paymentops.StatusAuthorized || !payment.CapturedByWebhook() {
+			return nil
+		}
+		// payment_intent.succeeded is sent on capture
+		_, err = Payments.Capture(paymentID, payment.Amount)
+		if err != nil {
+			log.Printf("handleEvent failed: %v", err)
+			return err
+		}
+		return nil
+	}
+
+	status, ok, err := event.PaymentStatus(Payments)
+	if err != nil {
+		log.Printf("handleEvent failed: %v", err)
+		return err
+	}
+	if !ok {
+		log.Printf("ignored event: %s (%s)", event.ID, event.Type)
+		return nil
+	}
+	return SendStatus(status)
+}
+
+// sendPaymentStatus sends the status to the Payment Status queue.
+func sendPaymentStatus(status store.PaymentStatus) error {
+	sqs := queueops.InitSesh()
+	url, err := queueops.GetQueueURL(sqs, queueops.PaymentStatusFifoQueue)
+	if err != nil {
+		log.Printf("sendPaymentStatus failed: %v", err)
+		return err
+	}
+	msgID, err := queueops.SendPaymentStatusMessage(sqs, url, status)
+	if err != nil {
+		log.Printf("sendPaymentStatus failed: %v", err)
+		return err
+	}
+	log.Printf("payment status message sent: %v", msgID)
+	return nil
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
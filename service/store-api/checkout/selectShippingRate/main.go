@@ -0,0 +1,188 @@
+package main
+
+/* selectShippingRate API sets the shipping rate selected by the customer from the rates quoted
+for the order's shipping address (see getShippingMethods). The rate is saved as the shipment's
+SelectedRate, used to purchase the shipping label, and its price is set as the order's shipping
+cost. Free shipping discounts, sales tax and the order total are recalculated. */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/taxops"
+)
+
+const route = "/checkout/shipping-rate" // PUT
+
+const failMsg = "Request failed!"
+const orderClosedMsg = "Order is not open for checkout."
+const shippingAddressMsg = "Please enter your shipping address before selecting a shipping option."
+const rateNotFoundMsg = "Shipping option is not available; please select another shipping option."
+
+// rateInfo contains the service level selected by the customer
+type rateInfo struct {
+	UserID       string `json:"user_id"`
+	OrderID      string `json:"order_id"`
+	ServiceLevel string `json:"service_level"` // service level token (ex: 'usps_priority')
+}
+
+// rateSummary contains the selected rate and the updated order totals returned to the user
+type rateSummary struct {
+	SelectedRate  store.RateSummary  `json:"selected_rate"`
+	ShippingCost  store.Money        `json:"shipping_cost"`
+	DiscountTotal store.Money        `json:"discount_total"`
+	SalesTax      store.Money        `json:"sales_tax"`
+	TaxBreakdown  store.TaxBreakdown `json:"tax_breakdown"`
+	OrderTotal    store.Money        `json:"order_total"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK},
+	dbops.Table{ // shipments table
+		Name:       dbops.ShipmentsTable(),
+		PrimaryKey: dbops.ShipmentsPK,
+		SortKey:    dbops.ShipmentsSK},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Tax is used to calculate the sales tax of orders
+var Tax taxops.Calculator = taxops.NewCalculatorFromEnv()
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := rateInfo{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// get order & shipment
+	order, err := DB.GetOrder(data.UserID, data.OrderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if order.OrderID == "" {
+		httpops.ErrResponse(w, "Order not found: "+data.OrderID, failMsg, http.StatusNotFound)
+		return
+	}
+	if order.OrderStatus != store.OrderStatusOpen {
+		httpops.ErrResponse(w, "Order is not open: "+order.OrderStatus, orderClosedMsg, http.StatusConflict)
+		return
+	}
+	shipment, err := DB.GetShipment(data.UserID, data.OrderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if !order.RequiresShipping() || shipment.OrderID == "" {
+		httpops.ErrResponse(w, "Shipping rates not found: "+data.OrderID, shippingAddressMsg, http.StatusConflict)
+		return
+	}
+
+	// get selected rate
+	rate, err := shipment.SelectRate(data.ServiceLevel)
+	if err != nil {
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), rateNotFoundMsg, http.StatusBadRequest)
+		return
+	}
+	cost, err := store.ParseMoney(rate.Price, rate.Currency)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// set shipping cost & recalculate the order's sales tax - retried with the latest order
+	// if it is updated concurrently
+	reread := false
+	err = dbops.RetryOnConflict(func() error {
+		if reread {
+			order, err = DB.GetOrder(data.UserID, data.OrderID)
+			if err != nil {
+				return err
+			}
+			if order.OrderStatus != store.OrderStatusOpen {
+				return fmt.Errorf(dbops.ErrOrderConflict)
+			}
+		}
+		reread = true
+		order.SetShippingCost(cost)
+		if err := taxops.ApplyTax(Tax, order, order.ShippingAddress); err != nil {
+			return err
+		}
+		return DB.PutOrder(order)
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		if err.Error() == dbops.ErrOrderConflict {
+			httpops.ErrResponse(w, "Order is not open: "+order.OrderStatus, orderClosedMsg, http.StatusConflict)
+			return
+		}
+		if dbops.IsVersionConflict(err) {
+			httpops.ErrResponse(w, "Order is being updated by another request; try again", "SAVE_SHIPPING_RATE_FAIL", http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), "SAVE_SHIPPING_RATE_FAIL", http.StatusInternalServerError)
+		return
+	}
+
+	// save selected rate for label purchase
+	shipment.SelectedRate = rate
+	shipment.EstimatedDays = rate.Days
+	err = DB.PutShipment(shipment)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), "SAVE_SHIPPING_RATE_FAIL", http.StatusInternalServerError)
+		return
+	}
+
+	// return updated order totals
+	summary := rateSummary{
+		SelectedRate:  rate,
+		ShippingCost:  order.ShippingCost,
+		DiscountTotal: order.DiscountTotal,
+		SalesTax:      order.SalesTax,
+		TaxBreakdown:  order.TaxBreakdown,
+		OrderTotal:    order.OrderTotal,
+	}
+	httpops.ErrResponse(w, "Shipping rate selected: ", summary, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,179 @@
+package main
+
+/* createContract API renders the lease agreement of a beat license in the customer's open order.
+The agreement is rendered from the license tier's template with the buyer's name, beat title,
+licensed rights and date, and is returned with its content hash for the buyer to sign
+(see signContract). Each request creates a new pending contract. */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/htmlops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/s3ops"
+)
+
+const route = "/contracts/create" // POST
+
+const failMsg = "Request failed!"
+const notFoundMsg = "Order item not found."
+const orderClosedMsg = "Order is not open for checkout."
+const conflictMsg = "Contract could not be created; try again"
+
+// contractInfo contains the order item to license & the buyer's legal name
+type contractInfo struct {
+	UserID    string `json:"user_id"`
+	OrderID   string `json:"order_id"`
+	SizeID    string `json:"size_id"`
+	BuyerName string `json:"buyer_name"`
+}
+
+// contractSummary contains the rendered agreement returned to the buyer for signing
+type contractSummary struct {
+	ContractID  string `json:"contract_id"`
+	Html        string `json:"html"`
+	ContentHash string `json:"content_hash"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK},
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK},
+	dbops.Table{ // contracts table
+		Name:       dbops.ContractsTable(),
+		PrimaryKey: dbops.ContractsPK,
+		SortKey:    dbops.ContractsSK},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := contractInfo{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+	if strings.TrimSpace(data.BuyerName) == "" {
+		httpops.ErrResponse(w, "Bad Request: buyer_name required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// get order & ordered item
+	order, err := DB.GetOrder(data.UserID, data.OrderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if order.OrderID == "" {
+		httpops.ErrResponse(w, "Order not found: "+data.OrderID, notFoundMsg, http.StatusNotFound)
+		return
+	}
+	if order.OrderStatus != store.OrderStatusOpen {
+		httpops.ErrResponse(w, "Order is not open: "+order.OrderStatus, orderClosedMsg, http.StatusConflict)
+		return
+	}
+	var line *store.CartItem
+	for _, item := range order.DigitalItems() {
+		if item.SizeID == data.SizeID {
+			line = item
+		}
+	}
+	if line == nil {
+		httpops.ErrResponse(w, "Digital item not found: "+data.SizeID, notFoundMsg, http.StatusNotFound)
+		return
+	}
+	item, err := DB.GetStoreItem(line.Subcategory, line.ItemID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// create contract
+	contract, err := store.NewContract(order, item, data.SizeID, data.BuyerName, time.Now())
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "License not found: "+data.SizeID, notFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	// render agreement from the license tier's template
+	tmpl, err := s3ops.GetContractHtmlTemplate(s3ops.InitSesh(), contract.Tier)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	html, err := htmlops.CreateHtmlTemplate(tmpl, htmlops.ContractTemplateData{
+		OrderID:   contract.OrderID,
+		BuyerName: contract.BuyerName,
+		BeatTitle: contract.BeatTitle,
+		License:   item.Licenses[contract.Tier].Name,
+		Rights:    contract.Rights,
+		Price:     contract.Price,
+		Date:      contract.Date,
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	contract.SetContent(html)
+
+	err = DB.PutContract(contract)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		if err.Error() == dbops.ErrConditionalCheck {
+			httpops.ErrResponse(w, "Contract exists: "+contract.ContractID, conflictMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Contract: ", contractSummary{
+		ContractID:  contract.ContractID,
+		Html:        contract.Html,
+		ContentHash: contract.ContentHash,
+	}, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -0,0 +1,74 @@
+package main
+
+/* getContract API redirects to a signed, time-limited URL of a signed lease agreement.
+Receipts link to this API so the links do not expire. */
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/s3ops"
+)
+
+const route = "/contracts/get" // GET
+
+const failMsg = "Request failed!"
+const notFoundMsg = "Contract not found."
+
+// urlExpiry is the validity of signed contract URLs.
+const urlExpiry = 15 * time.Minute
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // contracts table
+		Name:       dbops.ContractsTable(),
+		PrimaryKey: dbops.ContractsPK,
+		SortKey:    dbops.ContractsSK},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Assets is used to sign contract URLs
+var Assets s3ops.Signer = s3ops.NewS3Assets()
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	userID, contractID := params["user_id"], params["contract_id"]
+	if userID == "" || contractID == "" {
+		httpops.ErrResponse(w, "Bad Request: user_id and contract_id required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	contract, err := DB.GetContract(userID, contractID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if contract.Status != store.ContractStatusSigned || contract.DocumentKey == "" {
+		httpops.ErrResponse(w, "Signed contract not found: "+contractID, notFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	url, err := Assets.SignGetURL(s3ops.DigitalAssetsBucket(), contract.DocumentKey, urlExpiry)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
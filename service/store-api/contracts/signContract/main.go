@@ -0,0 +1,165 @@
+package main
+
+/* signContract API records the buyer's typed signature of a lease agreement rendered by
+createContract. The signature, time, IP address and content hash of the accepted agreement
+are recorded, the signed agreement is stored in S3, and the contract is attached to its
+order. Payment is refused until each digital item of the order has a signed contract. */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/htmlops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/s3ops"
+)
+
+const route = "/contracts/sign" // PUT
+
+const failMsg = "Request failed!"
+const notFoundMsg = "Contract not found."
+const signedMsg = "Contract was already signed."
+const changedMsg = "The agreement has changed. Please review the agreement and sign again."
+const signatureMsg = "Please type your full name as it appears on the agreement to sign."
+const orderClosedMsg = "Order is not open for checkout."
+
+// signature contains the buyer's acceptance of the agreement
+type signature struct {
+	UserID      string `json:"user_id"`
+	ContractID  string `json:"contract_id"`
+	Signature   string `json:"signature"`    // typed full name of the buyer
+	ContentHash string `json:"content_hash"` // content hash of the agreement displayed to the buyer
+}
+
+// signedContract is returned to the buyer once the contract is signed
+type signedContract struct {
+	ContractID  string `json:"contract_id"`
+	SizeID      string `json:"size_id"`
+	SignedAt    int64  `json:"signed_at"`
+	ContentHash string `json:"content_hash"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK},
+	dbops.Table{ // contracts table
+		Name:       dbops.ContractsTable(),
+		PrimaryKey: dbops.ContractsPK,
+		SortKey:    dbops.ContractsSK},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Assets is used to store signed agreements
+var Assets s3ops.Uploader = s3ops.NewS3Assets()
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := signature{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// get contract
+	contract, err := DB.GetContract(data.UserID, data.ContractID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if contract.ContractID == "" {
+		httpops.ErrResponse(w, "Contract not found: "+data.ContractID, notFoundMsg, http.StatusNotFound)
+		return
+	}
+
+	// record acceptance
+	err = contract.Sign(data.Signature, data.ContentHash, httpops.ClientIP(r), time.Now())
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		switch err.Error() {
+		case store.ErrContractSigned:
+			httpops.ErrResponse(w, "Contract already signed: "+data.ContractID, signedMsg, http.StatusConflict)
+		case store.ErrContractChanged:
+			httpops.ErrResponse(w, "Content hash does not match: "+data.ContentHash, changedMsg, http.StatusConflict)
+		default:
+			httpops.ErrResponse(w, "Invalid signature: "+err.Error(), signatureMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// store signed agreement
+	html, err := htmlops.CreateSignedContractHtml(contract.Html, htmlops.SignatureTemplateData{
+		Signature:   contract.Signature,
+		SignedAt:    time.Unix(contract.SignedAt, 0).UTC().Format(time.RFC3339),
+		SignerIP:    contract.SignerIP,
+		ContentHash: contract.ContentHash,
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	contract.DocumentKey = s3ops.ContractKey(contract.UserID, contract.ContractID)
+	err = Assets.PutObject(s3ops.DigitalAssetsBucket(), contract.DocumentKey, "text/html; charset=utf-8", []byte(html))
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// record signature & attach contract to order
+	err = dbops.SignContract(DB, contract)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		switch err.Error() {
+		case store.ErrContractSigned:
+			httpops.ErrResponse(w, "Contract already signed: "+data.ContractID, signedMsg, http.StatusConflict)
+		case dbops.ErrConditionalCheck:
+			httpops.ErrResponse(w, "Order is not open: "+contract.OrderID, orderClosedMsg, http.StatusConflict)
+		default:
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	httpops.ErrResponse(w, "Contract signed: ", signedContract{
+		ContractID:  contract.ContractID,
+		SizeID:      contract.SizeID,
+		SignedAt:    contract.SignedAt,
+		ContentHash: contract.ContentHash,
+	}, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
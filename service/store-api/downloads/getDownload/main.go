@@ -0,0 +1,118 @@
+package main
+
+/* getDownload API returns a signed, time-limited URL for the master file of a purchased digital
+item. Grants are identified by their random grant ID (see viewDownloads). Each request uses one of
+the grant's downloads; requests for expired grants or grants without remaining downloads are
+rejected. */
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/s3ops"
+)
+
+const route = "/downloads/get" // GET
+
+const failMsg = "Request failed!"
+const notFoundMsg = "Download not found."
+const limitMsg = "Download limit reached."
+const expiredMsg = "Download expired."
+
+// EnvarDownloadURLMinutes contains the environment variable of the number of minutes signed
+// download URLs are valid.
+const EnvarDownloadURLMinutes = "DOWNLOAD_URL_MINUTES"
+
+// defaultURLExpiry is the validity of signed URLs if DOWNLOAD_URL_MINUTES is unset or invalid.
+const defaultURLExpiry = 15 * time.Minute
+
+// download contains the signed URL & remaining downloads returned to the user.
+type download struct {
+	URL                string `json:"url"`
+	URLExpiresAt       int64  `json:"url_expires_at"` // unix timestamp (s)
+	Name               string `json:"name"`
+	Tier               string `json:"tier"`
+	DownloadsRemaining int    `json:"downloads_remaining"`
+	ExpiresAt          int64  `json:"expires_at"` // unix timestamp (s) the grant expires
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // downloads table
+		Name:       dbops.DownloadsTable(),
+		PrimaryKey: dbops.DownloadsPK,
+		SortKey:    dbops.DownloadsSK},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Assets is used to sign download URLs
+var Assets s3ops.Signer = s3ops.NewS3Assets()
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	userID, grantID := params["user_id"], params["grant_id"]
+	if userID == "" || grantID == "" {
+		httpops.ErrResponse(w, "Bad Request: user_id and grant_id required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	grant, err := dbops.UseDownload(DB, userID, grantID, now)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		switch err.Error() {
+		case store.ErrDownloadNotFound:
+			httpops.ErrResponse(w, "Download not found: "+grantID, notFoundMsg, http.StatusNotFound)
+		case store.ErrDownloadLimitReached:
+			httpops.ErrResponse(w, "Download limit reached: "+grantID, limitMsg, http.StatusForbidden)
+		case store.ErrDownloadExpired:
+			httpops.ErrResponse(w, "Download expired: "+grantID, expiredMsg, http.StatusGone)
+		default:
+			httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	expiry := urlExpiry()
+	url, err := Assets.SignGetURL(s3ops.DigitalAssetsBucket(), grant.AssetKey, expiry)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Download: ", download{
+		URL:                url,
+		URLExpiresAt:       now.Add(expiry).Unix(),
+		Name:               grant.Name,
+		Tier:               grant.Tier,
+		DownloadsRemaining: grant.DownloadsRemaining,
+		ExpiresAt:          grant.ExpiresAt,
+	}, http.StatusOK)
+	return
+}
+
+// urlExpiry returns the duration signed download URLs are valid.
+func urlExpiry() time.Duration {
+	mins, err := strconv.Atoi(os.Getenv(EnvarDownloadURLMinutes))
+	if err != nil || mins <= 0 {
+		return defaultURLExpiry
+	}
+	return time.Duration(mins) * time.Minute
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
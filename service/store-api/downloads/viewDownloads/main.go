@@ -0,0 +1,55 @@
+package main
+
+/* viewDownloads API returns the download grants issued for the digital items of a paid order,
+including the downloads remaining and expiration of each grant. Download URLs are requested
+separately for each grant (see getDownload). */
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/downloads/view" // GET
+
+const failMsg = "Request failed!"
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // downloads table
+		Name:       dbops.DownloadsTable(),
+		PrimaryKey: dbops.DownloadsPK,
+		SortKey:    dbops.DownloadsSK},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	userID, orderID := params["user_id"], params["order_id"]
+	if userID == "" || orderID == "" {
+		httpops.ErrResponse(w, "Bad Request: user_id and order_id required", failMsg, http.StatusBadRequest)
+		return
+	}
+
+	grants, err := DB.GetOrderDownloads(userID, orderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	httpops.ErrResponse(w, "Downloads: ", grants, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -18,23 +18,24 @@ const successMsg = "Request succeeded!"
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // orders table
-		Name:       dbops.OpenOrdersTable,
+		Name:       dbops.OpenOrdersTable(),
 		PrimaryKey: dbops.OpenOrdersPK,
 		SortKey:    dbops.OpenOrdersSK,
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
-
 	// get query strings from GET call
 	params := httpops.GetQueryStringParams(r)
 	userID := params["user_id"]
 	orderID := params["order_id"]
 
 	// get open order
-	order, err := dbops.GetOpenOrder(DB, userID, orderID)
+	order, err := DB.GetOpenOrder(userID, orderID)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
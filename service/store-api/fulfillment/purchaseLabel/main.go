@@ -1,6 +1,9 @@
 package main
 
-/* purchaseLabel API purchases a shipping label  */
+/* purchaseLabel API purchases a shipping label for each location the order is shipped from. Each
+   label is saved to the shipment as it is purchased, and the units shipped from the label's
+   location are removed from the location's inventory, so retried requests do not purchase a label
+   or remove units twice. */
 
 import (
 	"encoding/json"
@@ -9,6 +12,7 @@ import (
 	"net/http"
 
 	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
 	"github.com/tpillz-presents/service/util/shipops"
@@ -19,9 +23,6 @@ const route = "/fulfillment/purchase-label" // PUT
 const failMsg = "Request failed!"
 const successMsg = "Request succeeded!"
 
-// shippo API private key - get as env var
-const privateKey = ""
-
 // AWS ARN for Shipping Topic - get as env var
 const shipmentTopicArn = ""
 
@@ -33,7 +34,13 @@ type request struct {
 
 // http response data
 type responseBody struct {
-	OrderID              string `json:"order_id"`
+	OrderID string          `json:"order_id"`
+	Labels  []labelResponse `json:"labels"`
+}
+
+// shipping label data of response
+type labelResponse struct {
+	LocationID           string `json:"location_id"`
 	Carrier              string `json:"carrier"`
 	Price                string `json:"price"`
 	LabelUrl             string `json:"label_url"`
@@ -45,15 +52,30 @@ type responseBody struct {
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // orders table
-		Name:       dbops.OpenOrdersTable,
+		Name:       dbops.OpenOrdersTable(),
 		PrimaryKey: dbops.OpenOrdersPK,
 		SortKey:    dbops.OpenOrdersSK,
 	},
+	dbops.Table{ // shipments table
+		Name:       dbops.ShipmentsTable(),
+		PrimaryKey: dbops.ShipmentsPK,
+		SortKey:    dbops.ShipmentsSK,
+	},
+	dbops.Table{ // locations table
+		Name:       dbops.LocationsTable(),
+		PrimaryKey: dbops.LocationsPK,
+		SortKey:    "",
+	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Shipping is used to purchase shipping labels
+var Shipping shipops.Carrier = shipops.NewShippoFromEnv()
+
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
-	DB := dbops.InitDB(tables)
 	sns := snsops.InitSesh()
 
 	// verify content-type
@@ -80,30 +102,43 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get shipment
-	shipment, err := dbops.GetShipment(DB, data.UserID, data.OrderID)
+	shipment, err := DB.GetShipment(data.UserID, data.OrderID)
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	// initialize shippo client and purchase label
-	c := shipops.InitClient(privateKey)
-	err = shipops.PurchaseShippingLabel(c, shipment)
+	if shipment.OrderID == "" {
+		httpops.ErrResponse(w, "Shipment not found: "+data.OrderID, failMsg, http.StatusNotFound)
+		return
+	}
+
+	// purchase labels
+	err = shipops.PurchaseShippingLabel(Shipping, shipment, func(label store.ShippingLabel) error {
+		return saveLabel(shipment, label)
+	})
 	if err != nil {
 		log.Printf("RootHandler failed: %v", err)
+		if dbops.IsVersionConflict(err) {
+			httpops.ErrResponse(w, "Shipment was modified; retry request", failMsg, http.StatusConflict)
+			return
+		}
 		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
 		return
 	}
 
-	resp := responseBody{
-		OrderID:              shipment.OrderID,
-		Carrier:              shipment.Labels[0].Carrier,
-		Price:                shipment.Labels[0].Price,
-		LabelUrl:             shipment.Labels[0].LabelUrl,
-		CommercialInvoiceUrl: shipment.Labels[0].CommercialInvoiceUrl,
-		TrackingUrlProvider:  shipment.Labels[0].TrackingUrlProvider,
-		Eta:                  shipment.Labels[0].Eta,
+	resp := responseBody{OrderID: shipment.OrderID, Labels: []labelResponse{}}
+	for _, l := range shipment.Labels {
+		resp.Labels = append(resp.Labels, labelResponse{
+			LocationID:           l.LocationID,
+			Carrier:              l.Carrier,
+			Price:                l.Price,
+			LabelUrl:             l.LabelUrl,
+			CommercialInvoiceUrl: l.CommercialInvoiceUrl,
+			TrackingUrlProvider:  l.TrackingUrlProvider,
+			Eta:                  l.Eta,
+		})
 	}
 
 	// send shipment to shipping update topic >>> update shipment db object, send customer email notification
@@ -120,6 +155,41 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// saveLabel saves the purchased label to the shipment, then removes the units shipped from the
+// label's location from the location's inventory. Units are removed once for each saved label.
+func saveLabel(shipment *store.Shipment, label store.ShippingLabel) error {
+	err := dbops.SaveShippingLabel(DB, shipment, label)
+	if err != nil {
+		log.Printf("saveLabel failed: %v", err)
+		return err
+	}
+	short, err := dbops.ShipFromLocations(DB, shippedPackages(shipment, label.LocationID))
+	if err != nil {
+		// label is saved; log units for manual adjustment
+		log.Printf("saveLabel failed: remove units from location %s for order %s: %v", label.LocationID, shipment.OrderID, err)
+		return nil
+	}
+	if len(short) > 0 {
+		log.Printf("location inventory short for order %s: %v", shipment.OrderID, short)
+	}
+	return nil
+}
+
+// shippedPackages returns the shipment's packages shipped from the location. Packages without
+// an origin location are not returned.
+func shippedPackages(shipment *store.Shipment, locationID string) []store.Package {
+	pkgs := []store.Package{}
+	if locationID == "" {
+		return pkgs
+	}
+	for _, pkg := range shipment.Packages {
+		if pkg.LocationID == locationID {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
 func main() {
 	httpops.RegisterRoutes(route, RootHandler)
 	log.Fatal(gateway.ListenAndServe(":3000", nil))
@@ -2,7 +2,8 @@ package main
 
 /* queueOrder is triggered by an SNS event when a new Order message is published to
 the Fulfillment topic. This function writes the new order to the OpenOrders DB table
-and sends a summary of the order to the Fulfillment queue. */
+and sends a summary of the order to the Fulfillment queue. Digital items are downloaded
+by the customer and are not queued; digital-only orders are skipped. */
 
 import (
 	"context"
@@ -25,15 +26,17 @@ const successMsg = "Request succeeded!"
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // orders table
-		Name:       dbops.OpenOrdersTable,
+		Name:       dbops.OpenOrdersTable(),
 		PrimaryKey: dbops.OpenOrdersPK,
 		SortKey:    dbops.OpenOrdersSK,
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
 func handler(ctx context.Context, snsEvent events.SNSEvent) {
 	svc := queueops.InitSesh() // sqs
-	db := dbops.InitDB(tables) // dynamodb
 
 	for _, record := range snsEvent.Records {
 		snsRecord := record.SNS
@@ -49,8 +52,15 @@ func handler(ctx context.Context, snsEvent events.SNSEvent) {
 			return
 		}
 
+		// only physical items are fulfilled
+		if !order.RequiresShipping() {
+			log.Printf("handler: order %s has no items to ship - skipping...", order.OrderID)
+			continue
+		}
+		order.Items = order.PhysicalItems()
+
 		// write order to open orders table
-		err = dbops.PutOpenOrder(db, order)
+		err = DB.PutOpenOrder(order)
 		if err != nil {
 			// handle err
 			log.Printf("handler failed: %v", err)
@@ -0,0 +1,164 @@
+package main
+
+/* trackingWebhook receives carrier tracking webhook events. Webhooks are verified with the secret
+   token of the webhook URL ('?token=...') and matched to shipments by tracking number (see
+   updateShipment). Each new tracking status is appended to the shipment's tracking history, and
+   the order is marked as DELIVERED once each of the shipment's labels has been delivered.
+   Customers are emailed when a parcel is out for delivery, delivered, or has a delivery
+   exception. Delivered orders are closed once their return window ends (see
+   closeDeliveredOrders). */
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/sesops"
+	"github.com/tpillz-presents/service/util/shipops"
+)
+
+const route = "/fulfillment/tracking/webhook" // POST
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+
+const from = "dg.dev.test510@gmail.com" // test only - move to admin settings db table in prod
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK,
+	},
+	dbops.Table{ // shipments table
+		Name:       dbops.ShipmentsTable(),
+		PrimaryKey: dbops.ShipmentsPK,
+		SortKey:    dbops.ShipmentsSK,
+	},
+	dbops.Table{ // tracking table
+		Name:       dbops.TrackingTable(),
+		PrimaryKey: dbops.TrackingPK,
+		SortKey:    "",
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Shipping is used to verify and parse tracking webhooks
+var Shipping shipops.Carrier = shipops.NewShippoFromEnv()
+
+// SendNotice emails tracking notices to customers
+var SendNotice = sendTrackingNotice
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// verify & parse event
+	params := httpops.GetQueryStringParams(r)
+	event, err := Shipping.ParseTrackingEvent(payload, params["token"])
+	if err != nil {
+		if err.Error() == shipops.ErrIgnoredEvent {
+			httpops.ErrResponse(w, "Event ignored", successMsg, http.StatusOK)
+			return
+		}
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// get shipment of tracking number
+	ref, err := DB.GetTrackingRef(event.TrackingNumber)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if ref.OrderID == "" {
+		log.Printf("unknown tracking number: %s", event.TrackingNumber)
+		httpops.ErrResponse(w, "Event ignored: unknown tracking number "+event.TrackingNumber, successMsg, http.StatusOK)
+		return
+	}
+
+	// record tracking status - the event is appended to the shipment as read, and is recorded
+	// again against the latest shipment if another event or label was saved concurrently
+	var shipment *store.Shipment
+	duplicate := false
+	err = dbops.RetryOnConflict(func() error {
+		shipment, err = DB.GetShipment(ref.UserID, ref.OrderID)
+		if err != nil {
+			return err
+		}
+		if !shipment.AddTracking(*event) {
+			duplicate = true
+			return nil
+		}
+
+		// mark order delivered once every parcel has been delivered - before the event is saved,
+		// so the event is processed again if the order cannot be updated
+		if event.Status == store.TrackingStatusDelivered && shipment.Delivered() {
+			at := event.Time()
+			if at.IsZero() {
+				at = time.Now()
+			}
+			_, err := dbops.DeliverOrder(DB, ref.UserID, ref.OrderID, at)
+			if err != nil {
+				var te *store.TransitionError
+				if !errors.As(err, &te) {
+					return err
+				}
+				// order already delivered, closed or returned
+				log.Printf("order %s not marked delivered: %v", ref.OrderID, err)
+			}
+		}
+
+		// save tracking history
+		return dbops.SaveTrackingEvent(DB, shipment, *event)
+	})
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		if dbops.IsVersionConflict(err) {
+			httpops.ErrResponse(w, "Conflict: "+err.Error(), failMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if duplicate {
+		log.Printf("duplicate event: %s %s", event.TrackingNumber, event.ID)
+		httpops.ErrResponse(w, "Duplicate event: "+event.ID, successMsg, http.StatusOK)
+		return
+	}
+
+	// notify customer - tracking is recorded if the email fails
+	if event.Notice() != "" {
+		if err := SendNotice(shipment, *event); err != nil {
+			log.Printf("RootHandler failed: %v", err)
+		}
+	}
+
+	httpops.ErrResponse(w, "Event received: "+event.ID, successMsg, http.StatusOK)
+	return
+}
+
+// sendTrackingNotice emails the event's tracking notice to the shipment's recipient.
+func sendTrackingNotice(shipment *store.Shipment, event store.TrackingEvent) error {
+	return sesops.SendTrackingNotification(sesops.InitSesh(), from, shipment, event)
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
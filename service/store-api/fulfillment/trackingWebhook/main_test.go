@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/shipops"
+)
+
+func TestRootHandler(t *testing.T) {
+	DB = dbops.NewMemStore()
+	fake := shipops.NewFake()
+	fake.WebhookSecret = "secret"
+	Shipping = fake
+	notices := []string{}
+	SendNotice = func(shipment *store.Shipment, event store.TrackingEvent) error {
+		notices = append(notices, event.Notice())
+		return nil
+	}
+
+	to := store.Address{FirstName: "Jane", LastName: "Doe", AddressLine1: "3250 Hollis St", City: "Oakland", State: "CA", Zip: "94608", Email: "jane@example.com"}
+	from := store.Address{AddressLine1: "100 Test Way", City: "Modesto", State: "CA", Zip: "95355"}
+	pkg := store.Package{Dimensions: store.Dimensions{Length: "12", Width: "10", Height: "6", DistanceUnit: "in", Weight: "2", MassUnit: "lb"}}
+	shipment := &store.Shipment{UserID: "u01", OrderID: "u01-1", AddressTo: to}
+	shipment.AddOrigin(store.ShipmentOrigin{LocationID: "mod", AddressFrom: from}, []store.Package{pkg})
+	shipment.AddOrigin(store.ShipmentOrigin{LocationID: "nyc", AddressFrom: from}, []store.Package{pkg})
+	shipment.SelectedRate = store.RateSummary{ServiceLevel: store.ServiceLevel{Token: "usps_priority"}}
+	if err := shipops.PurchaseShippingLabel(fake, shipment, nil); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	DB.PutShipment(shipment)
+	for _, ref := range shipment.TrackingRefs() {
+		ref := ref
+		DB.PutTrackingRef(&ref)
+	}
+	DB.PutOrder(&store.Order{UserID: "u01", OrderID: "u01-1", OrderStatus: store.OrderStatusShipped})
+	first, second := shipment.Labels[0].TrackingNumber, shipment.Labels[1].TrackingNumber
+	now := time.Now()
+
+	var tests = []struct {
+		name        string
+		number      string
+		status      string // tracking status set before the webhook is sent; resent if empty
+		payload     []byte // sent instead of the tracking webhook if set
+		token       string
+		wantStatus  int
+		wantNotices int
+		wantOrder   string
+	}{
+		{name: "out for delivery", number: first, status: "TRANSIT/out_for_delivery", token: "secret",
+			wantStatus: http.StatusOK, wantNotices: 1, wantOrder: store.OrderStatusShipped},
+		{name: "duplicate", number: first, token: "secret", wantStatus: http.StatusOK, wantNotices: 1, wantOrder: store.OrderStatusShipped},
+		{name: "invalid token", number: first, status: "DELIVERED", token: "wrong", wantStatus: http.StatusBadRequest, wantNotices: 1, wantOrder: store.OrderStatusShipped},
+		{name: "first parcel delivered", number: first, token: "secret", wantStatus: http.StatusOK, wantNotices: 2, wantOrder: store.OrderStatusShipped},
+		{name: "unknown tracking number", payload: []byte(`{"tracking_number": "1Z999", "status": "DELIVERED"}`), token: "secret",
+			wantStatus: http.StatusOK, wantNotices: 2, wantOrder: store.OrderStatusShipped},
+		{name: "no status", payload: []byte(`{"tracking_number": "1Z999"}`), token: "secret", wantStatus: http.StatusOK, wantNotices: 2, wantOrder: store.OrderStatusShipped},
+		{name: "each parcel delivered", number: second, status: "DELIVERED", token: "secret", wantStatus: http.StatusOK, wantNotices: 3, wantOrder: store.OrderStatusDelivered},
+	}
+	for _, test := range tests {
+		payload := test.payload
+		if payload == nil {
+			if test.status != "" {
+				fake.SetTracking(test.number, test.status, "", now)
+			}
+			payload, _ = fake.TrackingWebhook(test.number)
+		}
+		req := httptest.NewRequest(http.MethodPost, route+"?token="+test.token, bytes.NewReader(payload))
+		w := httptest.NewRecorder()
+
+		RootHandler(w, req)
+		if w.Code != test.wantStatus {
+			t.Errorf("FAIL - %s: status %d; want: %d", test.name, w.Code, test.wantStatus)
+		}
+		if len(notices) != test.wantNotices {
+			t.Errorf("FAIL - %s: %v; want: %d notices", test.name, notices, test.wantNotices)
+		}
+		order, _ := DB.GetOrder("u01", "u01-1")
+		if order.OrderStatus != test.wantOrder {
+			t.Errorf("FAIL - %s: %s; want: %s", test.name, order.OrderStatus, test.wantOrder)
+		}
+	}
+
+	got, _ := DB.GetShipment("u01", "u01-1")
+	if len(got.TrackingHistory) != 3 || !got.Delivered() {
+		t.Errorf("FAIL: %v; want: 3 events, delivered", got.TrackingHistory)
+	}
+	order, _ := DB.GetOrder("u01", "u01-1")
+	if order.DeliveredAt != now.Unix() {
+		t.Errorf("FAIL - delivered at: %d; want: %d", order.DeliveredAt, now.Unix())
+	}
+}
@@ -16,14 +16,16 @@ import (
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // customers table
-		Name:       dbops.OrdersTable,
+		Name:       dbops.OrdersTable(),
 		PrimaryKey: dbops.OrdersPK,
 		SortKey:    dbops.OrdersSK,
 	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
 func handler(ctx context.Context, snsEvent events.SNSEvent) {
-	db := dbops.InitDB(tables)
 	for _, record := range snsEvent.Records {
 		snsRecord := record.SNS
 		// fmt.Printf("[%s %s] Message = %s \n", record.EventSource, snsRecord.Timestamp, snsRecord.Message)
@@ -38,8 +40,8 @@ func handler(ctx context.Context, snsEvent events.SNSEvent) {
 			return
 		}
 
-		// update order object shipment data
-		err = dbops.UpdateOrderShippingInfo(db, ship.UserID, ship.OrderID, "SHIPPED", true)
+		// update order status
+		_, err = dbops.TransitionOrder(DB, ship.UserID, ship.OrderID, store.OrderEventShip)
 		if err != nil {
 			// handle err
 			log.Printf("handler failed: %v", err)
@@ -47,7 +49,7 @@ func handler(ctx context.Context, snsEvent events.SNSEvent) {
 		}
 
 		// delete order from open orders table
-		err = dbops.DeleteOpenOrder(db, ship.UserID, ship.OrderID)
+		err = DB.DeleteOpenOrder(ship.UserID, ship.OrderID)
 		if err != nil {
 			// handle err
 			log.Printf("handler failed: %v", err)
@@ -1,6 +1,8 @@
 package main
 
-/* updateShipment updates a store.Shipment object in the DynamoDB Shipments table. */
+/* updateShipment updates a store.Shipment object in the DynamoDB Shipments table and indexes the
+tracking numbers of the shipment's labels in the Tracking table, so tracking webhook events can
+be matched to the shipment (see trackingWebhook). */
 
 import (
 	"context"
@@ -16,14 +18,20 @@ import (
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // customers table
-		Name:       dbops.ShipmentsTable,
+		Name:       dbops.ShipmentsTable(),
 		PrimaryKey: dbops.ShipmentsPK,
 		SortKey:    dbops.ShipmentsSK,
 	},
+	dbops.Table{ // tracking table
+		Name:       dbops.TrackingTable(),
+		PrimaryKey: dbops.TrackingPK,
+	},
 }
 
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
 func handler(ctx context.Context, snsEvent events.SNSEvent) {
-	db := dbops.InitDB(tables)
 	for _, record := range snsEvent.Records {
 		snsRecord := record.SNS
 		// fmt.Printf("[%s %s] Message = %s \n", record.EventSource, snsRecord.Timestamp, snsRecord.Message)
@@ -38,14 +46,34 @@ func handler(ctx context.Context, snsEvent events.SNSEvent) {
 			return
 		}
 
+		// keep tracking history recorded before the update
+		prev, err := DB.GetShipment(ship.UserID, ship.OrderID)
+		if err != nil {
+			log.Printf("handler failed: %v", err)
+			return
+		}
+		if len(ship.TrackingHistory) == 0 {
+			ship.TrackingHistory = prev.TrackingHistory
+		}
+
 		// update shipment in db
-		err = dbops.PutShipment(db, ship)
+		err = DB.PutShipment(ship)
 		if err != nil {
 			// handle err
 			log.Printf("handler failed: %v", err)
 			return
 		}
 
+		// index label tracking numbers
+		for _, ref := range ship.TrackingRefs() {
+			ref := ref
+			err = DB.PutTrackingRef(&ref)
+			if err != nil {
+				log.Printf("handler failed: %v", err)
+				return
+			}
+		}
+
 	}
 	return
 }
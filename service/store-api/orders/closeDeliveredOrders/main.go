@@ -0,0 +1,90 @@
+package main
+
+/* closeDeliveredOrders runs on a schedule to close DELIVERED orders once their return window of
+   RETURN_WINDOW_DAYS since delivery has ended (see trackingWebhook). Orders with an open return
+   are not DELIVERED and are closed by the return workflow. */
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/orders/close_delivered" // POST
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+
+// envarReturnWindowDays contains the number of days after delivery returns may be requested
+const envarReturnWindowDays = "RETURN_WINDOW_DAYS" // default 30
+
+// closeSummary contains the order IDs of the closed orders.
+type closeSummary struct {
+	Message  string   `json:"message"`
+	OrderIDs []string `json:"order_ids"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK,
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	window := time.Duration(envInt(envarReturnWindowDays, 30)) * 24 * time.Hour
+
+	orders, err := DB.ScanDeliveredOrders(now.Add(-window).Unix())
+	if err != nil {
+		log.Printf("closeDeliveredOrders failed: %v", err)
+		httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	closed := []string{}
+	for _, order := range orders {
+		if !order.ReturnWindowClosed(now, window) {
+			continue
+		}
+		// orders with a return requested since the scan fail the transition
+		_, err := dbops.TransitionOrder(DB, order.UserID, order.OrderID, store.OrderEventClose)
+		if err != nil {
+			log.Printf("closeDeliveredOrders: order %s not closed: %v", order.OrderID, err)
+			continue
+		}
+		closed = append(closed, order.OrderID)
+	}
+	log.Printf("closed %d delivered orders", len(closed))
+
+	summary := closeSummary{Message: successMsg, OrderIDs: closed}
+	httpops.ErrResponse(w, "Closed delivered orders: ", summary, http.StatusOK)
+	return
+}
+
+// envInt returns the integer value of the environment variable, or def if it is unset or invalid.
+func envInt(name string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -1,16 +1,22 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/apex/gateway"
 	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/hashops"
 	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/paymentops"
 	"github.com/tpillz-presents/service/util/queueops"
+	"github.com/tpillz-presents/service/util/searchops"
 	"github.com/tpillz-presents/service/util/snsops"
+	"github.com/tpillz-presents/service/util/timeops"
 )
 
 const route = "/checkout/payment" // PUT
@@ -22,6 +28,12 @@ const successMsg = "Request succeeded!"
 // value is set in the SAM template.yaml file.
 var FulfillmentTopicARN = os.Getenv("fulfillmentTopicArn")
 
+// paymentEvents maps payment statuses to the order event they trigger.
+var paymentEvents = map[string]store.OrderEvent{
+	store.PaymentStatusSuccess: store.OrderEventPaymentSuccess,
+	store.PaymentStatusFail:    store.OrderEventPaymentFail,
+}
+
 // list of tables function makes r/w calls to
 var tables = []dbops.Table{
 	dbops.Table{ // customers table
@@ -30,29 +42,65 @@ var tables = []dbops.Table{
 		SortKey:    "",
 	},
 	dbops.Table{ // store items table
-		Name:       dbops.StoreItemsTable,
+		Name:       dbops.StoreItemsTable(),
 		PrimaryKey: dbops.StoreItemPK,
 		SortKey:    dbops.StoreItemSK,
 	},
 	dbops.Table{ // shopping carts table
-		Name:       dbops.ShoppingCartsTable,
+		Name:       dbops.ShoppingCartsTable(),
 		PrimaryKey: dbops.ShoppingCartsPK,
 		SortKey:    "",
 	},
 	dbops.Table{ // transactions table
-		Name:       dbops.TransactionsTable,
+		Name:       dbops.TransactionsTable(),
 		PrimaryKey: dbops.TransactionsPK,
 		SortKey:    dbops.TransactionsSK,
 	},
 	dbops.Table{ // orders table
-		Name:       dbops.OrdersTable,
+		Name:       dbops.OrdersTable(),
 		PrimaryKey: dbops.OrdersPK,
 		SortKey:    dbops.OrdersSK,
 	},
+	dbops.Table{ // store items index table
+		Name:       dbops.StoreItemsIndexTable(),
+		PrimaryKey: dbops.StoreItemsIndexPK,
+		SortKey:    "",
+	},
+	dbops.Table{ // store item summaries table
+		Name:       dbops.StoreItemsSummaryTable(),
+		PrimaryKey: dbops.StoreItemSummaryPK,
+		SortKey:    dbops.StoreItemSummarySK,
+	},
+	dbops.Table{ // downloads table
+		Name:       dbops.DownloadsTable(),
+		PrimaryKey: dbops.DownloadsPK,
+		SortKey:    dbops.DownloadsSK,
+	},
+	dbops.Table{ // promotions table
+		Name:       dbops.PromotionsTable(),
+		PrimaryKey: dbops.PromotionsPK,
+		SortKey:    "",
+	},
+	dbops.Table{ // promotion redemptions table
+		Name:       dbops.RedemptionsTable(),
+		PrimaryKey: dbops.RedemptionsPK,
+		SortKey:    dbops.RedemptionsSK,
+	},
+	dbops.Table{ // inventory holds table
+		Name:       dbops.HoldsTable(),
+		PrimaryKey: dbops.HoldsPK,
+		SortKey:    dbops.HoldsSK,
+	},
 }
 
 // / DB is used to make DynamoDB API calls
-var DB = dbops.InitDB(tables)
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// Payments is used to refund exclusive licenses sold to another order
+var Payments paymentops.Provider = paymentops.NewStripeFromEnv()
+
+// Search is used to remove items sold exclusively from the catalog search index
+var Search searchops.Snapshots = searchops.NewS3SnapshotsFromEnv()
 
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -74,8 +122,10 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// update staged orders
-	for _, status := range resp.Statuses {
+	// update staged orders; statuses received before the order is staged are left in the
+	// queue and processed on redelivery
+	processedIDs, processedHandles := []string{}, []string{}
+	for i, status := range resp.Statuses {
 		custID := status.CustomerID
 		check := store.ValidPaymentStatus[status.TxStatus]
 		if !check {
@@ -83,65 +133,305 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 			httpops.ErrResponse(w, "Internal server error: ", "INVALID_TX_STATUS: "+status.TxStatus, http.StatusInternalServerError)
 			return
 		}
-		// refactor to update each object's fields in one api call
-		err := dbops.UpdateOrderPaymentStatus(DB, custID, status.OrderID, status.TxStatus)
+		// the transaction is written when the order is staged (see stageOrder); refunds and
+		// disputes of the sale are not recorded as the sale's payment status
+		field := store.PaymentStatusField(status.TxStatus)
+		txUpdate := dbops.NewTransactionUpdate(custID, status.TransactionID).
+			Set(field, status.TxStatus).
+			IfExists()
+		if field == "payment_status" {
+			txUpdate.Set("payment_method", status.PaymentMethod).
+				Set("payment_tx_id", status.PaymentTxID)
+		}
+		if status.TxStatus == store.PaymentStatusSuccess {
+			// processing fees are known once the payment is captured
+			txUpdate.Set("charges_and_fees", status.ChargesAndFees)
+		}
+		err := DB.UpdateItem(txUpdate)
 		if err != nil {
+			if err.Error() == dbops.ErrConditionalCheck {
+				log.Printf("processOrder: order %s not staged - retrying on redelivery", status.OrderID)
+				continue
+			}
 			log.Printf("processOrder failed: %v", err)
 			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = dbops.UpdateTxPaymentStatus(DB, custID, status.TransactionID, status.TxStatus)
+		err = DB.UpdateItem(dbops.NewOrderUpdate(custID, status.OrderID).
+			Set(field, status.TxStatus).
+			IfExists())
 		if err != nil {
+			if err.Error() == dbops.ErrConditionalCheck {
+				log.Printf("processOrder: order %s not found - retrying on redelivery", status.OrderID)
+				continue
+			}
 			log.Printf("processOrder failed: %v", err)
 			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = dbops.UpdateTxPaymentMethod(DB, custID, status.TransactionID, status.PaymentMethod)
+
+		// update order status; statuses may be delivered more than once by the
+		// payment webhook, so transitions of orders that are no longer in progress are skipped
+		event, ok := paymentEvents[status.TxStatus]
+		if !ok {
+			processedIDs = append(processedIDs, resp.MessageIDs[i])
+			processedHandles = append(processedHandles, resp.ReceiptHandles[i])
+			continue
+		}
+
+		// sell exclusive licenses & issue downloads of purchased digital items; settled
+		// before the order transitions so digital items are settled on redelivery if
+		// settling fails
+		if event == store.OrderEventPaymentSuccess {
+			if err := settleDigitalItems(status); err != nil {
+				log.Printf("processOrder failed: %v", err)
+				httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// restock physical items & release promotions of orders whose payment failed; reversed
+		// before the order transitions so they are reversed on redelivery if reversing fails
+		if event == store.OrderEventPaymentFail {
+			if err := cancelOrder(status); err != nil {
+				log.Printf("processOrder failed: %v", err)
+				httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		_, err = dbops.TransitionOrder(DB, custID, status.OrderID, event)
 		if err != nil {
+			var te *store.TransitionError
+			if errors.As(err, &te) && settled(te) {
+				log.Printf("processOrder: order %s not updated: %v", status.OrderID, err)
+				// paid orders are published on redelivery if publishing failed
+				if event == store.OrderEventPaymentSuccess {
+					if err := publishOrder(sns, custID, status.OrderID); err != nil {
+						log.Printf("processOrder failed: %v", err)
+						httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+						return
+					}
+				}
+				processedIDs = append(processedIDs, resp.MessageIDs[i])
+				processedHandles = append(processedHandles, resp.ReceiptHandles[i])
+				continue
+			}
+			if errors.As(err, &te) || err.Error() == dbops.ErrConditionalCheck || err.Error() == dbops.ErrOrderNotFound {
+				log.Printf("processOrder: order %s not updated: %v - retrying on redelivery", status.OrderID, err)
+				continue
+			}
 			log.Printf("processOrder failed: %v", err)
 			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = dbops.UpdateTxPaymentID(DB, custID, status.TransactionID, status.PaymentTxID)
+		if event == store.OrderEventPaymentSuccess {
+			if err := publishOrder(sns, custID, status.OrderID); err != nil {
+				log.Printf("processOrder failed: %v", err)
+				httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		processedIDs = append(processedIDs, resp.MessageIDs[i])
+		processedHandles = append(processedHandles, resp.ReceiptHandles[i])
+	}
+
+	// delete processed messages
+	if len(processedIDs) > 0 {
+		err = queueops.DeleteMessages(sqs, url, processedIDs, processedHandles)
 		if err != nil {
 			log.Printf("processOrder failed: %v", err)
 			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
 			return
 		}
+	}
+
+	httpops.ErrResponse(w, "Successfully retreived site info: ", successMsg, http.StatusOK)
+	return
+}
+
+// settled returns true if the payment event of the failed transition was already applied to the
+// order: the order is past PAYMENT_IN_PROGRESS, or was returned to OPEN by a failed payment.
+// Staged orders are PAYMENT_IN_PROGRESS, so a PAYMENT_SUCCESS on an OPEN order is retried.
+func settled(te *store.TransitionError) bool {
+	switch te.From {
+	case store.OrderStatusOpen, "":
+		return te.Event == store.OrderEventPaymentFail
+	case store.OrderStatusPaymentInProgress:
+		return false
+	}
+	return true
+}
+
+// publishOrder forwards the paid order to the Fulfillment topic. The order is marked
+// 'fulfillment_published' once published, so orders that failed to publish are published when
+// the payment status is redelivered. Orders past PAID are not published.
+func publishOrder(sns interface{}, userID, orderID string) error {
+	// get complete order record
+	order, err := DB.GetOrder(userID, orderID)
+	if err != nil {
+		log.Printf("publishOrder failed: %v", err)
+		return err
+	}
+	if order.Published || order.OrderStatus != store.OrderStatusPaid {
+		return nil
+	}
+
+	msgID, err := snsops.PublishOrderNotification(sns, order, FulfillmentTopicARN)
+	if err != nil {
+		log.Printf("publishOrder failed: %v", err)
+		return err
+	}
+	log.Printf("SNS message sent: %v", msgID)
+
+	err = DB.UpdateItem(dbops.NewOrderUpdate(userID, orderID).
+		Set("fulfillment_published", true).
+		IfExists())
+	if err != nil {
+		// order is published again on redelivery
+		log.Printf("publishOrder failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// cancelOrder restocks the physical items and releases the promotions of the staged order whose
+// payment failed (ex: customer authentication failed). Restocked holds are released and released
+// promotions are recorded on the order, so redelivered statuses are not reversed twice. Orders
+// that are no longer in progress are not changed.
+func cancelOrder(status store.PaymentStatus) error {
+	order, err := DB.GetOrder(status.CustomerID, status.OrderID)
+	if err != nil {
+		log.Printf("cancelOrder failed: %v", err)
+		return err
+	}
+	if order.OrderStatus != store.OrderStatusPaymentInProgress {
+		return nil
+	}
+
+	if order.RequiresShipping() {
+		if err := dbops.RestockOrderHolds(DB, order.OrderID); err != nil {
+			log.Printf("cancelOrder failed: %v", err)
+			return err
+		}
+	}
 
-		// get complete order record
-		order, err := dbops.GetOrder(DB, custID, status.OrderID)
+	// customer limits were reached by the order's redemptions, so promotions are not
+	// retrieved with dbops.GetCustomerPromotions
+	promos := []*store.Promotion{}
+	for _, d := range order.Discounts {
+		promo, err := DB.GetPromotion(store.NormalizeCode(d.Code))
 		if err != nil {
-			log.Printf("processOrder failed: %v", err)
-			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
-			return
+			log.Printf("cancelOrder failed: %v", err)
+			return err
 		}
+		if promo.Code == "" {
+			continue // deleted promotion
+		}
+		promos = append(promos, promo)
+	}
+	if err := DB.ReleaseOrderPromotions(order, promos); err != nil {
+		log.Printf("cancelOrder failed: %v", err)
+		return err
+	}
+	return nil
+}
 
-		// UPDATE TO ACTION PER TRANSACTION STATUS
-		// -- send inventory update message
-		// --
+// settleDigitalItems sells the exclusive licenses of the paid order's digital items and issues
+// the download grants of the items sold to the order. Exclusive licenses sold to another order,
+// and licenses of items whose exclusive license was sold to another order first, are refunded
+// and not downloaded. Grants already issued are not replaced.
+func settleDigitalItems(status store.PaymentStatus) error {
+	order, err := DB.GetOrder(status.CustomerID, status.OrderID)
+	if err != nil {
+		log.Printf("settleDigitalItems failed: %v", err)
+		return err
+	}
+	if len(order.DigitalItems()) == 0 {
+		return nil
+	}
 
-		// WRAP IN CONDITIONAL LOGIC FOR ON PAYMENT SUCCESS
-		// forward order to SNS topics
-		msgID, err := snsops.PublishOrderNotification(sns, order, FulfillmentTopicARN)
+	// concurrent exclusive purchases of an item are sold to the first order settled
+	sold, lost, err := dbops.SellExclusives(DB, order)
+	if err != nil {
+		log.Printf("settleDigitalItems failed: %v", err)
+		return err
+	}
+	for _, item := range sold {
+		// index is repaired by the rebuildIndex API if update fails
+		err := searchops.Update(Search, func(idx *searchops.Index) { idx.Remove(item.ItemID) })
 		if err != nil {
-			log.Printf("processOrder failed: %v", err)
-			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
-			return
+			log.Printf("settleDigitalItems failed - update search index: %v", err)
+		}
+	}
+	if len(lost) > 0 {
+		if err := refundExclusives(order, lost, status); err != nil {
+			log.Printf("settleDigitalItems failed: %v", err)
+			return err
 		}
-		log.Printf("SNS message sent: %v", msgID)
 	}
 
-	// delete processed messages
-	err = queueops.DeleteMessages(sqs, url, resp.MessageIDs, resp.ReceiptHandles)
+	grants, err := dbops.IssueDownloads(DB, order, time.Now())
 	if err != nil {
-		log.Printf("processOrder failed: %v", err)
-		httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
-		return
+		log.Printf("settleDigitalItems failed: %v", err)
+		return err
 	}
+	log.Printf("issued %d downloads for order %s", len(grants), order.OrderID)
+	return nil
+}
 
-	httpops.ErrResponse(w, "Successfully retreived site info: ", successMsg, http.StatusOK)
-	return
+// refundExclusives refunds the order's digital items that can no longer be licensed because an
+// exclusive license was sold to another order (see dbops.SellExclusives), and records the refund
+// as a Transaction with negative amounts. The refund is issued with an idempotency key derived
+// from the order ID and recorded with a Transaction ID derived from the same key, so redelivered
+// payment statuses retry failed refunds without refunding or recording them twice. The refunded
+// items are recorded on the order once the refund is recorded, and their downloads are not issued.
+func refundExclusives(order *store.Order, lost []*store.CartItem, status store.PaymentStatus) error {
+	if len(order.ExclusiveRefunds) > 0 {
+		return nil // refunded on a previous delivery
+	}
+	sizeIDs := []string{}
+	for _, item := range lost {
+		sizeIDs = append(sizeIDs, item.SizeID)
+	}
+
+	key := order.OrderID + "-exclusives"
+	subtotal, tax := order.RefundTotal(lost)
+	amount := subtotal.Add(tax)
+	r, err := Payments.Refund(status.PaymentTxID, amount, key)
+	if err != nil {
+		log.Printf("refundExclusives failed: %v", err)
+		return err
+	}
+
+	tx := &store.Transaction{
+		TransactionID:     hashops.GetMD5Hash(key),
+		UserID:            order.UserID,
+		OrderID:           order.OrderID,
+		Timestamp:         timeops.ConvertToTimestampString(time.Now()),
+		PaymentMethod:     status.PaymentMethod,
+		PaymentTxID:       r.ID,
+		SalesSubtotal:     subtotal.Neg(),
+		SalesTax:          tax.Neg(),
+		TotalAmount:       amount.Neg(),
+		PaymentStatus:     r.Status,
+		CorrespondingTxID: status.TransactionID,
+	}
+	if err := DB.PutTransaction(tx); err != nil {
+		log.Printf("refundExclusives failed: %v", err)
+		return err
+	}
+
+	err = DB.UpdateItem(dbops.NewOrderUpdate(order.UserID, order.OrderID).
+		Set("exclusive_refunds", sizeIDs).
+		IfExists())
+	if err != nil {
+		log.Printf("refundExclusives failed: %v", err)
+		return err
+	}
+	order.ExclusiveRefunds = sizeIDs
+	log.Printf("refunded exclusive licenses %v of order %s", sizeIDs, order.OrderID)
+	return nil
 }
 
 func main() {
@@ -0,0 +1,62 @@
+package main
+
+/* releaseHolds runs on a schedule to release the inventory holds of orders that expired before
+   payment. Released units are returned to the items' available-to-sell counts. */
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+)
+
+const route = "/orders/release_holds" // POST
+
+const failMsg = "Request failed!"
+const successMsg = "Request succeeded!"
+
+// releaseSummary contains the IDs of the orders whose holds were released.
+type releaseSummary struct {
+	Message  string   `json:"message"`
+	OrderIDs []string `json:"order_ids"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK,
+	},
+	dbops.Table{ // inventory holds table
+		Name:       dbops.HoldsTable(),
+		PrimaryKey: dbops.HoldsPK,
+		SortKey:    dbops.HoldsSK,
+	},
+}
+
+// / DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	released, err := dbops.ReleaseExpiredHolds(DB, time.Now().Unix())
+	if err != nil {
+		log.Printf("releaseHolds failed: %v", err)
+		httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("released holds for %d orders", len(released))
+
+	summary := releaseSummary{Message: successMsg, OrderIDs: released}
+	httpops.ErrResponse(w, "Released expired inventory holds: ", summary, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
@@ -3,13 +3,16 @@ package main
 // stageOrder receives order Staging messages from the Staging Queue.
 // Staging messages contain Order, Transaction, and Customer objects for
 // in-progress orders. Staged orders are actioned once payment is successfully
-// processed and a payment status confirmation message is received.
+// processed and a payment status confirmation message is received. Each stage
+// is written in a single transaction (see dbops.StageOrder). Stages that can not
+// be written are sent to the Staging dead-letter queue for manual review.
 
 import (
 	"log"
 	"net/http"
 
 	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/dbops"
 	"github.com/tpillz-presents/service/util/httpops"
 	"github.com/tpillz-presents/service/util/queueops"
@@ -28,19 +31,29 @@ var tables = []dbops.Table{
 		SortKey:    "",
 	},
 	dbops.Table{ // transactions table
-		Name:       dbops.TransactionsTable,
+		Name:       dbops.TransactionsTable(),
 		PrimaryKey: dbops.TransactionsPK,
 		SortKey:    dbops.TransactionsSK,
 	},
 	dbops.Table{ // orders table
-		Name:       dbops.OrdersTable,
+		Name:       dbops.OrdersTable(),
 		PrimaryKey: dbops.OrdersPK,
 		SortKey:    dbops.OrdersSK,
 	},
+	dbops.Table{ // store items table
+		Name:       dbops.StoreItemsTable(),
+		PrimaryKey: dbops.StoreItemPK,
+		SortKey:    dbops.StoreItemSK,
+	},
+	dbops.Table{ // inventory holds table
+		Name:       dbops.HoldsTable(),
+		PrimaryKey: dbops.HoldsPK,
+		SortKey:    dbops.HoldsSK,
+	},
 }
 
 // / DB is used to make DynamoDB API calls
-var DB = dbops.InitDB(tables)
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
 
 // RootHandler handles HTTP request to the root '/'
 func RootHandler(w http.ResponseWriter, r *http.Request) {
@@ -67,22 +80,36 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// put staged order info to database
+	// write staged order info to database
 	for _, stage := range resp.Stages {
-		err := dbops.PutOrder(DB, stage.Order)
-		if err != nil {
-			log.Printf("stageOrder failed: %v", err)
-			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
-			return
-		}
-		err = dbops.PutTransaction(DB, stage.Transaction)
+		inventory, err := unheldItems(stage.Order)
 		if err != nil {
 			log.Printf("stageOrder failed: %v", err)
 			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = dbops.PutCustomer(DB, stage.Customer)
+		err = DB.StageOrder(&dbops.OrderStage{
+			Order:       stage.Order,
+			Transaction: stage.Transaction,
+			Customer:    stage.Customer,
+			Inventory:   inventory,
+		})
 		if err != nil {
+			switch err.Error() {
+			case dbops.ErrOrderAlreadyStaged:
+				// staging messages may be delivered more than once
+				log.Printf("stageOrder: order %s already staged", stage.Order.OrderID)
+				continue
+			case dbops.ErrOrderConflict, dbops.ErrCustomerNotFound, dbops.ErrTransactionTooLarge, store.ErrInsufficientStock:
+				// retrying will not succeed - requires manual review
+				log.Printf("stageOrder: order %s not staged: %v", stage.Order.OrderID, err)
+				if err := sendDeadLetter(sqs, stage); err != nil {
+					log.Printf("stageOrder failed: %v", err)
+					httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
+					return
+				}
+				continue
+			}
 			log.Printf("stageOrder failed: %v", err)
 			httpops.ErrResponse(w, "Internal server error: ", err.Error(), http.StatusInternalServerError)
 			return
@@ -101,6 +128,45 @@ func RootHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// unheldItems returns the order's physical items that were not reserved by inventory holds.
+// The units of held items are decremented when the holds are converted at payment, which
+// precedes staging; items with unconverted holds are not decremented by staging.
+func unheldItems(order *store.Order) ([]*store.CartItem, error) {
+	holds, err := DB.GetOrderHolds(order.OrderID)
+	if err != nil {
+		log.Printf("unheldItems failed: %v", err)
+		return []*store.CartItem{}, err
+	}
+	held := make(map[string]bool)
+	for _, h := range holds {
+		held[h.SizeID] = true
+	}
+	items := []*store.CartItem{}
+	for _, item := range order.PhysicalItems() {
+		if !held[item.SizeID] {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// sendDeadLetter sends a stage that can not be staged to the Staging dead-letter queue for
+// manual review.
+func sendDeadLetter(sqs interface{}, stage queueops.Staging) error {
+	url, err := queueops.GetQueueURL(sqs, queueops.StagingDeadLetterFifoQueue)
+	if err != nil {
+		log.Printf("sendDeadLetter failed: %v", err)
+		return err
+	}
+	msgID, err := queueops.SendStagingMessage(sqs, url, stage)
+	if err != nil {
+		log.Printf("sendDeadLetter failed: %v", err)
+		return err
+	}
+	log.Printf("dead-letter message sent: %v", msgID)
+	return nil
+}
+
 func main() {
 	httpops.RegisterRoutes(route, RootHandler)
 	log.Fatal(gateway.ListenAndServe(":3000", nil))
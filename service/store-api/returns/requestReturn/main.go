@@ -0,0 +1,155 @@
+package main
+
+/* requestReturn API opens a return request for items of a customer's shipped order. The return
+   is reviewed by the store admin, who approves the return and sends the customer a return label. */
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/dbops"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/sesops"
+	"github.com/tpillz-presents/service/util/timeops"
+)
+
+const route = "/returns" // POST
+const failMsg = "Request failed!"
+const notEligibleMsg = "This order is not eligible for a return."
+
+const from = "dg.dev.test510@gmail.com" // test only - move to admin settings db table in prod
+
+// http request data
+type request struct {
+	UserEmail string         `json:"user_email"`
+	OrderID   string         `json:"order_id"`
+	Items     map[string]int `json:"items"` // item size IDs: quantity
+	Reason    string         `json:"reason"`
+}
+
+// list of tables function makes r/w calls to
+var tables = []dbops.Table{
+	dbops.Table{ // customers table
+		Name:       dbops.CustomersTable,
+		PrimaryKey: dbops.CustomersPK,
+		SortKey:    "",
+	},
+	dbops.Table{ // orders table
+		Name:       dbops.OrdersTable(),
+		PrimaryKey: dbops.OrdersPK,
+		SortKey:    dbops.OrdersSK,
+	},
+	dbops.Table{ // returns table
+		Name:       dbops.ReturnsTable(),
+		PrimaryKey: dbops.ReturnsPK,
+		SortKey:    dbops.ReturnsSK,
+	},
+}
+
+// DB is used to make DynamoDB API calls
+var DB dbops.Store = dbops.NewDynamoStore(dbops.InitDB(tables))
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// verify content-type
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		httpops.ErrResponse(w, "Content-Type is not application/json", failMsg, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// decode JSON object from http request
+	data := request{}
+	var unmarshalErr *json.UnmarshalTypeError
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&data)
+	if err != nil {
+		if errors.As(err, &unmarshalErr) {
+			httpops.ErrResponse(w, "Bad Request: Wrong type provided for field "+unmarshalErr.Field, failMsg, http.StatusBadRequest)
+		} else {
+			httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// get customer
+	cust, err := DB.GetCustomer(data.UserEmail)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// get order
+	order, err := DB.GetOrder(cust.UserID, data.OrderID)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	if cust.UserID == "" || order.OrderID == "" {
+		httpops.ErrResponse(w, "Order not found: "+data.OrderID, failMsg, http.StatusNotFound)
+		return
+	}
+
+	// create return
+	ret, err := store.NewReturn(order, data.Items, data.Reason)
+	if err != nil {
+		if err.Error() == store.ErrReturnNotEligible {
+			httpops.ErrResponse(w, "Return not eligible: "+order.OrderStatus, notEligibleMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+	ret.RequestDate = timeops.ConvertToTimestampString(time.Now())
+
+	// update order status
+	_, err = dbops.TransitionOrder(DB, order.UserID, order.OrderID, store.OrderEventRequestReturn)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		var te *store.TransitionError
+		if errors.As(err, &te) || err.Error() == dbops.ErrConditionalCheck {
+			httpops.ErrResponse(w, "Return not eligible: "+err.Error(), notEligibleMsg, http.StatusConflict)
+			return
+		}
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	err = DB.PutReturn(ret)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// update customer - incremented in place so concurrent customer updates are not lost
+	err = DB.UpdateItem(dbops.NewCustomerUpdate(cust.Email).Increment("returns", 1).IfExists())
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// notify customer
+	err = sesops.SendReturnNotification(sesops.InitSesh(), from, ret, order)
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+	}
+
+	httpops.ErrResponse(w, "Return requested: ", ret, http.StatusOK)
+	return
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
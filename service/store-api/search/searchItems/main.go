@@ -0,0 +1,93 @@
+package main
+
+/* searchItems searches the store catalog by name, description, category and subcategory. Results
+   are ranked by relevance and include facet counts by category, available size and price bucket.
+   The search index is loaded from its S3 snapshot and reloaded every few minutes on warm instances. */
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/gateway"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/httpops"
+	"github.com/tpillz-presents/service/util/searchops"
+)
+
+const route = "/store/search" // GET
+const failMsg = "Request failed!"
+
+// maxLimit is the maximum number of results returned
+const maxLimit = 100
+
+// indexMaxAge is the duration the search index is cached for before reloading
+const indexMaxAge = 5 * time.Minute
+
+// Index is used to load the catalog search index
+var Index = searchops.NewCache(searchops.NewS3SnapshotsFromEnv(), indexMaxAge)
+
+// RootHandler handles HTTP request to the root '/'
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	// get query strings from GET call
+	params := httpops.GetQueryStringParams(r)
+	query, err := parseQuery(params)
+	if err != nil {
+		httpops.ErrResponse(w, "Bad Request: "+err.Error(), failMsg, http.StatusBadRequest)
+		return
+	}
+
+	// search index
+	idx, err := Index.Index()
+	if err != nil {
+		log.Printf("RootHandler failed: %v", err)
+		httpops.ErrResponse(w, "Internal Server Error: "+err.Error(), failMsg, http.StatusInternalServerError)
+		return
+	}
+	results := idx.Search(query)
+
+	// return results to user
+	httpops.ErrResponse(w, "Success! Returning results...", results, http.StatusOK)
+	return
+}
+
+// parseQuery returns the search query for the given query string parameters. Prices are in US cents.
+func parseQuery(params map[string]string) (searchops.Query, error) {
+	query := searchops.Query{
+		Text:        params["q"],
+		Category:    params["category"],
+		Subcategory: params["sub_category"],
+		Size:        params["size"],
+		PriceBucket: params["price_bucket"],
+	}
+	var limit, min, max int64
+	for key, val := range map[string]*int64{"limit": &limit, "min_price": &min, "max_price": &max} {
+		v := params[key]
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return query, fmt.Errorf("invalid value for %s: %s", key, v)
+		}
+		*val = n
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	query.Limit = int(limit)
+	if min > 0 {
+		query.MinPrice = store.USD(min)
+	}
+	if max > 0 {
+		query.MaxPrice = store.USD(max)
+	}
+	return query, nil
+}
+
+func main() {
+	httpops.RegisterRoutes(route, RootHandler)
+	log.Fatal(gateway.ListenAndServe(":3000", nil))
+}
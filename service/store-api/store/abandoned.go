@@ -0,0 +1,83 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CartPromotionPrefix prefixes the coupon codes of one-time abandoned cart discounts.
+const CartPromotionPrefix = "CART-"
+
+// Touch records a cart update at now and sets the cart record to expire ttl after now.
+func (c *ShoppingCart) Touch(now time.Time, ttl time.Duration) {
+	c.UpdatedAt = now.Unix()
+	c.TTL = now.Add(ttl).Unix()
+}
+
+// ReminderDue returns true if an abandoned cart reminder should be sent for the cart: the cart
+// has items and a recipient, has not been updated for the idle duration, was last reminded at
+// least the idle duration ago, and has been sent fewer than max reminders.
+func (c *ShoppingCart) ReminderDue(now time.Time, idle time.Duration, max int) bool {
+	if len(c.Items) == 0 || c.UserEmail == "" || len(c.Reminders) >= max {
+		return false
+	}
+	cutoff := now.Add(-idle).Unix()
+	if c.UpdatedAt == 0 || c.UpdatedAt > cutoff {
+		return false
+	}
+	if n := len(c.Reminders); n > 0 && c.Reminders[n-1] > cutoff {
+		return false
+	}
+	return true
+}
+
+// InStockItems returns the cart's items with units available to sell, sorted by size ID.
+// StoreItems are keyed by item ID.
+func (c *ShoppingCart) InStockItems(items map[string]*StoreItem) []*CartItem {
+	inStock := []*CartItem{}
+	for _, line := range c.Items {
+		if item := items[line.ItemID]; item != nil && item.AvailableToSell(line.Size) > 0 {
+			inStock = append(inStock, line)
+		}
+	}
+	sort.Slice(inStock, func(i, j int) bool { return inStock[i].SizeID < inStock[j].SizeID })
+	return inStock
+}
+
+// NewCartPromotion returns a single use PERCENT_OFF promotion scoped to the cart's items with a
+// random coupon code, expiring validFor after now.
+func NewCartPromotion(c *ShoppingCart, pct float64, now time.Time, validFor time.Duration) (*Promotion, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return &Promotion{}, err
+	}
+	ids := []string{}
+	seen := make(map[string]bool)
+	for _, line := range c.Items {
+		if !seen[line.ItemID] {
+			seen[line.ItemID] = true
+			ids = append(ids, line.ItemID)
+		}
+	}
+	sort.Strings(ids)
+	p := &Promotion{
+		Code:                 CartPromotionPrefix + strings.ToUpper(hex.EncodeToString(b)),
+		Description:          fmt.Sprintf("%g%% off the items in your cart", pct),
+		PromotionType:        PromotionPercentOff,
+		PercentOff:           pct,
+		ItemIDs:              ids,
+		EndDate:              now.UTC().Add(validFor).Format(promotionDateLayout),
+		MaxRedemptions:       1,
+		RedemptionsRemaining: 1,
+		MaxPerCustomer:       1,
+		Active:               true,
+	}
+	if err := p.Validate(); err != nil {
+		return &Promotion{}, err
+	}
+	return p, nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShoppingCartReminderDue(t *testing.T) {
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+	idle := 24 * time.Hour
+	items := map[string]*CartItem{"005-M": {ItemID: "005", Size: "M", Quantity: 1}}
+
+	var tests = []struct {
+		cart *ShoppingCart
+		want bool
+	}{
+		{cart: &ShoppingCart{Items: items, UserEmail: "a@example.com", UpdatedAt: now.Add(-25 * time.Hour).Unix()}, want: true},
+		{cart: &ShoppingCart{Items: items, UserEmail: "a@example.com", UpdatedAt: now.Add(-23 * time.Hour).Unix()}, want: false}, // not idle
+		{cart: &ShoppingCart{Items: items, UpdatedAt: now.Add(-25 * time.Hour).Unix()}, want: false},                             // no recipient
+		{cart: &ShoppingCart{UserEmail: "a@example.com", UpdatedAt: now.Add(-25 * time.Hour).Unix()}, want: false},               // empty
+		{cart: &ShoppingCart{Items: items, UserEmail: "a@example.com", UpdatedAt: now.Add(-72 * time.Hour).Unix(),
+			Reminders: []int64{now.Add(-48 * time.Hour).Unix()}}, want: true},
+		{cart: &ShoppingCart{Items: items, UserEmail: "a@example.com", UpdatedAt: now.Add(-72 * time.Hour).Unix(),
+			Reminders: []int64{now.Add(-12 * time.Hour).Unix()}}, want: false}, // reminded this period
+		{cart: &ShoppingCart{Items: items, UserEmail: "a@example.com", UpdatedAt: now.Add(-96 * time.Hour).Unix(),
+			Reminders: []int64{now.Add(-72 * time.Hour).Unix(), now.Add(-48 * time.Hour).Unix()}}, want: false}, // max reminders
+	}
+	for _, test := range tests {
+		if got := test.cart.ReminderDue(now, idle, 2); got != test.want {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+	}
+}
+
+func TestShoppingCartInStockItems(t *testing.T) {
+	items := newTestCartItems()
+	cart := &ShoppingCart{UserID: "user001"}
+	cart.AddItem(items["005"], CartItem{Size: "M"}, 1)
+	cart.AddItem(items["005"], CartItem{Size: "L"}, 1)
+	cart.AddItem(items["001"], CartItem{Size: "OS"}, 1)
+	items["005"].UnitsAvailable["L"] = 1 // 1 unit reserved
+	delete(items, "001")
+
+	inStock := cart.InStockItems(items)
+	if len(inStock) != 1 || inStock[0].SizeID != "005-M" {
+		t.Errorf("FAIL: %v; want: [005-M]", inStock)
+	}
+}
+
+func TestNewCartPromotion(t *testing.T) {
+	items := newTestCartItems()
+	cart := &ShoppingCart{UserID: "user001"}
+	cart.AddItem(items["005"], CartItem{Size: "M"}, 1)
+	cart.AddItem(items["005"], CartItem{Size: "L"}, 1)
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+
+	p, err := NewCartPromotion(cart, 10, now, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if !strings.HasPrefix(p.Code, CartPromotionPrefix) || len(p.ItemIDs) != 1 || p.EndDate != "06-06-2021 12:00:00" {
+		t.Errorf("FAIL: %s, %v, %s", p.Code, p.ItemIDs, p.EndDate)
+	}
+	if err := p.Eligible(cart.InStockItems(items), USD(4590), now); err != nil {
+		t.Errorf("FAIL: %v; want: nil", err)
+	}
+	if _, err := NewCartPromotion(cart, 0, now, time.Hour); err == nil {
+		t.Errorf("FAIL: nil; want: %v", ErrInvalidPromotion)
+	}
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Browse sort options
+const (
+	SortPrice      = "price"      // ascending by default
+	SortName       = "name"       // ascending by default
+	SortDateAdded  = "date_added" // newest first by default
+	SortPopularity = "popularity" // units sold, then product views; most popular first by default
+)
+
+// Browse sort orders
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// DefaultBrowseLimit is the page size used when no limit is requested.
+const DefaultBrowseLimit = 24
+
+// MaxBrowseLimit is the maximum page size.
+const MaxBrowseLimit = 100
+
+// dateAddedLayout is the timestamp format of the DateAdded field ('MM-DD-YYYY HH:MM:SS').
+const dateAddedLayout = "01-02-2006 15:04:05"
+
+// ErrInvalidBrowseOptions is returned when a browse request has an invalid sort, order, limit
+// or price range.
+const ErrInvalidBrowseOptions = "ERR_INVALID_BROWSE_OPTIONS"
+
+// ErrInvalidCursor is returned when a page cursor cannot be decoded or was issued for a
+// different sort order.
+const ErrInvalidCursor = "ERR_INVALID_CURSOR"
+
+// BrowseOptions represents the page size, sort order and filters of a browse request.
+// Zero value MinPrice and MaxPrice fields are not applied.
+type BrowseOptions struct {
+	Limit    int    `json:"limit"`
+	Cursor   string `json:"cursor"` // opaque token returned as BrowsePage.NextCursor
+	Sort     string `json:"sort"`
+	Order    string `json:"order"`
+	MinPrice Money  `json:"min_price"`
+	MaxPrice Money  `json:"max_price"`
+}
+
+// BrowsePage represents a page of StoreItemSummary objects. NextCursor is empty on the last page.
+type BrowsePage struct {
+	Items      []StoreItemSummary `json:"items"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+// browseCursor is the decoded page cursor. The cursor contains the sort key of the last item of
+// the previous page, so that pages remain consistent when items are added or removed.
+type browseCursor struct {
+	Sort  string           `json:"s"`
+	Order string           `json:"o"`
+	Last  StoreItemSummary `json:"l"`
+}
+
+// ParseBrowseOptions returns the BrowseOptions for the given query string parameters
+// ('limit', 'cursor', 'sort', 'order', 'min_price', 'max_price'). Prices are in US cents.
+func ParseBrowseOptions(params map[string]string) (BrowseOptions, error) {
+	opts := BrowseOptions{
+		Cursor: params["cursor"],
+		Sort:   params["sort"],
+		Order:  params["order"],
+	}
+	var err error
+	if v := params["limit"]; v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			return opts, fmt.Errorf(ErrInvalidBrowseOptions)
+		}
+	}
+	for key, price := range map[string]*Money{"min_price": &opts.MinPrice, "max_price": &opts.MaxPrice} {
+		v := params[key]
+		if v == "" {
+			continue
+		}
+		amount, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf(ErrInvalidBrowseOptions)
+		}
+		*price = USD(amount)
+	}
+	return opts, opts.Validate()
+}
+
+// Validate sets the default limit, sort and order fields and verifies the options.
+func (o *BrowseOptions) Validate() error {
+	if o.Limit == 0 {
+		o.Limit = DefaultBrowseLimit
+	}
+	if o.Limit < 0 || o.Limit > MaxBrowseLimit {
+		return fmt.Errorf(ErrInvalidBrowseOptions)
+	}
+	if o.Sort == "" {
+		o.Sort = SortName
+	}
+	switch o.Sort {
+	case SortPrice, SortName:
+		if o.Order == "" {
+			o.Order = SortAsc
+		}
+	case SortDateAdded, SortPopularity:
+		if o.Order == "" {
+			o.Order = SortDesc
+		}
+	default:
+		return fmt.Errorf(ErrInvalidBrowseOptions)
+	}
+	if o.Order != SortAsc && o.Order != SortDesc {
+		return fmt.Errorf(ErrInvalidBrowseOptions)
+	}
+	if o.MinPrice.Amount < 0 || (o.MaxPrice.Amount > 0 && o.MaxPrice.Amount < o.MinPrice.Amount) {
+		return fmt.Errorf(ErrInvalidBrowseOptions)
+	}
+	return nil
+}
+
+// InPriceRange returns true if the price is within the options' price range.
+func (o *BrowseOptions) InPriceRange(price Money) bool {
+	if o.MinPrice.Amount > 0 && price.Amount < o.MinPrice.Amount {
+		return false
+	}
+	if o.MaxPrice.Amount > 0 && price.Amount > o.MaxPrice.Amount {
+		return false
+	}
+	return true
+}
+
+// Paginate filters and sorts the given items, and returns the page following the options'
+// cursor. The options must be validated before calling Paginate.
+func Paginate(items []StoreItemSummary, opts BrowseOptions) (BrowsePage, error) {
+	page := BrowsePage{Items: []StoreItemSummary{}}
+	less := browseLess(opts.Sort, opts.Order == SortDesc)
+
+	filtered := []StoreItemSummary{}
+	for _, item := range items {
+		if opts.InPriceRange(item.Price) {
+			filtered = append(filtered, item)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return less(filtered[i], filtered[j]) })
+
+	start := 0
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil || c.Sort != opts.Sort || c.Order != opts.Order {
+			return page, fmt.Errorf(ErrInvalidCursor)
+		}
+		// first item sorted after the previous page's last item
+		start = sort.Search(len(filtered), func(i int) bool { return less(c.Last, filtered[i]) })
+	}
+
+	end := start + opts.Limit
+	if end >= len(filtered) {
+		page.Items = append(page.Items, filtered[start:]...)
+		return page, nil
+	}
+	page.Items = append(page.Items, filtered[start:end]...)
+	page.NextCursor = encodeCursor(browseCursor{Sort: opts.Sort, Order: opts.Order, Last: filtered[end-1]})
+	return page, nil
+}
+
+// browseLess returns the comparison function for the given sort field. Items with equal sort
+// keys are ordered by ItemID, so that every item has a unique position.
+func browseLess(field string, desc bool) func(a, b StoreItemSummary) bool {
+	return func(a, b StoreItemSummary) bool {
+		c := 0
+		switch field {
+		case SortPrice:
+			c = compareInt(a.Price.Amount, b.Price.Amount)
+		case SortName:
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case SortDateAdded:
+			c = compareInt(dateAdded(a).Unix(), dateAdded(b).Unix())
+		case SortPopularity:
+			c = compareInt(int64(a.UnitsSold), int64(b.UnitsSold))
+			if c == 0 {
+				c = compareInt(int64(a.ProductViews), int64(b.ProductViews))
+			}
+		}
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			return a.ItemID < b.ItemID
+		}
+		return c < 0
+	}
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// dateAdded returns the parsed DateAdded field. Items without a valid date are sorted as oldest.
+func dateAdded(item StoreItemSummary) time.Time {
+	t, err := time.Parse(dateAddedLayout, item.DateAdded)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func encodeCursor(c browseCursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (browseCursor, error) {
+	c := browseCursor{}
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(js, &c)
+	return c, err
+}
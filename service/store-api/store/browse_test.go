@@ -0,0 +1,117 @@
+package store
+
+import (
+	"testing"
+)
+
+// testBrowseItems returns 5 item summaries with distinct prices, names, dates and popularity.
+func testBrowseItems() []StoreItemSummary {
+	return []StoreItemSummary{
+		{ItemID: "001", Name: "PawnWars Game Set", Price: USD(2995), DateAdded: "01-15-2022 10:00:00", UnitsSold: 12, ProductViews: 300},
+		{ItemID: "002", Name: "pawnwars chess pieces", Price: USD(1495), DateAdded: "03-01-2022 08:30:00", UnitsSold: 4, ProductViews: 90},
+		{ItemID: "003", Name: "King Poster", Price: USD(1795), DateAdded: "02-10-2022 12:00:00", UnitsSold: 12, ProductViews: 150},
+		{ItemID: "004", Name: "Queen Poster", Price: USD(1795), DateAdded: "", UnitsSold: 0, ProductViews: 10},
+		{ItemID: "005", Name: "Logo T-Shirt", Price: USD(2295), DateAdded: "05-20-2022 18:45:00", UnitsSold: 30, ProductViews: 25},
+	}
+}
+
+func TestParseBrowseOptions(t *testing.T) {
+	var tests = []struct {
+		params  map[string]string
+		want    BrowseOptions
+		wantErr bool
+	}{
+		{params: map[string]string{}, want: BrowseOptions{Limit: DefaultBrowseLimit, Sort: SortName, Order: SortAsc}, wantErr: false},
+		{params: map[string]string{"sort": "popularity"}, want: BrowseOptions{Limit: DefaultBrowseLimit, Sort: SortPopularity, Order: SortDesc}, wantErr: false},
+		{params: map[string]string{"sort": "price", "order": "desc", "limit": "2", "min_price": "1500", "max_price": "2500"}, want: BrowseOptions{Limit: 2, Sort: SortPrice, Order: SortDesc, MinPrice: USD(1500), MaxPrice: USD(2500)}, wantErr: false},
+		{params: map[string]string{"sort": "rating"}, wantErr: true},
+		{params: map[string]string{"order": "up"}, wantErr: true},
+		{params: map[string]string{"limit": "ten"}, wantErr: true},
+		{params: map[string]string{"limit": "101"}, wantErr: true},
+		{params: map[string]string{"min_price": "$15"}, wantErr: true},
+		{params: map[string]string{"min_price": "2500", "max_price": "1500"}, wantErr: true},
+	}
+	for _, test := range tests {
+		opts, err := ParseBrowseOptions(test.params)
+		if (err != nil) != test.wantErr {
+			t.Errorf("FAIL: %v; want err: %v", err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && opts != test.want {
+			t.Errorf("FAIL: %v; want: %v", opts, test.want)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	var tests = []struct {
+		opts BrowseOptions
+		want [][]string // item IDs of each page
+	}{
+		{opts: BrowseOptions{Limit: 2, Sort: SortPrice, Order: SortAsc}, want: [][]string{{"002", "003"}, {"004", "005"}, {"001"}}},
+		{opts: BrowseOptions{Limit: 2, Sort: SortPrice, Order: SortDesc}, want: [][]string{{"001", "005"}, {"003", "004"}, {"002"}}},
+		{opts: BrowseOptions{Limit: 3, Sort: SortName, Order: SortAsc}, want: [][]string{{"003", "005", "002"}, {"001", "004"}}},
+		{opts: BrowseOptions{Limit: 5, Sort: SortDateAdded, Order: SortDesc}, want: [][]string{{"005", "002", "003", "001", "004"}}},
+		{opts: BrowseOptions{Limit: 2, Sort: SortPopularity, Order: SortDesc}, want: [][]string{{"005", "001"}, {"003", "002"}, {"004"}}},
+		{opts: BrowseOptions{Limit: 10, Sort: SortPrice, Order: SortAsc, MinPrice: USD(1500), MaxPrice: USD(2500)}, want: [][]string{{"003", "004", "005"}}},
+	}
+	for _, test := range tests {
+		opts := test.opts
+		for i, want := range test.want {
+			page, err := Paginate(testBrowseItems(), opts)
+			if err != nil {
+				t.Fatalf("FAIL: %v", err)
+			}
+			got := []string{}
+			for _, item := range page.Items {
+				got = append(got, item.ItemID)
+			}
+			if len(got) != len(want) {
+				t.Errorf("FAIL - page %d: %v; want: %v", i, got, want)
+				break
+			}
+			for j := range got {
+				if got[j] != want[j] {
+					t.Errorf("FAIL - page %d: %v; want: %v", i, got, want)
+					break
+				}
+			}
+			last := i == len(test.want)-1
+			if last != (page.NextCursor == "") {
+				t.Errorf("FAIL - page %d cursor: %q", i, page.NextCursor)
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+}
+
+func TestPaginateCursor(t *testing.T) {
+	opts := BrowseOptions{Limit: 2, Sort: SortPrice, Order: SortAsc}
+	page, _ := Paginate(testBrowseItems(), opts)
+
+	// item removed & added before the cursor position
+	items := testBrowseItems()[1:]
+	items = append(items, StoreItemSummary{ItemID: "006", Name: "Sticker", Price: USD(500)})
+	opts.Cursor = page.NextCursor
+	next, err := Paginate(items, opts)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if len(next.Items) != 2 || next.Items[0].ItemID != "004" || next.Items[1].ItemID != "005" {
+		t.Errorf("FAIL: %v; want: [004 005]", next.Items)
+	}
+
+	var tests = []struct {
+		cursor string
+		sort   string
+	}{
+		{cursor: "not-a-cursor!", sort: SortPrice},
+		{cursor: page.NextCursor, sort: SortName}, // issued for a different sort
+	}
+	for _, test := range tests {
+		_, err := Paginate(testBrowseItems(), BrowseOptions{Limit: 2, Sort: test.sort, Order: SortAsc, Cursor: test.cursor})
+		if err == nil || err.Error() != ErrInvalidCursor {
+			t.Errorf("FAIL: %v; want: %v", err, ErrInvalidCursor)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrItemNotFound is returned when a cart item's StoreItem or size does not exist.
+const ErrItemNotFound = "ERR_ITEM_NOT_FOUND"
+
+// ErrCartItemNotFound is returned when an item size is not in the user's cart.
+const ErrCartItemNotFound = "ERR_CART_ITEM_NOT_FOUND"
+
+// ErrInvalidQuantity is returned when a cart item quantity is negative, or zero when adding
+// an item.
+const ErrInvalidQuantity = "ERR_INVALID_QUANTITY"
+
+// weight conversion factors
+const (
+	ozsPerLb float32 = 16
+	kgsPerOz float32 = 0.028349523
+)
+
+// NewSizeID returns the size ID of the item size (ex: '005-L').
+func NewSizeID(itemID, size string) string {
+	return fmt.Sprintf("%s-%s", itemID, size)
+}
+
+// unitWeightOzs returns the unit weight of the item in ounces.
+func (s *StoreItem) unitWeightOzs() float32 {
+	if s.UnitWeightOzs > 0 {
+		return s.UnitWeightOzs
+	}
+	return s.UnitWeightLbs * ozsPerLb
+}
+
+// hasSize returns true if the item is sold in the given size, or license tier for digital
+// items.
+func (s *StoreItem) hasSize(size string) bool {
+	if s.IsDigital() {
+		_, ok := s.Licenses[size]
+		return ok
+	}
+	_, ok := s.UnitsAvailable[size]
+	return ok
+}
+
+// AddItem adds quantity units of the StoreItem's size to the cart. The line's price is taken
+// from the StoreItem; the dimensions and thumbnail of the given CartItem are kept for new lines.
+// Returns ErrInsufficientStock if the size's available-to-sell units are less than the
+// quantity in the cart after the add. Cart totals are not updated (see Recalculate).
+func (c *ShoppingCart) AddItem(item *StoreItem, line CartItem, quantity int) error {
+	if quantity <= 0 {
+		return fmt.Errorf(ErrInvalidQuantity)
+	}
+	if !item.hasSize(line.Size) {
+		return fmt.Errorf(ErrItemNotFound)
+	}
+	if c.Items == nil {
+		c.Items = make(map[string]*CartItem)
+	}
+	sizeID := NewSizeID(item.ItemID, line.Size)
+	current := 0
+	if existing := c.Items[sizeID]; existing != nil {
+		current = existing.Quantity
+	}
+	if current+quantity > item.AvailableToSell(line.Size) {
+		return fmt.Errorf(ErrInsufficientStock)
+	}
+
+	if existing := c.Items[sizeID]; existing != nil {
+		existing.Quantity += quantity
+		return nil
+	}
+	line.UserID = c.UserID
+	line.ItemID = item.ItemID
+	line.SizeID = sizeID
+	line.Name = item.Name
+	line.Subcategory = item.Subcategory
+	line.Quantity = quantity
+	line.Price = item.PriceOf(line.Size)
+	line.AddedPrice = line.Price
+	line.Digital = item.IsDigital()
+	c.Items[sizeID] = &line
+	return nil
+}
+
+// SetQuantity sets the quantity of the StoreItem size in the cart. Setting the quantity to 0
+// removes the item. Returns ErrCartItemNotFound if the size is not in the cart and
+// ErrInsufficientStock if the quantity exceeds the size's available-to-sell units.
+func (c *ShoppingCart) SetQuantity(item *StoreItem, size string, quantity int) error {
+	if quantity < 0 {
+		return fmt.Errorf(ErrInvalidQuantity)
+	}
+	sizeID := NewSizeID(item.ItemID, size)
+	line := c.Items[sizeID]
+	if line == nil {
+		return fmt.Errorf(ErrCartItemNotFound)
+	}
+	if quantity == 0 {
+		delete(c.Items, sizeID)
+		return nil
+	}
+	if quantity > item.AvailableToSell(size) {
+		return fmt.Errorf(ErrInsufficientStock)
+	}
+	line.Quantity = quantity
+	return nil
+}
+
+// RemoveItem removes the item size from the cart. Returns ErrCartItemNotFound if the size is
+// not in the cart.
+func (c *ShoppingCart) RemoveItem(sizeID string) error {
+	if c.Items[sizeID] == nil {
+		return fmt.Errorf(ErrCartItemNotFound)
+	}
+	delete(c.Items, sizeID)
+	return nil
+}
+
+// RemoveOrdered removes the ordered quantities of the order's items from the cart. Units added
+// to the cart after the order was created are kept.
+func (c *ShoppingCart) RemoveOrdered(order *Order) {
+	for _, item := range order.Items {
+		line := c.Items[item.SizeID]
+		if line == nil {
+			continue
+		}
+		line.Quantity -= item.Quantity
+		if line.Quantity <= 0 {
+			delete(c.Items, item.SizeID)
+		}
+	}
+}
+
+// Clear removes all items from the cart and resets the cart totals.
+func (c *ShoppingCart) Clear() {
+	c.Items = make(map[string]*CartItem)
+	c.Recalculate(map[string]*StoreItem{})
+}
+
+// Recalculate sets each line's price and weight from its StoreItem, keyed by item ID, and
+// recomputes the cart's TotalItems, Subtotal and weights. Lines are flagged if the item's price
+// changed since the line was added, or if the item's available-to-sell units are less than the
+// line's quantity. Lines without a StoreItem are flagged as out of stock and keep their price.
+func (c *ShoppingCart) Recalculate(items map[string]*StoreItem) {
+	c.TotalItems = 0
+	c.Subtotal = Money{}
+	c.CartWeightOzs = 0
+	for _, line := range c.Items {
+		item := items[line.ItemID]
+		if item == nil {
+			line.InsufficientStock = true
+		} else {
+			line.Price = item.PriceOf(line.Size)
+			line.Digital = item.IsDigital()
+			line.InsufficientStock = line.Quantity > item.AvailableToSell(line.Size)
+			line.TotalWeightOzs = item.unitWeightOzs() * float32(line.Quantity)
+			line.TotalWeightLbs = line.TotalWeightOzs / ozsPerLb
+		}
+		if line.AddedPrice.IsZero() {
+			line.AddedPrice = line.Price
+		}
+		line.PriceChanged = line.Price.Cmp(line.AddedPrice) != 0
+		line.ItemSubtotal = line.Price.Mul(int64(line.Quantity))
+
+		c.TotalItems += line.Quantity
+		c.Subtotal = c.Subtotal.Add(line.ItemSubtotal)
+		c.CartWeightOzs += line.TotalWeightOzs
+	}
+	c.CartWeightLbs = c.CartWeightOzs / ozsPerLb
+	c.CartWeightKgs = c.CartWeightOzs * kgsPerOz
+}
+
+// Flagged returns the sorted size IDs of the cart's lines flagged by Recalculate.
+func (c *ShoppingCart) Flagged() []string {
+	flagged := []string{}
+	for sizeID, line := range c.Items {
+		if line.PriceChanged || line.InsufficientStock {
+			flagged = append(flagged, sizeID)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
+// GuestIDPrefix prefixes the user IDs generated for anonymous shopping sessions.
+const GuestIDPrefix = "guest-"
+
+// NewGuestID returns a new random user ID for an anonymous shopping session.
+func NewGuestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return GuestIDPrefix + hex.EncodeToString(b), nil
+}
+
+// IsGuestID returns true if the user ID was generated for an anonymous shopping session.
+func IsGuestID(userID string) bool {
+	return strings.HasPrefix(userID, GuestIDPrefix)
+}
+
+// Quantity merge rules
+const (
+	MergeQuantitySum  = "SUM"  // sum the quantities of both carts
+	MergeQuantityMax  = "MAX"  // keep the larger quantity
+	MergeQuantityKeep = "KEEP" // keep the customer cart's quantity
+)
+
+// MergeRules configure how items in both a guest cart and a customer cart are merged.
+type MergeRules struct {
+	Quantity    string // MergeQuantitySum, MergeQuantityMax or MergeQuantityKeep
+	CapAtStock  bool   // cap merged quantities at the units available to sell
+	NewestPrice bool   // keep the added price of the most recently added line
+}
+
+// DefaultMergeRules sums quantities capped at stock and keeps the newest price.
+var DefaultMergeRules = MergeRules{Quantity: MergeQuantitySum, CapAtStock: true, NewestPrice: true}
+
+// Merge merges the guest cart's items into the cart using the given rules. StoreItems are keyed
+// by item ID; quantities of items without a StoreItem are not capped. Items capped to 0 units are
+// not merged. Cart totals are not updated (see Recalculate).
+func (c *ShoppingCart) Merge(guest *ShoppingCart, items map[string]*StoreItem, rules MergeRules) {
+	if c.Items == nil {
+		c.Items = make(map[string]*CartItem)
+	}
+	for sizeID, g := range guest.Items {
+		line := c.Items[sizeID]
+		if line == nil {
+			merged := *g
+			merged.UserID = c.UserID
+			line = &merged
+		} else {
+			switch rules.Quantity {
+			case MergeQuantitySum:
+				line.Quantity += g.Quantity
+			case MergeQuantityMax:
+				if g.Quantity > line.Quantity {
+					line.Quantity = g.Quantity
+				}
+			}
+			if rules.NewestPrice && g.AddedAt > line.AddedAt {
+				line.AddedPrice = g.AddedPrice
+				line.AddedAt = g.AddedAt
+			}
+		}
+		if item := items[line.ItemID]; item != nil && rules.CapAtStock {
+			if ats := item.AvailableToSell(line.Size); line.Quantity > ats {
+				line.Quantity = ats
+			}
+		}
+		if line.Quantity <= 0 {
+			delete(c.Items, sizeID)
+			continue
+		}
+		c.Items[sizeID] = line
+	}
+}
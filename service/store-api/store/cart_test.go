@@ -0,0 +1,161 @@
+package store
+
+import (
+	"testing"
+)
+
+func newTestCartItems() map[string]*StoreItem {
+	return map[string]*StoreItem{
+		"005": {ItemID: "005", Subcategory: "shirts", Name: "Logo T-Shirt", Price: USD(2295), UnitWeightOzs: 6,
+			UnitsAvailable: map[string]int{"M": 3, "L": 2}, UnitsReserved: map[string]int{"L": 1}},
+		"001": {ItemID: "001", Subcategory: "game_sets", Name: "PawnWars Game Set", Price: USD(2995), UnitWeightLbs: 2.5,
+			UnitsAvailable: map[string]int{"OS": 5}},
+	}
+}
+
+func TestShoppingCartAddItem(t *testing.T) {
+	items := newTestCartItems()
+	cart := &ShoppingCart{UserID: "user001"}
+
+	var tests = []struct {
+		itemID   string
+		size     string
+		quantity int
+		wantErr  string
+		wantQty  int // quantity of size in cart after add
+	}{
+		{itemID: "005", size: "M", quantity: 2, wantQty: 2},
+		{itemID: "005", size: "M", quantity: 1, wantQty: 3},
+		{itemID: "005", size: "M", quantity: 1, wantErr: ErrInsufficientStock, wantQty: 3},
+		{itemID: "005", size: "L", quantity: 2, wantErr: ErrInsufficientStock}, // 1 unit reserved
+		{itemID: "005", size: "XL", quantity: 1, wantErr: ErrItemNotFound},
+		{itemID: "001", size: "OS", quantity: 0, wantErr: ErrInvalidQuantity},
+		{itemID: "001", size: "OS", quantity: 1, wantQty: 1},
+	}
+	for _, test := range tests {
+		err := cart.AddItem(items[test.itemID], CartItem{Size: test.size, Price: USD(1)}, test.quantity)
+		if (err == nil && test.wantErr != "") || (err != nil && err.Error() != test.wantErr) {
+			t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+		}
+		qty := 0
+		if line := cart.Items[NewSizeID(test.itemID, test.size)]; line != nil {
+			qty = line.Quantity
+		}
+		if qty != test.wantQty {
+			t.Errorf("FAIL - quantity: %d; want: %d", qty, test.wantQty)
+		}
+	}
+
+	cart.Recalculate(items)
+	if cart.TotalItems != 4 || cart.Subtotal.Cmp(USD(9880)) != 0 {
+		t.Errorf("FAIL - totals: %d items, %v; want: 4 items, %v", cart.TotalItems, cart.Subtotal, USD(9880))
+	}
+	if cart.CartWeightOzs != 58 || cart.CartWeightLbs != 3.625 {
+		t.Errorf("FAIL - weight: %v ozs, %v lbs; want: 58 ozs, 3.625 lbs", cart.CartWeightOzs, cart.CartWeightLbs)
+	}
+}
+
+func TestShoppingCartRecalculate(t *testing.T) {
+	items := newTestCartItems()
+	cart := &ShoppingCart{UserID: "user001"}
+	cart.AddItem(items["005"], CartItem{Size: "M"}, 2)
+	cart.AddItem(items["005"], CartItem{Size: "L"}, 1)
+	cart.AddItem(items["001"], CartItem{Size: "OS"}, 1)
+
+	// price of 005 raised; stock of 005-M sold; 001 removed from store
+	items["005"].Price = USD(2495)
+	items["005"].UnitsAvailable["M"] = 1
+	delete(items, "001")
+	cart.Recalculate(items)
+
+	var tests = []struct {
+		sizeID       string
+		wantPrice    bool
+		wantStock    bool
+		wantSubtotal Money
+	}{
+		{sizeID: "005-M", wantPrice: true, wantStock: true, wantSubtotal: USD(4990)},
+		{sizeID: "005-L", wantPrice: true, wantStock: false, wantSubtotal: USD(2495)},
+		{sizeID: "001-OS", wantPrice: false, wantStock: true, wantSubtotal: USD(2995)},
+	}
+	for _, test := range tests {
+		line := cart.Items[test.sizeID]
+		if line.PriceChanged != test.wantPrice || line.InsufficientStock != test.wantStock {
+			t.Errorf("FAIL - %s flags: %v, %v; want: %v, %v", test.sizeID, line.PriceChanged, line.InsufficientStock, test.wantPrice, test.wantStock)
+		}
+		if line.ItemSubtotal.Cmp(test.wantSubtotal) != 0 {
+			t.Errorf("FAIL - %s subtotal: %v; want: %v", test.sizeID, line.ItemSubtotal, test.wantSubtotal)
+		}
+	}
+	if flagged := cart.Flagged(); len(flagged) != 3 {
+		t.Errorf("FAIL - flagged: %v; want: 3 items", flagged)
+	}
+
+	// set quantity & remove
+	if err := cart.SetQuantity(items["005"], "M", 1); err != nil {
+		t.Errorf("FAIL: %v; want: nil", err)
+	}
+	if err := cart.SetQuantity(items["005"], "XL", 1); err == nil || err.Error() != ErrCartItemNotFound {
+		t.Errorf("FAIL: %v; want: %v", err, ErrCartItemNotFound)
+	}
+	if err := cart.SetQuantity(items["005"], "L", 0); err != nil || cart.Items["005-L"] != nil {
+		t.Errorf("FAIL: %v; want: item removed", err)
+	}
+	if err := cart.RemoveItem("001-OS"); err != nil {
+		t.Errorf("FAIL: %v; want: nil", err)
+	}
+	cart.Recalculate(items)
+	if cart.TotalItems != 1 || cart.Subtotal.Cmp(USD(2495)) != 0 {
+		t.Errorf("FAIL - totals: %d items, %v; want: 1 item, %v", cart.TotalItems, cart.Subtotal, USD(2495))
+	}
+
+	cart.Clear()
+	if len(cart.Items) != 0 || cart.TotalItems != 0 || !cart.Subtotal.IsZero() || cart.CartWeightOzs != 0 {
+		t.Errorf("FAIL - cart not cleared: %v", cart)
+	}
+}
+
+func TestShoppingCartMerge(t *testing.T) {
+	var tests = []struct {
+		rules     MergeRules
+		wantM     int   // quantity of 005-M
+		wantL     int   // quantity of 005-L
+		wantAdded Money // added price of 005-M
+	}{
+		{rules: DefaultMergeRules, wantM: 3, wantL: 1, wantAdded: USD(2495)},
+		{rules: MergeRules{Quantity: MergeQuantitySum}, wantM: 4, wantL: 1, wantAdded: USD(2295)},
+		{rules: MergeRules{Quantity: MergeQuantityMax, CapAtStock: true}, wantM: 3, wantL: 1, wantAdded: USD(2295)},
+		{rules: MergeRules{Quantity: MergeQuantityKeep, NewestPrice: true}, wantM: 1, wantL: 1, wantAdded: USD(2495)},
+	}
+	for _, test := range tests {
+		items := newTestCartItems()
+		cart := &ShoppingCart{UserID: "user001"}
+		cart.AddItem(items["005"], CartItem{Size: "M", AddedAt: 100}, 1)
+		guest := &ShoppingCart{UserID: "guest-01"}
+		guest.AddItem(items["005"], CartItem{Size: "M", AddedAt: 200}, 3)
+		guest.AddItem(items["005"], CartItem{Size: "L", AddedAt: 200}, 1)
+		guest.Items["005-M"].AddedPrice = USD(2495)
+
+		cart.Merge(guest, items, test.rules)
+		if cart.Items["005-M"].Quantity != test.wantM || cart.Items["005-L"].Quantity != test.wantL {
+			t.Errorf("FAIL - quantities: %d, %d; want: %d, %d", cart.Items["005-M"].Quantity, cart.Items["005-L"].Quantity, test.wantM, test.wantL)
+		}
+		if cart.Items["005-M"].AddedPrice.Cmp(test.wantAdded) != 0 {
+			t.Errorf("FAIL - added price: %v; want: %v", cart.Items["005-M"].AddedPrice, test.wantAdded)
+		}
+		if cart.Items["005-L"].UserID != "user001" {
+			t.Errorf("FAIL - user ID: %s; want: user001", cart.Items["005-L"].UserID)
+		}
+	}
+}
+
+func TestGuestID(t *testing.T) {
+	id, err := NewGuestID()
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	other, _ := NewGuestID()
+	if !IsGuestID(id) || id == other || IsGuestID("user001") {
+		t.Errorf("FAIL - guest IDs: %s, %s", id, other)
+	}
+}
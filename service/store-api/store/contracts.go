@@ -0,0 +1,156 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Contract statuses
+const (
+	ContractStatusPending = "PENDING" // rendered; awaiting the buyer's signature
+	ContractStatusSigned  = "SIGNED"
+)
+
+// ErrContractNotFound is returned when a contract does not exist.
+const ErrContractNotFound = "ERR_CONTRACT_NOT_FOUND"
+
+// ErrContractRequired is returned when an order is paid before the contract of each digital
+// item is signed.
+const ErrContractRequired = "ERR_CONTRACT_REQUIRED"
+
+// ErrContractSigned is returned when a contract that was already signed is signed again.
+const ErrContractSigned = "ERR_CONTRACT_SIGNED"
+
+// ErrContractChanged is returned when the content hash accepted by the buyer does not match
+// the contract's content hash.
+const ErrContractChanged = "ERR_CONTRACT_CHANGED"
+
+// ErrInvalidSignature is returned when the buyer's typed signature does not match the buyer
+// name of the contract.
+const ErrInvalidSignature = "ERR_INVALID_SIGNATURE"
+
+// contractDateLayout is the layout of the agreement date of contracts.
+const contractDateLayout = "January 2, 2006"
+
+// Contract represents the lease agreement of a digital item in an order. Contracts are
+// rendered from the license tier's template and signed by the buyer before payment.
+type Contract struct {
+	UserID      string `json:"user_id"`
+	ContractID  string `json:"contract_id"` // <orderID>.<sizeID>.<unix timestamp> (ex: 'u01-3.007-BASIC.1622376000')
+	OrderID     string `json:"order_id"`
+	ItemID      string `json:"item_id"`
+	SizeID      string `json:"size_id"`
+	Tier        string `json:"tier"`
+	BuyerName   string `json:"buyer_name"`
+	BeatTitle   string `json:"beat_title"`
+	Rights      string `json:"rights"` // license terms granted to the buyer
+	Price       Money  `json:"price"`
+	Date        string `json:"date"`         // agreement date (ex: 'May 30, 2021')
+	ContentHash string `json:"content_hash"` // hex SHA-256 of the rendered agreement
+	Html        string `json:"html"`         // rendered agreement; removed once the signed agreement is stored
+	Status      string `json:"status"`
+	Signature   string `json:"signature"` // buyer's typed signature
+	SignedAt    int64  `json:"signed_at"` // unix timestamp (s)
+	SignerIP    string `json:"signer_ip"`
+	DocumentKey string `json:"document_key"` // S3 key of the signed agreement
+}
+
+// ContractRef links a signed Contract to the ordered item it licenses.
+type ContractRef struct {
+	ContractID  string `json:"contract_id"`
+	SizeID      string `json:"size_id"`
+	DocumentKey string `json:"document_key"`
+}
+
+// NewContractID returns the ID of a new contract for the ordered item size.
+func NewContractID(orderID, sizeID string, now time.Time) string {
+	return fmt.Sprintf("%s.%s.%d", orderID, sizeID, now.Unix())
+}
+
+// NewContract returns a pending Contract licensing the order's digital item size to the buyer.
+// The agreement is rendered and hashed by the caller (see SetContent). Returns
+// ErrItemNotFound if the item size is not a licensed digital item of the order.
+func NewContract(order *Order, item *StoreItem, sizeID, buyerName string, now time.Time) (*Contract, error) {
+	for _, line := range order.DigitalItems() {
+		if line.SizeID != sizeID {
+			continue
+		}
+		tier, ok := item.Licenses[line.Size]
+		if !ok || line.ItemID != item.ItemID {
+			break
+		}
+		return &Contract{
+			UserID:     order.UserID,
+			ContractID: NewContractID(order.OrderID, sizeID, now),
+			OrderID:    order.OrderID,
+			ItemID:     item.ItemID,
+			SizeID:     sizeID,
+			Tier:       line.Size,
+			BuyerName:  strings.TrimSpace(buyerName),
+			BeatTitle:  item.Name,
+			Rights:     tier.Terms,
+			Price:      line.Price,
+			Date:       now.UTC().Format(contractDateLayout),
+			Status:     ContractStatusPending,
+		}, nil
+	}
+	return &Contract{}, fmt.Errorf(ErrItemNotFound)
+}
+
+// ContentHash returns the hex SHA-256 hash of the content.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetContent sets the rendered agreement and its content hash.
+func (c *Contract) SetContent(html string) {
+	c.Html = html
+	c.ContentHash = ContentHash(html)
+}
+
+// Sign records the buyer's acceptance of the agreement with the given content hash. The typed
+// signature must match the buyer name, ignoring case and extra spaces.
+func (c *Contract) Sign(signature, contentHash, ip string, now time.Time) error {
+	if c.Status != ContractStatusPending {
+		return fmt.Errorf(ErrContractSigned)
+	}
+	if contentHash != c.ContentHash {
+		return fmt.Errorf(ErrContractChanged)
+	}
+	typed := strings.Join(strings.Fields(signature), " ")
+	if typed == "" || !strings.EqualFold(typed, strings.Join(strings.Fields(c.BuyerName), " ")) {
+		return fmt.Errorf(ErrInvalidSignature)
+	}
+	c.Status = ContractStatusSigned
+	c.Signature = typed
+	c.SignedAt = now.Unix()
+	c.SignerIP = ip
+	return nil
+}
+
+// Ref returns the ContractRef of the contract.
+func (c *Contract) Ref() ContractRef {
+	return ContractRef{ContractID: c.ContractID, SizeID: c.SizeID, DocumentKey: c.DocumentKey}
+}
+
+// UnsignedItems returns the sorted size IDs of the order's digital items without a signed
+// contract.
+func (o *Order) UnsignedItems() []string {
+	signed := make(map[string]bool)
+	for _, ref := range o.Contracts {
+		signed[ref.SizeID] = true
+	}
+	unsigned := []string{}
+	for _, item := range o.DigitalItems() {
+		if !signed[item.SizeID] {
+			unsigned = append(unsigned, item.SizeID)
+		}
+	}
+	sort.Strings(unsigned)
+	return unsigned
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLeaseOrder() *Order {
+	return &Order{UserID: "u01", OrderID: "u01-1", Items: []*CartItem{
+		{ItemID: "007", SizeID: "007-BASIC", Name: "Midnight", Size: LicenseBasic, Price: USD(2999), Digital: true},
+		{ItemID: "005", SizeID: "005-M", Size: "M"},
+	}}
+}
+
+func TestNewContract(t *testing.T) {
+	beat := newTestBeat()
+	beat.Licenses[LicenseBasic] = LicenseTier{Tier: LicenseBasic, Price: USD(2999), Terms: "Non-exclusive; 5,000 streams"}
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+	order := newTestLeaseOrder()
+
+	var tests = []struct {
+		sizeID  string
+		wantErr string
+	}{
+		{sizeID: "007-BASIC"},
+		{sizeID: "007-PREMIUM", wantErr: ErrItemNotFound}, // not ordered
+		{sizeID: "005-M", wantErr: ErrItemNotFound},       // physical item
+	}
+	for _, test := range tests {
+		c, err := NewContract(order, beat, test.sizeID, " Jane Doe ", now)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || c.ContractID != "u01-1.007-BASIC.1622376000" || c.BuyerName != "Jane Doe" ||
+			c.Rights != "Non-exclusive; 5,000 streams" || c.Date != "May 30, 2021" || c.Status != ContractStatusPending {
+			t.Errorf("FAIL: %v, %v", err, c)
+		}
+	}
+}
+
+func TestContractSign(t *testing.T) {
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+	html := "<html><body>Lease</body></html>"
+
+	var tests = []struct {
+		signature string
+		hash      string
+		wantErr   string
+	}{
+		{signature: "Jane Doe", hash: "0000", wantErr: ErrContractChanged},
+		{signature: "John Doe", hash: ContentHash(html), wantErr: ErrInvalidSignature},
+		{signature: " ", hash: ContentHash(html), wantErr: ErrInvalidSignature},
+		{signature: " jane  DOE", hash: ContentHash(html)},
+		{signature: "Jane Doe", hash: ContentHash(html), wantErr: ErrContractSigned},
+	}
+	c := &Contract{ContractID: "u01-1.007-BASIC.1622376000", BuyerName: "Jane Doe", Status: ContractStatusPending}
+	c.SetContent(html)
+	for _, test := range tests {
+		err := c.Sign(test.signature, test.hash, "203.0.113.7", now)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || c.Status != ContractStatusSigned || c.Signature != "jane DOE" || c.SignedAt != now.Unix() || c.SignerIP != "203.0.113.7" {
+			t.Errorf("FAIL: %v, %v", err, c)
+		}
+	}
+}
+
+func TestOrderUnsignedItems(t *testing.T) {
+	order := newTestLeaseOrder()
+	order.Items = append(order.Items, &CartItem{ItemID: "008", SizeID: "008-PREMIUM", Size: LicensePremium, Digital: true})
+
+	var tests = []struct {
+		contracts []ContractRef
+		want      int
+	}{
+		{contracts: nil, want: 2},
+		{contracts: []ContractRef{{SizeID: "008-PREMIUM"}}, want: 1},
+		{contracts: []ContractRef{{SizeID: "008-PREMIUM"}, {SizeID: "007-BASIC"}}, want: 0},
+	}
+	for _, test := range tests {
+		order.Contracts = test.contracts
+		if got := order.UnsignedItems(); len(got) != test.want {
+			t.Errorf("FAIL: %v; want: %d", got, test.want)
+		}
+	}
+}
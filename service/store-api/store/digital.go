@@ -0,0 +1,212 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Product types
+const (
+	ProductTypePhysical = "PHYSICAL" // default; sold in sizes & shipped
+	ProductTypeDigital  = "DIGITAL"  // sold in license tiers & downloaded
+)
+
+// License tiers
+const (
+	LicenseBasic   = "BASIC"
+	LicensePremium = "PREMIUM"
+)
+
+// Download limits of license tiers that do not set their own.
+const (
+	DefaultMaxDownloads = 5
+	DefaultDownloadDays = 30
+)
+
+// grantRetention is the duration download grant records are retained after expiring.
+const grantRetention = 90 * 24 * time.Hour
+
+// ErrDownloadNotFound is returned when a download grant does not exist.
+const ErrDownloadNotFound = "ERR_DOWNLOAD_NOT_FOUND"
+
+// ErrDownloadLimitReached is returned when a download grant has no downloads remaining.
+const ErrDownloadLimitReached = "ERR_DOWNLOAD_LIMIT_REACHED"
+
+// ErrDownloadExpired is returned when a download grant has expired.
+const ErrDownloadExpired = "ERR_DOWNLOAD_EXPIRED"
+
+// ErrExclusiveSold is returned when the exclusive license of a digital item was sold to
+// another order.
+const ErrExclusiveSold = "ERR_EXCLUSIVE_SOLD"
+
+// LicenseTier represents a license sold for a digital StoreItem. The tier is used as the
+// item's size in carts and orders (ex: '007-BASIC').
+type LicenseTier struct {
+	Tier         string `json:"tier"`
+	Name         string `json:"name"`
+	Price        Money  `json:"price"`
+	Terms        string `json:"terms"`         // license terms displayed at checkout
+	MaxDownloads int    `json:"max_downloads"` // 0: DefaultMaxDownloads
+	DownloadDays int    `json:"download_days"` // days the master is downloadable; 0: DefaultDownloadDays
+	Exclusive    bool   `json:"exclusive"`     // sold once; removes the item from the store
+}
+
+// DownloadGrant represents a customer's right to download the master file of a purchased
+// digital item. Each grant allows a limited number of downloads until it expires.
+type DownloadGrant struct {
+	UserID             string `json:"user_id"`
+	GrantID            string `json:"grant_id"` // <orderID>.<random hex> (see NewGrantID)
+	OrderID            string `json:"order_id"`
+	ItemID             string `json:"item_id"`
+	Name               string `json:"name"`
+	Tier               string `json:"tier"`
+	Terms              string `json:"terms"`
+	AssetKey           string `json:"asset_key"` // S3 key of the master file
+	DownloadsRemaining int    `json:"downloads_remaining"`
+	ExpiresAt          int64  `json:"expires_at"` // unix timestamp (s)
+	TTL                int64  `json:"ttl"`        // unix timestamp (s) the record is deleted
+}
+
+// IsDigital returns true if the item is a digital product.
+func (s *StoreItem) IsDigital() bool {
+	return s.ProductType == ProductTypeDigital
+}
+
+// IsExclusive returns true if the license tier of the digital item is exclusive.
+func (s *StoreItem) IsExclusive(tier string) bool {
+	return s.IsDigital() && s.Licenses[tier].Exclusive
+}
+
+// PriceOf returns the unit price of the item size, or of the license tier for digital items.
+func (s *StoreItem) PriceOf(size string) Money {
+	if s.IsDigital() {
+		return s.Licenses[size].Price
+	}
+	return s.Price
+}
+
+// PhysicalItems returns the order's items that are shipped.
+func (o *Order) PhysicalItems() []*CartItem {
+	items := []*CartItem{}
+	for _, item := range o.Items {
+		if !item.Digital {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// DigitalItems returns the order's items that are downloaded.
+func (o *Order) DigitalItems() []*CartItem {
+	items := []*CartItem{}
+	for _, item := range o.Items {
+		if item.Digital {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// RequiresShipping returns true if the order contains physical items.
+func (o *Order) RequiresShipping() bool {
+	return len(o.PhysicalItems()) > 0
+}
+
+// exclusiveRefunded returns true if the order's item was refunded because an exclusive license
+// was sold to another order.
+func (o *Order) exclusiveRefunded(sizeID string) bool {
+	for _, id := range o.ExclusiveRefunds {
+		if id == sizeID {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantIDPrefix returns the prefix of the grant IDs of the order's download grants.
+func GrantIDPrefix(orderID string) string {
+	return orderID + "."
+}
+
+// NewGrantID returns a new random grant ID for a download grant of the order. Downloads are
+// authorized by grant ID, so grant IDs are not derived from the order's items.
+func NewGrantID(orderID string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return GrantIDPrefix(orderID) + hex.EncodeToString(b), nil
+}
+
+// NewDownloadGrants returns a DownloadGrant for each digital item of the order, using the
+// asset and license tier of its StoreItem, keyed by item ID. Items without a StoreItem or
+// license tier, exclusive licenses sold to another order, and items refunded because an
+// exclusive license was sold to another order (see Order.ExclusiveRefunds) are skipped.
+func NewDownloadGrants(order *Order, items map[string]*StoreItem, now time.Time) ([]*DownloadGrant, error) {
+	grants := []*DownloadGrant{}
+	for _, line := range order.DigitalItems() {
+		item := items[line.ItemID]
+		if item == nil {
+			continue
+		}
+		tier, ok := item.Licenses[line.Size]
+		if !ok || (tier.Exclusive && item.ExclusiveOrderID != order.OrderID) || order.exclusiveRefunded(line.SizeID) {
+			continue
+		}
+		max := tier.MaxDownloads
+		if max <= 0 {
+			max = DefaultMaxDownloads
+		}
+		days := tier.DownloadDays
+		if days <= 0 {
+			days = DefaultDownloadDays
+		}
+		expires := now.AddDate(0, 0, days)
+		grantID, err := NewGrantID(order.OrderID)
+		if err != nil {
+			return grants, err
+		}
+		grants = append(grants, &DownloadGrant{
+			UserID:             order.UserID,
+			GrantID:            grantID,
+			OrderID:            order.OrderID,
+			ItemID:             line.ItemID,
+			Name:               line.Name,
+			Tier:               line.Size,
+			Terms:              tier.Terms,
+			AssetKey:           item.MasterKey,
+			DownloadsRemaining: max,
+			ExpiresAt:          expires.Unix(),
+			TTL:                expires.Add(grantRetention).Unix(),
+		})
+	}
+	return grants, nil
+}
+
+// UnavailableLicenses returns the sorted size IDs of the cart's digital items whose license
+// tier is no longer sold (see ShoppingCart.Recalculate).
+func (c *ShoppingCart) UnavailableLicenses() []string {
+	unavailable := []string{}
+	for sizeID, line := range c.Items {
+		if line.Digital && line.InsufficientStock {
+			unavailable = append(unavailable, sizeID)
+		}
+	}
+	sort.Strings(unavailable)
+	return unavailable
+}
+
+// Available returns ErrDownloadExpired if the grant expired before now, or
+// ErrDownloadLimitReached if no downloads remain.
+func (g *DownloadGrant) Available(now time.Time) error {
+	if now.Unix() >= g.ExpiresAt {
+		return fmt.Errorf(ErrDownloadExpired)
+	}
+	if g.DownloadsRemaining <= 0 {
+		return fmt.Errorf(ErrDownloadLimitReached)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBeat() *StoreItem {
+	return &StoreItem{
+		ItemID:      "007",
+		Name:        "Midnight",
+		Subcategory: "beats",
+		ProductType: ProductTypeDigital,
+		MasterKey:   "masters/007.wav",
+		Licenses: map[string]LicenseTier{
+			LicenseBasic:   {Tier: LicenseBasic, Price: USD(2999), MaxDownloads: 3},
+			LicensePremium: {Tier: LicensePremium, Price: USD(9999), DownloadDays: 7},
+		},
+	}
+}
+
+func TestShoppingCartAddDigitalItem(t *testing.T) {
+	beat := newTestBeat()
+	items := newTestCartItems()
+	items[beat.ItemID] = beat
+
+	var tests = []struct {
+		size      string
+		quantity  int
+		wantErr   string
+		wantPrice Money
+	}{
+		{size: LicenseBasic, quantity: 1, wantPrice: USD(2999)},
+		{size: LicensePremium, quantity: 1, wantPrice: USD(9999)},
+		{size: LicensePremium, quantity: 1, wantErr: ErrInsufficientStock}, // one license per order
+		{size: "EXCLUSIVE", quantity: 1, wantErr: ErrItemNotFound},
+	}
+	cart := &ShoppingCart{UserID: "user001"}
+	for _, test := range tests {
+		err := cart.AddItem(beat, CartItem{Size: test.size}, test.quantity)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		line := cart.Items[NewSizeID(beat.ItemID, test.size)]
+		if err != nil || !line.Digital || line.Price.Cmp(test.wantPrice) != 0 {
+			t.Errorf("FAIL: %v, %v; want: %v", err, line, test.wantPrice)
+		}
+	}
+
+	cart.AddItem(items["005"], CartItem{Size: "M"}, 1)
+	cart.Recalculate(items)
+	if cart.Subtotal.Cmp(USD(2999+9999+2295)) != 0 {
+		t.Errorf("FAIL: %v; want: %v", cart.Subtotal, USD(2999+9999+2295))
+	}
+	if cart.CartWeightOzs != items["005"].UnitWeightOzs {
+		t.Errorf("FAIL: %v; want: %v", cart.CartWeightOzs, items["005"].UnitWeightOzs)
+	}
+}
+
+func TestOrderRequiresShipping(t *testing.T) {
+	digital := &CartItem{SizeID: "007-BASIC", Digital: true}
+	physical := &CartItem{SizeID: "005-M"}
+
+	var tests = []struct {
+		items []*CartItem
+		want  bool
+	}{
+		{items: []*CartItem{digital}, want: false},
+		{items: []*CartItem{digital, physical}, want: true},
+		{items: []*CartItem{physical}, want: true},
+	}
+	for _, test := range tests {
+		order := &Order{Items: test.items}
+		if got := order.RequiresShipping(); got != test.want {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+	}
+
+	order := &Order{OrderID: "u01-1", Items: []*CartItem{digital, physical}, TtlMs: 1000}
+	holds := NewHolds(order, time.Now())
+	if len(holds) != 1 || holds[0].SizeID != "005-M" {
+		t.Errorf("FAIL: %v; want: [005-M]", holds)
+	}
+}
+
+func TestNewDownloadGrants(t *testing.T) {
+	beat := newTestBeat()
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+	order := &Order{UserID: "u01", OrderID: "u01-1", Items: []*CartItem{
+		{ItemID: "007", SizeID: "007-BASIC", Size: LicenseBasic, Digital: true},
+		{ItemID: "007", SizeID: "007-PREMIUM", Size: LicensePremium, Digital: true},
+		{ItemID: "009", SizeID: "009-BASIC", Size: LicenseBasic, Digital: true}, // no StoreItem
+		{ItemID: "005", SizeID: "005-M", Size: "M"},
+	}}
+
+	grants, err := NewDownloadGrants(order, map[string]*StoreItem{"007": beat}, now)
+	if err != nil || len(grants) != 2 {
+		t.Fatalf("FAIL: %v, %d grants; want: 2", err, len(grants))
+	}
+	var tests = []struct {
+		grant       *DownloadGrant
+		wantTier    string
+		wantMax     int
+		wantExpires time.Time
+	}{
+		{grant: grants[0], wantTier: LicenseBasic, wantMax: 3, wantExpires: now.AddDate(0, 0, DefaultDownloadDays)},
+		{grant: grants[1], wantTier: LicensePremium, wantMax: DefaultMaxDownloads, wantExpires: now.AddDate(0, 0, 7)},
+	}
+	for _, test := range tests {
+		g := test.grant
+		if g.Tier != test.wantTier || g.DownloadsRemaining != test.wantMax || g.ExpiresAt != test.wantExpires.Unix() || g.AssetKey != beat.MasterKey {
+			t.Errorf("FAIL: %v; want: %s, %d, %v", g, test.wantTier, test.wantMax, test.wantExpires)
+		}
+	}
+
+	// grant IDs are random, prefixed with the order ID
+	if grants[0].GrantID == grants[1].GrantID || !strings.HasPrefix(grants[0].GrantID, GrantIDPrefix(order.OrderID)) || len(grants[0].GrantID) != len("u01-1.")+32 {
+		t.Errorf("FAIL: %s, %s", grants[0].GrantID, grants[1].GrantID)
+	}
+
+	// items refunded because the exclusive license was sold to another order
+	order.ExclusiveRefunds = []string{"007-BASIC"}
+	grants, err = NewDownloadGrants(order, map[string]*StoreItem{"007": beat}, now)
+	if err != nil || len(grants) != 1 || grants[0].Tier != LicensePremium {
+		t.Errorf("FAIL: %v, %v; want: 1 %s grant", err, grants, LicensePremium)
+	}
+}
+
+func TestDownloadGrantAvailable(t *testing.T) {
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		grant *DownloadGrant
+		want  string
+	}{
+		{grant: &DownloadGrant{DownloadsRemaining: 1, ExpiresAt: now.Add(time.Hour).Unix()}, want: ""},
+		{grant: &DownloadGrant{DownloadsRemaining: 0, ExpiresAt: now.Add(time.Hour).Unix()}, want: ErrDownloadLimitReached},
+		{grant: &DownloadGrant{DownloadsRemaining: 1, ExpiresAt: now.Unix()}, want: ErrDownloadExpired},
+	}
+	for _, test := range tests {
+		err := test.grant.Available(now)
+		if (err == nil && test.want != "") || (err != nil && err.Error() != test.want) {
+			t.Errorf("FAIL: %v; want: %s", err, test.want)
+		}
+	}
+}
+
+func TestExclusiveLicense(t *testing.T) {
+	beat := newTestBeat()
+	beat.Licenses[LicensePremium] = LicenseTier{Tier: LicensePremium, Price: USD(9999), Exclusive: true}
+	if !beat.IsExclusive(LicensePremium) || beat.IsExclusive(LicenseBasic) {
+		t.Errorf("FAIL: exclusive tiers: %v", beat.Licenses)
+	}
+
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+	lines := []*CartItem{
+		{ItemID: "007", SizeID: "007-PREMIUM", Size: LicensePremium, Digital: true},
+		{ItemID: "007", SizeID: "007-BASIC", Size: LicenseBasic, Digital: true},
+	}
+	winner := &Order{UserID: "u01", OrderID: "u01-1", Items: lines}
+	loser := &Order{UserID: "u02", OrderID: "u02-1", Items: lines}
+	beat.SoldExclusive, beat.ExclusiveOrderID = true, winner.OrderID
+
+	var tests = []struct {
+		order *Order
+		want  int // grants
+	}{
+		{order: winner, want: 2},
+		{order: loser, want: 1}, // basic lease paid before the exclusive sold is kept
+	}
+	for _, test := range tests {
+		grants, _ := NewDownloadGrants(test.order, map[string]*StoreItem{"007": beat}, now)
+		if len(grants) != test.want {
+			t.Errorf("FAIL: %d grants; want: %d", len(grants), test.want)
+		}
+	}
+
+	// future sales are revoked
+	cart := &ShoppingCart{UserID: "u03"}
+	if err := cart.AddItem(beat, CartItem{Size: LicenseBasic}, 1); err == nil || err.Error() != ErrInsufficientStock {
+		t.Errorf("FAIL: %v; want: %s", err, ErrInsufficientStock)
+	}
+	cart.Items = map[string]*CartItem{"007-BASIC": {ItemID: "007", SizeID: "007-BASIC", Size: LicenseBasic, Quantity: 1}}
+	cart.Recalculate(map[string]*StoreItem{"007": beat})
+	if got := cart.UnavailableLicenses(); len(got) != 1 || got[0] != "007-BASIC" {
+		t.Errorf("FAIL: %v; want: [007-BASIC]", got)
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"time"
+)
+
+// Inventory hold statuses
+const (
+	HoldStatusActive    = "ACTIVE"    // units reserved for the order
+	HoldStatusConverted = "CONVERTED" // units sold; on-hand count decremented
+	HoldStatusReleased  = "RELEASED"  // units returned to available-to-sell count
+)
+
+// ErrInsufficientStock is returned when an item's available-to-sell count is less than the
+// quantity to reserve.
+const ErrInsufficientStock = "ERR_INSUFFICIENT_STOCK"
+
+// ErrHoldNotActive is returned when an inventory hold has already been converted or released.
+const ErrHoldNotActive = "ERR_HOLD_NOT_ACTIVE"
+
+// InventoryHold represents units of an item size reserved for an order during checkout.
+// Reserved units are counted in StoreItem.UnitsReserved until the hold is converted to a sale
+// or released.
+type InventoryHold struct {
+	OrderID     string `json:"order_id"`
+	SizeID      string `json:"size_id"` // SKU + size (ex: 005-L)
+	UserID      string `json:"user_id"`
+	Subcategory string `json:"sub_category"`
+	ItemID      string `json:"item_id"`
+	Size        string `json:"size"`
+	Quantity    int    `json:"quantity"`
+	HoldStatus  string `json:"hold_status"`
+	ExpiresAt   int64  `json:"expires_at"` // unix timestamp (s)
+	TTL         int64  `json:"ttl"`        // unix timestamp (s) - record expiration
+}
+
+// holdRetention is the duration hold records are retained after expiring.
+const holdRetention = 7 * 24 * time.Hour
+
+// NewHolds returns an active InventoryHold for each physical item of the order, expiring after
+// the order's TtlMs. Digital items are not held.
+func NewHolds(order *Order, now time.Time) []*InventoryHold {
+	expires := now.Add(time.Duration(order.TtlMs) * time.Millisecond)
+	holds := []*InventoryHold{}
+	for _, item := range order.PhysicalItems() {
+		holds = append(holds, &InventoryHold{
+			OrderID:     order.OrderID,
+			SizeID:      item.SizeID,
+			UserID:      order.UserID,
+			Subcategory: item.Subcategory,
+			ItemID:      item.ItemID,
+			Size:        item.Size,
+			Quantity:    item.Quantity,
+			HoldStatus:  HoldStatusActive,
+			ExpiresAt:   expires.Unix(),
+			TTL:         expires.Add(holdRetention).Unix(),
+		})
+	}
+	return holds
+}
+
+// Expired returns true if the hold is active and its expiration time has passed.
+func (h *InventoryHold) Expired(now time.Time) bool {
+	return h.HoldStatus == HoldStatusActive && now.Unix() >= h.ExpiresAt
+}
+
+// AvailableToSell returns the units of the given size that are on hand and not reserved by
+// active inventory holds. Digital items have 1 unit of each license tier available until the
+// item's exclusive license is sold.
+func (s *StoreItem) AvailableToSell(size string) int {
+	if s.IsDigital() {
+		if _, ok := s.Licenses[size]; ok && !s.SoldExclusive {
+			return 1 // one license per order
+		}
+		return 0
+	}
+	ats := s.UnitsAvailable[size] - s.UnitsReserved[size]
+	if ats < 0 {
+		return 0
+	}
+	return ats
+}
+
+// AvailableToSellCounts returns the available-to-sell units of each size.
+func (s *StoreItem) AvailableToSellCounts() map[string]int {
+	counts := make(map[string]int)
+	for size := range s.Licenses {
+		counts[size] = s.AvailableToSell(size)
+	}
+	for size := range s.UnitsAvailable {
+		counts[size] = s.AvailableToSell(size)
+	}
+	return counts
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewHolds(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	order := &Order{
+		OrderID: "user001-1",
+		UserID:  "user001",
+		TtlMs:   600000,
+		Items: []*CartItem{
+			{Subcategory: "shirts", ItemID: "005", SizeID: "005-L", Size: "L", Quantity: 2},
+			{Subcategory: "posters", ItemID: "003", SizeID: "003-OS", Size: "OS", Quantity: 1},
+		},
+	}
+
+	holds := NewHolds(order, now)
+	if len(holds) != 2 {
+		t.Fatalf("FAIL: %d holds; want: 2", len(holds))
+	}
+	var tests = []struct {
+		at   time.Time
+		want bool
+	}{
+		{at: now, want: false},
+		{at: now.Add(9 * time.Minute), want: false},
+		{at: now.Add(10 * time.Minute), want: true},
+		{at: now.Add(time.Hour), want: true},
+	}
+	for _, test := range tests {
+		if got := holds[0].Expired(test.at); got != test.want {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+	}
+
+	h := holds[0]
+	if h.OrderID != "user001-1" || h.SizeID != "005-L" || h.Quantity != 2 || h.HoldStatus != HoldStatusActive {
+		t.Errorf("FAIL: %+v", h)
+	}
+	if h.TTL <= h.ExpiresAt {
+		t.Errorf("FAIL: ttl %d; want > %d", h.TTL, h.ExpiresAt)
+	}
+
+	// settled holds do not expire
+	h.HoldStatus = HoldStatusConverted
+	if h.Expired(now.Add(time.Hour)) {
+		t.Errorf("FAIL: %v; want: %v", true, false)
+	}
+}
+
+func TestAvailableToSell(t *testing.T) {
+	item := &StoreItem{
+		UnitsAvailable: map[string]int{"S": 10, "M": 2, "L": 0},
+		UnitsReserved:  map[string]int{"S": 4, "M": 3},
+	}
+	var tests = []struct {
+		size string
+		want int
+	}{
+		{size: "S", want: 6},
+		{size: "M", want: 0}, // over-reserved
+		{size: "L", want: 0},
+		{size: "XL", want: 0}, // not stocked
+	}
+	for _, test := range tests {
+		if got := item.AvailableToSell(test.size); got != test.want {
+			t.Errorf("FAIL: %s: %d; want: %d", test.size, got, test.want)
+		}
+	}
+	counts := item.AvailableToSellCounts()
+	if len(counts) != 3 || counts["S"] != 6 {
+		t.Errorf("FAIL: %v", counts)
+	}
+}
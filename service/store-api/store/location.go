@@ -0,0 +1,211 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Location types
+const (
+	LocationWarehouse = "WAREHOUSE"
+	LocationStudio    = "STUDIO"
+)
+
+// ErrInvalidLocation is returned when a location's fields are missing or invalid.
+const ErrInvalidLocation = "ERR_INVALID_LOCATION"
+
+// ErrLocationNotFound is returned when a location does not exist.
+const ErrLocationNotFound = "ERR_LOCATION_NOT_FOUND"
+
+// ErrInsufficientLocationStock is returned when the active locations do not stock enough units
+// to ship an order's items.
+const ErrInsufficientLocationStock = "ERR_INSUFFICIENT_LOCATION_STOCK"
+
+// Location is a warehouse or studio that orders are shipped from. The location's Inventory
+// contains the units in stock of each size it stocks, keyed by SizeID. Returns of orders
+// shipped from the location are shipped to its ReturnAddress, or to its Address if no return
+// address is set.
+type Location struct {
+	LocationID    string         `json:"location_id"`
+	Name          string         `json:"name"`
+	LocationType  string         `json:"location_type"`
+	Address       Address        `json:"address"`
+	ReturnAddress Address        `json:"return_address"`
+	Inventory     map[string]int `json:"inventory"` // size ID: units in stock
+	Priority      int            `json:"priority"`  // locations with the lowest priority are preferred between equally near locations
+	Active        bool           `json:"active"`
+}
+
+// Validate verifies the location's fields. The LocationID is normalized.
+func (l *Location) Validate() error {
+	l.LocationID = strings.TrimSpace(l.LocationID)
+	if l.LocationID == "" || (l.LocationType != LocationWarehouse && l.LocationType != LocationStudio) {
+		return fmt.Errorf(ErrInvalidLocation)
+	}
+	if !validOrigin(l.Address) {
+		return fmt.Errorf(ErrInvalidLocation)
+	}
+	if l.ReturnAddress != (Address{}) && !validOrigin(l.ReturnAddress) {
+		return fmt.Errorf(ErrInvalidLocation)
+	}
+	if l.Inventory == nil {
+		l.Inventory = make(map[string]int)
+	}
+	for _, count := range l.Inventory {
+		if count < 0 {
+			return fmt.Errorf(ErrInvalidLocation)
+		}
+	}
+	return nil
+}
+
+// validOrigin returns true if the address has the fields required to ship from it.
+func validOrigin(a Address) bool {
+	for _, f := range []string{a.AddressLine1, a.City, a.State, a.Zip} {
+		if strings.TrimSpace(f) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns returns the address returns to the location are shipped to.
+func (l *Location) Returns() Address {
+	if l.ReturnAddress != (Address{}) {
+		return l.ReturnAddress
+	}
+	return l.Address
+}
+
+// Origin returns the ShipmentOrigin of packages shipped from the location.
+func (l *Location) Origin() ShipmentOrigin {
+	return ShipmentOrigin{LocationID: l.LocationID, AddressFrom: l.Address, ReturnAddress: l.Returns()}
+}
+
+// Allocation contains the items shipped from a location. Items are copies of the order's items
+// with the quantity shipped from the location.
+type Allocation struct {
+	Location *Location
+	Items    []*CartItem
+}
+
+// AllocateItems chooses the active locations the items are shipped from. The items are shipped
+// from the location nearest to the address that stocks each unit, if any. Otherwise the items
+// are split between locations: the location that stocks the most remaining units is chosen
+// until each unit is allocated, preferring nearer locations between locations that stock as
+// many units. Returns ErrInsufficientLocationStock if the locations do not stock each unit.
+func AllocateItems(locations []*Location, items []*CartItem, to Address) ([]Allocation, error) {
+	candidates := []*Location{}
+	for _, l := range locations {
+		if l.Active {
+			candidates = append(candidates, l)
+		}
+	}
+	dist := make(map[*Location]int)
+	for _, l := range candidates {
+		dist[l] = distance(l.Address, to)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if dist[a] != dist[b] {
+			return dist[a] < dist[b]
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.LocationID < b.LocationID
+	})
+
+	remaining := make([]int, len(items))
+	left := 0
+	for i, item := range items {
+		remaining[i] = item.Quantity
+		left += item.Quantity
+	}
+	allocs := []Allocation{}
+	for left > 0 {
+		// units of the remaining items stocked by the location
+		best, bestUnits := -1, 0
+		for i, l := range candidates {
+			units := 0
+			for j, item := range items {
+				units += minInt(remaining[j], l.Inventory[item.SizeID])
+			}
+			if units > bestUnits {
+				best, bestUnits = i, units
+			}
+		}
+		if best < 0 {
+			return []Allocation{}, fmt.Errorf(ErrInsufficientLocationStock)
+		}
+		l := candidates[best]
+		alloc := Allocation{Location: l}
+		for j, item := range items {
+			n := minInt(remaining[j], l.Inventory[item.SizeID])
+			if n == 0 {
+				continue
+			}
+			copied := *item
+			copied.Quantity = n
+			alloc.Items = append(alloc.Items, &copied)
+			remaining[j] -= n
+		}
+		allocs = append(allocs, alloc)
+		left -= bestUnits
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+	return allocs, nil
+}
+
+// minInt returns the smaller of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// distance returns an approximate distance between the addresses. US ZIP code prefixes are
+// assigned in geographic order, so the distance between US addresses is the difference of their
+// 3 digit ZIP prefixes. Addresses in different countries are the furthest apart.
+func distance(from, to Address) int {
+	if country(from) != country(to) {
+		return 100000
+	}
+	a, aErr := strconv.Atoi(zip3(from.Zip))
+	b, bErr := strconv.Atoi(zip3(to.Zip))
+	if aErr != nil || bErr != nil {
+		// non-US postal codes
+		if strings.EqualFold(strings.TrimSpace(from.State), strings.TrimSpace(to.State)) {
+			return 0
+		}
+		return 1000
+	}
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// country returns the ISO country code of the address. Addresses without a country are US
+// addresses.
+func country(a Address) string {
+	c := strings.ToUpper(strings.TrimSpace(a.Country))
+	switch c {
+	case "", "USA", "UNITED STATES":
+		return "US"
+	}
+	return c
+}
+
+// zip3 returns the 3 digit prefix of a 5 digit ZIP code, or an empty string if the ZIP code is
+// invalid.
+func zip3(zip string) string {
+	zip = strings.TrimSpace(zip)
+	if len(zip) < 5 {
+		return ""
+	}
+	return zip[:3]
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testLocations() []*Location {
+	return []*Location{
+		{LocationID: "mod", LocationType: LocationWarehouse, Address: Address{State: "CA", Zip: "95355"}, Inventory: map[string]int{"001-S": 5, "002-M": 1}, Active: true},
+		{LocationID: "nyc", LocationType: LocationStudio, Address: Address{State: "NY", Zip: "10001"}, Inventory: map[string]int{"001-S": 2, "002-M": 3, "003-L": 1}, Active: true},
+		{LocationID: "la", LocationType: LocationStudio, Address: Address{State: "CA", Zip: "90012"}, Inventory: map[string]int{"001-S": 1}, Priority: 1, Active: true},
+		{LocationID: "old", LocationType: LocationWarehouse, Address: Address{State: "NY", Zip: "10002"}, Inventory: map[string]int{"003-L": 10}},
+	}
+}
+
+func TestAllocateItems(t *testing.T) {
+	sf := Address{State: "CA", Zip: "94105"}
+	ny := Address{State: "NY", Zip: "11201"}
+	var tests = []struct {
+		name    string
+		items   []*CartItem
+		to      Address
+		want    []string // location:size:quantity
+		wantErr string
+	}{
+		{
+			name:  "nearest location",
+			items: []*CartItem{{SizeID: "001-S", Quantity: 1}},
+			to:    sf,
+			want:  []string{"mod:001-S:1"},
+		},
+		{
+			name:  "nearest location stocking each unit",
+			items: []*CartItem{{SizeID: "001-S", Quantity: 2}},
+			to:    ny,
+			want:  []string{"nyc:001-S:2"},
+		},
+		{
+			name:  "location stocking each unit preferred over nearer location",
+			items: []*CartItem{{SizeID: "001-S", Quantity: 1}, {SizeID: "002-M", Quantity: 2}},
+			to:    sf,
+			want:  []string{"nyc:001-S:1", "nyc:002-M:2"},
+		},
+		{
+			name:  "split between locations",
+			items: []*CartItem{{SizeID: "001-S", Quantity: 4}, {SizeID: "003-L", Quantity: 1}},
+			to:    sf,
+			want:  []string{"mod:001-S:4", "nyc:003-L:1"},
+		},
+		{
+			name:    "inactive location ignored",
+			items:   []*CartItem{{SizeID: "003-L", Quantity: 2}},
+			to:      ny,
+			wantErr: ErrInsufficientLocationStock,
+		},
+		{
+			name:    "not stocked",
+			items:   []*CartItem{{SizeID: "004-XL", Quantity: 1}},
+			to:      sf,
+			wantErr: ErrInsufficientLocationStock,
+		},
+	}
+	for _, test := range tests {
+		allocs, err := AllocateItems(testLocations(), test.items, test.to)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL - %s: %v; want: %s", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL - %s: %v", test.name, err)
+			continue
+		}
+		got := []string{}
+		for _, a := range allocs {
+			for _, item := range a.Items {
+				got = append(got, fmt.Sprintf("%s:%s:%d", a.Location.LocationID, item.SizeID, item.Quantity))
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("FAIL - %s: %v; want: %v", test.name, got, test.want)
+		}
+		// order items are not modified
+		if test.items[0].Quantity == 0 {
+			t.Errorf("FAIL - %s: item quantity modified", test.name)
+		}
+	}
+}
+
+func TestAllocateItemsPriority(t *testing.T) {
+	locations := []*Location{
+		{LocationID: "b", Address: Address{Zip: "95355"}, Inventory: map[string]int{"001-S": 1}, Priority: 2, Active: true},
+		{LocationID: "a", Address: Address{Zip: "95301"}, Inventory: map[string]int{"001-S": 1}, Priority: 1, Active: true},
+	}
+	allocs, err := AllocateItems(locations, []*CartItem{{SizeID: "001-S", Quantity: 1}}, Address{Zip: "95320"})
+	if err != nil || len(allocs) != 1 || allocs[0].Location.LocationID != "a" {
+		t.Errorf("FAIL: %v, %v; want: a", allocs, err)
+	}
+}
+
+func TestValidateLocation(t *testing.T) {
+	addr := Address{AddressLine1: "100 Test Way", City: "Modesto", State: "CA", Zip: "95355"}
+	var tests = []struct {
+		location Location
+		want     bool // valid
+	}{
+		{Location{LocationID: "mod", LocationType: LocationWarehouse, Address: addr}, true},
+		{Location{LocationID: " ", LocationType: LocationWarehouse, Address: addr}, false},
+		{Location{LocationID: "mod", LocationType: "STORE", Address: addr}, false},
+		{Location{LocationID: "mod", LocationType: LocationStudio, Address: Address{City: "Modesto", State: "CA", Zip: "95355"}}, false},
+		{Location{LocationID: "mod", LocationType: LocationStudio, Address: addr, ReturnAddress: Address{City: "Modesto"}}, false},
+		{Location{LocationID: "mod", LocationType: LocationStudio, Address: addr, Inventory: map[string]int{"001-S": -1}}, false},
+	}
+	for _, test := range tests {
+		err := test.location.Validate()
+		if (err == nil) != test.want {
+			t.Errorf("FAIL: %v, %v; want valid: %v", test.location, err, test.want)
+		}
+		if err == nil && test.location.Inventory == nil {
+			t.Errorf("FAIL: inventory not initialized: %v", test.location)
+		}
+	}
+
+	l := Location{Address: addr}
+	if l.Returns() != addr {
+		t.Errorf("FAIL: %v; want: %v", l.Returns(), addr)
+	}
+	l.ReturnAddress = Address{AddressLine1: "1 Returns Rd", City: "Fresno", State: "CA", Zip: "93650"}
+	if o := l.Origin(); o.AddressFrom != addr || o.ReturnAddress != l.ReturnAddress {
+		t.Errorf("FAIL: %v; want return address: %v", o, l.ReturnAddress)
+	}
+}
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// CurrencyUSD is the ISO 4217 code for US Dollars; the store's default currency.
+const CurrencyUSD = "USD"
+
+const ErrCurrencyMismatch = "ERR_CURRENCY_MISMATCH"
+const ErrInvalidAmount = "ERR_INVALID_AMOUNT"
+
+// RoundingMode specifies how fractional minor units are rounded.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halves away from zero (0.5 -> 1, -0.5 -> -1).
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halves to the nearest even value (banker's rounding).
+	RoundHalfEven
+)
+
+// minorUnits lists the number of decimal places for currencies that do not use 2.
+var minorUnits = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+// Money represents a monetary value as an integer number of minor units (cents)
+// in the given ISO 4217 currency. The zero value is 0 of an unspecified currency,
+// which is compatible with any currency in arithmetic operations.
+type Money struct {
+	Amount   int64  `json:"amount"`   // minor units (ex: 2995 = $29.95)
+	Currency string `json:"currency"` // ISO 4217 currency code
+}
+
+// USD returns a new Money value of the given amount of US cents.
+func USD(cents int64) Money {
+	return Money{Amount: cents, Currency: CurrencyUSD}
+}
+
+// NewMoney returns a new Money value of the given amount of minor units.
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// ParseMoney parses a decimal string in major units (ex: "29.95") into a Money value.
+// Digits beyond the currency's minor units are rounded half up.
+func ParseMoney(s, currency string) (Money, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Money{}, fmt.Errorf(ErrInvalidAmount)
+	}
+	currency = strings.ToUpper(currency)
+	r.Mul(r, new(big.Rat).SetInt(scale(currency)))
+	return Money{Amount: roundRat(r, RoundHalfUp), Currency: currency}, nil
+}
+
+// MoneyFromFloat converts a float value in major units into a Money value.
+// It is used to read legacy float encoded prices.
+func MoneyFromFloat(f float64, currency string) Money {
+	m, _ := ParseMoney(strconv.FormatFloat(f, 'f', -1, 64), currency)
+	return m
+}
+
+// MinorUnits returns the number of decimal places used by the currency.
+func MinorUnits(currency string) int {
+	if d, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return d
+	}
+	return 2
+}
+
+// scale returns 10^MinorUnits(currency).
+func scale(currency string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(MinorUnits(currency))), nil)
+}
+
+// roundRat rounds r to an integer with the given rounding mode.
+func roundRat(r *big.Rat, mode RoundingMode) int64 {
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if m.Sign() == 0 {
+		return q.Int64()
+	}
+	// compare the remainder against half of the denominator
+	cmp := new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(r.Denom())
+	away := cmp > 0
+	if cmp == 0 {
+		switch mode {
+		case RoundHalfEven:
+			away = q.Bit(0) == 1
+		default:
+			away = true
+		}
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	return q.Int64()
+}
+
+// currencyOf returns the shared currency of a and b. A zero value currency
+// adopts the currency of the other operand.
+func currencyOf(a, b Money) string {
+	if a.Currency == "" {
+		return b.Currency
+	}
+	if b.Currency != "" && a.Currency != b.Currency {
+		panic(fmt.Errorf("%s: %s != %s", ErrCurrencyMismatch, a.Currency, b.Currency))
+	}
+	return a.Currency
+}
+
+// sameCurrency returns true if a and b are of the same currency, or either currency is
+// unspecified; i.e. if a and b can be compared without panicking.
+func sameCurrency(a, b Money) bool {
+	return a.Currency == "" || b.Currency == "" || a.Currency == b.Currency
+}
+
+// storeCurrency normalizes the currency code of m to upper case and returns true if m is in the
+// store's currency (CurrencyUSD) or of an unspecified currency. Used to validate amounts set by
+// admins.
+func storeCurrency(m *Money) bool {
+	m.Currency = strings.ToUpper(strings.TrimSpace(m.Currency))
+	return m.Currency == "" || m.Currency == CurrencyUSD
+}
+
+// Add returns m + o. Add panics if m and o are of different currencies.
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: currencyOf(m, o)}
+}
+
+// Sub returns m - o. Sub panics if m and o are of different currencies.
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: currencyOf(m, o)}
+}
+
+// Mul returns m * n, used for unit price * quantity.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// MulRate returns m * rate rounded to the nearest minor unit with the given mode
+// (ex: USD(1000).MulRate(0.0725, RoundHalfUp) = USD(73)). The rate is read from its
+// shortest decimal representation so that values such as 0.0725 are exact.
+func (m Money) MulRate(rate float64, mode RoundingMode) Money {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	r.Mul(r, new(big.Rat).SetInt64(m.Amount))
+	return Money{Amount: roundRat(r, mode), Currency: m.Currency}
+}
+
+// Percent returns pct percent of m rounded with the given mode (ex: 15 = 15%).
+func (m Money) Percent(pct float64, mode RoundingMode) Money {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(pct, 'f', -1, 64))
+	r.Mul(r, new(big.Rat).SetInt64(m.Amount))
+	r.Quo(r, new(big.Rat).SetInt64(100))
+	return Money{Amount: roundRat(r, mode), Currency: m.Currency}
+}
+
+// MulFrac returns m * num / den rounded with the given mode. It is used to prorate amounts,
+// such as the sales tax of a partial refund (ex: SalesTax.MulFrac(refunded, subtotal, mode)).
+// A zero denominator returns zero.
+func (m Money) MulFrac(num, den int64, mode RoundingMode) Money {
+	if den == 0 {
+		return Money{Currency: m.Currency}
+	}
+	r := new(big.Rat).SetFrac(big.NewInt(m.Amount), big.NewInt(den))
+	r.Mul(r, new(big.Rat).SetInt64(num))
+	return Money{Amount: roundRat(r, mode), Currency: m.Currency}
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Cmp compares m and o and returns -1 if m < o, 0 if m == o and 1 if m > o.
+// Cmp panics if m and o are of different currencies.
+func (m Money) Cmp(o Money) int {
+	currencyOf(m, o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// IsZero returns true if m is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative returns true if m is less than zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Float64 returns m in major units. It is intended for display and
+// third party APIs only; arithmetic should be done with Money values.
+func (m Money) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(big.NewInt(m.Amount), scale(m.Currency)).Float64()
+	return f
+}
+
+// String returns m as a decimal string in major units (ex: "29.95").
+func (m Money) String() string {
+	d := MinorUnits(m.Currency)
+	sign := ""
+	amt := m.Amount
+	if amt < 0 {
+		sign = "-"
+		amt = -amt
+	}
+	s := strconv.FormatInt(amt, 10)
+	if d == 0 {
+		return sign + s
+	}
+	if len(s) <= d {
+		s = strings.Repeat("0", d-len(s)+1) + s
+	}
+	return sign + s[:len(s)-d] + "." + s[len(s)-d:]
+}
+
+// money is used to encode/decode Money values without recursion.
+type money Money
+
+// UnmarshalJSON decodes a Money value from either the {"amount", "currency"} object
+// encoding, or from a legacy float encoded value in US Dollars (ex: 29.95).
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if data[0] != '{' {
+		// legacy float value; quoted numbers are accepted
+		parsed, err := ParseMoney(strings.Trim(string(data), `"`), CurrencyUSD)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+	v := money{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	v.Currency = strings.ToUpper(v.Currency)
+	*m = Money(v)
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue encodes a Money value as a DynamoDB map attribute.
+func (m Money) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	av.M = map[string]*dynamodb.AttributeValue{
+		"amount":   {N: strPtr(strconv.FormatInt(m.Amount, 10))},
+		"currency": {S: strPtr(m.Currency)},
+	}
+	if m.Currency == "" {
+		av.M["currency"] = &dynamodb.AttributeValue{NULL: boolPtr(true)}
+	}
+	return nil
+}
+
+// UnmarshalDynamoDBAttributeValue decodes a Money value from either a DynamoDB map
+// attribute, or from a legacy number attribute in US Dollars.
+func (m *Money) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	switch {
+	case av == nil || (av.NULL != nil && *av.NULL):
+		return nil
+	case av.N != nil:
+		parsed, err := ParseMoney(*av.N, CurrencyUSD)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case av.M != nil:
+		v := Money{}
+		if amt, ok := av.M["amount"]; ok && amt.N != nil {
+			n, err := strconv.ParseInt(*amt.N, 10, 64)
+			if err != nil {
+				return fmt.Errorf(ErrInvalidAmount)
+			}
+			v.Amount = n
+		}
+		if cur, ok := av.M["currency"]; ok && cur.S != nil {
+			v.Currency = strings.ToUpper(*cur.S)
+		}
+		*m = v
+		return nil
+	}
+	return fmt.Errorf(ErrInvalidAmount)
+}
+
+func strPtr(s string) *string { return &s }
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestParseMoney(t *testing.T) {
+	var tests = []struct {
+		input    string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{"29.95", "USD", USD(2995), false},
+		{"0.1", "usd", USD(10), false},
+		{"1.005", "USD", USD(101), false}, // half up
+		{"-1.005", "USD", USD(-101), false},
+		{"100", "JPY", NewMoney(100, "JPY"), false},
+		{"1.2345", "KWD", NewMoney(1235, "KWD"), false},
+		{"abc", "USD", Money{}, true},
+	}
+	for _, test := range tests {
+		got, err := ParseMoney(test.input, test.currency)
+		if (err != nil) != test.wantErr {
+			t.Errorf("FAIL - err: %v; want err: %v", err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+	}
+}
+
+func TestMoneyMulRate(t *testing.T) {
+	var tests = []struct {
+		m    Money
+		rate float64
+		mode RoundingMode
+		want Money
+	}{
+		{USD(1000), 0.0725, RoundHalfUp, USD(73)},    // 72.5 -> 73
+		{USD(1000), 0.0725, RoundHalfEven, USD(72)},  // 72.5 -> 72
+		{USD(1400), 0.0725, RoundHalfEven, USD(102)}, // 101.5 -> 102
+		{USD(2995), 0.0725, RoundHalfUp, USD(217)},   // 217.1375
+		{USD(-1000), 0.0725, RoundHalfUp, USD(-73)},
+		{USD(10), 5.55, RoundHalfUp, USD(56)},
+		{USD(0), 0.0725, RoundHalfUp, USD(0)},
+	}
+	for _, test := range tests {
+		got := test.m.MulRate(test.rate, test.mode)
+		if got != test.want {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+	}
+}
+
+func TestMoneyMulFrac(t *testing.T) {
+	var tests = []struct {
+		m        Money
+		num, den int64
+		mode     RoundingMode
+		want     Money
+	}{
+		{USD(217), 1, 3, RoundHalfUp, USD(72)},  // 72.33
+		{USD(217), 2, 3, RoundHalfUp, USD(145)}, // 144.67
+		{USD(300), 1000, 4000, RoundHalfUp, USD(75)},
+		{USD(5), 1, 2, RoundHalfUp, USD(3)},   // 2.5 -> 3
+		{USD(5), 1, 2, RoundHalfEven, USD(2)}, // 2.5 -> 2
+		{USD(217), 0, 0, RoundHalfUp, USD(0)}, // zero denominator
+	}
+	for _, test := range tests {
+		got := test.m.MulFrac(test.num, test.den, test.mode)
+		if got != test.want {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	var tests = []struct {
+		m    Money
+		pct  float64
+		mode RoundingMode
+		want Money
+	}{
+		{USD(2995), 10, RoundHalfUp, USD(300)},   // 299.5
+		{USD(2995), 10, RoundHalfEven, USD(300)}, // 299.5 -> 300
+		{USD(2985), 10, RoundHalfEven, USD(298)}, // 298.5 -> 298
+		{USD(1999), 100, RoundHalfUp, USD(1999)},
+	}
+	for _, test := range tests {
+		got := test.m.Percent(test.pct, test.mode)
+		if got != test.want {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	// float32 sums drift: 0.1 added 10 times != 1.0
+	total := USD(0)
+	for i := 0; i < 10; i++ {
+		total = total.Add(USD(10))
+	}
+	if total != USD(100) {
+		t.Errorf("FAIL: %v; want: %v", total, USD(100))
+	}
+	if got := USD(1495).Mul(3).Sub(USD(85)); got != USD(4400) {
+		t.Errorf("FAIL: %v; want: %v", got, USD(4400))
+	}
+	// zero value adopts currency
+	if got := (Money{}).Add(USD(5)); got != USD(5) {
+		t.Errorf("FAIL: %v; want: %v", got, USD(5))
+	}
+	if USD(5).Cmp(USD(6)) != -1 || USD(6).Cmp(USD(5)) != 1 || USD(5).Cmp(USD(5)) != 0 {
+		t.Errorf("FAIL: Cmp")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("FAIL: currency mismatch did not panic")
+		}
+	}()
+	USD(5).Add(NewMoney(5, "EUR"))
+}
+
+func TestMoneyString(t *testing.T) {
+	var tests = []struct {
+		m    Money
+		want string
+	}{
+		{USD(2995), "29.95"},
+		{USD(5), "0.05"},
+		{USD(0), "0.00"},
+		{USD(-150), "-1.50"},
+		{NewMoney(500, "JPY"), "500"},
+		{NewMoney(1234, "KWD"), "1.234"},
+	}
+	for _, test := range tests {
+		if got := test.m.String(); got != test.want {
+			t.Errorf("FAIL: %s; want: %s", got, test.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var tests = []struct {
+		input string
+		want  Money
+	}{
+		{`{"price": {"amount": 2995, "currency": "USD"}}`, USD(2995)},
+		{`{"price": {"amount": 2995, "currency": "usd"}}`, USD(2995)},
+		{`{"price": 29.95}`, USD(2995)}, // legacy float encoding
+		{`{"price": 17.950000762939453}`, USD(1795)},
+		{`{"price": 0}`, USD(0)},
+		{`{"price": null}`, Money{}},
+		{`{}`, Money{}},
+	}
+	for _, test := range tests {
+		v := StoreItemSummary{}
+		if err := json.Unmarshal([]byte(test.input), &v); err != nil {
+			t.Errorf("FAIL: %v", err)
+		}
+		if v.Price != test.want {
+			t.Errorf("FAIL: %v; want: %v", v.Price, test.want)
+		}
+	}
+
+	// round trip
+	in := StoreItemSummary{ItemID: "001", Price: USD(1499)}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	out := StoreItemSummary{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if out.Price != in.Price {
+		t.Errorf("FAIL: %v; want: %v", out.Price, in.Price)
+	}
+}
+
+func TestMoneyDynamoDBAttributeValue(t *testing.T) {
+	// legacy number attribute
+	legacy := map[string]*dynamodb.AttributeValue{
+		"item_id": {S: strPtr("001")},
+		"price":   {N: strPtr("29.95")},
+	}
+	v := StoreItemSummary{}
+	if err := dynamodbattribute.UnmarshalMap(legacy, &v); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if v.Price != USD(2995) {
+		t.Errorf("FAIL: %v; want: %v", v.Price, USD(2995))
+	}
+
+	// round trip
+	in := StoreItemSummary{ItemID: "002", Price: USD(1499)}
+	av, err := dynamodbattribute.MarshalMap(in)
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if av["price"].M == nil {
+		t.Errorf("FAIL: %v; want: map attribute", av["price"])
+	}
+	out := StoreItemSummary{}
+	if err := dynamodbattribute.UnmarshalMap(av, &out); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if out.Price != in.Price {
+		t.Errorf("FAIL: %v; want: %v", out.Price, in.Price)
+	}
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"fmt"
+)
+
+// ErrInvalidTransition is returned when an event is not valid for the order's current status.
+const ErrInvalidTransition = "ERR_INVALID_TRANSITION"
+
+// ErrUnknownOrderStatus is returned when an order has an unrecognized status value.
+const ErrUnknownOrderStatus = "ERR_UNKNOWN_ORDER_STATUS"
+
+// ErrUnknownOrderEvent is returned for unrecognized OrderEvent values.
+const ErrUnknownOrderEvent = "ERR_UNKNOWN_ORDER_EVENT"
+
+// OrderEvent represents an event that moves an Order from one status to another.
+type OrderEvent string
+
+// Order lifecycle events
+const (
+	OrderEventCheckout       OrderEvent = "CHECKOUT"             // OPEN -> PAYMENT_IN_PROGRESS
+	OrderEventPaymentSuccess OrderEvent = "PAYMENT_SUCCESS"      // PAYMENT_IN_PROGRESS -> PAID
+	OrderEventPaymentFail    OrderEvent = "PAYMENT_FAIL"         // PAYMENT_IN_PROGRESS -> OPEN
+	OrderEventShip           OrderEvent = "SHIP"                 // PAID -> SHIPPED
+	OrderEventDeliver        OrderEvent = "DELIVER"              // SHIPPED -> DELIVERED
+	OrderEventClose          OrderEvent = "CLOSE"                // DELIVERED -> CLOSED
+	OrderEventRequestReturn  OrderEvent = "REQUEST_RETURN"       // SHIPPED, DELIVERED, CLOSED -> OPEN_RETURN
+	OrderEventRejectReturn   OrderEvent = "REJECT_RETURN"        // OPEN_RETURN -> CLOSED
+	OrderEventRefund         OrderEvent = "REFUND"               // OPEN_RETURN, OPEN_RETURN_ITEMS_RECEIVED -> OPEN_RETURN_REFUNDED
+	OrderEventReceiveItems   OrderEvent = "RECEIVE_RETURN_ITEMS" // OPEN_RETURN, OPEN_RETURN_REFUNDED -> OPEN_RETURN_ITEMS_RECEIVED
+	OrderEventCompleteReturn OrderEvent = "COMPLETE_RETURN"      // OPEN_RETURN_REFUNDED, OPEN_RETURN_ITEMS_RECEIVED -> RETURNED
+)
+
+// orderTransitions maps each order status to the events it accepts and the resulting status.
+var orderTransitions = map[string]map[OrderEvent]string{
+	OrderStatusOpen: {
+		OrderEventCheckout: OrderStatusPaymentInProgress,
+	},
+	OrderStatusPaymentInProgress: {
+		OrderEventPaymentSuccess: OrderStatusPaid,
+		OrderEventPaymentFail:    OrderStatusOpen,
+	},
+	OrderStatusPaid: {
+		OrderEventShip: OrderStatusShipped,
+	},
+	OrderStatusShipped: {
+		OrderEventDeliver:       OrderStatusDelivered,
+		OrderEventRequestReturn: OrderStatusOpenReturn,
+	},
+	OrderStatusDelivered: {
+		OrderEventClose:         OrderStatusClosed,
+		OrderEventRequestReturn: OrderStatusOpenReturn,
+	},
+	OrderStatusClosed: {
+		OrderEventRequestReturn: OrderStatusOpenReturn,
+	},
+	OrderStatusOpenReturn: {
+		OrderEventRefund:       OrderStatusRefunded,
+		OrderEventReceiveItems: OrderStatusItemsReceived,
+		OrderEventRejectReturn: OrderStatusClosed,
+	},
+	OrderStatusRefunded: {
+		OrderEventReceiveItems:   OrderStatusItemsReceived,
+		OrderEventCompleteReturn: OrderStatusReturned,
+	},
+	OrderStatusItemsReceived: {
+		OrderEventRefund:         OrderStatusRefunded,
+		OrderEventCompleteReturn: OrderStatusReturned,
+	},
+	OrderStatusReturned: {},
+}
+
+// validOrderEvents contains each event accepted by at least one status.
+var validOrderEvents = map[OrderEvent]bool{
+	OrderEventCheckout:       true,
+	OrderEventPaymentSuccess: true,
+	OrderEventPaymentFail:    true,
+	OrderEventShip:           true,
+	OrderEventDeliver:        true,
+	OrderEventClose:          true,
+	OrderEventRequestReturn:  true,
+	OrderEventRejectReturn:   true,
+	OrderEventRefund:         true,
+	OrderEventReceiveItems:   true,
+	OrderEventCompleteReturn: true,
+}
+
+// TransitionError is returned when an OrderEvent cannot be applied to an order.
+// Code contains one of ErrInvalidTransition, ErrUnknownOrderStatus or ErrUnknownOrderEvent.
+type TransitionError struct {
+	Code  string
+	From  string
+	Event OrderEvent
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: %s on status %q", e.Code, e.Event, e.From)
+}
+
+// ValidOrderStatus returns true if status is a valid order status value.
+func ValidOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// NextOrderStatus returns the status that follows from applying event to an order with
+// the given status, or a *TransitionError if the transition is not allowed.
+// An empty status is treated as OrderStatusOpen for records created before OrderStatus was set.
+func NextOrderStatus(from string, event OrderEvent) (string, error) {
+	if from == "" {
+		from = OrderStatusOpen
+	}
+	if !validOrderEvents[event] {
+		return "", &TransitionError{Code: ErrUnknownOrderEvent, From: from, Event: event}
+	}
+	events, ok := orderTransitions[from]
+	if !ok {
+		return "", &TransitionError{Code: ErrUnknownOrderStatus, From: from, Event: event}
+	}
+	to, ok := events[event]
+	if !ok {
+		return "", &TransitionError{Code: ErrInvalidTransition, From: from, Event: event}
+	}
+	return to, nil
+}
+
+// OrderStatusFlags returns the values of the Order.Paid, Order.Shipped, Order.Delivered
+// and Order.Complete fields for the given order status.
+func OrderStatusFlags(status string) (paid, shipped, delivered, complete bool) {
+	switch status {
+	case OrderStatusPaymentInProgress:
+		complete = true
+	case OrderStatusPaid:
+		paid, complete = true, true
+	case OrderStatusShipped:
+		paid, shipped, complete = true, true, true
+	case OrderStatusDelivered, OrderStatusClosed, OrderStatusOpenReturn,
+		OrderStatusRefunded, OrderStatusItemsReceived, OrderStatusReturned:
+		paid, shipped, delivered, complete = true, true, true, true
+	}
+	return
+}
+
+// SyncStatusFlags sets the o.Paid, o.Shipped, o.Delivered and o.Complete fields
+// to match o.OrderStatus.
+func (o *Order) SyncStatusFlags() {
+	o.Paid, o.Shipped, o.Delivered, o.Complete = OrderStatusFlags(o.OrderStatus)
+}
+
+// Apply applies event to the order, updating o.OrderStatus and the status flags.
+// The prior status is returned for use in conditional writes. The order is not
+// modified if the transition is not allowed.
+func (o *Order) Apply(event OrderEvent) (string, error) {
+	prior := o.OrderStatus
+	to, err := NextOrderStatus(prior, event)
+	if err != nil {
+		return prior, err
+	}
+	o.OrderStatus = to
+	o.SyncStatusFlags()
+	return prior, nil
+}
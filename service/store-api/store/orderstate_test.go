@@ -0,0 +1,95 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNextOrderStatus(t *testing.T) {
+	var tests = []struct {
+		from    string
+		event   OrderEvent
+		want    string
+		wantErr string
+	}{
+		{OrderStatusOpen, OrderEventCheckout, OrderStatusPaymentInProgress, ""},
+		{"", OrderEventCheckout, OrderStatusPaymentInProgress, ""}, // legacy record
+		{OrderStatusPaymentInProgress, OrderEventPaymentSuccess, OrderStatusPaid, ""},
+		{OrderStatusPaymentInProgress, OrderEventPaymentFail, OrderStatusOpen, ""},
+		{OrderStatusPaid, OrderEventShip, OrderStatusShipped, ""},
+		{OrderStatusShipped, OrderEventDeliver, OrderStatusDelivered, ""},
+		{OrderStatusDelivered, OrderEventClose, OrderStatusClosed, ""},
+		{OrderStatusClosed, OrderEventRequestReturn, OrderStatusOpenReturn, ""},
+		{OrderStatusShipped, OrderEventRequestReturn, OrderStatusOpenReturn, ""},
+		{OrderStatusOpenReturn, OrderEventRejectReturn, OrderStatusClosed, ""},
+		{OrderStatusOpenReturn, OrderEventRefund, OrderStatusRefunded, ""},
+		{OrderStatusRefunded, OrderEventReceiveItems, OrderStatusItemsReceived, ""},
+		{OrderStatusItemsReceived, OrderEventCompleteReturn, OrderStatusReturned, ""},
+		{OrderStatusOpen, OrderEventShip, "", ErrInvalidTransition},             // not paid
+		{OrderStatusPaid, OrderEventCheckout, "", ErrInvalidTransition},         // double checkout
+		{OrderStatusShipped, OrderEventShip, "", ErrInvalidTransition},          // already shipped
+		{OrderStatusReturned, OrderEventRefund, "", ErrInvalidTransition},       // terminal status
+		{OrderStatusPaid, OrderEventRequestReturn, "", ErrInvalidTransition},    // not shipped
+		{OrderStatusRefunded, OrderEventRejectReturn, "", ErrInvalidTransition}, // refund issued
+		{"SHIPPING", OrderEventDeliver, "", ErrUnknownOrderStatus},              // invalid status
+		{OrderStatusPaid, OrderEvent("TELEPORT"), "", ErrUnknownOrderEvent},     // invalid event
+	}
+	for _, test := range tests {
+		got, err := NextOrderStatus(test.from, test.event)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("FAIL: %v; want: nil", err)
+			}
+			if got != test.want {
+				t.Errorf("FAIL: %s; want: %s", got, test.want)
+			}
+			continue
+		}
+		var te *TransitionError
+		if !errors.As(err, &te) {
+			t.Errorf("FAIL: %v; want: *TransitionError", err)
+			continue
+		}
+		if te.Code != test.wantErr {
+			t.Errorf("FAIL: %s; want: %s", te.Code, test.wantErr)
+		}
+	}
+}
+
+func TestOrderApply(t *testing.T) {
+	order := &Order{OrderStatus: OrderStatusOpen}
+	events := []OrderEvent{OrderEventCheckout, OrderEventPaymentSuccess, OrderEventShip, OrderEventDeliver}
+	for _, event := range events {
+		if _, err := order.Apply(event); err != nil {
+			t.Fatalf("FAIL: %v; want: nil", err)
+		}
+	}
+	if order.OrderStatus != OrderStatusDelivered {
+		t.Errorf("FAIL: %s; want: %s", order.OrderStatus, OrderStatusDelivered)
+	}
+	if !order.Paid || !order.Shipped || !order.Delivered || !order.Complete {
+		t.Errorf("FAIL - flags: %v", order)
+	}
+
+	// illegal transition leaves order unmodified
+	prior, err := order.Apply(OrderEventShip)
+	if err == nil {
+		t.Errorf("FAIL: nil; want: %s", ErrInvalidTransition)
+	}
+	if prior != OrderStatusDelivered || order.OrderStatus != OrderStatusDelivered {
+		t.Errorf("FAIL: %s; want: %s", order.OrderStatus, OrderStatusDelivered)
+	}
+
+	// payment failure returns order to OPEN and clears flags
+	order = &Order{OrderStatus: OrderStatusPaymentInProgress, Complete: true}
+	prior, err = order.Apply(OrderEventPaymentFail)
+	if err != nil {
+		t.Errorf("FAIL: %v; want: nil", err)
+	}
+	if prior != OrderStatusPaymentInProgress {
+		t.Errorf("FAIL: %s; want: %s", prior, OrderStatusPaymentInProgress)
+	}
+	if order.Paid || order.Complete {
+		t.Errorf("FAIL - flags: %v", order)
+	}
+}
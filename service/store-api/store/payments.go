@@ -15,6 +15,7 @@ const RefundStatusSuccess = "REFUND_SUCCESS"
 // RefundStatusFail contains the status code for failed refunds.
 const RefundStatusFail = "REFUND_FAIL"
 
+// PaymentDisputed contains the status code for disputed transactions.
 const PaymentDisputed = "DISPUTED"
 
 // ValidPaymentStatus contains the valid values for Stripe transaction statuses.
@@ -24,17 +25,42 @@ var ValidPaymentStatus = map[string]bool{
 	PaymentStatusInProgress: true,
 	RefundStatusFail:        true,
 	RefundStatusSuccess:     true,
+	PaymentDisputed:         true,
+}
+
+// PaymentStatusField returns the name of the Transaction and Order attribute the status is
+// recorded in. Refund and dispute statuses are recorded separately from the sale's payment
+// status, as a refund may be for part of the sale.
+func PaymentStatusField(status string) string {
+	switch status {
+	case RefundStatusSuccess, RefundStatusFail:
+		return "refund_status"
+	case PaymentDisputed:
+		return "dispute_status"
+	}
+	return "payment_status"
 }
 
 // PaymentStatus contains message info to send to the PaymentStatus fifo queue.
 // Objects staged in the Staging fifo queue are processed on receipt of this message
 type PaymentStatus struct {
-	CustomerEmail string `json:"customer_email"`
-	CustomerID    string `json:"customer_id"`
-	OrderID       string `json:"order_id"`
-	TransactionID string `json:"transaction_id"`
-	PaymentMethod string `json:"payment_method"` // 3rd party payment platform name (ex: Stripe, Apple Pay)
-	PaymentTxID   string `json:"payment_tx_id"`  // 3rd party payment transaction ID returned by API
-	TxStatus      string `json:"tx_status"`
-	TxMessage     string `json:"tx_message"`
+	CustomerEmail  string `json:"customer_email"`
+	CustomerID     string `json:"customer_id"`
+	OrderID        string `json:"order_id"`
+	TransactionID  string `json:"transaction_id"`
+	PaymentMethod  string `json:"payment_method"` // 3rd party payment platform name (ex: Stripe, Apple Pay)
+	PaymentTxID    string `json:"payment_tx_id"`  // 3rd party payment transaction ID returned by API
+	TxStatus       string `json:"tx_status"`
+	TxMessage      string `json:"tx_message"`
+	ChargesAndFees Money  `json:"charges_and_fees"` // processing fees charged by the payment platform; set for successful payments
+}
+
+// WebhookEvent records a payment provider webhook event that has been processed.
+// Events are recorded by ID so that redelivered events are only processed once.
+type WebhookEvent struct {
+	EventID    string `json:"event_id"` // DB PK
+	Provider   string `json:"provider"`
+	Type       string `json:"type"`
+	ReceivedAt string `json:"received_at"`
+	TTL        int64  `json:"ttl"` // unix timestamp (s) - record expiration
 }
@@ -0,0 +1,22 @@
+package store
+
+import "testing"
+
+func TestPaymentStatusField(t *testing.T) {
+	var tests = []struct {
+		status string
+		want   string
+	}{
+		{PaymentStatusSuccess, "payment_status"},
+		{PaymentStatusFail, "payment_status"},
+		{PaymentStatusInProgress, "payment_status"},
+		{RefundStatusSuccess, "refund_status"},
+		{RefundStatusFail, "refund_status"},
+		{PaymentDisputed, "dispute_status"},
+	}
+	for _, test := range tests {
+		if got := PaymentStatusField(test.status); got != test.want {
+			t.Errorf("FAIL - %s: %s; want: %s", test.status, got, test.want)
+		}
+	}
+}
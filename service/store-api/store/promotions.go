@@ -0,0 +1,263 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Promotion types
+const (
+	PromotionPercentOff   = "PERCENT_OFF"   // percent off eligible items
+	PromotionFixedAmount  = "FIXED_AMOUNT"  // fixed amount off eligible items
+	PromotionFreeShipping = "FREE_SHIPPING" // shipping cost discounted in full
+	PromotionBuyXGetY     = "BUY_X_GET_Y"   // percent off the Y lowest priced units of every X+Y eligible units
+)
+
+// promotionDateLayout is the timestamp format of Promotion start & end dates ('MM-DD-YYYY HH:MM:SS').
+const promotionDateLayout = "01-02-2006 15:04:05"
+
+// ErrInvalidPromotion is returned when a promotion's rule fields are missing or invalid.
+const ErrInvalidPromotion = "ERR_INVALID_PROMOTION"
+
+// ErrPromotionNotFound is returned when a coupon code does not match an existing promotion.
+const ErrPromotionNotFound = "ERR_PROMOTION_NOT_FOUND"
+
+// ErrPromotionInactive is returned when a promotion is disabled or outside of its start & end dates.
+const ErrPromotionInactive = "ERR_PROMOTION_INACTIVE"
+
+// ErrPromotionNotApplicable is returned when the order does not meet the promotion's minimum
+// subtotal or contains no items within the promotion's scope.
+const ErrPromotionNotApplicable = "ERR_PROMOTION_NOT_APPLICABLE"
+
+// ErrPromotionNotStackable is returned when a promotion that is not stackable is combined with
+// other promotions, or when a coupon code is applied more than once.
+const ErrPromotionNotStackable = "ERR_PROMOTION_NOT_STACKABLE"
+
+// ErrPromotionLimitReached is returned when a promotion's global usage limit has been reached.
+const ErrPromotionLimitReached = "ERR_PROMOTION_LIMIT_REACHED"
+
+// ErrPromotionCustomerLimit is returned when a customer has reached a promotion's per-customer usage limit.
+const ErrPromotionCustomerLimit = "ERR_PROMOTION_CUSTOMER_LIMIT"
+
+// Promotion represents a coupon code and the discount rule applied to orders redeeming it.
+// Promotions may be scoped to subcategories and/or item IDs; promotions without a scope apply
+// to all items.
+type Promotion struct {
+	Code                 string   `json:"code"` // uppercase coupon code
+	Description          string   `json:"description"`
+	PromotionType        string   `json:"promotion_type"`
+	PercentOff           float64  `json:"percent_off"`           // PERCENT_OFF, BUY_X_GET_Y (ex: 15 = 15%, 100 = free)
+	AmountOff            Money    `json:"amount_off"`            // FIXED_AMOUNT
+	BuyQuantity          int      `json:"buy_quantity"`          // BUY_X_GET_Y - X
+	GetQuantity          int      `json:"get_quantity"`          // BUY_X_GET_Y - Y
+	Subcategories        []string `json:"sub_categories"`        // scope
+	ItemIDs              []string `json:"item_ids"`              // scope
+	MinSubtotal          Money    `json:"min_subtotal"`          // minimum order subtotal
+	StartDate            string   `json:"start_date"`            // 'MM-DD-YYYY HH:MM:SS'; no start date if empty
+	EndDate              string   `json:"end_date"`              // 'MM-DD-YYYY HH:MM:SS'; no end date if empty
+	MaxRedemptions       int      `json:"max_redemptions"`       // global usage limit; 0 = unlimited
+	RedemptionsRemaining int      `json:"redemptions_remaining"` // decremented on redemption if MaxRedemptions > 0
+	Redemptions          int      `json:"redemptions"`           // total number of redemptions
+	MaxPerCustomer       int      `json:"max_per_customer"`      // per-customer usage limit; 0 = unlimited
+	Stackable            bool     `json:"stackable"`             // may be combined with other stackable promotions
+	Active               bool     `json:"active"`
+}
+
+// PromotionRedemption records the number of times a customer has redeemed a promotion.
+type PromotionRedemption struct {
+	Code        string `json:"code"`
+	UserID      string `json:"user_id"`
+	Redemptions int    `json:"redemptions"`
+}
+
+// Discount represents a promotion applied to an order. Amount is the positive amount
+// subtracted from the order total.
+type Discount struct {
+	Code          string `json:"code"`
+	PromotionType string `json:"promotion_type"`
+	Description   string `json:"description"`
+	Amount        Money  `json:"amount"`
+}
+
+// NormalizeCode returns the coupon code in the format used as the Promotions table key.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate verifies the promotion's rule fields. The Code field and the currencies of AmountOff and
+// MinSubtotal are normalized; amounts must be in the store's currency.
+func (p *Promotion) Validate() error {
+	p.Code = NormalizeCode(p.Code)
+	if p.Code == "" || p.MaxRedemptions < 0 || p.MaxPerCustomer < 0 {
+		return fmt.Errorf(ErrInvalidPromotion)
+	}
+	if !storeCurrency(&p.AmountOff) || !storeCurrency(&p.MinSubtotal) {
+		return fmt.Errorf(ErrInvalidPromotion)
+	}
+	switch p.PromotionType {
+	case PromotionPercentOff:
+		if p.PercentOff <= 0 || p.PercentOff > 100 {
+			return fmt.Errorf(ErrInvalidPromotion)
+		}
+	case PromotionFixedAmount:
+		if p.AmountOff.Amount <= 0 {
+			return fmt.Errorf(ErrInvalidPromotion)
+		}
+	case PromotionFreeShipping:
+	case PromotionBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 || p.PercentOff <= 0 || p.PercentOff > 100 {
+			return fmt.Errorf(ErrInvalidPromotion)
+		}
+	default:
+		return fmt.Errorf(ErrInvalidPromotion)
+	}
+	for _, d := range []string{p.StartDate, p.EndDate} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(promotionDateLayout, d); err != nil {
+			return fmt.Errorf(ErrInvalidPromotion)
+		}
+	}
+	return nil
+}
+
+// InScope returns true if the item is within the promotion's subcategory or item ID scope.
+func (p *Promotion) InScope(item *CartItem) bool {
+	if len(p.Subcategories) == 0 && len(p.ItemIDs) == 0 {
+		return true
+	}
+	for _, s := range p.Subcategories {
+		if s == item.Subcategory {
+			return true
+		}
+	}
+	for _, id := range p.ItemIDs {
+		if id == item.ItemID {
+			return true
+		}
+	}
+	return false
+}
+
+// Eligible verifies that the promotion is active at the given time, has redemptions remaining,
+// and applies to the given items and order subtotal. Per-customer limits are verified by the
+// caller, as they require the customer's redemption count.
+func (p *Promotion) Eligible(items []*CartItem, subtotal Money, now time.Time) error {
+	if p.Code == "" {
+		return fmt.Errorf(ErrPromotionNotFound)
+	}
+	if !p.Active {
+		return fmt.Errorf(ErrPromotionInactive)
+	}
+	if p.StartDate != "" {
+		start, err := time.Parse(promotionDateLayout, p.StartDate)
+		if err != nil || now.Before(start) {
+			return fmt.Errorf(ErrPromotionInactive)
+		}
+	}
+	if p.EndDate != "" {
+		end, err := time.Parse(promotionDateLayout, p.EndDate)
+		if err != nil || now.After(end) {
+			return fmt.Errorf(ErrPromotionInactive)
+		}
+	}
+	if p.MaxRedemptions > 0 && p.RedemptionsRemaining <= 0 {
+		return fmt.Errorf(ErrPromotionLimitReached)
+	}
+	if !sameCurrency(subtotal, p.MinSubtotal) || !sameCurrency(subtotal, p.AmountOff) || subtotal.Cmp(p.MinSubtotal) < 0 {
+		return fmt.Errorf(ErrPromotionNotApplicable)
+	}
+	for _, item := range items {
+		if p.InScope(item) {
+			return nil
+		}
+	}
+	return fmt.Errorf(ErrPromotionNotApplicable)
+}
+
+// Discount returns the discount line for the promotion applied to the given items and shipping
+// cost. Item discounts are limited to the subtotal of the items in the promotion's scope.
+func (p *Promotion) Discount(items []*CartItem, shipping Money) Discount {
+	d := Discount{Code: p.Code, PromotionType: p.PromotionType, Description: p.Description}
+	scoped := Money{}
+	units := []Money{} // unit prices of items in scope
+	for _, item := range items {
+		if !p.InScope(item) {
+			continue
+		}
+		scoped = scoped.Add(item.ItemSubtotal)
+		for i := 0; i < item.Quantity; i++ {
+			units = append(units, item.Price)
+		}
+	}
+
+	switch p.PromotionType {
+	case PromotionPercentOff:
+		d.Amount = scoped.Percent(p.PercentOff, RoundHalfUp)
+	case PromotionFixedAmount:
+		d.Amount = p.AmountOff
+		if d.Amount.Cmp(scoped) > 0 {
+			d.Amount = scoped
+		}
+	case PromotionFreeShipping:
+		d.Amount = shipping
+	case PromotionBuyXGetY:
+		// units are grouped from highest to lowest price; the lowest priced Y units of each
+		// complete group of X+Y units are discounted
+		sort.Slice(units, func(i, j int) bool { return units[i].Amount > units[j].Amount })
+		d.Amount = Money{Currency: scoped.Currency}
+		group := p.BuyQuantity + p.GetQuantity
+		for i := 0; i+group <= len(units); i += group {
+			for _, u := range units[i+p.BuyQuantity : i+group] {
+				d.Amount = d.Amount.Add(u.Percent(p.PercentOff, RoundHalfUp))
+			}
+		}
+	}
+	return d
+}
+
+// ApplyPromotions verifies the eligibility of each promotion and returns the discount lines
+// for the order's items, subtotal and shipping cost. Promotions may only be combined if each
+// promotion is stackable. The combined item discounts do not exceed the order subtotal.
+func ApplyPromotions(promos []*Promotion, items []*CartItem, subtotal, shipping Money, now time.Time) ([]Discount, error) {
+	discounts := []Discount{}
+	applied := make(map[string]bool)
+	for _, p := range promos {
+		if applied[p.Code] || (len(promos) > 1 && !p.Stackable) {
+			return []Discount{}, fmt.Errorf(ErrPromotionNotStackable)
+		}
+		if err := p.Eligible(items, subtotal, now); err != nil {
+			return []Discount{}, err
+		}
+		applied[p.Code] = true
+	}
+
+	remaining := subtotal
+	for _, p := range promos {
+		d := p.Discount(items, shipping)
+		if d.PromotionType != PromotionFreeShipping {
+			if d.Amount.Cmp(remaining) > 0 {
+				d.Amount = remaining
+			}
+			remaining = remaining.Sub(d.Amount)
+		}
+		discounts = append(discounts, d)
+	}
+	return discounts, nil
+}
+
+// DiscountTotals returns the sum of the discounts applied to items and to shipping costs.
+// Sales tax is calculated on the item subtotal less item discounts.
+func DiscountTotals(discounts []Discount) (items, shipping Money) {
+	for _, d := range discounts {
+		if d.PromotionType == PromotionFreeShipping {
+			shipping = shipping.Add(d.Amount)
+			continue
+		}
+		items = items.Add(d.Amount)
+	}
+	return items, shipping
+}
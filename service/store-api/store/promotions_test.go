@@ -0,0 +1,152 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+// testPromoItems returns 2 shirts at $29.95, 1 poster at $15.00 and 1 hoodie at $50.00.
+func testPromoItems() []*CartItem {
+	return []*CartItem{
+		{ItemID: "005", SizeID: "005-L", Subcategory: "shirts", Quantity: 2, Price: USD(2995), ItemSubtotal: USD(5990)},
+		{ItemID: "003", SizeID: "003-OS", Subcategory: "posters", Quantity: 1, Price: USD(1500), ItemSubtotal: USD(1500)},
+		{ItemID: "009", SizeID: "009-M", Subcategory: "hoodies", Quantity: 1, Price: USD(5000), ItemSubtotal: USD(5000)},
+	}
+}
+
+var testPromoTime = time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func TestPromotionValidate(t *testing.T) {
+	var tests = []struct {
+		promo    Promotion
+		wantCode string
+		wantErr  bool
+	}{
+		{promo: Promotion{Code: " save10 ", PromotionType: PromotionPercentOff, PercentOff: 10}, wantCode: "SAVE10", wantErr: false},
+		{promo: Promotion{Code: "FIVE", PromotionType: PromotionFixedAmount, AmountOff: USD(500)}, wantCode: "FIVE", wantErr: false},
+		{promo: Promotion{Code: "FIVE", PromotionType: PromotionFixedAmount, AmountOff: NewMoney(500, "usd"), MinSubtotal: Money{Amount: 2000, Currency: "usd"}}, wantCode: "FIVE", wantErr: false},
+		{promo: Promotion{Code: "BAD", PromotionType: PromotionFixedAmount, AmountOff: NewMoney(500, "EUR")}, wantErr: true},
+		{promo: Promotion{Code: "BAD", PromotionType: PromotionFreeShipping, MinSubtotal: NewMoney(2000, "cad")}, wantErr: true},
+		{promo: Promotion{Code: "SHIP", PromotionType: PromotionFreeShipping}, wantCode: "SHIP", wantErr: false},
+		{promo: Promotion{Code: "B2G1", PromotionType: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, PercentOff: 100}, wantCode: "B2G1", wantErr: false},
+		{promo: Promotion{Code: "", PromotionType: PromotionFreeShipping}, wantErr: true},
+		{promo: Promotion{Code: "BAD", PromotionType: "HALF_OFF"}, wantErr: true},
+		{promo: Promotion{Code: "BAD", PromotionType: PromotionPercentOff, PercentOff: 110}, wantErr: true},
+		{promo: Promotion{Code: "BAD", PromotionType: PromotionFixedAmount}, wantErr: true},
+		{promo: Promotion{Code: "BAD", PromotionType: PromotionBuyXGetY, BuyQuantity: 1, PercentOff: 100}, wantErr: true},
+		{promo: Promotion{Code: "BAD", PromotionType: PromotionFreeShipping, StartDate: "2022-06-01"}, wantErr: true},
+		{promo: Promotion{Code: "BAD", PromotionType: PromotionFreeShipping, MaxRedemptions: -1}, wantErr: true},
+	}
+	for _, test := range tests {
+		err := test.promo.Validate()
+		if (err != nil) != test.wantErr {
+			t.Errorf("FAIL: %v; want err: %v", err, test.wantErr)
+		}
+		if !test.wantErr && test.promo.Code != test.wantCode {
+			t.Errorf("FAIL: %s; want: %s", test.promo.Code, test.wantCode)
+		}
+		if !test.wantErr && (test.promo.AmountOff.Currency == "usd" || test.promo.MinSubtotal.Currency == "usd") {
+			t.Errorf("FAIL: %v, %v; want: USD", test.promo.AmountOff, test.promo.MinSubtotal)
+		}
+	}
+}
+
+func TestPromotionEligible(t *testing.T) {
+	var tests = []struct {
+		promo   Promotion
+		wantErr string
+	}{
+		{promo: Promotion{Code: "A", Active: true}, wantErr: ""},
+		{promo: Promotion{Code: "", Active: true}, wantErr: ErrPromotionNotFound},
+		{promo: Promotion{Code: "A", Active: false}, wantErr: ErrPromotionInactive},
+		{promo: Promotion{Code: "A", Active: true, StartDate: "06-01-2022 00:00:00", EndDate: "06-30-2022 23:59:59"}, wantErr: ""},
+		{promo: Promotion{Code: "A", Active: true, StartDate: "07-01-2022 00:00:00"}, wantErr: ErrPromotionInactive},
+		{promo: Promotion{Code: "A", Active: true, EndDate: "06-14-2022 23:59:59"}, wantErr: ErrPromotionInactive},
+		{promo: Promotion{Code: "A", Active: true, MaxRedemptions: 100, RedemptionsRemaining: 1}, wantErr: ""},
+		{promo: Promotion{Code: "A", Active: true, MaxRedemptions: 100, RedemptionsRemaining: 0}, wantErr: ErrPromotionLimitReached},
+		{promo: Promotion{Code: "A", Active: true, MinSubtotal: USD(12490)}, wantErr: ""},
+		{promo: Promotion{Code: "A", Active: true, MinSubtotal: USD(12491)}, wantErr: ErrPromotionNotApplicable},
+		{promo: Promotion{Code: "A", Active: true, MinSubtotal: NewMoney(100, "EUR")}, wantErr: ErrPromotionNotApplicable},
+		{promo: Promotion{Code: "A", Active: true, AmountOff: NewMoney(500, "EUR")}, wantErr: ErrPromotionNotApplicable},
+		{promo: Promotion{Code: "A", Active: true, Subcategories: []string{"posters"}}, wantErr: ""},
+		{promo: Promotion{Code: "A", Active: true, ItemIDs: []string{"009"}}, wantErr: ""},
+		{promo: Promotion{Code: "A", Active: true, Subcategories: []string{"hats"}, ItemIDs: []string{"001"}}, wantErr: ErrPromotionNotApplicable},
+	}
+	for _, test := range tests {
+		err := test.promo.Eligible(testPromoItems(), USD(12490), testPromoTime)
+		if test.wantErr == "" && err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+		}
+	}
+}
+
+func TestPromotionDiscount(t *testing.T) {
+	var tests = []struct {
+		promo Promotion
+		want  Money
+	}{
+		{promo: Promotion{PromotionType: PromotionPercentOff, PercentOff: 10}, want: USD(1249)},                                            // 1249.0
+		{promo: Promotion{PromotionType: PromotionPercentOff, PercentOff: 15, Subcategories: []string{"shirts"}}, want: USD(899)},          // 898.5
+		{promo: Promotion{PromotionType: PromotionPercentOff, PercentOff: 20, ItemIDs: []string{"003", "009"}}, want: USD(1300)},           // posters + hoodies
+		{promo: Promotion{PromotionType: PromotionFixedAmount, AmountOff: USD(1000)}, want: USD(1000)},                                     // amount off
+		{promo: Promotion{PromotionType: PromotionFixedAmount, AmountOff: USD(2000), Subcategories: []string{"posters"}}, want: USD(1500)}, // limited to scope
+		{promo: Promotion{PromotionType: PromotionFreeShipping}, want: USD(800)},
+		{promo: Promotion{PromotionType: PromotionBuyXGetY, BuyQuantity: 1, GetQuantity: 1, PercentOff: 100, Subcategories: []string{"shirts"}}, want: USD(2995)},
+		{promo: Promotion{PromotionType: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, PercentOff: 100}, want: USD(2995)}, // 5000, 2995, [2995], 1500
+		{promo: Promotion{PromotionType: PromotionBuyXGetY, BuyQuantity: 1, GetQuantity: 1, PercentOff: 50}, want: USD(2248)},  // 5000, [2995], 2995, [1500] -> 1497.5 + 750
+		{promo: Promotion{PromotionType: PromotionBuyXGetY, BuyQuantity: 4, GetQuantity: 1, PercentOff: 100}, want: USD(0)},    // incomplete group
+	}
+	for _, test := range tests {
+		d := test.promo.Discount(testPromoItems(), USD(800))
+		if d.Amount != test.want {
+			t.Errorf("FAIL: %v; want: %v", d.Amount, test.want)
+		}
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	save10 := &Promotion{Code: "SAVE10", PromotionType: PromotionPercentOff, PercentOff: 10, Active: true, Stackable: true}
+	ship := &Promotion{Code: "SHIPFREE", PromotionType: PromotionFreeShipping, Active: true, Stackable: true}
+	big := &Promotion{Code: "BIG", PromotionType: PromotionFixedAmount, AmountOff: USD(20000), Active: true, Stackable: true}
+	solo := &Promotion{Code: "SOLO", PromotionType: PromotionFixedAmount, AmountOff: USD(500), Active: true}
+	expired := &Promotion{Code: "OLD", PromotionType: PromotionFreeShipping, Active: true, Stackable: true, EndDate: "01-01-2022 00:00:00"}
+
+	var tests = []struct {
+		promos       []*Promotion
+		wantItems    Money
+		wantShipping Money
+		wantErr      string
+	}{
+		{promos: []*Promotion{}, wantItems: Money{}, wantShipping: Money{}, wantErr: ""},
+		{promos: []*Promotion{save10}, wantItems: USD(1249), wantShipping: Money{}, wantErr: ""},
+		{promos: []*Promotion{save10, ship}, wantItems: USD(1249), wantShipping: USD(800), wantErr: ""},
+		{promos: []*Promotion{solo}, wantItems: USD(500), wantShipping: Money{}, wantErr: ""},
+		{promos: []*Promotion{save10, big}, wantItems: USD(12490), wantShipping: Money{}, wantErr: ""}, // limited to subtotal
+		{promos: []*Promotion{save10, solo}, wantErr: ErrPromotionNotStackable},
+		{promos: []*Promotion{save10, save10}, wantErr: ErrPromotionNotStackable},
+		{promos: []*Promotion{save10, expired}, wantErr: ErrPromotionInactive},
+	}
+	for _, test := range tests {
+		discounts, err := ApplyPromotions(test.promos, testPromoItems(), USD(12490), USD(800), testPromoTime)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+			continue
+		}
+		if len(discounts) != len(test.promos) {
+			t.Errorf("FAIL - discounts: %v", discounts)
+		}
+		items, shipping := DiscountTotals(discounts)
+		if items.Amount != test.wantItems.Amount || shipping.Amount != test.wantShipping.Amount {
+			t.Errorf("FAIL: %v, %v; want: %v, %v", items, shipping, test.wantItems, test.wantShipping)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"fmt"
+)
+
+// Return statuses
+const (
+	ReturnStatusRequested     = "REQUESTED"      // awaiting review by store admin
+	ReturnStatusApproved      = "APPROVED"       // return label sent to customer
+	ReturnStatusRejected      = "REJECTED"       // return denied; order closed
+	ReturnStatusItemsReceived = "ITEMS_RECEIVED" // items received; awaiting refund
+	ReturnStatusRefunded      = "REFUNDED"       // refund issued; awaiting items
+	ReturnStatusComplete      = "COMPLETE"       // items received and refund issued
+)
+
+// ErrReturnNotEligible is returned when a return is requested for an order that has not shipped
+// or already has a return.
+const ErrReturnNotEligible = "ERR_RETURN_NOT_ELIGIBLE"
+
+// ErrInvalidReturnItems is returned when a return contains items or quantities not in the order.
+const ErrInvalidReturnItems = "ERR_INVALID_RETURN_ITEMS"
+
+// ErrInvalidReturnTransition is returned when an event is not valid for the return's current status.
+const ErrInvalidReturnTransition = "ERR_INVALID_RETURN_TRANSITION"
+
+// ReturnEvent represents an action taken on a Return by the store admin.
+type ReturnEvent string
+
+// Return events
+const (
+	ReturnEventApprove      ReturnEvent = "APPROVE"       // REQUESTED -> APPROVED
+	ReturnEventReject       ReturnEvent = "REJECT"        // REQUESTED, APPROVED -> REJECTED
+	ReturnEventReceiveItems ReturnEvent = "RECEIVE_ITEMS" // APPROVED -> ITEMS_RECEIVED, REFUNDED -> COMPLETE
+	ReturnEventRefund       ReturnEvent = "REFUND"        // APPROVED -> REFUNDED, ITEMS_RECEIVED -> COMPLETE
+)
+
+// returnTransitions maps each return status to the events it accepts and the resulting status.
+var returnTransitions = map[string]map[ReturnEvent]string{
+	ReturnStatusRequested: {
+		ReturnEventApprove: ReturnStatusApproved,
+		ReturnEventReject:  ReturnStatusRejected,
+	},
+	ReturnStatusApproved: {
+		ReturnEventReject:       ReturnStatusRejected,
+		ReturnEventReceiveItems: ReturnStatusItemsReceived,
+		ReturnEventRefund:       ReturnStatusRefunded,
+	},
+	ReturnStatusItemsReceived: {
+		ReturnEventRefund: ReturnStatusComplete,
+	},
+	ReturnStatusRefunded: {
+		ReturnEventReceiveItems: ReturnStatusComplete,
+	},
+	ReturnStatusRejected: {},
+	ReturnStatusComplete: {},
+}
+
+// returnOrderEvents maps return events to the event applied to the return's order.
+var returnOrderEvents = map[ReturnEvent]OrderEvent{
+	ReturnEventReject:       OrderEventRejectReturn,
+	ReturnEventReceiveItems: OrderEventReceiveItems,
+	ReturnEventRefund:       OrderEventRefund,
+}
+
+// NewReturn creates a new Return for the given quantities of the order's items, keyed by
+// CartItem.SizeID. Orders accept one return, which may be requested once the order has shipped.
+func NewReturn(order *Order, items map[string]int, reason string) (*Return, error) {
+	if _, err := NextOrderStatus(order.OrderStatus, OrderEventRequestReturn); err != nil {
+		return &Return{}, fmt.Errorf(ErrReturnNotEligible)
+	}
+	if len(items) == 0 {
+		return &Return{}, fmt.Errorf(ErrInvalidReturnItems)
+	}
+
+	ordered := make(map[string]int)
+	for _, item := range order.Items {
+		ordered[item.SizeID] += item.Quantity
+	}
+	returnItems := make(map[string]int)
+	for sizeID, qty := range items {
+		if qty <= 0 || qty > ordered[sizeID] {
+			return &Return{}, fmt.Errorf(ErrInvalidReturnItems)
+		}
+		returnItems[sizeID] = qty
+	}
+
+	r := &Return{
+		UserID:       order.UserID,
+		UserEmail:    order.UserEmail,
+		ReturnID:     order.OrderID + "-r",
+		OrderID:      order.OrderID,
+		OriginalTxID: order.TransactionID,
+		ReturnItems:  returnItems,
+		Reason:       reason,
+		Open:         true,
+		ReturnStatus: ReturnStatusRequested,
+	}
+	return r, nil
+}
+
+// NextReturnStatus returns the status that follows from applying event to a return with the
+// given status, or ErrInvalidReturnTransition if the transition is not allowed.
+func NextReturnStatus(from string, event ReturnEvent) (string, error) {
+	to, ok := returnTransitions[from][event]
+	if !ok {
+		return "", fmt.Errorf(ErrInvalidReturnTransition)
+	}
+	return to, nil
+}
+
+// Apply applies event to the return, updating r.ReturnStatus and the status flags. The order
+// events to apply to the return's order are returned in order. The return is not modified
+// if the transition is not allowed.
+func (r *Return) Apply(event ReturnEvent) ([]OrderEvent, error) {
+	to, err := NextReturnStatus(r.ReturnStatus, event)
+	if err != nil {
+		return []OrderEvent{}, err
+	}
+	r.ReturnStatus = to
+
+	events := []OrderEvent{}
+	if e, ok := returnOrderEvents[event]; ok {
+		events = append(events, e)
+	}
+	switch event {
+	case ReturnEventReceiveItems:
+		r.ItemsReceived = true
+	case ReturnEventRefund:
+		r.Refunded = true
+	}
+	switch to {
+	case ReturnStatusRejected:
+		r.Open, r.Complete = false, true
+	case ReturnStatusComplete:
+		r.Open, r.Complete = false, true
+		events = append(events, OrderEventCompleteReturn)
+	}
+	return events, nil
+}
+
+// Items returns the order's returned items with quantities and subtotals set to the
+// returned quantities.
+func (r *Return) Items(order *Order) []*CartItem {
+	items := []*CartItem{}
+	remaining := make(map[string]int)
+	for sizeID, qty := range r.ReturnItems {
+		remaining[sizeID] = qty
+	}
+	for _, item := range order.Items {
+		qty := remaining[item.SizeID]
+		if qty > item.Quantity {
+			qty = item.Quantity
+		}
+		if qty <= 0 {
+			continue
+		}
+		remaining[item.SizeID] -= qty
+		returned := *item
+		returned.Quantity = qty
+		returned.ItemSubtotal = item.Price.Mul(int64(qty))
+		items = append(items, &returned)
+	}
+	return items
+}
+
+// RefundTotal returns the subtotal of the returned items and the sales tax charged on them,
+// prorated from the order's sales tax. Item discounts applied to the order are prorated from
+// the subtotal. Shipping costs are not refunded.
+func (r *Return) RefundTotal(order *Order) (subtotal, tax Money) {
+	return order.RefundTotal(r.Items(order))
+}
+
+// RefundTotal returns the refunded subtotal and sales tax of the given items of the order. The
+// order's discounts and sales tax are prorated by the items' share of the order subtotal.
+func (o *Order) RefundTotal(items []*CartItem) (subtotal, tax Money) {
+	refunded := Money{Currency: o.SalesSubtotal.Currency}
+	for _, item := range items {
+		refunded = refunded.Add(item.ItemSubtotal)
+	}
+	discount, _ := DiscountTotals(o.Discounts)
+	subtotal = refunded.Sub(discount.MulFrac(refunded.Amount, o.SalesSubtotal.Amount, RoundHalfUp))
+	tax = o.SalesTax.MulFrac(refunded.Amount, o.SalesSubtotal.Amount, RoundHalfUp)
+	return subtotal, tax
+}
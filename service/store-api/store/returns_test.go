@@ -0,0 +1,208 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testReturnOrder returns a shipped order of 2 shirts and 1 poster with 7.25% sales tax.
+func testReturnOrder() *Order {
+	return &Order{
+		UserID:        "user001",
+		UserEmail:     "user001@test.com",
+		OrderID:       "user001-1",
+		TransactionID: "tx001",
+		OrderStatus:   OrderStatusShipped,
+		Items: []*CartItem{
+			{ItemID: "005", SizeID: "005-L", Size: "L", Subcategory: "shirts", Quantity: 2, Price: USD(2995), ItemSubtotal: USD(5990)},
+			{ItemID: "003", SizeID: "003-OS", Size: "OS", Subcategory: "posters", Quantity: 1, Price: USD(1500), ItemSubtotal: USD(1500)},
+		},
+		SalesSubtotal: USD(7490),
+		SalesTax:      USD(543),
+		ShippingCost:  USD(800),
+	}
+}
+
+func TestNewReturn(t *testing.T) {
+	var tests = []struct {
+		status  string
+		items   map[string]int
+		wantErr string
+	}{
+		{status: OrderStatusShipped, items: map[string]int{"005-L": 1}, wantErr: ""},
+		{status: OrderStatusDelivered, items: map[string]int{"005-L": 2, "003-OS": 1}, wantErr: ""},
+		{status: OrderStatusPaid, items: map[string]int{"005-L": 1}, wantErr: ErrReturnNotEligible},       // not shipped
+		{status: OrderStatusOpenReturn, items: map[string]int{"005-L": 1}, wantErr: ErrReturnNotEligible}, // return in progress
+		{status: OrderStatusShipped, items: map[string]int{"005-L": 3}, wantErr: ErrInvalidReturnItems},   // quantity > ordered
+		{status: OrderStatusShipped, items: map[string]int{"005-XL": 1}, wantErr: ErrInvalidReturnItems},  // size not ordered
+		{status: OrderStatusShipped, items: map[string]int{"005-L": 0}, wantErr: ErrInvalidReturnItems},
+		{status: OrderStatusShipped, items: map[string]int{}, wantErr: ErrInvalidReturnItems},
+	}
+	for _, test := range tests {
+		order := testReturnOrder()
+		order.OrderStatus = test.status
+		r, err := NewReturn(order, test.items, "wrong size")
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+			continue
+		}
+		if r.ReturnStatus != ReturnStatusRequested || !r.Open || r.OriginalTxID != "tx001" || r.UserID != "user001" {
+			t.Errorf("FAIL - DATA: %v", r)
+		}
+		if !reflect.DeepEqual(r.ReturnItems, test.items) {
+			t.Errorf("FAIL: %v; want: %v", r.ReturnItems, test.items)
+		}
+	}
+}
+
+func TestReturnApply(t *testing.T) {
+	var tests = []struct {
+		events     []ReturnEvent
+		wantStatus string
+		wantOrder  []OrderEvent // order events of the last return event
+		wantErr    string
+	}{
+		{
+			events:     []ReturnEvent{ReturnEventApprove},
+			wantStatus: ReturnStatusApproved,
+			wantOrder:  []OrderEvent{},
+		},
+		{
+			events:     []ReturnEvent{ReturnEventReject},
+			wantStatus: ReturnStatusRejected,
+			wantOrder:  []OrderEvent{OrderEventRejectReturn},
+		},
+		{
+			events:     []ReturnEvent{ReturnEventApprove, ReturnEventReceiveItems, ReturnEventRefund},
+			wantStatus: ReturnStatusComplete,
+			wantOrder:  []OrderEvent{OrderEventRefund, OrderEventCompleteReturn},
+		},
+		{
+			events:     []ReturnEvent{ReturnEventApprove, ReturnEventRefund, ReturnEventReceiveItems},
+			wantStatus: ReturnStatusComplete,
+			wantOrder:  []OrderEvent{OrderEventReceiveItems, OrderEventCompleteReturn},
+		},
+		{
+			events:     []ReturnEvent{ReturnEventRefund}, // not approved
+			wantStatus: ReturnStatusRequested,
+			wantErr:    ErrInvalidReturnTransition,
+		},
+		{
+			events:     []ReturnEvent{ReturnEventApprove, ReturnEventRefund, ReturnEventRefund}, // refunded twice
+			wantStatus: ReturnStatusRefunded,
+			wantErr:    ErrInvalidReturnTransition,
+		},
+		{
+			events:     []ReturnEvent{ReturnEventApprove, ReturnEventRefund, ReturnEventReject}, // refund issued
+			wantStatus: ReturnStatusRefunded,
+			wantErr:    ErrInvalidReturnTransition,
+		},
+	}
+	for _, test := range tests {
+		r, err := NewReturn(testReturnOrder(), map[string]int{"005-L": 1}, "")
+		if err != nil {
+			t.Fatalf("FAIL: %v", err)
+		}
+		var got []OrderEvent
+		for _, event := range test.events {
+			got, err = r.Apply(event)
+			if err != nil {
+				break
+			}
+		}
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		} else if !reflect.DeepEqual(got, test.wantOrder) {
+			t.Errorf("FAIL: %v; want: %v", got, test.wantOrder)
+		}
+		if r.ReturnStatus != test.wantStatus {
+			t.Errorf("FAIL: %s; want: %s", r.ReturnStatus, test.wantStatus)
+		}
+		done := test.wantStatus == ReturnStatusComplete || test.wantStatus == ReturnStatusRejected
+		if r.Complete != done || r.Open == done {
+			t.Errorf("FAIL - flags: %v", r)
+		}
+	}
+}
+
+// TestReturnOrderEvents verifies that the order events returned by Return.Apply are valid
+// transitions of the order state machine.
+func TestReturnOrderEvents(t *testing.T) {
+	order := testReturnOrder()
+	r, _ := NewReturn(order, map[string]int{"005-L": 1}, "")
+	if _, err := order.Apply(OrderEventRequestReturn); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	for _, event := range []ReturnEvent{ReturnEventApprove, ReturnEventRefund, ReturnEventReceiveItems} {
+		events, err := r.Apply(event)
+		if err != nil {
+			t.Fatalf("FAIL: %v", err)
+		}
+		for _, e := range events {
+			if _, err := order.Apply(e); err != nil {
+				t.Errorf("FAIL: %v; want: nil", err)
+			}
+		}
+	}
+	if order.OrderStatus != OrderStatusReturned {
+		t.Errorf("FAIL: %s; want: %s", order.OrderStatus, OrderStatusReturned)
+	}
+}
+
+func TestReturnRefundTotal(t *testing.T) {
+	var tests = []struct {
+		items        map[string]int
+		discounts    []Discount
+		wantSubtotal Money
+		wantTax      Money
+	}{
+		{items: map[string]int{"005-L": 1}, wantSubtotal: USD(2995), wantTax: USD(217)},              // 543 * 2995/7490 = 217.13
+		{items: map[string]int{"005-L": 2, "003-OS": 1}, wantSubtotal: USD(7490), wantTax: USD(543)}, // full return
+		{items: map[string]int{"003-OS": 1}, wantSubtotal: USD(1500), wantTax: USD(109)},             // 108.75
+		{ // 10% off; 749 * 2995/7490 = 299.5 discount prorated
+			items:        map[string]int{"005-L": 1},
+			discounts:    []Discount{{Code: "SAVE10", PromotionType: PromotionPercentOff, Amount: USD(749)}},
+			wantSubtotal: USD(2695),
+			wantTax:      USD(217),
+		},
+		{ // shipping discounts are not prorated
+			items:        map[string]int{"005-L": 1},
+			discounts:    []Discount{{Code: "SHIPFREE", PromotionType: PromotionFreeShipping, Amount: USD(800)}},
+			wantSubtotal: USD(2995),
+			wantTax:      USD(217),
+		},
+	}
+	for _, test := range tests {
+		order := testReturnOrder()
+		order.Discounts = test.discounts
+		r, err := NewReturn(order, test.items, "")
+		if err != nil {
+			t.Fatalf("FAIL: %v", err)
+		}
+		subtotal, tax := r.RefundTotal(order)
+		if subtotal != test.wantSubtotal || tax != test.wantTax {
+			t.Errorf("FAIL: %v, %v; want: %v, %v", subtotal, tax, test.wantSubtotal, test.wantTax)
+		}
+	}
+
+	// returned items
+	order := testReturnOrder()
+	r, _ := NewReturn(order, map[string]int{"005-L": 1}, "")
+	items := r.Items(order)
+	if len(items) != 1 || items[0].Quantity != 1 || items[0].ItemSubtotal != USD(2995) {
+		t.Errorf("FAIL - items: %v", items)
+	}
+	if order.Items[0].Quantity != 2 {
+		t.Errorf("FAIL - order modified: %v", order.Items[0])
+	}
+}
@@ -3,7 +3,7 @@ package store
 import (
 	"fmt"
 	"log"
-	"math"
+	"strconv"
 )
 
 const InvalidWeightErr = "INVALID_WEIGHT_UNIT"
@@ -105,12 +105,12 @@ var UspsSmallParcel = Parcel{
 type ShippingMethod struct {
 	MethodID          string  `json:"method_id"`
 	Name              string  `json:"name"`
-	RateUSD           float32 `json:"rate_usd"`            // shipping rate in US Dollars ($/weight unit)
+	RateUSD           Money   `json:"rate_usd"`            // shipping rate in US Dollars ($/weight unit)
 	RateWeightUnit    string  `json:"rate_weight_unit"`    // weight unit required for calculating shipping price
 	WeightInputOz     float32 `json:"weight_input_oz"`     // User input weight value in Oz for price calculation.
 	WeightInputLbs    float32 `json:"weight_input_lbs"`    // weigt value in lbs.
 	WeightInputKg     float32 `json:"weight_input_kg"`     // weight input in kilograms
-	PriceOutputUSD    float32 `json:"price_output_usd"`    // calculated price value in US Dollars
+	PriceOutputUSD    Money   `json:"price_output_usd"`    // calculated price value in US Dollars
 	PriceOutputString string  `json:"price_output_string"` // get price as string value
 }
 
@@ -121,8 +121,9 @@ type Parcel struct {
 	Name             string     `json:"name"`
 	ParcelDimensions Dimensions `json:"parcel_dimensions"`
 	Template         string     `json:"template"`        // shippo parcel template
-	UnitPriceUSD     float32    `json:"unit_price_usd"`  // price per unit
+	UnitPriceUSD     Money      `json:"unit_price_usd"`  // price per unit
 	UnitsAvailable   int        `json:"units_available"` // units in stock ready for shipping use
+	MaxWeightLbs     float32    `json:"max_weight_lbs"`  // max gross weight; 0: no limit besides the carrier's
 }
 
 // Package represents a filled parcel in a Shipment.
@@ -134,19 +135,23 @@ type Package struct {
 	Template       string           `json:"template"` // shippo parcel template
 	Items          []PkgItemSummary `json:"items"`
 	TrackingNumber string           `json:"tracking_number"`
+	LocationID     string           `json:"location_id"` // origin location
 }
 
 // PkgItemSummary contains summary information for each item in the package.
 type PkgItemSummary struct {
 	Subcategory string `json:"subcategory"`
 	ItemID      string `json:"item_id"`
+	SizeID      string `json:"size_id"`
 	Name        string `json:"name"`
+	Size        string `json:"size"`
 	Quantity    int    `json:"quantity"`
 }
 
 // RateSummary contains summary information for an order's shipping rates
 type RateSummary struct {
 	Price        string       `json:"string"`
+	CarrierPrice string       `json:"carrier_price"` // carrier rate before shipping rules are applied
 	Currency     string       `json:"currency"`
 	Provider     string       `json:"provider"`
 	Days         int          `json:"days"`
@@ -175,64 +180,123 @@ type ShippingLabel struct {
 	Eta                  string `json:"eta"`
 	LabelUrl             string `json:"label_url"`
 	CommercialInvoiceUrl string `json:"commercial_invoice_url"`
+	LocationID           string `json:"location_id"` // origin location
+}
+
+// ShipmentOrigin is a location a shipment's packages are shipped from.
+type ShipmentOrigin struct {
+	LocationID    string  `json:"location_id"`
+	AddressFrom   Address `json:"address_from"`
+	ReturnAddress Address `json:"return_address"`
 }
 
 // Shipment contains order shipping info used for order fulfillment at the time of shipping label purchase.
+// Orders split between locations have an origin for each location; AddressFrom is the address of
+// the first origin.
 type Shipment struct {
-	UserID        string          `json:"user_id"`  // pk
-	OrderID       string          `json:"order_id"` // sk
-	Status        string          `json:"status"`
-	AddressTo     Address         `json:"address_to"`
-	AddressFrom   Address         `json:"address_from"`
-	Packages      []Package       `json:"packages"`
-	Rates         []RateSummary   `json:"rates"`
-	SelectedRate  RateSummary     `json:"selected_rate"`
-	Labels        []ShippingLabel `json:"labels"`
-	EstimatedDays int             `json:"estimated_days"`
-}
-
-func (s *ShippingMethod) GetPriceOzs(weight float32) (float32, error) {
+	UserID          string           `json:"user_id"`  // pk
+	OrderID         string           `json:"order_id"` // sk
+	Status          string           `json:"status"`
+	AddressTo       Address          `json:"address_to"`
+	AddressFrom     Address          `json:"address_from"`
+	Origins         []ShipmentOrigin `json:"origins"`
+	Packages        []Package        `json:"packages"`
+	Rates           []RateSummary    `json:"rates"`
+	SelectedRate    RateSummary      `json:"selected_rate"`
+	Labels          []ShippingLabel  `json:"labels"`
+	EstimatedDays   int              `json:"estimated_days"`
+	TrackingHistory []TrackingEvent  `json:"tracking_history"` // tracking events of each label, in order received
+	Version         int              `json:"version"`          // incremented when a label or tracking event is saved
+}
+
+// AddOrigin adds the origin and its packages to the shipment. The packages' LocationID is set to
+// the origin's location.
+func (s *Shipment) AddOrigin(origin ShipmentOrigin, pkgs []Package) {
+	if len(s.Origins) == 0 {
+		s.AddressFrom = origin.AddressFrom
+	}
+	s.Origins = append(s.Origins, origin)
+	for _, pkg := range pkgs {
+		pkg.LocationID = origin.LocationID
+		s.Packages = append(s.Packages, pkg)
+	}
+}
+
+// SplitByOrigin returns a shipment for each of the shipment's origins, containing the packages
+// shipped from the origin. Shipments without origins are returned as is.
+func (s *Shipment) SplitByOrigin() []*Shipment {
+	if len(s.Origins) == 0 {
+		return []*Shipment{s}
+	}
+	parts := []*Shipment{}
+	for _, o := range s.Origins {
+		part := &Shipment{
+			UserID:       s.UserID,
+			OrderID:      s.OrderID,
+			AddressTo:    s.AddressTo,
+			AddressFrom:  o.AddressFrom,
+			Origins:      []ShipmentOrigin{o},
+			SelectedRate: s.SelectedRate,
+		}
+		for _, pkg := range s.Packages {
+			if pkg.LocationID == o.LocationID {
+				part.Packages = append(part.Packages, pkg)
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// ReturnTo returns the address returns of the shipment are shipped to: the return address of
+// the shipment's first origin, or AddressFrom if the shipment has no origins.
+func (s *Shipment) ReturnTo() Address {
+	if len(s.Origins) == 0 {
+		return s.AddressFrom
+	}
+	return s.Origins[0].ReturnAddress
+}
+
+func (s *ShippingMethod) GetPriceOzs(weight float32) (Money, error) {
 	if s.RateWeightUnit != "OZ" {
 		log.Printf("invvalid weight unit %s for GetPricesOz()", s.RateWeightUnit)
-		return 0.00, fmt.Errorf(InvalidWeightErr)
+		return Money{}, fmt.Errorf(InvalidWeightErr)
 	}
 	s.WeightInputOz = weight
-	price := s.RateUSD * s.WeightInputOz
-	rounded := round(price)
-	s.PriceOutputUSD = rounded
-	return rounded, nil
+	price := s.RateUSD.MulRate(decimal32(s.WeightInputOz), RoundHalfUp)
+	s.PriceOutputUSD = price
+	return price, nil
 }
 
-func (s *ShippingMethod) GetPriceLbs(weight float32) (float32, error) {
+func (s *ShippingMethod) GetPriceLbs(weight float32) (Money, error) {
 	if s.RateWeightUnit != "LB" {
 		log.Printf("invvalid weight unit %s for GetPricesLbs()", s.RateWeightUnit)
-		return 0.00, fmt.Errorf(InvalidWeightErr)
+		return Money{}, fmt.Errorf(InvalidWeightErr)
 	}
 	s.WeightInputLbs = weight
-	price := s.RateUSD * s.WeightInputLbs
-	rounded := round(price)
-	s.PriceOutputUSD = rounded
-	return rounded, nil
+	price := s.RateUSD.MulRate(decimal32(s.WeightInputLbs), RoundHalfUp)
+	s.PriceOutputUSD = price
+	return price, nil
 }
 
-func (s *ShippingMethod) GetPriceKgs(weight float32) (float32, error) {
+func (s *ShippingMethod) GetPriceKgs(weight float32) (Money, error) {
 	if s.RateWeightUnit != "KG" {
 		log.Printf("invvalid weight unit %s for GetPricesLbs()", s.RateWeightUnit)
-		return 0.00, fmt.Errorf(InvalidWeightErr)
+		return Money{}, fmt.Errorf(InvalidWeightErr)
 	}
 	s.WeightInputKg = weight
-	price := s.RateUSD * s.WeightInputKg
-	rounded := round(price)
-	s.PriceOutputUSD = rounded
-	return rounded, nil
+	price := s.RateUSD.MulRate(decimal32(s.WeightInputKg), RoundHalfUp)
+	s.PriceOutputUSD = price
+	return price, nil
 }
 
-func round(x float32) float32 {
-	price := float64(x)
-	unit := 0.01 // round float values to next cent
-	return float32(math.Ceil(price/unit) * unit)
+// decimal32 converts a float32 value to the float64 value of its shortest decimal
+// representation (ex: float32(5.55) -> 5.55 rather than 5.550000190734863).
+func decimal32(f float32) float64 {
+	d, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'f', -1, 32), 64)
+	return d
 }
 
 func (s *ShippingMethod) GetPriceString() string {
-	return fmt.Sprintf("%.2f", s.PriceOutputUSD)
+	return s.PriceOutputUSD.String()
 }
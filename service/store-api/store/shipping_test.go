@@ -4,40 +4,16 @@ import (
 	"testing"
 )
 
-func TestRound(t *testing.T) {
-	var tests = []struct {
-		input float32
-		want  float32
-	}{
-		{1.2444, 1.25},
-		{0.7222333, 0.73},
-		{1.000000, 1},
-		{0.000000, 0},
-		{0.000000001, 0.01},
-		{1.799999, 1.80},
-		{25.25252525, 25.26},
-		{100.19001, 100.2},
-	}
-	for _, test := range tests {
-		rounded := round(test.input)
-		if rounded != test.want {
-			t.Errorf("FAIL: %f; want: %f", rounded, test.want)
-		} else {
-			t.Log(rounded)
-		}
-	}
-}
-
 func TestGetPriceOzs(t *testing.T) {
 	var tests = []struct {
-		rate   float32
+		rate   Money
 		weight float32
-		want   float32 // price
+		want   Money // price
 	}{
-		{0.50, 4.00, 2.00},
-		{1.00, 5.50, 5.50},
-		{.10, 5.55, 0.56},
-		{0.00, 20.00, 0.00},
+		{USD(50), 4.00, USD(200)},
+		{USD(100), 5.50, USD(550)},
+		{USD(10), 5.55, USD(56)},
+		{USD(0), 20.00, USD(0)},
 	}
 	for _, test := range tests {
 		m := ShippingMethod{
@@ -49,7 +25,7 @@ func TestGetPriceOzs(t *testing.T) {
 			t.Errorf("FAIL - err: %v", err)
 		}
 		if price != test.want {
-			t.Errorf("FAIL: %f; want: %f", price, test.want)
+			t.Errorf("FAIL: %v; want: %v", price, test.want)
 		}
 		if price == test.want {
 			t.Log(price)
@@ -59,14 +35,14 @@ func TestGetPriceOzs(t *testing.T) {
 
 func TestGetPriceLbs(t *testing.T) {
 	var tests = []struct {
-		rate   float32
+		rate   Money
 		weight float32
-		want   float32 // price
+		want   Money // price
 	}{
-		{0.50, 4.00, 2.00},
-		{1.00, 5.50, 5.50},
-		{.10, 5.55, 0.56},
-		{0.00, 20.00, 0.00},
+		{USD(50), 4.00, USD(200)},
+		{USD(100), 5.50, USD(550)},
+		{USD(10), 5.55, USD(56)},
+		{USD(0), 20.00, USD(0)},
 	}
 	for _, test := range tests {
 		m := ShippingMethod{
@@ -78,7 +54,7 @@ func TestGetPriceLbs(t *testing.T) {
 			t.Errorf("FAIL - err: %v", err)
 		}
 		if price != test.want {
-			t.Errorf("FAIL: %f; want: %f", price, test.want)
+			t.Errorf("FAIL: %v; want: %v", price, test.want)
 		}
 		if price == test.want {
 			t.Log(price)
@@ -88,14 +64,14 @@ func TestGetPriceLbs(t *testing.T) {
 
 func TestGetPriceKg(t *testing.T) {
 	var tests = []struct {
-		rate   float32
+		rate   Money
 		weight float32
-		want   float32 // price
+		want   Money // price
 	}{
-		{0.50, 4.00, 2.00},
-		{1.00, 5.50, 5.50},
-		{.10, 5.55, 0.56},
-		{0.00, 20.00, 0.00},
+		{USD(50), 4.00, USD(200)},
+		{USD(100), 5.50, USD(550)},
+		{USD(10), 5.55, USD(56)},
+		{USD(0), 20.00, USD(0)},
 	}
 	for _, test := range tests {
 		m := ShippingMethod{
@@ -107,7 +83,7 @@ func TestGetPriceKg(t *testing.T) {
 			t.Errorf("FAIL - err: %v", err)
 		}
 		if price != test.want {
-			t.Errorf("FAIL: %f; want: %f", price, test.want)
+			t.Errorf("FAIL: %v; want: %v", price, test.want)
 		}
 		if price == test.want {
 			t.Log(price)
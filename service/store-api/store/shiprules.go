@@ -0,0 +1,224 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Shipping rule types
+const (
+	RuleFreeShipping = "FREE_SHIPPING" // service levels in scope are free above MinSubtotal
+	RuleFlatRate     = "FLAT_RATE"     // service levels in scope are charged Amount
+	RuleMarkup       = "MARKUP"        // Amount plus PercentFee of the carrier rate is added as a handling fee
+	RuleHideService  = "HIDE_SERVICE"  // service levels in scope are not offered
+	RuleMaxDays      = "MAX_DAYS"      // service levels in scope slower than MaxDays are not offered
+)
+
+// ErrInvalidShippingRule is returned when a shipping rule's fields are missing or invalid.
+const ErrInvalidShippingRule = "ERR_INVALID_SHIPPING_RULE"
+
+// ErrShippingRuleNotFound is returned when a shipping rule does not exist.
+const ErrShippingRuleNotFound = "ERR_SHIPPING_RULE_NOT_FOUND"
+
+// ErrNoShippingRates is returned when the shipping rules hide every carrier rate.
+const ErrNoShippingRates = "ERR_NO_SHIPPING_RATES"
+
+// ErrShippingRateNotFound is returned when the selected service level is not one of the
+// shipment's rates.
+const ErrShippingRateNotFound = "ERR_SHIPPING_RATE_NOT_FOUND"
+
+// ShippingRule adjusts the carrier rates offered to customers. Rules may be scoped to service
+// level tokens and to destination countries and states; rules without a scope apply to all
+// service levels and destinations. Rules only apply to orders with a subtotal of at least
+// MinSubtotal.
+type ShippingRule struct {
+	RuleID        string   `json:"rule_id"`
+	Description   string   `json:"description"`
+	RuleType      string   `json:"rule_type"`
+	Priority      int      `json:"priority"`       // FLAT_RATE rules with the lowest priority are applied first
+	ServiceLevels []string `json:"service_levels"` // scope - service level tokens (ex: 'usps_priority')
+	Countries     []string `json:"countries"`      // scope - ISO country codes (ex: 'US')
+	States        []string `json:"states"`         // scope - state codes (ex: 'HI')
+	MinSubtotal   Money    `json:"min_subtotal"`
+	Amount        Money    `json:"amount"`      // FLAT_RATE, MARKUP
+	PercentFee    float64  `json:"percent_fee"` // MARKUP (ex: 10 = 10% of the carrier rate)
+	MaxDays       int      `json:"max_days"`    // MAX_DAYS
+	Active        bool     `json:"active"`
+}
+
+// Validate verifies the rule's fields. The RuleID, scope fields and the currencies of MinSubtotal
+// and Amount are normalized; amounts must be in the store's currency.
+func (r *ShippingRule) Validate() error {
+	r.RuleID = strings.TrimSpace(r.RuleID)
+	for i, c := range r.Countries {
+		r.Countries[i] = strings.ToUpper(strings.TrimSpace(c))
+	}
+	for i, s := range r.States {
+		r.States[i] = strings.ToUpper(strings.TrimSpace(s))
+	}
+	if r.RuleID == "" || r.MinSubtotal.IsNegative() {
+		return fmt.Errorf(ErrInvalidShippingRule)
+	}
+	if !storeCurrency(&r.MinSubtotal) || !storeCurrency(&r.Amount) {
+		return fmt.Errorf(ErrInvalidShippingRule)
+	}
+	switch r.RuleType {
+	case RuleFreeShipping:
+	case RuleFlatRate:
+		if r.Amount.IsNegative() {
+			return fmt.Errorf(ErrInvalidShippingRule)
+		}
+	case RuleMarkup:
+		if r.Amount.IsNegative() || r.PercentFee < 0 || (r.Amount.IsZero() && r.PercentFee == 0) {
+			return fmt.Errorf(ErrInvalidShippingRule)
+		}
+	case RuleHideService:
+		if len(r.ServiceLevels) == 0 && len(r.Countries) == 0 && len(r.States) == 0 {
+			return fmt.Errorf(ErrInvalidShippingRule) // would hide every rate
+		}
+	case RuleMaxDays:
+		if r.MaxDays < 1 {
+			return fmt.Errorf(ErrInvalidShippingRule)
+		}
+	default:
+		return fmt.Errorf(ErrInvalidShippingRule)
+	}
+	return nil
+}
+
+// Applies returns true if the rule is active and applies to orders shipped to the address
+// with the given subtotal. Rules with a MinSubtotal in another currency do not apply.
+func (r *ShippingRule) Applies(to Address, subtotal Money) bool {
+	if !r.Active || !sameCurrency(subtotal, r.MinSubtotal) || subtotal.Cmp(r.MinSubtotal) < 0 {
+		return false
+	}
+	country := strings.ToUpper(strings.TrimSpace(to.Country))
+	if country == "" {
+		country = "US"
+	}
+	return inScope(r.Countries, country) && inScope(r.States, strings.ToUpper(strings.TrimSpace(to.State)))
+}
+
+// InScope returns true if the service level is within the rule's service level scope.
+func (r *ShippingRule) InScope(rate RateSummary) bool {
+	return inScope(r.ServiceLevels, rate.ServiceLevel.Token)
+}
+
+// inScope returns true if the scope is empty or contains the value.
+func inScope(scope []string, value string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+	for _, s := range scope {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyShippingRules returns the rates offered to a customer for the carrier rates of an order
+// shipped to the address. subtotal is the order subtotal less item discounts. Rates hidden by
+// HIDE_SERVICE and MAX_DAYS rules are removed. The price of each remaining rate is the carrier
+// rate plus the handling fees of MARKUP rules, or the Amount of the first FLAT_RATE rule in
+// scope, or free if a FREE_SHIPPING rule is in scope. The carrier rate is kept in
+// CarrierPrice. Rates are sorted by price. Returns ErrNoShippingRates if every rate is hidden.
+func ApplyShippingRules(rules []*ShippingRule, rates []RateSummary, subtotal Money, to Address) ([]RateSummary, error) {
+	applied := []*ShippingRule{}
+	for _, r := range rules {
+		if r.Applies(to, subtotal) {
+			applied = append(applied, r)
+		}
+	}
+	sort.SliceStable(applied, func(i, j int) bool {
+		if applied[i].Priority != applied[j].Priority {
+			return applied[i].Priority < applied[j].Priority
+		}
+		return applied[i].RuleID < applied[j].RuleID
+	})
+
+	offered := []RateSummary{}
+	prices := []Money{}
+	for _, rate := range rates {
+		carrier, err := ParseMoney(rate.Price, rate.Currency)
+		if err != nil {
+			return []RateSummary{}, err
+		}
+		price, hidden, flat, free := carrier, false, false, false
+		fees := NewMoney(0, carrier.Currency)
+		for _, r := range applied {
+			if !r.InScope(rate) || !sameCurrency(carrier, r.Amount) {
+				continue
+			}
+			switch r.RuleType {
+			case RuleHideService:
+				hidden = true
+			case RuleMaxDays:
+				hidden = hidden || rate.Days > r.MaxDays
+			case RuleFreeShipping:
+				free = true
+			case RuleFlatRate:
+				if !flat {
+					price, flat = r.Amount, true
+				}
+			case RuleMarkup:
+				fees = fees.Add(r.Amount).Add(carrier.Percent(r.PercentFee, RoundHalfUp))
+			}
+		}
+		if hidden {
+			continue
+		}
+		switch {
+		case free:
+			price = NewMoney(0, carrier.Currency)
+		case !flat:
+			price = price.Add(fees)
+		}
+		rate.CarrierPrice = carrier.String()
+		rate.Price = price.String()
+		rate.Currency = price.Currency
+		offered = append(offered, rate)
+		prices = append(prices, price)
+	}
+	if len(offered) == 0 {
+		return offered, fmt.Errorf(ErrNoShippingRates)
+	}
+
+	idx := make([]int, len(offered))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return prices[idx[i]].Cmp(prices[idx[j]]) < 0 })
+	sorted := make([]RateSummary, len(offered))
+	for i, k := range idx {
+		sorted[i] = offered[k]
+	}
+	return sorted, nil
+}
+
+// SelectRate returns the shipment rate with the service level token. Returns
+// ErrShippingRateNotFound if the service level is not offered.
+func (s *Shipment) SelectRate(token string) (RateSummary, error) {
+	for _, rate := range s.Rates {
+		if rate.ServiceLevel.Token == token {
+			return rate, nil
+		}
+	}
+	return RateSummary{}, fmt.Errorf(ErrShippingRateNotFound)
+}
+
+// SetShippingCost sets the order's shipping cost, updates the amount of free shipping
+// discounts to the new cost and recalculates the discount total and order total. Sales tax
+// is recalculated by the caller.
+func (o *Order) SetShippingCost(cost Money) {
+	o.ShippingCost = cost
+	for i := range o.Discounts {
+		if o.Discounts[i].PromotionType == PromotionFreeShipping {
+			o.Discounts[i].Amount = cost
+		}
+	}
+	items, shipping := DiscountTotals(o.Discounts)
+	o.DiscountTotal = items.Add(shipping)
+	o.UpdateTotal()
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"testing"
+)
+
+func testRates() []RateSummary {
+	return []RateSummary{
+		{Price: "8.25", Currency: CurrencyUSD, Days: 5, ServiceLevel: ServiceLevel{Token: "usps_ground_advantage"}},
+		{Price: "12.50", Currency: CurrencyUSD, Days: 3, ServiceLevel: ServiceLevel{Token: "usps_priority"}},
+		{Price: "34.00", Currency: CurrencyUSD, Days: 1, ServiceLevel: ServiceLevel{Token: "usps_priority_express"}},
+	}
+}
+
+func TestApplyShippingRules(t *testing.T) {
+	ca := Address{State: "CA", Country: "US"}
+	hi := Address{State: "hi"}
+	ground := []string{"usps_ground_advantage"}
+	var tests = []struct {
+		name     string
+		rules    []*ShippingRule
+		subtotal Money
+		to       Address
+		want     []string // token:price
+		wantErr  string
+	}{
+		{
+			name:     "no rules",
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_ground_advantage:8.25", "usps_priority:12.50", "usps_priority_express:34.00"},
+		},
+		{
+			name:     "free ground shipping above subtotal",
+			rules:    []*ShippingRule{{RuleID: "free", RuleType: RuleFreeShipping, ServiceLevels: ground, MinSubtotal: USD(7500), Active: true}},
+			subtotal: USD(7500), to: ca,
+			want: []string{"usps_ground_advantage:0.00", "usps_priority:12.50", "usps_priority_express:34.00"},
+		},
+		{
+			name:     "free shipping below subtotal",
+			rules:    []*ShippingRule{{RuleID: "free", RuleType: RuleFreeShipping, ServiceLevels: ground, MinSubtotal: USD(7500), Active: true}},
+			subtotal: USD(7499), to: ca,
+			want: []string{"usps_ground_advantage:8.25", "usps_priority:12.50", "usps_priority_express:34.00"},
+		},
+		{
+			name:     "inactive rule",
+			rules:    []*ShippingRule{{RuleID: "free", RuleType: RuleFreeShipping}},
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_ground_advantage:8.25", "usps_priority:12.50", "usps_priority_express:34.00"},
+		},
+		{
+			name: "flat rate by priority; markup not added to flat rates",
+			rules: []*ShippingRule{
+				{RuleID: "flat-b", RuleType: RuleFlatRate, Priority: 2, Amount: USD(500), Active: true},
+				{RuleID: "flat-a", RuleType: RuleFlatRate, Priority: 1, ServiceLevels: []string{"usps_priority"}, Amount: USD(700), Active: true},
+				{RuleID: "handling", RuleType: RuleMarkup, Amount: USD(100), PercentFee: 10, Active: true},
+			},
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_ground_advantage:5.00", "usps_priority_express:5.00", "usps_priority:7.00"},
+		},
+		{
+			name: "markups",
+			rules: []*ShippingRule{
+				{RuleID: "handling", RuleType: RuleMarkup, Amount: USD(100), Active: true},
+				{RuleID: "fuel", RuleType: RuleMarkup, PercentFee: 10, ServiceLevels: ground, Active: true},
+			},
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_ground_advantage:10.08", "usps_priority:13.50", "usps_priority_express:35.00"}, // 8.25 + 1.00 + 0.83
+		},
+		{
+			name: "hide express to hawaii",
+			rules: []*ShippingRule{
+				{RuleID: "hi", RuleType: RuleHideService, ServiceLevels: []string{"usps_priority_express"}, States: []string{"HI"}, Active: true},
+			},
+			subtotal: USD(5000), to: hi,
+			want: []string{"usps_ground_advantage:8.25", "usps_priority:12.50"},
+		},
+		{
+			name: "hide express outside of hawaii",
+			rules: []*ShippingRule{
+				{RuleID: "hi", RuleType: RuleHideService, ServiceLevels: []string{"usps_priority_express"}, States: []string{"HI"}, Active: true},
+			},
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_ground_advantage:8.25", "usps_priority:12.50", "usps_priority_express:34.00"},
+		},
+		{
+			name:     "hide slow services",
+			rules:    []*ShippingRule{{RuleID: "fast", RuleType: RuleMaxDays, MaxDays: 3, Active: true}},
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_priority:12.50", "usps_priority_express:34.00"},
+		},
+		{
+			name: "free shipping sorted first",
+			rules: []*ShippingRule{
+				{RuleID: "free", RuleType: RuleFreeShipping, ServiceLevels: []string{"usps_priority"}, Active: true},
+			},
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_priority:0.00", "usps_ground_advantage:8.25", "usps_priority_express:34.00"},
+		},
+		{
+			name: "rules in other currencies skipped",
+			rules: []*ShippingRule{
+				{RuleID: "free", RuleType: RuleFreeShipping, MinSubtotal: NewMoney(1000, "EUR"), Active: true},
+				{RuleID: "flat", RuleType: RuleFlatRate, Amount: NewMoney(500, "EUR"), Active: true},
+			},
+			subtotal: USD(5000), to: ca,
+			want: []string{"usps_ground_advantage:8.25", "usps_priority:12.50", "usps_priority_express:34.00"},
+		},
+		{
+			name:     "every rate hidden",
+			rules:    []*ShippingRule{{RuleID: "intl", RuleType: RuleHideService, Countries: []string{"CA"}, Active: true}},
+			subtotal: USD(5000), to: Address{Country: "CA"},
+			wantErr: ErrNoShippingRates,
+		},
+	}
+	for _, test := range tests {
+		rates, err := ApplyShippingRules(test.rules, testRates(), test.subtotal, test.to)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL - %s: %v; want: %s", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL - %s: %v", test.name, err)
+			continue
+		}
+		got := []string{}
+		for _, r := range rates {
+			got = append(got, r.ServiceLevel.Token+":"+r.Price)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("FAIL - %s: %v; want: %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("FAIL - %s: %v; want: %v", test.name, got, test.want)
+				break
+			}
+		}
+		for _, r := range rates {
+			if r.CarrierPrice == "" {
+				t.Errorf("FAIL - %s: carrier price not set: %v", test.name, r)
+			}
+		}
+	}
+}
+
+func TestValidateShippingRule(t *testing.T) {
+	var tests = []struct {
+		rule ShippingRule
+		want bool // valid
+	}{
+		{ShippingRule{RuleID: "free", RuleType: RuleFreeShipping, MinSubtotal: USD(5000)}, true},
+		{ShippingRule{RuleID: " ", RuleType: RuleFreeShipping}, false},
+		{ShippingRule{RuleID: "flat", RuleType: RuleFlatRate, Amount: USD(500)}, true},
+		{ShippingRule{RuleID: "flat", RuleType: RuleFlatRate, Amount: USD(-1)}, false},
+		{ShippingRule{RuleID: "flat", RuleType: RuleFlatRate, Amount: Money{Amount: 500, Currency: "usd"}}, true},
+		{ShippingRule{RuleID: "flat", RuleType: RuleFlatRate, Amount: NewMoney(500, "EUR")}, false},
+		{ShippingRule{RuleID: "free", RuleType: RuleFreeShipping, MinSubtotal: NewMoney(5000, "GBP")}, false},
+		{ShippingRule{RuleID: "fee", RuleType: RuleMarkup, PercentFee: 5}, true},
+		{ShippingRule{RuleID: "fee", RuleType: RuleMarkup}, false},
+		{ShippingRule{RuleID: "hide", RuleType: RuleHideService, States: []string{"ak"}}, true},
+		{ShippingRule{RuleID: "hide", RuleType: RuleHideService}, false},
+		{ShippingRule{RuleID: "days", RuleType: RuleMaxDays, MaxDays: 0}, false},
+		{ShippingRule{RuleID: "other", RuleType: "OTHER"}, false},
+	}
+	for _, test := range tests {
+		err := test.rule.Validate()
+		if (err == nil) != test.want {
+			t.Errorf("FAIL: %v, %v; want valid: %v", test.rule, err, test.want)
+		}
+	}
+}
+
+func TestSetShippingCost(t *testing.T) {
+	o := &Order{
+		SalesSubtotal: USD(5000),
+		Discounts: []Discount{
+			{Code: "TEN", PromotionType: PromotionFixedAmount, Amount: USD(1000)},
+			{Code: "SHIP", PromotionType: PromotionFreeShipping},
+		},
+		SalesTax: USD(300),
+	}
+	o.SetShippingCost(USD(850))
+	if o.ShippingCost != USD(850) || o.Discounts[1].Amount != USD(850) || o.DiscountTotal != USD(1850) {
+		t.Errorf("FAIL: %v, %v, %v; want: 8.50, 8.50, 18.50", o.ShippingCost, o.Discounts[1].Amount, o.DiscountTotal)
+	}
+	// 50.00 - 18.50 + 8.50 + 3.00
+	if o.OrderTotal != USD(4300) {
+		t.Errorf("FAIL: %v; want: 43.00", o.OrderTotal)
+	}
+}
@@ -32,10 +32,15 @@ type ShoppingCart struct {
 	UserID        string               `json:"user_id"`
 	Items         map[string]*CartItem `json:"items"` // item ID: CartItem
 	TotalItems    int                  `json:"total_items"`
-	Subtotal      float32              `json:"subtotal"` // sum of CartItems[i].ItemSubtotal
+	Subtotal      Money                `json:"subtotal"` // sum of CartItems[i].ItemSubtotal
 	CartWeightOzs float32              `json:"cart_weight_ozs"`
 	CartWeightLbs float32              `json:"cart_weight_lbs"`
 	CartWeightKgs float32              `json:"cart_weight_kgs"`
+	UserEmail     string               `json:"user_email"` // set at checkout; abandoned cart reminder recipient
+	UpdatedAt     int64                `json:"updated_at"` // unix timestamp (s) of the last cart update
+	Reminders     []int64              `json:"reminders"`  // unix timestamps (s) of abandoned cart reminders sent
+	TTL           int64                `json:"ttl"`        // unix timestamp (s) - record expiration
+	Version       int                  `json:"version"`    // incremented on each write
 }
 
 // CartItem represents a StoreItem added to user's cart for purchase.
@@ -48,13 +53,18 @@ type CartItem struct {
 	Subcategory        string     `json:"sub_category"`
 	Size               string     `json:"size"`
 	Quantity           int        `json:"quantity"`
-	Price              float32    `json:"price"`
-	ItemSubtotal       float32    `json:"item_subtotal"`       // quantity * price
+	Price              Money      `json:"price"`
+	ItemSubtotal       Money      `json:"item_subtotal"`       // quantity * price
 	ProductDimensions  Dimensions `json:"product_dimensions"`  // unpackaged product measurements
 	ShippingDimensions Dimensions `json:"shipping_dimensions"` // packaged product measurements
 	TotalWeightOzs     float32    `json:"total_weight_ozs"`    // quantity * unit weight
 	TotalWeightLbs     float32    `json:"total_weight"`        // quantity * unit weight
 	ThumbnailID        string     `json:"thumbnail_id"`
+	AddedPrice         Money      `json:"added_price"`        // unit price when added to cart
+	AddedAt            int64      `json:"added_at"`           // unix timestamp (s) when added to cart
+	PriceChanged       bool       `json:"price_changed"`      // price changed since added to cart
+	InsufficientStock  bool       `json:"insufficient_stock"` // quantity exceeds units available to sell
+	Digital            bool       `json:"digital"`            // downloaded; not shipped
 }
 
 // Dimensions represents the dimensions of a CartItem or Parcel
@@ -106,19 +116,26 @@ func (d *Dimensions) GetFloats() ([]float32, error) {
 
 // StoreItem represents an item available for purchase in the online store.
 type StoreItem struct {
-	ItemID         string         `json:"item_id"`
-	Name           string         `json:"name"`
-	Description    string         `json:"description"`
-	Category       string         `json:"category"`
-	Subcategory    string         `json:"sub_category"`
-	Price          float32        `json:"price"`
-	UnitsSold      map[string]int `json:"units_sold"`
-	ProductViews   int            `json:"product_views"`   // number of times product viewed
-	UnitsAvailable map[string]int `json:"units_available"` // size: units
-	UnitWeightOzs  float32        `json:"unit_weight_ozs"`
-	UnitWeightLbs  float32        `json:"unit_weight_lbs"`
-	DateAdded      string         `json:"date_added"`
-	ImageUrls      []string       `json:"image_urls"` // src urls for html images for product
+	ItemID           string                 `json:"item_id"`
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description"`
+	Category         string                 `json:"category"`
+	Subcategory      string                 `json:"sub_category"`
+	Price            Money                  `json:"price"`
+	UnitsSold        map[string]int         `json:"units_sold"`
+	ProductViews     int                    `json:"product_views"`   // number of times product viewed
+	UnitsAvailable   map[string]int         `json:"units_available"` // size: units on hand
+	UnitsReserved    map[string]int         `json:"units_reserved"`  // size: units held by active inventory holds
+	UnitWeightOzs    float32                `json:"unit_weight_ozs"`
+	UnitWeightLbs    float32                `json:"unit_weight_lbs"`
+	DateAdded        string                 `json:"date_added"`
+	ImageUrls        []string               `json:"image_urls"`         // src urls for html images for product
+	ProductType      string                 `json:"product_type"`       // ProductTypePhysical (default) or ProductTypeDigital
+	PreviewKey       string                 `json:"preview_key"`        // S3 key of the digital item's mp3 preview
+	MasterKey        string                 `json:"master_key"`         // S3 key of the digital item's WAV master
+	Licenses         map[string]LicenseTier `json:"licenses"`           // tier: license of digital items
+	SoldExclusive    bool                   `json:"sold_exclusive"`     // exclusive license sold; no longer sold
+	ExclusiveOrderID string                 `json:"exclusive_order_id"` // order the exclusive license was sold to
 }
 
 // StoreItemIndex represent a k/v pair of a subcategory and a list of all items belonging to that subcategory.
@@ -147,29 +164,36 @@ func (s *StoreItemIndex) Remove(itemID string) {
 // StoreItemSummary contains summarized info of each StoreItem that is displayed when
 // a user is browsing a selection of items.
 type StoreItemSummary struct {
-	ItemID       string  `json:"item_id"`
-	Subcategory  string  `json:"sub_category"`
-	Name         string  `json:"name"`
-	Price        float32 `json:"price"`
-	ThumbnailUrl string  `json:"thumbnail_url"`
+	ItemID       string `json:"item_id"`
+	Subcategory  string `json:"sub_category"`
+	Name         string `json:"name"`
+	Price        Money  `json:"price"`
+	ThumbnailUrl string `json:"thumbnail_url"`
+	DateAdded    string `json:"date_added"`
+	UnitsSold    int    `json:"units_sold"`    // total units sold of all sizes
+	ProductViews int    `json:"product_views"` // number of times product viewed
 }
 
 // Transaction represents a monetary transaction between the store and a user.
 type Transaction struct {
-	TransactionID     string  `json:"transaction_id"`
-	OrderID           string  `json:"order_id"`
-	UserID            string  `json:"user_id"`
-	Timestamp         string  `json:"timestamp"`
-	PaymentMethod     string  `json:"payment_method"`
-	PaymentTxID       string  `json:"payment_tx_id"`
-	SalesSubtotal     float32 `json:"sales_subtotal"`
-	ShippingCost      float32 `json:"shipping_cost"`
-	SalesTax          float32 `json:"sales_tax"`
-	ChargesAndFees    float32 `json:"charges_and_fees"` // stripe processing, other fees
-	TotalAmount       float32 `json:"total_amount"`
-	PaymentStatus     string  `json:"payment_status"` // SUCCESS, FAILED, DISPUTED, REFUNDED
-	PaymentMessage    string  `json:"payment_message"`
-	CorrespondingTxID string  `json:"corresponding_tx_id"` // link to corresponding transaction for refunds
+	TransactionID     string     `json:"transaction_id"`
+	OrderID           string     `json:"order_id"`
+	UserID            string     `json:"user_id"`
+	Timestamp         string     `json:"timestamp"`
+	PaymentMethod     string     `json:"payment_method"`
+	PaymentTxID       string     `json:"payment_tx_id"`
+	SalesSubtotal     Money      `json:"sales_subtotal"`
+	Discounts         []Discount `json:"discounts"`
+	DiscountTotal     Money      `json:"discount_total"`
+	ShippingCost      Money      `json:"shipping_cost"`
+	SalesTax          Money      `json:"sales_tax"`
+	ChargesAndFees    Money      `json:"charges_and_fees"` // stripe processing, other fees
+	TotalAmount       Money      `json:"total_amount"`
+	PaymentStatus     string     `json:"payment_status"` // SUCCESS, FAILED, IN_PROGRESS
+	PaymentMessage    string     `json:"payment_message"`
+	RefundStatus      string     `json:"refund_status"`       // status of the latest refund of the sale (see PaymentStatusField)
+	DisputeStatus     string     `json:"dispute_status"`      // set when the sale is disputed
+	CorrespondingTxID string     `json:"corresponding_tx_id"` // link to corresponding transaction for refunds
 }
 
 // SetHashID sets the t.TransctionID field with a MD5 hash generated from the t.Timestamp value
@@ -186,57 +210,74 @@ func (t *Transaction) SetHashID() {
 
 // Order represents a customer order for a store item.
 type Order struct {
-	OrderID         string      `json:"order_id"`
-	TransactionID   string      `json:"transaction_id"`
-	StripeChargeID  string      `json:"stripe_charge_id"`
-	UserID          string      `json:"user_id"`
-	UserEmail       string      `json:"user_email"`
-	Complete        bool        `json:"status"`    // denotes whether order is complete after creation at initial checkout page
-	Expired         bool        `json:"expired"`   // denotes whether order is expired (checkout timeout / cart updated)
-	TtlMs           int         `json:"ttl_ms"`    // time to live in ms
-	InitTime        string      `json:"init_time"` // timestamp when order is created - format to/from time.Time obj
-	Items           []*CartItem `json:"items"`
-	TotalItems      int         `json:"total_items"` // sum of quantities of all items in cart
-	SalesSubtotal   float32     `json:"sales_subtotal"`
-	ShippingCost    float32     `json:"shipping_cost"`
-	SalesTax        float32     `json:"sales_tax"`
-	ChargesAndFees  float32     `json:"charges_and_fees"` // stripe processing, other fees
-	OrderTotal      float32     `json:"order_total"`
-	OrderDate       string      `json:"order_date"`
-	TxTimestamp     string      `json:"transaction_timestamp"`
-	PaymentStatus   string      `json:"payment_status"`
-	Paid            bool        `json:"paid"`
-	BillingAddress  Address     `json:"billing_address"`
-	ShippingAddress Address     `json:"shipping_address"`
-	Shipped         bool        `json:"shipped"`
-	Delivered       bool        `json:"delivered"`
-	OrderStatus     string      `json:"order_status"`
+	OrderID            string        `json:"order_id"`
+	TransactionID      string        `json:"transaction_id"`
+	StripeChargeID     string        `json:"stripe_charge_id"`
+	UserID             string        `json:"user_id"`
+	UserEmail          string        `json:"user_email"`
+	Complete           bool          `json:"status"`    // denotes whether order is complete after creation at initial checkout page
+	Expired            bool          `json:"expired"`   // denotes whether order is expired (checkout timeout / cart updated)
+	TtlMs              int           `json:"ttl_ms"`    // time to live in ms
+	InitTime           string        `json:"init_time"` // timestamp when order is created - format to/from time.Time obj
+	Items              []*CartItem   `json:"items"`
+	TotalItems         int           `json:"total_items"` // sum of quantities of all items in cart
+	SalesSubtotal      Money         `json:"sales_subtotal"`
+	Discounts          []Discount    `json:"discounts"`      // promotions applied to the order
+	DiscountTotal      Money         `json:"discount_total"` // sum of Discounts amounts
+	ShippingCost       Money         `json:"shipping_cost"`
+	SalesTax           Money         `json:"sales_tax"`
+	TaxBreakdown       TaxBreakdown  `json:"tax_breakdown"`    // set once the shipping address is known
+	ChargesAndFees     Money         `json:"charges_and_fees"` // stripe processing, other fees
+	OrderTotal         Money         `json:"order_total"`
+	OrderWeightOzs     float32       `json:"order_weight_ozs"`
+	OrderWeightLbs     float32       `json:"order_weight_lbs"`
+	OrderWeightKgs     float32       `json:"order_weight_kgs"`
+	OrderDate          string        `json:"order_date"`
+	TxTimestamp        string        `json:"transaction_timestamp"`
+	PaymentStatus      string        `json:"payment_status"`
+	RefundStatus       string        `json:"refund_status"`  // status of the latest refund of the order's sale
+	DisputeStatus      string        `json:"dispute_status"` // set when the order's sale is disputed
+	Paid               bool          `json:"paid"`
+	BillingAddress     Address       `json:"billing_address"`
+	ShippingAddress    Address       `json:"shipping_address"`
+	Shipped            bool          `json:"shipped"`
+	Delivered          bool          `json:"delivered"`
+	DeliveredAt        int64         `json:"delivered_at"` // unix timestamp (s) of delivery; 0 if not delivered
+	OrderStatus        string        `json:"order_status"`
+	Version            int           `json:"version"`                         // incremented on each write
+	ExclusiveRefunds   []string      `json:"exclusive_refunds,omitempty"`     // size IDs of digital items refunded because an exclusive license was sold to another order
+	Contracts          []ContractRef `json:"contracts,omitempty"`             // signed contracts of digital items; omitted when empty so contracts can be appended
+	PromotionsReleased bool          `json:"promotions_released,omitempty"`   // set when the promotions redeemed by a failed payment are released
+	Published          bool          `json:"fulfillment_published,omitempty"` // set when the paid order is published to the Fulfillment topic
 }
 
 // Receipt represents a receipt sent to customers after placing orders.
 type Receipt struct {
-	UserID          string      `json:"user_id"`
-	OrderID         string      `json:"order_id"`
-	TransactionID   string      `json:"transaction_id"`
-	UserEmail       string      `json:"user_email"`
-	OrderSummary    []*CartItem `json:"order_summary"`
-	SalesSubtotal   float32     `json:"sales_subtotal"`
-	ShippingCost    float32     `json:"shipping_cost"`
-	SalesTax        float32     `json:"sales_tax"`
-	ChargesAndFees  float32     `json:"charges_and_fees"` // stripe processing, other fees
-	OrderTotal      float32     `json:"order_total"`
-	BillingAddress  Address     `json:"billing_address"`  // Address, City, State, ZIP
-	ShippingAddress Address     `json:"shipping_address"` // Address, City, State, ZIP
+	UserID          string       `json:"user_id"`
+	OrderID         string       `json:"order_id"`
+	TransactionID   string       `json:"transaction_id"`
+	UserEmail       string       `json:"user_email"`
+	OrderSummary    []*CartItem  `json:"order_summary"`
+	SalesSubtotal   Money        `json:"sales_subtotal"`
+	Discounts       []Discount   `json:"discounts"`
+	DiscountTotal   Money        `json:"discount_total"`
+	ShippingCost    Money        `json:"shipping_cost"`
+	SalesTax        Money        `json:"sales_tax"`
+	TaxBreakdown    TaxBreakdown `json:"tax_breakdown"`
+	ChargesAndFees  Money        `json:"charges_and_fees"` // stripe processing, other fees
+	OrderTotal      Money        `json:"order_total"`
+	BillingAddress  Address      `json:"billing_address"`  // Address, City, State, ZIP
+	ShippingAddress Address      `json:"shipping_address"` // Address, City, State, ZIP
 }
 
 // OrderSummary is used to get summary information used during order fulfillment.
 type OrderSummary struct {
-	UserEmail  string  `json:"user_email"`
-	UserID     string  `json:"user_id"`
-	OrderID    string  `json:"order_id"`
-	OrderDate  string  `json:"order_date"`
-	OrderTotal float32 `json:"order_total"`
-	TotalItems int     `json:"total_items"`
+	UserEmail  string `json:"user_email"`
+	UserID     string `json:"user_id"`
+	OrderID    string `json:"order_id"`
+	OrderDate  string `json:"order_date"`
+	OrderTotal Money  `json:"order_total"`
+	TotalItems int    `json:"total_items"`
 }
 
 // NewReceipt creates a new *Receipt object from the Order.
@@ -248,8 +289,11 @@ func (o *Order) NewReceipt() *Receipt {
 	r.UserEmail = o.UserEmail
 	r.OrderSummary = o.Items
 	r.SalesSubtotal = o.SalesSubtotal
+	r.Discounts = o.Discounts
+	r.DiscountTotal = o.DiscountTotal
 	r.ShippingCost = o.ShippingCost
 	r.SalesTax = o.SalesTax
+	r.TaxBreakdown = o.TaxBreakdown
 	r.ChargesAndFees = o.ChargesAndFees
 	r.OrderTotal = o.OrderTotal
 	r.BillingAddress = o.BillingAddress
@@ -257,6 +301,12 @@ func (o *Order) NewReceipt() *Receipt {
 	return r
 }
 
+// UpdateTotal sets o.OrderTotal from the order's subtotal, discounts, shipping cost, sales tax
+// and fees.
+func (o *Order) UpdateTotal() {
+	o.OrderTotal = o.SalesSubtotal.Sub(o.DiscountTotal).Add(o.ShippingCost).Add(o.SalesTax).Add(o.ChargesAndFees)
+}
+
 // NewSummary creates a new *OrderSummary object from the Order.
 func (o *Order) NewSummary() *OrderSummary {
 	os := &OrderSummary{
@@ -272,14 +322,20 @@ func (o *Order) NewSummary() *OrderSummary {
 
 // Return represents a customer return request
 type Return struct {
-	UserID        string         `json:"user_id"`
+	UserID        string         `json:"user_id"` // pk
 	UserEmail     string         `json:"user_email"`
-	ReturnID      string         `json:"return_id"`
+	ReturnID      string         `json:"return_id"` // sk
 	OrderID       string         `json:"order_id"`
 	OriginalTxID  string         `json:"original_tx_id"`
-	ReturnTxID    string         `json:"return_tx_id"`
-	ReturnItems   map[string]int `json:"return_items"` // return item IDs: quantity
+	ReturnTxID    string         `json:"return_tx_id"` // refund transaction ID
+	ReturnItems   map[string]int `json:"return_items"` // return item size IDs: quantity
+	Reason        string         `json:"reason"`
+	Message       string         `json:"message"` // message to customer from store admin
+	RequestDate   string         `json:"request_date"`
+	RefundAmount  Money          `json:"refund_amount"`
+	ReturnLabel   ShippingLabel  `json:"return_label"`
 	Open          bool           `json:"open"`
+	Refunding     bool           `json:"refunding"` // refund claimed by the store admin; set before the refund is issued
 	Refunded      bool           `json:"refunded"`
 	ItemsReceived bool           `json:"items_received"`
 	Complete      bool           `json:"complete"`
@@ -300,11 +356,13 @@ type Customer struct {
 	Purchases       int      `json:"purchases"`     // total number of purchases
 	Returns         int      `json:"returns"`       // total number of returns
 	Disputes        int      `json:"disputes"`      // total number of disputes
-	TotalSpent      float32  `json:"total_spent"`   // total USD spent
+	TotalSpent      Money    `json:"total_spent"`   // total amount spent
 	Orders          int      `json:"orders"`        // total number of orders created
 	OpenOrder       bool     `json:"open_order"`    // denotes if customer has order in progress
 	OpenOrderIDs    []string `json:"open_order_id"` // IDs of open orders
 	JoinDate        string   `json:"join_date"`
+	Guest           bool     `json:"guest"`   // created by guest checkout; no account
+	Version         int      `json:"version"` // incremented on each write
 }
 
 // Address represents a mailling or billing address.
@@ -321,15 +379,3 @@ type Address struct {
 	PhoneNumber  string `json:"phone_number"`
 	Email        string `json:"email"`
 }
-
-// ReturnAddress contains the business's return address for shipping operations.
-var ReturnAddress = Address{
-	Company:      "ACamoPRJCT",
-	AddressLine1: "3204 Caraway Court",
-	City:         "Modesto",
-	State:        "CA",
-	Country:      "United States",
-	Zip:          "95355",
-	PhoneNumber:  "209-495-5130",
-	Email:        "sgarza1209@gmail.com",
-}
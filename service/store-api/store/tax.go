@@ -0,0 +1,22 @@
+package store
+
+// TaxBreakdown represents the sales tax calculated for an order shipped to a tax jurisdiction.
+type TaxBreakdown struct {
+	Jurisdiction    string    `json:"jurisdiction"` // state or state & ZIP code (ex: 'CA', 'CA-90001')
+	Rate            float64   `json:"rate"`         // combined sales tax rate (ex: .0725)
+	ItemsTaxable    Money     `json:"items_taxable"`
+	ShippingTaxable bool      `json:"shipping_taxable"`
+	ShippingAmount  Money     `json:"shipping_amount"` // taxable shipping cost
+	ItemsTax        Money     `json:"items_tax"`
+	ShippingTax     Money     `json:"shipping_tax"`
+	TotalTax        Money     `json:"total_tax"`
+	Lines           []TaxLine `json:"lines"`
+}
+
+// TaxLine represents the taxability of an order item.
+type TaxLine struct {
+	SizeID        string `json:"size_id"`
+	TaxCategory   string `json:"tax_category"` // ex: 'clothing', 'digital'
+	Taxable       bool   `json:"taxable"`
+	TaxableAmount Money  `json:"taxable_amount"` // item subtotal less allocated item discounts
+}
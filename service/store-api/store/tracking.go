@@ -0,0 +1,140 @@
+package store
+
+import (
+	"time"
+)
+
+// Tracking statuses. Carrier specific statuses are mapped to these values.
+const (
+	TrackingStatusPreTransit = "PRE_TRANSIT" // label created; not yet scanned by the carrier
+	TrackingStatusTransit    = "TRANSIT"
+	TrackingStatusDelivered  = "DELIVERED"
+	TrackingStatusReturned   = "RETURNED" // returned to sender
+	TrackingStatusFailure    = "FAILURE"  // delivery exception
+	TrackingStatusUnknown    = "UNKNOWN"
+)
+
+// TrackingSubstatusOutForDelivery is the substatus of TRANSIT events for parcels out for delivery.
+const TrackingSubstatusOutForDelivery = "out_for_delivery"
+
+// Tracking notices sent to customers
+const (
+	TrackingNoticeOutForDelivery = "OUT_FOR_DELIVERY"
+	TrackingNoticeDelivered      = "DELIVERED"
+	TrackingNoticeException      = "EXCEPTION"
+)
+
+// trackingDateLayout is the timestamp format of tracking status dates ('MM-DD-YYYY HH:MM:SS' UTC).
+const trackingDateLayout = "01-02-2006 15:04:05"
+
+// TrackingEvent contains a tracking status of a shipping label reported by the carrier.
+type TrackingEvent struct {
+	ID             string `json:"id"` // carrier tracking status ID
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	Status         string `json:"status"`
+	Substatus      string `json:"substatus"` // carrier specific (ex: 'out_for_delivery')
+	StatusDetails  string `json:"status_details"`
+	StatusDate     string `json:"status_date"` // 'MM-DD-YYYY HH:MM:SS' UTC
+}
+
+// TrackingRef links a shipping label's tracking number to its shipment, so tracking events can
+// be matched to orders.
+type TrackingRef struct {
+	TrackingNumber string `json:"tracking_number"`
+	UserID         string `json:"user_id"`
+	OrderID        string `json:"order_id"`
+	LabelID        string `json:"label_id"`
+}
+
+// TrackingRefs returns the TrackingRef of each of the shipment's labels.
+func (s *Shipment) TrackingRefs() []TrackingRef {
+	refs := []TrackingRef{}
+	for _, l := range s.Labels {
+		if l.TrackingNumber == "" {
+			continue
+		}
+		refs = append(refs, TrackingRef{TrackingNumber: l.TrackingNumber, UserID: s.UserID, OrderID: s.OrderID, LabelID: l.LabelID})
+	}
+	return refs
+}
+
+// Notice returns the tracking notice sent to the customer for the event, or an empty string
+// if the event does not notify the customer.
+func (e TrackingEvent) Notice() string {
+	switch e.Status {
+	case TrackingStatusDelivered:
+		return TrackingNoticeDelivered
+	case TrackingStatusFailure, TrackingStatusReturned:
+		return TrackingNoticeException
+	case TrackingStatusTransit:
+		if e.Substatus == TrackingSubstatusOutForDelivery {
+			return TrackingNoticeOutForDelivery
+		}
+	}
+	return ""
+}
+
+// Time returns the time of the event's status date, or the zero time if the date is invalid.
+func (e TrackingEvent) Time() time.Time {
+	t, err := time.Parse(trackingDateLayout, e.StatusDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// same returns true if the events report the same status of the tracking number.
+func (e TrackingEvent) same(o TrackingEvent) bool {
+	if e.ID != "" && o.ID != "" {
+		return e.ID == o.ID
+	}
+	return e.TrackingNumber == o.TrackingNumber && e.Status == o.Status && e.Substatus == o.Substatus && e.StatusDate == o.StatusDate
+}
+
+// AddTracking appends the event to the shipment's tracking history and updates the tracking
+// status of the event's label, unless the label has a more recent status. Returns false if
+// the event was already recorded or the tracking number is not one of the shipment's labels.
+func (s *Shipment) AddTracking(e TrackingEvent) bool {
+	label := -1
+	for i, l := range s.Labels {
+		if l.TrackingNumber == e.TrackingNumber {
+			label = i
+		}
+	}
+	if label < 0 {
+		return false
+	}
+	latest := time.Time{}
+	for _, h := range s.TrackingHistory {
+		if h.same(e) {
+			return false
+		}
+		if h.TrackingNumber == e.TrackingNumber && h.Time().After(latest) {
+			latest = h.Time()
+		}
+	}
+	s.TrackingHistory = append(s.TrackingHistory, e)
+	if !e.Time().Before(latest) {
+		s.Labels[label].TrackingStatus = e.Status
+	}
+	return true
+}
+
+// Delivered returns true if each of the shipment's labels has been delivered.
+func (s *Shipment) Delivered() bool {
+	if len(s.Labels) == 0 {
+		return false
+	}
+	for _, l := range s.Labels {
+		if l.TrackingStatus != TrackingStatusDelivered {
+			return false
+		}
+	}
+	return true
+}
+
+// ReturnWindowClosed returns true if the order was delivered more than 'window' before now.
+func (o *Order) ReturnWindowClosed(now time.Time, window time.Duration) bool {
+	return o.OrderStatus == OrderStatusDelivered && o.DeliveredAt > 0 && !time.Unix(o.DeliveredAt, 0).Add(window).After(now)
+}
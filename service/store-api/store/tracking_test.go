@@ -0,0 +1,85 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddTracking(t *testing.T) {
+	s := &Shipment{Labels: []ShippingLabel{{LabelID: "l1", TrackingNumber: "T1"}, {LabelID: "l2", TrackingNumber: "T2"}}}
+	var tests = []struct {
+		event      TrackingEvent
+		want       bool
+		wantStatus []string // label tracking statuses
+	}{
+		{TrackingEvent{ID: "e1", TrackingNumber: "T1", Status: TrackingStatusTransit, StatusDate: "10-01-2026 08:00:00"}, true,
+			[]string{TrackingStatusTransit, ""}},
+		{TrackingEvent{ID: "e1", TrackingNumber: "T1", Status: TrackingStatusTransit, StatusDate: "10-01-2026 08:00:00"}, false, // duplicate
+			[]string{TrackingStatusTransit, ""}},
+		{TrackingEvent{ID: "e2", TrackingNumber: "T9", Status: TrackingStatusDelivered}, false, // unknown tracking number
+			[]string{TrackingStatusTransit, ""}},
+		{TrackingEvent{ID: "e3", TrackingNumber: "T1", Status: TrackingStatusDelivered, StatusDate: "10-03-2026 14:00:00"}, true,
+			[]string{TrackingStatusDelivered, ""}},
+		{TrackingEvent{ID: "e4", TrackingNumber: "T1", Status: TrackingStatusTransit, StatusDate: "10-02-2026 09:00:00"}, true, // out of order
+			[]string{TrackingStatusDelivered, ""}},
+		{TrackingEvent{TrackingNumber: "T2", Status: TrackingStatusDelivered, StatusDate: "10-03-2026 15:00:00"}, true,
+			[]string{TrackingStatusDelivered, TrackingStatusDelivered}},
+		{TrackingEvent{TrackingNumber: "T2", Status: TrackingStatusDelivered, StatusDate: "10-03-2026 15:00:00"}, false, // duplicate w/o ID
+			[]string{TrackingStatusDelivered, TrackingStatusDelivered}},
+	}
+	for i, test := range tests {
+		if got := s.AddTracking(test.event); got != test.want {
+			t.Errorf("FAIL - %d: %v; want: %v", i, got, test.want)
+		}
+		for j, l := range s.Labels {
+			if l.TrackingStatus != test.wantStatus[j] {
+				t.Errorf("FAIL - %d: label %s %s; want: %s", i, l.LabelID, l.TrackingStatus, test.wantStatus[j])
+			}
+		}
+	}
+	if len(s.TrackingHistory) != 4 || !s.Delivered() {
+		t.Errorf("FAIL: %v, %v; want: 4 events, delivered", s.TrackingHistory, s.Delivered())
+	}
+	if (&Shipment{}).Delivered() {
+		t.Errorf("FAIL: shipment without labels delivered")
+	}
+}
+
+func TestTrackingNotice(t *testing.T) {
+	var tests = []struct {
+		event TrackingEvent
+		want  string
+	}{
+		{TrackingEvent{Status: TrackingStatusPreTransit}, ""},
+		{TrackingEvent{Status: TrackingStatusTransit}, ""},
+		{TrackingEvent{Status: TrackingStatusTransit, Substatus: TrackingSubstatusOutForDelivery}, TrackingNoticeOutForDelivery},
+		{TrackingEvent{Status: TrackingStatusDelivered}, TrackingNoticeDelivered},
+		{TrackingEvent{Status: TrackingStatusFailure}, TrackingNoticeException},
+		{TrackingEvent{Status: TrackingStatusReturned}, TrackingNoticeException},
+		{TrackingEvent{Status: TrackingStatusUnknown}, ""},
+	}
+	for _, test := range tests {
+		if got := test.event.Notice(); got != test.want {
+			t.Errorf("FAIL: %v: %s; want: %s", test.event, got, test.want)
+		}
+	}
+}
+
+func TestReturnWindowClosed(t *testing.T) {
+	now := time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC)
+	window := 30 * 24 * time.Hour
+	var tests = []struct {
+		order Order
+		want  bool
+	}{
+		{Order{OrderStatus: OrderStatusDelivered, DeliveredAt: now.Add(-window).Unix()}, true},
+		{Order{OrderStatus: OrderStatusDelivered, DeliveredAt: now.Add(-window).Unix() + 1}, false},
+		{Order{OrderStatus: OrderStatusDelivered}, false}, // delivery time not recorded
+		{Order{OrderStatus: OrderStatusOpenReturn, DeliveredAt: now.Add(-2 * window).Unix()}, false},
+	}
+	for _, test := range tests {
+		if got := test.order.ReturnWindowClosed(now, window); got != test.want {
+			t.Errorf("FAIL: %s %d: %v; want: %v", test.order.OrderStatus, test.order.DeliveredAt, got, test.want)
+		}
+	}
+}
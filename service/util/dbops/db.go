@@ -1,29 +1,53 @@
 package dbops
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strconv"
 	"sync"
-
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
 	"github.com/go-aws/go-dynamo/dynamo"
 	"github.com/tpillz-presents/service/store-api/store"
 )
 
 // DB Table Environment Variable Names
 const (
+	EnvarContractsTable         = "DB_CONTRACTS_TABLE"
 	EnvarCustomersTable         = "DB_CUSTOMERS_TABLE"
+	EnvarDownloadsTable         = "DB_DOWNLOADS_TABLE"
+	EnvarHoldsTable             = "DB_INVENTORY_HOLDS_TABLE"
+	EnvarLocationsTable         = "DB_LOCATIONS_TABLE"
 	EnvarOrdersTable            = "DB_ORDERS_TABLE"
 	EnvarOpenOrdersTable        = "DB_OPEN_ORDERS_TABLE"
 	EnvarParcelsTable           = "DB_PARCELS_TABLE"
+	EnvarPromotionsTable        = "DB_PROMOTIONS_TABLE"
+	EnvarRedemptionsTable       = "DB_PROMOTION_REDEMPTIONS_TABLE"
+	EnvarReturnsTable           = "DB_RETURNS_TABLE"
 	EnvarShipmentsTable         = "DB_SHIPMENTS_TABLE"
+	EnvarShippingRulesTable     = "DB_SHIPPING_RULES_TABLE"
 	EnvarShoppingCartsTable     = "DB_SHOPPING_CARTS_TABLE"
 	EnvarStoreItemsTable        = "DB_STORE_ITEMS_TABLE"
 	EnvarStoreItemsIndexTable   = "DB_STORE_ITEMS_INDEX_TABLE"
 	EnvarStoreItemsSummaryTable = "DB_STORE_ITEMS_SUMMARY_TABLE"
+	EnvarTrackingTable          = "DB_TRACKING_TABLE"
 	EnvarTransactionsTable      = "DB_TRANSACTIONS_TABLE"
+	EnvarWebhookEventsTable     = "DB_WEBHOOK_EVENTS_TABLE"
 )
 
+// EnvarDynamoEndpoint contains the name of the environment variable holding an alternate
+// DynamoDB endpoint URL (ex: http://localhost:8000 for DynamoDB Local).
+const EnvarDynamoEndpoint = "DYNAMODB_ENDPOINT"
+
 // CustomersTable contains the name of the Users Table.
 var CustomersTable = os.Getenv(EnvarCustomersTable)
 
@@ -102,9 +126,97 @@ const OpenOrdersPK = "user_id"
 // OpenOrdersSK contains the sort key name of the OpenOrders table.
 const OpenOrdersSK = "order_id"
 
+// WebhookEventsTable contains the name of the Webhook Events table, used to deduplicate
+// payment provider webhook events.
+func WebhookEventsTable() string { return os.Getenv(EnvarWebhookEventsTable) }
+
+// WebhookEventsPK contains the primary key name of the Webhook Events table.
+const WebhookEventsPK = "event_id"
+
+// ReturnsTable contains the name of the Returns table.
+func ReturnsTable() string { return os.Getenv(EnvarReturnsTable) }
+
+// ReturnsPK contains the primary key name of the Returns table.
+const ReturnsPK = "user_id"
+
+// ReturnsSK contains the sort key name of the Returns table.
+const ReturnsSK = "return_id"
+
+// PromotionsTable contains the name of the Promotions table.
+func PromotionsTable() string { return os.Getenv(EnvarPromotionsTable) }
+
+// PromotionsPK contains the primary key name of the Promotions table.
+const PromotionsPK = "code"
+
+// RedemptionsTable contains the name of the Promotion Redemptions table, which records
+// the number of times each customer has redeemed a promotion.
+func RedemptionsTable() string { return os.Getenv(EnvarRedemptionsTable) }
+
+// RedemptionsPK contains the primary key name of the Promotion Redemptions table.
+const RedemptionsPK = "code"
+
+// RedemptionsSK contains the sort key name of the Promotion Redemptions table.
+const RedemptionsSK = "user_id"
+
+// ShippingRulesTable contains the name of the Shipping Rules table, which contains the rules
+// applied to the carrier rates offered to customers.
+func ShippingRulesTable() string { return os.Getenv(EnvarShippingRulesTable) }
+
+// ShippingRulesPK contains the primary key name of the Shipping Rules table.
+const ShippingRulesPK = "rule_id"
+
+// LocationsTable contains the name of the Locations table, which contains the warehouses and
+// studios orders are shipped from and their inventory.
+func LocationsTable() string { return os.Getenv(EnvarLocationsTable) }
+
+// LocationsPK contains the primary key name of the Locations table.
+const LocationsPK = "location_id"
+
+// TrackingTable contains the name of the Tracking table, which maps the tracking numbers of
+// shipping labels to their shipments.
+func TrackingTable() string { return os.Getenv(EnvarTrackingTable) }
+
+// TrackingPK contains the primary key name of the Tracking table.
+const TrackingPK = "tracking_number"
+
+// HoldsTable contains the name of the Inventory Holds table.
+func HoldsTable() string { return os.Getenv(EnvarHoldsTable) }
+
+// HoldsPK contains the primary key name of the Inventory Holds table.
+const HoldsPK = "order_id"
+
+// HoldsSK contains the sort key name of the Inventory Holds table.
+const HoldsSK = "size_id"
+
+// DownloadsTable contains the name of the Downloads table, which contains the download grants
+// of purchased digital items.
+func DownloadsTable() string { return os.Getenv(EnvarDownloadsTable) }
+
+// DownloadsPK contains the primary key name of the Downloads table.
+const DownloadsPK = "user_id"
+
+// DownloadsSK contains the sort key name of the Downloads table.
+const DownloadsSK = "grant_id"
+
+// ContractsTable contains the name of the Contracts table, which contains the lease agreements
+// of ordered digital items.
+func ContractsTable() string { return os.Getenv(EnvarContractsTable) }
+
+// ContractsPK contains the primary key name of the Contracts table.
+const ContractsPK = "user_id"
+
+// ContractsSK contains the sort key name of the Contracts table.
+const ContractsSK = "contract_id"
+
 // ErrConditionCheckFail contains the error code values for failed conditional writes.
 const ErrConditionalCheck = "ERR_CONDITIONAL_CHECK"
 
+// ErrOrderNotFound contains the error code for operations on orders that do not exist.
+const ErrOrderNotFound = "ERR_ORDER_NOT_FOUND"
+
+// ErrReturnNotFound contains the error code for operations on returns that do not exist.
+const ErrReturnNotFound = "ERR_RETURN_NOT_FOUND"
+
 // Table contains the necessary information to access the service's DynamoDB tables.
 // Primary & Sort key types are hardcoded as string format.
 type Table struct {
@@ -132,8 +244,16 @@ func NewTable(name, primaryKey, sortKey string) Table {
 
 // InitDB initializes a new DynamoDB session and creates a dynamo.DbInfo object with
 // the defined Table objects to be used by the program.
+// Requests are sent to the endpoint set in the DYNAMODB_ENDPOINT environment variable if set,
+// such as a DynamoDB Local instance used for offline testing.
 func InitDB(tables []Table) *dynamo.DbInfo {
 	svc := dynamo.InitSesh()
+	if endpoint := os.Getenv(EnvarDynamoEndpoint); endpoint != "" {
+		sess := session.Must(session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		}))
+		svc = dynamodb.New(sess, aws.NewConfig().WithEndpoint(endpoint))
+	}
 	db := dynamo.InitDbInfo()
 	db.SetSvc(svc)
 	for _, table := range tables {
@@ -191,24 +311,7 @@ func PutStoreItem(DB *dynamo.DbInfo, item *store.StoreItem) error {
 }
 
 func UpdateStoreItem(DB *dynamo.DbInfo, subcat, itemID, field string, value interface{}) error {
-	// create and set update query
-	q := dynamo.CreateNewQueryObj(subcat, itemID)
-	q.UpdateCurrent(field, value)
-
-	// build expression
-	update := dynamo.NewUpdateExpr()
-	update.Set(field, value)
-
-	eb := dynamo.NewExprBuilder()
-	eb.SetUpdate(update)
-	expression, err := eb.BuildExpression()
-	if err != nil {
-		log.Printf("UpdateStoreItem failed: %v", err)
-		return err
-	}
-
-	// update DB object
-	err = dynamo.UpdateItem(DB.Svc, q, DB.Tables[StoreItemsTable()], expression)
+	err := UpdateItem(DB, NewStoreItemUpdate(subcat, itemID).Set(field, value))
 	if err != nil {
 		log.Printf("UpdateStoreItem failed: %v", err)
 		return err
@@ -226,6 +329,28 @@ func DeleteStoreItem(DB *dynamo.DbInfo, subcategory, itemID string) error {
 	return nil
 }
 
+// ScanStoreItems returns each StoreItem in the StoreItemsTable.
+func ScanStoreItems(DB *dynamo.DbInfo) ([]*store.StoreItem, error) {
+	items := []*store.StoreItem{}
+	input := &dynamodb.ScanInput{TableName: aws.String(StoreItemsTable())}
+	err := DB.Svc.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, av := range page.Items {
+			item := &store.StoreItem{}
+			if err := dynamodbattribute.UnmarshalMap(av, item); err != nil {
+				log.Printf("ScanStoreItems failed: %v", err)
+				continue
+			}
+			items = append(items, item)
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("ScanStoreItems failed: %v", err)
+		return items, err
+	}
+	return items, nil
+}
+
 // GetStoreItem retreives a StoreItem object from the StoreItemsTable.
 func GetStoreItemIndex(DB *dynamo.DbInfo, subcategory string) (*store.StoreItemIndex, error) {
 	q := dynamo.CreateNewQueryObj(subcategory, "")
@@ -271,28 +396,11 @@ func PutStoreItemSummary(DB *dynamo.DbInfo, item *store.StoreItemSummary) error
 }
 
 func UpdateStoreItemSummary(DB *dynamo.DbInfo, subcat, itemID, field string, value interface{}) error {
-	// create and set update query
-	q := dynamo.CreateNewQueryObj(subcat, itemID)
-	q.UpdateCurrent(field, value)
-
-	// build expression
-	update := dynamo.NewUpdateExpr()
-	update.Set(field, value)
-
-	eb := dynamo.NewExprBuilder()
-	eb.SetUpdate(update)
-	expression, err := eb.BuildExpression()
+	err := UpdateItem(DB, NewStoreItemSummaryUpdate(subcat, itemID).Set(field, value))
 	if err != nil {
 		log.Printf("UpdateStoreItemSummary failed: %v", err)
 		return err
 	}
-
-	// update DB object
-	err = dynamo.UpdateItem(DB.Svc, q, DB.Tables[StoreItemsSummaryTable()], expression)
-	if err != nil {
-		log.Printf("UpdateStoreIteSummary failed: %v", err)
-		return err
-	}
 	return nil
 }
 
@@ -307,7 +415,7 @@ func DeleteStoreItemSummary(DB *dynamo.DbInfo, subcategory, itemID string) error
 }
 
 // Scan StoreItemSummary objects for a given browsing category.
-// TO DO: ADD LOGIC FOR PAGINATION
+// See QueryItemsForCategory and BrowseItems for sorted & paginated browsing.
 func ScanItemsForCategory(DB *dynamo.DbInfo, subcat string) ([]store.StoreItemSummary, error) {
 	items := []store.StoreItemSummary{}
 	model := store.StoreItemSummary{}
@@ -332,6 +440,62 @@ func ScanItemsForCategory(DB *dynamo.DbInfo, subcat string) ([]store.StoreItemSu
 	return items, nil
 }
 
+// QueryItemsForCategory queries the StoreItemsSummary table by partition key and returns each
+// StoreItemSummary in the given subcategory. Price ranges are applied by BrowseItems (see
+// store.Paginate).
+func QueryItemsForCategory(DB *dynamo.DbInfo, subcat string) ([]store.StoreItemSummary, error) {
+	items := []store.StoreItemSummary{}
+	keyCond := expression.Key(StoreItemSummaryPK).Equal(expression.Value(subcat))
+	builder := expression.NewBuilder().WithKeyCondition(keyCond)
+	expr, err := builder.Build()
+	if err != nil {
+		log.Printf("QueryItemsForCategory failed: %v", err)
+		return items, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(StoreItemsSummaryTable()),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	var unmarshalErr error
+	err = DB.Svc.QueryPages(input, func(page *dynamodb.QueryOutput, last bool) bool {
+		for _, item := range page.Items {
+			summary := store.StoreItemSummary{}
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(item, &summary); unmarshalErr != nil {
+				return false
+			}
+			items = append(items, summary)
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		log.Printf("QueryItemsForCategory failed: %v", err)
+		return items, err
+	}
+	return items, nil
+}
+
+// BrowseItems returns the page of StoreItemSummary objects in the given subcategory for the
+// browse options' cursor, sort order and price range. Returns ErrInvalidBrowseOptions or
+// ErrInvalidCursor if the options are invalid. Each page reads every item summary of the
+// subcategory, as items are sorted and filtered by store.Paginate rather than by the query.
+func BrowseItems(s Store, subcat string, opts store.BrowseOptions) (store.BrowsePage, error) {
+	if err := opts.Validate(); err != nil {
+		return store.BrowsePage{Items: []store.StoreItemSummary{}}, err
+	}
+	items, err := s.QueryItemsForCategory(subcat)
+	if err != nil {
+		log.Printf("BrowseItems failed: %v", err)
+		return store.BrowsePage{Items: []store.StoreItemSummary{}}, err
+	}
+	return store.Paginate(items, opts)
+}
+
 // GetShopping cart retreives a ShoppingCart object from the ShoppingCartsTable (primary key only).
 func GetShoppingCart(DB *dynamo.DbInfo, userID string) (*store.ShoppingCart, error) {
 	q := dynamo.CreateNewQueryObj(userID, "")
@@ -347,20 +511,133 @@ func GetShoppingCart(DB *dynamo.DbInfo, userID string) (*store.ShoppingCart, err
 	return item.(*store.ShoppingCart), nil
 }
 
-// PutShoppingCart puts a new ShoppingCart object to the ShoppingCartsTable.
+// PutShoppingCart puts a ShoppingCart object to the ShoppingCartsTable on the condition that the
+// stored cart's version equals the cart's version, and increments the cart's version. Returns a
+// *VersionConflictError if the cart was modified since it was read.
 func PutShoppingCart(DB *dynamo.DbInfo, cart *store.ShoppingCart) error {
-	err := dynamo.CreateItem(DB.Svc, cart, DB.Tables[ShoppingCartsTable()])
+	cart.Version++
+	err := putVersioned(DB, ShoppingCartsTable(), cart.UserID, cart, cart.Version-1)
 	if err != nil {
+		cart.Version--
 		log.Printf("PutShoppingCart failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ScanIdleCarts scans the ShoppingCartsTable for carts with a recipient email that have not been
+// updated since before (unix timestamp (s)).
+func ScanIdleCarts(DB *dynamo.DbInfo, before int64) ([]*store.ShoppingCart, error) {
+	carts := []*store.ShoppingCart{}
+	filter := expression.Name("updated_at").LessThanEqual(expression.Value(before)).
+		And(expression.Name("user_email").GreaterThan(expression.Value("")))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		log.Printf("ScanIdleCarts failed: %v", err)
+		return carts, err
+	}
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(ShoppingCartsTable()),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	var uerr error
+	err = DB.Svc.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, av := range page.Items {
+			c := &store.ShoppingCart{}
+			if uerr = dynamodbattribute.UnmarshalMap(av, c); uerr != nil {
+				return false
+			}
+			carts = append(carts, c)
+		}
+		return true
+	})
+	if err == nil {
+		err = uerr
+	}
+	if err != nil {
+		log.Printf("ScanIdleCarts failed: %v", err)
+		return carts, err
+	}
+	return carts, nil
+}
+
+// DeleteShoppingCart deletes the user's ShoppingCart from the ShoppingCartsTable.
+func DeleteShoppingCart(DB *dynamo.DbInfo, userID string) error {
+	q := dynamo.CreateNewQueryObj(userID, "")
+	err := dynamo.DeleteItem(DB.Svc, q, DB.Tables[ShoppingCartsTable()])
+	if err != nil {
+		log.Printf("DeleteShoppingCart failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// PutMergedCart puts the user's cart and deletes the guest cart merged into it in a single
+// transaction, on the condition that neither cart was modified since it was read, and
+// increments the user cart's version. Returns a *VersionConflictError if either condition fails,
+// so a guest cart is merged at most once.
+func PutMergedCart(DB *dynamo.DbInfo, cart, guest *store.ShoppingCart) error {
+	av, err := dynamodbattribute.MarshalMap(cart)
+	if err != nil {
+		log.Printf("PutMergedCart failed: %v", err)
+		return err
+	}
+	av["version"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(cart.Version + 1))}
+	put, err := expression.NewBuilder().WithCondition(versionCondition(cart.Version)).Build()
+	if err != nil {
+		log.Printf("PutMergedCart failed: %v", err)
+		return err
+	}
+	del, err := expression.NewBuilder().WithCondition(versionCondition(guest.Version)).Build()
+	if err != nil {
+		log.Printf("PutMergedCart failed: %v", err)
+		return err
+	}
+	items := []*dynamodb.TransactWriteItem{
+		{Put: &dynamodb.Put{
+			TableName:                 aws.String(ShoppingCartsTable()),
+			Item:                      av,
+			ConditionExpression:       put.Condition(),
+			ExpressionAttributeNames:  put.Names(),
+			ExpressionAttributeValues: put.Values(),
+		}},
+		{Delete: &dynamodb.Delete{
+			TableName:                 aws.String(ShoppingCartsTable()),
+			Key:                       map[string]*dynamodb.AttributeValue{ShoppingCartsPK: {S: aws.String(guest.UserID)}},
+			ConditionExpression:       del.Condition(),
+			ExpressionAttributeNames:  del.Names(),
+			ExpressionAttributeValues: del.Values(),
+		}},
+	}
+
+	_, err = DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		if tce, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for i, reason := range tce.CancellationReasons {
+				if reason.Code == nil || *reason.Code != "ConditionalCheckFailed" {
+					continue
+				}
+				if i == 0 {
+					return &VersionConflictError{Table: ShoppingCartsTable(), Key: cart.UserID, Version: cart.Version}
+				}
+				return &VersionConflictError{Table: ShoppingCartsTable(), Key: guest.UserID, Version: guest.Version}
+			}
+		}
+		log.Printf("PutMergedCart failed: %v", err)
+		return err
 	}
+	cart.Version++
 	return nil
 }
 
 // PutParcel adds a new store.Parcel object to the Parcels table.
-func PutParcel(DB *dynamo.DbInfo, parcel []*store.Parcel) error {
+func PutParcel(DB *dynamo.DbInfo, parcel *store.Parcel) error {
 	err := dynamo.CreateItem(DB.Svc, parcel, DB.Tables[ParcelsTable()])
 	if err != nil {
-		log.Printf("PutShoppingCart failed: %v", err)
+		log.Printf("PutParcel failed: %v", err)
+		return err
 	}
 	return nil
 }
@@ -395,6 +672,7 @@ func PutShipment(DB *dynamo.DbInfo, shipment *store.Shipment) error {
 	err := dynamo.CreateItem(DB.Svc, shipment, DB.Tables[ShipmentsTable()])
 	if err != nil {
 		log.Printf("PutShipment failed: %v", err)
+		return err
 	}
 	return nil
 }
@@ -412,6 +690,29 @@ func GetShipment(DB *dynamo.DbInfo, userID, orderID string) (*store.Shipment, er
 	return item.(*store.Shipment), nil
 }
 
+// GetTrackingRef retreives the TrackingRef of a tracking number from the Tracking table. An
+// empty TrackingRef is returned if the tracking number does not exist.
+func GetTrackingRef(DB *dynamo.DbInfo, trackingNumber string) (*store.TrackingRef, error) {
+	q := dynamo.CreateNewQueryObj(trackingNumber, "")
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[TrackingTable()], &store.TrackingRef{}, expr)
+	if err != nil {
+		log.Printf("GetTrackingRef failed: %v", err)
+		return &store.TrackingRef{}, err
+	}
+	return item.(*store.TrackingRef), nil
+}
+
+// PutTrackingRef puts a TrackingRef object to the Tracking table, replacing the existing record.
+func PutTrackingRef(DB *dynamo.DbInfo, ref *store.TrackingRef) error {
+	err := dynamo.CreateItem(DB.Svc, ref, DB.Tables[TrackingTable()])
+	if err != nil {
+		log.Printf("PutTrackingRef failed: %v", err)
+		return err
+	}
+	return nil
+}
+
 // GetTransaction retreives a Transaction object from the TransactionsTable.
 func GetTransaction(DB *dynamo.DbInfo, userID, txID string) (*store.Transaction, error) {
 	q := dynamo.CreateNewQueryObj(userID, txID)
@@ -429,6 +730,7 @@ func PutTransaction(DB *dynamo.DbInfo, tx *store.Transaction) error {
 	err := dynamo.CreateItem(DB.Svc, tx, DB.Tables[TransactionsTable()])
 	if err != nil {
 		log.Printf("PutTransaction failed: %v", err)
+		return err
 	}
 	return nil
 }
@@ -445,11 +747,16 @@ func GetCustomer(DB *dynamo.DbInfo, email string) (*store.Customer, error) {
 	return item.(*store.Customer), nil
 }
 
-// PutCustomer puts a new Customer object to the CustomersTable.
+// PutCustomer puts a Customer object to the CustomersTable on the condition that the stored
+// customer's version equals the customer's version, and increments the customer's version.
+// Returns a *VersionConflictError if the customer was modified since it was read.
 func PutCustomer(DB *dynamo.DbInfo, user *store.Customer) error {
-	err := dynamo.CreateItem(DB.Svc, user, DB.Tables[CustomersTable])
+	user.Version++
+	err := putVersioned(DB, CustomersTable, user.Email, user, user.Version-1)
 	if err != nil {
+		user.Version--
 		log.Printf("PutCustomer failed: %v", err)
+		return err
 	}
 	return nil
 }
@@ -496,79 +803,264 @@ func GetOpenOrder(DB *dynamo.DbInfo, userID, orderID string) (*store.Order, erro
 	return item.(*store.Order), nil
 }
 
-// PutOrder puts a new Order object to the Orders table.
-func PutOrder(DB *dynamo.DbInfo, user *store.Order) error {
-	err := dynamo.CreateItem(DB.Svc, user, DB.Tables[OrdersTable()])
+// PutOrder puts an Order object to the Orders table on the condition that the stored order's
+// version equals the order's version, and increments the order's version. Returns a
+// *VersionConflictError if the order was modified since it was read.
+func PutOrder(DB *dynamo.DbInfo, order *store.Order) error {
+	order.Version++
+	err := putVersioned(DB, OrdersTable(), memKey(order.UserID, order.OrderID), order, order.Version-1)
 	if err != nil {
+		order.Version--
 		log.Printf("PutOrder failed: %v", err)
+		return err
 	}
 	return nil
 }
 
 func UpdateOrderAddress(DB *dynamo.DbInfo, userID, orderID string, addr store.Address, shipping bool) error {
+	err := UpdateItem(DB, orderAddressUpdate(userID, orderID, addr, shipping))
+	if err != nil {
+		log.Printf("UpdateOrderAddress failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// orderAddressUpdate returns the Update setting the order's shipping or billing address.
+func orderAddressUpdate(userID, orderID string, addr store.Address, shipping bool) *Update {
 	field := "shipping_address"
 	if !shipping {
 		field = "billing_address"
 	}
+	return NewOrderUpdate(userID, orderID).Set(field, addr)
+}
 
-	// create and set update query
-	q := dynamo.CreateNewQueryObj(userID, orderID)
-	q.UpdateCurrent(field, addr)
+// UpdateOrderStatus sets the order's status to 'to' and updates the paid, shipped, delivered
+// and complete flags to match. The update is conditional on the order existing with the
+// status 'from'; ErrConditionalCheck is returned if the order's status has changed.
+// Use TransitionOrder to validate the transition before writing.
+func UpdateOrderStatus(DB *dynamo.DbInfo, userID, orderID, from, to string) error {
+	err := UpdateItem(DB, orderStatusUpdate(userID, orderID, from, to))
+	if err != nil && err.Error() != ErrConditionalCheck {
+		log.Printf("UpdateOrderStatus failed: %v", err)
+	}
+	return err
+}
 
-	// build expression
-	update := dynamo.NewUpdateExpr()
-	update.Set(field, addr)
+// orderStatusUpdate returns the Update setting the order's status and status flags on the
+// condition that the order exists with the status 'from'.
+func orderStatusUpdate(userID, orderID, from, to string) *Update {
+	paid, shipped, delivered, complete := store.OrderStatusFlags(to)
+	u := NewOrderUpdate(userID, orderID).
+		Set("order_status", to).
+		Set("paid", paid).
+		Set("shipped", shipped).
+		Set("delivered", delivered).
+		Set("status", complete).
+		IfExists()
+
+	// orders created before order_status was set have no status attribute
+	if from == "" {
+		return u.If(Equal("order_status", from), NotExists("order_status"))
+	}
+	return u.If(Equal("order_status", from))
+}
 
-	eb := dynamo.NewExprBuilder()
-	eb.SetUpdate(update)
-	expression, err := eb.BuildExpression()
+// TransitionOrder applies the event to the order's current status and persists the
+// new status with a conditional write on the prior status. A *store.TransitionError
+// is returned if the event is not valid for the order's status, and ErrConditionalCheck
+// is returned if the order was modified concurrently. Returns the updated order.
+func TransitionOrder(s Store, userID, orderID string, event store.OrderEvent) (*store.Order, error) {
+	order, err := s.GetOrder(userID, orderID)
 	if err != nil {
-		log.Printf("UpdateOrderAddress failed: %v", err)
-		return err
+		log.Printf("TransitionOrder failed: %v", err)
+		return &store.Order{}, err
+	}
+	if order.OrderID == "" {
+		log.Printf("TransitionOrder failed: %s", ErrOrderNotFound)
+		return &store.Order{}, fmt.Errorf(ErrOrderNotFound)
+	}
+	prior, err := order.Apply(event)
+	if err != nil {
+		log.Printf("TransitionOrder failed: %v", err)
+		return order, err
+	}
+	err = s.UpdateOrderStatus(userID, orderID, prior, order.OrderStatus)
+	if err != nil {
+		log.Printf("TransitionOrder failed: %v", err)
+		return order, err
 	}
+	return order, nil
+}
 
-	// update DB object
-	err = dynamo.UpdateItem(DB.Svc, q, DB.Tables[OrdersTable()], expression)
+// DeliverOrder transitions the order to DELIVERED and records the time of delivery. Orders that
+// are still PAID are shipped first, as the carrier may report the delivery before the order is
+// marked as shipped. The update is conditional on the order's prior status; a
+// *store.TransitionError is returned if the order cannot be delivered, and
+// ErrConditionalCheck if it was modified concurrently. Returns the updated order.
+func DeliverOrder(s Store, userID, orderID string, at time.Time) (*store.Order, error) {
+	order, err := s.GetOrder(userID, orderID)
 	if err != nil {
-		log.Printf("UpdateOrderAddress failed: %v", err)
-		return err
+		log.Printf("DeliverOrder failed: %v", err)
+		return &store.Order{}, err
 	}
-	return nil
+	if order.OrderID == "" {
+		log.Printf("DeliverOrder failed: %s", ErrOrderNotFound)
+		return &store.Order{}, fmt.Errorf(ErrOrderNotFound)
+	}
+	prior := order.OrderStatus
+	if prior == store.OrderStatusPaid {
+		if _, err := order.Apply(store.OrderEventShip); err != nil {
+			log.Printf("DeliverOrder failed: %v", err)
+			return order, err
+		}
+	}
+	if _, err := order.Apply(store.OrderEventDeliver); err != nil {
+		log.Printf("DeliverOrder failed: %v", err)
+		return order, err
+	}
+	order.DeliveredAt = at.Unix()
+	err = s.UpdateItem(orderStatusUpdate(userID, orderID, prior, order.OrderStatus).Set("delivered_at", order.DeliveredAt))
+	if err != nil {
+		log.Printf("DeliverOrder failed: %v", err)
+		return order, err
+	}
+	return order, nil
 }
 
-func UpdateOrderShippingInfo(DB *dynamo.DbInfo, userID, orderID, status string, shipped bool) error {
-	// create and set update query
-	q := dynamo.CreateNewQueryObj(userID, orderID)
-	q.UpdateCurrent("order_status", status) // fix muliple fields
-	q.UpdateCurrent("shipped", shipped)     // fix muliple fields
+// ScanDeliveredOrders scans the OrdersTable for DELIVERED orders delivered at or before
+// 'before' (unix timestamp (s)).
+func ScanDeliveredOrders(DB *dynamo.DbInfo, before int64) ([]*store.Order, error) {
+	orders := []*store.Order{}
+	filter := expression.Name("order_status").Equal(expression.Value(store.OrderStatusDelivered)).
+		And(expression.Name("delivered_at").LessThanEqual(expression.Value(before)))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		log.Printf("ScanDeliveredOrders failed: %v", err)
+		return orders, err
+	}
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(OrdersTable()),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	var uerr error
+	err = DB.Svc.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, av := range page.Items {
+			o := &store.Order{}
+			if uerr = dynamodbattribute.UnmarshalMap(av, o); uerr != nil {
+				return false
+			}
+			orders = append(orders, o)
+		}
+		return true
+	})
+	if err == nil {
+		err = uerr
+	}
+	if err != nil {
+		log.Printf("ScanDeliveredOrders failed: %v", err)
+		return orders, err
+	}
+	return orders, nil
+}
+
+// GetReturn retreives a Return object from the Returns table.
+func GetReturn(DB *dynamo.DbInfo, userID, returnID string) (*store.Return, error) {
+	q := dynamo.CreateNewQueryObj(userID, returnID)
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[ReturnsTable()], &store.Return{}, expr)
+	if err != nil {
+		log.Printf("GetReturn failed: %v", err)
+		return &store.Return{}, err
+	}
+	return item.(*store.Return), nil
+}
 
-	// build expression
-	update := dynamo.NewUpdateExpr()
-	update.Set("order_status", status)
-	update.Set("shipped", shipped) // fix multiple values
+// PutReturn puts a Return object to the Returns table, replacing the existing record.
+func PutReturn(DB *dynamo.DbInfo, ret *store.Return) error {
+	err := dynamo.CreateItem(DB.Svc, ret, DB.Tables[ReturnsTable()])
+	if err != nil {
+		log.Printf("PutReturn failed: %v", err)
+		return err
+	}
+	return nil
+}
 
-	eb := dynamo.NewExprBuilder()
-	eb.SetUpdate(update)
-	expression, err := eb.BuildExpression()
+// TransitionReturn applies the event to the return, transitions the return's order with the
+// resulting order events and persists the updated return. The return and the order's status are
+// written in a single transaction, on the condition that the return's status and the order's
+// status were not changed since they were read; ErrConditionalCheck is returned otherwise.
+func TransitionReturn(s Store, ret *store.Return, event store.ReturnEvent) error {
+	if ret.ReturnID == "" {
+		log.Printf("TransitionReturn failed: %s", ErrReturnNotFound)
+		return fmt.Errorf(ErrReturnNotFound)
+	}
+	from := ret.ReturnStatus
+	events, err := ret.Apply(event)
 	if err != nil {
-		log.Printf("UpdateOrderShippingInfo failed: %v", err)
+		log.Printf("TransitionReturn failed: %v", err)
 		return err
 	}
+	updates := []*Update{returnUpdate(ret).If(Equal("return_status", from))}
 
-	// update DB object
-	err = dynamo.UpdateItem(DB.Svc, q, DB.Tables[OrdersTable()], expression)
+	if len(events) > 0 {
+		order, err := s.GetOrder(ret.UserID, ret.OrderID)
+		if err != nil {
+			log.Printf("TransitionReturn failed: %v", err)
+			return err
+		}
+		if order.OrderID == "" {
+			log.Printf("TransitionReturn failed: %s", ErrOrderNotFound)
+			return fmt.Errorf(ErrOrderNotFound)
+		}
+		prior := order.OrderStatus
+		for _, e := range events {
+			if _, err := order.Apply(e); err != nil {
+				log.Printf("TransitionReturn failed: %v", err)
+				return err
+			}
+		}
+		updates = append(updates, orderStatusUpdate(ret.UserID, ret.OrderID, prior, order.OrderStatus))
+	}
+
+	err = s.UpdateItems(updates...)
 	if err != nil {
-		log.Printf("UpdateOrderShippingInfo failed: %v", err)
+		log.Printf("TransitionReturn failed: %v", err)
 		return err
 	}
 	return nil
 }
 
+// returnUpdate returns the Update setting each attribute of the existing return.
+func returnUpdate(ret *store.Return) *Update {
+	return NewReturnUpdate(ret.UserID, ret.ReturnID).
+		Set("user_email", ret.UserEmail).
+		Set("order_id", ret.OrderID).
+		Set("original_tx_id", ret.OriginalTxID).
+		Set("return_tx_id", ret.ReturnTxID).
+		Set("return_items", ret.ReturnItems).
+		Set("reason", ret.Reason).
+		Set("message", ret.Message).
+		Set("request_date", ret.RequestDate).
+		Set("refund_amount", ret.RefundAmount).
+		Set("return_label", ret.ReturnLabel).
+		Set("open", ret.Open).
+		Set("refunding", ret.Refunding).
+		Set("refunded", ret.Refunded).
+		Set("items_received", ret.ItemsReceived).
+		Set("complete", ret.Complete).
+		Set("return_status", ret.ReturnStatus).
+		IfExists()
+}
+
 // PutOpenOrder puts a new Order object to the Orders table.
 func PutOpenOrder(DB *dynamo.DbInfo, order *store.Order) error {
 	err := dynamo.CreateItem(DB.Svc, order, DB.Tables[OpenOrdersTable()])
 	if err != nil {
 		log.Printf("PutOpenOrder failed: %v", err)
+		return err
 	}
 	return nil
 }
@@ -578,15 +1070,13 @@ func DeleteOpenOrder(DB *dynamo.DbInfo, userID, orderID string) error {
 	err := dynamo.DeleteItem(DB.Svc, q, DB.Tables[OpenOrdersTable()])
 	if err != nil {
 		log.Printf("DeleteOpenOrder failed: %v", err)
+		return err
 	}
 	return nil
 }
 
 func UpdateOrderPaymentStatus(DB *dynamo.DbInfo, customerID, orderID, status string) error {
-	q := dynamo.CreateNewQueryObj(customerID, orderID)
-	expr := dynamo.NewExpression()
-	q.UpdateCurrent("payment_status", status)
-	err := dynamo.UpdateItem(DB.Svc, q, DB.Tables[OrdersTable()], expr)
+	err := UpdateItem(DB, NewOrderUpdate(customerID, orderID).Set("payment_status", status))
 	if err != nil {
 		log.Printf("UpdateOrderPaymentStatus failed: %v", err)
 		return err
@@ -595,43 +1085,88 @@ func UpdateOrderPaymentStatus(DB *dynamo.DbInfo, customerID, orderID, status str
 }
 
 func UpdateTxPaymentStatus(DB *dynamo.DbInfo, customerID, txID, status string) error {
-	q := dynamo.CreateNewQueryObj(customerID, txID)
-	q.UpdateCurrent("payment_status", status)
-	expr := dynamo.NewExpression()
-	err := dynamo.UpdateItem(DB.Svc, q, DB.Tables[TransactionsTable()], expr)
+	err := UpdateItem(DB, NewTransactionUpdate(customerID, txID).Set("payment_status", status))
 	if err != nil {
-		log.Printf("UpdateOrderPaymentStatus failed: %v", err)
+		log.Printf("UpdateTxPaymentStatus failed: %v", err)
 		return err
 	}
 	return nil
 }
 
 func UpdateTxPaymentMethod(DB *dynamo.DbInfo, customerID, txID, method string) error {
-	q := dynamo.CreateNewQueryObj(customerID, txID)
-	q.UpdateCurrent("payment_method", method)
-	expr := dynamo.NewExpression()
-	err := dynamo.UpdateItem(DB.Svc, q, DB.Tables[TransactionsTable()], expr)
+	err := UpdateItem(DB, NewTransactionUpdate(customerID, txID).Set("payment_method", method))
 	if err != nil {
-		log.Printf("UpdateOrderPaymentMethod failed: %v", err)
+		log.Printf("UpdateTxPaymentMethod failed: %v", err)
 		return err
 	}
 	return nil
 }
 
 func UpdateTxPaymentID(DB *dynamo.DbInfo, customerID, txID, paymentID string) error {
-	q := dynamo.CreateNewQueryObj(customerID, txID)
-	q.UpdateCurrent("payment_tx_id", paymentID)
-	expr := dynamo.NewExpression()
-	err := dynamo.UpdateItem(DB.Svc, q, DB.Tables[TransactionsTable()], expr)
+	err := UpdateItem(DB, NewTransactionUpdate(customerID, txID).Set("payment_tx_id", paymentID))
+	if err != nil {
+		log.Printf("UpdateTxPaymentID failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// PutWebhookEvent records a processed webhook event on the condition that the event ID
+// has not been recorded. Returns ErrConditionalCheck for duplicate events.
+func PutWebhookEvent(DB *dynamo.DbInfo, event *store.WebhookEvent) error {
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		log.Printf("PutWebhookEvent failed: %v", err)
+		return err
+	}
+	cond := expression.AttributeNotExists(expression.Name(WebhookEventsPK))
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
 	if err != nil {
-		log.Printf("UpdateOrderPaymentID failed: %v", err)
+		log.Printf("PutWebhookEvent failed: %v", err)
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(WebhookEventsTable()),
+		Item:                     item,
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}
+	_, err = DB.Svc.PutItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf(ErrConditionalCheck)
+		}
+		log.Printf("PutWebhookEvent failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteWebhookEvent deletes a webhook event record so the event can be processed on redelivery.
+func DeleteWebhookEvent(DB *dynamo.DbInfo, eventID string) error {
+	q := dynamo.CreateNewQueryObj(eventID, "")
+	err := dynamo.DeleteItem(DB.Svc, q, DB.Tables[WebhookEventsTable()])
+	if err != nil {
+		log.Printf("DeleteWebhookEvent failed: %v", err)
 		return err
 	}
 	return nil
 }
 
-// VerifyOrderStock verifies that all items in an order are still in stock at the time of payment.
-func VerifyOrderStock(DB *dynamo.DbInfo, items []*store.CartItem) (bool, []string, error) {
+// RestockItem increments a Store Item's units available for the given sizeKey by count,
+// used to return items to inventory. Returns ErrConditionalCheck if the item does not exist.
+func RestockItem(DB *dynamo.DbInfo, subcat, itemID, sizeKey string, count int) error {
+	err := UpdateItem(DB, restockUpdate(subcat, itemID, sizeKey, count))
+	if err != nil && err.Error() != ErrConditionalCheck {
+		log.Printf("RestockItem failed: %v", err)
+	}
+	return err
+}
+
+// VerifyOrderStock verifies that all items in an order are still available to sell at the time
+// of payment. Units reserved by other orders' inventory holds are not available.
+func VerifyOrderStock(s Store, items []*store.CartItem) (bool, []string, error) {
 	bc := make(chan map[string]bool)
 	ec := make(chan error)
 	var wg sync.WaitGroup
@@ -641,7 +1176,7 @@ func VerifyOrderStock(DB *dynamo.DbInfo, items []*store.CartItem) (bool, []strin
 
 	for _, item := range items {
 		wg.Add(1)
-		go checkInventory(DB, item, bc, ec, &wg)
+		go checkInventory(s, item, bc, ec, &wg)
 	}
 
 	br := 0
@@ -676,25 +1211,17 @@ func VerifyOrderStock(DB *dynamo.DbInfo, items []*store.CartItem) (bool, []strin
 }
 
 // checkInventory runs as a goroutine to check the availability of a shopping cart's items concurrently
-func checkInventory(DB *dynamo.DbInfo, item *store.CartItem, bc chan map[string]bool, ec chan error, wg *sync.WaitGroup) {
+func checkInventory(s Store, item *store.CartItem, bc chan map[string]bool, ec chan error, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	field := "units_available"
-	keyName := fmt.Sprintf("%s.%s", field, item.Size)
-	q := dynamo.CreateNewQueryObj(item.Subcategory, item.ItemID)
-
-	eb := dynamo.NewExprBuilder()
-	eb.SetProjection([]string{keyName})
-	expr, err := eb.BuildExpression()
-
-	check, err := dynamo.GetItem(DB.Svc, q, DB.Tables[StoreItemsTable()], &store.StoreItem{}, expr)
+	check, err := s.GetStoreItem(item.Subcategory, item.ItemID)
 	if err != nil {
 		log.Printf("checkInventory failed: %v", err)
 		bc <- map[string]bool{item.ItemID: false}
 		ec <- err
 		return
 	}
-	if check.(*store.StoreItem).UnitsAvailable[item.Size] < item.Quantity {
+	if check.AvailableToSell(item.Size) < item.Quantity {
 		bc <- map[string]bool{item.ItemID: false}
 		ec <- nil
 		return
@@ -704,41 +1231,1776 @@ func checkInventory(DB *dynamo.DbInfo, item *store.CartItem, bc chan map[string]
 	return
 }
 
+// restockUpdate returns the Update incrementing the units of the item's size by count.
+func restockUpdate(subcat, itemID, sizeKey string, count int) *Update {
+	return NewStoreItemUpdate(subcat, itemID).
+		Increment(fmt.Sprintf("units_available.%s", sizeKey), count).
+		IfExists()
+}
+
+// inventoryUpdate returns the Update decrementing the units of the item's size by count on the
+// condition that count units are available.
+func inventoryUpdate(subcat, itemID, sizeKey string, count int) *Update {
+	path := fmt.Sprintf("units_available.%s", sizeKey)
+	return NewStoreItemUpdate(subcat, itemID).
+		Increment(path, -count).
+		If(GreaterThanEqual(path, count))
+}
+
 // UpdateInventoryCount updates a Store Item's inventory count by size and decrements the value for
 // the given sizeKey by the count integer. The update succeeds on the condition that the quantity
 // of the given size is greater than or equal to the count variable. Returns ItemID and ConditionalCheck error if item
 // is out of stock.
 func UpdateInventoryCount(DB *dynamo.DbInfo, subcat, itemID, sizeKey string, count int) (string, error) {
-	field := "units_available"
-	keyName := fmt.Sprintf("%s.%s", field, sizeKey)
-
-	// create and set update query
-	q := dynamo.CreateNewQueryObj(subcat, itemID)
-	q.UpdateCurrent(field, count)
-
-	// build expression
-	cond := dynamo.NewCondition()
-	cond.GreaterThanEqual(keyName, count)
-
-	update := dynamo.NewUpdateExpr()
-	update.SetMinus(keyName, keyName, count, true)
-
-	eb := dynamo.NewExprBuilder()
-	eb.SetCondition(cond)
-	eb.SetUpdate(update)
-	expression, err := eb.BuildExpression()
+	err := UpdateItem(DB, inventoryUpdate(subcat, itemID, sizeKey, count))
 	if err != nil {
+		if err.Error() == ErrConditionalCheck {
+			return itemID, err
+		}
 		log.Printf("UpdateInventoryCount failed: %v", err)
 		return "", err
 	}
+	return "", nil
+}
+
+// GetPromotion retreives a Promotion object from the Promotions table.
+func GetPromotion(DB *dynamo.DbInfo, code string) (*store.Promotion, error) {
+	q := dynamo.CreateNewQueryObj(code, "")
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[PromotionsTable()], &store.Promotion{}, expr)
+	if err != nil {
+		log.Printf("GetPromotion failed: %v", err)
+		return &store.Promotion{}, err
+	}
+	return item.(*store.Promotion), nil
+}
 
-	err = dynamo.UpdateItem(DB.Svc, q, DB.Tables[StoreItemsTable()], expression)
+// PutPromotion puts a Promotion object to the Promotions table, replacing the existing record.
+func PutPromotion(DB *dynamo.DbInfo, promo *store.Promotion) error {
+	err := dynamo.CreateItem(DB.Svc, promo, DB.Tables[PromotionsTable()])
 	if err != nil {
-		if err.Error() == dynamo.ErrConditionalCheck {
-			return itemID, fmt.Errorf(ErrConditionalCheck)
-		}
-		log.Printf("UpdateInventoryCount failed: %v", err)
-		return "", err
+		log.Printf("PutPromotion failed: %v", err)
+		return err
 	}
-	return "", nil
+	return nil
+}
+
+// DeletePromotion deletes a Promotion object from the Promotions table.
+func DeletePromotion(DB *dynamo.DbInfo, code string) error {
+	q := dynamo.CreateNewQueryObj(code, "")
+	err := dynamo.DeleteItem(DB.Svc, q, DB.Tables[PromotionsTable()])
+	if err != nil {
+		log.Printf("DeletePromotion failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ScanPromotions returns each Promotion in the Promotions table.
+func ScanPromotions(DB *dynamo.DbInfo) ([]*store.Promotion, error) {
+	promos := []*store.Promotion{}
+	input := &dynamodb.ScanInput{TableName: aws.String(PromotionsTable())}
+	err := DB.Svc.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			promo := &store.Promotion{}
+			if err := dynamodbattribute.UnmarshalMap(item, promo); err != nil {
+				log.Printf("ScanPromotions failed: %v", err)
+				continue
+			}
+			promos = append(promos, promo)
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("ScanPromotions failed: %v", err)
+		return promos, err
+	}
+	return promos, nil
+}
+
+// GetShippingRule retreives a ShippingRule object from the Shipping Rules table.
+func GetShippingRule(DB *dynamo.DbInfo, ruleID string) (*store.ShippingRule, error) {
+	q := dynamo.CreateNewQueryObj(ruleID, "")
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[ShippingRulesTable()], &store.ShippingRule{}, expr)
+	if err != nil {
+		log.Printf("GetShippingRule failed: %v", err)
+		return &store.ShippingRule{}, err
+	}
+	return item.(*store.ShippingRule), nil
+}
+
+// PutShippingRule puts a ShippingRule object to the Shipping Rules table, replacing the
+// existing record.
+func PutShippingRule(DB *dynamo.DbInfo, rule *store.ShippingRule) error {
+	err := dynamo.CreateItem(DB.Svc, rule, DB.Tables[ShippingRulesTable()])
+	if err != nil {
+		log.Printf("PutShippingRule failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteShippingRule deletes a ShippingRule object from the Shipping Rules table.
+func DeleteShippingRule(DB *dynamo.DbInfo, ruleID string) error {
+	q := dynamo.CreateNewQueryObj(ruleID, "")
+	err := dynamo.DeleteItem(DB.Svc, q, DB.Tables[ShippingRulesTable()])
+	if err != nil {
+		log.Printf("DeleteShippingRule failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ScanShippingRules returns each ShippingRule in the Shipping Rules table.
+func ScanShippingRules(DB *dynamo.DbInfo) ([]*store.ShippingRule, error) {
+	rules := []*store.ShippingRule{}
+	input := &dynamodb.ScanInput{TableName: aws.String(ShippingRulesTable())}
+	err := DB.Svc.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			rule := &store.ShippingRule{}
+			if err := dynamodbattribute.UnmarshalMap(item, rule); err != nil {
+				log.Printf("ScanShippingRules failed: %v", err)
+				continue
+			}
+			rules = append(rules, rule)
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("ScanShippingRules failed: %v", err)
+		return rules, err
+	}
+	return rules, nil
+}
+
+// GetLocation retreives a Location object from the Locations table.
+func GetLocation(DB *dynamo.DbInfo, locationID string) (*store.Location, error) {
+	q := dynamo.CreateNewQueryObj(locationID, "")
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[LocationsTable()], &store.Location{}, expr)
+	if err != nil {
+		log.Printf("GetLocation failed: %v", err)
+		return &store.Location{}, err
+	}
+	return item.(*store.Location), nil
+}
+
+// PutLocation puts a Location object to the Locations table, replacing the existing record.
+func PutLocation(DB *dynamo.DbInfo, loc *store.Location) error {
+	err := dynamo.CreateItem(DB.Svc, loc, DB.Tables[LocationsTable()])
+	if err != nil {
+		log.Printf("PutLocation failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteLocation deletes a Location object from the Locations table.
+func DeleteLocation(DB *dynamo.DbInfo, locationID string) error {
+	q := dynamo.CreateNewQueryObj(locationID, "")
+	err := dynamo.DeleteItem(DB.Svc, q, DB.Tables[LocationsTable()])
+	if err != nil {
+		log.Printf("DeleteLocation failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ScanLocations returns each Location in the Locations table.
+func ScanLocations(DB *dynamo.DbInfo) ([]*store.Location, error) {
+	locations := []*store.Location{}
+	input := &dynamodb.ScanInput{TableName: aws.String(LocationsTable())}
+	err := DB.Svc.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, item := range page.Items {
+			loc := &store.Location{}
+			if err := dynamodbattribute.UnmarshalMap(item, loc); err != nil {
+				log.Printf("ScanLocations failed: %v", err)
+				continue
+			}
+			locations = append(locations, loc)
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("ScanLocations failed: %v", err)
+		return locations, err
+	}
+	return locations, nil
+}
+
+// locationStockUpdate returns the Update adding count units of the size to the location's
+// inventory. Negative counts are conditional on the location having the units in stock.
+func locationStockUpdate(locationID, sizeID string, count int) *Update {
+	path := fmt.Sprintf("inventory.%s", sizeID)
+	u := NewLocationUpdate(locationID).Increment(path, count).IfExists()
+	if count < 0 {
+		u.If(GreaterThanEqual(path, -count))
+	}
+	return u
+}
+
+// UpdateLocationStock adds count units of the size to the location's inventory; negative counts
+// remove units. The update fails with ErrConditionalCheck if the location does not exist or
+// has fewer units in stock than are removed.
+func UpdateLocationStock(DB *dynamo.DbInfo, locationID, sizeID string, count int) error {
+	err := UpdateItem(DB, locationStockUpdate(locationID, sizeID, count))
+	if err != nil && err.Error() != ErrConditionalCheck {
+		log.Printf("UpdateLocationStock failed: %v", err)
+	}
+	return err
+}
+
+// ShipFromLocations removes the units of each of the packages from the inventory of the
+// package's origin location. Packages without an origin location are skipped. The inventory
+// of sizes the location has fewer units of than were shipped is not updated; their size IDs are
+// returned so the location's inventory can be recounted.
+func ShipFromLocations(s Store, pkgs []store.Package) ([]string, error) {
+	short := []string{}
+	for _, pkg := range pkgs {
+		if pkg.LocationID == "" {
+			continue
+		}
+		for _, item := range pkg.Items {
+			err := s.UpdateLocationStock(pkg.LocationID, item.SizeID, -item.Quantity)
+			if err == nil {
+				continue
+			}
+			if err.Error() != ErrConditionalCheck {
+				log.Printf("ShipFromLocations failed: %v", err)
+				return short, err
+			}
+			short = append(short, item.SizeID)
+		}
+	}
+	return short, nil
+}
+
+// SaveShippingLabel appends the label to the stored shipment's Labels and increments the
+// shipment's version, on the condition that the shipment has not been labeled since it was read.
+// Returns a *VersionConflictError if the condition fails.
+func SaveShippingLabel(s Store, shipment *store.Shipment, label store.ShippingLabel) error {
+	version := []Condition{Equal("version", shipment.Version)}
+	if shipment.Version == 0 {
+		version = append(version, NotExists("version"))
+	}
+	u := NewShipmentUpdate(shipment.UserID, shipment.OrderID).
+		Append("labels", label).
+		Increment("version", 1).
+		IfExists().
+		If(version...)
+	err := s.UpdateItem(u)
+	if err != nil {
+		if err.Error() == ErrConditionalCheck {
+			return &VersionConflictError{Table: ShipmentsTable(), Key: memKey(shipment.UserID, shipment.OrderID), Version: shipment.Version}
+		}
+		log.Printf("SaveShippingLabel failed: %v", err)
+		return err
+	}
+	shipment.Version++
+	return nil
+}
+
+// SaveTrackingEvent appends the tracking event to the stored shipment's TrackingHistory, saves the
+// shipment's label tracking statuses and increments the shipment's version, on the condition that
+// the shipment has not changed since it was read. The event must already have been added to the
+// shipment (see store.Shipment.AddTracking). Returns a *VersionConflictError if the condition fails.
+func SaveTrackingEvent(s Store, shipment *store.Shipment, event store.TrackingEvent) error {
+	version := []Condition{Equal("version", shipment.Version)}
+	if shipment.Version == 0 {
+		version = append(version, NotExists("version"))
+	}
+	u := NewShipmentUpdate(shipment.UserID, shipment.OrderID).
+		Append("tracking_history", event).
+		Set("labels", shipment.Labels).
+		Increment("version", 1).
+		IfExists().
+		If(version...)
+	err := s.UpdateItem(u)
+	if err != nil {
+		if err.Error() == ErrConditionalCheck {
+			return &VersionConflictError{Table: ShipmentsTable(), Key: memKey(shipment.UserID, shipment.OrderID), Version: shipment.Version}
+		}
+		log.Printf("SaveTrackingEvent failed: %v", err)
+		return err
+	}
+	shipment.Version++
+	return nil
+}
+
+// GetCustomerRedemptions returns the number of times the customer has redeemed the promotion.
+func GetCustomerRedemptions(DB *dynamo.DbInfo, code, userID string) (int, error) {
+	q := dynamo.CreateNewQueryObj(code, userID)
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[RedemptionsTable()], &store.PromotionRedemption{}, expr)
+	if err != nil {
+		log.Printf("GetCustomerRedemptions failed: %v", err)
+		return 0, err
+	}
+	return item.(*store.PromotionRedemption).Redemptions, nil
+}
+
+// RedeemPromotions records the customer's redemption of each promotion in a single transaction.
+// Promotions must be active, the remaining redemptions of promotions with a global usage limit
+// are decremented on the condition that redemptions remain, and the customer's redemption count is incremented on the
+// condition that the customer has not reached the promotion's per-customer limit. No
+// redemptions are recorded if any condition fails; ErrPromotionLimitReached or
+// ErrPromotionCustomerLimit is returned for the first failed condition.
+func RedeemPromotions(DB *dynamo.DbInfo, userID string, promos []*store.Promotion) error {
+	items, err := redemptionWrites(userID, promos, 1)
+	if err != nil {
+		log.Printf("RedeemPromotions failed: %v", err)
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	_, err = DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		if tce, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for i, reason := range tce.CancellationReasons {
+				if reason.Code == nil || *reason.Code != "ConditionalCheckFailed" {
+					continue
+				}
+				// writes alternate between the promotions & redemptions tables
+				if i%2 == 0 {
+					return fmt.Errorf(store.ErrPromotionLimitReached)
+				}
+				return fmt.Errorf(store.ErrPromotionCustomerLimit)
+			}
+		}
+		log.Printf("RedeemPromotions failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ReleasePromotions reverses the customer's redemption of each promotion, used when an
+// order's payment fails after the promotions were redeemed.
+func ReleasePromotions(DB *dynamo.DbInfo, userID string, promos []*store.Promotion) error {
+	items, err := redemptionWrites(userID, promos, -1)
+	if err != nil {
+		log.Printf("ReleasePromotions failed: %v", err)
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	_, err = DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		log.Printf("ReleasePromotions failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ReleaseOrderPromotions reverses the customer's redemption of the promotions of a staged order
+// whose payment failed. The order is marked 'promotions_released' in the same transaction, so
+// the promotions of an order are released once.
+func ReleaseOrderPromotions(DB *dynamo.DbInfo, order *store.Order, promos []*store.Promotion) error {
+	if len(promos) == 0 {
+		return nil
+	}
+	release, err := NewOrderUpdate(order.UserID, order.OrderID).
+		Set("promotions_released", true).
+		IfExists().
+		If(NotExists("promotions_released")).
+		transactItem()
+	if err != nil {
+		log.Printf("ReleaseOrderPromotions failed: %v", err)
+		return err
+	}
+	items, err := redemptionWrites(order.UserID, promos, -1)
+	if err != nil {
+		log.Printf("ReleaseOrderPromotions failed: %v", err)
+		return err
+	}
+
+	_, err = DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: append([]*dynamodb.TransactWriteItem{release}, items...)})
+	if err != nil {
+		if tce, ok := err.(*dynamodb.TransactionCanceledException); ok && len(tce.CancellationReasons) > 0 {
+			reason := tce.CancellationReasons[0]
+			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				return nil // released on a previous delivery
+			}
+		}
+		log.Printf("ReleaseOrderPromotions failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// redemptionWrites returns the transaction writes that add n to the redemption counts of each
+// promotion and the customer's redemption records. Usage limits are only enforced for n > 0.
+func redemptionWrites(userID string, promos []*store.Promotion, n int) ([]*dynamodb.TransactWriteItem, error) {
+	items := []*dynamodb.TransactWriteItem{}
+	for _, p := range promos {
+		// promotion redemption counts
+		redemptions := expression.Name("redemptions")
+		remaining := expression.Name("redemptions_remaining")
+		update := expression.Add(redemptions, expression.Value(n))
+		cond := expression.AttributeExists(expression.Name(PromotionsPK))
+		if n > 0 {
+			cond = cond.And(expression.Name("active").Equal(expression.Value(true)))
+		}
+		if p.MaxRedemptions > 0 {
+			update = update.Set(remaining, expression.Minus(remaining, expression.Value(n)))
+			if n > 0 {
+				cond = cond.And(remaining.GreaterThanEqual(expression.Value(n)))
+			}
+		}
+		expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+		if err != nil {
+			return items, err
+		}
+		items = append(items, &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+			TableName:                 aws.String(PromotionsTable()),
+			Key:                       map[string]*dynamodb.AttributeValue{PromotionsPK: {S: aws.String(p.Code)}},
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		}})
+
+		// customer redemption counts
+		builder := expression.NewBuilder().WithUpdate(expression.Add(redemptions, expression.Value(n)))
+		if p.MaxPerCustomer > 0 && n > 0 {
+			limit := expression.Or(
+				expression.AttributeNotExists(redemptions),
+				redemptions.LessThanEqual(expression.Value(p.MaxPerCustomer-n)),
+			)
+			builder = builder.WithCondition(limit)
+		}
+		expr, err = builder.Build()
+		if err != nil {
+			return items, err
+		}
+		items = append(items, &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+			TableName: aws.String(RedemptionsTable()),
+			Key: map[string]*dynamodb.AttributeValue{
+				RedemptionsPK: {S: aws.String(p.Code)},
+				RedemptionsSK: {S: aws.String(userID)},
+			},
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		}})
+	}
+	return items, nil
+}
+
+// GetCustomerPromotions retreives the promotions for the given coupon codes and verifies that
+// the customer has not reached each promotion's per-customer usage limit. Returns
+// ErrPromotionNotFound if a code does not match a promotion.
+func GetCustomerPromotions(s Store, userID string, codes []string) ([]*store.Promotion, error) {
+	promos := []*store.Promotion{}
+	for _, code := range codes {
+		promo, err := s.GetPromotion(store.NormalizeCode(code))
+		if err != nil {
+			log.Printf("GetCustomerPromotions failed: %v", err)
+			return promos, err
+		}
+		if promo.Code == "" {
+			return promos, fmt.Errorf(store.ErrPromotionNotFound)
+		}
+		if promo.MaxPerCustomer > 0 {
+			count, err := s.GetCustomerRedemptions(promo.Code, userID)
+			if err != nil {
+				log.Printf("GetCustomerPromotions failed: %v", err)
+				return promos, err
+			}
+			if count >= promo.MaxPerCustomer {
+				return promos, fmt.Errorf(store.ErrPromotionCustomerLimit)
+			}
+		}
+		promos = append(promos, promo)
+	}
+	return promos, nil
+}
+
+// maxReserveRetries is the number of times ReserveItems retries when an item's reserved counts
+// are modified by a concurrent reservation.
+const maxReserveRetries = 3
+
+// ReserveItems places the inventory holds and adds each hold's quantity to the reserved count
+// of its item size in a single transaction. No holds are placed if any item size has fewer
+// units available to sell than the quantity held; the IDs of the items without enough stock
+// are returned with ErrInsufficientStock. Returns ErrTransactionTooLarge if the holds and their
+// items exceed the writes of a single transaction.
+func ReserveItems(DB *dynamo.DbInfo, holds []*store.InventoryHold) ([]string, error) {
+	if len(holds) == 0 {
+		return []string{}, nil
+	}
+	if len(holds)+len(groupHolds(holds)) > maxTransactItems {
+		return []string{}, fmt.Errorf(ErrTransactionTooLarge)
+	}
+	for retries := 0; ; retries++ {
+		outOfStock, items, err := reserveWrites(DB, holds)
+		if err != nil {
+			log.Printf("ReserveItems failed: %v", err)
+			return []string{}, err
+		}
+		if len(outOfStock) > 0 {
+			return outOfStock, fmt.Errorf(store.ErrInsufficientStock)
+		}
+
+		_, err = DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+		if err == nil {
+			return []string{}, nil
+		}
+		tce, ok := err.(*dynamodb.TransactionCanceledException)
+		if !ok {
+			log.Printf("ReserveItems failed: %v", err)
+			return []string{}, err
+		}
+		for i, reason := range tce.CancellationReasons {
+			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" && i < len(holds) {
+				// hold writes precede the item writes
+				return []string{}, fmt.Errorf(ErrConditionalCheck)
+			}
+		}
+		if retries >= maxReserveRetries {
+			log.Printf("ReserveItems failed: %v", err)
+			return []string{}, err
+		}
+		// reserved counts changed since read - retry with current counts
+	}
+}
+
+// reserveWrites reads the current counts of each held item and returns the transaction writes
+// placing the holds. Reserved counts are updated on the condition that they are unchanged since
+// they were read. Returns the IDs of items without enough units available to sell.
+func reserveWrites(DB *dynamo.DbInfo, holds []*store.InventoryHold) ([]string, []*dynamodb.TransactWriteItem, error) {
+	outOfStock := []string{}
+	items := []*dynamodb.TransactWriteItem{}
+	for _, h := range holds {
+		av, err := dynamodbattribute.MarshalMap(h)
+		if err != nil {
+			return outOfStock, items, err
+		}
+		cond := expression.AttributeNotExists(expression.Name(HoldsPK))
+		expr, err := expression.NewBuilder().WithCondition(cond).Build()
+		if err != nil {
+			return outOfStock, items, err
+		}
+		items = append(items, &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+			TableName:                 aws.String(HoldsTable()),
+			Item:                      av,
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		}})
+	}
+
+	for _, group := range groupHolds(holds) {
+		item, err := GetStoreItem(DB, group[0].Subcategory, group[0].ItemID)
+		if err != nil {
+			return outOfStock, items, err
+		}
+		counts := heldUnits(group)
+		sizes := sortedSizes(counts)
+		for _, size := range sizes {
+			if item.AvailableToSell(size) < counts[size] {
+				outOfStock = append(outOfStock, group[0].ItemID)
+				break
+			}
+		}
+		if len(outOfStock) > 0 {
+			continue
+		}
+		expr, err := reserveExpr(item, sizes, counts)
+		if err != nil {
+			return outOfStock, items, err
+		}
+		items = append(items, storeItemUpdate(group[0], expr))
+	}
+	return outOfStock, items, nil
+}
+
+// reserveExpr returns the expression adding the held units of each size to the item's
+// reserved counts, conditional on the counts read.
+func reserveExpr(item *store.StoreItem, sizes []string, counts map[string]int) (expression.Expression, error) {
+	reserved := expression.Name("units_reserved")
+	if len(item.UnitsReserved) == 0 {
+		// replace missing, null or empty map
+		update := expression.Set(reserved, expression.Value(counts))
+		cond := expression.Or(
+			expression.AttributeNotExists(reserved),
+			expression.AttributeType(reserved, expression.Null),
+			expression.Size(reserved).Equal(expression.Value(0)),
+		)
+		for _, size := range sizes {
+			onHand := expression.Name(fmt.Sprintf("units_available.%s", size))
+			cond = cond.And(onHand.GreaterThanEqual(expression.Value(counts[size])))
+		}
+		return expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	}
+
+	var update expression.UpdateBuilder
+	var cond expression.ConditionBuilder
+	for i, size := range sizes {
+		name := expression.Name(fmt.Sprintf("units_reserved.%s", size))
+		onHand := expression.Name(fmt.Sprintf("units_available.%s", size))
+		current, ok := item.UnitsReserved[size]
+		c := expression.AttributeNotExists(name)
+		if ok {
+			c = name.Equal(expression.Value(current))
+		}
+		c = c.And(onHand.GreaterThanEqual(expression.Value(current + counts[size])))
+		if i == 0 {
+			update = expression.Set(name, expression.Value(current+counts[size]))
+			cond = c
+			continue
+		}
+		update = update.Set(name, expression.Value(current+counts[size]))
+		cond = cond.And(c)
+	}
+	return expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+}
+
+// ConvertHolds converts active inventory holds to sales; the quantity of each hold is subtracted
+// from its item size's on-hand and reserved counts. Returns ErrHoldNotActive if any hold has
+// already been converted or released.
+func ConvertHolds(DB *dynamo.DbInfo, holds []*store.InventoryHold) error {
+	if err := settleHolds(DB, holds, store.HoldStatusActive, store.HoldStatusConverted); err != nil {
+		log.Printf("ConvertHolds failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ReleaseHolds releases active inventory holds; the quantity of each hold is subtracted from its
+// item size's reserved count. Returns ErrHoldNotActive if any hold has already been converted
+// or released.
+func ReleaseHolds(DB *dynamo.DbInfo, holds []*store.InventoryHold) error {
+	if err := settleHolds(DB, holds, store.HoldStatusActive, store.HoldStatusReleased); err != nil {
+		log.Printf("ReleaseHolds failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RestockHolds releases converted inventory holds of orders that were not paid; the quantity of
+// each hold is added back to its item size's on-hand count. Returns ErrHoldNotActive if any
+// hold is not converted, so units are restocked once.
+func RestockHolds(DB *dynamo.DbInfo, holds []*store.InventoryHold) error {
+	if err := settleHolds(DB, holds, store.HoldStatusConverted, store.HoldStatusReleased); err != nil {
+		log.Printf("RestockHolds failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// settleHolds moves each hold from one status to another and updates the held items' counts in
+// a single transaction: active holds are removed from the reserved counts, holds converted to
+// sales are removed from the on-hand counts, and released converted holds are added back.
+func settleHolds(DB *dynamo.DbInfo, holds []*store.InventoryHold, from, to string) error {
+	if len(holds) == 0 {
+		return nil
+	}
+	items := []*dynamodb.TransactWriteItem{}
+	for _, h := range holds {
+		update := expression.Set(expression.Name("hold_status"), expression.Value(to))
+		cond := expression.Name("hold_status").Equal(expression.Value(from))
+		expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+		if err != nil {
+			return err
+		}
+		items = append(items, &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+			TableName: aws.String(HoldsTable()),
+			Key: map[string]*dynamodb.AttributeValue{
+				HoldsPK: {S: aws.String(h.OrderID)},
+				HoldsSK: {S: aws.String(h.SizeID)},
+			},
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		}})
+	}
+
+	for _, group := range groupHolds(holds) {
+		counts := heldUnits(group)
+		update := expression.UpdateBuilder{}
+		cond := expression.AttributeExists(expression.Name(StoreItemPK))
+		for _, size := range sortedSizes(counts) {
+			q := expression.Value(counts[size])
+			reserved := expression.Name(fmt.Sprintf("units_reserved.%s", size))
+			onHand := expression.Name(fmt.Sprintf("units_available.%s", size))
+			if from == store.HoldStatusActive {
+				update = update.Set(reserved, expression.Minus(reserved, q))
+				cond = cond.And(reserved.GreaterThanEqual(q))
+			}
+			switch {
+			case to == store.HoldStatusConverted:
+				update = update.Set(onHand, expression.Minus(onHand, q))
+				cond = cond.And(onHand.GreaterThanEqual(q))
+			case from == store.HoldStatusConverted:
+				update = update.Set(onHand, expression.Plus(expression.IfNotExists(onHand, expression.Value(0)), q))
+			}
+		}
+		expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+		if err != nil {
+			return err
+		}
+		items = append(items, storeItemUpdate(group[0], expr))
+	}
+
+	_, err := DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		if tce, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for i, reason := range tce.CancellationReasons {
+				if reason.Code == nil || *reason.Code != "ConditionalCheckFailed" {
+					continue
+				}
+				if i < len(holds) {
+					return fmt.Errorf(store.ErrHoldNotActive)
+				}
+				return fmt.Errorf(ErrConditionalCheck)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// storeItemUpdate returns the transaction write updating the StoreItem of the hold with the
+// expression.
+func storeItemUpdate(h *store.InventoryHold, expr expression.Expression) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+		TableName: aws.String(StoreItemsTable()),
+		Key: map[string]*dynamodb.AttributeValue{
+			StoreItemPK: {S: aws.String(h.Subcategory)},
+			StoreItemSK: {S: aws.String(h.ItemID)},
+		},
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	}}
+}
+
+// groupHolds groups the holds by item, sorted by item key. A transaction may only write to
+// each item once.
+func groupHolds(holds []*store.InventoryHold) [][]*store.InventoryHold {
+	byItem := make(map[string][]*store.InventoryHold)
+	keys := []string{}
+	for _, h := range holds {
+		k := memKey(h.Subcategory, h.ItemID)
+		if _, ok := byItem[k]; !ok {
+			keys = append(keys, k)
+		}
+		byItem[k] = append(byItem[k], h)
+	}
+	sort.Strings(keys)
+	groups := [][]*store.InventoryHold{}
+	for _, k := range keys {
+		groups = append(groups, byItem[k])
+	}
+	return groups
+}
+
+// heldUnits returns the total quantity held of each size.
+func heldUnits(holds []*store.InventoryHold) map[string]int {
+	counts := make(map[string]int)
+	for _, h := range holds {
+		counts[h.Size] += h.Quantity
+	}
+	return counts
+}
+
+// sortedSizes returns the sizes of the counts in sorted order.
+func sortedSizes(counts map[string]int) []string {
+	sizes := []string{}
+	for size := range counts {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+	return sizes
+}
+
+// GetOrderHolds retreives the inventory holds placed for the order.
+func GetOrderHolds(DB *dynamo.DbInfo, orderID string) ([]*store.InventoryHold, error) {
+	holds := []*store.InventoryHold{}
+	keyCond := expression.Key(HoldsPK).Equal(expression.Value(orderID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		log.Printf("GetOrderHolds failed: %v", err)
+		return holds, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(HoldsTable()),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	var uerr error
+	err = DB.Svc.QueryPages(input, func(page *dynamodb.QueryOutput, last bool) bool {
+		for _, av := range page.Items {
+			h := &store.InventoryHold{}
+			if uerr = dynamodbattribute.UnmarshalMap(av, h); uerr != nil {
+				return false
+			}
+			holds = append(holds, h)
+		}
+		return true
+	})
+	if err == nil {
+		err = uerr
+	}
+	if err != nil {
+		log.Printf("GetOrderHolds failed: %v", err)
+		return holds, err
+	}
+	return holds, nil
+}
+
+// ScanExpiredHolds returns each active inventory hold that expired at or before now
+// (unix timestamp (s)).
+func ScanExpiredHolds(DB *dynamo.DbInfo, now int64) ([]*store.InventoryHold, error) {
+	holds := []*store.InventoryHold{}
+	filter := expression.Name("hold_status").Equal(expression.Value(store.HoldStatusActive)).
+		And(expression.Name("expires_at").LessThanEqual(expression.Value(now)))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		log.Printf("ScanExpiredHolds failed: %v", err)
+		return holds, err
+	}
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(HoldsTable()),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	var uerr error
+	err = DB.Svc.ScanPages(input, func(page *dynamodb.ScanOutput, last bool) bool {
+		for _, av := range page.Items {
+			h := &store.InventoryHold{}
+			if uerr = dynamodbattribute.UnmarshalMap(av, h); uerr != nil {
+				return false
+			}
+			holds = append(holds, h)
+		}
+		return true
+	})
+	if err == nil {
+		err = uerr
+	}
+	if err != nil {
+		log.Printf("ScanExpiredHolds failed: %v", err)
+		return holds, err
+	}
+	return holds, nil
+}
+
+// ConvertOrderHolds converts the order's active inventory holds to sales. Returns
+// ErrHoldNotActive if the order has no active holds, or if any hold expired before now
+// (unix timestamp (s)).
+func ConvertOrderHolds(s Store, orderID string, now int64) error {
+	holds, err := s.GetOrderHolds(orderID)
+	if err != nil {
+		log.Printf("ConvertOrderHolds failed: %v", err)
+		return err
+	}
+	if len(holds) == 0 {
+		return fmt.Errorf(store.ErrHoldNotActive)
+	}
+	for _, h := range holds {
+		if h.HoldStatus != store.HoldStatusActive || h.ExpiresAt <= now {
+			return fmt.Errorf(store.ErrHoldNotActive)
+		}
+	}
+	if err := s.ConvertHolds(holds); err != nil {
+		log.Printf("ConvertOrderHolds failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RestockOrderHolds restocks the units of the order's converted inventory holds (see
+// RestockHolds), used when a staged order's payment fails or the order is not staged. Holds
+// that were not converted, or were already restocked, are skipped.
+func RestockOrderHolds(s Store, orderID string) error {
+	holds, err := s.GetOrderHolds(orderID)
+	if err != nil {
+		log.Printf("RestockOrderHolds failed: %v", err)
+		return err
+	}
+	converted := []*store.InventoryHold{}
+	for _, h := range holds {
+		if h.HoldStatus == store.HoldStatusConverted {
+			converted = append(converted, h)
+		}
+	}
+	if err := s.RestockHolds(converted); err != nil {
+		log.Printf("RestockOrderHolds failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ReleaseExpiredHolds releases each active inventory hold that expired at or before now
+// (unix timestamp (s)), grouped by order. Returns the IDs of the orders whose holds were
+// released.
+func ReleaseExpiredHolds(s Store, now int64) ([]string, error) {
+	released := []string{}
+	holds, err := s.ScanExpiredHolds(now)
+	if err != nil {
+		log.Printf("ReleaseExpiredHolds failed: %v", err)
+		return released, err
+	}
+	byOrder := make(map[string][]*store.InventoryHold)
+	orderIDs := []string{}
+	for _, h := range holds {
+		if _, ok := byOrder[h.OrderID]; !ok {
+			orderIDs = append(orderIDs, h.OrderID)
+		}
+		byOrder[h.OrderID] = append(byOrder[h.OrderID], h)
+	}
+	sort.Strings(orderIDs)
+	for _, orderID := range orderIDs {
+		if err := s.ReleaseHolds(byOrder[orderID]); err != nil {
+			if err.Error() == store.ErrHoldNotActive {
+				continue // converted or released since scanned
+			}
+			log.Printf("ReleaseExpiredHolds failed: %v", err)
+			return released, err
+		}
+		released = append(released, orderID)
+	}
+	return released, nil
+}
+
+// Staging error codes
+const (
+	ErrOrderAlreadyStaged  = "ERR_ORDER_ALREADY_STAGED" // transaction record exists
+	ErrOrderConflict       = "ERR_ORDER_CONFLICT"       // order is not open for staging
+	ErrCustomerNotFound    = "ERR_CUSTOMER_NOT_FOUND"
+	ErrTransactionTooLarge = "ERR_TRANSACTION_TOO_LARGE"
+)
+
+// maxTransactItems is the maximum number of writes in a single DynamoDB transaction.
+const maxTransactItems = 100
+
+// OrderStage contains the objects written by StageOrder. Inventory contains the order items
+// whose units on hand are decremented when the order is staged; items reserved by inventory
+// holds are excluded, as their units are decremented when the holds are converted at payment.
+type OrderStage struct {
+	Order       *store.Order
+	Transaction *store.Transaction
+	Customer    *store.Customer
+	Inventory   []*store.CartItem
+}
+
+// StageOrder writes the staged Order, Transaction, Customer counters and inventory decrements in
+// a single transaction; nothing is written if any write fails. Writes are conditional:
+//   - ErrOrderAlreadyStaged is returned if the Transaction has already been written.
+//   - ErrOrderConflict is returned if the stored order is no longer open.
+//   - ErrCustomerNotFound is returned if the Customer does not exist.
+//   - ErrInsufficientStock is returned if any item has fewer units on hand than ordered.
+func StageOrder(DB *dynamo.DbInfo, stage *OrderStage) error {
+	items, codes, err := stageWrites(stage)
+	if err != nil {
+		log.Printf("StageOrder failed: %v", err)
+		return err
+	}
+	_, err = DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		err = cancellationErr(err, codes)
+		log.Printf("StageOrder failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// stageWrites returns the transaction writes for the stage and the error code returned when
+// each write's condition fails.
+func stageWrites(stage *OrderStage) ([]*dynamodb.TransactWriteItem, []string, error) {
+	items := []*dynamodb.TransactWriteItem{}
+	codes := []string{}
+	if 3+len(stage.Inventory) > maxTransactItems {
+		return items, codes, fmt.Errorf(ErrTransactionTooLarge)
+	}
+
+	// transaction - written once
+	cond := expression.AttributeNotExists(expression.Name(TransactionsSK))
+	put, err := conditionalPut(TransactionsTable(), stage.Transaction, cond)
+	if err != nil {
+		return items, codes, err
+	}
+	items = append(items, put)
+	codes = append(codes, ErrOrderAlreadyStaged)
+
+	// order - replaces open order created at checkout; guarded by the order's status rather
+	// than its version, as the staged order is a snapshot taken at payment
+	cond = expression.Or(
+		expression.AttributeNotExists(expression.Name(OrdersSK)),
+		expression.Name("order_status").Equal(expression.Value(store.OrderStatusOpen)),
+	)
+	order := *stage.Order
+	order.Version++
+	put, err = conditionalPut(OrdersTable(), &order, cond)
+	if err != nil {
+		return items, codes, err
+	}
+	items = append(items, put)
+	codes = append(codes, ErrOrderConflict)
+
+	// customer counters & saved addresses
+	update := expression.Add(expression.Name("purchases"), expression.Value(stage.Order.TotalItems)).
+		Set(expression.Name("billing_address"), expression.Value(stage.Customer.BillingAddress)).
+		Set(expression.Name("shipping_address"), expression.Value(stage.Customer.ShippingAddress)).
+		Add(expression.Name("version"), expression.Value(1))
+	cond = expression.AttributeExists(expression.Name(CustomersPK))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return items, codes, err
+	}
+	items = append(items, &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+		TableName:                 aws.String(CustomersTable),
+		Key:                       map[string]*dynamodb.AttributeValue{CustomersPK: {S: aws.String(stage.Customer.Email)}},
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	}})
+	codes = append(codes, ErrCustomerNotFound)
+
+	// inventory decrements
+	holds := []*store.InventoryHold{}
+	for _, item := range stage.Inventory {
+		holds = append(holds, &store.InventoryHold{Subcategory: item.Subcategory, ItemID: item.ItemID, Size: item.Size, Quantity: item.Quantity})
+	}
+	for _, group := range groupHolds(holds) {
+		counts := heldUnits(group)
+		var update expression.UpdateBuilder
+		var cond expression.ConditionBuilder
+		for i, size := range sortedSizes(counts) {
+			q := expression.Value(counts[size])
+			onHand := expression.Name(fmt.Sprintf("units_available.%s", size))
+			if i == 0 {
+				update = expression.Set(onHand, expression.Minus(onHand, q))
+				cond = onHand.GreaterThanEqual(q)
+				continue
+			}
+			update = update.Set(onHand, expression.Minus(onHand, q))
+			cond = cond.And(onHand.GreaterThanEqual(q))
+		}
+		expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+		if err != nil {
+			return items, codes, err
+		}
+		items = append(items, storeItemUpdate(group[0], expr))
+		codes = append(codes, store.ErrInsufficientStock)
+	}
+	return items, codes, nil
+}
+
+// conditionalPut returns the transaction write putting v to the table on the given condition.
+func conditionalPut(table string, v interface{}, cond expression.ConditionBuilder) (*dynamodb.TransactWriteItem, error) {
+	av, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+		TableName:                 aws.String(table),
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}}, nil
+}
+
+// cancellationErr decodes the cancellation reasons of a canceled transaction. codes contains the
+// error code returned for each write of the transaction, in order; the code of the first write
+// whose condition failed is returned. Other errors are returned unchanged.
+func cancellationErr(err error, codes []string) error {
+	tce, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok {
+		return err
+	}
+	for i, reason := range tce.CancellationReasons {
+		if reason.Code == nil || *reason.Code != "ConditionalCheckFailed" {
+			continue
+		}
+		if i < len(codes) {
+			return fmt.Errorf(codes[i])
+		}
+		return fmt.Errorf(ErrConditionalCheck)
+	}
+	return err
+}
+
+// ErrVersionConflict contains the error code for writes to versioned objects that were modified
+// since they were read.
+const ErrVersionConflict = "ERR_VERSION_CONFLICT"
+
+// VersionConflictError is returned when a versioned object (ShoppingCart, Order or Customer) is
+// written after the stored object was modified by another request.
+type VersionConflictError struct {
+	Table   string
+	Key     string
+	Version int // version the object was read at
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: %s %s at version %d", ErrVersionConflict, e.Table, e.Key, e.Version)
+}
+
+// IsVersionConflict returns true if err is a *VersionConflictError.
+func IsVersionConflict(err error) bool {
+	var vc *VersionConflictError
+	return errors.As(err, &vc)
+}
+
+// versionCondition returns the condition that the stored object's version equals v. Objects
+// written before versions were added have no version attribute and match version 0.
+func versionCondition(v int) expression.ConditionBuilder {
+	cond := expression.Name("version").Equal(expression.Value(v))
+	if v == 0 {
+		return expression.Or(cond, expression.AttributeNotExists(expression.Name("version")))
+	}
+	return cond
+}
+
+// putVersioned puts the item to the table on the condition that the stored item's version
+// equals prior. Returns a *VersionConflictError if the condition fails.
+func putVersioned(DB *dynamo.DbInfo, table, key string, item interface{}, prior int) error {
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+	expr, err := expression.NewBuilder().WithCondition(versionCondition(prior)).Build()
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:                 aws.String(table),
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	_, err = DB.Svc.PutItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return &VersionConflictError{Table: table, Key: key, Version: prior}
+		}
+		return err
+	}
+	return nil
+}
+
+// ConflictRetries is the number of times RetryOnConflict calls its function.
+const ConflictRetries = 3
+
+// conflictBackoff is the delay before retrying a function, multiplied by the attempt number.
+var conflictBackoff = 20 * time.Millisecond
+
+// RetryOnConflict calls fn until it succeeds, returns an error other than a *VersionConflictError,
+// or has been called ConflictRetries times. fn must read the versioned objects it modifies on
+// each call, so that each attempt applies its changes to the latest versions.
+func RetryOnConflict(fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= ConflictRetries; attempt++ {
+		err = fn()
+		if err == nil || !IsVersionConflict(err) {
+			return err
+		}
+		log.Printf("RetryOnConflict: attempt %d: %v", attempt, err)
+		time.Sleep(time.Duration(attempt) * conflictBackoff)
+	}
+	return err
+}
+
+// GetCartStoreItems returns the StoreItems of the cart's items, keyed by item ID. Items that no
+// longer exist are omitted.
+func GetCartStoreItems(s Store, cart *store.ShoppingCart) (map[string]*store.StoreItem, error) {
+	items := make(map[string]*store.StoreItem)
+	for _, line := range cart.Items {
+		if items[line.ItemID] != nil {
+			continue
+		}
+		item, err := s.GetStoreItem(line.Subcategory, line.ItemID)
+		if err != nil {
+			log.Printf("GetCartStoreItems failed: %v", err)
+			return items, err
+		}
+		if item.ItemID != "" {
+			items[line.ItemID] = item
+		}
+	}
+	return items, nil
+}
+
+// GetRecalculatedCart returns the user's cart with prices, totals and weights recalculated from
+// the current StoreItems (see store.ShoppingCart.Recalculate). The cart is not written.
+func GetRecalculatedCart(s Store, userID string) (*store.ShoppingCart, error) {
+	cart, err := s.GetShoppingCart(userID)
+	if err != nil {
+		log.Printf("GetRecalculatedCart failed: %v", err)
+		return &store.ShoppingCart{}, err
+	}
+	if cart.UserID == "" {
+		cart.UserID = userID
+	}
+	items, err := GetCartStoreItems(s, cart)
+	if err != nil {
+		log.Printf("GetRecalculatedCart failed: %v", err)
+		return &store.ShoppingCart{}, err
+	}
+	cart.Recalculate(items)
+	return cart, nil
+}
+
+// UpdateCart applies fn to the user's cart, recalculates the cart from the current StoreItems
+// and puts the cart. The cart's update time and expiration are reset (see CartTTL). The cart is re-read and fn re-applied if the cart is updated concurrently
+// (see RetryOnConflict). Errors returned by fn are returned unchanged.
+func UpdateCart(s Store, userID string, fn func(cart *store.ShoppingCart) error) (*store.ShoppingCart, error) {
+	var cart *store.ShoppingCart
+	err := RetryOnConflict(func() error {
+		c, err := s.GetShoppingCart(userID)
+		if err != nil {
+			return err
+		}
+		if c.UserID == "" {
+			c.UserID = userID
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+		items, err := GetCartStoreItems(s, c)
+		if err != nil {
+			return err
+		}
+		c.Recalculate(items)
+		c.Touch(time.Now(), CartTTL())
+		if err := s.PutShoppingCart(c); err != nil {
+			return err
+		}
+		cart = c
+		return nil
+	})
+	if err != nil {
+		log.Printf("UpdateCart failed: %v", err)
+		return &store.ShoppingCart{}, err
+	}
+	return cart, nil
+}
+
+// Cart merge environment variables; unset variables use store.DefaultMergeRules.
+const (
+	EnvarCartMergeQuantity    = "CART_MERGE_QUANTITY"     // SUM, MAX or KEEP
+	EnvarCartMergeCapAtStock  = "CART_MERGE_CAP_AT_STOCK" // true or false
+	EnvarCartMergeNewestPrice = "CART_MERGE_NEWEST_PRICE" // true or false
+)
+
+// MergeRulesFromEnv returns the cart merge rules set by the CART_MERGE_* environment variables.
+// Unset or invalid values use the default rule.
+func MergeRulesFromEnv() store.MergeRules {
+	rules := store.DefaultMergeRules
+	switch q := os.Getenv(EnvarCartMergeQuantity); q {
+	case store.MergeQuantitySum, store.MergeQuantityMax, store.MergeQuantityKeep:
+		rules.Quantity = q
+	}
+	if b, err := strconv.ParseBool(os.Getenv(EnvarCartMergeCapAtStock)); err == nil {
+		rules.CapAtStock = b
+	}
+	if b, err := strconv.ParseBool(os.Getenv(EnvarCartMergeNewestPrice)); err == nil {
+		rules.NewestPrice = b
+	}
+	return rules
+}
+
+// MergeGuestCart merges the guest's cart into the user's cart with the given rules and deletes
+// the guest cart in the same write (see PutMergedCart). The carts are re-read and merged again if
+// either is updated concurrently; a guest cart that was already merged is not merged again.
+// Returns the user's recalculated cart.
+func MergeGuestCart(s Store, guestID, userID string, rules store.MergeRules) (*store.ShoppingCart, error) {
+	var cart *store.ShoppingCart
+	err := RetryOnConflict(func() error {
+		guest, err := s.GetShoppingCart(guestID)
+		if err != nil {
+			return err
+		}
+		if len(guest.Items) == 0 {
+			cart, err = GetRecalculatedCart(s, userID)
+			return err
+		}
+		if guest.UserID == "" {
+			guest.UserID = guestID
+		}
+		guestItems, err := GetCartStoreItems(s, guest)
+		if err != nil {
+			return err
+		}
+		c, err := s.GetShoppingCart(userID)
+		if err != nil {
+			return err
+		}
+		if c.UserID == "" {
+			c.UserID = userID
+		}
+		c.Merge(guest, guestItems, rules)
+		items, err := GetCartStoreItems(s, c)
+		if err != nil {
+			return err
+		}
+		c.Recalculate(items)
+		c.Touch(time.Now(), CartTTL())
+		if err := s.PutMergedCart(c, guest); err != nil {
+			return err
+		}
+		cart = c
+		return nil
+	})
+	if err != nil {
+		log.Printf("MergeGuestCart failed: %v", err)
+		return &store.ShoppingCart{}, err
+	}
+	return cart, nil
+}
+
+// EnvarCartTTLDays contains the environment variable of the number of days carts are retained
+// after their last update.
+const EnvarCartTTLDays = "CART_TTL_DAYS"
+
+// defaultCartTTL is the retention of carts if CART_TTL_DAYS is unset or invalid.
+const defaultCartTTL = 60 * 24 * time.Hour
+
+// CartTTL returns the duration carts are retained after their last update before the
+// ShoppingCartsTable's TTL expires them.
+func CartTTL() time.Duration {
+	days, err := strconv.Atoi(os.Getenv(EnvarCartTTLDays))
+	if err != nil || days <= 0 {
+		return defaultCartTTL
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetDownloadGrant returns the user's DownloadGrant. Returns an empty DownloadGrant if the grant
+// does not exist.
+func GetDownloadGrant(DB *dynamo.DbInfo, userID, grantID string) (*store.DownloadGrant, error) {
+	q := dynamo.CreateNewQueryObj(userID, grantID)
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[DownloadsTable()], &store.DownloadGrant{}, expr)
+	if err != nil {
+		log.Printf("GetDownloadGrant failed: %v", err)
+		return &store.DownloadGrant{}, err
+	}
+	return item.(*store.DownloadGrant), nil
+}
+
+// GetOrderDownloads returns the DownloadGrants issued for the user's order.
+func GetOrderDownloads(DB *dynamo.DbInfo, userID, orderID string) ([]*store.DownloadGrant, error) {
+	grants := []*store.DownloadGrant{}
+	keyCond := expression.Key(DownloadsPK).Equal(expression.Value(userID)).
+		And(expression.Key(DownloadsSK).BeginsWith(store.GrantIDPrefix(orderID)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		log.Printf("GetOrderDownloads failed: %v", err)
+		return grants, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(DownloadsTable()),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	var uerr error
+	err = DB.Svc.QueryPages(input, func(page *dynamodb.QueryOutput, last bool) bool {
+		for _, av := range page.Items {
+			g := &store.DownloadGrant{}
+			if uerr = dynamodbattribute.UnmarshalMap(av, g); uerr != nil {
+				return false
+			}
+			grants = append(grants, g)
+		}
+		return true
+	})
+	if err == nil {
+		err = uerr
+	}
+	if err != nil {
+		log.Printf("GetOrderDownloads failed: %v", err)
+		return grants, err
+	}
+	return grants, nil
+}
+
+// PutDownloadGrant puts a new DownloadGrant to the Downloads table. Returns ErrConditionalCheck
+// if the grant was already issued, so redelivered payment events do not reset download counts.
+func PutDownloadGrant(DB *dynamo.DbInfo, grant *store.DownloadGrant) error {
+	item, err := dynamodbattribute.MarshalMap(grant)
+	if err != nil {
+		log.Printf("PutDownloadGrant failed: %v", err)
+		return err
+	}
+	cond := expression.AttributeNotExists(expression.Name(DownloadsSK))
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		log.Printf("PutDownloadGrant failed: %v", err)
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(DownloadsTable()),
+		Item:                     item,
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}
+	_, err = DB.Svc.PutItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf(ErrConditionalCheck)
+		}
+		log.Printf("PutDownloadGrant failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// IssueDownloads issues a DownloadGrant for each digital item of the paid order (see
+// store.NewDownloadGrants). Items with a grant issued for the order are not issued again, and
+// their issued grants are returned.
+func IssueDownloads(s Store, order *store.Order, now time.Time) ([]*store.DownloadGrant, error) {
+	items, err := getStoreItems(s, order.DigitalItems())
+	if err != nil {
+		log.Printf("IssueDownloads failed: %v", err)
+		return []*store.DownloadGrant{}, err
+	}
+	issued, err := s.GetOrderDownloads(order.UserID, order.OrderID)
+	if err != nil {
+		log.Printf("IssueDownloads failed: %v", err)
+		return []*store.DownloadGrant{}, err
+	}
+	newGrants, err := store.NewDownloadGrants(order, items, now)
+	if err != nil {
+		log.Printf("IssueDownloads failed: %v", err)
+		return []*store.DownloadGrant{}, err
+	}
+	grants := []*store.DownloadGrant{}
+	for _, g := range newGrants {
+		if prev := findGrant(issued, g.ItemID, g.Tier); prev != nil {
+			grants = append(grants, prev)
+			continue
+		}
+		err := s.PutDownloadGrant(g)
+		if err != nil {
+			log.Printf("IssueDownloads failed: %v", err)
+			return grants, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+// findGrant returns the grant of the item's license tier, or nil if no grant was issued.
+func findGrant(grants []*store.DownloadGrant, itemID, tier string) *store.DownloadGrant {
+	for _, g := range grants {
+		if g.ItemID == itemID && g.Tier == tier {
+			return g
+		}
+	}
+	return nil
+}
+
+// UseDownload decrements the downloads remaining of the user's grant and returns the updated
+// grant. Returns ErrDownloadNotFound, ErrDownloadExpired or ErrDownloadLimitReached if the
+// grant cannot be downloaded at now.
+func UseDownload(s Store, userID, grantID string, now time.Time) (*store.DownloadGrant, error) {
+	u := NewDownloadGrantUpdate(userID, grantID).
+		Increment("downloads_remaining", -1).
+		IfExists().
+		If(GreaterThanEqual("downloads_remaining", 1)).
+		If(GreaterThanEqual("expires_at", int(now.Unix()+1)))
+	err := s.UpdateItem(u)
+	if err != nil && err.Error() != ErrConditionalCheck {
+		log.Printf("UseDownload failed: %v", err)
+		return &store.DownloadGrant{}, err
+	}
+	grant, gerr := s.GetDownloadGrant(userID, grantID)
+	if gerr != nil {
+		log.Printf("UseDownload failed: %v", gerr)
+		return &store.DownloadGrant{}, gerr
+	}
+	if err == nil {
+		return grant, nil
+	}
+	if grant.GrantID == "" {
+		return grant, fmt.Errorf(store.ErrDownloadNotFound)
+	}
+	if aerr := grant.Available(now); aerr != nil {
+		return grant, aerr
+	}
+	return grant, err // updated concurrently
+}
+
+// getStoreItems returns the StoreItems of the given order or cart items, keyed by item ID.
+// Items that no longer exist are omitted.
+func getStoreItems(s Store, lines []*store.CartItem) (map[string]*store.StoreItem, error) {
+	items := make(map[string]*store.StoreItem)
+	for _, line := range lines {
+		if items[line.ItemID] != nil {
+			continue
+		}
+		item, err := s.GetStoreItem(line.Subcategory, line.ItemID)
+		if err != nil {
+			return items, err
+		}
+		if item.ItemID != "" {
+			items[line.ItemID] = item
+		}
+	}
+	return items, nil
+}
+
+// SellExclusive marks the digital item's exclusive license as sold to the order and removes
+// the item from its subcategory's StoreItemIndex and the StoreItemsSummary table in a single
+// transaction. The item remains in the StoreItems table so existing download grants are kept.
+// Returns store.ErrExclusiveSold if the license was sold to another order; selling the
+// license to the same order again succeeds.
+func SellExclusive(DB *dynamo.DbInfo, subcat, itemID, orderID string) error {
+	for retries := 0; ; retries++ {
+		index, err := GetStoreItemIndex(DB, subcat)
+		if err != nil {
+			log.Printf("SellExclusive failed: %v", err)
+			return err
+		}
+		items, err := sellExclusiveWrites(index, subcat, itemID, orderID)
+		if err != nil {
+			log.Printf("SellExclusive failed: %v", err)
+			return err
+		}
+
+		_, err = DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+		if err == nil {
+			return nil
+		}
+		tce, ok := err.(*dynamodb.TransactionCanceledException)
+		if !ok {
+			log.Printf("SellExclusive failed: %v", err)
+			return err
+		}
+		reasons := tce.CancellationReasons
+		if len(reasons) > 0 && reasons[0].Code != nil && *reasons[0].Code == "ConditionalCheckFailed" {
+			// item write precedes the summary & index writes
+			return exclusiveSoldErr(DB, subcat, itemID, orderID)
+		}
+		if retries >= maxReserveRetries {
+			log.Printf("SellExclusive failed: %v", err)
+			return err
+		}
+		// index changed since read - retry with current index
+	}
+}
+
+// sellExclusiveWrites returns the transaction writes of SellExclusive. The item is removed
+// from the index by position, on the condition that the index is unchanged at that position.
+func sellExclusiveWrites(index *store.StoreItemIndex, subcat, itemID, orderID string) ([]*dynamodb.TransactWriteItem, error) {
+	sold := expression.Name("sold_exclusive")
+	update := expression.Set(sold, expression.Value(true)).
+		Set(expression.Name("exclusive_order_id"), expression.Value(orderID))
+	cond := expression.AttributeExists(expression.Name(StoreItemSK)).
+		And(expression.Or(expression.AttributeNotExists(sold), sold.Equal(expression.Value(false))))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return nil, err
+	}
+	items := []*dynamodb.TransactWriteItem{
+		{Update: &dynamodb.Update{
+			TableName: aws.String(StoreItemsTable()),
+			Key: map[string]*dynamodb.AttributeValue{
+				StoreItemPK: {S: aws.String(subcat)},
+				StoreItemSK: {S: aws.String(itemID)},
+			},
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		}},
+		{Delete: &dynamodb.Delete{
+			TableName: aws.String(StoreItemsSummaryTable()),
+			Key: map[string]*dynamodb.AttributeValue{
+				StoreItemSummaryPK: {S: aws.String(subcat)},
+				StoreItemSummarySK: {S: aws.String(itemID)},
+			},
+		}},
+	}
+
+	for i, id := range index.ItemIDs {
+		if id != itemID {
+			continue
+		}
+		pos := expression.Name(fmt.Sprintf("item_ids[%d]", i))
+		expr, err := expression.NewBuilder().
+			WithUpdate(expression.Remove(pos)).
+			WithCondition(pos.Equal(expression.Value(itemID))).
+			Build()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+			TableName: aws.String(StoreItemsIndexTable()),
+			Key: map[string]*dynamodb.AttributeValue{
+				StoreItemsIndexPK: {S: aws.String(subcat)},
+			},
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		}})
+		break
+	}
+	return items, nil
+}
+
+// exclusiveSoldErr returns the error of a SellExclusive call whose item condition failed: nil if
+// the license was already sold to the order, store.ErrItemNotFound if the item does not exist
+// and store.ErrExclusiveSold otherwise.
+func exclusiveSoldErr(DB *dynamo.DbInfo, subcat, itemID, orderID string) error {
+	item, err := GetStoreItem(DB, subcat, itemID)
+	if err != nil {
+		log.Printf("SellExclusive failed: %v", err)
+		return err
+	}
+	switch {
+	case item.ItemID == "":
+		return fmt.Errorf(store.ErrItemNotFound)
+	case item.ExclusiveOrderID == orderID:
+		return nil // sold to order on a previous attempt
+	}
+	return fmt.Errorf(store.ErrExclusiveSold)
+}
+
+// SellExclusives sells the exclusive license of each exclusive digital item of the paid order
+// (see SellExclusive). Returns the order's items whose exclusive license was sold to the order,
+// and the items that can no longer be licensed to the order: exclusive licenses sold to another
+// order, and other licenses of items whose exclusive license was sold to another order before a
+// download was issued for the license.
+func SellExclusives(s Store, order *store.Order) (sold, lost []*store.CartItem, err error) {
+	sold, lost = []*store.CartItem{}, []*store.CartItem{}
+	items, err := getStoreItems(s, order.DigitalItems())
+	if err != nil {
+		log.Printf("SellExclusives failed: %v", err)
+		return sold, lost, err
+	}
+	issued, err := s.GetOrderDownloads(order.UserID, order.OrderID)
+	if err != nil {
+		log.Printf("SellExclusives failed: %v", err)
+		return sold, lost, err
+	}
+	for _, line := range order.DigitalItems() {
+		item := items[line.ItemID]
+		if item == nil {
+			continue
+		}
+		if !item.IsExclusive(line.Size) {
+			if item.SoldExclusive && item.ExclusiveOrderID != order.OrderID && findGrant(issued, line.ItemID, line.Size) == nil {
+				lost = append(lost, line)
+			}
+			continue
+		}
+		err := s.SellExclusive(line.Subcategory, line.ItemID, order.OrderID)
+		if err != nil {
+			if err.Error() == store.ErrExclusiveSold {
+				lost = append(lost, line)
+				continue
+			}
+			log.Printf("SellExclusives failed: %v", err)
+			return sold, lost, err
+		}
+		sold = append(sold, line)
+	}
+	return sold, lost, nil
+}
+
+// GetContract returns the user's Contract. Returns an empty Contract if the contract does not
+// exist.
+func GetContract(DB *dynamo.DbInfo, userID, contractID string) (*store.Contract, error) {
+	q := dynamo.CreateNewQueryObj(userID, contractID)
+	expr := dynamo.NewExpression()
+	item, err := dynamo.GetItem(DB.Svc, q, DB.Tables[ContractsTable()], &store.Contract{}, expr)
+	if err != nil {
+		log.Printf("GetContract failed: %v", err)
+		return &store.Contract{}, err
+	}
+	return item.(*store.Contract), nil
+}
+
+// PutContract puts a new Contract to the Contracts table. Returns ErrConditionalCheck if the
+// contract exists, so signed contracts are never replaced.
+func PutContract(DB *dynamo.DbInfo, contract *store.Contract) error {
+	item, err := dynamodbattribute.MarshalMap(contract)
+	if err != nil {
+		log.Printf("PutContract failed: %v", err)
+		return err
+	}
+	cond := expression.AttributeNotExists(expression.Name(ContractsSK))
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		log.Printf("PutContract failed: %v", err)
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(ContractsTable()),
+		Item:                     item,
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}
+	_, err = DB.Svc.PutItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf(ErrConditionalCheck)
+		}
+		log.Printf("PutContract failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SignContract records the signature of the contract signed with store.Contract.Sign and
+// attaches the contract to its order in a single transaction. The rendered agreement is removed
+// from the contract record once the signed agreement is stored at the contract's DocumentKey.
+// Returns store.ErrContractSigned if the contract was already signed, and ErrConditionalCheck if
+// the order is no longer open.
+func SignContract(s Store, c *store.Contract) error {
+	u := NewContractUpdate(c.UserID, c.ContractID).
+		Set("status", store.ContractStatusSigned).
+		Set("signature", c.Signature).
+		Set("signed_at", c.SignedAt).
+		Set("signer_ip", c.SignerIP).
+		Set("document_key", c.DocumentKey).
+		Remove("html").
+		IfExists().
+		If(Equal("status", store.ContractStatusPending))
+	o := NewOrderUpdate(c.UserID, c.OrderID).
+		Append("contracts", c.Ref()).
+		IfExists().
+		If(Equal("order_status", store.OrderStatusOpen))
+	err := s.UpdateItems(u, o)
+	if err != nil {
+		if err.Error() != ErrConditionalCheck {
+			log.Printf("SignContract failed: %v", err)
+			return err
+		}
+		current, gerr := s.GetContract(c.UserID, c.ContractID)
+		if gerr != nil {
+			log.Printf("SignContract failed: %v", gerr)
+			return gerr
+		}
+		if current.Status == store.ContractStatusSigned {
+			return fmt.Errorf(store.ErrContractSigned)
+		}
+		return err
+	}
+	return nil
 }
@@ -3,37 +3,61 @@ package dbops
 import (
 	"fmt"
 	"log"
-	"os"
 	"sync"
 	"testing"
 
 	"github.com/tpillz-presents/service/store-api/store"
 )
 
-func TestPutStoreItem(t *testing.T) {
-	var tests = []struct {
-		category       string
-		subcat         string
-		itemID         string
-		name           string
-		price          float32
-		unitsSold      int
-		unitsAvailable map[string]int
-	}{
-		{category: "games", subcat: "game_sets", itemID: "001", name: "PawnWars Game Set", price: 29.95, unitsSold: 0, unitsAvailable: map[string]int{"OS": 10}},
-		{category: "games", subcat: "game_sets", itemID: "002", name: "PawnWars Chess Pieces", price: 14.95, unitsSold: 0, unitsAvailable: map[string]int{"OS": 10}},
-		{category: "artwork", subcat: "posters", itemID: "003", name: "PawnWars King Poster", price: 17.95, unitsSold: 0, unitsAvailable: map[string]int{"OS": 20}},
-		{category: "artwork", subcat: "posters", itemID: "004", name: "PawnWars Queen Poster", price: 17.95, unitsSold: 0, unitsAvailable: map[string]int{"OS": 20}},
-		{category: "clothing", subcat: "shirts", itemID: "005", name: "ACamoPrjct Logo T-Shirt", price: 22.95, unitsSold: 0, unitsAvailable: map[string]int{"S": 10, "M": 8, "L": 6, "XL": 4}},
+// testItems contains the StoreItem fixtures written by newTestStore.
+var testItems = []struct {
+	category       string
+	subcat         string
+	itemID         string
+	name           string
+	price          store.Money
+	unitsSold      int
+	unitsAvailable map[string]int
+}{
+	{category: "games", subcat: "game_sets", itemID: "001", name: "PawnWars Game Set", price: store.USD(2995), unitsSold: 0, unitsAvailable: map[string]int{"OS": 10}},
+	{category: "games", subcat: "game_sets", itemID: "002", name: "PawnWars Chess Pieces", price: store.USD(1495), unitsSold: 0, unitsAvailable: map[string]int{"OS": 10}},
+	{category: "artwork", subcat: "posters", itemID: "003", name: "PawnWars King Poster", price: store.USD(1795), unitsSold: 0, unitsAvailable: map[string]int{"OS": 20}},
+	{category: "artwork", subcat: "posters", itemID: "004", name: "PawnWars Queen Poster", price: store.USD(1795), unitsSold: 0, unitsAvailable: map[string]int{"OS": 1}},
+	{category: "clothing", subcat: "shirts", itemID: "005", name: "ACamoPrjct Logo T-Shirt", price: store.USD(2295), unitsSold: 0, unitsAvailable: map[string]int{"S": 10, "M": 8, "L": 6, "XL": 4}},
+}
+
+// newTestStore returns a new MemStore containing the testItems fixtures and their summaries.
+func newTestStore(t *testing.T) Store {
+	s := NewMemStore()
+	for _, test := range testItems {
+		item := &store.StoreItem{
+			ItemID:         test.itemID,
+			Name:           test.name,
+			Category:       test.category,
+			Subcategory:    test.subcat,
+			Price:          test.price,
+			UnitsAvailable: test.unitsAvailable,
+		}
+		if err := s.PutStoreItem(item); err != nil {
+			t.Fatalf("FAIL - put item: %v", err)
+		}
+		summary := &store.StoreItemSummary{
+			ItemID:      test.itemID,
+			Subcategory: test.subcat,
+			Name:        test.name,
+			Price:       test.price,
+		}
+		if err := s.PutStoreItemSummary(summary); err != nil {
+			t.Fatalf("FAIL - put summary: %v", err)
+		}
 	}
+	return s
+}
 
-	// init db
-	os.Setenv(EnvarStoreItemsTable, "tpillz-store-items-dev")
-	table := NewTable(StoreItemsTable(), StoreItemPK, StoreItemSK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+func TestPutStoreItem(t *testing.T) {
+	s := NewMemStore()
 
-	for _, test := range tests {
+	for _, test := range testItems {
 		item := &store.StoreItem{
 			ItemID:      test.itemID,
 			Name:        test.name,
@@ -43,10 +67,17 @@ func TestPutStoreItem(t *testing.T) {
 			// UnitsSold:      test.unitsSold,
 			UnitsAvailable: test.unitsAvailable,
 		}
-		err := PutStoreItem(dbInfo, item)
+		err := s.PutStoreItem(item)
 		if err != nil {
 			t.Errorf("FAIL: %v; want: nil", err)
 		}
+		got, err := s.GetStoreItem(test.subcat, test.itemID)
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		if got.Name != test.name || got.UnitsAvailable["OS"] != test.unitsAvailable["OS"] {
+			t.Errorf("FAIL - DATA: %v; want: %v", got, item)
+		}
 	}
 }
 
@@ -63,14 +94,10 @@ func TestGetStoreItem(t *testing.T) {
 		{subcat: "shirts", itemID: "007", wantName: ""}, // non existent item
 	}
 
-	os.Setenv(EnvarStoreItemsTable, "tpillz-store-items-dev")
-
-	table := NewTable(StoreItemsTable(), StoreItemPK, StoreItemSK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 
 	for _, test := range tests {
-		item, err := GetStoreItem(dbInfo, test.subcat, test.itemID)
+		item, err := s.GetStoreItem(test.subcat, test.itemID)
 		if err != nil {
 			t.Errorf("FAIL: %v", err)
 		}
@@ -91,11 +118,7 @@ func TestCheckInventory(t *testing.T) {
 		{item: &store.CartItem{Subcategory: "pants", ItemID: "010", Size: "32", Quantity: 1}, want: false},    // Non existent partition
 	}
 
-	os.Setenv(EnvarStoreItemsTable, "tpillz-store-items-dev")
-
-	table := NewTable(StoreItemsTable(), StoreItemPK, StoreItemSK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 	bc := make(chan map[string]bool)
 	ec := make(chan error)
 	var wg sync.WaitGroup
@@ -105,7 +128,7 @@ func TestCheckInventory(t *testing.T) {
 	for _, test := range tests {
 		wg.Add(1)
 		wantMap[test.item.ItemID] = test.want
-		go checkInventory(dbInfo, test.item, bc, ec, &wg)
+		go checkInventory(s, test.item, bc, ec, &wg)
 	}
 
 	br := 0
@@ -157,14 +180,10 @@ func TestUpdateInventoryCount(t *testing.T) {
 		{subcat: "shirts", itemID: "007", sizeKey: "OS", count: 2, wantErr: fmt.Errorf(ErrConditionalCheck)},  // ITEM DOES NOT EXIST
 	}
 
-	os.Setenv(EnvarStoreItemsTable, "tpillz-store-items-dev")
-
-	table := NewTable(StoreItemsTable(), StoreItemPK, StoreItemSK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 
 	for _, test := range tests {
-		id, err := UpdateInventoryCount(dbInfo, test.subcat, test.itemID, test.sizeKey, test.count)
+		id, err := s.UpdateInventoryCount(test.subcat, test.itemID, test.sizeKey, test.count)
 		t.Logf("out of stock ID: %s", id)
 		if err != nil && test.wantErr == nil {
 			t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
@@ -193,14 +212,10 @@ func TestUpdateStoreItemObj(t *testing.T) {
 		// {subcat: "posters", itemID: "003", fieldName: "", value: ""},                    // empty values -> BuildOperand error
 	}
 
-	os.Setenv(EnvarStoreItemsTable, "tpillz-store-items-dev")
-
-	table := NewTable(StoreItemsTable(), StoreItemPK, StoreItemSK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 
 	for _, test := range tests {
-		err := UpdateStoreItem(dbInfo, test.subcat, test.itemID, test.fieldName, test.value)
+		err := s.UpdateStoreItem(test.subcat, test.itemID, test.fieldName, test.value)
 		if err != nil {
 			t.Errorf("FAIL: %v", err)
 		}
@@ -223,14 +238,10 @@ func TestUpdateStoreItemSummary(t *testing.T) {
 		// {subcat: "posters", itemID: "003", fieldName: "", value: ""},                    // empty values
 	}
 
-	os.Setenv(EnvarStoreItemsSummaryTable, "tpillz-store-items-summary-dev")
-
-	table := NewTable(StoreItemsSummaryTable(), StoreItemSummaryPK, StoreItemSummarySK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 
 	for _, test := range tests {
-		err := UpdateStoreItemSummary(dbInfo, test.subcat, test.itemID, test.fieldName, test.value)
+		err := s.UpdateStoreItemSummary(test.subcat, test.itemID, test.fieldName, test.value)
 		if err != nil {
 			t.Errorf("FAIL: %v", err)
 		}
@@ -246,40 +257,37 @@ func TestDeleteStoreItem(t *testing.T) {
 		{subcat: "posters", itemID: "003", err: nil},
 		{subcat: "posters", itemID: "009", err: nil},
 	}
-	os.Setenv(EnvarStoreItemsTable, "tpillz-store-items-dev")
-	table := NewTable(StoreItemsTable(), StoreItemPK, StoreItemSK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 
 	for _, test := range tests {
-		err := DeleteStoreItem(dbInfo, test.subcat, test.itemID)
+		err := s.DeleteStoreItem(test.subcat, test.itemID)
 		if err != nil {
 			t.Errorf("FAIL: %v", err)
 		}
 	}
 }
 
-// ScanItems failed: ValidationException: The provided starting key is invalid: The provided key element does not match the schema
 func TestScanItems(t *testing.T) {
 	var tests = []struct {
 		subcat string
+		want   int
 		err    error
 	}{
-		{subcat: "game_sets", err: nil},
-		{subcat: "posters", err: nil},
-		{subcat: "", err: nil},
+		{subcat: "game_sets", want: 2, err: nil},
+		{subcat: "posters", want: 2, err: nil},
+		{subcat: "", want: 0, err: nil},
 	}
 
-	os.Setenv(EnvarStoreItemsSummaryTable, "tpillz-store-items-summary-dev")
-	table := NewTable(StoreItemsSummaryTable(), StoreItemSummaryPK, StoreItemSummarySK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 
 	for _, test := range tests {
-		items, err := ScanItemsForCategory(dbInfo, test.subcat)
+		items, err := s.ScanItemsForCategory(test.subcat)
 		if err != nil {
 			t.Errorf("FAIL: %v", err)
 		}
+		if len(items) != test.want {
+			t.Errorf("FAIL - len(items): %d; want: %d", len(items), test.want)
+		}
 		for _, item := range items {
 			t.Logf("item: %v", item)
 		}
@@ -290,26 +298,82 @@ func TestBatchGetStoreItemSummary(t *testing.T) {
 	var tests = []struct {
 		subcat string
 		ids    []string
+		want   int
 		err    error
 	}{
-		{subcat: "game_sets", ids: []string{"PCS-69d48d59", "PCP-65d655cb"}, err: nil}, // id values from existing db items
-		{subcat: "posters", ids: []string{"PSP-44984760"}, err: nil},
+		{subcat: "game_sets", ids: []string{"001", "002"}, want: 2, err: nil},
+		{subcat: "posters", ids: []string{"003", "009"}, want: 1, err: nil}, // non existent item omitted
 		// {subcat: "", ids: []string{}, err: nil},
 	}
 
-	os.Setenv(EnvarStoreItemsSummaryTable, "tpillz-store-items-summary-dev")
-	table := NewTable(StoreItemsSummaryTable(), StoreItemSummaryPK, StoreItemSummarySK)
-	tables := []Table{table}
-	dbInfo := InitDB(tables)
+	s := newTestStore(t)
 
 	for _, test := range tests {
-		items, err := BatchGetStoreItemSummary(dbInfo, test.subcat, test.ids)
+		items, err := s.BatchGetStoreItemSummary(test.subcat, test.ids)
 		t.Logf("len(items): %v", len(items))
 		if err != nil {
 			t.Errorf("FAIL: %v", err)
 		}
+		if len(items) != test.want {
+			t.Errorf("FAIL - len(items): %d; want: %d", len(items), test.want)
+		}
 		for _, item := range items {
 			t.Logf("item: %v", item)
 		}
 	}
 }
+
+func TestBrowseItems(t *testing.T) {
+	var tests = []struct {
+		subcat string
+		opts   store.BrowseOptions
+		want   []string
+		next   bool
+		err    string
+	}{
+		{subcat: "game_sets", opts: store.BrowseOptions{Sort: store.SortPrice}, want: []string{"002", "001"}, next: false, err: ""},
+		{subcat: "game_sets", opts: store.BrowseOptions{Sort: store.SortPrice, Limit: 1}, want: []string{"002"}, next: true, err: ""},
+		{subcat: "game_sets", opts: store.BrowseOptions{MinPrice: store.USD(2000)}, want: []string{"001"}, next: false, err: ""},
+		{subcat: "posters", opts: store.BrowseOptions{Sort: store.SortName, Order: store.SortDesc}, want: []string{"004", "003"}, next: false, err: ""},
+		{subcat: "hats", opts: store.BrowseOptions{}, want: []string{}, next: false, err: ""},
+		{subcat: "posters", opts: store.BrowseOptions{Sort: "rating"}, err: store.ErrInvalidBrowseOptions},
+		{subcat: "posters", opts: store.BrowseOptions{Cursor: "abc"}, err: store.ErrInvalidCursor},
+	}
+
+	s := newTestStore(t)
+
+	for _, test := range tests {
+		page, err := BrowseItems(s, test.subcat, test.opts)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("FAIL: %v; want: %v", err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL: %v", err)
+			continue
+		}
+		got := []string{}
+		for _, item := range page.Items {
+			got = append(got, item.ItemID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+		}
+		if (page.NextCursor != "") != test.next {
+			t.Errorf("FAIL - next cursor: %q; want: %v", page.NextCursor, test.next)
+		}
+	}
+}
+
+func TestScanStoreItems(t *testing.T) {
+	s := newTestStore(t)
+	items, err := s.ScanStoreItems()
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if len(items) != len(testItems) {
+		t.Errorf("FAIL - len(items): %d; want: %d", len(items), len(testItems))
+	}
+}
@@ -0,0 +1,110 @@
+package dbops
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/go-aws/go-dynamo/dynamo"
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// Tests in this file run against DynamoDB Local and are skipped unless the DYNAMODB_ENDPOINT
+// environment variable is set (ex: DYNAMODB_ENDPOINT=http://localhost:8000 go test ./...).
+
+// newLocalDB creates uniquely named tables for the test in DynamoDB Local and returns the
+// initialized DbInfo. Tables are deleted when the test completes.
+func newLocalDB(t *testing.T) *dynamo.DbInfo {
+	if os.Getenv(EnvarDynamoEndpoint) == "" {
+		t.Skipf("%s not set - skipping DynamoDB Local test", EnvarDynamoEndpoint)
+	}
+	suffix := fmt.Sprintf("-%d", time.Now().UnixNano())
+	tables := []Table{
+		{Name: "customers" + suffix, PrimaryKey: CustomersPK},
+		{Name: "orders" + suffix, PrimaryKey: OrdersPK, SortKey: OrdersSK},
+		{Name: "transactions" + suffix, PrimaryKey: TransactionsPK, SortKey: TransactionsSK},
+		{Name: "store-items" + suffix, PrimaryKey: StoreItemPK, SortKey: StoreItemSK},
+		{Name: "inventory-holds" + suffix, PrimaryKey: HoldsPK, SortKey: HoldsSK},
+	}
+	envars := []string{EnvarOrdersTable, EnvarTransactionsTable, EnvarStoreItemsTable, EnvarHoldsTable}
+	customers := CustomersTable
+	CustomersTable = tables[0].Name
+	for i, envar := range envars {
+		prev := os.Getenv(envar)
+		os.Setenv(envar, tables[i+1].Name)
+		t.Cleanup(func() { os.Setenv(envar, prev) })
+	}
+
+	DB := InitDB(tables)
+	for _, table := range tables {
+		input := &dynamodb.CreateTableInput{
+			TableName:   aws.String(table.Name),
+			BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{AttributeName: aws.String(table.PrimaryKey), AttributeType: aws.String("S")},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String(table.PrimaryKey), KeyType: aws.String("HASH")},
+			},
+		}
+		if table.SortKey != "" {
+			input.AttributeDefinitions = append(input.AttributeDefinitions,
+				&dynamodb.AttributeDefinition{AttributeName: aws.String(table.SortKey), AttributeType: aws.String("S")})
+			input.KeySchema = append(input.KeySchema,
+				&dynamodb.KeySchemaElement{AttributeName: aws.String(table.SortKey), KeyType: aws.String("RANGE")})
+		}
+		if _, err := DB.Svc.CreateTable(input); err != nil {
+			t.Fatalf("FAIL - create table %s: %v", table.Name, err)
+		}
+	}
+	t.Cleanup(func() {
+		CustomersTable = customers
+		for _, table := range tables {
+			DB.Svc.DeleteTable(&dynamodb.DeleteTableInput{TableName: aws.String(table.Name)})
+		}
+	})
+	return DB
+}
+
+func TestStageOrderLocal(t *testing.T) {
+	DB := newLocalDB(t)
+	s := NewDynamoStore(DB)
+	s.PutStoreItem(&store.StoreItem{Subcategory: "shirts", ItemID: "005", UnitsAvailable: map[string]int{"M": 8}})
+	s.PutCustomer(&store.Customer{UserID: "user001", Email: "user001@example.com", Purchases: 3})
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-2", OrderStatus: store.OrderStatusPaid})
+
+	var tests = []struct {
+		stage   func(st *OrderStage)
+		wantErr string
+	}{
+		{stage: func(st *OrderStage) { st.Customer.Email = "none@example.com" }, wantErr: ErrCustomerNotFound},
+		{stage: func(st *OrderStage) { st.Inventory[0].Quantity = 9 }, wantErr: store.ErrInsufficientStock},
+		{stage: func(st *OrderStage) { st.Order.OrderID, st.Transaction.TransactionID = "user001-2", "tx002" }, wantErr: ErrOrderConflict},
+		{stage: func(st *OrderStage) {}},
+		{stage: func(st *OrderStage) {}, wantErr: ErrOrderAlreadyStaged},
+	}
+	for _, test := range tests {
+		stage := newTestStage()
+		test.stage(stage)
+		err := s.StageOrder(stage)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+	}
+
+	cust, _ := s.GetCustomer("user001@example.com")
+	if cust.Purchases != 5 || cust.ShippingAddress.City != "Austin" {
+		t.Errorf("FAIL - customer: %d purchases, %v", cust.Purchases, cust.ShippingAddress)
+	}
+	item, _ := s.GetStoreItem("shirts", "005")
+	if item.UnitsAvailable["M"] != 6 {
+		t.Errorf("FAIL - units: %d; want: 6", item.UnitsAvailable["M"])
+	}
+}
@@ -0,0 +1,1240 @@
+package dbops
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// MemStore table names
+const (
+	memContracts    = "contracts"
+	memCustomers    = "customers"
+	memDownloads    = "downloads"
+	memOrders       = "orders"
+	memOpenOrders   = "open_orders"
+	memParcels      = "parcels"
+	memPromotions   = "promotions"
+	memRedemptions  = "promotion_redemptions"
+	memReturns      = "returns"
+	memShipments    = "shipments"
+	memShipRules    = "shipping_rules"
+	memCarts        = "shopping_carts"
+	memItems        = "store_items"
+	memItemsIndex   = "store_items_index"
+	memItemsSummary = "store_items_summary"
+	memHolds        = "inventory_holds"
+	memLocations    = "locations"
+	memTracking     = "tracking"
+	memTransactions = "transactions"
+	memWebhooks     = "webhook_events"
+)
+
+// ErrInvalidPath contains the error code for update expressions targeting a nested
+// attribute whose parent is not a map.
+const ErrInvalidPath = "ERR_INVALID_PATH"
+
+// document represents a single table record in the MemStore, keyed by JSON attribute name.
+type document map[string]interface{}
+
+// MemStore implements the Store interface in memory. Objects are stored as JSON documents
+// keyed by their primary and sort key values, so attribute names used in updates match the
+// names used by the DynamoDB tables. MemStore is safe for concurrent use.
+type MemStore struct {
+	mu     sync.RWMutex
+	tables map[string]map[string]document // table name: key: document
+}
+
+// NewMemStore returns a new, empty *MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{tables: make(map[string]map[string]document)}
+}
+
+// memKey returns the MemStore key for the given primary and sort key values.
+func memKey(pk, sk string) string {
+	return pk + "#" + sk
+}
+
+// toDocument converts v to a document with the JSON encoding used by the store package.
+func toDocument(v interface{}) (document, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := document{}
+	if err := json.Unmarshal(js, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// fromDocument decodes a document into the model pointed to by v.
+func fromDocument(doc document, v interface{}) error {
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(js, v)
+}
+
+// getString returns the string value of the given document attribute.
+func (d document) getString(name string) string {
+	s, _ := d[name].(string)
+	return s
+}
+
+// put writes v to the given table, keyed by the values of the pk and sk attributes.
+func (m *MemStore) put(table, pk, sk string, v interface{}) error {
+	doc, err := toDocument(v)
+	if err != nil {
+		return err
+	}
+	key := memKey(doc.getString(pk), "")
+	if sk != "" {
+		key = memKey(doc.getString(pk), doc.getString(sk))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables[table] == nil {
+		m.tables[table] = make(map[string]document)
+	}
+	m.tables[table][key] = doc
+	return nil
+}
+
+// putVersioned increments *version and writes v to the given table on the condition that the
+// stored document's version equals the prior version. *version is restored and a
+// *VersionConflictError returned if the condition fails.
+func (m *MemStore) putVersioned(table, pk, sk string, version *int, v interface{}) error {
+	prior := *version
+	*version++
+	doc, err := toDocument(v)
+	if err != nil {
+		*version = prior
+		return err
+	}
+	key := memKey(doc.getString(pk), "")
+	if sk != "" {
+		key = memKey(doc.getString(pk), doc.getString(sk))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables[table] == nil {
+		m.tables[table] = make(map[string]document)
+	}
+	if stored, ok := m.tables[table][key]; ok {
+		n, _ := stored["version"].(float64)
+		if int(n) != prior {
+			*version = prior
+			return &VersionConflictError{Table: table, Key: key, Version: prior}
+		}
+	}
+	m.tables[table][key] = doc
+	return nil
+}
+
+// get decodes the document with the given keys into v. v is left unchanged if the document
+// does not exist, matching the behavior of dynamo.GetItem for missing items.
+func (m *MemStore) get(table, pk, sk string, v interface{}) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	doc, ok := m.tables[table][memKey(pk, sk)]
+	if !ok {
+		return nil
+	}
+	return fromDocument(doc, v)
+}
+
+// delete removes the document with the given keys. Deleting a missing document is not an error.
+func (m *MemStore) delete(table, pk, sk string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tables[table], memKey(pk, sk))
+}
+
+// normalize returns value's JSON representation, matching the values of stored documents.
+func normalize(value interface{}) (interface{}, error) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var val interface{}
+	if err := json.Unmarshal(js, &val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// UpdateItem applies the Update to its document if each of the update's condition groups
+// passes. Returns ErrConditionalCheck if a condition fails. Updating a missing document creates
+// it, as UpdateItem does in DynamoDB. No attributes are updated if any action fails.
+func (m *MemStore) UpdateItem(u *Update) error {
+	return m.UpdateItems(u)
+}
+
+// UpdateItems applies the Updates to their documents if the conditions of every update pass.
+// No document is updated if any condition or action fails.
+func (m *MemStore) UpdateItems(updates ...*Update) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs := make([]document, len(updates))
+	for i, u := range updates {
+		doc, err := m.updated(u)
+		if err != nil {
+			return err
+		}
+		docs[i] = doc
+	}
+	for i, u := range updates {
+		if docs[i] == nil {
+			continue
+		}
+		if m.tables[u.memTable] == nil {
+			m.tables[u.memTable] = make(map[string]document)
+		}
+		m.tables[u.memTable][memKey(u.pk, u.sk)] = docs[i]
+	}
+	return nil
+}
+
+// updated returns a copy of the Update's document with the update applied, or nil if the
+// update has no actions. The caller must hold the write lock.
+func (m *MemStore) updated(u *Update) (document, error) {
+	if len(u.ops) == 0 {
+		return nil, nil
+	}
+	current, ok := m.tables[u.memTable][memKey(u.pk, u.sk)]
+	if !ok {
+		current = document{u.pkName: u.pk}
+		if u.skName != "" {
+			current[u.skName] = u.sk
+		}
+	}
+	for _, group := range u.conds {
+		pass := false
+		for _, c := range group {
+			if c.eval(current, ok) {
+				pass = true
+				break
+			}
+		}
+		if !pass {
+			return nil, fmt.Errorf(ErrConditionalCheck)
+		}
+	}
+
+	doc, err := toDocument(current) // copy
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range u.ops {
+		parent, name, err := resolvePath(doc, op.path)
+		if err != nil {
+			return nil, err
+		}
+		val, err := normalize(op.value)
+		if err != nil {
+			return nil, err
+		}
+		switch op.op {
+		case opSet:
+			parent[name] = val
+		case opRemove:
+			delete(parent, name)
+		case opIncrement:
+			n, _ := parent[name].(float64)
+			parent[name] = n + val.(float64)
+		case opAppend:
+			list, _ := parent[name].([]interface{})
+			parent[name] = append(list, val.([]interface{})...)
+		}
+	}
+	if u.versioned {
+		version, _ := doc["version"].(float64)
+		doc["version"] = version + 1
+	}
+	return doc, nil
+}
+
+// eval returns true if the condition passes for the document. exists is false if the document
+// has not been written.
+func (c Condition) eval(doc document, exists bool) bool {
+	var val interface{}
+	found := false
+	if parent, name, err := resolvePath(doc, c.Path); err == nil && exists {
+		val, found = parent[name]
+	}
+	switch c.Op {
+	case CondExists:
+		return found
+	case CondNotExists:
+		return !found
+	case CondGreaterThanEqual:
+		n, ok := val.(float64)
+		want, _ := normalize(c.Value)
+		return ok && n >= want.(float64)
+	}
+	want, err := normalize(c.Value)
+	return found && err == nil && reflect.DeepEqual(val, want)
+}
+
+// resolvePath returns the map containing the attribute at the given dot notation path and
+// the attribute's name within that map.
+func resolvePath(doc document, path string) (map[string]interface{}, string, error) {
+	parts := strings.Split(path, ".")
+	current := map[string]interface{}(doc)
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := current[p].(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf(ErrInvalidPath)
+		}
+		current = next
+	}
+	return current, parts[len(parts)-1], nil
+}
+
+// scan returns each document in the table where the given attribute equals value, sorted by key.
+func (m *MemStore) scan(table, attr, value string) []document {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for k, doc := range m.tables[table] {
+		if doc.getString(attr) == value {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	docs := []document{}
+	for _, k := range keys {
+		docs = append(docs, m.tables[table][k])
+	}
+	return docs
+}
+
+func (m *MemStore) GetStoreItem(subcategory, itemID string) (*store.StoreItem, error) {
+	item := &store.StoreItem{}
+	if err := m.get(memItems, subcategory, itemID, item); err != nil {
+		log.Printf("GetStoreItem failed: %v", err)
+		return &store.StoreItem{}, err
+	}
+	return item, nil
+}
+
+func (m *MemStore) PutStoreItem(item *store.StoreItem) error {
+	return m.put(memItems, StoreItemPK, StoreItemSK, item)
+}
+
+func (m *MemStore) UpdateStoreItem(subcat, itemID, field string, value interface{}) error {
+	return m.UpdateItem(NewStoreItemUpdate(subcat, itemID).Set(field, value))
+}
+
+func (m *MemStore) DeleteStoreItem(subcategory, itemID string) error {
+	m.delete(memItems, subcategory, itemID)
+	return nil
+}
+
+func (m *MemStore) ScanStoreItems() ([]*store.StoreItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for k := range m.tables[memItems] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := []*store.StoreItem{}
+	for _, k := range keys {
+		item := &store.StoreItem{}
+		if err := fromDocument(m.tables[memItems][k], item); err != nil {
+			log.Printf("ScanStoreItems failed: %v", err)
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// UpdateInventoryCount decrements the units available for the given size by count on the
+// condition that the current quantity is greater than or equal to count. Returns the ItemID
+// and a ConditionalCheck error if the item is out of stock or does not exist.
+func (m *MemStore) UpdateInventoryCount(subcat, itemID, sizeKey string, count int) (string, error) {
+	err := m.UpdateItem(inventoryUpdate(subcat, itemID, sizeKey, count))
+	if err != nil {
+		if err.Error() == ErrConditionalCheck {
+			return itemID, err
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// RestockItem increments the units available for the given size by count. Returns a
+// ConditionalCheck error if the item does not exist.
+func (m *MemStore) RestockItem(subcat, itemID, sizeKey string, count int) error {
+	return m.UpdateItem(restockUpdate(subcat, itemID, sizeKey, count))
+}
+
+func (m *MemStore) GetStoreItemSummary(subcategory, itemID string) (*store.StoreItemSummary, error) {
+	item := &store.StoreItemSummary{}
+	if err := m.get(memItemsSummary, subcategory, itemID, item); err != nil {
+		log.Printf("GetStoreItemSummary failed: %v", err)
+		return &store.StoreItemSummary{}, err
+	}
+	return item, nil
+}
+
+func (m *MemStore) BatchGetStoreItemSummary(subcategory string, itemIDs []string) ([]*store.StoreItemSummary, error) {
+	results := []*store.StoreItemSummary{}
+	for _, id := range itemIDs {
+		item, err := m.GetStoreItemSummary(subcategory, id)
+		if err != nil {
+			log.Printf("BatchGetStoreItemSummary failed: %v", err)
+			return results, err
+		}
+		if item.ItemID == "" {
+			continue // not found
+		}
+		results = append(results, item)
+	}
+	return results, nil
+}
+
+func (m *MemStore) PutStoreItemSummary(item *store.StoreItemSummary) error {
+	return m.put(memItemsSummary, StoreItemSummaryPK, StoreItemSummarySK, item)
+}
+
+func (m *MemStore) UpdateStoreItemSummary(subcat, itemID, field string, value interface{}) error {
+	return m.UpdateItem(NewStoreItemSummaryUpdate(subcat, itemID).Set(field, value))
+}
+
+func (m *MemStore) DeleteStoreItemSummary(subcategory, itemID string) error {
+	m.delete(memItemsSummary, subcategory, itemID)
+	return nil
+}
+
+func (m *MemStore) ScanItemsForCategory(subcat string) ([]store.StoreItemSummary, error) {
+	items := []store.StoreItemSummary{}
+	for _, doc := range m.scan(memItemsSummary, StoreItemSummaryPK, subcat) {
+		item := store.StoreItemSummary{}
+		if err := fromDocument(doc, &item); err != nil {
+			log.Printf("ScanItemsForCategory failed: %v", err)
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (m *MemStore) QueryItemsForCategory(subcat string) ([]store.StoreItemSummary, error) {
+	return m.ScanItemsForCategory(subcat)
+}
+
+// SellExclusive marks the item's exclusive license as sold to the order and removes the item
+// from its index and summaries. Returns store.ErrExclusiveSold if the license was sold to
+// another order.
+func (m *MemStore) SellExclusive(subcat, itemID, orderID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.tables[memItems][memKey(subcat, itemID)]
+	if !ok {
+		return fmt.Errorf(store.ErrItemNotFound)
+	}
+	if sold, _ := doc["sold_exclusive"].(bool); sold {
+		if doc.getString("exclusive_order_id") == orderID {
+			return nil
+		}
+		return fmt.Errorf(store.ErrExclusiveSold)
+	}
+	doc["sold_exclusive"] = true
+	doc["exclusive_order_id"] = orderID
+	delete(m.tables[memItemsSummary], memKey(subcat, itemID))
+	if index, ok := m.tables[memItemsIndex][memKey(subcat, "")]; ok {
+		ids, _ := index["item_ids"].([]interface{})
+		kept := []interface{}{}
+		for _, id := range ids {
+			if id != itemID {
+				kept = append(kept, id)
+			}
+		}
+		index["item_ids"] = kept
+	}
+	return nil
+}
+
+func (m *MemStore) GetStoreItemIndex(subcategory string) (*store.StoreItemIndex, error) {
+	index := &store.StoreItemIndex{}
+	if err := m.get(memItemsIndex, subcategory, "", index); err != nil {
+		log.Printf("GetStoreItemIndex failed: %v", err)
+		return &store.StoreItemIndex{}, err
+	}
+	return index, nil
+}
+
+func (m *MemStore) PutStoreItemIndex(item *store.StoreItemIndex) error {
+	return m.put(memItemsIndex, StoreItemsIndexPK, "", item)
+}
+
+func (m *MemStore) GetShoppingCart(userID string) (*store.ShoppingCart, error) {
+	cart := &store.ShoppingCart{}
+	if err := m.get(memCarts, userID, "", cart); err != nil {
+		log.Printf("GetShoppingCart failed: %v", err)
+		return &store.ShoppingCart{}, err
+	}
+	if len(cart.Items) == 0 {
+		cart.Items = make(map[string]*store.CartItem)
+	}
+	return cart, nil
+}
+
+func (m *MemStore) PutShoppingCart(cart *store.ShoppingCart) error {
+	return m.putVersioned(memCarts, ShoppingCartsPK, "", &cart.Version, cart)
+}
+
+func (m *MemStore) DeleteShoppingCart(userID string) error {
+	m.delete(memCarts, userID, "")
+	return nil
+}
+
+func (m *MemStore) PutMergedCart(cart, guest *store.ShoppingCart) error {
+	doc, err := toDocument(cart)
+	if err != nil {
+		return err
+	}
+	doc["version"] = float64(cart.Version + 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables[memCarts] == nil {
+		m.tables[memCarts] = make(map[string]document)
+	}
+	for _, c := range []*store.ShoppingCart{cart, guest} {
+		n, _ := m.tables[memCarts][memKey(c.UserID, "")]["version"].(float64)
+		if int(n) != c.Version {
+			return &VersionConflictError{Table: memCarts, Key: memKey(c.UserID, ""), Version: c.Version}
+		}
+	}
+	m.tables[memCarts][memKey(cart.UserID, "")] = doc
+	delete(m.tables[memCarts], memKey(guest.UserID, ""))
+	cart.Version++
+	return nil
+}
+
+func (m *MemStore) ScanIdleCarts(before int64) ([]*store.ShoppingCart, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for k := range m.tables[memCarts] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	carts := []*store.ShoppingCart{}
+	for _, k := range keys {
+		cart := &store.ShoppingCart{}
+		if err := fromDocument(m.tables[memCarts][k], cart); err != nil {
+			log.Printf("ScanIdleCarts failed: %v", err)
+			return carts, err
+		}
+		if cart.UserEmail != "" && cart.UpdatedAt <= before {
+			carts = append(carts, cart)
+		}
+	}
+	return carts, nil
+}
+
+func (m *MemStore) GetOrder(userID, orderID string) (*store.Order, error) {
+	order := &store.Order{}
+	if err := m.get(memOrders, userID, orderID, order); err != nil {
+		log.Printf("GetOrder failed: %v", err)
+		return &store.Order{}, err
+	}
+	return order, nil
+}
+
+func (m *MemStore) GetOrderItems(userID, orderID string) (*store.Order, error) {
+	order, err := m.GetOrder(userID, orderID)
+	if err != nil {
+		log.Printf("GetOrderItems failed: %v", err)
+		return &store.Order{}, err
+	}
+	return &store.Order{Items: order.Items}, nil
+}
+
+func (m *MemStore) PutOrder(order *store.Order) error {
+	return m.putVersioned(memOrders, OrdersPK, OrdersSK, &order.Version, order)
+}
+
+func (m *MemStore) UpdateOrderAddress(userID, orderID string, addr store.Address, shipping bool) error {
+	return m.UpdateItem(orderAddressUpdate(userID, orderID, addr, shipping))
+}
+
+// UpdateOrderStatus sets the order's status to 'to' and updates the status flags on the
+// condition that the order exists and its current status is 'from'.
+func (m *MemStore) UpdateOrderStatus(userID, orderID, from, to string) error {
+	return m.UpdateItem(orderStatusUpdate(userID, orderID, from, to))
+}
+
+func (m *MemStore) UpdateOrderPaymentStatus(customerID, orderID, status string) error {
+	return m.UpdateItem(NewOrderUpdate(customerID, orderID).Set("payment_status", status))
+}
+
+func (m *MemStore) ScanDeliveredOrders(before int64) ([]*store.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for k := range m.tables[memOrders] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	orders := []*store.Order{}
+	for _, k := range keys {
+		order := &store.Order{}
+		if err := fromDocument(m.tables[memOrders][k], order); err != nil {
+			log.Printf("ScanDeliveredOrders failed: %v", err)
+			return orders, err
+		}
+		if order.OrderStatus == store.OrderStatusDelivered && order.DeliveredAt <= before {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (m *MemStore) GetOpenOrder(userID, orderID string) (*store.Order, error) {
+	order := &store.Order{}
+	if err := m.get(memOpenOrders, userID, orderID, order); err != nil {
+		log.Printf("GetOpenOrder failed: %v", err)
+		return &store.Order{}, err
+	}
+	return order, nil
+}
+
+func (m *MemStore) PutOpenOrder(order *store.Order) error {
+	return m.put(memOpenOrders, OpenOrdersPK, OpenOrdersSK, order)
+}
+
+func (m *MemStore) DeleteOpenOrder(userID, orderID string) error {
+	m.delete(memOpenOrders, userID, orderID)
+	return nil
+}
+
+func (m *MemStore) GetReturn(userID, returnID string) (*store.Return, error) {
+	ret := &store.Return{}
+	if err := m.get(memReturns, userID, returnID, ret); err != nil {
+		log.Printf("GetReturn failed: %v", err)
+		return &store.Return{}, err
+	}
+	return ret, nil
+}
+
+func (m *MemStore) PutReturn(ret *store.Return) error {
+	return m.put(memReturns, ReturnsPK, ReturnsSK, ret)
+}
+
+func (m *MemStore) GetTransaction(userID, txID string) (*store.Transaction, error) {
+	tx := &store.Transaction{}
+	if err := m.get(memTransactions, userID, txID, tx); err != nil {
+		log.Printf("GetTransaction failed: %v", err)
+		return &store.Transaction{}, err
+	}
+	return tx, nil
+}
+
+func (m *MemStore) PutTransaction(tx *store.Transaction) error {
+	return m.put(memTransactions, TransactionsPK, TransactionsSK, tx)
+}
+
+func (m *MemStore) UpdateTxPaymentStatus(customerID, txID, status string) error {
+	return m.UpdateItem(NewTransactionUpdate(customerID, txID).Set("payment_status", status))
+}
+
+func (m *MemStore) UpdateTxPaymentMethod(customerID, txID, method string) error {
+	return m.UpdateItem(NewTransactionUpdate(customerID, txID).Set("payment_method", method))
+}
+
+func (m *MemStore) UpdateTxPaymentID(customerID, txID, paymentID string) error {
+	return m.UpdateItem(NewTransactionUpdate(customerID, txID).Set("payment_tx_id", paymentID))
+}
+
+func (m *MemStore) GetCustomer(email string) (*store.Customer, error) {
+	cust := &store.Customer{}
+	if err := m.get(memCustomers, email, "", cust); err != nil {
+		log.Printf("GetCustomer failed: %v", err)
+		return &store.Customer{}, err
+	}
+	return cust, nil
+}
+
+func (m *MemStore) PutCustomer(user *store.Customer) error {
+	return m.putVersioned(memCustomers, CustomersPK, "", &user.Version, user)
+}
+
+func (m *MemStore) GetParcels(carrier string) ([]*store.Parcel, error) {
+	parcels := []*store.Parcel{}
+	for _, doc := range m.scan(memParcels, ParcelsPK, carrier) {
+		parcel := &store.Parcel{}
+		if err := fromDocument(doc, parcel); err != nil {
+			log.Printf("GetParcels failed: %v", err)
+			return parcels, err
+		}
+		parcels = append(parcels, parcel)
+	}
+	return parcels, nil
+}
+
+func (m *MemStore) PutParcel(parcel *store.Parcel) error {
+	return m.put(memParcels, ParcelsPK, ParcelsSK, parcel)
+}
+
+func (m *MemStore) GetShipment(userID, orderID string) (*store.Shipment, error) {
+	shipment := &store.Shipment{}
+	if err := m.get(memShipments, userID, orderID, shipment); err != nil {
+		log.Printf("GetShipment failed: %v", err)
+		return &store.Shipment{}, err
+	}
+	return shipment, nil
+}
+
+func (m *MemStore) PutShipment(shipment *store.Shipment) error {
+	return m.put(memShipments, ShipmentsPK, ShipmentsSK, shipment)
+}
+
+func (m *MemStore) GetTrackingRef(trackingNumber string) (*store.TrackingRef, error) {
+	ref := &store.TrackingRef{}
+	if err := m.get(memTracking, trackingNumber, "", ref); err != nil {
+		log.Printf("GetTrackingRef failed: %v", err)
+		return &store.TrackingRef{}, err
+	}
+	return ref, nil
+}
+
+func (m *MemStore) PutTrackingRef(ref *store.TrackingRef) error {
+	return m.put(memTracking, TrackingPK, "", ref)
+}
+
+// PutWebhookEvent records the event on the condition that the event ID has not been recorded.
+func (m *MemStore) PutWebhookEvent(event *store.WebhookEvent) error {
+	doc, err := toDocument(event)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables[memWebhooks] == nil {
+		m.tables[memWebhooks] = make(map[string]document)
+	}
+	key := memKey(event.EventID, "")
+	if _, ok := m.tables[memWebhooks][key]; ok {
+		return fmt.Errorf(ErrConditionalCheck)
+	}
+	m.tables[memWebhooks][key] = doc
+	return nil
+}
+
+func (m *MemStore) DeleteWebhookEvent(eventID string) error {
+	m.delete(memWebhooks, eventID, "")
+	return nil
+}
+
+func (m *MemStore) GetPromotion(code string) (*store.Promotion, error) {
+	promo := &store.Promotion{}
+	if err := m.get(memPromotions, code, "", promo); err != nil {
+		log.Printf("GetPromotion failed: %v", err)
+		return &store.Promotion{}, err
+	}
+	return promo, nil
+}
+
+func (m *MemStore) PutPromotion(promo *store.Promotion) error {
+	return m.put(memPromotions, PromotionsPK, "", promo)
+}
+
+func (m *MemStore) DeletePromotion(code string) error {
+	m.delete(memPromotions, code, "")
+	return nil
+}
+
+func (m *MemStore) ScanPromotions() ([]*store.Promotion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for k := range m.tables[memPromotions] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	promos := []*store.Promotion{}
+	for _, k := range keys {
+		promo := &store.Promotion{}
+		if err := fromDocument(m.tables[memPromotions][k], promo); err != nil {
+			log.Printf("ScanPromotions failed: %v", err)
+			return promos, err
+		}
+		promos = append(promos, promo)
+	}
+	return promos, nil
+}
+
+func (m *MemStore) GetShippingRule(ruleID string) (*store.ShippingRule, error) {
+	rule := &store.ShippingRule{}
+	if err := m.get(memShipRules, ruleID, "", rule); err != nil {
+		log.Printf("GetShippingRule failed: %v", err)
+		return &store.ShippingRule{}, err
+	}
+	return rule, nil
+}
+
+func (m *MemStore) PutShippingRule(rule *store.ShippingRule) error {
+	return m.put(memShipRules, ShippingRulesPK, "", rule)
+}
+
+func (m *MemStore) DeleteShippingRule(ruleID string) error {
+	m.delete(memShipRules, ruleID, "")
+	return nil
+}
+
+func (m *MemStore) ScanShippingRules() ([]*store.ShippingRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for k := range m.tables[memShipRules] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rules := []*store.ShippingRule{}
+	for _, k := range keys {
+		rule := &store.ShippingRule{}
+		if err := fromDocument(m.tables[memShipRules][k], rule); err != nil {
+			log.Printf("ScanShippingRules failed: %v", err)
+			return rules, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (m *MemStore) GetLocation(locationID string) (*store.Location, error) {
+	loc := &store.Location{}
+	if err := m.get(memLocations, locationID, "", loc); err != nil {
+		log.Printf("GetLocation failed: %v", err)
+		return &store.Location{}, err
+	}
+	return loc, nil
+}
+
+func (m *MemStore) PutLocation(loc *store.Location) error {
+	return m.put(memLocations, LocationsPK, "", loc)
+}
+
+func (m *MemStore) DeleteLocation(locationID string) error {
+	m.delete(memLocations, locationID, "")
+	return nil
+}
+
+func (m *MemStore) ScanLocations() ([]*store.Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for k := range m.tables[memLocations] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	locations := []*store.Location{}
+	for _, k := range keys {
+		loc := &store.Location{}
+		if err := fromDocument(m.tables[memLocations][k], loc); err != nil {
+			log.Printf("ScanLocations failed: %v", err)
+			return locations, err
+		}
+		locations = append(locations, loc)
+	}
+	return locations, nil
+}
+
+func (m *MemStore) UpdateLocationStock(locationID, sizeID string, count int) error {
+	return m.UpdateItem(locationStockUpdate(locationID, sizeID, count))
+}
+
+func (m *MemStore) GetCustomerRedemptions(code, userID string) (int, error) {
+	r := &store.PromotionRedemption{}
+	if err := m.get(memRedemptions, code, userID, r); err != nil {
+		log.Printf("GetCustomerRedemptions failed: %v", err)
+		return 0, err
+	}
+	return r.Redemptions, nil
+}
+
+// RedeemPromotions records the customer's redemption of each promotion if every promotion has
+// redemptions remaining and the customer is within each per-customer limit. No redemptions are
+// recorded if any limit is reached.
+func (m *MemStore) RedeemPromotions(userID string, promos []*store.Promotion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range promos {
+		doc, ok := m.tables[memPromotions][memKey(p.Code, "")]
+		if !ok {
+			return fmt.Errorf(store.ErrPromotionLimitReached)
+		}
+		remaining, _ := doc["redemptions_remaining"].(float64)
+		if active, _ := doc["active"].(bool); !active || (p.MaxRedemptions > 0 && remaining < 1) {
+			return fmt.Errorf(store.ErrPromotionLimitReached)
+		}
+		count, _ := m.tables[memRedemptions][memKey(p.Code, userID)]["redemptions"].(float64)
+		if p.MaxPerCustomer > 0 && count >= float64(p.MaxPerCustomer) {
+			return fmt.Errorf(store.ErrPromotionCustomerLimit)
+		}
+	}
+	m.addRedemptions(userID, promos, 1)
+	return nil
+}
+
+// ReleasePromotions reverses the customer's redemption of each promotion.
+func (m *MemStore) ReleasePromotions(userID string, promos []*store.Promotion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addRedemptions(userID, promos, -1)
+	return nil
+}
+
+// ReleaseOrderPromotions reverses the customer's redemption of the order's promotions once,
+// marking the order 'promotions_released'.
+func (m *MemStore) ReleaseOrderPromotions(order *store.Order, promos []*store.Promotion) error {
+	if len(promos) == 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.tables[memOrders][memKey(order.UserID, order.OrderID)]
+	if !ok {
+		return fmt.Errorf(ErrConditionalCheck)
+	}
+	if _, released := doc["promotions_released"]; released {
+		return nil
+	}
+	m.addRedemptions(order.UserID, promos, -1)
+	version, _ := doc["version"].(float64)
+	doc["promotions_released"] = true
+	doc["version"] = version + 1
+	return nil
+}
+
+// addRedemptions adds n to the redemption counts of each promotion and the customer's
+// redemption records. The caller must hold the write lock.
+func (m *MemStore) addRedemptions(userID string, promos []*store.Promotion, n float64) {
+	if m.tables[memRedemptions] == nil {
+		m.tables[memRedemptions] = make(map[string]document)
+	}
+	for _, p := range promos {
+		if doc, ok := m.tables[memPromotions][memKey(p.Code, "")]; ok {
+			redemptions, _ := doc["redemptions"].(float64)
+			doc["redemptions"] = redemptions + n
+			if p.MaxRedemptions > 0 {
+				remaining, _ := doc["redemptions_remaining"].(float64)
+				doc["redemptions_remaining"] = remaining - n
+			}
+		}
+		key := memKey(p.Code, userID)
+		doc, ok := m.tables[memRedemptions][key]
+		if !ok {
+			doc = document{RedemptionsPK: p.Code, RedemptionsSK: userID}
+			m.tables[memRedemptions][key] = doc
+		}
+		count, _ := doc["redemptions"].(float64)
+		doc["redemptions"] = count + n
+	}
+}
+
+// GetOrderHolds returns the inventory holds placed for the order, sorted by size ID.
+func (m *MemStore) GetOrderHolds(orderID string) ([]*store.InventoryHold, error) {
+	holds := []*store.InventoryHold{}
+	for _, doc := range m.scan(memHolds, HoldsPK, orderID) {
+		h := &store.InventoryHold{}
+		if err := fromDocument(doc, h); err != nil {
+			log.Printf("GetOrderHolds failed: %v", err)
+			return holds, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, nil
+}
+
+// ReserveItems places the inventory holds if every held item size has enough units available
+// to sell. No holds are placed if any item is out of stock.
+func (m *MemStore) ReserveItems(holds []*store.InventoryHold) ([]string, error) {
+	if len(holds)+len(groupHolds(holds)) > maxTransactItems {
+		return []string{}, fmt.Errorf(ErrTransactionTooLarge)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outOfStock := []string{}
+	for _, group := range groupHolds(holds) {
+		doc := m.tables[memItems][memKey(group[0].Subcategory, group[0].ItemID)]
+		item := &store.StoreItem{}
+		if err := fromDocument(doc, item); err != nil {
+			log.Printf("ReserveItems failed: %v", err)
+			return []string{}, err
+		}
+		counts := heldUnits(group)
+		for _, size := range sortedSizes(counts) {
+			if item.AvailableToSell(size) < counts[size] {
+				outOfStock = append(outOfStock, group[0].ItemID)
+				break
+			}
+		}
+	}
+	if len(outOfStock) > 0 {
+		return outOfStock, fmt.Errorf(store.ErrInsufficientStock)
+	}
+	for _, h := range holds {
+		if _, ok := m.tables[memHolds][memKey(h.OrderID, h.SizeID)]; ok {
+			return []string{}, fmt.Errorf(ErrConditionalCheck)
+		}
+	}
+
+	if m.tables[memHolds] == nil {
+		m.tables[memHolds] = make(map[string]document)
+	}
+	for _, h := range holds {
+		doc, err := toDocument(h)
+		if err != nil {
+			log.Printf("ReserveItems failed: %v", err)
+			return []string{}, err
+		}
+		m.tables[memHolds][memKey(h.OrderID, h.SizeID)] = doc
+		addUnits(m.tables[memItems][memKey(h.Subcategory, h.ItemID)], "units_reserved", h.Size, h.Quantity)
+	}
+	return []string{}, nil
+}
+
+// ConvertHolds converts active inventory holds to sales. Returns ErrHoldNotActive if any hold
+// has already been converted or released.
+func (m *MemStore) ConvertHolds(holds []*store.InventoryHold) error {
+	return m.settleHolds(holds, store.HoldStatusActive, store.HoldStatusConverted)
+}
+
+// ReleaseHolds releases active inventory holds. Returns ErrHoldNotActive if any hold has
+// already been converted or released.
+func (m *MemStore) ReleaseHolds(holds []*store.InventoryHold) error {
+	return m.settleHolds(holds, store.HoldStatusActive, store.HoldStatusReleased)
+}
+
+// RestockHolds releases converted inventory holds and adds their units back to the on-hand
+// counts. Returns ErrHoldNotActive if any hold is not converted.
+func (m *MemStore) RestockHolds(holds []*store.InventoryHold) error {
+	return m.settleHolds(holds, store.HoldStatusConverted, store.HoldStatusReleased)
+}
+
+// settleHolds moves each hold from one status to another and updates the held items' counts.
+func (m *MemStore) settleHolds(holds []*store.InventoryHold, from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range holds {
+		doc, ok := m.tables[memHolds][memKey(h.OrderID, h.SizeID)]
+		if !ok || doc.getString("hold_status") != from {
+			return fmt.Errorf(store.ErrHoldNotActive)
+		}
+	}
+	for _, h := range holds {
+		m.tables[memHolds][memKey(h.OrderID, h.SizeID)]["hold_status"] = to
+		item := m.tables[memItems][memKey(h.Subcategory, h.ItemID)]
+		if from == store.HoldStatusActive {
+			addUnits(item, "units_reserved", h.Size, -h.Quantity)
+		}
+		switch {
+		case to == store.HoldStatusConverted:
+			addUnits(item, "units_available", h.Size, -h.Quantity)
+		case from == store.HoldStatusConverted:
+			addUnits(item, "units_available", h.Size, h.Quantity)
+		}
+	}
+	return nil
+}
+
+// ScanExpiredHolds returns each active inventory hold that expired at or before now
+// (unix timestamp (s)), sorted by order ID and size ID.
+func (m *MemStore) ScanExpiredHolds(now int64) ([]*store.InventoryHold, error) {
+	holds := []*store.InventoryHold{}
+	for _, doc := range m.scan(memHolds, "hold_status", store.HoldStatusActive) {
+		h := &store.InventoryHold{}
+		if err := fromDocument(doc, h); err != nil {
+			log.Printf("ScanExpiredHolds failed: %v", err)
+			return holds, err
+		}
+		if h.ExpiresAt <= now {
+			holds = append(holds, h)
+		}
+	}
+	return holds, nil
+}
+
+// addUnits adds n to the count of the given size in the document's map attribute, creating
+// the map if it does not exist. Missing documents are ignored.
+func addUnits(doc document, attr, size string, n int) {
+	if doc == nil {
+		return
+	}
+	units, ok := doc[attr].(map[string]interface{})
+	if !ok {
+		units = make(map[string]interface{})
+		doc[attr] = units
+	}
+	count, _ := units[size].(float64)
+	units[size] = count + float64(n)
+}
+
+// StageOrder writes the staged Order, Transaction, Customer counters and inventory decrements if
+// every write's condition passes. Nothing is written if any condition fails.
+func (m *MemStore) StageOrder(stage *OrderStage) error {
+	if 3+len(stage.Inventory) > maxTransactItems {
+		return fmt.Errorf(ErrTransactionTooLarge)
+	}
+	staged := *stage.Order
+	staged.Version++
+	order, err := toDocument(&staged)
+	if err != nil {
+		log.Printf("StageOrder failed: %v", err)
+		return err
+	}
+	tx, err := toDocument(stage.Transaction)
+	if err != nil {
+		log.Printf("StageOrder failed: %v", err)
+		return err
+	}
+	billing, err := toDocument(stage.Customer.BillingAddress)
+	if err != nil {
+		log.Printf("StageOrder failed: %v", err)
+		return err
+	}
+	shipping, err := toDocument(stage.Customer.ShippingAddress)
+	if err != nil {
+		log.Printf("StageOrder failed: %v", err)
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txKey := memKey(stage.Transaction.UserID, stage.Transaction.TransactionID)
+	if _, ok := m.tables[memTransactions][txKey]; ok {
+		return fmt.Errorf(ErrOrderAlreadyStaged)
+	}
+	orderKey := memKey(stage.Order.UserID, stage.Order.OrderID)
+	if doc, ok := m.tables[memOrders][orderKey]; ok && doc.getString("order_status") != store.OrderStatusOpen {
+		return fmt.Errorf(ErrOrderConflict)
+	}
+	cust, ok := m.tables[memCustomers][memKey(stage.Customer.Email, "")]
+	if !ok {
+		return fmt.Errorf(ErrCustomerNotFound)
+	}
+	ordered := make(map[string]int) // item key & size: units
+	for _, item := range stage.Inventory {
+		k := memKey(item.Subcategory, item.ItemID)
+		ordered[k+"."+item.Size] += item.Quantity
+		units, _ := m.tables[memItems][k]["units_available"].(map[string]interface{})
+		available, _ := units[item.Size].(float64)
+		if available < float64(ordered[k+"."+item.Size]) {
+			return fmt.Errorf(store.ErrInsufficientStock)
+		}
+	}
+
+	for _, table := range []string{memTransactions, memOrders} {
+		if m.tables[table] == nil {
+			m.tables[table] = make(map[string]document)
+		}
+	}
+	m.tables[memTransactions][txKey] = tx
+	m.tables[memOrders][orderKey] = order
+	purchases, _ := cust["purchases"].(float64)
+	cust["purchases"] = purchases + float64(stage.Order.TotalItems)
+	cust["billing_address"] = map[string]interface{}(billing)
+	cust["shipping_address"] = map[string]interface{}(shipping)
+	version, _ := cust["version"].(float64)
+	cust["version"] = version + 1
+	for _, item := range stage.Inventory {
+		addUnits(m.tables[memItems][memKey(item.Subcategory, item.ItemID)], "units_available", item.Size, -item.Quantity)
+	}
+	return nil
+}
+
+func (m *MemStore) GetDownloadGrant(userID, grantID string) (*store.DownloadGrant, error) {
+	grant := &store.DownloadGrant{}
+	if err := m.get(memDownloads, userID, grantID, grant); err != nil {
+		log.Printf("GetDownloadGrant failed: %v", err)
+		return &store.DownloadGrant{}, err
+	}
+	return grant, nil
+}
+
+func (m *MemStore) GetOrderDownloads(userID, orderID string) ([]*store.DownloadGrant, error) {
+	grants := []*store.DownloadGrant{}
+	prefix := store.GrantIDPrefix(orderID)
+	for _, doc := range m.scan(memDownloads, DownloadsPK, userID) {
+		if !strings.HasPrefix(doc.getString(DownloadsSK), prefix) {
+			continue
+		}
+		g := &store.DownloadGrant{}
+		if err := fromDocument(doc, g); err != nil {
+			log.Printf("GetOrderDownloads failed: %v", err)
+			return grants, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+// PutDownloadGrant puts the grant if it was not already issued. Returns ErrConditionalCheck if
+// the grant exists.
+func (m *MemStore) PutDownloadGrant(grant *store.DownloadGrant) error {
+	doc, err := toDocument(grant)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables[memDownloads] == nil {
+		m.tables[memDownloads] = make(map[string]document)
+	}
+	key := memKey(grant.UserID, grant.GrantID)
+	if _, ok := m.tables[memDownloads][key]; ok {
+		return fmt.Errorf(ErrConditionalCheck)
+	}
+	m.tables[memDownloads][key] = doc
+	return nil
+}
+
+func (m *MemStore) GetContract(userID, contractID string) (*store.Contract, error) {
+	contract := &store.Contract{}
+	if err := m.get(memContracts, userID, contractID, contract); err != nil {
+		log.Printf("GetContract failed: %v", err)
+		return &store.Contract{}, err
+	}
+	return contract, nil
+}
+
+// PutContract puts the contract if it does not exist. Returns ErrConditionalCheck if the
+// contract exists.
+func (m *MemStore) PutContract(contract *store.Contract) error {
+	doc, err := toDocument(contract)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables[memContracts] == nil {
+		m.tables[memContracts] = make(map[string]document)
+	}
+	key := memKey(contract.UserID, contract.ContractID)
+	if _, ok := m.tables[memContracts][key]; ok {
+		return fmt.Errorf(ErrConditionalCheck)
+	}
+	m.tables[memContracts][key] = doc
+	return nil
+}
@@ -0,0 +1,1028 @@
+package dbops
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+func TestMemStoreUpdateInventoryCountConcurrent(t *testing.T) {
+	s := newTestStore(t)
+
+	// 20 units of item 003 in stock - exactly 20 of 25 concurrent single unit updates succeed
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok, failed := 0, 0
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.UpdateInventoryCount("posters", "003", "OS", 1)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if err.Error() != ErrConditionalCheck {
+					t.Errorf("FAIL: %v; want: %v", err, ErrConditionalCheck)
+				}
+				failed++
+				return
+			}
+			ok++
+		}()
+	}
+	wg.Wait()
+
+	if ok != 20 || failed != 5 {
+		t.Errorf("FAIL - ok: %d, failed: %d; want: 20, 5", ok, failed)
+	}
+	item, err := s.GetStoreItem("posters", "003")
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if item.UnitsAvailable["OS"] != 0 {
+		t.Errorf("FAIL - units available: %d; want: 0", item.UnitsAvailable["OS"])
+	}
+}
+
+func TestMemStoreUpdate(t *testing.T) {
+	var tests = []struct {
+		field   string
+		value   interface{}
+		wantErr error
+	}{
+		{field: "name", value: "PawnWars Battle Unit Chess Pieces", wantErr: nil},
+		{field: "units_available.XL", value: 12, wantErr: nil},
+		{field: "supplier", value: "China", wantErr: nil},                      // field does not exist - ok
+		{field: "name.first", value: "x", wantErr: fmt.Errorf(ErrInvalidPath)}, // parent is not a map
+	}
+
+	s := newTestStore(t)
+
+	for _, test := range tests {
+		err := s.UpdateStoreItem("shirts", "005", test.field, test.value)
+		if err != nil && test.wantErr == nil {
+			t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+		}
+		if err != nil && test.wantErr != nil {
+			if err.Error() != test.wantErr.Error() {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		}
+	}
+
+	item, err := s.GetStoreItem("shirts", "005")
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if item.Name != "PawnWars Battle Unit Chess Pieces" || item.UnitsAvailable["XL"] != 12 || item.UnitsAvailable["S"] != 10 {
+		t.Errorf("FAIL - DATA: %v", item)
+	}
+}
+
+func TestMemStoreOrders(t *testing.T) {
+	s := NewMemStore()
+	order := &store.Order{UserID: "user001", OrderID: "user001-1", OrderStatus: store.OrderStatusPaid}
+	if err := s.PutOrder(order); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+
+	addr := store.Address{FirstName: "Daniel", City: "Oakland"}
+	if err := s.UpdateOrderAddress(order.UserID, order.OrderID, addr, true); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if err := s.UpdateOrderStatus(order.UserID, order.OrderID, store.OrderStatusPaid, store.OrderStatusShipped); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+
+	got, err := s.GetOrder(order.UserID, order.OrderID)
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if got.ShippingAddress.City != "Oakland" || got.OrderStatus != store.OrderStatusShipped || !got.Shipped {
+		t.Errorf("FAIL - DATA: %v", got)
+	}
+
+	// missing records return empty objects
+	missing, err := s.GetOrder("user002", "user002-1")
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if missing.OrderID != "" {
+		t.Errorf("FAIL - DATA: %v; want: empty order", missing)
+	}
+}
+
+func TestTransitionOrder(t *testing.T) {
+	var tests = []struct {
+		orderID string
+		event   store.OrderEvent
+		want    string // order status
+		wantErr string
+	}{
+		{orderID: "user001-1", event: store.OrderEventCheckout, want: store.OrderStatusPaymentInProgress},
+		{orderID: "user001-1", event: store.OrderEventPaymentSuccess, want: store.OrderStatusPaid},
+		{orderID: "user001-1", event: store.OrderEventShip, want: store.OrderStatusShipped},
+		{orderID: "user001-1", event: store.OrderEventShip, wantErr: store.ErrInvalidTransition},          // already shipped
+		{orderID: "user001-2", event: store.OrderEventCheckout, want: store.OrderStatusPaymentInProgress}, // legacy record w/o status
+		{orderID: "user001-9", event: store.OrderEventCheckout, wantErr: ErrOrderNotFound},
+	}
+
+	s := NewMemStore()
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-1", OrderStatus: store.OrderStatusOpen})
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-2"})
+
+	for _, test := range tests {
+		order, err := TransitionOrder(s, "user001", test.orderID, test.event)
+		if test.wantErr != "" {
+			var te *store.TransitionError
+			if errors.As(err, &te) {
+				err = fmt.Errorf(te.Code)
+			}
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		got, _ := s.GetOrder("user001", test.orderID)
+		if order.OrderStatus != test.want || got.OrderStatus != test.want {
+			t.Errorf("FAIL: %s; want: %s", got.OrderStatus, test.want)
+		}
+		paid, shipped, delivered, complete := store.OrderStatusFlags(test.want)
+		if got.Paid != paid || got.Shipped != shipped || got.Delivered != delivered || got.Complete != complete {
+			t.Errorf("FAIL - flags: %v", got)
+		}
+	}
+
+	// stale prior status fails the conditional write
+	err := s.UpdateOrderStatus("user001", "user001-1", store.OrderStatusPaid, store.OrderStatusShipped)
+	if err == nil || err.Error() != ErrConditionalCheck {
+		t.Errorf("FAIL: %v; want: %v", err, ErrConditionalCheck)
+	}
+}
+
+func TestMemStoreRestockItem(t *testing.T) {
+	var tests = []struct {
+		itemID  string
+		size    string
+		count   int
+		want    int
+		wantErr string
+	}{
+		{itemID: "005", size: "S", count: 2, want: 12},
+		{itemID: "005", size: "XXL", count: 1, want: 1}, // size not stocked
+		{itemID: "999", size: "S", count: 1, wantErr: ErrConditionalCheck},
+	}
+
+	s := newTestStore(t)
+	for _, test := range tests {
+		err := s.RestockItem("shirts", test.itemID, test.size, test.count)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		item, _ := s.GetStoreItem("shirts", test.itemID)
+		if item.UnitsAvailable[test.size] != test.want {
+			t.Errorf("FAIL: %d; want: %d", item.UnitsAvailable[test.size], test.want)
+		}
+	}
+}
+
+func TestTransitionReturn(t *testing.T) {
+	s := NewMemStore()
+	order := &store.Order{
+		UserID:      "user001",
+		OrderID:     "user001-1",
+		OrderStatus: store.OrderStatusShipped,
+		Items:       []*store.CartItem{{ItemID: "005", SizeID: "005-L", Size: "L", Quantity: 2, Price: store.USD(2995)}},
+	}
+	ret, err := store.NewReturn(order, map[string]int{"005-L": 1}, "too small")
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	order.Apply(store.OrderEventRequestReturn)
+	s.PutOrder(order)
+	s.PutReturn(ret)
+
+	var tests = []struct {
+		event      store.ReturnEvent
+		wantReturn string
+		wantOrder  string
+		wantErr    string
+	}{
+		{event: store.ReturnEventApprove, wantReturn: store.ReturnStatusApproved, wantOrder: store.OrderStatusOpenReturn},
+		{event: store.ReturnEventReceiveItems, wantReturn: store.ReturnStatusItemsReceived, wantOrder: store.OrderStatusItemsReceived},
+		{event: store.ReturnEventReceiveItems, wantReturn: store.ReturnStatusItemsReceived, wantOrder: store.OrderStatusItemsReceived, wantErr: store.ErrInvalidReturnTransition},
+		{event: store.ReturnEventRefund, wantReturn: store.ReturnStatusComplete, wantOrder: store.OrderStatusReturned},
+	}
+	for _, test := range tests {
+		ret, _ := s.GetReturn("user001", "user001-1-r")
+		err := TransitionReturn(s, ret, test.event)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		got, _ := s.GetReturn("user001", "user001-1-r")
+		gotOrder, _ := s.GetOrder("user001", "user001-1")
+		if got.ReturnStatus != test.wantReturn || gotOrder.OrderStatus != test.wantOrder {
+			t.Errorf("FAIL: %s, %s; want: %s, %s", got.ReturnStatus, gotOrder.OrderStatus, test.wantReturn, test.wantOrder)
+		}
+	}
+
+	// returns changed since they were read are not written
+	order.OrderID, order.OrderStatus = "user001-2", store.OrderStatusShipped
+	ret, _ = store.NewReturn(order, map[string]int{"005-L": 1}, "too big")
+	order.Apply(store.OrderEventRequestReturn)
+	s.PutOrder(order)
+	s.PutReturn(ret)
+	stale, _ := s.GetReturn("user001", ret.ReturnID)
+	stale.Message = "stale"
+	if err := TransitionReturn(s, ret, store.ReturnEventApprove); err != nil {
+		t.Errorf("FAIL: %v; want: nil", err)
+	}
+	if err := TransitionReturn(s, stale, store.ReturnEventApprove); err == nil || err.Error() != ErrConditionalCheck {
+		t.Errorf("FAIL: %v; want: %v", err, ErrConditionalCheck)
+	}
+	if got, _ := s.GetReturn("user001", ret.ReturnID); got.Message == "stale" {
+		t.Errorf("FAIL: %v", got)
+	}
+
+	// missing return
+	err = TransitionReturn(s, &store.Return{}, store.ReturnEventApprove)
+	if err == nil || err.Error() != ErrReturnNotFound {
+		t.Errorf("FAIL: %v; want: %v", err, ErrReturnNotFound)
+	}
+}
+
+func TestMemStoreRedeemPromotions(t *testing.T) {
+	s := NewMemStore()
+	limited := &store.Promotion{Code: "SAVE10", Active: true, MaxRedemptions: 2, RedemptionsRemaining: 2, Stackable: true}
+	once := &store.Promotion{Code: "WELCOME", Active: true, MaxPerCustomer: 1, Stackable: true}
+	s.PutPromotion(limited)
+	s.PutPromotion(once)
+
+	var tests = []struct {
+		userID  string
+		promos  []*store.Promotion
+		wantErr string
+	}{
+		{userID: "user001", promos: []*store.Promotion{limited, once}, wantErr: ""},
+		{userID: "user001", promos: []*store.Promotion{limited, once}, wantErr: store.ErrPromotionCustomerLimit},
+		{userID: "user002", promos: []*store.Promotion{limited}, wantErr: ""},
+		{userID: "user003", promos: []*store.Promotion{limited}, wantErr: store.ErrPromotionLimitReached},
+		{userID: "user003", promos: []*store.Promotion{once}, wantErr: ""},
+		{userID: "user003", promos: []*store.Promotion{{Code: "NONE"}}, wantErr: store.ErrPromotionLimitReached},
+	}
+	for _, test := range tests {
+		err := s.RedeemPromotions(test.userID, test.promos)
+		if test.wantErr == "" && err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+		}
+	}
+
+	// failed redemptions are not recorded
+	got, _ := s.GetPromotion("SAVE10")
+	if got.RedemptionsRemaining != 0 || got.Redemptions != 2 {
+		t.Errorf("FAIL - SAVE10: %v", got)
+	}
+	if ct, _ := s.GetCustomerRedemptions("WELCOME", "user001"); ct != 1 {
+		t.Errorf("FAIL - WELCOME redemptions: %d; want: 1", ct)
+	}
+
+	// released redemptions may be redeemed again
+	s.ReleasePromotions("user001", []*store.Promotion{limited, once})
+	if err := s.RedeemPromotions("user001", []*store.Promotion{limited, once}); err != nil {
+		t.Errorf("FAIL: %v; want: nil", err)
+	}
+	promos, _ := s.ScanPromotions()
+	if len(promos) != 2 || promos[0].Code != "SAVE10" || promos[1].Redemptions != 2 {
+		t.Errorf("FAIL - scan: %v", promos)
+	}
+
+	// promotions of a failed order are released once
+	order := &store.Order{UserID: "user001", OrderID: "user001-1"}
+	s.PutOrder(order)
+	for i := 0; i < 2; i++ {
+		if err := s.ReleaseOrderPromotions(order, []*store.Promotion{once}); err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+	}
+	if ct, _ := s.GetCustomerRedemptions("WELCOME", "user001"); ct != 0 {
+		t.Errorf("FAIL - WELCOME redemptions: %d; want: 0", ct)
+	}
+	if got, _ := s.GetOrder("user001", "user001-1"); !got.PromotionsReleased || got.Version != 2 {
+		t.Errorf("FAIL - order: %v", got)
+	}
+}
+
+func TestGetCustomerPromotions(t *testing.T) {
+	s := NewMemStore()
+	s.PutPromotion(&store.Promotion{Code: "WELCOME", Active: true, MaxPerCustomer: 1})
+	s.PutPromotion(&store.Promotion{Code: "SAVE10", Active: true})
+	s.RedeemPromotions("user001", []*store.Promotion{{Code: "WELCOME", MaxPerCustomer: 1}})
+
+	var tests = []struct {
+		userID  string
+		codes   []string
+		want    int
+		wantErr string
+	}{
+		{userID: "user002", codes: []string{"welcome", " Save10"}, want: 2},
+		{userID: "user001", codes: []string{"SAVE10"}, want: 1},
+		{userID: "user001", codes: []string{"WELCOME"}, wantErr: store.ErrPromotionCustomerLimit},
+		{userID: "user002", codes: []string{"FREE"}, wantErr: store.ErrPromotionNotFound},
+	}
+	for _, test := range tests {
+		promos, err := GetCustomerPromotions(s, test.userID, test.codes)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || len(promos) != test.want {
+			t.Errorf("FAIL: %v, %v; want: %d promotions", promos, err, test.want)
+		}
+	}
+}
+
+func TestMemStoreReserveItems(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	newOrder := func(orderID string, items ...*store.CartItem) *store.Order {
+		return &store.Order{OrderID: orderID, UserID: "user001", TtlMs: 600000, Items: items}
+	}
+	shirt := func(size string, qty int) *store.CartItem {
+		return &store.CartItem{Subcategory: "shirts", ItemID: "005", SizeID: "005-" + size, Size: size, Quantity: qty}
+	}
+	poster := &store.CartItem{Subcategory: "posters", ItemID: "004", SizeID: "004-OS", Size: "OS", Quantity: 1}
+	large := newOrder("user001-5")
+	for i := 0; i < 51; i++ { // 51 holds & 51 item updates
+		id := fmt.Sprintf("1%02d", i)
+		large.Items = append(large.Items, &store.CartItem{Subcategory: "posters", ItemID: id, SizeID: id + "-OS", Size: "OS", Quantity: 1})
+	}
+
+	var tests = []struct {
+		order   *store.Order
+		wantOut []string
+		wantErr string
+	}{
+		{order: newOrder("user001-1", shirt("XL", 3), poster)},
+		{order: newOrder("user001-2", shirt("XL", 2)), wantOut: []string{"005"}, wantErr: store.ErrInsufficientStock}, // 1 XL available to sell
+		{order: newOrder("user001-3", shirt("L", 1), poster), wantOut: []string{"004"}, wantErr: store.ErrInsufficientStock},
+		{order: newOrder("user001-1", shirt("XL", 1)), wantErr: ErrConditionalCheck}, // hold already placed
+		{order: newOrder("user001-4", shirt("XL", 1), shirt("L", 6))},
+		{order: large, wantErr: ErrTransactionTooLarge},
+	}
+
+	s := newTestStore(t)
+	for _, test := range tests {
+		out, err := s.ReserveItems(store.NewHolds(test.order, now))
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		if fmt.Sprint(out) != fmt.Sprint(test.wantOut) {
+			t.Errorf("FAIL: %v; want: %v", out, test.wantOut)
+		}
+	}
+
+	// failed reservations are not recorded
+	item, _ := s.GetStoreItem("shirts", "005")
+	if item.UnitsReserved["XL"] != 4 || item.UnitsReserved["L"] != 6 || item.UnitsReserved["S"] != 0 {
+		t.Errorf("FAIL - reserved: %v", item.UnitsReserved)
+	}
+	if item.AvailableToSell("XL") != 0 || item.UnitsAvailable["XL"] != 4 {
+		t.Errorf("FAIL - XL: %d available to sell, %d on hand", item.AvailableToSell("XL"), item.UnitsAvailable["XL"])
+	}
+	if holds, _ := s.GetOrderHolds("user001-3"); len(holds) != 0 {
+		t.Errorf("FAIL - holds: %v; want: []", holds)
+	}
+}
+
+func TestMemStoreSettleHolds(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newTestStore(t)
+	for i, qty := range []int{2, 3} {
+		order := &store.Order{
+			OrderID: fmt.Sprintf("user001-%d", i+1),
+			UserID:  "user001",
+			TtlMs:   600000 * (i + 1),
+			Items:   []*store.CartItem{{Subcategory: "shirts", ItemID: "005", SizeID: "005-M", Size: "M", Quantity: qty}},
+		}
+		if _, err := s.ReserveItems(store.NewHolds(order, now)); err != nil {
+			t.Fatalf("FAIL: %v", err)
+		}
+	}
+
+	var tests = []struct {
+		orderID      string
+		at           time.Time
+		release      bool
+		wantErr      string
+		wantOnHand   int
+		wantReserved int
+	}{
+		{orderID: "user001-1", at: now.Add(time.Minute), wantOnHand: 6, wantReserved: 3},
+		{orderID: "user001-1", at: now.Add(time.Minute), wantErr: store.ErrHoldNotActive, wantOnHand: 6, wantReserved: 3},
+		{orderID: "user001-2", at: now.Add(30 * time.Minute), wantErr: store.ErrHoldNotActive, wantOnHand: 6, wantReserved: 3}, // expired
+		{orderID: "user001-2", at: now.Add(30 * time.Minute), release: true, wantOnHand: 6, wantReserved: 0},
+		{orderID: "user001-9", at: now, wantErr: store.ErrHoldNotActive, wantOnHand: 6, wantReserved: 0}, // no holds
+	}
+	for _, test := range tests {
+		var err error
+		if test.release {
+			var released []string
+			released, err = ReleaseExpiredHolds(s, test.at.Unix())
+			if len(released) != 1 || released[0] != test.orderID {
+				t.Errorf("FAIL - released: %v; want: [%s]", released, test.orderID)
+			}
+		} else {
+			err = ConvertOrderHolds(s, test.orderID, test.at.Unix())
+		}
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		item, _ := s.GetStoreItem("shirts", "005")
+		if item.UnitsAvailable["M"] != test.wantOnHand || item.UnitsReserved["M"] != test.wantReserved {
+			t.Errorf("FAIL: %d on hand, %d reserved; want: %d, %d", item.UnitsAvailable["M"], item.UnitsReserved["M"], test.wantOnHand, test.wantReserved)
+		}
+	}
+
+	// settled holds are not released
+	if expired, _ := s.ScanExpiredHolds(now.Add(time.Hour).Unix()); len(expired) != 0 {
+		t.Errorf("FAIL - expired: %v; want: []", expired)
+	}
+
+	// converted holds of unpaid orders are restocked once; released holds are not restocked
+	for _, orderID := range []string{"user001-1", "user001-1", "user001-2"} {
+		if err := RestockOrderHolds(s, orderID); err != nil {
+			t.Errorf("FAIL: %v", err)
+		}
+	}
+	if item, _ := s.GetStoreItem("shirts", "005"); item.UnitsAvailable["M"] != 8 || item.UnitsReserved["M"] != 0 {
+		t.Errorf("FAIL - restocked: %d on hand, %d reserved; want: 8, 0", item.UnitsAvailable["M"], item.UnitsReserved["M"])
+	}
+}
+
+// newTestStage returns an OrderStage for user001's first order of 2 size M shirts.
+func newTestStage() *OrderStage {
+	order := &store.Order{
+		UserID:      "user001",
+		OrderID:     "user001-1",
+		OrderStatus: store.OrderStatusPaymentInProgress,
+		TotalItems:  2,
+		Items:       []*store.CartItem{{Subcategory: "shirts", ItemID: "005", SizeID: "005-M", Size: "M", Quantity: 2}},
+	}
+	tx := &store.Transaction{UserID: "user001", OrderID: "user001-1", TransactionID: "tx001"}
+	cust := &store.Customer{UserID: "user001", Email: "user001@example.com", ShippingAddress: store.Address{City: "Austin"}}
+	return &OrderStage{Order: order, Transaction: tx, Customer: cust, Inventory: order.Items}
+}
+
+func TestMemStoreStageOrder(t *testing.T) {
+	s := newTestStore(t)
+	s.PutCustomer(&store.Customer{UserID: "user001", Email: "user001@example.com", Purchases: 3})
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-2", OrderStatus: store.OrderStatusPaid})
+
+	var tests = []struct {
+		stage   func(st *OrderStage)
+		wantErr string
+	}{
+		{stage: func(st *OrderStage) { st.Customer.Email = "none@example.com" }, wantErr: ErrCustomerNotFound},
+		{stage: func(st *OrderStage) { st.Inventory[0].Quantity = 9 }, wantErr: store.ErrInsufficientStock},
+		{stage: func(st *OrderStage) { st.Order.OrderID, st.Transaction.TransactionID = "user001-2", "tx002" }, wantErr: ErrOrderConflict},
+		{stage: func(st *OrderStage) {}},
+		{stage: func(st *OrderStage) {}, wantErr: ErrOrderAlreadyStaged},
+	}
+	for _, test := range tests {
+		stage := newTestStage()
+		test.stage(stage)
+		err := s.StageOrder(stage)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+	}
+
+	// only the successful stage is written
+	cust, _ := s.GetCustomer("user001@example.com")
+	if cust.Purchases != 5 || cust.ShippingAddress.City != "Austin" {
+		t.Errorf("FAIL - customer: %d purchases, %v", cust.Purchases, cust.ShippingAddress)
+	}
+	item, _ := s.GetStoreItem("shirts", "005")
+	if item.UnitsAvailable["M"] != 6 {
+		t.Errorf("FAIL - units: %d; want: 6", item.UnitsAvailable["M"])
+	}
+	order, _ := s.GetOrder("user001", "user001-1")
+	if order.OrderStatus != store.OrderStatusPaymentInProgress {
+		t.Errorf("FAIL - order status: %s; want: %s", order.OrderStatus, store.OrderStatusPaymentInProgress)
+	}
+	if tx, _ := s.GetTransaction("user001", "tx002"); tx.TransactionID != "" {
+		t.Errorf("FAIL - tx: %v; want: none", tx)
+	}
+}
+
+func TestMemStoreVersionConflict(t *testing.T) {
+	s := NewMemStore()
+	s.PutShoppingCart(&store.ShoppingCart{UserID: "user001", TotalItems: 1})
+
+	// two requests read the cart at version 1; the second write conflicts
+	a, _ := s.GetShoppingCart("user001")
+	b, _ := s.GetShoppingCart("user001")
+	a.TotalItems++
+	if err := s.PutShoppingCart(a); err != nil {
+		t.Errorf("FAIL: %v; want: nil", err)
+	}
+	b.TotalItems++
+	err := s.PutShoppingCart(b)
+	if !IsVersionConflict(err) {
+		t.Errorf("FAIL: %v; want: %v", err, ErrVersionConflict)
+	}
+	if b.Version != 1 {
+		t.Errorf("FAIL - version: %d; want: 1", b.Version)
+	}
+
+	cart, _ := s.GetShoppingCart("user001")
+	if cart.TotalItems != 2 || cart.Version != 2 {
+		t.Errorf("FAIL - cart: %d items, version %d; want: 2 items, version 2", cart.TotalItems, cart.Version)
+	}
+
+	// creating an order that already exists conflicts
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-1"})
+	if err := s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-1"}); !IsVersionConflict(err) {
+		t.Errorf("FAIL: %v; want: %v", err, ErrVersionConflict)
+	}
+
+	// updates increment the version
+	s.UpdateItem(NewOrderUpdate("user001", "user001-1").Set("payment_status", "SUCCESS"))
+	order, _ := s.GetOrder("user001", "user001-1")
+	if order.Version != 2 {
+		t.Errorf("FAIL - version: %d; want: 2", order.Version)
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	s := NewMemStore()
+	s.PutCustomer(&store.Customer{UserID: "user001", Email: "user001@example.com"})
+
+	// 10 concurrent read-modify-writes - each is applied once
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conflicts := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := RetryOnConflict(func() error {
+				cust, err := s.GetCustomer("user001@example.com")
+				if err != nil {
+					return err
+				}
+				cust.Orders++
+				return s.PutCustomer(cust)
+			})
+			if err != nil {
+				if !IsVersionConflict(err) {
+					t.Errorf("FAIL: %v; want: %v", err, ErrVersionConflict)
+				}
+				mu.Lock()
+				conflicts++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	cust, _ := s.GetCustomer("user001@example.com")
+	if cust.Orders != 10-conflicts {
+		t.Errorf("FAIL - orders: %d; want: %d", cust.Orders, 10-conflicts)
+	}
+
+	// errors other than conflicts are not retried
+	calls := 0
+	err := RetryOnConflict(func() error {
+		calls++
+		return errors.New("ERR")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("FAIL - calls: %d; want: 1", calls)
+	}
+}
+
+func TestMergeGuestCart(t *testing.T) {
+	s := NewMemStore()
+	s.PutStoreItem(&store.StoreItem{Subcategory: "shirts", ItemID: "005", Price: store.USD(2295), UnitsAvailable: map[string]int{"M": 3, "L": 2}})
+	item, _ := s.GetStoreItem("shirts", "005")
+	if _, err := UpdateCart(s, "user001", func(cart *store.ShoppingCart) error {
+		return cart.AddItem(item, store.CartItem{Size: "M"}, 2)
+	}); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if _, err := UpdateCart(s, "guest-01", func(cart *store.ShoppingCart) error {
+		if err := cart.AddItem(item, store.CartItem{Size: "M"}, 2); err != nil {
+			return err
+		}
+		return cart.AddItem(item, store.CartItem{Size: "L"}, 1)
+	}); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+
+	stale, _ := s.GetShoppingCart("guest-01")
+	cart, err := MergeGuestCart(s, "guest-01", "user001", store.DefaultMergeRules)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	// 005-M capped at 3 units
+	if cart.TotalItems != 4 || cart.Subtotal.Cmp(store.USD(9180)) != 0 {
+		t.Errorf("FAIL - totals: %d items, %v; want: 4 items, %v", cart.TotalItems, cart.Subtotal, store.USD(9180))
+	}
+	stored, _ := s.GetShoppingCart("user001")
+	if stored.TotalItems != 4 {
+		t.Errorf("FAIL - stored items: %d; want: 4", stored.TotalItems)
+	}
+	if guest, _ := s.GetShoppingCart("guest-01"); len(guest.Items) != 0 {
+		t.Errorf("FAIL - guest cart: %v; want: deleted", guest.Items)
+	}
+
+	// the guest cart is merged once - retried merges and stale copies do not add its items again
+	if cart, err := MergeGuestCart(s, "guest-01", "user001", store.DefaultMergeRules); err != nil || cart.TotalItems != 4 {
+		t.Errorf("FAIL - merged again: %v, %d items; want: nil, 4 items", err, cart.TotalItems)
+	}
+	stored.Merge(stale, nil, store.MergeRules{Quantity: store.MergeQuantitySum})
+	if err := s.PutMergedCart(stored, stale); !IsVersionConflict(err) {
+		t.Errorf("FAIL - stale guest cart: %v; want: %s", err, ErrVersionConflict)
+	}
+	if stored, _ = s.GetShoppingCart("user001"); stored.TotalItems != 4 {
+		t.Errorf("FAIL - stored items: %d; want: 4", stored.TotalItems)
+	}
+}
+
+func TestMemStoreScanIdleCarts(t *testing.T) {
+	s := NewMemStore()
+	s.PutShoppingCart(&store.ShoppingCart{UserID: "user001", UserEmail: "user001@example.com", UpdatedAt: 100})
+	s.PutShoppingCart(&store.ShoppingCart{UserID: "user002", UserEmail: "user002@example.com", UpdatedAt: 300})
+	s.PutShoppingCart(&store.ShoppingCart{UserID: "guest-01", UpdatedAt: 100})
+
+	carts, err := s.ScanIdleCarts(200)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if len(carts) != 1 || carts[0].UserID != "user001" {
+		t.Errorf("FAIL: %v; want: [user001]", carts)
+	}
+
+	// updates reset the update time & expiration
+	cart, _ := UpdateCart(s, "user001", func(cart *store.ShoppingCart) error { return nil })
+	if cart.UpdatedAt <= 100 || cart.TTL != cart.UpdatedAt+int64(CartTTL().Seconds()) {
+		t.Errorf("FAIL - updated: %d, ttl: %d", cart.UpdatedAt, cart.TTL)
+	}
+}
+
+func TestUseDownload(t *testing.T) {
+	s := NewMemStore()
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+	s.PutStoreItem(&store.StoreItem{ItemID: "007", Subcategory: "beats", ProductType: store.ProductTypeDigital,
+		MasterKey: "masters/007.wav", Licenses: map[string]store.LicenseTier{
+			store.LicenseBasic: {Tier: store.LicenseBasic, MaxDownloads: 2, DownloadDays: 1}}})
+	order := &store.Order{UserID: "u01", OrderID: "u01-1", Items: []*store.CartItem{
+		{ItemID: "007", Subcategory: "beats", SizeID: "007-BASIC", Size: store.LicenseBasic, Digital: true}}}
+
+	grants, err := IssueDownloads(s, order, now)
+	if err != nil || len(grants) != 1 {
+		t.Fatalf("FAIL: %v, %v", err, grants)
+	}
+	grantID := grants[0].GrantID
+
+	var tests = []struct {
+		grantID       string
+		now           time.Time
+		wantErr       string
+		wantRemaining int
+	}{
+		{grantID: grantID, now: now, wantRemaining: 1},
+		{grantID: grantID, now: now.Add(time.Hour), wantRemaining: 0},
+		{grantID: grantID, now: now.Add(time.Hour), wantErr: store.ErrDownloadLimitReached},
+		{grantID: "u01-1.009-BASIC", now: now, wantErr: store.ErrDownloadNotFound},
+	}
+	for _, test := range tests {
+		grant, err := UseDownload(s, "u01", test.grantID, test.now)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || grant.DownloadsRemaining != test.wantRemaining {
+			t.Errorf("FAIL: %v, %d; want: %d", err, grant.DownloadsRemaining, test.wantRemaining)
+		}
+	}
+
+	// reissuing returns the issued grant and does not reset the download count
+	reissued, err := IssueDownloads(s, order, now)
+	if err != nil || len(reissued) != 1 || reissued[0].GrantID != grantID {
+		t.Errorf("FAIL: %v, %v; want: %s", err, reissued, grantID)
+	}
+	downloads, _ := s.GetOrderDownloads("u01", "u01-1")
+	if len(downloads) != 1 || downloads[0].DownloadsRemaining != 0 {
+		t.Errorf("FAIL: %v; want: 1 grant, 0 remaining", downloads)
+	}
+
+	// expired grants
+	order.OrderID = "u01-2"
+	grants, _ = IssueDownloads(s, order, now)
+	if _, err := UseDownload(s, "u01", grants[0].GrantID, now.Add(48*time.Hour)); err == nil || err.Error() != store.ErrDownloadExpired {
+		t.Errorf("FAIL: %v; want: %s", err, store.ErrDownloadExpired)
+	}
+}
+
+func TestSellExclusives(t *testing.T) {
+	s := NewMemStore()
+	s.PutStoreItem(&store.StoreItem{ItemID: "007", Subcategory: "beats", ProductType: store.ProductTypeDigital,
+		Licenses: map[string]store.LicenseTier{
+			store.LicenseBasic:   {Tier: store.LicenseBasic},
+			store.LicensePremium: {Tier: store.LicensePremium, Exclusive: true}}})
+	s.PutStoreItemSummary(&store.StoreItemSummary{ItemID: "007", Subcategory: "beats"})
+	s.PutStoreItemIndex(&store.StoreItemIndex{Subcategory: "beats", ItemIDs: []string{"003", "007", "009"}})
+	newOrder := func(orderID, tier string) *store.Order {
+		return &store.Order{UserID: "u01", OrderID: orderID, Items: []*store.CartItem{
+			{ItemID: "007", Subcategory: "beats", SizeID: "007-" + tier, Size: tier, Digital: true}}}
+	}
+
+	// basic license downloaded before the exclusive license is sold
+	early := newOrder("u01-0", store.LicenseBasic)
+	if _, err := IssueDownloads(s, early, time.Now()); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+
+	// racing exclusive purchases: exactly one order wins
+	orders := []*store.Order{newOrder("u01-1", store.LicensePremium), newOrder("u01-2", store.LicensePremium)}
+	results := make([]int, len(orders))
+	var wg sync.WaitGroup
+	for i, order := range orders {
+		wg.Add(1)
+		go func(i int, order *store.Order) {
+			defer wg.Done()
+			sold, _, err := SellExclusives(s, order)
+			if err != nil {
+				t.Errorf("FAIL: %v", err)
+			}
+			results[i] = len(sold)
+		}(i, order)
+	}
+	wg.Wait()
+	if results[0]+results[1] != 1 {
+		t.Fatalf("FAIL: %v; want: 1 sale", results)
+	}
+	winner := orders[0]
+	if results[1] == 1 {
+		winner = orders[1]
+	}
+
+	var tests = []struct {
+		order    *store.Order
+		wantSold int
+		wantLost int
+	}{
+		{order: winner, wantSold: 1}, // redelivered
+		{order: newOrder("u01-3", store.LicensePremium), wantLost: 1},
+		{order: newOrder("u01-4", store.LicenseBasic), wantLost: 1}, // no longer licensed
+		{order: early},
+	}
+	for _, test := range tests {
+		sold, lost, err := SellExclusives(s, test.order)
+		if err != nil || len(sold) != test.wantSold || len(lost) != test.wantLost {
+			t.Errorf("FAIL: %v, %d sold, %d lost; want: %d, %d", err, len(sold), len(lost), test.wantSold, test.wantLost)
+		}
+	}
+
+	item, _ := s.GetStoreItem("beats", "007")
+	summary, _ := s.GetStoreItemSummary("beats", "007")
+	index, _ := s.GetStoreItemIndex("beats")
+	if !item.SoldExclusive || item.ExclusiveOrderID != winner.OrderID || summary.ItemID != "" || len(index.ItemIDs) != 2 {
+		t.Errorf("FAIL: %v, %v, %v", item, summary, index.ItemIDs)
+	}
+}
+
+func TestSignContract(t *testing.T) {
+	s := NewMemStore()
+	now := time.Date(2021, 5, 30, 12, 0, 0, 0, time.UTC)
+	s.PutOrder(&store.Order{UserID: "u01", OrderID: "u01-1", OrderStatus: store.OrderStatusOpen, Items: []*store.CartItem{
+		{ItemID: "007", SizeID: "007-BASIC", Size: store.LicenseBasic, Digital: true}}})
+	c := &store.Contract{UserID: "u01", ContractID: "u01-1.007-BASIC.1622376000", OrderID: "u01-1", SizeID: "007-BASIC",
+		BuyerName: "Jane Doe", Status: store.ContractStatusPending}
+	c.SetContent("<html><body>Lease</body></html>")
+	if err := s.PutContract(c); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if err := s.PutContract(c); err == nil || err.Error() != ErrConditionalCheck {
+		t.Errorf("FAIL: %v; want: %s", err, ErrConditionalCheck)
+	}
+
+	c.Sign("Jane Doe", c.ContentHash, "203.0.113.7", now)
+	c.DocumentKey = "contracts/u01/u01-1.007-BASIC.1622376000.html"
+	if err := SignContract(s, c); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if err := SignContract(s, c); err == nil || err.Error() != store.ErrContractSigned {
+		t.Errorf("FAIL: %v; want: %s", err, store.ErrContractSigned)
+	}
+
+	signed, _ := s.GetContract("u01", c.ContractID)
+	if signed.Status != store.ContractStatusSigned || signed.Html != "" || signed.SignerIP != "203.0.113.7" || signed.DocumentKey != c.DocumentKey {
+		t.Errorf("FAIL: %v", signed)
+	}
+	order, _ := s.GetOrder("u01", "u01-1")
+	if unsigned := order.UnsignedItems(); len(unsigned) != 0 || order.Contracts[0].DocumentKey != c.DocumentKey {
+		t.Errorf("FAIL: %v, %v; want: no unsigned items", unsigned, order.Contracts)
+	}
+
+	// contracts are not attached to orders that are no longer open
+	s.UpdateOrderStatus("u01", "u01-1", store.OrderStatusOpen, store.OrderStatusPaymentInProgress)
+	c.ContractID = "u01-1.007-BASIC.1622376060"
+	c.Status = store.ContractStatusPending
+	s.PutContract(c)
+	if err := SignContract(s, c); err == nil || err.Error() != ErrConditionalCheck {
+		t.Errorf("FAIL: %v; want: %s", err, ErrConditionalCheck)
+	}
+	if unsigned, _ := s.GetContract("u01", c.ContractID); unsigned.Status != store.ContractStatusPending {
+		t.Errorf("FAIL: %s; want: %s", unsigned.Status, store.ContractStatusPending)
+	}
+}
+
+func TestDeliverOrder(t *testing.T) {
+	s := NewMemStore()
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-1", OrderStatus: store.OrderStatusShipped})
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-2", OrderStatus: store.OrderStatusPaid})
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-3", OrderStatus: store.OrderStatusOpen})
+	s.PutOrder(&store.Order{UserID: "user001", OrderID: "user001-4", OrderStatus: store.OrderStatusShipped})
+
+	var tests = []struct {
+		orderID string
+		at      int64
+		wantErr string
+	}{
+		{orderID: "user001-1", at: 100},
+		{orderID: "user001-2", at: 200}, // delivered before the order was marked shipped
+		{orderID: "user001-1", at: 150, wantErr: store.ErrInvalidTransition},
+		{orderID: "user001-3", at: 100, wantErr: store.ErrInvalidTransition},
+		{orderID: "user001-9", at: 100, wantErr: ErrOrderNotFound},
+		{orderID: "user001-4", at: 300},
+	}
+	for _, test := range tests {
+		_, err := DeliverOrder(s, "user001", test.orderID, time.Unix(test.at, 0))
+		if test.wantErr != "" {
+			var te *store.TransitionError
+			if errors.As(err, &te) {
+				err = fmt.Errorf(te.Code)
+			}
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL - %s: %v; want: %v", test.orderID, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL - %s: %v; want: nil", test.orderID, err)
+			continue
+		}
+		got, _ := s.GetOrder("user001", test.orderID)
+		if got.OrderStatus != store.OrderStatusDelivered || !got.Delivered || got.DeliveredAt != test.at {
+			t.Errorf("FAIL - %s: %s, %v, %d; want: DELIVERED, true, %d", test.orderID, got.OrderStatus, got.Delivered, got.DeliveredAt, test.at)
+		}
+	}
+
+	orders, err := s.ScanDeliveredOrders(200)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	got := []string{}
+	for _, o := range orders {
+		got = append(got, o.OrderID)
+	}
+	if fmt.Sprint(got) != "[user001-1 user001-2]" {
+		t.Errorf("FAIL: %v; want: [user001-1 user001-2]", got)
+	}
+}
+
+func TestShipFromLocations(t *testing.T) {
+	s := NewMemStore()
+	s.PutLocation(&store.Location{LocationID: "mod", Inventory: map[string]int{"005-M": 3, "006-L": 1}, Active: true})
+	s.PutLocation(&store.Location{LocationID: "nyc", Inventory: map[string]int{"005-M": 2}, Active: true})
+
+	pkgs := []store.Package{
+		{LocationID: "mod", Items: []store.PkgItemSummary{{SizeID: "005-M", Quantity: 2}, {SizeID: "006-L", Quantity: 2}}},
+		{LocationID: "nyc", Items: []store.PkgItemSummary{{SizeID: "005-M", Quantity: 2}}},
+		{Items: []store.PkgItemSummary{{SizeID: "005-M", Quantity: 5}}}, // legacy package w/o origin
+	}
+	short, err := ShipFromLocations(s, pkgs)
+	if err != nil || fmt.Sprint(short) != "[006-L]" {
+		t.Errorf("FAIL: %v, %v; want: [006-L]", err, short)
+	}
+	var tests = []struct {
+		locationID string
+		sizeID     string
+		want       int
+	}{
+		{"mod", "005-M", 1},
+		{"mod", "006-L", 1}, // short - not updated
+		{"nyc", "005-M", 0},
+	}
+	for _, test := range tests {
+		loc, _ := s.GetLocation(test.locationID)
+		if loc.Inventory[test.sizeID] != test.want {
+			t.Errorf("FAIL - %s %s: %d; want: %d", test.locationID, test.sizeID, loc.Inventory[test.sizeID], test.want)
+		}
+	}
+
+	// restock adds sizes not yet stocked; locations must exist
+	if err := s.UpdateLocationStock("nyc", "007-S", 4); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if err := s.UpdateLocationStock("sfo", "007-S", 4); err == nil || err.Error() != ErrConditionalCheck {
+		t.Errorf("FAIL: %v; want: %s", err, ErrConditionalCheck)
+	}
+	locs, _ := s.ScanLocations()
+	if len(locs) != 2 || locs[1].Inventory["007-S"] != 4 {
+		t.Errorf("FAIL: %v", locs)
+	}
+}
+
+func TestSaveShippingLabel(t *testing.T) {
+	s := NewMemStore()
+	s.PutShipment(&store.Shipment{UserID: "user001", OrderID: "user001-1"})
+
+	// labels are saved to the shipment as read; stale copies conflict
+	a, _ := s.GetShipment("user001", "user001-1")
+	b, _ := s.GetShipment("user001", "user001-1")
+	if err := SaveShippingLabel(s, a, store.ShippingLabel{LabelID: "l1", LocationID: "mod"}); err != nil || a.Version != 1 {
+		t.Fatalf("FAIL: %v, %d; want: nil, 1", err, a.Version)
+	}
+	if err := SaveShippingLabel(s, b, store.ShippingLabel{LabelID: "l2", LocationID: "mod"}); !IsVersionConflict(err) {
+		t.Errorf("FAIL: %v; want: %s", err, ErrVersionConflict)
+	}
+	if err := SaveShippingLabel(s, a, store.ShippingLabel{LabelID: "l3", LocationID: "nyc"}); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if err := SaveShippingLabel(s, &store.Shipment{UserID: "user001", OrderID: "user001-9"}, store.ShippingLabel{LabelID: "l4"}); !IsVersionConflict(err) {
+		t.Errorf("FAIL: %v; want: %s", err, ErrVersionConflict)
+	}
+
+	got, _ := s.GetShipment("user001", "user001-1")
+	if len(got.Labels) != 2 || got.Labels[1].LabelID != "l3" || got.Version != 2 {
+		t.Errorf("FAIL: %v, %d; want: [l1 l3], 2", got.Labels, got.Version)
+	}
+}
+
+func TestSaveTrackingEvent(t *testing.T) {
+	s := NewMemStore()
+	labels := []store.ShippingLabel{{LabelID: "l1", TrackingNumber: "t1"}, {LabelID: "l2", TrackingNumber: "t2"}}
+	s.PutShipment(&store.Shipment{UserID: "user001", OrderID: "user001-1", Labels: labels})
+
+	// events are appended to the history as read; stale copies conflict
+	a, _ := s.GetShipment("user001", "user001-1")
+	b, _ := s.GetShipment("user001", "user001-1")
+	e1 := store.TrackingEvent{ID: "e1", TrackingNumber: "t1", Status: store.TrackingStatusDelivered}
+	a.AddTracking(e1)
+	if err := SaveTrackingEvent(s, a, e1); err != nil || a.Version != 1 {
+		t.Fatalf("FAIL: %v, %d; want: nil, 1", err, a.Version)
+	}
+	e2 := store.TrackingEvent{ID: "e2", TrackingNumber: "t2", Status: store.TrackingStatusTransit}
+	b.AddTracking(e2)
+	if err := SaveTrackingEvent(s, b, e2); !IsVersionConflict(err) {
+		t.Errorf("FAIL: %v; want: %s", err, ErrVersionConflict)
+	}
+	b, _ = s.GetShipment("user001", "user001-1")
+	b.AddTracking(e2)
+	if err := SaveTrackingEvent(s, b, e2); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+
+	got, _ := s.GetShipment("user001", "user001-1")
+	if len(got.TrackingHistory) != 2 || got.Labels[0].TrackingStatus != store.TrackingStatusDelivered || got.Labels[1].TrackingStatus != store.TrackingStatusTransit || got.Version != 2 {
+		t.Errorf("FAIL: %v, %v, %d; want: [e1 e2], [DELIVERED TRANSIT], 2", got.TrackingHistory, got.Labels, got.Version)
+	}
+}
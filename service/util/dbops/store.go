@@ -0,0 +1,464 @@
+package dbops
+
+import (
+	"github.com/go-aws/go-dynamo/dynamo"
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// Store contains the methods used by the service's handlers to read and write store objects.
+// DynamoStore implements Store with the service's DynamoDB tables, and MemStore implements
+// Store in memory for use in offline tests.
+type Store interface {
+	// partial updates
+	UpdateItem(u *Update) error
+	UpdateItems(updates ...*Update) error
+
+	// store items
+	GetStoreItem(subcategory, itemID string) (*store.StoreItem, error)
+	PutStoreItem(item *store.StoreItem) error
+	UpdateStoreItem(subcat, itemID, field string, value interface{}) error
+	DeleteStoreItem(subcategory, itemID string) error
+	ScanStoreItems() ([]*store.StoreItem, error)
+	UpdateInventoryCount(subcat, itemID, sizeKey string, count int) (string, error)
+	RestockItem(subcat, itemID, sizeKey string, count int) error
+	SellExclusive(subcat, itemID, orderID string) error
+
+	// store item summaries
+	GetStoreItemSummary(subcategory, itemID string) (*store.StoreItemSummary, error)
+	BatchGetStoreItemSummary(subcategory string, itemIDs []string) ([]*store.StoreItemSummary, error)
+	PutStoreItemSummary(item *store.StoreItemSummary) error
+	UpdateStoreItemSummary(subcat, itemID, field string, value interface{}) error
+	DeleteStoreItemSummary(subcategory, itemID string) error
+	ScanItemsForCategory(subcat string) ([]store.StoreItemSummary, error)
+	QueryItemsForCategory(subcat string) ([]store.StoreItemSummary, error)
+
+	// store item index
+	GetStoreItemIndex(subcategory string) (*store.StoreItemIndex, error)
+	PutStoreItemIndex(item *store.StoreItemIndex) error
+
+	// shopping carts
+	GetShoppingCart(userID string) (*store.ShoppingCart, error)
+	PutShoppingCart(cart *store.ShoppingCart) error
+	DeleteShoppingCart(userID string) error
+	PutMergedCart(cart, guest *store.ShoppingCart) error
+	ScanIdleCarts(before int64) ([]*store.ShoppingCart, error)
+
+	// orders
+	GetOrder(userID, orderID string) (*store.Order, error)
+	GetOrderItems(userID, orderID string) (*store.Order, error)
+	PutOrder(order *store.Order) error
+	UpdateOrderAddress(userID, orderID string, addr store.Address, shipping bool) error
+	UpdateOrderStatus(userID, orderID, from, to string) error
+	UpdateOrderPaymentStatus(customerID, orderID, status string) error
+	ScanDeliveredOrders(before int64) ([]*store.Order, error)
+
+	// open orders
+	GetOpenOrder(userID, orderID string) (*store.Order, error)
+	PutOpenOrder(order *store.Order) error
+	DeleteOpenOrder(userID, orderID string) error
+
+	// returns
+	GetReturn(userID, returnID string) (*store.Return, error)
+	PutReturn(ret *store.Return) error
+
+	// transactions
+	GetTransaction(userID, txID string) (*store.Transaction, error)
+	PutTransaction(tx *store.Transaction) error
+	UpdateTxPaymentStatus(customerID, txID, status string) error
+	UpdateTxPaymentMethod(customerID, txID, method string) error
+	UpdateTxPaymentID(customerID, txID, paymentID string) error
+
+	// customers
+	GetCustomer(email string) (*store.Customer, error)
+	PutCustomer(user *store.Customer) error
+
+	// parcels
+	GetParcels(carrier string) ([]*store.Parcel, error)
+	PutParcel(parcel *store.Parcel) error
+
+	// shipments
+	GetShipment(userID, orderID string) (*store.Shipment, error)
+	PutShipment(shipment *store.Shipment) error
+
+	// tracking
+	GetTrackingRef(trackingNumber string) (*store.TrackingRef, error)
+	PutTrackingRef(ref *store.TrackingRef) error
+
+	// webhook events
+	PutWebhookEvent(event *store.WebhookEvent) error
+	DeleteWebhookEvent(eventID string) error
+
+	// promotions
+	GetPromotion(code string) (*store.Promotion, error)
+	PutPromotion(promo *store.Promotion) error
+	DeletePromotion(code string) error
+	ScanPromotions() ([]*store.Promotion, error)
+	GetCustomerRedemptions(code, userID string) (int, error)
+	RedeemPromotions(userID string, promos []*store.Promotion) error
+	ReleasePromotions(userID string, promos []*store.Promotion) error
+	ReleaseOrderPromotions(order *store.Order, promos []*store.Promotion) error
+
+	// shipping rules
+	GetShippingRule(ruleID string) (*store.ShippingRule, error)
+	PutShippingRule(rule *store.ShippingRule) error
+	DeleteShippingRule(ruleID string) error
+	ScanShippingRules() ([]*store.ShippingRule, error)
+
+	// locations
+	GetLocation(locationID string) (*store.Location, error)
+	PutLocation(loc *store.Location) error
+	DeleteLocation(locationID string) error
+	ScanLocations() ([]*store.Location, error)
+	UpdateLocationStock(locationID, sizeID string, count int) error
+
+	// inventory holds
+	GetOrderHolds(orderID string) ([]*store.InventoryHold, error)
+	ReserveItems(holds []*store.InventoryHold) ([]string, error)
+	ConvertHolds(holds []*store.InventoryHold) error
+	ReleaseHolds(holds []*store.InventoryHold) error
+	RestockHolds(holds []*store.InventoryHold) error
+	ScanExpiredHolds(now int64) ([]*store.InventoryHold, error)
+
+	// order staging
+	StageOrder(stage *OrderStage) error
+
+	// downloads
+	GetDownloadGrant(userID, grantID string) (*store.DownloadGrant, error)
+	GetOrderDownloads(userID, orderID string) ([]*store.DownloadGrant, error)
+	PutDownloadGrant(grant *store.DownloadGrant) error
+
+	// contracts
+	GetContract(userID, contractID string) (*store.Contract, error)
+	PutContract(contract *store.Contract) error
+}
+
+// DynamoStore implements the Store interface with the package level DynamoDB functions.
+type DynamoStore struct {
+	DB *dynamo.DbInfo
+}
+
+// NewDynamoStore returns a new *DynamoStore for the given dynamo.DbInfo object.
+// The DbInfo object must be initialized with each table the caller reads or writes to (see InitDB).
+func NewDynamoStore(db *dynamo.DbInfo) *DynamoStore {
+	return &DynamoStore{DB: db}
+}
+
+func (d *DynamoStore) UpdateItem(u *Update) error {
+	return UpdateItem(d.DB, u)
+}
+
+func (d *DynamoStore) UpdateItems(updates ...*Update) error {
+	return UpdateItems(d.DB, updates...)
+}
+
+func (d *DynamoStore) GetStoreItem(subcategory, itemID string) (*store.StoreItem, error) {
+	return GetStoreItem(d.DB, subcategory, itemID)
+}
+
+func (d *DynamoStore) PutStoreItem(item *store.StoreItem) error {
+	return PutStoreItem(d.DB, item)
+}
+
+func (d *DynamoStore) UpdateStoreItem(subcat, itemID, field string, value interface{}) error {
+	return UpdateStoreItem(d.DB, subcat, itemID, field, value)
+}
+
+func (d *DynamoStore) DeleteStoreItem(subcategory, itemID string) error {
+	return DeleteStoreItem(d.DB, subcategory, itemID)
+}
+
+func (d *DynamoStore) SellExclusive(subcat, itemID, orderID string) error {
+	return SellExclusive(d.DB, subcat, itemID, orderID)
+}
+
+func (d *DynamoStore) ScanStoreItems() ([]*store.StoreItem, error) {
+	return ScanStoreItems(d.DB)
+}
+
+func (d *DynamoStore) UpdateInventoryCount(subcat, itemID, sizeKey string, count int) (string, error) {
+	return UpdateInventoryCount(d.DB, subcat, itemID, sizeKey, count)
+}
+
+func (d *DynamoStore) RestockItem(subcat, itemID, sizeKey string, count int) error {
+	return RestockItem(d.DB, subcat, itemID, sizeKey, count)
+}
+
+func (d *DynamoStore) GetStoreItemSummary(subcategory, itemID string) (*store.StoreItemSummary, error) {
+	return GetStoreItemSummary(d.DB, subcategory, itemID)
+}
+
+func (d *DynamoStore) BatchGetStoreItemSummary(subcategory string, itemIDs []string) ([]*store.StoreItemSummary, error) {
+	return BatchGetStoreItemSummary(d.DB, subcategory, itemIDs)
+}
+
+func (d *DynamoStore) PutStoreItemSummary(item *store.StoreItemSummary) error {
+	return PutStoreItemSummary(d.DB, item)
+}
+
+func (d *DynamoStore) UpdateStoreItemSummary(subcat, itemID, field string, value interface{}) error {
+	return UpdateStoreItemSummary(d.DB, subcat, itemID, field, value)
+}
+
+func (d *DynamoStore) DeleteStoreItemSummary(subcategory, itemID string) error {
+	return DeleteStoreItemSummary(d.DB, subcategory, itemID)
+}
+
+func (d *DynamoStore) ScanItemsForCategory(subcat string) ([]store.StoreItemSummary, error) {
+	return ScanItemsForCategory(d.DB, subcat)
+}
+
+func (d *DynamoStore) QueryItemsForCategory(subcat string) ([]store.StoreItemSummary, error) {
+	return QueryItemsForCategory(d.DB, subcat)
+}
+
+func (d *DynamoStore) GetStoreItemIndex(subcategory string) (*store.StoreItemIndex, error) {
+	return GetStoreItemIndex(d.DB, subcategory)
+}
+
+func (d *DynamoStore) PutStoreItemIndex(item *store.StoreItemIndex) error {
+	return PutStoreItemIndex(d.DB, item)
+}
+
+func (d *DynamoStore) GetShoppingCart(userID string) (*store.ShoppingCart, error) {
+	return GetShoppingCart(d.DB, userID)
+}
+
+func (d *DynamoStore) PutShoppingCart(cart *store.ShoppingCart) error {
+	return PutShoppingCart(d.DB, cart)
+}
+
+func (d *DynamoStore) DeleteShoppingCart(userID string) error {
+	return DeleteShoppingCart(d.DB, userID)
+}
+
+func (d *DynamoStore) PutMergedCart(cart, guest *store.ShoppingCart) error {
+	return PutMergedCart(d.DB, cart, guest)
+}
+
+func (d *DynamoStore) ScanIdleCarts(before int64) ([]*store.ShoppingCart, error) {
+	return ScanIdleCarts(d.DB, before)
+}
+
+func (d *DynamoStore) GetOrder(userID, orderID string) (*store.Order, error) {
+	return GetOrder(d.DB, userID, orderID)
+}
+
+func (d *DynamoStore) GetOrderItems(userID, orderID string) (*store.Order, error) {
+	return GetOrderItems(d.DB, userID, orderID)
+}
+
+func (d *DynamoStore) PutOrder(order *store.Order) error {
+	return PutOrder(d.DB, order)
+}
+
+func (d *DynamoStore) UpdateOrderAddress(userID, orderID string, addr store.Address, shipping bool) error {
+	return UpdateOrderAddress(d.DB, userID, orderID, addr, shipping)
+}
+
+func (d *DynamoStore) UpdateOrderStatus(userID, orderID, from, to string) error {
+	return UpdateOrderStatus(d.DB, userID, orderID, from, to)
+}
+
+func (d *DynamoStore) UpdateOrderPaymentStatus(customerID, orderID, status string) error {
+	return UpdateOrderPaymentStatus(d.DB, customerID, orderID, status)
+}
+
+func (d *DynamoStore) ScanDeliveredOrders(before int64) ([]*store.Order, error) {
+	return ScanDeliveredOrders(d.DB, before)
+}
+
+func (d *DynamoStore) GetOpenOrder(userID, orderID string) (*store.Order, error) {
+	return GetOpenOrder(d.DB, userID, orderID)
+}
+
+func (d *DynamoStore) PutOpenOrder(order *store.Order) error {
+	return PutOpenOrder(d.DB, order)
+}
+
+func (d *DynamoStore) DeleteOpenOrder(userID, orderID string) error {
+	return DeleteOpenOrder(d.DB, userID, orderID)
+}
+
+func (d *DynamoStore) GetReturn(userID, returnID string) (*store.Return, error) {
+	return GetReturn(d.DB, userID, returnID)
+}
+
+func (d *DynamoStore) PutReturn(ret *store.Return) error {
+	return PutReturn(d.DB, ret)
+}
+
+func (d *DynamoStore) GetTransaction(userID, txID string) (*store.Transaction, error) {
+	return GetTransaction(d.DB, userID, txID)
+}
+
+func (d *DynamoStore) PutTransaction(tx *store.Transaction) error {
+	return PutTransaction(d.DB, tx)
+}
+
+func (d *DynamoStore) UpdateTxPaymentStatus(customerID, txID, status string) error {
+	return UpdateTxPaymentStatus(d.DB, customerID, txID, status)
+}
+
+func (d *DynamoStore) UpdateTxPaymentMethod(customerID, txID, method string) error {
+	return UpdateTxPaymentMethod(d.DB, customerID, txID, method)
+}
+
+func (d *DynamoStore) UpdateTxPaymentID(customerID, txID, paymentID string) error {
+	return UpdateTxPaymentID(d.DB, customerID, txID, paymentID)
+}
+
+func (d *DynamoStore) GetCustomer(email string) (*store.Customer, error) {
+	return GetCustomer(d.DB, email)
+}
+
+func (d *DynamoStore) PutCustomer(user *store.Customer) error {
+	return PutCustomer(d.DB, user)
+}
+
+func (d *DynamoStore) GetParcels(carrier string) ([]*store.Parcel, error) {
+	return GetParcels(d.DB, carrier)
+}
+
+func (d *DynamoStore) PutParcel(parcel *store.Parcel) error {
+	return PutParcel(d.DB, parcel)
+}
+
+func (d *DynamoStore) GetShipment(userID, orderID string) (*store.Shipment, error) {
+	return GetShipment(d.DB, userID, orderID)
+}
+
+func (d *DynamoStore) PutShipment(shipment *store.Shipment) error {
+	return PutShipment(d.DB, shipment)
+}
+
+func (d *DynamoStore) GetTrackingRef(trackingNumber string) (*store.TrackingRef, error) {
+	return GetTrackingRef(d.DB, trackingNumber)
+}
+
+func (d *DynamoStore) PutTrackingRef(ref *store.TrackingRef) error {
+	return PutTrackingRef(d.DB, ref)
+}
+
+func (d *DynamoStore) PutWebhookEvent(event *store.WebhookEvent) error {
+	return PutWebhookEvent(d.DB, event)
+}
+
+func (d *DynamoStore) DeleteWebhookEvent(eventID string) error {
+	return DeleteWebhookEvent(d.DB, eventID)
+}
+
+func (d *DynamoStore) GetPromotion(code string) (*store.Promotion, error) {
+	return GetPromotion(d.DB, code)
+}
+
+func (d *DynamoStore) PutPromotion(promo *store.Promotion) error {
+	return PutPromotion(d.DB, promo)
+}
+
+func (d *DynamoStore) DeletePromotion(code string) error {
+	return DeletePromotion(d.DB, code)
+}
+
+func (d *DynamoStore) ScanPromotions() ([]*store.Promotion, error) {
+	return ScanPromotions(d.DB)
+}
+
+func (d *DynamoStore) GetCustomerRedemptions(code, userID string) (int, error) {
+	return GetCustomerRedemptions(d.DB, code, userID)
+}
+
+func (d *DynamoStore) RedeemPromotions(userID string, promos []*store.Promotion) error {
+	return RedeemPromotions(d.DB, userID, promos)
+}
+
+func (d *DynamoStore) ReleasePromotions(userID string, promos []*store.Promotion) error {
+	return ReleasePromotions(d.DB, userID, promos)
+}
+
+func (d *DynamoStore) ReleaseOrderPromotions(order *store.Order, promos []*store.Promotion) error {
+	return ReleaseOrderPromotions(d.DB, order, promos)
+}
+
+func (d *DynamoStore) GetShippingRule(ruleID string) (*store.ShippingRule, error) {
+	return GetShippingRule(d.DB, ruleID)
+}
+
+func (d *DynamoStore) PutShippingRule(rule *store.ShippingRule) error {
+	return PutShippingRule(d.DB, rule)
+}
+
+func (d *DynamoStore) DeleteShippingRule(ruleID string) error {
+	return DeleteShippingRule(d.DB, ruleID)
+}
+
+func (d *DynamoStore) ScanShippingRules() ([]*store.ShippingRule, error) {
+	return ScanShippingRules(d.DB)
+}
+
+func (d *DynamoStore) GetLocation(locationID string) (*store.Location, error) {
+	return GetLocation(d.DB, locationID)
+}
+
+func (d *DynamoStore) PutLocation(loc *store.Location) error {
+	return PutLocation(d.DB, loc)
+}
+
+func (d *DynamoStore) DeleteLocation(locationID string) error {
+	return DeleteLocation(d.DB, locationID)
+}
+
+func (d *DynamoStore) ScanLocations() ([]*store.Location, error) {
+	return ScanLocations(d.DB)
+}
+
+func (d *DynamoStore) UpdateLocationStock(locationID, sizeID string, count int) error {
+	return UpdateLocationStock(d.DB, locationID, sizeID, count)
+}
+
+func (d *DynamoStore) GetOrderHolds(orderID string) ([]*store.InventoryHold, error) {
+	return GetOrderHolds(d.DB, orderID)
+}
+
+func (d *DynamoStore) ReserveItems(holds []*store.InventoryHold) ([]string, error) {
+	return ReserveItems(d.DB, holds)
+}
+
+func (d *DynamoStore) ConvertHolds(holds []*store.InventoryHold) error {
+	return ConvertHolds(d.DB, holds)
+}
+
+func (d *DynamoStore) ReleaseHolds(holds []*store.InventoryHold) error {
+	return ReleaseHolds(d.DB, holds)
+}
+
+func (d *DynamoStore) RestockHolds(holds []*store.InventoryHold) error {
+	return RestockHolds(d.DB, holds)
+}
+
+func (d *DynamoStore) ScanExpiredHolds(now int64) ([]*store.InventoryHold, error) {
+	return ScanExpiredHolds(d.DB, now)
+}
+
+func (d *DynamoStore) StageOrder(stage *OrderStage) error {
+	return StageOrder(d.DB, stage)
+}
+
+func (d *DynamoStore) GetDownloadGrant(userID, grantID string) (*store.DownloadGrant, error) {
+	return GetDownloadGrant(d.DB, userID, grantID)
+}
+
+func (d *DynamoStore) GetOrderDownloads(userID, orderID string) ([]*store.DownloadGrant, error) {
+	return GetOrderDownloads(d.DB, userID, orderID)
+}
+
+func (d *DynamoStore) PutDownloadGrant(grant *store.DownloadGrant) error {
+	return PutDownloadGrant(d.DB, grant)
+}
+
+func (d *DynamoStore) GetContract(userID, contractID string) (*store.Contract, error) {
+	return GetContract(d.DB, userID, contractID)
+}
+
+func (d *DynamoStore) PutContract(contract *store.Contract) error {
+	return PutContract(d.DB, contract)
+}
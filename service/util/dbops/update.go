@@ -0,0 +1,320 @@
+package dbops
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/go-aws/go-dynamo/dynamo"
+)
+
+// Update operation types
+const (
+	opSet       = "SET"
+	opRemove    = "REMOVE"
+	opIncrement = "INCREMENT"
+	opAppend    = "APPEND"
+)
+
+// Condition operators
+const (
+	CondEqual            = "EQUAL"
+	CondExists           = "EXISTS"
+	CondNotExists        = "NOT_EXISTS"
+	CondGreaterThanEqual = "GREATER_THAN_EQUAL"
+)
+
+// Condition represents a condition on an attribute of the updated item. Nested map attributes
+// are addressed with dot notation (ex: 'units_available.XL').
+type Condition struct {
+	Path  string
+	Op    string
+	Value interface{}
+}
+
+// Equal returns a Condition that the attribute at path equals value.
+func Equal(path string, value interface{}) Condition {
+	return Condition{Path: path, Op: CondEqual, Value: value}
+}
+
+// Exists returns a Condition that the attribute at path exists.
+func Exists(path string) Condition {
+	return Condition{Path: path, Op: CondExists}
+}
+
+// NotExists returns a Condition that the attribute at path does not exist.
+func NotExists(path string) Condition {
+	return Condition{Path: path, Op: CondNotExists}
+}
+
+// GreaterThanEqual returns a Condition that the number at path is greater than or equal to n.
+func GreaterThanEqual(path string, n int) Condition {
+	return Condition{Path: path, Op: CondGreaterThanEqual, Value: n}
+}
+
+// updateOp represents a single action of an Update.
+type updateOp struct {
+	op    string
+	path  string
+	value interface{}
+}
+
+// Update is a partial update of a single item. Updates set, remove, increment and append to
+// multiple attributes in one UpdateItem call, optionally on a set of conditions. Use the
+// constructor for the item's table (ex: NewOrderUpdate) and apply the Update with
+// Store.UpdateItem. Updating an item that does not exist creates it unless a condition
+// requires the item to exist (see Exists).
+type Update struct {
+	table     func() string // DynamoDB table name
+	memTable  string        // MemStore table name
+	pkName    string
+	pk        string
+	skName    string
+	sk        string
+	ops       []updateOp
+	conds     [][]Condition // conditions of each group are OR'd; groups are AND'd
+	versioned bool          // increment the item's version on update
+}
+
+// NewStoreItemUpdate returns a new Update for the StoreItem.
+func NewStoreItemUpdate(subcat, itemID string) *Update {
+	return &Update{table: StoreItemsTable, memTable: memItems, pkName: StoreItemPK, pk: subcat, skName: StoreItemSK, sk: itemID}
+}
+
+// NewStoreItemSummaryUpdate returns a new Update for the StoreItemSummary.
+func NewStoreItemSummaryUpdate(subcat, itemID string) *Update {
+	return &Update{table: StoreItemsSummaryTable, memTable: memItemsSummary, pkName: StoreItemSummaryPK, pk: subcat, skName: StoreItemSummarySK, sk: itemID}
+}
+
+// NewOrderUpdate returns a new Update for the Order. Updates increment the order's version.
+func NewOrderUpdate(userID, orderID string) *Update {
+	return &Update{table: OrdersTable, memTable: memOrders, pkName: OrdersPK, pk: userID, skName: OrdersSK, sk: orderID, versioned: true}
+}
+
+// NewTransactionUpdate returns a new Update for the Transaction.
+func NewTransactionUpdate(userID, txID string) *Update {
+	return &Update{table: TransactionsTable, memTable: memTransactions, pkName: TransactionsPK, pk: userID, skName: TransactionsSK, sk: txID}
+}
+
+// NewCustomerUpdate returns a new Update for the Customer. Updates increment the customer's
+// version.
+func NewCustomerUpdate(email string) *Update {
+	return &Update{table: func() string { return CustomersTable }, memTable: memCustomers, pkName: CustomersPK, pk: email, versioned: true}
+}
+
+// NewDownloadGrantUpdate returns a new Update for the DownloadGrant.
+func NewDownloadGrantUpdate(userID, grantID string) *Update {
+	return &Update{table: DownloadsTable, memTable: memDownloads, pkName: DownloadsPK, pk: userID, skName: DownloadsSK, sk: grantID}
+}
+
+// NewContractUpdate returns a new Update for the Contract.
+func NewContractUpdate(userID, contractID string) *Update {
+	return &Update{table: ContractsTable, memTable: memContracts, pkName: ContractsPK, pk: userID, skName: ContractsSK, sk: contractID}
+}
+
+// NewReturnUpdate returns a new Update for the Return.
+func NewReturnUpdate(userID, returnID string) *Update {
+	return &Update{table: ReturnsTable, memTable: memReturns, pkName: ReturnsPK, pk: userID, skName: ReturnsSK, sk: returnID}
+}
+
+// NewShipmentUpdate returns a new Update for the Shipment.
+func NewShipmentUpdate(userID, orderID string) *Update {
+	return &Update{table: ShipmentsTable, memTable: memShipments, pkName: ShipmentsPK, pk: userID, skName: ShipmentsSK, sk: orderID}
+}
+
+// NewPromotionUpdate returns a new Update for the Promotion.
+func NewPromotionUpdate(code string) *Update {
+	return &Update{table: PromotionsTable, memTable: memPromotions, pkName: PromotionsPK, pk: code}
+}
+
+// NewLocationUpdate returns a new Update for the Location.
+func NewLocationUpdate(locationID string) *Update {
+	return &Update{table: LocationsTable, memTable: memLocations, pkName: LocationsPK, pk: locationID}
+}
+
+// Set sets the attribute at path to value.
+func (u *Update) Set(path string, value interface{}) *Update {
+	u.ops = append(u.ops, updateOp{op: opSet, path: path, value: value})
+	return u
+}
+
+// Remove removes the attribute at path.
+func (u *Update) Remove(path string) *Update {
+	u.ops = append(u.ops, updateOp{op: opRemove, path: path})
+	return u
+}
+
+// Increment adds n to the number at path. Missing attributes are treated as 0.
+func (u *Update) Increment(path string, n int) *Update {
+	u.ops = append(u.ops, updateOp{op: opIncrement, path: path, value: n})
+	return u
+}
+
+// Append appends the values to the list at path. Missing attributes are treated as empty lists.
+func (u *Update) Append(path string, values ...interface{}) *Update {
+	u.ops = append(u.ops, updateOp{op: opAppend, path: path, value: values})
+	return u
+}
+
+// If adds a condition group to the update; the update succeeds if any condition of the group
+// passes. Each condition group must pass.
+func (u *Update) If(conds ...Condition) *Update {
+	if len(conds) > 0 {
+		u.conds = append(u.conds, conds)
+	}
+	return u
+}
+
+// IfExists adds the condition that the item exists.
+func (u *Update) IfExists() *Update {
+	return u.If(Exists(u.pkName))
+}
+
+// build returns the update's DynamoDB expression.
+func (u *Update) build() (expression.Expression, error) {
+	var update expression.UpdateBuilder
+	for _, op := range u.ops {
+		name := expression.Name(op.path)
+		switch op.op {
+		case opSet:
+			update = update.Set(name, expression.Value(op.value))
+		case opRemove:
+			update = update.Remove(name)
+		case opIncrement:
+			update = update.Set(name, expression.Plus(expression.IfNotExists(name, expression.Value(0)), expression.Value(op.value)))
+		case opAppend:
+			update = update.Set(name, expression.ListAppend(expression.IfNotExists(name, expression.Value([]interface{}{})), expression.Value(op.value)))
+		}
+	}
+	if u.versioned {
+		update = update.Add(expression.Name("version"), expression.Value(1))
+	}
+	builder := expression.NewBuilder().WithUpdate(update)
+
+	var cond expression.ConditionBuilder
+	for i, group := range u.conds {
+		c := group[0].build()
+		if len(group) > 1 {
+			others := []expression.ConditionBuilder{}
+			for _, other := range group[2:] {
+				others = append(others, other.build())
+			}
+			c = expression.Or(c, group[1].build(), others...)
+		}
+		if i == 0 {
+			cond = c
+			continue
+		}
+		cond = cond.And(c)
+	}
+	if len(u.conds) > 0 {
+		builder = builder.WithCondition(cond)
+	}
+	return builder.Build()
+}
+
+// build returns the DynamoDB condition.
+func (c Condition) build() expression.ConditionBuilder {
+	name := expression.Name(c.Path)
+	switch c.Op {
+	case CondExists:
+		return expression.AttributeExists(name)
+	case CondNotExists:
+		return expression.AttributeNotExists(name)
+	case CondGreaterThanEqual:
+		return name.GreaterThanEqual(expression.Value(c.Value))
+	}
+	return name.Equal(expression.Value(c.Value))
+}
+
+// key returns the DynamoDB key of the updated item.
+func (u *Update) key() map[string]*dynamodb.AttributeValue {
+	key := map[string]*dynamodb.AttributeValue{u.pkName: {S: aws.String(u.pk)}}
+	if u.skName != "" {
+		key[u.skName] = &dynamodb.AttributeValue{S: aws.String(u.sk)}
+	}
+	return key
+}
+
+// transactItem returns the Update as a write of a TransactWriteItems call.
+func (u *Update) transactItem() (*dynamodb.TransactWriteItem, error) {
+	expr, err := u.build()
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+		TableName:                 aws.String(u.table()),
+		Key:                       u.key(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	}}, nil
+}
+
+// UpdateItem applies the Update to its item in a single UpdateItem call. Returns
+// ErrConditionalCheck if a condition of the update fails.
+func UpdateItem(DB *dynamo.DbInfo, u *Update) error {
+	if len(u.ops) == 0 {
+		return nil
+	}
+	expr, err := u.build()
+	if err != nil {
+		log.Printf("UpdateItem failed: %v", err)
+		return err
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(u.table()),
+		Key:                       u.key(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	}
+	_, err = DB.Svc.UpdateItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf(ErrConditionalCheck)
+		}
+		log.Printf("UpdateItem failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateItems applies the Updates to their items in a single transaction. No item is updated if
+// a condition of any update fails; ErrConditionalCheck is returned.
+func UpdateItems(DB *dynamo.DbInfo, updates ...*Update) error {
+	items := []*dynamodb.TransactWriteItem{}
+	for _, u := range updates {
+		if len(u.ops) == 0 {
+			continue
+		}
+		item, err := u.transactItem()
+		if err != nil {
+			log.Printf("UpdateItems failed: %v", err)
+			return err
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	_, err := DB.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		if tce, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for _, reason := range tce.CancellationReasons {
+				if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+					return fmt.Errorf(ErrConditionalCheck)
+				}
+			}
+		}
+		log.Printf("UpdateItems failed: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package dbops
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+func TestMemStoreUpdateItem(t *testing.T) {
+	s := NewMemStore()
+	s.PutCustomer(&store.Customer{UserID: "user001", Email: "user001@example.com", Purchases: 2, OpenOrderIDs: []string{"user001-1"}})
+
+	var tests = []struct {
+		update  *Update
+		wantErr string
+	}{
+		{update: NewCustomerUpdate("user001@example.com").Increment("purchases", 3).Set("city", "Austin").IfExists()},
+		{update: NewCustomerUpdate("user001@example.com").Append("open_order_id", "user001-2", "user001-3").Remove("country")},
+		{update: NewCustomerUpdate("user001@example.com").Set("city", "Dallas").If(Equal("city", "Houston")), wantErr: ErrConditionalCheck},
+		{update: NewCustomerUpdate("user001@example.com").Set("city", "Dallas").If(Equal("city", "Houston"), GreaterThanEqual("purchases", 5))},
+		{update: NewCustomerUpdate("user001@example.com").Increment("purchases", 1).If(GreaterThanEqual("purchases", 5)).If(NotExists("returns")), wantErr: ErrConditionalCheck},
+		{update: NewCustomerUpdate("user002@example.com").Set("city", "Austin").IfExists(), wantErr: ErrConditionalCheck},
+		{update: NewCustomerUpdate("user001@example.com").Set("city", "Waco").Set("city.name", "Waco"), wantErr: ErrInvalidPath},
+	}
+	for _, test := range tests {
+		err := s.UpdateItem(test.update)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+	}
+
+	// failed updates are not applied
+	cust, _ := s.GetCustomer("user001@example.com")
+	if cust.Purchases != 5 || cust.City != "Dallas" || cust.Country != "" {
+		t.Errorf("FAIL: %d purchases, %q, %q", cust.Purchases, cust.City, cust.Country)
+	}
+	if strings.Join(cust.OpenOrderIDs, ",") != "user001-1,user001-2,user001-3" {
+		t.Errorf("FAIL: %v", cust.OpenOrderIDs)
+	}
+	if missing, _ := s.GetCustomer("user002@example.com"); missing.Email != "" {
+		t.Errorf("FAIL: %v; want: none", missing)
+	}
+}
+
+func TestUpdateBuild(t *testing.T) {
+	var tests = []struct {
+		update     *Update
+		wantUpdate []string
+		wantCond   string
+	}{
+		{
+			update:     NewTransactionUpdate("user001", "tx001").Set("payment_status", "SUCCESS").Set("payment_method", "stripe"),
+			wantUpdate: []string{"SET #0 = :0, #1 = :1"},
+		},
+		{
+			update:     NewOrderUpdate("user001", "user001-1").Increment("total_items", 2).Append("items", "005-L").Remove("coupon"),
+			wantUpdate: []string{"if_not_exists(", "list_append(", "REMOVE ", "ADD "},
+		},
+		{
+			update:     orderStatusUpdate("user001", "user001-1", "", store.OrderStatusPaid),
+			wantUpdate: []string{"SET "},
+			wantCond:   "(attribute_exists (#0)) AND ((#1 = :0) OR (attribute_not_exists (#1)))",
+		},
+	}
+	for _, test := range tests {
+		expr, err := test.update.build()
+		if err != nil {
+			t.Errorf("FAIL: %v", err)
+			continue
+		}
+		for _, want := range test.wantUpdate {
+			if !strings.Contains(*expr.Update(), want) {
+				t.Errorf("FAIL: %s; want: %s", *expr.Update(), want)
+			}
+		}
+		if test.wantCond == "" {
+			if expr.Condition() != nil {
+				t.Errorf("FAIL: %s; want: nil", *expr.Condition())
+			}
+			continue
+		}
+		if expr.Condition() == nil {
+			t.Errorf("FAIL: nil; want: %s", test.wantCond)
+		} else if *expr.Condition() != test.wantCond {
+			t.Errorf("FAIL: %s; want: %s", *expr.Condition(), test.wantCond)
+		}
+	}
+}
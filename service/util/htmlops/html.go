@@ -3,14 +3,19 @@ package htmlops
 import (
 	"bytes"
 	"html/template"
+	"strings"
+
+	"github.com/tpillz-presents/service/store-api/store"
 )
 
 type ReceiptTemplateData struct {
 	OrderID    string
-	Subtotal   float32
-	SalesTax   float32
-	Shipping   float32
-	OrderTotal float32
+	Subtotal   store.Money
+	SalesTax   store.Money
+	TaxRegion  string  // sales tax jurisdiction (ex: CA, CA-90001)
+	TaxRate    float64 // combined sales tax rate (ex: 0.0725)
+	Shipping   store.Money
+	OrderTotal store.Money
 	FirstName  string
 	LastName   string
 	Address1   string
@@ -20,6 +25,7 @@ type ReceiptTemplateData struct {
 	Zip        string
 	Phone      string
 	Items      []ItemSummary
+	Contracts  []ContractLink // signed lease agreements of digital items
 }
 
 type ItemSummary struct {
@@ -45,6 +51,92 @@ type ShippingNotificationTemplateData struct {
 	Items          []ItemSummary
 }
 
+type ReturnNotificationTemplateData struct {
+	ReturnID       string
+	OrderID        string
+	ReturnStatus   string
+	Message        string
+	RefundAmount   store.Money
+	Carrier        string
+	TrackingNumber string
+	TrackingUrl    string
+	LabelUrl       string
+	FirstName      string
+	LastName       string
+	Items          []ItemSummary
+}
+
+type TrackingNotificationTemplateData struct {
+	OrderID        string
+	Notice         string // OUT_FOR_DELIVERY, DELIVERED, EXCEPTION
+	Status         string
+	StatusDetails  string
+	StatusDate     string // 'MM-DD-YYYY HH:MM:SS' UTC
+	Carrier        string
+	TrackingNumber string
+	TrackingUrl    string
+	FirstName      string
+	LastName       string
+}
+
+type CartReminderTemplateData struct {
+	Items        []CartReminderItem
+	Subtotal     store.Money
+	DiscountCode string // one-time coupon code; no discount offered if empty
+	Discount     string // discount description (ex: 10% off the items in your cart)
+	DiscountEnds string // coupon code expiration ('MM-DD-YYYY HH:MM:SS' UTC)
+}
+
+type CartReminderItem struct {
+	Name         string
+	Size         string
+	Quantity     int
+	Price        store.Money
+	ThumbnailUrl string
+}
+
+type ContractLink struct {
+	Name string // item name & license (ex: Midnight (BASIC))
+	Url  string
+}
+
+type ContractTemplateData struct {
+	OrderID   string
+	BuyerName string
+	BeatTitle string
+	License   string // license tier name (ex: Basic Lease)
+	Rights    string // license terms granted to the buyer
+	Price     store.Money
+	Date      string
+}
+
+type SignatureTemplateData struct {
+	Signature   string
+	SignedAt    string // RFC 3339 UTC
+	SignerIP    string
+	ContentHash string // hash of the agreement signed
+}
+
+// signatureTmpl is the signature block appended to signed agreements.
+const signatureTmpl = `<div class="signature">
+<p>Signed electronically by <strong>{{.Signature}}</strong> on {{.SignedAt}} from {{.SignerIP}}.</p>
+<p>Agreement SHA-256: <code>{{.ContentHash}}</code></p>
+</div>
+`
+
+// CreateSignedContractHtml returns the agreement with the signature block inserted before the
+// closing body tag, or appended if the agreement has no body tag.
+func CreateSignedContractHtml(contract string, data SignatureTemplateData) (string, error) {
+	block, err := CreateHtmlTemplate(signatureTmpl, data)
+	if err != nil {
+		return "", err
+	}
+	if i := strings.LastIndex(contract, "</body>"); i >= 0 {
+		return contract[:i] + block + contract[i:], nil
+	}
+	return contract + block, nil
+}
+
 func CreateHtmlTemplate(tmpl string, data interface{}) (string, error) {
 	t := template.New("order_notification")
 
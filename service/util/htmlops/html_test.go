@@ -1,8 +1,10 @@
 package htmlops
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/tpillz-presents/service/store-api/store"
 	"github.com/tpillz-presents/service/util/s3ops"
 )
 
@@ -10,10 +12,10 @@ func TestCreateHtmlTemplate(t *testing.T) {
 	var tests = []ReceiptTemplateData{
 		ReceiptTemplateData{
 			OrderID:    "0000001",
-			Subtotal:   19.99,
-			SalesTax:   0.88,
-			Shipping:   5.99,
-			OrderTotal: 26.78,
+			Subtotal:   store.USD(1999),
+			SalesTax:   store.USD(88),
+			Shipping:   store.USD(599),
+			OrderTotal: store.USD(2678),
 			FirstName:  "Daniel",
 			LastName:   "Garcia",
 			Address1:   "3250 Hollis St",
@@ -42,3 +44,22 @@ func TestCreateHtmlTemplate(t *testing.T) {
 		t.Logf("result: %s", html)
 	}
 }
+
+func TestCreateSignedContractHtml(t *testing.T) {
+	data := SignatureTemplateData{Signature: "Jane Doe", SignedAt: "2021-05-30T12:00:00Z", SignerIP: "203.0.113.7", ContentHash: "abc123"}
+	var tests = []struct {
+		contract   string
+		wantPrefix string
+		wantSuffix string
+	}{
+		{contract: "<html><body><p>Lease</p></body></html>", wantPrefix: "<html><body><p>Lease</p><div", wantSuffix: "</div>\n</body></html>"},
+		{contract: "<p>Lease</p>", wantPrefix: "<p>Lease</p><div", wantSuffix: "</div>\n"},
+	}
+	for _, test := range tests {
+		html, err := CreateSignedContractHtml(test.contract, data)
+		if err != nil || !strings.HasPrefix(html, test.wantPrefix) || !strings.HasSuffix(html, test.wantSuffix) ||
+			!strings.Contains(html, "Signed electronically by <strong>Jane Doe</strong>") {
+			t.Errorf("FAIL: %v, %s", err, html)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package httpops
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"strings"
 )
 
 // HttpResponse contains a status code, message, and body to return to the client
@@ -48,3 +50,16 @@ func GetQueryStringParams(r *http.Request) map[string]string {
 	}
 	return data
 }
+
+// ClientIP returns the IP address of the client that sent the request. The first address of the
+// X-Forwarded-For header set by the API gateway is used if present.
+func ClientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
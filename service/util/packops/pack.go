@@ -0,0 +1,597 @@
+// Package packops packs the physical items of an order into the store's shipping parcels.
+// Items are placed in 3 dimensions with each of their orientations, so parcels are only used
+// if their contents fit, and the parcel weight limits are respected. Packings are chosen to
+// minimize the estimated shipping cost or the number of parcels.
+package packops
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// Error codes returned by Pack.
+const (
+	ErrNoParcels         = "ERR_NO_PARCELS"
+	ErrItemTooLarge      = "ERR_ITEM_TOO_LARGE"
+	ErrInvalidDimensions = "ERR_INVALID_DIMENSIONS"
+)
+
+// Packing objectives
+const (
+	MinimizeCost  = "COST"  // lowest estimated shipping cost, then fewest parcels
+	MinimizeBoxes = "BOXES" // fewest parcels, then lowest estimated shipping cost
+)
+
+// DefaultMaxWeightLbs is the weight limit of parcels that do not set their own (the USPS
+// limit for domestic parcels).
+const DefaultMaxWeightLbs = 70
+
+// DimDivisor is the number of cubic inches billed as one pound of dimensional weight.
+const DimDivisor = 139
+
+// DefaultPerLb is the estimated shipping cost per billable pound used by DefaultOptions.
+var DefaultPerLb = store.USD(100)
+
+// eps is the tolerance of dimension comparisons in inches.
+const eps = 1e-6
+
+// CostFunc returns the estimated cost of shipping the parcel with the given gross weight.
+type CostFunc func(p *store.Parcel, weightLbs float64) store.Money
+
+// Options configure how items are packed.
+type Options struct {
+	Objective string   // MinimizeCost or MinimizeBoxes
+	Cost      CostFunc // nil: BillableWeightCost(DefaultPerLb, DimDivisor)
+}
+
+// DefaultOptions minimizes the shipping cost estimated from the billable weight of parcels.
+var DefaultOptions = Options{Objective: MinimizeCost}
+
+// BillableWeightCost returns a CostFunc that estimates the shipping cost of a parcel as the
+// parcel's unit price plus perLb for each pound of its billable weight: the greater of its
+// gross weight and dimensional weight (volume / divisor), rounded up.
+func BillableWeightCost(perLb store.Money, divisor float64) CostFunc {
+	return func(p *store.Parcel, weightLbs float64) store.Money {
+		billable := weightLbs
+		if l, w, h, err := sizeIn(p.ParcelDimensions); err == nil && divisor > 0 {
+			if dim := l * w * h / divisor; dim > billable {
+				billable = dim
+			}
+		}
+		return p.UnitPriceUSD.Add(perLb.Mul(int64(math.Ceil(billable - eps))))
+	}
+}
+
+// unit is a single unit of an item to pack.
+type unit struct {
+	item   *store.CartItem
+	dims   [3]float64 // inches
+	weight float64    // lbs
+	volume float64
+}
+
+// boxType is a parcel of the catalog.
+type boxType struct {
+	parcel    *store.Parcel
+	dims      [3]float64 // inner dimensions in inches
+	tare      float64    // lbs
+	maxWeight float64    // lbs
+	volume    float64
+}
+
+// placement is a unit placed in a bin at x, y, z with the given oriented dimensions.
+type placement struct {
+	unit *unit
+	pos  [3]float64
+	dims [3]float64
+}
+
+// bin is a parcel being packed.
+type bin struct {
+	box    *boxType
+	weight float64 // contents weight in lbs
+	placed []placement
+	points [][3]float64 // candidate positions
+}
+
+func newBin(box *boxType) *bin {
+	return &bin{box: box, points: [][3]float64{{0, 0, 0}}}
+}
+
+// orientations returns the distinct orientations of the dimensions, lowest height first.
+func orientations(d [3]float64) [][3]float64 {
+	perms := [][3]int{{0, 1, 2}, {1, 0, 2}, {0, 2, 1}, {2, 0, 1}, {1, 2, 0}, {2, 1, 0}}
+	out := [][3]float64{}
+	seen := make(map[[3]float64]bool)
+	for _, p := range perms {
+		o := [3]float64{d[p[0]], d[p[1]], d[p[2]]}
+		if !seen[o] {
+			seen[o] = true
+			out = append(out, o)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i][2] < out[j][2] })
+	return out
+}
+
+// overlaps returns true if the boxes at a and b with dimensions da and db intersect.
+func overlaps(a, da, b, db [3]float64) bool {
+	for i := 0; i < 3; i++ {
+		if a[i]+da[i] <= b[i]+eps || b[i]+db[i] <= a[i]+eps {
+			return false
+		}
+	}
+	return true
+}
+
+// place places the unit at the lowest, back-most, left-most candidate position it fits in
+// any orientation. Returns false if the unit does not fit or exceeds the bin's weight limit.
+func (b *bin) place(u *unit) bool {
+	if b.box.tare+b.weight+u.weight > b.box.maxWeight+eps {
+		return false
+	}
+	for _, pt := range b.points {
+		for _, o := range orientations(u.dims) {
+			if pt[0]+o[0] > b.box.dims[0]+eps || pt[1]+o[1] > b.box.dims[1]+eps || pt[2]+o[2] > b.box.dims[2]+eps {
+				continue
+			}
+			free := true
+			for _, p := range b.placed {
+				if overlaps(pt, o, p.pos, p.dims) {
+					free = false
+					break
+				}
+			}
+			if !free {
+				continue
+			}
+			b.placed = append(b.placed, placement{unit: u, pos: pt, dims: o})
+			b.weight += u.weight
+			b.updatePoints(pt, o)
+			return true
+		}
+	}
+	return false
+}
+
+// updatePoints replaces the candidate positions covered by a unit placed at pos with dimensions
+// dims, including pos, by the positions at the unit's right, back and top. Positions covered by
+// placed units or on the far sides of the bin cannot hold a unit and are dropped, so the number
+// of candidate positions stays proportional to the surface of the bin's contents.
+func (b *bin) updatePoints(pos, dims [3]float64) {
+	points := [][3]float64{}
+	for _, pt := range b.points {
+		if !covers(pos, dims, pt) {
+			points = append(points, pt)
+		}
+	}
+	for d := 0; d < 3; d++ {
+		pt := pos
+		pt[d] += dims[d]
+		if b.usable(pt, points) {
+			points = append(points, pt)
+		}
+	}
+	b.points = points
+	sort.SliceStable(b.points, func(i, j int) bool {
+		p, q := b.points[i], b.points[j]
+		if p[2] != q[2] {
+			return p[2] < q[2]
+		}
+		if p[1] != q[1] {
+			return p[1] < q[1]
+		}
+		return p[0] < q[0]
+	})
+}
+
+// covers returns true if the point is within the box at pos with dimensions dims, excluding its
+// far sides; units placed at the point would overlap the box.
+func covers(pos, dims, pt [3]float64) bool {
+	for i := 0; i < 3; i++ {
+		if pt[i] < pos[i]-eps || pt[i] >= pos[i]+dims[i]-eps {
+			return false
+		}
+	}
+	return true
+}
+
+// usable returns true if the point is inside the bin, not covered by a placed unit, and not one
+// of the candidate positions.
+func (b *bin) usable(pt [3]float64, points [][3]float64) bool {
+	for i := 0; i < 3; i++ {
+		if pt[i] >= b.box.dims[i]-eps {
+			return false
+		}
+	}
+	for _, p := range b.placed {
+		if covers(p.pos, p.dims, pt) {
+			return false
+		}
+	}
+	for _, p := range points {
+		if p == pt {
+			return false
+		}
+	}
+	return true
+}
+
+// fill packs as many of the units as fit into a new bin of the box type, in order, and
+// returns the bin and the units that did not fit. Units identical to a unit that did not fit
+// are not placed again until another unit is placed, as the bin is unchanged.
+func fill(box *boxType, units []*unit) (*bin, []*unit) {
+	b := newBin(box)
+	rest := []*unit{}
+	failed := make(map[shape]int) // shape: number of units placed when the shape did not fit
+	for _, u := range units {
+		if n, ok := failed[u.shape()]; ok && n == len(b.placed) {
+			rest = append(rest, u)
+			continue
+		}
+		if !b.place(u) {
+			failed[u.shape()] = len(b.placed)
+			rest = append(rest, u)
+		}
+	}
+	return b, rest
+}
+
+// shape is the dimensions and weight of a unit; units of the same shape are placed alike.
+type shape struct {
+	dims   [3]float64
+	weight float64
+}
+
+func (u *unit) shape() shape {
+	return shape{dims: u.dims, weight: u.weight}
+}
+
+// fits returns true if the box can hold the unit on its own.
+func (box *boxType) fits(u *unit) bool {
+	return newBin(box).place(u)
+}
+
+// solution is a complete packing of the units.
+type solution struct {
+	bins []*bin
+	cost store.Money
+}
+
+// packer packs units into the box types of the catalog.
+type packer struct {
+	boxes []*boxType
+	opts  Options
+}
+
+// cost returns the estimated shipping cost of the bin.
+func (p *packer) cost(b *bin) store.Money {
+	return p.opts.Cost(b.box.parcel, b.box.tare+b.weight)
+}
+
+// better returns true if solution a is better than solution b for the packing objective.
+func (p *packer) better(a, b *solution) bool {
+	if b == nil {
+		return true
+	}
+	c := a.cost.Cmp(b.cost)
+	if p.opts.Objective == MinimizeBoxes {
+		if len(a.bins) != len(b.bins) {
+			return len(a.bins) < len(b.bins)
+		}
+		return c < 0
+	}
+	if c != 0 {
+		return c < 0
+	}
+	return len(a.bins) < len(b.bins)
+}
+
+// smallest returns the cheapest bin of any box type that holds all of the units, or nil.
+func (p *packer) smallest(units []*unit) *bin {
+	var best *bin
+	var bestCost store.Money
+	for _, box := range p.boxes {
+		b, rest := fill(box, units)
+		if len(rest) > 0 {
+			continue
+		}
+		if c := p.cost(b); best == nil || c.Cmp(bestCost) < 0 {
+			best, bestCost = b, c
+		}
+	}
+	return best
+}
+
+// greedy packs the units by repeatedly filling the bin chosen by pick with the remaining
+// units. Each bin is then repacked into the cheapest box type that holds its contents.
+func (p *packer) greedy(units []*unit, pick func(units []*unit) *bin) *solution {
+	s := &solution{}
+	for len(units) > 0 {
+		b := pick(units)
+		if b == nil || len(b.placed) == 0 {
+			return nil
+		}
+		s.bins = append(s.bins, b)
+		placed := make(map[*unit]bool)
+		for _, pl := range b.placed {
+			placed[pl.unit] = true
+		}
+		rest := []*unit{}
+		for _, u := range units {
+			if !placed[u] {
+				rest = append(rest, u)
+			}
+		}
+		units = rest
+	}
+	for i, b := range s.bins {
+		contents := []*unit{}
+		for _, pl := range b.placed {
+			contents = append(contents, pl.unit)
+		}
+		sortUnits(contents)
+		if small := p.smallest(contents); small != nil {
+			s.bins[i] = small
+		}
+		s.cost = s.cost.Add(p.cost(s.bins[i]))
+	}
+	return s
+}
+
+// pack returns the best packing of the units found by filling bins of each single box type,
+// and by filling the cheapest box type that holds the remaining units, or else the box type
+// that ships the most volume (or the most volume per cost), at each step.
+func (p *packer) pack(units []*unit) (*solution, error) {
+	for _, u := range units {
+		fit := false
+		for _, box := range p.boxes {
+			if box.fits(u) {
+				fit = true
+				break
+			}
+		}
+		if !fit {
+			return nil, fmt.Errorf(ErrItemTooLarge)
+		}
+	}
+
+	var best *solution
+	for _, box := range p.boxes {
+		box := box
+		s := p.greedy(units, func(units []*unit) *bin {
+			b, _ := fill(box, units)
+			return b
+		})
+		if s != nil && p.better(s, best) {
+			best = s
+		}
+	}
+	s := p.greedy(units, func(units []*unit) *bin {
+		if b := p.smallest(units); b != nil {
+			return b
+		}
+		var pick *bin
+		var pickScore float64
+		for _, box := range p.boxes {
+			b, _ := fill(box, units)
+			vol := 0.0
+			for _, pl := range b.placed {
+				vol += pl.unit.volume
+			}
+			if vol == 0 {
+				continue
+			}
+			score := vol
+			if p.opts.Objective != MinimizeBoxes {
+				score = vol / math.Max(p.cost(b).Float64(), eps)
+			}
+			if pick == nil || score > pickScore+eps {
+				pick, pickScore = b, score
+			}
+		}
+		return pick
+	})
+	if s != nil && p.better(s, best) {
+		best = s
+	}
+	if best == nil {
+		return nil, fmt.Errorf(ErrItemTooLarge)
+	}
+	return best, nil
+}
+
+// Pack packs the units of the items into parcels of the catalog and returns a Package for each
+// parcel used, with its contents and gross weight. Returns ErrItemTooLarge if an item does not
+// fit any parcel, and ErrNoParcels if the catalog is empty.
+func Pack(items []*store.CartItem, parcels []*store.Parcel, opts Options) ([]store.Package, error) {
+	if opts.Cost == nil {
+		opts.Cost = BillableWeightCost(DefaultPerLb, DimDivisor)
+	}
+	boxes, err := newBoxTypes(parcels)
+	if err != nil {
+		log.Printf("Pack failed: %v", err)
+		return []store.Package{}, err
+	}
+	units, err := newUnits(items)
+	if err != nil {
+		log.Printf("Pack failed: %v", err)
+		return []store.Package{}, err
+	}
+	if len(units) == 0 {
+		return []store.Package{}, nil
+	}
+	if len(boxes) == 0 {
+		return []store.Package{}, fmt.Errorf(ErrNoParcels)
+	}
+
+	p := &packer{boxes: boxes, opts: opts}
+	s, err := p.pack(units)
+	if err != nil {
+		log.Printf("Pack failed: %v", err)
+		return []store.Package{}, err
+	}
+	packages := []store.Package{}
+	for _, b := range s.bins {
+		packages = append(packages, b.pkg())
+	}
+	return packages, nil
+}
+
+// pkg returns the Package of the bin.
+func (b *bin) pkg() store.Package {
+	summaries := make(map[string]*store.PkgItemSummary)
+	for _, pl := range b.placed {
+		item := pl.unit.item
+		s := summaries[item.SizeID]
+		if s == nil {
+			s = &store.PkgItemSummary{
+				Subcategory: item.Subcategory,
+				ItemID:      item.ItemID,
+				SizeID:      item.SizeID,
+				Name:        item.Name,
+				Size:        item.Size,
+			}
+			summaries[item.SizeID] = s
+		}
+		s.Quantity++
+	}
+	contents := []store.PkgItemSummary{}
+	for _, s := range summaries {
+		contents = append(contents, *s)
+	}
+	sort.Slice(contents, func(i, j int) bool { return contents[i].SizeID < contents[j].SizeID })
+
+	p := b.box.parcel
+	dims := p.ParcelDimensions
+	dims.Weight = fmt.Sprintf("%.2f", b.box.tare+b.weight)
+	dims.MassUnit = "lb"
+	return store.Package{
+		Carrier:    p.Carrier,
+		ParcelID:   p.ParcelID,
+		Name:       p.Name,
+		Dimensions: dims,
+		Template:   p.Template,
+		Items:      contents,
+	}
+}
+
+// newBoxTypes returns the box types of the parcels, smallest first.
+func newBoxTypes(parcels []*store.Parcel) ([]*boxType, error) {
+	boxes := []*boxType{}
+	for _, p := range parcels {
+		l, w, h, err := sizeIn(p.ParcelDimensions)
+		if err != nil {
+			return boxes, err
+		}
+		tare := 0.0
+		if p.ParcelDimensions.Weight != "" {
+			tare, err = weightLbs(p.ParcelDimensions)
+			if err != nil {
+				return boxes, err
+			}
+		}
+		max := float64(p.MaxWeightLbs)
+		if max <= 0 {
+			max = DefaultMaxWeightLbs
+		}
+		boxes = append(boxes, &boxType{parcel: p, dims: [3]float64{l, w, h}, tare: tare, maxWeight: max, volume: l * w * h})
+	}
+	sort.SliceStable(boxes, func(i, j int) bool {
+		if boxes[i].volume != boxes[j].volume {
+			return boxes[i].volume < boxes[j].volume
+		}
+		return boxes[i].parcel.ParcelID < boxes[j].parcel.ParcelID
+	})
+	return boxes, nil
+}
+
+// newUnits returns a unit for each quantity of the items, sorted for packing.
+func newUnits(items []*store.CartItem) ([]*unit, error) {
+	units := []*unit{}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			continue
+		}
+		l, w, h, err := sizeIn(item.ShippingDimensions)
+		if err != nil {
+			return units, err
+		}
+		wt, err := weightLbs(item.ShippingDimensions)
+		if err != nil {
+			return units, err
+		}
+		for i := 0; i < item.Quantity; i++ {
+			units = append(units, &unit{item: item, dims: [3]float64{l, w, h}, weight: wt, volume: l * w * h})
+		}
+	}
+	sortUnits(units)
+	return units, nil
+}
+
+// sortUnits sorts the units by volume, longest side and weight, largest first.
+func sortUnits(units []*unit) {
+	longest := func(u *unit) float64 { return math.Max(u.dims[0], math.Max(u.dims[1], u.dims[2])) }
+	sort.SliceStable(units, func(i, j int) bool {
+		a, b := units[i], units[j]
+		if a.volume != b.volume {
+			return a.volume > b.volume
+		}
+		if la, lb := longest(a), longest(b); la != lb {
+			return la > lb
+		}
+		if a.weight != b.weight {
+			return a.weight > b.weight
+		}
+		return a.item.SizeID < b.item.SizeID
+	})
+}
+
+// sizeIn returns the length, width and height of the dimensions in inches. Returns
+// ErrInvalidDimensions if a dimension is not a positive number.
+func sizeIn(d store.Dimensions) (float64, float64, float64, error) {
+	factor := 1.0
+	switch strings.ToLower(d.DistanceUnit) {
+	case "cm":
+		factor = 1 / 2.54
+	case "mm":
+		factor = 1 / 25.4
+	case "m":
+		factor = 1 / 0.0254
+	case "ft":
+		factor = 12
+	}
+	vals := [3]float64{}
+	for i, s := range []string{d.Length, d.Width, d.Height} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || v <= 0 {
+			return 0, 0, 0, fmt.Errorf(ErrInvalidDimensions)
+		}
+		vals[i] = v * factor
+	}
+	return vals[0], vals[1], vals[2], nil
+}
+
+// weightLbs returns the weight of the dimensions in pounds. Returns ErrInvalidDimensions if
+// the weight is not a number.
+func weightLbs(d store.Dimensions) (float64, error) {
+	w, err := strconv.ParseFloat(strings.TrimSpace(d.Weight), 64)
+	if err != nil || w < 0 {
+		return 0, fmt.Errorf(ErrInvalidDimensions)
+	}
+	switch strings.ToLower(d.MassUnit) {
+	case "oz":
+		return w / 16, nil
+	case "kg":
+		return w * 2.20462262, nil
+	case "g":
+		return w * 0.00220462262, nil
+	}
+	return w, nil
+}
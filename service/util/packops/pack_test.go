@@ -0,0 +1,272 @@
+package packops
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+func box(id, l, w, h, tare string, price int64, maxLbs float32) *store.Parcel {
+	return &store.Parcel{
+		Carrier:          store.CarriersUsps,
+		ParcelID:         id,
+		Name:             id,
+		ParcelDimensions: store.Dimensions{Length: l, Width: w, Height: h, DistanceUnit: "in", Weight: tare, MassUnit: "lb"},
+		UnitPriceUSD:     store.USD(price),
+		MaxWeightLbs:     maxLbs,
+	}
+}
+
+func item(sizeID string, qty int, l, w, h, wt string) *store.CartItem {
+	return &store.CartItem{
+		ItemID:             sizeID[:3],
+		SizeID:             sizeID,
+		Name:               "item " + sizeID,
+		Quantity:           qty,
+		ShippingDimensions: store.Dimensions{Length: l, Width: w, Height: h, DistanceUnit: "in", Weight: wt, MassUnit: "lb"},
+	}
+}
+
+// summary returns the parcel IDs and contents of the packages (ex: 'small[001-S:2]').
+func summary(pkgs []store.Package) []string {
+	out := []string{}
+	for _, p := range pkgs {
+		s := p.ParcelID + "["
+		for i, item := range p.Items {
+			if i > 0 {
+				s += " "
+			}
+			s += fmt.Sprintf("%s:%d", item.SizeID, item.Quantity)
+		}
+		out = append(out, s+"]")
+	}
+	return out
+}
+
+func TestPack(t *testing.T) {
+	small := box("small", "10", "10", "10", "0.5", 100, 0)
+	long := box("long", "30", "4", "4", "0.5", 150, 0)
+	big := box("big", "20", "20", "20", "1", 300, 0)
+	light := box("light", "12", "12", "12", "0.5", 100, 5)
+	var tests = []struct {
+		name      string
+		items     []*store.CartItem
+		parcels   []*store.Parcel
+		objective string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:    "long item rotated into long parcel",
+			items:   []*store.CartItem{item("001-P", 1, "3", "28", "3", "1")},
+			parcels: []*store.Parcel{small, long, big},
+			want:    []string{"long[001-P:1]"},
+		},
+		{
+			// 2 x 343 in3 fit the volume of a 1000 in3 parcel, but not its 10 in sides
+			name:    "volume fits but geometry does not",
+			items:   []*store.CartItem{item("002-C", 2, "7", "7", "7", "1")},
+			parcels: []*store.Parcel{small, big},
+			want:    []string{"small[002-C:1]", "small[002-C:1]"},
+		},
+		{
+			name:      "fewest boxes uses the larger parcel",
+			items:     []*store.CartItem{item("002-C", 2, "7", "7", "7", "1")},
+			parcels:   []*store.Parcel{small, big},
+			objective: MinimizeBoxes,
+			want:      []string{"big[002-C:2]"},
+		},
+		{
+			name:    "flat items stack",
+			items:   []*store.CartItem{item("003-M", 10, "10", "10", "1", "0.5")},
+			parcels: []*store.Parcel{small, big},
+			want:    []string{"small[003-M:10]"},
+		},
+		{
+			// 4 units fit the volume of the parcel, but only 2 fit its 5 lb limit with the tare
+			name:    "weight limit splits parcels",
+			items:   []*store.CartItem{item("004-B", 4, "6", "6", "6", "2")},
+			parcels: []*store.Parcel{light},
+			want:    []string{"light[004-B:2]", "light[004-B:2]"},
+		},
+		{
+			name: "mixed items share a parcel",
+			items: []*store.CartItem{
+				item("005-S", 1, "10", "10", "5", "1"),
+				item("006-S", 2, "5", "5", "5", "0.5"),
+			},
+			parcels: []*store.Parcel{small, big},
+			want:    []string{"small[005-S:1 006-S:2]"},
+		},
+		{
+			name:    "item too large",
+			items:   []*store.CartItem{item("007-X", 1, "31", "2", "2", "1")},
+			parcels: []*store.Parcel{small, long, big},
+			wantErr: ErrItemTooLarge,
+		},
+		{
+			name:    "item too heavy",
+			items:   []*store.CartItem{item("008-H", 1, "2", "2", "2", "5")},
+			parcels: []*store.Parcel{light},
+			wantErr: ErrItemTooLarge,
+		},
+		{
+			name:    "no parcels",
+			items:   []*store.CartItem{item("001-P", 1, "1", "1", "1", "1")},
+			parcels: []*store.Parcel{},
+			wantErr: ErrNoParcels,
+		},
+		{
+			name:    "invalid dimensions",
+			items:   []*store.CartItem{item("001-P", 1, "", "1", "1", "1")},
+			parcels: []*store.Parcel{small},
+			wantErr: ErrInvalidDimensions,
+		},
+		{
+			name:    "no items",
+			items:   []*store.CartItem{},
+			parcels: []*store.Parcel{small},
+			want:    []string{},
+		},
+	}
+	for _, test := range tests {
+		opts := DefaultOptions
+		if test.objective != "" {
+			opts.Objective = test.objective
+		}
+		pkgs, err := Pack(test.items, test.parcels, opts)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL - %s: %v; want: %s", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL - %s: %v", test.name, err)
+			continue
+		}
+		got := summary(pkgs)
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("FAIL - %s: %v; want: %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPackWeight(t *testing.T) {
+	parcels := []*store.Parcel{box("small", "10", "10", "10", "0.5", 100, 0)}
+	items := []*store.CartItem{
+		item("001-A", 2, "4", "4", "4", "0.75"),
+		{SizeID: "002-B", Quantity: 1, ShippingDimensions: store.Dimensions{Length: "10", Width: "10", Height: "5", DistanceUnit: "cm", Weight: "8", MassUnit: "oz"}},
+	}
+	pkgs, err := Pack(items, parcels, DefaultOptions)
+	if err != nil || len(pkgs) != 1 {
+		t.Fatalf("FAIL: %v, %v; want: 1 package", err, pkgs)
+	}
+	// 0.5 tare + 2 * 0.75 + 8 oz
+	if pkgs[0].Dimensions.Weight != "2.50" || pkgs[0].Dimensions.MassUnit != "lb" {
+		t.Errorf("FAIL - weight: %s %s; want: 2.50 lb", pkgs[0].Dimensions.Weight, pkgs[0].Dimensions.MassUnit)
+	}
+}
+
+func TestBillableWeightCost(t *testing.T) {
+	cost := BillableWeightCost(store.USD(100), DimDivisor)
+	var tests = []struct {
+		parcel *store.Parcel
+		weight float64
+		want   store.Money
+	}{
+		{box("small", "10", "10", "10", "0", 50, 0), 1, store.USD(50 + 800)},    // 1000 / 139 = 7.2 dim lbs
+		{box("small", "10", "10", "10", "0", 50, 0), 9.5, store.USD(50 + 1000)}, // actual weight
+		{box("tiny", "4", "4", "4", "0", 0, 0), 2, store.USD(200)},
+	}
+	for _, test := range tests {
+		if got := cost(test.parcel, test.weight); got.Cmp(test.want) != 0 {
+			t.Errorf("FAIL: %s; want: %s", got, test.want)
+		}
+	}
+}
+
+// TestPackRandom packs random item mixes and verifies that every unit is packed exactly once,
+// inside its bin, without overlapping other units or exceeding the bin's weight limit.
+func TestPackRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	parcels := []*store.Parcel{
+		box("s", "8", "6", "4", "0.3", 80, 0),
+		box("m", "12", "10", "6", "0.4", 120, 0),
+		box("l", "18", "14", "10", "0.8", 200, 20),
+		box("t", "24", "6", "6", "0.5", 150, 0),
+	}
+	boxes, err := newBoxTypes(parcels)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	dim := func(max int) string { return fmt.Sprintf("%.1f", 0.5+r.Float64()*float64(max)) }
+	for i := 0; i < 50; i++ {
+		items := []*store.CartItem{}
+		for j := 0; j < 1+r.Intn(5); j++ {
+			items = append(items, item(fmt.Sprintf("%03d-%d", i, j), 1+r.Intn(4), dim(10), dim(6), dim(4), dim(3)))
+		}
+		units, err := newUnits(items)
+		if err != nil {
+			t.Fatalf("FAIL: %v", err)
+		}
+		for _, objective := range []string{MinimizeCost, MinimizeBoxes} {
+			p := &packer{boxes: boxes, opts: Options{Objective: objective, Cost: BillableWeightCost(DefaultPerLb, DimDivisor)}}
+			s, err := p.pack(units)
+			if err != nil {
+				t.Errorf("FAIL - mix %d: %v", i, err)
+				continue
+			}
+			seen := make(map[*unit]int)
+			for _, b := range s.bins {
+				if b.box.tare+b.weight > b.box.maxWeight+eps {
+					t.Errorf("FAIL - mix %d: weight %.2f > %.2f", i, b.box.tare+b.weight, b.box.maxWeight)
+				}
+				for k, pl := range b.placed {
+					seen[pl.unit]++
+					for d := 0; d < 3; d++ {
+						if pl.pos[d] < -eps || pl.pos[d]+pl.dims[d] > b.box.dims[d]+eps {
+							t.Errorf("FAIL - mix %d: unit at %v %v outside of %v", i, pl.pos, pl.dims, b.box.dims)
+						}
+					}
+					for _, other := range b.placed[k+1:] {
+						if overlaps(pl.pos, pl.dims, other.pos, other.dims) {
+							t.Errorf("FAIL - mix %d: units at %v and %v overlap", i, pl.pos, other.pos)
+						}
+					}
+				}
+			}
+			for _, u := range units {
+				if seen[u] != 1 {
+					t.Errorf("FAIL - mix %d: unit %s packed %d times; want: 1", i, u.item.SizeID, seen[u])
+				}
+			}
+		}
+	}
+}
+
+// TestPackLargeQuantity packs a single line of many identical units, which used to take seconds.
+func TestPackLargeQuantity(t *testing.T) {
+	parcels := []*store.Parcel{box("m", "12", "10", "6", "0.4", 120, 0)}
+	items := []*store.CartItem{item("001-A", 500, "2", "2", "1", "0.1")}
+	start := time.Now()
+	pkgs, err := Pack(items, parcels, DefaultOptions)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	packed := 0
+	for _, p := range pkgs {
+		for _, item := range p.Items {
+			packed += item.Quantity
+		}
+	}
+	if packed != 500 || len(pkgs) > 3 {
+		t.Errorf("FAIL: %v; want: 500 units in at most 3 packages", summary(pkgs))
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("FAIL: packed in %s; want: < 1s", d)
+	}
+}
@@ -0,0 +1,189 @@
+package paymentops
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// Fake payment tokens. Any other token is authorized successfully.
+const (
+	FakeTokenDecline        = "tok_chargeDeclined"
+	FakeToken3DS            = "tok_threeDSecureRequired"
+	FakeTokenNetworkFailure = "tok_networkFailure"
+)
+
+// FakeFeePct and FakeFeeFixed are used to calculate the processing fee of captured payments.
+const (
+	FakeFeePct   = 2.9
+	FakeFeeFixed = 30 // cents
+)
+
+// Fake implements Provider in memory with deterministic results based on the payment token.
+// Fake is safe for concurrent use.
+type Fake struct {
+	mu       sync.Mutex
+	payments map[string]*Payment
+	refunds  map[string]*Refund
+	keys     map[string]string // idempotency key: payment ID
+	rkeys    map[string]string // idempotency key: refund ID
+	count    int
+}
+
+// NewFake returns a new *Fake payment provider.
+func NewFake() *Fake {
+	return &Fake{
+		payments: make(map[string]*Payment),
+		refunds:  make(map[string]*Refund),
+		keys:     make(map[string]string),
+		rkeys:    make(map[string]string),
+	}
+}
+
+func (f *Fake) Name() string {
+	return ProviderFake
+}
+
+// Authorize authorizes req.Amount. FakeTokenDecline returns a declined payment and ErrCardDeclined,
+// FakeToken3DS returns a payment requiring customer authentication (see Authenticate) that is
+// captured by the payment webhook, and FakeTokenNetworkFailure returns ErrProviderUnavailable.
+func (f *Fake) Authorize(req AuthorizeRequest) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.Token == FakeTokenNetworkFailure {
+		return &Payment{}, fmt.Errorf(ErrProviderUnavailable)
+	}
+	if req.Token == "" || req.Amount.Amount <= 0 {
+		return &Payment{}, fmt.Errorf(ErrInvalidRequest)
+	}
+	if id, ok := f.keys[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		p := *f.payments[id]
+		return &p, nil
+	}
+
+	f.count++
+	p := &Payment{
+		ID:       fmt.Sprintf("fake_pi_%04d", f.count),
+		Provider: ProviderFake,
+		Status:   StatusAuthorized,
+		Amount:   req.Amount,
+		Metadata: req.metadata(),
+	}
+	var err error
+	switch req.Token {
+	case FakeTokenDecline:
+		p.Status = StatusDeclined
+		p.Message = "Your card has insufficient funds."
+		err = fmt.Errorf(ErrCardDeclined)
+	case FakeToken3DS:
+		p.Status = StatusRequiresAction
+		p.ClientSecret = p.ID + "_secret"
+		p.Metadata[MetaAuthenticated] = "true"
+	}
+	f.payments[p.ID] = p
+	if req.IdempotencyKey != "" {
+		f.keys[req.IdempotencyKey] = p.ID
+	}
+	out := *p
+	return &out, err
+}
+
+// Authenticate simulates the customer completing 3DS authentication for the payment.
+func (f *Fake) Authenticate(paymentID string) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return &Payment{}, fmt.Errorf(ErrPaymentNotFound)
+	}
+	if p.Status != StatusRequiresAction {
+		return &Payment{}, fmt.Errorf(ErrInvalidPaymentState)
+	}
+	p.Status = StatusAuthorized
+	p.ClientSecret = ""
+	out := *p
+	return &out, nil
+}
+
+func (f *Fake) Capture(paymentID string, amount store.Money) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return &Payment{}, fmt.Errorf(ErrPaymentNotFound)
+	}
+	if p.Status != StatusAuthorized {
+		return &Payment{}, fmt.Errorf(ErrInvalidPaymentState)
+	}
+	if amount.Amount <= 0 || amount.Cmp(p.Amount) > 0 {
+		return &Payment{}, fmt.Errorf(ErrInvalidRequest)
+	}
+	p.Status = StatusCaptured
+	p.Captured = amount
+	p.Fee = amount.Percent(FakeFeePct, store.RoundHalfUp).Add(store.NewMoney(FakeFeeFixed, amount.Currency))
+	out := *p
+	return &out, nil
+}
+
+func (f *Fake) Refund(paymentID string, amount store.Money, idempotencyKey string) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id, ok := f.rkeys[idempotencyKey]; ok && idempotencyKey != "" {
+		r := *f.refunds[id]
+		return &r, nil
+	}
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return &Refund{}, fmt.Errorf(ErrPaymentNotFound)
+	}
+	if p.Status != StatusCaptured {
+		return &Refund{}, fmt.Errorf(ErrInvalidPaymentState)
+	}
+	if amount.Amount <= 0 || p.Refunded.Add(amount).Cmp(p.Captured) > 0 {
+		return &Refund{}, fmt.Errorf(ErrInvalidRequest)
+	}
+	p.Refunded = p.Refunded.Add(amount)
+	if p.Refunded == p.Captured {
+		p.Status = StatusRefunded
+	}
+	r := &Refund{
+		ID:        fmt.Sprintf("fake_re_%04d", len(f.refunds)+1),
+		PaymentID: paymentID,
+		Amount:    amount,
+		Status:    store.RefundStatusSuccess,
+	}
+	f.refunds[r.ID] = r
+	if idempotencyKey != "" {
+		f.rkeys[idempotencyKey] = r.ID
+	}
+	out := *r
+	return &out, nil
+}
+
+func (f *Fake) Void(paymentID string) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return &Payment{}, fmt.Errorf(ErrPaymentNotFound)
+	}
+	if p.Status != StatusAuthorized && p.Status != StatusRequiresAction {
+		return &Payment{}, fmt.Errorf(ErrInvalidPaymentState)
+	}
+	p.Status = StatusCanceled
+	out := *p
+	return &out, nil
+}
+
+func (f *Fake) Retrieve(paymentID string) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return &Payment{}, fmt.Errorf(ErrPaymentNotFound)
+	}
+	out := *p
+	return &out, nil
+}
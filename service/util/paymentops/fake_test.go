@@ -0,0 +1,100 @@
+package paymentops
+
+import (
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+func TestFakeAuthorize(t *testing.T) {
+	var tests = []struct {
+		token      string
+		wantStatus string
+		wantErr    string
+	}{
+		{token: "tok_visa", wantStatus: StatusAuthorized, wantErr: ""},
+		{token: FakeTokenDecline, wantStatus: StatusDeclined, wantErr: ErrCardDeclined},
+		{token: FakeToken3DS, wantStatus: StatusRequiresAction, wantErr: ""},
+		{token: FakeTokenNetworkFailure, wantStatus: "", wantErr: ErrProviderUnavailable},
+		{token: "", wantStatus: "", wantErr: ErrInvalidRequest},
+	}
+
+	f := NewFake()
+	for _, test := range tests {
+		p, err := f.Authorize(AuthorizeRequest{Token: test.token, Amount: store.USD(2995), OrderID: "user001-1"})
+		if test.wantErr == "" && err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+		}
+		if p.Status != test.wantStatus {
+			t.Errorf("FAIL: %s; want: %s", p.Status, test.wantStatus)
+		}
+	}
+}
+
+func TestFakeLifecycle(t *testing.T) {
+	f := NewFake()
+	total := store.USD(10000)
+
+	// idempotent authorization
+	p, err := f.Authorize(AuthorizeRequest{Token: "tok_visa", Amount: total, IdempotencyKey: "tx001"})
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	again, _ := f.Authorize(AuthorizeRequest{Token: "tok_visa", Amount: total, IdempotencyKey: "tx001"})
+	if again.ID != p.ID {
+		t.Errorf("FAIL: %s; want: %s", again.ID, p.ID)
+	}
+
+	// capture
+	p, err = f.Capture(p.ID, total)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if p.Status != StatusCaptured || p.PaymentStatus() != store.PaymentStatusSuccess {
+		t.Errorf("FAIL: %s; want: %s", p.Status, StatusCaptured)
+	}
+	if p.Fee != store.USD(320) { // 2.9% + 30c
+		t.Errorf("FAIL: %v; want: %v", p.Fee, store.USD(320))
+	}
+	if _, err := f.Void(p.ID); err == nil || err.Error() != ErrInvalidPaymentState {
+		t.Errorf("FAIL: %v; want: %v", err, ErrInvalidPaymentState)
+	}
+
+	// partial, then full refund
+	r, err := f.Refund(p.ID, store.USD(4000), "re-key-1")
+	if err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	if retried, err := f.Refund(p.ID, store.USD(4000), "re-key-1"); err != nil || retried.ID != r.ID {
+		t.Errorf("FAIL: %v %v; want: %v", err, retried, r) // refunded once
+	}
+	if _, err := f.Refund(p.ID, store.USD(7000), ""); err == nil || err.Error() != ErrInvalidRequest {
+		t.Errorf("FAIL: %v; want: %v", err, ErrInvalidRequest)
+	}
+	if _, err := f.Refund(p.ID, store.USD(6000), ""); err != nil {
+		t.Errorf("FAIL: %v", err)
+	}
+	p, _ = f.Retrieve(p.ID)
+	if p.Status != StatusRefunded || p.Refunded != total {
+		t.Errorf("FAIL: %v; want: %s", p, StatusRefunded)
+	}
+
+	// 3DS then void
+	p, _ = f.Authorize(AuthorizeRequest{Token: FakeToken3DS, Amount: total})
+	if _, err := f.Capture(p.ID, total); err == nil || err.Error() != ErrInvalidPaymentState {
+		t.Errorf("FAIL: %v; want: %v", err, ErrInvalidPaymentState)
+	}
+	if p, err = f.Authenticate(p.ID); err != nil || p.Status != StatusAuthorized {
+		t.Errorf("FAIL: %v %v; want: %s", err, p.Status, StatusAuthorized)
+	}
+	if p, err = f.Void(p.ID); err != nil || p.PaymentStatus() != store.PaymentStatusFail {
+		t.Errorf("FAIL: %v %v; want: %s", err, p.Status, StatusCanceled)
+	}
+
+	if _, err := f.Retrieve("fake_pi_9999"); err == nil || err.Error() != ErrPaymentNotFound {
+		t.Errorf("FAIL: %v; want: %v", err, ErrPaymentNotFound)
+	}
+}
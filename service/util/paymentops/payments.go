@@ -0,0 +1,123 @@
+// Package paymentops defines the Provider interface used by the store to process customer payments,
+// with a Stripe PaymentIntents implementation and a deterministic Fake for offline tests.
+package paymentops
+
+import (
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// Provider names
+const (
+	ProviderStripe = "Stripe"
+	ProviderFake   = "Fake"
+)
+
+// Payment statuses. Provider specific statuses are mapped to these values.
+const (
+	StatusAuthorized     = "AUTHORIZED"      // funds held; awaiting capture
+	StatusRequiresAction = "REQUIRES_ACTION" // customer authentication (3DS) required
+	StatusProcessing     = "PROCESSING"      // payment in progress
+	StatusCaptured       = "CAPTURED"        // funds captured
+	StatusDeclined       = "DECLINED"        // payment method declined
+	StatusCanceled       = "CANCELED"        // authorization voided
+	StatusRefunded       = "REFUNDED"        // captured amount fully refunded
+)
+
+// Error codes returned by Provider methods.
+const (
+	ErrCardDeclined        = "ERR_CARD_DECLINED"
+	ErrProviderUnavailable = "ERR_PROVIDER_UNAVAILABLE"
+	ErrInvalidRequest      = "ERR_INVALID_PAYMENT_REQUEST"
+	ErrPaymentNotFound     = "ERR_PAYMENT_NOT_FOUND"
+	ErrInvalidPaymentState = "ERR_INVALID_PAYMENT_STATE"
+)
+
+// Provider contains the operations used to process payments with a 3rd party payment platform.
+type Provider interface {
+	// Name returns the name of the payment platform (ex: Stripe).
+	Name() string
+	// Authorize places a hold for req.Amount on the payment method identified by req.Token.
+	// A declined payment method returns the declined Payment and an ErrCardDeclined error.
+	Authorize(req AuthorizeRequest) (*Payment, error)
+	// Capture captures amount from an authorized payment.
+	Capture(paymentID string, amount store.Money) (*Payment, error)
+	// Refund refunds amount from a captured payment. Requests sent with the same idempotency
+	// key (ex: store.Return.ReturnID) are refunded once.
+	Refund(paymentID string, amount store.Money, idempotencyKey string) (*Refund, error)
+	// Void cancels an authorized payment that has not been captured.
+	Void(paymentID string) (*Payment, error)
+	// Retrieve returns the current state of the payment.
+	Retrieve(paymentID string) (*Payment, error)
+}
+
+// Payment metadata keys. Metadata is stored with the payment by the provider and returned
+// in webhook events to link the payment to the store's records.
+const (
+	MetaOrderID       = "order_id"
+	MetaCustomerID    = "customer_id"
+	MetaCustomerEmail = "customer_email"
+	MetaTransactionID = "transaction_id"
+	MetaAuthenticated = "authenticated" // "true" on payments that required customer authentication
+)
+
+// AuthorizeRequest contains the info used to authorize a new payment.
+type AuthorizeRequest struct {
+	Token          string      // payment method token provided by the client (ex: Stripe pm_xxx)
+	Amount         store.Money // amount to authorize
+	OrderID        string
+	CustomerID     string
+	CustomerEmail  string
+	TransactionID  string
+	IdempotencyKey string // unique key for safely retrying the request (ex: store.Transaction.TransactionID)
+}
+
+// metadata returns the payment metadata for the request.
+func (r AuthorizeRequest) metadata() map[string]string {
+	return map[string]string{
+		MetaOrderID:       r.OrderID,
+		MetaCustomerID:    r.CustomerID,
+		MetaCustomerEmail: r.CustomerEmail,
+		MetaTransactionID: r.TransactionID,
+	}
+}
+
+// Payment contains the provider's record of a payment.
+type Payment struct {
+	ID           string            `json:"id"`       // provider payment ID (ex: Stripe PaymentIntent ID)
+	Provider     string            `json:"provider"` // provider name
+	Status       string            `json:"status"`
+	Amount       store.Money       `json:"amount"`        // authorized amount
+	Captured     store.Money       `json:"captured"`      // captured amount
+	Refunded     store.Money       `json:"refunded"`      // refunded amount
+	Fee          store.Money       `json:"fee"`           // processing fees charged by the provider
+	ClientSecret string            `json:"client_secret"` // used by the client to complete customer authentication
+	Message      string            `json:"message"`       // decline or error message
+	Metadata     map[string]string `json:"metadata"`
+}
+
+// Refund contains the provider's record of a refund.
+type Refund struct {
+	ID        string      `json:"id"`
+	PaymentID string      `json:"payment_id"`
+	Amount    store.Money `json:"amount"`
+	Status    string      `json:"status"` // store.RefundStatusSuccess or store.RefundStatusFail
+}
+
+// CapturedByWebhook returns true if the payment required customer authentication (3DS), so
+// it is captured by the payment webhook once authorized rather than by the payment API.
+func (p *Payment) CapturedByWebhook() bool {
+	return p.Metadata[MetaAuthenticated] == "true"
+}
+
+// PaymentStatus returns the store.PaymentStatus value for the payment's status.
+func (p *Payment) PaymentStatus() string {
+	switch p.Status {
+	case StatusCaptured:
+		return store.PaymentStatusSuccess
+	case StatusRefunded:
+		return store.RefundStatusSuccess
+	case StatusDeclined, StatusCanceled:
+		return store.PaymentStatusFail
+	}
+	return store.PaymentStatusInProgress
+}
@@ -0,0 +1,351 @@
+package paymentops
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// EnvarStripeSecretKey contains the name of the environment variable holding the Stripe API secret key.
+const EnvarStripeSecretKey = "STRIPE_SECRET_KEY"
+
+// StripeAPIURL contains the base URL of the Stripe API.
+const StripeAPIURL = "https://api.stripe.com"
+
+// Stripe implements Provider with the Stripe PaymentIntents API. Payments are authorized with
+// manual capture so that funds are only captured once the order is confirmed.
+type Stripe struct {
+	SecretKey  string
+	BaseURL    string
+	Client     *http.Client
+	MaxRetries int           // retries for network errors and 5xx responses
+	Backoff    time.Duration // initial retry backoff; doubled on each retry
+}
+
+// NewStripe returns a new *Stripe provider for the given secret key.
+func NewStripe(secretKey string) *Stripe {
+	return &Stripe{
+		SecretKey:  secretKey,
+		BaseURL:    StripeAPIURL,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 2,
+		Backoff:    500 * time.Millisecond,
+	}
+}
+
+// NewStripeFromEnv returns a new *Stripe provider using the secret key set in the
+// STRIPE_SECRET_KEY environment variable.
+func NewStripeFromEnv() *Stripe {
+	return NewStripe(os.Getenv(EnvarStripeSecretKey))
+}
+
+// stripeIntent contains the PaymentIntent fields used by the store.
+type stripeIntent struct {
+	ID               string            `json:"id"`
+	Amount           int64             `json:"amount"`
+	AmountCapturable int64             `json:"amount_capturable"`
+	AmountReceived   int64             `json:"amount_received"`
+	Currency         string            `json:"currency"`
+	Status           string            `json:"status"`
+	ClientSecret     string            `json:"client_secret"`
+	LastPaymentError *stripeErrorBody  `json:"last_payment_error"`
+	LatestCharge     json.RawMessage   `json:"latest_charge"` // charge ID or expanded charge object
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// stripeCharge contains the expanded Charge fields used by the store.
+type stripeCharge struct {
+	ID                 string          `json:"id"`
+	AmountRefunded     int64           `json:"amount_refunded"`
+	BalanceTransaction json.RawMessage `json:"balance_transaction"` // ID or expanded object
+}
+
+type stripeBalanceTransaction struct {
+	Fee      int64  `json:"fee"`
+	Currency string `json:"currency"`
+}
+
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	PaymentIntent string `json:"payment_intent"`
+	Status        string `json:"status"`
+}
+
+type stripeErrorBody struct {
+	Type          string        `json:"type"`
+	Code          string        `json:"code"`
+	DeclineCode   string        `json:"decline_code"`
+	Message       string        `json:"message"`
+	PaymentIntent *stripeIntent `json:"payment_intent"`
+}
+
+type stripeErrorResponse struct {
+	Error stripeErrorBody `json:"error"`
+}
+
+// chargeExpand expands the latest charge and its balance transaction to retrieve processing fees.
+const chargeExpand = "latest_charge.balance_transaction"
+
+func (s *Stripe) Name() string {
+	return ProviderStripe
+}
+
+func (s *Stripe) Authorize(req AuthorizeRequest) (*Payment, error) {
+	if req.Token == "" || req.Amount.Amount <= 0 {
+		return &Payment{}, fmt.Errorf(ErrInvalidRequest)
+	}
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(req.Amount.Amount, 10))
+	form.Set("currency", strings.ToLower(req.Amount.Currency))
+	form.Set("payment_method", req.Token)
+	form.Set("payment_method_types[]", "card")
+	form.Set("capture_method", "manual")
+	form.Set("confirm", "true")
+	for k, v := range req.metadata() {
+		form.Set("metadata["+k+"]", v)
+	}
+	if req.CustomerEmail != "" {
+		form.Set("receipt_email", req.CustomerEmail)
+	}
+
+	pi := &stripeIntent{}
+	err := s.do(http.MethodPost, "/v1/payment_intents", form, req.IdempotencyKey, pi)
+	if err != nil {
+		log.Printf("Authorize failed: %v", err)
+		return declinedPayment(err), err
+	}
+
+	// payments requiring authentication are marked to be captured by the payment webhook
+	if pi.Status == "requires_action" {
+		meta := url.Values{}
+		meta.Set("metadata["+MetaAuthenticated+"]", "true")
+		err = s.do(http.MethodPost, "/v1/payment_intents/"+url.PathEscape(pi.ID), meta, "authenticated-"+pi.ID, pi)
+		if err != nil {
+			log.Printf("Authorize failed: %v", err)
+			return &Payment{}, err
+		}
+	}
+	return pi.payment(), nil
+}
+
+func (s *Stripe) Capture(paymentID string, amount store.Money) (*Payment, error) {
+	form := url.Values{}
+	form.Set("amount_to_capture", strconv.FormatInt(amount.Amount, 10))
+	form.Add("expand[]", chargeExpand)
+
+	pi := &stripeIntent{}
+	err := s.do(http.MethodPost, "/v1/payment_intents/"+url.PathEscape(paymentID)+"/capture", form, "capture-"+paymentID, pi)
+	if err != nil {
+		log.Printf("Capture failed: %v", err)
+		return &Payment{}, err
+	}
+	return pi.payment(), nil
+}
+
+func (s *Stripe) Refund(paymentID string, amount store.Money, idempotencyKey string) (*Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", paymentID)
+	form.Set("amount", strconv.FormatInt(amount.Amount, 10))
+
+	re := &stripeRefund{}
+	err := s.do(http.MethodPost, "/v1/refunds", form, idempotencyKey, re)
+	if err != nil {
+		log.Printf("Refund failed: %v", err)
+		return &Refund{}, err
+	}
+	r := &Refund{
+		ID:        re.ID,
+		PaymentID: re.PaymentIntent,
+		Amount:    store.NewMoney(re.Amount, re.Currency),
+		Status:    store.RefundStatusSuccess,
+	}
+	if re.Status == "failed" || re.Status == "canceled" {
+		r.Status = store.RefundStatusFail
+	}
+	return r, nil
+}
+
+func (s *Stripe) Void(paymentID string) (*Payment, error) {
+	pi := &stripeIntent{}
+	err := s.do(http.MethodPost, "/v1/payment_intents/"+url.PathEscape(paymentID)+"/cancel", url.Values{}, "cancel-"+paymentID, pi)
+	if err != nil {
+		log.Printf("Void failed: %v", err)
+		return &Payment{}, err
+	}
+	return pi.payment(), nil
+}
+
+func (s *Stripe) Retrieve(paymentID string) (*Payment, error) {
+	q := url.Values{}
+	q.Add("expand[]", chargeExpand)
+
+	pi := &stripeIntent{}
+	err := s.do(http.MethodGet, "/v1/payment_intents/"+url.PathEscape(paymentID)+"?"+q.Encode(), nil, "", pi)
+	if err != nil {
+		log.Printf("Retrieve failed: %v", err)
+		return &Payment{}, err
+	}
+	return pi.payment(), nil
+}
+
+// stripeError is returned for error responses from the Stripe API. The decline details
+// are kept so the declined PaymentIntent can be returned to the caller.
+type stripeError struct {
+	code string
+	body stripeErrorBody
+}
+
+func (e *stripeError) Error() string {
+	return e.code
+}
+
+// declinedPayment returns the declined Payment contained in a card error, or an empty Payment.
+func declinedPayment(err error) *Payment {
+	se, ok := err.(*stripeError)
+	if !ok || se.code != ErrCardDeclined {
+		return &Payment{}
+	}
+	p := &Payment{Provider: ProviderStripe, Status: StatusDeclined, Message: se.body.Message}
+	if se.body.PaymentIntent != nil {
+		p = se.body.PaymentIntent.payment()
+		p.Status = StatusDeclined
+		p.Message = se.body.Message
+	}
+	return p
+}
+
+// do sends the request to the Stripe API and decodes the response into v. Network errors and
+// 5xx responses are retried with exponential backoff for GET requests and for POST requests sent
+// with an idempotency key, so retried requests are not processed twice.
+func (s *Stripe) do(method, path string, form url.Values, idempotencyKey string, v interface{}) error {
+	retries := 0
+	backoff := s.Backoff
+	for {
+		status, body, err := s.send(method, path, form, idempotencyKey)
+		if err != nil || status >= 500 {
+			// POST requests without an idempotency key are not safe to retry
+			retryable := method == http.MethodGet || idempotencyKey != ""
+			if !retryable || retries >= s.MaxRetries {
+				log.Printf("stripe request failed: %v (status %d) -- max retries exceeded", err, status)
+				return fmt.Errorf(ErrProviderUnavailable)
+			}
+			log.Printf("stripe request failed: %v (status %d) -- retrying...", err, status)
+			time.Sleep(backoff)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		if status >= 400 {
+			resp := stripeErrorResponse{}
+			json.Unmarshal(body, &resp)
+			code := ErrInvalidRequest
+			switch {
+			case resp.Error.Type == "card_error":
+				code = ErrCardDeclined
+			case status == http.StatusNotFound || resp.Error.Code == "resource_missing":
+				code = ErrPaymentNotFound
+			case resp.Error.Code == "payment_intent_unexpected_state":
+				code = ErrInvalidPaymentState
+			}
+			return &stripeError{code: code, body: resp.Error}
+		}
+		return json.Unmarshal(body, v)
+	}
+}
+
+// send sends a single request to the Stripe API and returns the status code and response body.
+func (s *Stripe) send(method, path string, form url.Values, idempotencyKey string) (int, []byte, error) {
+	var req *http.Request
+	var err error
+	if form != nil {
+		req, err = http.NewRequest(method, s.BaseURL+path, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequest(method, s.BaseURL+path, nil)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	req.SetBasicAuth(s.SecretKey, "")
+	if idempotencyKey != "" && method == http.MethodPost {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// payment converts the PaymentIntent to a Payment.
+func (pi *stripeIntent) payment() *Payment {
+	currency := strings.ToUpper(pi.Currency)
+	p := &Payment{
+		ID:           pi.ID,
+		Provider:     ProviderStripe,
+		Status:       stripeStatus(pi.Status),
+		Amount:       store.NewMoney(pi.Amount, currency),
+		Captured:     store.NewMoney(pi.AmountReceived, currency),
+		ClientSecret: pi.ClientSecret,
+		Metadata:     pi.Metadata,
+	}
+	if p.Status != StatusRequiresAction {
+		p.ClientSecret = ""
+	}
+	if pi.LastPaymentError != nil {
+		p.Message = pi.LastPaymentError.Message
+	}
+
+	// expanded charge & balance transaction
+	charge := stripeCharge{}
+	if len(pi.LatestCharge) > 0 && pi.LatestCharge[0] == '{' {
+		json.Unmarshal(pi.LatestCharge, &charge)
+	}
+	p.Refunded = store.NewMoney(charge.AmountRefunded, currency)
+	if charge.AmountRefunded > 0 && charge.AmountRefunded == pi.AmountReceived {
+		p.Status = StatusRefunded
+	}
+	bt := stripeBalanceTransaction{}
+	if len(charge.BalanceTransaction) > 0 && charge.BalanceTransaction[0] == '{' {
+		json.Unmarshal(charge.BalanceTransaction, &bt)
+		p.Fee = store.NewMoney(bt.Fee, bt.Currency)
+	}
+	return p
+}
+
+// stripeStatus maps PaymentIntent statuses to Payment statuses.
+func stripeStatus(status string) string {
+	switch status {
+	case "requires_capture":
+		return StatusAuthorized
+	case "requires_action":
+		return StatusRequiresAction
+	case "succeeded":
+		return StatusCaptured
+	case "canceled":
+		return StatusCanceled
+	case "requires_payment_method":
+		return StatusDeclined
+	}
+	return StatusProcessing // requires_confirmation, processing
+}
@@ -0,0 +1,156 @@
+package paymentops
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// newTestStripe returns a *Stripe provider for a test server that responds to each request
+// with the next status and body in responses.
+func newTestStripe(t *testing.T, responses []struct {
+	status int
+	body   string
+}) (*Stripe, *[]*http.Request) {
+	reqs := []*http.Request{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		reqs = append(reqs, r)
+		if len(reqs) > len(responses) {
+			t.Fatalf("FAIL: unexpected request %s %s", r.Method, r.URL)
+		}
+		resp := responses[len(reqs)-1]
+		w.WriteHeader(resp.status)
+		w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(srv.Close)
+
+	s := NewStripe("sk_test_123")
+	s.BaseURL = srv.URL
+	s.Backoff = 0
+	return s, &reqs
+}
+
+func TestStripeAuthorize(t *testing.T) {
+	var tests = []struct {
+		name      string
+		responses []struct {
+			status int
+			body   string
+		}
+		wantStatus string
+		wantErr    string
+		wantReqs   int
+	}{
+		{
+			name: "authorized",
+			responses: []struct {
+				status int
+				body   string
+			}{{200, `{"id":"pi_1","amount":2995,"currency":"usd","status":"requires_capture"}`}},
+			wantStatus: StatusAuthorized, wantReqs: 1,
+		},
+		{
+			name: "3ds required",
+			responses: []struct {
+				status int
+				body   string
+			}{{200, `{"id":"pi_2","amount":2995,"currency":"usd","status":"requires_action","client_secret":"pi_2_secret"}`},
+				{200, `{"id":"pi_2","amount":2995,"currency":"usd","status":"requires_action","client_secret":"pi_2_secret","metadata":{"authenticated":"true"}}`}},
+			wantStatus: StatusRequiresAction, wantReqs: 2, // marked to be captured by the webhook
+		},
+		{
+			name: "declined",
+			responses: []struct {
+				status int
+				body   string
+			}{{402, `{"error":{"type":"card_error","code":"card_declined","decline_code":"insufficient_funds","message":"Your card has insufficient funds.","payment_intent":{"id":"pi_3","amount":2995,"currency":"usd","status":"requires_payment_method"}}}`}},
+			wantStatus: StatusDeclined, wantErr: ErrCardDeclined, wantReqs: 1,
+		},
+		{
+			name: "retry 5xx",
+			responses: []struct {
+				status int
+				body   string
+			}{{500, `{}`}, {200, `{"id":"pi_4","amount":2995,"currency":"usd","status":"requires_capture"}`}},
+			wantStatus: StatusAuthorized, wantReqs: 2,
+		},
+		{
+			name: "unavailable",
+			responses: []struct {
+				status int
+				body   string
+			}{{503, `{}`}, {503, `{}`}, {503, `{}`}},
+			wantStatus: "", wantErr: ErrProviderUnavailable, wantReqs: 3,
+		},
+	}
+
+	for _, test := range tests {
+		s, reqs := newTestStripe(t, test.responses)
+		p, err := s.Authorize(AuthorizeRequest{Token: "pm_card_visa", Amount: store.USD(2995), OrderID: "user001-1", IdempotencyKey: "tx001"})
+		if test.wantErr == "" && err != nil {
+			t.Errorf("FAIL - %s: %v; want: nil", test.name, err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("FAIL - %s: %v; want: %v", test.name, err, test.wantErr)
+		}
+		if p.Status != test.wantStatus {
+			t.Errorf("FAIL - %s: %s; want: %s", test.name, p.Status, test.wantStatus)
+		}
+		if p.CapturedByWebhook() != (test.wantStatus == StatusRequiresAction) {
+			t.Errorf("FAIL - %s: captured by webhook: %v", test.name, p.CapturedByWebhook())
+		}
+		if len(*reqs) != test.wantReqs {
+			t.Errorf("FAIL - %s: %d requests; want: %d", test.name, len(*reqs), test.wantReqs)
+		}
+		req := (*reqs)[0]
+		if req.PostForm.Get("amount") != "2995" || req.PostForm.Get("capture_method") != "manual" || req.Header.Get("Idempotency-Key") != "tx001" {
+			t.Errorf("FAIL - %s: request %v", test.name, req.PostForm)
+		}
+	}
+}
+
+func TestStripeCapture(t *testing.T) {
+	s, reqs := newTestStripe(t, []struct {
+		status int
+		body   string
+	}{{200, `{"id":"pi_1","amount":2995,"amount_received":2995,"currency":"usd","status":"succeeded",
+		"latest_charge":{"id":"ch_1","amount_refunded":0,"balance_transaction":{"fee":117,"currency":"usd"}}}`}})
+
+	p, err := s.Capture("pi_1", store.USD(2995))
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if p.Status != StatusCaptured || p.Captured != store.USD(2995) || p.Fee != store.USD(117) {
+		t.Errorf("FAIL: %v", p)
+	}
+	if (*reqs)[0].URL.Path != "/v1/payment_intents/pi_1/capture" {
+		t.Errorf("FAIL: %s", (*reqs)[0].URL.Path)
+	}
+}
+
+func TestStripeRefund(t *testing.T) {
+	s, reqs := newTestStripe(t, []struct {
+		status int
+		body   string
+	}{
+		{200, `{"id":"re_1","amount":1000,"currency":"usd","payment_intent":"pi_1","status":"succeeded"}`},
+		{500, `{}`}, // not retried without an idempotency key
+	})
+
+	r, err := s.Refund("pi_1", store.USD(1000), "user001-1-r")
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	if r.Status != store.RefundStatusSuccess || r.Amount != store.USD(1000) {
+		t.Errorf("FAIL: %v", r)
+	}
+	if key := (*reqs)[0].Header.Get("Idempotency-Key"); key != "user001-1-r" {
+		t.Errorf("FAIL: %s; want: %s", key, "user001-1-r")
+	}
+	if _, err := s.Refund("pi_1", store.USD(1000), ""); err == nil || err.Error() != ErrProviderUnavailable {
+		t.Errorf("FAIL: %v; want: %v", err, ErrProviderUnavailable)
+	}
+}
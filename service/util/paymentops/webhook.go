@@ -0,0 +1,244 @@
+package paymentops
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// EnvarStripeWebhookSecret contains the name of the environment variable holding the
+// signing secret of the Stripe webhook endpoint.
+const EnvarStripeWebhookSecret = "STRIPE_WEBHOOK_SECRET"
+
+// StripeSignatureHeader contains the name of the header containing the Stripe webhook signature.
+const StripeSignatureHeader = "Stripe-Signature"
+
+// DefaultSignatureTolerance is the maximum age of a webhook signature timestamp.
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// Webhook error codes
+const (
+	ErrInvalidSignature = "ERR_INVALID_SIGNATURE"
+	ErrSignatureExpired = "ERR_SIGNATURE_EXPIRED"
+	ErrInvalidEvent     = "ERR_INVALID_EVENT"
+)
+
+// Stripe event types used by the store.
+const (
+	EventPaymentSucceeded  = "payment_intent.succeeded"
+	EventPaymentFailed     = "payment_intent.payment_failed"
+	EventPaymentCanceled   = "payment_intent.canceled"
+	EventPaymentCapturable = "payment_intent.amount_capturable_updated" // authorized after customer authentication
+	EventDisputeCreated    = "charge.dispute.created"
+	EventChargeRefunded    = "charge.refunded"
+	EventRefundUpdated     = "charge.refund.updated"
+	EventRefundFailed      = "refund.failed"
+)
+
+// stripeEventStatus maps Stripe event types to store.PaymentStatus values.
+// EventRefundUpdated is mapped by the refund's status. Refund and dispute statuses are
+// recorded separately from the sale's payment status (see store.PaymentStatusField).
+var stripeEventStatus = map[string]string{
+	EventPaymentSucceeded: store.PaymentStatusSuccess,
+	EventPaymentFailed:    store.PaymentStatusFail,
+	EventPaymentCanceled:  store.PaymentStatusFail,
+	EventDisputeCreated:   store.PaymentDisputed,
+	EventChargeRefunded:   store.RefundStatusSuccess,
+	EventRefundFailed:     store.RefundStatusFail,
+}
+
+// StripeEvent represents a Stripe webhook event.
+type StripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// stripeEventObject contains the fields used by the store from the event's object, which is
+// a PaymentIntent, Charge, Dispute or Refund depending on the event type.
+type stripeEventObject struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	PaymentIntent    string            `json:"payment_intent"`
+	Amount           int64             `json:"amount"`
+	Currency         string            `json:"currency"`
+	Status           string            `json:"status"`
+	Reason           string            `json:"reason"`         // dispute reason
+	FailureReason    string            `json:"failure_reason"` // refund failure reason
+	Metadata         map[string]string `json:"metadata"`
+	LastPaymentError *stripeErrorBody  `json:"last_payment_error"`
+}
+
+// StripeWebhookSecret returns the webhook signing secret set in the STRIPE_WEBHOOK_SECRET environment variable.
+func StripeWebhookSecret() string { return os.Getenv(EnvarStripeWebhookSecret) }
+
+// VerifyStripeSignature verifies the Stripe-Signature header value for the raw request payload.
+// The header contains a timestamp (t) and one or more HMAC-SHA256 signatures (v1) of 't.payload'.
+// Signatures with timestamps older than tolerance are rejected to prevent replays.
+func VerifyStripeSignature(payload []byte, header, secret string, tolerance time.Duration) error {
+	var ts int64
+	sigs := [][]byte{}
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			t, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf(ErrInvalidSignature)
+			}
+			ts = t
+		case "v1":
+			sig, err := hex.DecodeString(kv[1])
+			if err != nil {
+				continue
+			}
+			sigs = append(sigs, sig)
+		}
+	}
+	if ts == 0 || len(sigs) == 0 {
+		return fmt.Errorf(ErrInvalidSignature)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	valid := false
+	for _, sig := range sigs {
+		if hmac.Equal(sig, expected) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf(ErrInvalidSignature)
+	}
+	if tolerance > 0 && time.Since(time.Unix(ts, 0)) > tolerance {
+		return fmt.Errorf(ErrSignatureExpired)
+	}
+	return nil
+}
+
+// StripeSignature returns a Stripe-Signature header value for the payload. It is used to test
+// webhook receivers.
+func StripeSignature(payload []byte, secret string, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(payload)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// ParseStripeEvent decodes a Stripe webhook event from the request payload.
+func ParseStripeEvent(payload []byte) (*StripeEvent, error) {
+	e := &StripeEvent{}
+	if err := json.Unmarshal(payload, e); err != nil {
+		log.Printf("ParseStripeEvent failed: %v", err)
+		return &StripeEvent{}, fmt.Errorf(ErrInvalidEvent)
+	}
+	if e.ID == "" || e.Type == "" || len(e.Data.Object) == 0 {
+		return &StripeEvent{}, fmt.Errorf(ErrInvalidEvent)
+	}
+	return e, nil
+}
+
+// PaymentID returns the ID of the PaymentIntent the event refers to.
+func (e *StripeEvent) PaymentID() (string, error) {
+	obj := stripeEventObject{}
+	if err := json.Unmarshal(e.Data.Object, &obj); err != nil {
+		return "", fmt.Errorf(ErrInvalidEvent)
+	}
+	if obj.Object == "payment_intent" {
+		return obj.ID, nil
+	}
+	return obj.PaymentIntent, nil
+}
+
+// PaymentStatus maps the event to a store.PaymentStatus. ok is false for event types that
+// do not change a payment's status. Events on Charges, Disputes and Refunds do not contain
+// the PaymentIntent's metadata, which is retrieved from the provider, as are the processing
+// fees of captured payments.
+func (e *StripeEvent) PaymentStatus(p Provider) (status store.PaymentStatus, ok bool, err error) {
+	obj := stripeEventObject{}
+	if err := json.Unmarshal(e.Data.Object, &obj); err != nil {
+		log.Printf("PaymentStatus failed: %v", err)
+		return store.PaymentStatus{}, false, fmt.Errorf(ErrInvalidEvent)
+	}
+
+	txStatus, ok := stripeEventStatus[e.Type]
+	if e.Type == EventRefundUpdated && obj.Status == "failed" {
+		txStatus, ok = store.RefundStatusFail, true
+	}
+	if !ok {
+		return store.PaymentStatus{}, false, nil
+	}
+
+	// set message
+	msg := ""
+	switch {
+	case obj.LastPaymentError != nil:
+		msg = obj.LastPaymentError.Message
+	case obj.Reason != "":
+		msg = obj.Reason
+	case obj.FailureReason != "":
+		msg = obj.FailureReason
+	}
+
+	paymentID := obj.PaymentIntent
+	meta := obj.Metadata
+	if obj.Object == "payment_intent" {
+		paymentID = obj.ID
+	} else if paymentID != "" {
+		payment, err := p.Retrieve(paymentID)
+		if err != nil {
+			log.Printf("PaymentStatus failed: %v", err)
+			return store.PaymentStatus{}, false, err
+		}
+		meta = payment.Metadata
+	}
+	if meta[MetaOrderID] == "" {
+		log.Printf("PaymentStatus failed: event %s has no order metadata", e.ID)
+		return store.PaymentStatus{}, false, fmt.Errorf(ErrInvalidEvent)
+	}
+
+	// processing fees are recorded once the payment is captured
+	fee := store.Money{}
+	if txStatus == store.PaymentStatusSuccess {
+		payment, err := p.Retrieve(paymentID)
+		if err != nil {
+			log.Printf("PaymentStatus failed: %v", err)
+			return store.PaymentStatus{}, false, err
+		}
+		fee = payment.Fee
+	}
+
+	status = store.PaymentStatus{
+		CustomerEmail:  meta[MetaCustomerEmail],
+		CustomerID:     meta[MetaCustomerID],
+		OrderID:        meta[MetaOrderID],
+		TransactionID:  meta[MetaTransactionID],
+		PaymentMethod:  p.Name(),
+		PaymentTxID:    paymentID,
+		TxStatus:       txStatus,
+		TxMessage:      msg,
+		ChargesAndFees: fee,
+	}
+	return status, true, nil
+}
@@ -0,0 +1,168 @@
+package paymentops
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+func TestVerifyStripeSignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_001","type":"payment_intent.succeeded"}`)
+	secret := "whsec_test"
+	now := time.Now()
+
+	var tests = []struct {
+		header  string
+		wantErr string
+	}{
+		{header: StripeSignature(payload, secret, now), wantErr: ""},
+		{header: StripeSignature(payload, "whsec_other", now), wantErr: ErrInvalidSignature},
+		{header: StripeSignature(payload, secret, now.Add(-10*time.Minute)), wantErr: ErrSignatureExpired},
+		{header: StripeSignature(payload, "whsec_other", now) + ",v1=" + signature(payload, secret, now), wantErr: ""}, // rolled secret
+		{header: "t=abc,v1=00", wantErr: ErrInvalidSignature},
+		{header: "", wantErr: ErrInvalidSignature},
+	}
+	for _, test := range tests {
+		err := VerifyStripeSignature(payload, test.header, secret, DefaultSignatureTolerance)
+		if test.wantErr == "" && err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+			t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+		}
+	}
+
+	// modified payload
+	header := StripeSignature(payload, secret, now)
+	if err := VerifyStripeSignature([]byte(`{"id":"evt_002"}`), header, secret, DefaultSignatureTolerance); err == nil {
+		t.Errorf("FAIL: nil; want: %v", ErrInvalidSignature)
+	}
+}
+
+func TestStripeEventPaymentStatus(t *testing.T) {
+	f := NewFake()
+	p, err := f.Authorize(AuthorizeRequest{
+		Token:         "tok_visa",
+		Amount:        store.USD(2995),
+		OrderID:       "user001-1",
+		CustomerID:    "cust001",
+		CustomerEmail: "user001@test.com",
+		TransactionID: "tx001",
+	})
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	p, err = f.Capture(p.ID, p.Amount)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+
+	pi := fmt.Sprintf(`{"id":"%s","object":"payment_intent","metadata":{"order_id":"user001-1","customer_id":"cust001","customer_email":"user001@test.com","transaction_id":"tx001"}`, p.ID)
+	var tests = []struct {
+		event    string
+		wantOk   bool
+		wantTx   string
+		wantMsg  string
+		wantErr  string
+		wantTxID string
+		wantFee  store.Money
+	}{
+		{
+			event:  `{"id":"evt_001","type":"payment_intent.succeeded","data":{"object":` + pi + `}}}`,
+			wantOk: true, wantTx: store.PaymentStatusSuccess, wantTxID: p.ID, wantFee: p.Fee,
+		},
+		{
+			event:  `{"id":"evt_002","type":"payment_intent.payment_failed","data":{"object":` + pi + `,"last_payment_error":{"message":"Your card was declined."}}}}`,
+			wantOk: true, wantTx: store.PaymentStatusFail, wantMsg: "Your card was declined.", wantTxID: p.ID,
+		},
+		{
+			event:  fmt.Sprintf(`{"id":"evt_003","type":"charge.dispute.created","data":{"object":{"id":"dp_001","object":"dispute","payment_intent":"%s","reason":"fraudulent"}}}`, p.ID),
+			wantOk: true, wantTx: store.PaymentDisputed, wantMsg: "fraudulent", wantTxID: p.ID,
+		},
+		{
+			event:  fmt.Sprintf(`{"id":"evt_004","type":"charge.refunded","data":{"object":{"id":"ch_001","object":"charge","payment_intent":"%s"}}}`, p.ID),
+			wantOk: true, wantTx: store.RefundStatusSuccess, wantTxID: p.ID,
+		},
+		{
+			event:  fmt.Sprintf(`{"id":"evt_005","type":"charge.refund.updated","data":{"object":{"id":"re_001","object":"refund","payment_intent":"%s","status":"failed","failure_reason":"expired_or_canceled_card"}}}`, p.ID),
+			wantOk: true, wantTx: store.RefundStatusFail, wantMsg: "expired_or_canceled_card", wantTxID: p.ID,
+		},
+		{
+			event:  fmt.Sprintf(`{"id":"evt_006","type":"charge.refund.updated","data":{"object":{"id":"re_001","object":"refund","payment_intent":"%s","status":"succeeded"}}}`, p.ID),
+			wantOk: false,
+		},
+		{
+			event:  `{"id":"evt_007","type":"customer.created","data":{"object":{"id":"cus_001","object":"customer"}}}`,
+			wantOk: false,
+		},
+		{
+			event:   `{"id":"evt_008","type":"charge.refunded","data":{"object":{"id":"ch_002","object":"charge","payment_intent":"pi_unknown"}}}`,
+			wantErr: ErrPaymentNotFound,
+		},
+		{
+			event:   `{"id":"evt_009","type":"payment_intent.succeeded","data":{"object":{"id":"pi_002","object":"payment_intent"}}}`,
+			wantErr: ErrInvalidEvent, // missing metadata
+		},
+	}
+	for _, test := range tests {
+		e, err := ParseStripeEvent([]byte(test.event))
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+			continue
+		}
+		status, ok, err := e.PaymentStatus(f)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+		}
+		if ok != test.wantOk {
+			t.Errorf("FAIL - %s: %v; want: %v", e.ID, ok, test.wantOk)
+		}
+		if !ok {
+			continue
+		}
+		want := store.PaymentStatus{
+			CustomerEmail:  "user001@test.com",
+			CustomerID:     "cust001",
+			OrderID:        "user001-1",
+			TransactionID:  "tx001",
+			PaymentMethod:  ProviderFake,
+			PaymentTxID:    test.wantTxID,
+			TxStatus:       test.wantTx,
+			TxMessage:      test.wantMsg,
+			ChargesAndFees: test.wantFee,
+		}
+		if status != want {
+			t.Errorf("FAIL: %v; want: %v", status, want)
+		}
+	}
+}
+
+func TestParseStripeEvent(t *testing.T) {
+	var tests = []string{
+		`not json`,
+		`{"id":"evt_001","data":{"object":{}}}`,
+		`{"type":"payment_intent.succeeded","data":{"object":{}}}`,
+		`{"id":"evt_001","type":"payment_intent.succeeded"}`,
+	}
+	for _, test := range tests {
+		_, err := ParseStripeEvent([]byte(test))
+		if err == nil || err.Error() != ErrInvalidEvent {
+			t.Errorf("FAIL: %v; want: %v", err, ErrInvalidEvent)
+		}
+	}
+}
+
+// signature returns the v1 signature of the payload.
+func signature(payload []byte, secret string, t time.Time) string {
+	header := StripeSignature(payload, secret, t)
+	return header[strings.Index(header, "v1=")+len("v1="):]
+}
@@ -17,6 +17,10 @@ import (
 // objects awaiting processing pending receipt of a StripeTxStatus message.
 const StagingFifoQueue = "staging-queue.fifo"
 
+// StagingDeadLetterFifoQueue contains the queue name of the order staging dead-letter queue.
+// Staging messages that can not be staged are sent to this queue for manual review.
+const StagingDeadLetterFifoQueue = "staging-dlq.fifo"
+
 // PaymentStatusFifoQueue contains the name of the Payment Status queue.
 // Messages sent to this queue are used to confirm the successful completion of
 // payments before processing the objects sent to the Staging queue.
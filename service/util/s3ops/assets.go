@@ -0,0 +1,82 @@
+package s3ops
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// EnvarDigitalAssetsBucket contains the name of the environment variable holding the name of
+// the S3 bucket containing the preview and master files of digital items.
+const EnvarDigitalAssetsBucket = "DIGITAL_ASSETS_BUCKET"
+
+// DigitalAssetsBucket returns the name of the S3 bucket containing the preview and master
+// files of digital items and signed contracts. The bucket is private; objects are downloaded
+// with signed URLs.
+func DigitalAssetsBucket() string { return os.Getenv(EnvarDigitalAssetsBucket) }
+
+// Signer contains the operations used to create time-limited download URLs for S3 objects.
+type Signer interface {
+	// SignGetURL returns a URL that downloads the object until it expires.
+	SignGetURL(bucket, key string, expires time.Duration) (string, error)
+}
+
+// Uploader contains the operations used to store generated documents in S3.
+type Uploader interface {
+	// PutObject puts the object, replacing the existing object with the same key.
+	PutObject(bucket, key, contentType string, body []byte) error
+}
+
+// ContractKey returns the S3 key of the user's signed contract.
+func ContractKey(userID, contractID string) string {
+	return fmt.Sprintf("contracts/%s/%s.html", userID, contractID)
+}
+
+// S3Assets implements Signer and Uploader with the S3 API.
+type S3Assets struct {
+	Svc *s3.S3
+}
+
+// NewS3Assets returns a new *S3Assets using the default AWS session.
+func NewS3Assets() *S3Assets {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	return &S3Assets{Svc: s3.New(sess)}
+}
+
+// SignGetURL returns a presigned GetObject URL for the object, valid until it expires.
+func (s *S3Assets) SignGetURL(bucket, key string, expires time.Duration) (string, error) {
+	req, _ := s.Svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	url, err := req.Presign(expires)
+	if err != nil {
+		log.Printf("SignGetURL failed: %v", err)
+		return "", err
+	}
+	return url, nil
+}
+
+// PutObject puts the object with server side encryption.
+func (s *S3Assets) PutObject(bucket, key, contentType string, body []byte) error {
+	_, err := s.Svc.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(body),
+		ContentType:          aws.String(contentType),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	})
+	if err != nil {
+		log.Printf("PutObject failed: %v", err)
+		return err
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package s3ops
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-aws/go-s3/gos3"
@@ -118,3 +120,134 @@ func GetShippingNotificationHtmlTemplate(svc interface{}) (string, error) {
 		return string(obj), nil
 	}
 }
+
+// GetReturnNotificationHtmlTemplate retrieves the return notification email html template from
+// the SystemAssetsBucket in S3 and returns it as a string.
+func GetReturnNotificationHtmlTemplate(svc interface{}) (string, error) {
+	// generate receipt and email info
+	key := "html/email-return-notification-tmpl.html" // test only
+
+	// poll for messages with exponential backoff for errors & empty responses
+	retries := 0
+	maxRetries := 4
+	backoff := 1000.0
+	for {
+		// receive messages from queue
+		obj, err := gos3.GetObject(svc, SystemAssetsBucket, key)
+		if err != nil {
+			if err.Error() == gos3.ErrNoSuchKey {
+				log.Printf("GetReturnNotificationHtmlTemplate failed: %v", err)
+				return "", err
+			}
+			// retry with backoff if error
+			if retries > maxRetries {
+				log.Printf("GetReturnNotificationHtmlTemplate failed: %v -- max retries exceeded", err)
+				return "", err
+			}
+			log.Printf("GetReturnNotificationHtmlTemplate failed: %v -- retrying...", err)
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		return string(obj), nil
+	}
+}
+
+// GetTrackingNotificationHtmlTemplate retrieves the tracking notification email html template
+// from the SystemAssetsBucket in S3 and returns it as a string.
+func GetTrackingNotificationHtmlTemplate(svc interface{}) (string, error) {
+	key := "html/email-tracking-notification-tmpl.html"
+
+	// get object with exponential backoff for errors
+	retries := 0
+	maxRetries := 4
+	backoff := 1000.0
+	for {
+		obj, err := gos3.GetObject(svc, SystemAssetsBucket, key)
+		if err != nil {
+			if err.Error() == gos3.ErrNoSuchKey {
+				log.Printf("GetTrackingNotificationHtmlTemplate failed: %v", err)
+				return "", err
+			}
+			// retry with backoff if error
+			if retries > maxRetries {
+				log.Printf("GetTrackingNotificationHtmlTemplate failed: %v -- max retries exceeded", err)
+				return "", err
+			}
+			log.Printf("GetTrackingNotificationHtmlTemplate failed: %v -- retrying...", err)
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		return string(obj), nil
+	}
+}
+
+// GetCartReminderHtmlTemplate retrieves the abandoned cart reminder email html template from
+// the SystemAssetsBucket in S3 and returns it as a string.
+func GetCartReminderHtmlTemplate(svc interface{}) (string, error) {
+	key := "html/email-cart-reminder-tmpl.html"
+
+	// get object with exponential backoff for errors
+	retries := 0
+	maxRetries := 4
+	backoff := 1000.0
+	for {
+		obj, err := gos3.GetObject(svc, SystemAssetsBucket, key)
+		if err != nil {
+			if err.Error() == gos3.ErrNoSuchKey {
+				log.Printf("GetCartReminderHtmlTemplate failed: %v", err)
+				return "", err
+			}
+			// retry with backoff if error
+			if retries > maxRetries {
+				log.Printf("GetCartReminderHtmlTemplate failed: %v -- max retries exceeded", err)
+				return "", err
+			}
+			log.Printf("GetCartReminderHtmlTemplate failed: %v -- retrying...", err)
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		return string(obj), nil
+	}
+}
+
+// GetContractHtmlTemplate retrieves the lease agreement html template of the license tier
+// (ex: 'html/contract-basic-tmpl.html') from the SystemAssetsBucket in S3 and returns it
+// as a string.
+func GetContractHtmlTemplate(svc interface{}, tier string) (string, error) {
+	key := fmt.Sprintf("html/contract-%s-tmpl.html", strings.ToLower(tier))
+
+	// get object with exponential backoff for errors
+	retries := 0
+	maxRetries := 4
+	backoff := 1000.0
+	for {
+		obj, err := gos3.GetObject(svc, SystemAssetsBucket, key)
+		if err != nil {
+			if err.Error() == gos3.ErrNoSuchKey {
+				log.Printf("GetContractHtmlTemplate failed: %v", err)
+				return "", err
+			}
+			// retry with backoff if error
+			if retries > maxRetries {
+				log.Printf("GetContractHtmlTemplate failed: %v -- max retries exceeded", err)
+				return "", err
+			}
+			log.Printf("GetContractHtmlTemplate failed: %v -- retrying...", err)
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		return string(obj), nil
+	}
+}
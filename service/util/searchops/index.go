@@ -0,0 +1,398 @@
+// Package searchops implements an embedded full-text search index over the store catalog.
+// The index is built from StoreItem objects, persisted as a snapshot of its documents, and
+// rebuilt in memory by the functions that search it.
+package searchops
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// Field boosts applied to term frequencies
+const (
+	BoostName        = 3.0
+	BoostSubcategory = 2.0
+	BoostCategory    = 1.5
+	BoostDescription = 1.0
+)
+
+// Match weights applied to exact, prefix and typo tolerant term matches
+const (
+	WeightExact  = 1.0
+	WeightPrefix = 0.6
+	WeightTypo   = 0.4
+)
+
+// DefaultLimit is the number of results returned when no limit is requested.
+const DefaultLimit = 20
+
+// Price buckets
+const (
+	PriceUnder25   = "under_25"
+	Price25To50    = "25_to_50"
+	Price50To100   = "50_to_100"
+	Price100AndUp  = "100_and_up"
+	priceBucket25  = 2500  // cents
+	priceBucket50  = 5000  // cents
+	priceBucket100 = 10000 // cents
+)
+
+// Document contains the indexed fields of a StoreItem and the fields returned in search results.
+type Document struct {
+	ItemID       string      `json:"item_id"`
+	Name         string      `json:"name"`
+	Description  string      `json:"description"`
+	Category     string      `json:"category"`
+	Subcategory  string      `json:"sub_category"`
+	Price        store.Money `json:"price"`
+	Sizes        []string    `json:"sizes"` // sizes with units available
+	ThumbnailUrl string      `json:"thumbnail_url"`
+}
+
+// NewDocument returns the search Document for the given StoreItem.
+func NewDocument(item *store.StoreItem) *Document {
+	doc := &Document{
+		ItemID:      item.ItemID,
+		Name:        item.Name,
+		Description: item.Description,
+		Category:    item.Category,
+		Subcategory: item.Subcategory,
+		Price:       item.Price,
+		Sizes:       []string{},
+	}
+	for size, units := range item.UnitsAvailable {
+		if units > 0 {
+			doc.Sizes = append(doc.Sizes, size)
+		}
+	}
+	sort.Strings(doc.Sizes)
+	if len(item.ImageUrls) > 0 {
+		doc.ThumbnailUrl = item.ImageUrls[0]
+	}
+	return doc
+}
+
+// Query represents a search request. Empty filter fields are not applied.
+type Query struct {
+	Text        string      `json:"q"`
+	Category    string      `json:"category"`
+	Subcategory string      `json:"sub_category"`
+	Size        string      `json:"size"`
+	PriceBucket string      `json:"price_bucket"`
+	MinPrice    store.Money `json:"min_price"`
+	MaxPrice    store.Money `json:"max_price"`
+	Limit       int         `json:"limit"`
+}
+
+// Hit represents a matching Document and its relevance score.
+type Hit struct {
+	Document
+	Score float64 `json:"score"`
+}
+
+// Facets contains the number of matching documents for each category, available size and
+// price bucket.
+type Facets struct {
+	Categories   map[string]int `json:"categories"`
+	Sizes        map[string]int `json:"sizes"`
+	PriceBuckets map[string]int `json:"price_buckets"`
+}
+
+// Results contains the ranked hits of a search, the total number of matches and the facet
+// counts of all matches.
+type Results struct {
+	Hits   []Hit  `json:"hits"`
+	Total  int    `json:"total"`
+	Facets Facets `json:"facets"`
+}
+
+// Index is an in-memory inverted index of catalog Documents. An Index is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*Document          // item ID: document
+	postings map[string]map[string]float64 // term: item ID: boosted term frequency
+	terms    []string                      // sorted terms; nil if stale
+}
+
+// NewIndex returns a new empty Index.
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*Document),
+		postings: make(map[string]map[string]float64),
+	}
+}
+
+// Build returns a new Index containing the given StoreItems. Digital items whose exclusive
+// license was sold are not indexed.
+func Build(items []*store.StoreItem) *Index {
+	idx := NewIndex()
+	for _, item := range items {
+		if item.SoldExclusive {
+			continue
+		}
+		idx.Add(NewDocument(item))
+	}
+	return idx
+}
+
+// Len returns the number of documents in the index.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Add adds the document to the index, replacing the existing document with the same item ID.
+func (idx *Index) Add(doc *Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.ItemID)
+	idx.docs[doc.ItemID] = doc
+	fields := []struct {
+		text  string
+		boost float64
+	}{
+		{text: doc.Name, boost: BoostName},
+		{text: strings.ReplaceAll(doc.Subcategory, "_", " "), boost: BoostSubcategory},
+		{text: strings.ReplaceAll(doc.Category, "_", " "), boost: BoostCategory},
+		{text: doc.Description, boost: BoostDescription},
+	}
+	for _, f := range fields {
+		for _, term := range Terms(f.text) {
+			if idx.postings[term] == nil {
+				idx.postings[term] = make(map[string]float64)
+				idx.terms = nil
+			}
+			idx.postings[term][doc.ItemID] += f.boost
+		}
+	}
+}
+
+// Remove removes the document with the given item ID from the index.
+func (idx *Index) Remove(itemID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(itemID)
+}
+
+func (idx *Index) remove(itemID string) {
+	if _, ok := idx.docs[itemID]; !ok {
+		return
+	}
+	delete(idx.docs, itemID)
+	for term, docs := range idx.postings {
+		if _, ok := docs[itemID]; !ok {
+			continue
+		}
+		delete(docs, itemID)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+			idx.terms = nil
+		}
+	}
+}
+
+// Search returns the documents matching every term of the query text and the query's filters,
+// ranked by relevance. Each query term matches indexed terms exactly, by prefix, or within the
+// number of typos tolerated for the term's length. An empty query text matches all documents.
+func (idx *Index) Search(q Query) Results {
+	sorted := idx.sortedTerms()
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[string]float64
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		scores = make(map[string]float64)
+		for id := range idx.docs {
+			scores[id] = 0
+		}
+	}
+	for _, term := range terms {
+		matches := idx.match(term, sorted)
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for id := range scores {
+			if s, ok := matches[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	res := Results{
+		Hits: []Hit{},
+		Facets: Facets{
+			Categories:   make(map[string]int),
+			Sizes:        make(map[string]int),
+			PriceBuckets: make(map[string]int),
+		},
+	}
+	for id, score := range scores {
+		doc := idx.docs[id]
+		if !q.accepts(doc) {
+			continue
+		}
+		res.Hits = append(res.Hits, Hit{Document: *doc, Score: score})
+		res.Facets.Categories[doc.Category]++
+		res.Facets.PriceBuckets[PriceBucket(doc.Price)]++
+		for _, size := range doc.Sizes {
+			res.Facets.Sizes[size]++
+		}
+	}
+	res.Total = len(res.Hits)
+
+	sort.Slice(res.Hits, func(i, j int) bool {
+		if res.Hits[i].Score != res.Hits[j].Score {
+			return res.Hits[i].Score > res.Hits[j].Score
+		}
+		return res.Hits[i].ItemID < res.Hits[j].ItemID
+	})
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(res.Hits) > limit {
+		res.Hits = res.Hits[:limit]
+	}
+	return res
+}
+
+// sortedTerms returns the sorted indexed terms, sorting the terms if they are stale.
+func (idx *Index) sortedTerms() []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.terms == nil {
+		idx.terms = make([]string, 0, len(idx.postings))
+		for term := range idx.postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+	}
+	return idx.terms
+}
+
+// match returns the score of each document matching the query term. Each indexed term is
+// weighted by its best match type and inverse document frequency. The caller must hold the
+// read lock.
+func (idx *Index) match(term string, sorted []string) map[string]float64 {
+	weights := make(map[string]float64) // indexed term: match weight
+	if _, ok := idx.postings[term]; ok {
+		weights[term] = WeightExact
+	}
+	i := sort.SearchStrings(sorted, term)
+	for ; i < len(sorted) && strings.HasPrefix(sorted[i], term); i++ {
+		if weights[sorted[i]] == 0 {
+			weights[sorted[i]] = WeightPrefix
+		}
+	}
+	if typos := maxTypos(term); typos > 0 {
+		for _, t := range sorted {
+			if weights[t] == 0 && Distance(term, t, typos) <= typos {
+				weights[t] = WeightTypo
+			}
+		}
+	}
+
+	scores := make(map[string]float64)
+	n := float64(len(idx.docs))
+	for t, w := range weights {
+		docs := idx.postings[t]
+		if len(docs) == 0 {
+			continue // removed since terms were sorted
+		}
+		idf := math.Log(1 + n/float64(len(docs)))
+		for id, tf := range docs {
+			s := w * idf * tf
+			if s > scores[id] {
+				scores[id] = s
+			}
+		}
+	}
+	return scores
+}
+
+// accepts returns true if the document passes the query's filters.
+func (q *Query) accepts(doc *Document) bool {
+	if q.Category != "" && q.Category != doc.Category {
+		return false
+	}
+	if q.Subcategory != "" && q.Subcategory != doc.Subcategory {
+		return false
+	}
+	if q.PriceBucket != "" && q.PriceBucket != PriceBucket(doc.Price) {
+		return false
+	}
+	if q.MinPrice.Amount > 0 && doc.Price.Amount < q.MinPrice.Amount {
+		return false
+	}
+	if q.MaxPrice.Amount > 0 && doc.Price.Amount > q.MaxPrice.Amount {
+		return false
+	}
+	if q.Size != "" {
+		for _, size := range doc.Sizes {
+			if size == q.Size {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// PriceBucket returns the price bucket facet of the given price.
+func PriceBucket(price store.Money) string {
+	switch {
+	case price.Amount < priceBucket25:
+		return PriceUnder25
+	case price.Amount < priceBucket50:
+		return Price25To50
+	case price.Amount < priceBucket100:
+		return Price50To100
+	}
+	return Price100AndUp
+}
+
+// WriteTo writes the index's documents to w as a JSON array.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	idx.mu.RLock()
+	docs := make([]*Document, 0, len(idx.docs))
+	for _, doc := range idx.docs {
+		docs = append(docs, doc)
+	}
+	idx.mu.RUnlock()
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ItemID < docs[j].ItemID })
+
+	js, err := json.Marshal(docs)
+	if err != nil {
+		log.Printf("WriteTo failed: %v", err)
+		return 0, err
+	}
+	n, err := w.Write(js)
+	return int64(n), err
+}
+
+// ReadIndex returns a new Index containing the documents written by Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	docs := []*Document{}
+	if err := json.NewDecoder(r).Decode(&docs); err != nil {
+		log.Printf("ReadIndex failed: %v", err)
+		return NewIndex(), err
+	}
+	idx := NewIndex()
+	for _, doc := range docs {
+		idx.Add(doc)
+	}
+	return idx, nil
+}
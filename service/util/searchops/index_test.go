@@ -0,0 +1,176 @@
+package searchops
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// testCatalog returns the StoreItem fixtures indexed by newTestIndex.
+func testCatalog() []*store.StoreItem {
+	return []*store.StoreItem{
+		{ItemID: "001", Name: "PawnWars Game Set", Description: "Complete chess set with board", Category: "games", Subcategory: "game_sets", Price: store.USD(2995), UnitsAvailable: map[string]int{"OS": 10}},
+		{ItemID: "002", Name: "PawnWars Chess Pieces", Description: "Replacement pieces", Category: "games", Subcategory: "game_sets", Price: store.USD(1495), UnitsAvailable: map[string]int{"OS": 0}},
+		{ItemID: "003", Name: "PawnWars King Poster", Description: "18x24 printed poster", Category: "artwork", Subcategory: "posters", Price: store.USD(1795), UnitsAvailable: map[string]int{"OS": 20}},
+		{ItemID: "005", Name: "ACamoPrjct Logo T-Shirt", Description: "Heavyweight cotton tee", Category: "clothing", Subcategory: "shirts", Price: store.USD(2995), UnitsAvailable: map[string]int{"S": 10, "M": 0, "L": 6}},
+		{ItemID: "009", Name: "ACamoPrjct Logo Hoodie", Description: "Fleece hoodie with printed logo", Category: "clothing", Subcategory: "hoodies", Price: store.USD(5500), UnitsAvailable: map[string]int{"M": 2, "L": 1}},
+	}
+}
+
+func newTestIndex() *Index {
+	return Build(testCatalog())
+}
+
+func hitIDs(res Results) []string {
+	ids := []string{}
+	for _, h := range res.Hits {
+		ids = append(ids, h.ItemID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	var tests = []struct {
+		query Query
+		want  []string // ranked item IDs
+	}{
+		{query: Query{Text: "hoodies"}, want: []string{"009"}},         // stemmed
+		{query: Query{Text: "hood"}, want: []string{"009"}},            // prefix
+		{query: Query{Text: "hoody"}, want: []string{"009"}},           // typo
+		{query: Query{Text: "shrit"}, want: []string{"005"}},           // transposition
+		{query: Query{Text: "pawnwars poster"}, want: []string{"003"}}, // all terms required
+		{query: Query{Text: "chess"}, want: []string{"002", "001"}},    // name ranked above description
+		{query: Query{Text: "logo"}, want: []string{"009", "005"}},     // name & description
+		{query: Query{Text: "printed"}, want: []string{"003", "009"}},  // description
+		{query: Query{Text: "clothing"}, want: []string{"005", "009"}}, // category
+		{query: Query{Text: "logo", Size: "M"}, want: []string{"009"}}, // size available
+		{query: Query{Text: "pawnwars", Subcategory: "game_sets"}, want: []string{"001", "002"}},
+		{query: Query{Text: "pawnwars", MaxPrice: store.USD(1500)}, want: []string{"002"}},
+		{query: Query{Text: "", Category: "games", Limit: 1}, want: []string{"001"}}, // all documents
+		{query: Query{Text: "xylophone"}, want: []string{}},
+	}
+	idx := newTestIndex()
+	for _, test := range tests {
+		got := hitIDs(idx.Search(test.query))
+		if len(got) != len(test.want) {
+			t.Errorf("FAIL - %q: %v; want: %v", test.query.Text, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("FAIL - %q: %v; want: %v", test.query.Text, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSearchFacets(t *testing.T) {
+	res := newTestIndex().Search(Query{Text: "acamoprjct pawnwars", Limit: 1})
+	if res.Total != 0 {
+		t.Errorf("FAIL - total: %d; want: 0", res.Total)
+	}
+
+	res = newTestIndex().Search(Query{Limit: 1})
+	if res.Total != 5 || len(res.Hits) != 1 {
+		t.Errorf("FAIL - total: %d, hits: %d; want: 5, 1", res.Total, len(res.Hits))
+	}
+	var tests = []struct {
+		facet map[string]int
+		key   string
+		want  int
+	}{
+		{facet: res.Facets.Categories, key: "games", want: 2},
+		{facet: res.Facets.Categories, key: "clothing", want: 2},
+		{facet: res.Facets.Categories, key: "artwork", want: 1},
+		{facet: res.Facets.Sizes, key: "OS", want: 2}, // 002 sold out
+		{facet: res.Facets.Sizes, key: "M", want: 1},
+		{facet: res.Facets.Sizes, key: "L", want: 2},
+		{facet: res.Facets.PriceBuckets, key: PriceUnder25, want: 2},
+		{facet: res.Facets.PriceBuckets, key: Price25To50, want: 2},
+		{facet: res.Facets.PriceBuckets, key: Price50To100, want: 1},
+		{facet: res.Facets.PriceBuckets, key: Price100AndUp, want: 0},
+	}
+	for _, test := range tests {
+		if test.facet[test.key] != test.want {
+			t.Errorf("FAIL - %s: %d; want: %d", test.key, test.facet[test.key], test.want)
+		}
+	}
+}
+
+func TestIndexAddRemove(t *testing.T) {
+	idx := newTestIndex()
+
+	// replace document
+	item := testCatalog()[4]
+	item.Name = "ACamoPrjct Logo Crewneck"
+	idx.Add(NewDocument(item))
+	if got := hitIDs(idx.Search(Query{Text: "hoodie"})); len(got) != 1 {
+		t.Errorf("FAIL - description match: %v", got) // description still matches
+	}
+	if got := hitIDs(idx.Search(Query{Text: "crewneck"})); len(got) != 1 || got[0] != "009" {
+		t.Errorf("FAIL - renamed: %v", got)
+	}
+
+	idx.Remove("009")
+	idx.Remove("404")
+	if idx.Len() != 4 {
+		t.Errorf("FAIL - len: %d; want: 4", idx.Len())
+	}
+	if got := hitIDs(idx.Search(Query{Text: "crewneck"})); len(got) != 0 {
+		t.Errorf("FAIL - removed: %v", got)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	s := NewMemSnapshots()
+	idx, err := s.Load()
+	if err != nil || idx.Len() != 0 {
+		t.Fatalf("FAIL - empty snapshot: %v, %d", err, idx.Len())
+	}
+	if err := s.Save(newTestIndex()); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	err = Update(s, func(idx *Index) { idx.Remove("001") })
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+
+	idx, _ = s.Load()
+	if idx.Len() != 4 {
+		t.Errorf("FAIL - len: %d; want: 4", idx.Len())
+	}
+	if got := hitIDs(idx.Search(Query{Text: "chess"})); len(got) != 1 || got[0] != "002" {
+		t.Errorf("FAIL: %v; want: [002]", got)
+	}
+
+	if _, err := ReadIndex(bytes.NewBufferString("{")); err == nil {
+		t.Errorf("FAIL - invalid snapshot: nil error")
+	}
+
+	cache := NewCache(s, 0)
+	first, _ := cache.Index()
+	Update(s, func(idx *Index) { idx.Remove("002") })
+	second, _ := cache.Index()
+	if first.Len() != 4 || second.Len() != 3 {
+		t.Errorf("FAIL - cache reload: %d, %d; want: 4, 3", first.Len(), second.Len())
+	}
+
+	// updates are retried with the latest snapshot if it is saved concurrently
+	calls := 0
+	err = Update(s, func(idx *Index) {
+		calls++
+		if calls == 1 {
+			Update(s, func(idx *Index) { idx.Remove("005") })
+		}
+		idx.Remove("009")
+	})
+	idx, _ = s.Load()
+	if err != nil || calls != 2 || idx.Len() != 1 {
+		t.Errorf("FAIL - concurrent update: %v, %d calls, len %d; want: nil, 2, 1", err, calls, idx.Len())
+	}
+	if err := s.SaveIf(idx, ""); err == nil || err.Error() != ErrSnapshotChanged {
+		t.Errorf("FAIL: %v; want: %s", err, ErrSnapshotChanged)
+	}
+}
@@ -0,0 +1,262 @@
+package searchops
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// EnvarSearchIndexBucket contains the name of the environment variable holding the S3 bucket
+// name of the search index snapshot.
+const EnvarSearchIndexBucket = "SEARCH_INDEX_BUCKET"
+
+// SnapshotKey contains the S3 object key of the search index snapshot.
+const SnapshotKey = "search/catalog-index.json"
+
+// ErrSnapshotChanged is returned by Snapshots.SaveIf when the snapshot was saved since it was
+// loaded.
+const ErrSnapshotChanged = "ERR_SNAPSHOT_CHANGED"
+
+// maxUpdateRetries is the number of times Update retries when the snapshot is saved by a
+// concurrent update.
+const maxUpdateRetries = 5
+
+// Snapshots contains the operations used to persist the search index.
+type Snapshots interface {
+	// Load returns the saved Index. An empty Index is returned if no snapshot has been saved.
+	Load() (*Index, error)
+	// Save saves the Index, replacing the existing snapshot.
+	Save(idx *Index) error
+	// LoadVersion returns the saved Index and the version of the snapshot (ex: S3 ETag). The
+	// version is empty if no snapshot has been saved.
+	LoadVersion() (*Index, string, error)
+	// SaveIf saves the Index if the snapshot's version is unchanged, and returns
+	// ErrSnapshotChanged otherwise.
+	SaveIf(idx *Index, version string) error
+}
+
+// S3Snapshots implements Snapshots with an S3 object.
+type S3Snapshots struct {
+	Svc    s3iface.S3API
+	Bucket string
+	Key    string
+}
+
+// NewS3Snapshots returns a new *S3Snapshots for the given bucket.
+func NewS3Snapshots(bucket string) *S3Snapshots {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	return &S3Snapshots{Svc: s3.New(sess), Bucket: bucket, Key: SnapshotKey}
+}
+
+// NewS3SnapshotsFromEnv returns a new *S3Snapshots for the bucket set in the
+// SEARCH_INDEX_BUCKET environment variable.
+func NewS3SnapshotsFromEnv() *S3Snapshots {
+	return NewS3Snapshots(os.Getenv(EnvarSearchIndexBucket))
+}
+
+// Load retrieves and reads the snapshot object.
+func (s *S3Snapshots) Load() (*Index, error) {
+	idx, _, err := s.LoadVersion()
+	return idx, err
+}
+
+// LoadVersion retrieves and reads the snapshot object. The object's ETag is returned as the
+// snapshot's version.
+func (s *S3Snapshots) LoadVersion() (*Index, string, error) {
+	obj, err := s.Svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return NewIndex(), "", nil
+		}
+		log.Printf("Load failed: %v", err)
+		return NewIndex(), "", err
+	}
+	defer obj.Body.Close()
+	idx, err := ReadIndex(obj.Body)
+	return idx, aws.StringValue(obj.ETag), err
+}
+
+// Save writes the index to the snapshot object.
+func (s *S3Snapshots) Save(idx *Index) error {
+	input, err := s.putInput(idx)
+	if err != nil {
+		log.Printf("Save failed: %v", err)
+		return err
+	}
+	_, err = s.Svc.PutObject(input)
+	if err != nil {
+		log.Printf("Save failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SaveIf writes the index to the snapshot object on the condition that the object's ETag
+// matches version, or that the object does not exist if version is empty.
+func (s *S3Snapshots) SaveIf(idx *Index, version string) error {
+	input, err := s.putInput(idx)
+	if err != nil {
+		log.Printf("SaveIf failed: %v", err)
+		return err
+	}
+	if version == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(version)
+	}
+	_, err = s.Svc.PutObject(input)
+	if err != nil {
+		// 409 ConditionalRequestConflict is returned for concurrent conditional writes
+		if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "PreconditionFailed" || aerr.Code() == "ConditionalRequestConflict") {
+			return fmt.Errorf(ErrSnapshotChanged)
+		}
+		log.Printf("SaveIf failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// putInput returns the PutObject input writing the index to the snapshot object.
+func (s *S3Snapshots) putInput(idx *Index) (*s3.PutObjectInput, error) {
+	buf := &bytes.Buffer{}
+	if _, err := idx.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	return &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.Key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/json"),
+	}, nil
+}
+
+// MemSnapshots implements Snapshots in memory for tests and local development.
+type MemSnapshots struct {
+	mu      sync.Mutex
+	data    []byte
+	version int // incremented on each save
+}
+
+// NewMemSnapshots returns a new empty *MemSnapshots.
+func NewMemSnapshots() *MemSnapshots {
+	return &MemSnapshots{}
+}
+
+func (m *MemSnapshots) Load() (*Index, error) {
+	idx, _, err := m.LoadVersion()
+	return idx, err
+}
+
+func (m *MemSnapshots) LoadVersion() (*Index, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return NewIndex(), "", nil
+	}
+	idx, err := ReadIndex(bytes.NewReader(m.data))
+	return idx, strconv.Itoa(m.version), err
+}
+
+func (m *MemSnapshots) Save(idx *Index) error {
+	buf := &bytes.Buffer{}
+	if _, err := idx.WriteTo(buf); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = buf.Bytes()
+	m.version++
+	return nil
+}
+
+func (m *MemSnapshots) SaveIf(idx *Index, version string) error {
+	buf := &bytes.Buffer{}
+	if _, err := idx.WriteTo(buf); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := ""
+	if m.data != nil {
+		current = strconv.Itoa(m.version)
+	}
+	if version != current {
+		return fmt.Errorf(ErrSnapshotChanged)
+	}
+	m.data = buf.Bytes()
+	m.version++
+	return nil
+}
+
+// Update loads the saved index, applies fn and saves the result on the condition that the
+// snapshot was not saved since it was loaded. Updates are retried with the latest snapshot if
+// the snapshot is saved by a concurrent update; fn may be applied more than once.
+func Update(s Snapshots, fn func(idx *Index)) error {
+	for retries := 0; ; retries++ {
+		idx, version, err := s.LoadVersion()
+		if err != nil {
+			log.Printf("Update failed: %v", err)
+			return err
+		}
+		fn(idx)
+		err = s.SaveIf(idx, version)
+		if err == nil {
+			return nil
+		}
+		if err.Error() != ErrSnapshotChanged || retries >= maxUpdateRetries {
+			log.Printf("Update failed: %v", err)
+			return err
+		}
+	}
+}
+
+// Cache holds the index loaded from Snapshots and reloads it once it is older than MaxAge, so
+// that warm function instances see catalog updates.
+type Cache struct {
+	Snapshots Snapshots
+	MaxAge    time.Duration
+
+	mu       sync.Mutex
+	idx      *Index
+	loadedAt time.Time
+}
+
+// NewCache returns a new *Cache for the given snapshots.
+func NewCache(s Snapshots, maxAge time.Duration) *Cache {
+	return &Cache{Snapshots: s, MaxAge: maxAge}
+}
+
+// Index returns the cached Index, loading the snapshot if the index is missing or expired.
+// The previously loaded index is returned if the snapshot cannot be loaded.
+func (c *Cache) Index() (*Index, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.idx != nil && time.Since(c.loadedAt) < c.MaxAge {
+		return c.idx, nil
+	}
+	idx, err := c.Snapshots.Load()
+	if err != nil {
+		log.Printf("Index failed: %v", err)
+		if c.idx != nil {
+			return c.idx, nil
+		}
+		return NewIndex(), err
+	}
+	c.idx, c.loadedAt = idx, time.Now()
+	return idx, nil
+}
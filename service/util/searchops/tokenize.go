@@ -0,0 +1,170 @@
+package searchops
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are common words that are not indexed.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "with": true,
+}
+
+// Tokenize splits the text into lowercase alphanumeric tokens and removes stop words.
+// Apostrophes are removed so that possessives match (ex: "king's" -> "kings").
+func Tokenize(text string) []string {
+	text = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(text))
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := []string{}
+	for _, f := range fields {
+		if stopWords[f] {
+			continue
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+// Stem returns the stem of the lowercase token using a reduced form of the Porter stemmer:
+// plurals, -ed and -ing suffixes, and trailing 'y' and 'e' characters are normalized so that
+// inflections of a word share a stem (ex: "hoodies", "hoodie" -> "hoodi").
+func Stem(token string) string {
+	if len(token) <= 3 || !isAlpha(token) {
+		return token
+	}
+	s := token
+
+	// plurals
+	switch {
+	case strings.HasSuffix(s, "sses"):
+		s = s[:len(s)-2]
+	case strings.HasSuffix(s, "ies"):
+		s = s[:len(s)-2]
+	case strings.HasSuffix(s, "ss"), strings.HasSuffix(s, "us"):
+	case strings.HasSuffix(s, "s"):
+		s = s[:len(s)-1]
+	}
+
+	// -eed, -ed, -ing
+	switch {
+	case strings.HasSuffix(s, "eed"):
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "ed") && hasVowel(s[:len(s)-2]) && len(s) > 4:
+		s = trimDouble(s[:len(s)-2])
+	case strings.HasSuffix(s, "ing") && hasVowel(s[:len(s)-3]) && len(s) > 5:
+		s = trimDouble(s[:len(s)-3])
+	}
+
+	// trailing y & e
+	if strings.HasSuffix(s, "y") && hasVowel(s[:len(s)-1]) {
+		s = s[:len(s)-1] + "i"
+	}
+	if strings.HasSuffix(s, "e") && len(s) > 4 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Terms returns the stemmed tokens of the text.
+func Terms(text string) []string {
+	terms := []string{}
+	for _, t := range Tokenize(text) {
+		terms = append(terms, Stem(t))
+	}
+	return terms
+}
+
+// Distance returns the optimal string alignment distance between a and b (the number of
+// insertions, deletions, substitutions and adjacent transpositions). Distances greater than max
+// are returned as max+1.
+func Distance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && prev2[j-2]+1 < cur[j] {
+				cur[j] = prev2[j-2] + 1
+			}
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	if prev[len(rb)] > max {
+		return max + 1
+	}
+	return prev[len(rb)]
+}
+
+// maxTypos returns the number of typos tolerated for a query term of the given length.
+func maxTypos(term string) int {
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func hasVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
+
+// trimDouble removes the last character of stems ending in a double consonant (ex: "shipp" -> "ship").
+func trimDouble(s string) string {
+	n := len(s)
+	if n >= 2 && s[n-1] == s[n-2] && !strings.ContainsRune("aeioulsz", rune(s[n-1])) {
+		return s[:n-1]
+	}
+	return s
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
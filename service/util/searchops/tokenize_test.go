@@ -0,0 +1,78 @@
+package searchops
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	var tests = []struct {
+		text string
+		want []string
+	}{
+		{text: "PawnWars King's Poster", want: []string{"pawnwars", "kings", "poster"}},
+		{text: "ACamoPrjct Logo T-Shirt (Black)", want: []string{"acamoprjct", "logo", "t", "shirt", "black"}},
+		{text: "A set of the pieces", want: []string{"set", "pieces"}},
+		{text: "  ", want: []string{}},
+	}
+	for _, test := range tests {
+		got := Tokenize(test.text)
+		if len(got) != len(test.want) {
+			t.Errorf("FAIL: %v; want: %v", got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("FAIL: %v; want: %v", got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	var tests = []struct {
+		tokens []string
+		want   string
+	}{
+		{tokens: []string{"hoodie", "hoodies"}, want: "hoodi"},
+		{tokens: []string{"shirt", "shirts"}, want: "shirt"},
+		{tokens: []string{"poster", "posters"}, want: "poster"},
+		{tokens: []string{"print", "printed", "printing", "prints"}, want: "print"},
+		{tokens: []string{"ship", "shipping", "shipped"}, want: "ship"},
+		{tokens: []string{"game", "games"}, want: "game"},
+		{tokens: []string{"king", "kings"}, want: "king"},
+		{tokens: []string{"dress", "dresses"}, want: "dress"},
+		{tokens: []string{"jersey", "jerseys"}, want: "jersei"},
+		{tokens: []string{"tee", "tees"}, want: "tee"},
+		{tokens: []string{"2xl"}, want: "2xl"},
+	}
+	for _, test := range tests {
+		for _, token := range test.tokens {
+			if got := Stem(token); got != test.want {
+				t.Errorf("FAIL - %s: %s; want: %s", token, got, test.want)
+			}
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{a: "hoodi", b: "hoodi", max: 1, want: 0},
+		{a: "hodi", b: "hoodi", max: 1, want: 1},  // insertion
+		{a: "shrit", b: "shirt", max: 1, want: 1}, // transposition
+		{a: "postre", b: "poster", max: 1, want: 1},
+		{a: "pawnwar", b: "pawnwars", max: 2, want: 1},
+		{a: "chass", b: "chess", max: 1, want: 1}, // substitution
+		{a: "hat", b: "shirt", max: 1, want: 2},   // exceeds max
+		{a: "poster", b: "pstr", max: 2, want: 2},
+	}
+	for _, test := range tests {
+		if got := Distance(test.a, test.b, test.max); got != test.want {
+			t.Errorf("FAIL - %s, %s: %d; want: %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package sesops
 import (
 	"fmt"
 	"log"
+	"net/url"
+	"os"
 	"time"
 
 	"github.com/go-aws/go-ses/goses"
@@ -13,6 +15,13 @@ import (
 
 // const FulfillmentTopicARN = os.Getenv("fulfillmentTopicArn")
 
+// EnvarStoreAPIURL contains the environment variable of the store API's base URL
+// (ex: https://api.store.com), used to link signed contracts in receipts.
+const EnvarStoreAPIURL = "STORE_API_URL"
+
+// contractRoute is the store API route that downloads a signed contract.
+const contractRoute = "/contracts/get"
+
 // InitSesh encapsulates the gosns.InitSesh() method and returns the SNS service
 // as an interface{} type.
 func InitSesh() interface{} {
@@ -26,7 +35,7 @@ func SendCustomerReceipt(svc interface{}, from string, order *store.Order) error
 	// generate receipt and email info
 	// receipt := order.NewReceipt()
 	subject := fmt.Sprintf("Thank you from ACamoPrjct! (Order #%s)", order.OrderID)
-	text := fmt.Sprintf("Order #%s received! Price: %0.2f", order.OrderID, order.OrderTotal)
+	text := fmt.Sprintf("Order #%s received! Price: %s", order.OrderID, order.OrderTotal)
 	tmpl, err := s3ops.GetReceiptHtmlTemplate(s3ops.InitSesh())
 	if err != nil {
 		log.Printf("SendCustomerReceipt failed: %v", err)
@@ -45,6 +54,8 @@ func SendCustomerReceipt(svc interface{}, from string, order *store.Order) error
 		Subtotal:   order.SalesSubtotal,
 		Shipping:   order.ShippingCost,
 		SalesTax:   order.SalesTax,
+		TaxRegion:  order.TaxBreakdown.Jurisdiction,
+		TaxRate:    order.TaxBreakdown.Rate,
 		OrderTotal: order.OrderTotal,
 		FirstName:  order.ShippingAddress.FirstName,
 		LastName:   order.ShippingAddress.LastName,
@@ -55,6 +66,7 @@ func SendCustomerReceipt(svc interface{}, from string, order *store.Order) error
 		Zip:        order.ShippingAddress.Zip,
 		Phone:      order.ShippingAddress.PhoneNumber,
 		Items:      items,
+		Contracts:  contractLinks(order),
 	}
 	html, err := htmlops.CreateHtmlTemplate(tmpl, htmlInput)
 	if err != nil {
@@ -91,7 +103,7 @@ func SendCustomerReceipt(svc interface{}, from string, order *store.Order) error
 func SendOrderNotification(svc interface{}, from, notifyEmail string, order *store.Order) error {
 	// generate receipt and email info
 	subject := fmt.Sprintf("New Order! (#%s)", order.OrderID)
-	text := fmt.Sprintf("Order #%s received! Price: %0.2f", order.OrderID, order.OrderTotal)
+	text := fmt.Sprintf("Order #%s received! Price: %s", order.OrderID, order.OrderTotal)
 	tmpl, err := s3ops.GetOrderNotificationHtmlTemplate(s3ops.InitSesh())
 	if err != nil {
 		log.Printf("SendCustomerReceipt failed: %v", err)
@@ -111,6 +123,8 @@ func SendOrderNotification(svc interface{}, from, notifyEmail string, order *sto
 		Subtotal:   order.SalesSubtotal,
 		Shipping:   order.ShippingCost,
 		SalesTax:   order.SalesTax,
+		TaxRegion:  order.TaxBreakdown.Jurisdiction,
+		TaxRate:    order.TaxBreakdown.Rate,
 		OrderTotal: order.OrderTotal,
 		FirstName:  order.ShippingAddress.FirstName,
 		LastName:   order.ShippingAddress.LastName,
@@ -209,3 +223,219 @@ func SendShippingNotification(svc interface{}, from, to string, shipment *store.
 		return nil
 	}
 }
+
+// returnSubjects contains the subject line of return notification emails for each return status.
+var returnSubjects = map[string]string{
+	store.ReturnStatusRequested:     "Return Request Received (Order #%s)",
+	store.ReturnStatusApproved:      "Return Approved - Your Return Label (Order #%s)",
+	store.ReturnStatusRejected:      "Return Request Update (Order #%s)",
+	store.ReturnStatusItemsReceived: "Returned Items Received (Order #%s)",
+	store.ReturnStatusRefunded:      "Refund Issued (Order #%s)",
+	store.ReturnStatusComplete:      "Return Complete (Order #%s)",
+}
+
+// SendReturnNotification sends an email to the customer for the return's current status.
+// The return label is included for approved returns and the refund amount for refunded returns.
+// 'from' specifies the SES verified sender email (ex: orders@store.com)
+func SendReturnNotification(svc interface{}, from string, ret *store.Return, order *store.Order) error {
+	subject := fmt.Sprintf(returnSubjects[ret.ReturnStatus], ret.OrderID)
+	text := fmt.Sprintf("Return #%s for order #%s: %s", ret.ReturnID, ret.OrderID, ret.ReturnStatus)
+	tmpl, err := s3ops.GetReturnNotificationHtmlTemplate(s3ops.InitSesh())
+	if err != nil {
+		log.Printf("SendReturnNotification failed: %v", err)
+		return err
+	}
+	items := []htmlops.ItemSummary{}
+	for _, item := range ret.Items(order) {
+		is := htmlops.ItemSummary{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+		}
+		items = append(items, is)
+	}
+	htmlInput := htmlops.ReturnNotificationTemplateData{
+		ReturnID:       ret.ReturnID,
+		OrderID:        ret.OrderID,
+		ReturnStatus:   ret.ReturnStatus,
+		Message:        ret.Message,
+		RefundAmount:   ret.RefundAmount,
+		Carrier:        ret.ReturnLabel.Carrier,
+		TrackingNumber: ret.ReturnLabel.TrackingNumber,
+		TrackingUrl:    ret.ReturnLabel.TrackingUrlProvider,
+		LabelUrl:       ret.ReturnLabel.LabelUrl,
+		FirstName:      order.ShippingAddress.FirstName,
+		LastName:       order.ShippingAddress.LastName,
+		Items:          items,
+	}
+	html, err := htmlops.CreateHtmlTemplate(tmpl, htmlInput)
+	if err != nil {
+		log.Printf("SendReturnNotification failed: %v", err)
+		return err
+	}
+
+	// send email with exponential backoff for errors
+	retries := 0
+	maxRetries := 4
+	backoff := 1000.0
+	for {
+		err := goses.SendEmail(svc, []string{ret.UserEmail}, []string{}, from, subject, text, html)
+		if err != nil {
+			// retry with backoff if error
+			if retries > maxRetries {
+				log.Printf("SendReturnNotification failed: %v -- max retries exceeded", err)
+				return err
+			}
+			log.Printf("SendReturnNotification failed: %v -- retrying...", err)
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		return nil
+	}
+}
+
+// trackingSubjects contains the subject line of tracking notification emails for each notice.
+var trackingSubjects = map[string]string{
+	store.TrackingNoticeOutForDelivery: "Order #%s Is Out for Delivery!",
+	store.TrackingNoticeDelivered:      "Order #%s Delivered!",
+	store.TrackingNoticeException:      "Delivery Update for Order #%s",
+}
+
+// SendTrackingNotification sends an email to the shipment's recipient for the tracking event's
+// notice (see store.TrackingEvent.Notice). 'from' specifies the SES verified sender email
+// (ex: orders@store.com)
+func SendTrackingNotification(svc interface{}, from string, shipment *store.Shipment, event store.TrackingEvent) error {
+	notice := event.Notice()
+	subject := fmt.Sprintf(trackingSubjects[notice], shipment.OrderID)
+	text := fmt.Sprintf("Order #%s tracking update: %s", shipment.OrderID, event.StatusDetails)
+	tmpl, err := s3ops.GetTrackingNotificationHtmlTemplate(s3ops.InitSesh())
+	if err != nil {
+		log.Printf("SendTrackingNotification failed: %v", err)
+		return err
+	}
+
+	htmlInput := htmlops.TrackingNotificationTemplateData{
+		OrderID:        shipment.OrderID,
+		Notice:         notice,
+		Status:         event.Status,
+		StatusDetails:  event.StatusDetails,
+		StatusDate:     event.StatusDate,
+		Carrier:        event.Carrier,
+		TrackingNumber: event.TrackingNumber,
+		FirstName:      shipment.AddressTo.FirstName,
+		LastName:       shipment.AddressTo.LastName,
+	}
+	for _, l := range shipment.Labels {
+		if l.TrackingNumber == event.TrackingNumber {
+			htmlInput.TrackingUrl = l.TrackingUrlProvider
+		}
+	}
+	html, err := htmlops.CreateHtmlTemplate(tmpl, htmlInput)
+	if err != nil {
+		log.Printf("SendTrackingNotification failed: %v", err)
+		return err
+	}
+
+	// send email with exponential backoff for errors
+	retries := 0
+	maxRetries := 4
+	backoff := 1000.0
+	for {
+		err := goses.SendEmail(svc, []string{shipment.AddressTo.Email}, []string{}, from, subject, text, html)
+		if err != nil {
+			// retry with backoff if error
+			if retries > maxRetries {
+				log.Printf("SendTrackingNotification failed: %v -- max retries exceeded", err)
+				return err
+			}
+			log.Printf("SendTrackingNotification failed: %v -- retrying...", err)
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		return nil
+	}
+}
+
+// SendCartReminder sends an abandoned cart reminder to the cart's UserEmail listing the cart's
+// in-stock items. StoreItems are keyed by item ID; the first image of each item is used as its
+// thumbnail. A one-time discount is offered if promo is not nil.
+// 'from' specifies the SES verified sender email (ex: orders@store.com)
+func SendCartReminder(svc interface{}, from string, cart *store.ShoppingCart, items map[string]*store.StoreItem, promo *store.Promotion) error {
+	subject := "You left something in your cart"
+	text := "Items in your cart are still available."
+	tmpl, err := s3ops.GetCartReminderHtmlTemplate(s3ops.InitSesh())
+	if err != nil {
+		log.Printf("SendCartReminder failed: %v", err)
+		return err
+	}
+	htmlInput := htmlops.CartReminderTemplateData{Subtotal: cart.Subtotal}
+	for _, line := range cart.InStockItems(items) {
+		item := htmlops.CartReminderItem{
+			Name:     line.Name,
+			Size:     line.Size,
+			Quantity: line.Quantity,
+			Price:    line.Price,
+		}
+		if urls := items[line.ItemID].ImageUrls; len(urls) > 0 {
+			item.ThumbnailUrl = urls[0]
+		}
+		htmlInput.Items = append(htmlInput.Items, item)
+	}
+	if promo != nil {
+		htmlInput.DiscountCode = promo.Code
+		htmlInput.Discount = promo.Description
+		htmlInput.DiscountEnds = promo.EndDate
+		text = fmt.Sprintf("%s Use code %s for %s.", text, promo.Code, promo.Description)
+	}
+	html, err := htmlops.CreateHtmlTemplate(tmpl, htmlInput)
+	if err != nil {
+		log.Printf("SendCartReminder failed: %v", err)
+		return err
+	}
+
+	// send email with exponential backoff for errors
+	retries := 0
+	maxRetries := 4
+	backoff := 1000.0
+	for {
+		err := goses.SendEmail(svc, []string{cart.UserEmail}, []string{}, from, subject, text, html)
+		if err != nil {
+			// retry with backoff if error
+			if retries > maxRetries {
+				log.Printf("SendCartReminder failed: %v -- max retries exceeded", err)
+				return err
+			}
+			log.Printf("SendCartReminder failed: %v -- retrying...", err)
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
+			backoff = backoff * 2
+			retries++
+			continue
+		}
+
+		return nil
+	}
+}
+
+// contractLinks returns links to the signed contracts of the order's digital items.
+func contractLinks(order *store.Order) []htmlops.ContractLink {
+	names := make(map[string]string)
+	for _, item := range order.Items {
+		names[item.SizeID] = fmt.Sprintf("%s (%s)", item.Name, item.Size)
+	}
+	links := []htmlops.ContractLink{}
+	for _, ref := range order.Contracts {
+		q := url.Values{}
+		q.Set("user_id", order.UserID)
+		q.Set("contract_id", ref.ContractID)
+		links = append(links, htmlops.ContractLink{
+			Name: names[ref.SizeID],
+			Url:  os.Getenv(EnvarStoreAPIURL) + contractRoute + "?" + q.Encode(),
+		})
+	}
+	return links
+}
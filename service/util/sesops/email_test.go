@@ -11,10 +11,10 @@ func TestSendCustomerReceipt(t *testing.T) {
 		&store.Order{
 			OrderID:       "t0001",
 			UserEmail:     "danielgarcia95367@gmail.com",
-			SalesSubtotal: 19.99,
-			SalesTax:      0.80,
-			ShippingCost:  5.99,
-			OrderTotal:    26.78,
+			SalesSubtotal: store.USD(1999),
+			SalesTax:      store.USD(80),
+			ShippingCost:  store.USD(599),
+			OrderTotal:    store.USD(2678),
 			ShippingAddress: store.Address{
 				FirstName:    "Daniel",
 				LastName:     "Garcia",
@@ -33,10 +33,10 @@ func TestSendCustomerReceipt(t *testing.T) {
 		&store.Order{
 			OrderID:       "t0002",
 			UserEmail:     "sgarza1209@gmail.com",
-			SalesSubtotal: 19.99,
-			SalesTax:      0.80,
-			ShippingCost:  5.99,
-			OrderTotal:    26.78,
+			SalesSubtotal: store.USD(1999),
+			SalesTax:      store.USD(80),
+			ShippingCost:  store.USD(599),
+			OrderTotal:    store.USD(2678),
 			ShippingAddress: store.Address{
 				FirstName:    "Sal",
 				LastName:     "Garza",
@@ -71,10 +71,10 @@ func TestSendOrderNotification(t *testing.T) {
 		{order: &store.Order{
 			OrderID:       "t0001",
 			UserEmail:     "danielgarcia95367@gmail.com",
-			SalesSubtotal: 19.99,
-			SalesTax:      0.80,
-			ShippingCost:  5.99,
-			OrderTotal:    26.78,
+			SalesSubtotal: store.USD(1999),
+			SalesTax:      store.USD(80),
+			ShippingCost:  store.USD(599),
+			OrderTotal:    store.USD(2678),
 			ShippingAddress: store.Address{
 				FirstName:    "Daniel",
 				LastName:     "Garcia",
@@ -93,10 +93,10 @@ func TestSendOrderNotification(t *testing.T) {
 		{order: &store.Order{
 			OrderID:       "t0002",
 			UserEmail:     "sgarza1209@gmail.com",
-			SalesSubtotal: 19.99,
-			SalesTax:      0.80,
-			ShippingCost:  5.99,
-			OrderTotal:    26.78,
+			SalesSubtotal: store.USD(1999),
+			SalesTax:      store.USD(80),
+			ShippingCost:  store.USD(599),
+			OrderTotal:    store.USD(2678),
 			ShippingAddress: store.Address{
 				FirstName:    "Sal",
 				LastName:     "Garza",
@@ -0,0 +1,238 @@
+// Package shipops defines the Carrier interface used by the store to rate shipments and purchase
+// shipping labels, with a Shippo implementation and a deterministic Fake for offline tests.
+package shipops
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// Carrier names
+const (
+	CarrierShippo = "Shippo"
+	CarrierFake   = "Fake"
+)
+
+// Error codes returned by Carrier methods.
+const (
+	ErrInvalidAddress   = "ERR_INVALID_ADDRESS"
+	ErrNoRates          = "ERR_NO_RATES"
+	ErrRateNotFound     = "ERR_RATE_NOT_FOUND"
+	ErrLabelFailed      = "ERR_LABEL_FAILED"
+	ErrLabelNotFound    = "ERR_LABEL_NOT_FOUND"
+	ErrVoidFailed       = "ERR_LABEL_VOID_FAILED"
+	ErrTrackingNotFound = "ERR_TRACKING_NOT_FOUND"
+	ErrInvalidWebhook   = "ERR_INVALID_WEBHOOK"
+	ErrIgnoredEvent     = "ERR_IGNORED_EVENT"
+)
+
+// Tracking statuses. Carrier specific statuses are mapped to these values.
+const (
+	TrackingPreTransit = store.TrackingStatusPreTransit // label created; not yet scanned by the carrier
+	TrackingTransit    = store.TrackingStatusTransit
+	TrackingDelivered  = store.TrackingStatusDelivered
+	TrackingReturned   = store.TrackingStatusReturned
+	TrackingFailure    = store.TrackingStatusFailure
+	TrackingUnknown    = store.TrackingStatusUnknown
+)
+
+// Carrier contains the operations used to ship orders with a 3rd party shipping platform.
+type Carrier interface {
+	// Name returns the name of the shipping platform (ex: Shippo).
+	Name() string
+	// ValidateAddress returns the carrier's normalized address. Returns ErrInvalidAddress if the
+	// address is not deliverable.
+	ValidateAddress(addr store.Address) (store.Address, error)
+	// CreateParcel creates a parcel with the package's dimensions and template. The parcel's
+	// weight is the gross weight of the package.
+	CreateParcel(pkg store.Package) (*Parcel, error)
+	// Rate returns the rates of each service level for shipping the parcels. Returns ErrNoRates
+	// if no service level ships the parcels.
+	Rate(from, to store.Address, parcels []*Parcel) ([]store.RateSummary, error)
+	// PurchaseLabel purchases a label for the shipment's packages at the service level of the
+	// shipment's SelectedRate. Returns ErrRateNotFound if the service level is not available.
+	PurchaseLabel(s *store.Shipment) (store.ShippingLabel, error)
+	// VoidLabel voids an unused label and refunds its price. Returns ErrVoidFailed if the label
+	// was used.
+	VoidLabel(labelID string) error
+	// Track returns the current tracking status of the carrier's tracking number.
+	Track(carrier, trackingNumber string) (*Tracking, error)
+	// ParseTrackingEvent verifies a tracking webhook sent with the secret token of the webhook
+	// URL and returns its tracking status. Returns ErrInvalidWebhook if the token or payload is
+	// invalid, and ErrIgnoredEvent for webhooks that do not report a tracking status.
+	ParseTrackingEvent(payload []byte, token string) (*Tracking, error)
+}
+
+// Parcel represents a parcel created with a Carrier for rating and label purchases.
+type Parcel struct {
+	ID         string           `json:"id"` // carrier parcel ID
+	Dimensions store.Dimensions `json:"dimensions"`
+	Template   string           `json:"template"`
+	obj        interface{}      // carrier parcel object
+}
+
+// Tracking contains the tracking status of a shipping label.
+type Tracking = store.TrackingEvent
+
+// verifyToken returns ErrInvalidWebhook if the webhook secret is not set or the token does not
+// match it.
+func verifyToken(token, secret string) error {
+	if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return fmt.Errorf(ErrInvalidWebhook)
+	}
+	return nil
+}
+
+// PurchaseShippingLabel purchases the shipping labels of the given shipment object: a label for
+// the packages of each of the shipment's origins (see store.Shipment.SplitByOrigin). Labels are
+// purchased per the Shipment's 'SelectedRate' field and appended to its Labels. Origins with a
+// label are skipped, so failed purchases of split shipments can be retried.
+//
+// Each purchased label is passed to save (if not nil) before the next label is purchased, so
+// purchased labels are persisted and not purchased again by retries. Labels that cannot be
+// saved are voided and save's error is returned.
+func PurchaseShippingLabel(c Carrier, s *store.Shipment, save func(label store.ShippingLabel) error) error {
+	for _, part := range s.SplitByOrigin() {
+		locationID := ""
+		if len(part.Origins) > 0 {
+			locationID = part.Origins[0].LocationID
+		}
+		if hasLabel(s, locationID) {
+			continue
+		}
+		label, err := c.PurchaseLabel(part)
+		if err != nil {
+			log.Printf("PurchaseShippingLabel failed: %v", err)
+			return err
+		}
+		label.LocationID = locationID
+		if save != nil {
+			if err := save(label); err != nil {
+				log.Printf("PurchaseShippingLabel failed: %v", err)
+				if verr := c.VoidLabel(label.LabelID); verr != nil {
+					log.Printf("PurchaseShippingLabel failed: void label %s: %v", label.LabelID, verr)
+				}
+				return err
+			}
+		}
+		s.Labels = append(s.Labels, label)
+	}
+	return nil
+}
+
+// hasLabel returns true if the shipment has a label for the packages of the location. Shipments
+// without origins have a single label, with an empty location ID.
+func hasLabel(s *store.Shipment, locationID string) bool {
+	for _, l := range s.Labels {
+		if l.LocationID == locationID {
+			return true
+		}
+	}
+	return false
+}
+
+// RateShipment returns the rates of each service level for shipping the shipment's packages.
+// Split shipments are rated for each origin; the rates of service levels offered from each
+// origin are combined (see CombineRates). Returns ErrNoRates if no service level ships each of
+// the shipment's packages.
+func RateShipment(c Carrier, s *store.Shipment) ([]store.RateSummary, error) {
+	rateSets := [][]store.RateSummary{}
+	for _, part := range s.SplitByOrigin() {
+		parcels := []*Parcel{}
+		for _, pkg := range part.Packages {
+			parcel, err := c.CreateParcel(pkg)
+			if err != nil {
+				log.Printf("RateShipment failed: %v", err)
+				return nil, err
+			}
+			parcels = append(parcels, parcel)
+		}
+		rates, err := c.Rate(part.AddressFrom, part.AddressTo, parcels)
+		if err != nil {
+			log.Printf("RateShipment failed: %v", err)
+			return nil, err
+		}
+		rateSets = append(rateSets, rates)
+	}
+	return CombineRates(rateSets)
+}
+
+// CombineRates combines the rates of the parts of a split shipment. Service levels offered for
+// each part are priced at the sum of the part's rates, and delivered in the days of the slowest
+// part. Rates are sorted by price. Returns ErrNoRates if no service level is offered for each part.
+func CombineRates(rateSets [][]store.RateSummary) ([]store.RateSummary, error) {
+	if len(rateSets) == 1 {
+		return rateSets[0], nil
+	}
+	if len(rateSets) == 0 {
+		return nil, fmt.Errorf(ErrNoRates)
+	}
+	combined := []store.RateSummary{}
+	prices := make(map[string]store.Money)
+	for _, rate := range rateSets[0] {
+		token := rate.ServiceLevel.Token
+		price, err := store.ParseMoney(rate.Price, rate.Currency)
+		if err != nil {
+			return nil, err
+		}
+		offered := true
+		for _, rates := range rateSets[1:] {
+			found := false
+			for _, r := range rates {
+				if r.ServiceLevel.Token != token || r.Currency != rate.Currency {
+					continue
+				}
+				p, err := store.ParseMoney(r.Price, r.Currency)
+				if err != nil {
+					return nil, err
+				}
+				price = price.Add(p)
+				if r.Days > rate.Days {
+					rate.Days = r.Days
+				}
+				found = true
+				break
+			}
+			offered = offered && found
+		}
+		if !offered {
+			continue
+		}
+		rate.Price = price.String()
+		prices[token] = price
+		combined = append(combined, rate)
+	}
+	if len(combined) == 0 {
+		return nil, fmt.Errorf(ErrNoRates)
+	}
+	sort.SliceStable(combined, func(i, j int) bool {
+		return prices[combined[i].ServiceLevel.Token].Cmp(prices[combined[j].ServiceLevel.Token]) < 0
+	})
+	return combined, nil
+}
+
+// PurchaseReturnLabel purchases a return shipping label from the original shipment's destination
+// to the return address of the location the shipment was shipped from (see
+// store.Shipment.ReturnTo), using the original shipment's packages and selected rate.
+// The label is set to the ret.ReturnLabel field.
+func PurchaseReturnLabel(c Carrier, ret *store.Return, s *store.Shipment) error {
+	rs := &store.Shipment{
+		UserID:       s.UserID,
+		OrderID:      s.OrderID,
+		AddressFrom:  s.AddressTo,
+		AddressTo:    s.ReturnTo(),
+		Packages:     s.Packages,
+		SelectedRate: s.SelectedRate,
+	}
+	label, err := c.PurchaseLabel(rs)
+	if err != nil {
+		log.Printf("PurchaseReturnLabel failed: %v", err)
+		return err
+	}
+	ret.ReturnLabel = label
+	return nil
+}
@@ -0,0 +1,116 @@
+package shipops
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+func TestSplitShipment(t *testing.T) {
+	f := NewFake()
+	to := store.Address{AddressLine1: "3250 Hollis St", City: "Oakland", State: "CA", Zip: "94608"}
+	east := store.ShipmentOrigin{LocationID: "nyc", AddressFrom: store.Address{AddressLine1: "1 Main St", City: "New York", State: "NY", Zip: "10001"}}
+	west := store.ShipmentOrigin{LocationID: "mod", AddressFrom: origin, ReturnAddress: store.Address{AddressLine1: "2 Returns Way", City: "Modesto", State: "CA", Zip: "95355"}}
+	pkg := store.Package{Dimensions: store.Dimensions{Length: "12", Width: "10", Height: "6", DistanceUnit: "in", Weight: "2", MassUnit: "lb"}}
+
+	s := &store.Shipment{UserID: "u01", OrderID: "u01-1", AddressTo: to}
+	s.AddOrigin(west, []store.Package{pkg})
+	s.AddOrigin(east, []store.Package{pkg, pkg})
+	if s.AddressFrom != west.AddressFrom || s.ReturnTo() != west.ReturnAddress || len(s.Packages) != 3 || s.Packages[2].LocationID != "nyc" {
+		t.Fatalf("FAIL: %v", s)
+	}
+
+	// each origin is rated separately; service levels are priced at the sum of each origin's rate
+	rates, err := RateShipment(f, s)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	got := []string{}
+	for _, r := range rates {
+		got = append(got, fmt.Sprintf("%s:%s:%d", r.ServiceLevel.Token, r.Price, r.Days))
+	}
+	// ground: local 4.50 + 1.00; zone 8 5.50 + 1.50 each
+	want := "[usps_ground_advantage:19.50:5 usps_priority:34.50:3 usps_priority_express:94.50:1]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("FAIL: %v; want: %s", got, want)
+	}
+
+	// a label is purchased for each origin; labels that cannot be saved are voided
+	s.SelectedRate = rates[0]
+	unsaved := ""
+	save := func(label store.ShippingLabel) error {
+		if label.LocationID == "nyc" {
+			unsaved = label.LabelID
+			return fmt.Errorf("ERR_SAVE")
+		}
+		return nil
+	}
+	if err := PurchaseShippingLabel(f, s, save); err == nil || len(s.Labels) != 1 {
+		t.Fatalf("FAIL: %v, %v; want: ERR_SAVE, 1 label", err, s.Labels)
+	}
+	if err := f.VoidLabel(unsaved); err == nil || err.Error() != ErrVoidFailed {
+		t.Errorf("FAIL: %v; want: %s", err, ErrVoidFailed)
+	}
+
+	// retries skip origins with a label
+	if err := PurchaseShippingLabel(f, s, nil); err != nil || len(s.Labels) != 2 {
+		t.Fatalf("FAIL: %v, %v", err, s.Labels)
+	}
+	if s.Labels[0].LocationID != "mod" || s.Labels[1].LocationID != "nyc" {
+		t.Errorf("FAIL: %s, %s; want: mod, nyc", s.Labels[0].LocationID, s.Labels[1].LocationID)
+	}
+	if err := PurchaseShippingLabel(f, s, nil); err != nil || len(s.Labels) != 2 {
+		t.Errorf("FAIL: %v, %d labels; want: 2", err, len(s.Labels))
+	}
+
+	// returns are shipped to the first origin's return address
+	ret := &store.Return{}
+	if err := PurchaseReturnLabel(f, ret, s); err != nil || ret.ReturnLabel.LabelID == "" {
+		t.Errorf("FAIL: %v, %v", err, ret.ReturnLabel)
+	}
+}
+
+func TestCombineRates(t *testing.T) {
+	rate := func(token, price string, days int) store.RateSummary {
+		return store.RateSummary{Price: price, Currency: store.CurrencyUSD, Days: days, ServiceLevel: store.ServiceLevel{Token: token}}
+	}
+	var tests = []struct {
+		sets    [][]store.RateSummary
+		want    string
+		wantErr string
+	}{
+		{
+			sets: [][]store.RateSummary{{rate("ground", "5.00", 5), rate("priority", "9.00", 3)}},
+			want: "[ground:5.00:5 priority:9.00:3]",
+		},
+		{
+			sets: [][]store.RateSummary{
+				{rate("priority", "9.00", 3), rate("ground", "5.00", 5), rate("express", "30.00", 1)},
+				{rate("ground", "7.25", 2), rate("priority", "8.00", 4)},
+			},
+			want: "[ground:12.25:5 priority:17.00:4]", // express not offered for each part
+		},
+		{
+			sets:    [][]store.RateSummary{{rate("ground", "5.00", 5)}, {rate("priority", "8.00", 4)}},
+			wantErr: ErrNoRates,
+		},
+		{sets: [][]store.RateSummary{}, wantErr: ErrNoRates},
+	}
+	for _, test := range tests {
+		rates, err := CombineRates(test.sets)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		got := []string{}
+		for _, r := range rates {
+			got = append(got, fmt.Sprintf("%s:%s:%d", r.ServiceLevel.Token, r.Price, r.Days))
+		}
+		if err != nil || fmt.Sprint(got) != test.want {
+			t.Errorf("FAIL: %v, %v; want: %s", err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package shipops
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/timeops"
+)
+
+// Shipping zones of the Fake carrier (see Zone).
+const (
+	ZoneLocal         = 1
+	ZoneMax           = 8
+	ZoneInternational = 9
+)
+
+// FakeRate is a rate offered by the Fake carrier for a service level in a shipping zone. The
+// price of each parcel is Price plus PerLb for each pound of the parcel's weight, rounded up.
+type FakeRate struct {
+	Provider     string
+	ServiceLevel store.ServiceLevel
+	Zone         int // 0: rate applies to each zone without a zone specific rate
+	Price        store.Money
+	PerLb        store.Money
+	Days         int
+}
+
+// DefaultFakeRates are used by the Fake carrier if no rates are configured. International
+// parcels are shipped with Priority Mail International only.
+var DefaultFakeRates = []FakeRate{
+	{Provider: store.CarriersUsps, ServiceLevel: store.ServiceLevel{Name: "Ground Advantage", Token: "usps_ground_advantage"}, Price: store.USD(550), PerLb: store.USD(75), Days: 5},
+	{Provider: store.CarriersUsps, ServiceLevel: store.ServiceLevel{Name: "Ground Advantage", Token: "usps_ground_advantage"}, Zone: ZoneLocal, Price: store.USD(450), PerLb: store.USD(50), Days: 2},
+	{Provider: store.CarriersUsps, ServiceLevel: store.ServiceLevel{Name: "Priority Mail", Token: "usps_priority"}, Price: store.USD(950), PerLb: store.USD(100), Days: 3},
+	{Provider: store.CarriersUsps, ServiceLevel: store.ServiceLevel{Name: "Priority Mail Express", Token: "usps_priority_express"}, Price: store.USD(2850), PerLb: store.USD(150), Days: 1},
+	{Provider: store.CarriersUsps, ServiceLevel: store.ServiceLevel{Name: "Priority Mail International", Token: "usps_priority_mail_international"}, Zone: ZoneInternational, Price: store.USD(4500), PerLb: store.USD(400), Days: 10},
+}
+
+// fakeLabel is a label purchased from the Fake carrier.
+type fakeLabel struct {
+	label  store.ShippingLabel
+	voided bool
+}
+
+// Fake implements Carrier in memory with deterministic rates based on the configured FakeRates.
+// Addresses without a street or ZIP code, or with a ZIP code in InvalidZips, are invalid.
+// Tracking webhooks are Tracking objects encoded as JSON (see TrackingWebhook).
+// Fake is safe for concurrent use.
+type Fake struct {
+	Rates         []FakeRate
+	InvalidZips   map[string]bool
+	WebhookSecret string
+
+	mu       sync.Mutex
+	labels   map[string]*fakeLabel // label ID: label
+	tracking map[string]*Tracking  // tracking number: tracking status
+	count    int
+}
+
+// NewFake returns a new *Fake carrier with the given rates, or DefaultFakeRates if no rates
+// are given.
+func NewFake(rates ...FakeRate) *Fake {
+	if len(rates) == 0 {
+		rates = DefaultFakeRates
+	}
+	return &Fake{
+		Rates:       rates,
+		InvalidZips: make(map[string]bool),
+		labels:      make(map[string]*fakeLabel),
+		tracking:    make(map[string]*Tracking),
+	}
+}
+
+func (f *Fake) Name() string {
+	return CarrierFake
+}
+
+// Zone returns the shipping zone between the ZIP codes of the addresses: ZoneLocal plus the
+// difference of their first digits, or ZoneInternational if either ZIP code is not a US ZIP code.
+func Zone(from, to store.Address) int {
+	a, b := zipDigit(from.Zip), zipDigit(to.Zip)
+	if a < 0 || b < 0 {
+		return ZoneInternational
+	}
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	if zone := ZoneLocal + diff; zone < ZoneMax {
+		return zone
+	}
+	return ZoneMax
+}
+
+// zipDigit returns the first digit of the 5 digit US ZIP code, or -1 if the ZIP code is invalid.
+func zipDigit(zip string) int {
+	zip = strings.TrimSpace(zip)
+	if len(zip) < 5 {
+		return -1
+	}
+	if _, err := strconv.Atoi(zip[:5]); err != nil {
+		return -1
+	}
+	return int(zip[0] - '0')
+}
+
+// ValidateAddress returns the address with its state and country upper cased, or
+// ErrInvalidAddress.
+func (f *Fake) ValidateAddress(addr store.Address) (store.Address, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if strings.TrimSpace(addr.AddressLine1) == "" || strings.TrimSpace(addr.Zip) == "" || f.InvalidZips[addr.Zip] {
+		return store.Address{}, fmt.Errorf(ErrInvalidAddress)
+	}
+	addr.State = strings.ToUpper(addr.State)
+	addr.Country = strings.ToUpper(addr.Country)
+	return addr, nil
+}
+
+func (f *Fake) CreateParcel(pkg store.Package) (*Parcel, error) {
+	if _, err := weightLbs(pkg.Dimensions); err != nil {
+		return &Parcel{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count++
+	return &Parcel{ID: fmt.Sprintf("fake_parcel_%04d", f.count), Dimensions: pkg.Dimensions, Template: pkg.Template}, nil
+}
+
+// weightLbs returns the weight of the dimensions in pounds.
+func weightLbs(d store.Dimensions) (float64, error) {
+	w, err := strconv.ParseFloat(d.Weight, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.ToLower(d.MassUnit) {
+	case "oz":
+		return w / 16, nil
+	case "kg":
+		return w * 2.20462262, nil
+	case "g":
+		return w * 0.00220462262, nil
+	}
+	return w, nil
+}
+
+// Rate returns the rate of each service level for the zone between the addresses, sorted by
+// price.
+func (f *Fake) Rate(from, to store.Address, parcels []*Parcel) ([]store.RateSummary, error) {
+	dims := []store.Dimensions{}
+	for _, p := range parcels {
+		dims = append(dims, p.Dimensions)
+	}
+	return f.rate(from, to, dims)
+}
+
+func (f *Fake) rate(from, to store.Address, parcels []store.Dimensions) ([]store.RateSummary, error) {
+	if len(parcels) == 0 {
+		return nil, fmt.Errorf(ErrNoRates)
+	}
+	zone := Zone(from, to)
+
+	// zone specific rates replace the default rate of the service level
+	selected := make(map[string]FakeRate)
+	for _, r := range f.Rates {
+		if r.Zone != zone && (r.Zone != 0 || zone == ZoneInternational) {
+			continue
+		}
+		if _, ok := selected[r.ServiceLevel.Token]; ok && r.Zone == 0 {
+			continue
+		}
+		selected[r.ServiceLevel.Token] = r
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf(ErrNoRates)
+	}
+
+	rates := []store.RateSummary{}
+	prices := make(map[string]store.Money)
+	for token, r := range selected {
+		price := store.NewMoney(0, r.Price.Currency)
+		for _, d := range parcels {
+			lbs, err := weightLbs(d)
+			if err != nil {
+				return nil, err
+			}
+			price = price.Add(r.Price).Add(r.PerLb.Mul(int64(math.Ceil(lbs))))
+		}
+		prices[token] = price
+		rates = append(rates, store.RateSummary{
+			Price:        price.String(),
+			Currency:     price.Currency,
+			Provider:     r.Provider,
+			Days:         r.Days,
+			ServiceLevel: r.ServiceLevel,
+		})
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		a, b := prices[rates[i].ServiceLevel.Token], prices[rates[j].ServiceLevel.Token]
+		if c := a.Cmp(b); c != 0 {
+			return c < 0
+		}
+		return rates[i].ServiceLevel.Token < rates[j].ServiceLevel.Token
+	})
+	return rates, nil
+}
+
+// PurchaseLabel purchases a label priced at the current rate of the selected service level.
+// Labels are numbered in order of purchase.
+func (f *Fake) PurchaseLabel(s *store.Shipment) (store.ShippingLabel, error) {
+	dims := []store.Dimensions{}
+	for _, pkg := range s.Packages {
+		dims = append(dims, pkg.Dimensions)
+	}
+	rates, err := f.rate(s.AddressFrom, s.AddressTo, dims)
+	if err != nil {
+		return store.ShippingLabel{}, err
+	}
+	var rate *store.RateSummary
+	for i := range rates {
+		if rates[i].ServiceLevel.Token == s.SelectedRate.ServiceLevel.Token {
+			rate = &rates[i]
+		}
+	}
+	if rate == nil {
+		return store.ShippingLabel{}, fmt.Errorf(ErrRateNotFound)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count++
+	now := time.Now()
+	labelID := fmt.Sprintf("fake_label_%04d", f.count)
+	trackingNumber := fmt.Sprintf("FAKE%010d", f.count)
+	label := store.ShippingLabel{
+		OrderID:             s.OrderID,
+		LabelID:             labelID,
+		Carrier:             rate.Provider,
+		Price:               rate.Price,
+		Currency:            rate.Currency,
+		PurchaseDate:        timeops.ConvertToDateString(now),
+		TrackingNumber:      trackingNumber,
+		TrackingStatus:      TrackingPreTransit,
+		TrackingUrlProvider: "https://tracking.example.com/" + trackingNumber,
+		Eta:                 timeops.ConvertToTimestampStringHour(now.AddDate(0, 0, rate.Days)),
+		LabelUrl:            "https://labels.example.com/" + labelID + ".pdf",
+	}
+	f.labels[labelID] = &fakeLabel{label: label}
+	f.tracking[trackingNumber] = &Tracking{
+		ID:             fmt.Sprintf("fake_status_%04d", f.count),
+		Carrier:        rate.Provider,
+		TrackingNumber: trackingNumber,
+		Status:         TrackingPreTransit,
+		StatusDate:     timeops.ConvertToTimestampString(now.UTC()),
+	}
+	return label, nil
+}
+
+// VoidLabel voids the label. Returns ErrVoidFailed if the label was voided or has been scanned
+// by the carrier.
+func (f *Fake) VoidLabel(labelID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l := f.labels[labelID]
+	if l == nil {
+		return fmt.Errorf(ErrLabelNotFound)
+	}
+	if l.voided || f.tracking[l.label.TrackingNumber].Status != TrackingPreTransit {
+		return fmt.Errorf(ErrVoidFailed)
+	}
+	l.voided = true
+	return nil
+}
+
+func (f *Fake) Track(carrier, trackingNumber string) (*Tracking, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.tracking[trackingNumber]
+	if t == nil {
+		return &Tracking{}, fmt.Errorf(ErrTrackingNotFound)
+	}
+	tracking := *t
+	return &tracking, nil
+}
+
+// SetTracking sets the tracking status of the label with the tracking number, as if the
+// carrier scanned the parcel. The status may be followed by a substatus
+// (ex: TRANSIT/out_for_delivery).
+func (f *Fake) SetTracking(trackingNumber, status, details string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.tracking[trackingNumber]
+	if t == nil {
+		return fmt.Errorf(ErrTrackingNotFound)
+	}
+	f.count++
+	t.ID = fmt.Sprintf("fake_status_%04d", f.count)
+	t.Status, t.Substatus = status, ""
+	if i := strings.Index(status, "/"); i >= 0 {
+		t.Status, t.Substatus = status[:i], status[i+1:]
+	}
+	t.StatusDetails = details
+	t.StatusDate = timeops.ConvertToTimestampString(at.UTC())
+	return nil
+}
+
+// TrackingWebhook returns the payload of a tracking webhook reporting the current tracking
+// status of the tracking number.
+func (f *Fake) TrackingWebhook(trackingNumber string) ([]byte, error) {
+	t, err := f.Track("", trackingNumber)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(t)
+}
+
+// ParseTrackingEvent verifies the webhook's token against the webhook secret and decodes the
+// Tracking object of the payload.
+func (f *Fake) ParseTrackingEvent(payload []byte, token string) (*Tracking, error) {
+	if err := verifyToken(token, f.WebhookSecret); err != nil {
+		return &Tracking{}, err
+	}
+	t := &Tracking{}
+	if err := json.Unmarshal(payload, t); err != nil || t.TrackingNumber == "" {
+		return &Tracking{}, fmt.Errorf(ErrInvalidWebhook)
+	}
+	if t.Status == "" {
+		return &Tracking{}, fmt.Errorf(ErrIgnoredEvent)
+	}
+	return t, nil
+}
@@ -0,0 +1,193 @@
+package shipops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// origin is the address test shipments are shipped from.
+var origin = store.Address{Company: "Test Warehouse", AddressLine1: "100 Test Way", City: "Modesto", State: "CA", Country: "US", Zip: "95355"}
+
+func TestZone(t *testing.T) {
+	var tests = []struct {
+		from, to string
+		want     int
+	}{
+		{from: "95355", to: "94608", want: ZoneLocal},
+		{from: "95355", to: "80202", want: 2},
+		{from: "95355", to: "10001", want: ZoneMax},
+		{from: "10001", to: "95355-1234", want: ZoneMax},
+		{from: "95355", to: "M5V 2T6", want: ZoneInternational},
+		{from: "95355", to: "", want: ZoneInternational},
+	}
+	for _, test := range tests {
+		if got := Zone(store.Address{Zip: test.from}, store.Address{Zip: test.to}); got != test.want {
+			t.Errorf("FAIL: %s -> %s: %d; want: %d", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestFakeRate(t *testing.T) {
+	parcel := func(weight, unit string) *Parcel {
+		return &Parcel{Dimensions: store.Dimensions{Length: "10", Width: "8", Height: "4", DistanceUnit: "in", Weight: weight, MassUnit: unit}}
+	}
+	var tests = []struct {
+		zip       string
+		parcels   []*Parcel
+		wantRates []string // service level token: price, sorted by price
+		wantErr   string
+	}{
+		{zip: "94608", parcels: []*Parcel{parcel("1.2", "lb")}, // local zone rate
+			wantRates: []string{"usps_ground_advantage:5.50", "usps_priority:11.50", "usps_priority_express:31.50"}},
+		{zip: "10001", parcels: []*Parcel{parcel("1.2", "lb")},
+			wantRates: []string{"usps_ground_advantage:7.00", "usps_priority:11.50", "usps_priority_express:31.50"}},
+		{zip: "10001", parcels: []*Parcel{parcel("8", "oz"), parcel("8", "oz")}, // priced per parcel
+			wantRates: []string{"usps_ground_advantage:12.50", "usps_priority:21.00", "usps_priority_express:60.00"}},
+		{zip: "M5V 2T6", parcels: []*Parcel{parcel("1", "kg")},
+			wantRates: []string{"usps_priority_mail_international:57.00"}},
+		{zip: "94608", parcels: []*Parcel{}, wantErr: ErrNoRates},
+	}
+	f := NewFake()
+	for _, test := range tests {
+		rates, err := f.Rate(origin, store.Address{Zip: test.zip}, test.parcels)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || len(rates) != len(test.wantRates) {
+			t.Errorf("FAIL: %v, %v; want: %v", err, rates, test.wantRates)
+			continue
+		}
+		for i, rate := range rates {
+			if got := rate.ServiceLevel.Token + ":" + rate.Price; got != test.wantRates[i] {
+				t.Errorf("FAIL: %s; want: %s", got, test.wantRates[i])
+			}
+		}
+	}
+
+	// configured rates
+	f = NewFake(FakeRate{Provider: store.CarriersUPS, ServiceLevel: store.ServiceLevel{Token: "ups_ground"}, Zone: 2, Price: store.USD(899), Days: 3})
+	if _, err := f.Rate(origin, store.Address{Zip: "94608"}, []*Parcel{parcel("1", "lb")}); err == nil || err.Error() != ErrNoRates {
+		t.Errorf("FAIL: %v; want: %s", err, ErrNoRates)
+	}
+	rates, err := f.Rate(origin, store.Address{Zip: "80202"}, []*Parcel{parcel("1", "lb")})
+	if err != nil || len(rates) != 1 || rates[0].Price != "8.99" || rates[0].Provider != store.CarriersUPS {
+		t.Errorf("FAIL: %v, %v; want: ups_ground 8.99", err, rates)
+	}
+}
+
+func TestFakeLabelLifecycle(t *testing.T) {
+	f := NewFake()
+	f.InvalidZips["00000"] = true
+
+	if _, err := f.ValidateAddress(store.Address{AddressLine1: "1 Main St", Zip: "00000"}); err == nil || err.Error() != ErrInvalidAddress {
+		t.Errorf("FAIL: %v; want: %s", err, ErrInvalidAddress)
+	}
+	to, err := f.ValidateAddress(store.Address{FirstName: "Jane", AddressLine1: "3250 Hollis St", City: "Oakland", State: "ca", Zip: "94608", Country: "us"})
+	if err != nil || to.State != "CA" || to.Country != "US" || to.FirstName != "Jane" {
+		t.Fatalf("FAIL: %v, %v", err, to)
+	}
+
+	pkg := store.Package{Template: store.UspsSmallFlatRate1, Dimensions: store.Dimensions{Length: "8.69", Width: "5.44", Height: "1.75", DistanceUnit: "in", Weight: "0.50", MassUnit: "lb"}}
+	parcel, err := f.CreateParcel(pkg)
+	if err != nil || parcel.ID == "" {
+		t.Fatalf("FAIL: %v, %v", err, parcel)
+	}
+	rates, err := f.Rate(origin, to, []*Parcel{parcel})
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+
+	s := &store.Shipment{OrderID: "u01-1", AddressFrom: origin, AddressTo: to, Packages: []store.Package{pkg}}
+	s.SelectedRate = store.RateSummary{ServiceLevel: store.ServiceLevel{Token: "ups_next_day_air"}}
+	if err := PurchaseShippingLabel(f, s, nil); err == nil || err.Error() != ErrRateNotFound {
+		t.Errorf("FAIL: %v; want: %s", err, ErrRateNotFound)
+	}
+	s.SelectedRate = rates[1]
+	if err := PurchaseShippingLabel(f, s, nil); err != nil || len(s.Labels) != 1 {
+		t.Fatalf("FAIL: %v, %v", err, s.Labels)
+	}
+	label := s.Labels[0]
+	if label.Price != rates[1].Price || label.TrackingStatus != TrackingPreTransit || label.TrackingNumber == "" {
+		t.Errorf("FAIL: %v; want: %s, %s", label, rates[1].Price, TrackingPreTransit)
+	}
+
+	// unused labels can be voided once
+	var tests = []struct {
+		labelID string
+		wantErr string
+	}{
+		{labelID: label.LabelID},
+		{labelID: label.LabelID, wantErr: ErrVoidFailed},
+		{labelID: "fake_label_9999", wantErr: ErrLabelNotFound},
+	}
+	for _, test := range tests {
+		err := f.VoidLabel(test.labelID)
+		if (test.wantErr == "" && err != nil) || (test.wantErr != "" && (err == nil || err.Error() != test.wantErr)) {
+			t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+		}
+	}
+
+	// scanned labels cannot be voided
+	s.Labels = nil // replace voided label
+	PurchaseShippingLabel(f, s, nil)
+	label = s.Labels[0]
+	f.SetTracking(label.TrackingNumber, TrackingTransit, "Departed USPS facility", time.Now())
+	tracking, err := f.Track(label.Carrier, label.TrackingNumber)
+	if err != nil || tracking.Status != TrackingTransit {
+		t.Errorf("FAIL: %v, %v; want: %s", err, tracking, TrackingTransit)
+	}
+	if err := f.VoidLabel(label.LabelID); err == nil || err.Error() != ErrVoidFailed {
+		t.Errorf("FAIL: %v; want: %s", err, ErrVoidFailed)
+	}
+	if _, err := f.Track(label.Carrier, "FAKE9999999999"); err == nil || err.Error() != ErrTrackingNotFound {
+		t.Errorf("FAIL: %v; want: %s", err, ErrTrackingNotFound)
+	}
+}
+
+func TestFakeTrackingWebhook(t *testing.T) {
+	f := NewFake()
+	f.WebhookSecret = "secret"
+	to := store.Address{AddressLine1: "3250 Hollis St", City: "Oakland", State: "CA", Zip: "94608"}
+	pkg := store.Package{Dimensions: store.Dimensions{Length: "12", Width: "10", Height: "6", DistanceUnit: "in", Weight: "2", MassUnit: "lb"}}
+	s := &store.Shipment{OrderID: "u01-1", AddressFrom: origin, AddressTo: to, Packages: []store.Package{pkg}}
+	s.SelectedRate = store.RateSummary{ServiceLevel: store.ServiceLevel{Token: "usps_priority"}}
+	if err := PurchaseShippingLabel(f, s, nil); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	number := s.Labels[0].TrackingNumber
+	f.SetTracking(number, TrackingTransit+"/"+store.TrackingSubstatusOutForDelivery, "Out for delivery", time.Now())
+	payload, err := f.TrackingWebhook(number)
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+
+	var tests = []struct {
+		payload []byte
+		token   string
+		wantErr string
+	}{
+		{payload: payload, token: "secret"},
+		{payload: payload, token: "wrong", wantErr: ErrInvalidWebhook},
+		{payload: payload, token: "", wantErr: ErrInvalidWebhook},
+		{payload: []byte("{"), token: "secret", wantErr: ErrInvalidWebhook},
+		{payload: []byte(`{"tracking_number": "FAKE0000000001"}`), token: "secret", wantErr: ErrIgnoredEvent},
+	}
+	for _, test := range tests {
+		tracking, err := f.ParseTrackingEvent(test.payload, test.token)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || tracking.TrackingNumber != number || tracking.Status != TrackingTransit ||
+			tracking.Substatus != store.TrackingSubstatusOutForDelivery || tracking.ID == "" {
+			t.Errorf("FAIL: %v, %v; want: %s %s/%s", err, tracking, number, TrackingTransit, store.TrackingSubstatusOutForDelivery)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package shipops
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/coldbrewcloud/go-shippo"
+	"github.com/coldbrewcloud/go-shippo/client"
+	"github.com/coldbrewcloud/go-shippo/models"
+	"github.com/tpillz-presents/service/store-api/store"
+	"github.com/tpillz-presents/service/util/timeops"
+)
+
+// EnvarShippoPrivateToken contains the name of the environment variable holding the Shippo API
+// private token.
+const EnvarShippoPrivateToken = "SHIPPO_PRIVATE_TOKEN"
+
+// EnvarShippoWebhookSecret contains the name of the environment variable holding the secret
+// token of the Shippo tracking webhook URL.
+const EnvarShippoWebhookSecret = "SHIPPO_WEBHOOK_SECRET"
+
+// shippoTrackUpdated is the event type of Shippo tracking webhooks.
+const shippoTrackUpdated = "track_updated"
+
+// shippoRefundError is the status of Shippo refunds that were rejected.
+const shippoRefundError = "ERROR"
+
+// Shippo implements Carrier with the Shippo API.
+type Shippo struct {
+	Client        *client.Client
+	WebhookSecret string // secret token of the tracking webhook URL (ex: /webhook?token=<secret>)
+}
+
+// InitClient initializes the Shippo API client.
+func InitClient(privateKey string) *client.Client {
+	c := shippo.NewClient(privateKey)
+	return c
+}
+
+// NewShippo returns a new *Shippo carrier for the given private token.
+func NewShippo(privateToken string) *Shippo {
+	return &Shippo{Client: InitClient(privateToken)}
+}
+
+// NewShippoFromEnv returns a new *Shippo carrier using the private token set in the
+// SHIPPO_PRIVATE_TOKEN environment variable and the webhook secret set in the
+// SHIPPO_WEBHOOK_SECRET environment variable.
+func NewShippoFromEnv() *Shippo {
+	s := NewShippo(os.Getenv(EnvarShippoPrivateToken))
+	s.WebhookSecret = os.Getenv(EnvarShippoWebhookSecret)
+	return s
+}
+
+func (s *Shippo) Name() string {
+	return CarrierShippo
+}
+
+// addressInput returns the Shippo address input of the address.
+func addressInput(a store.Address, validate bool) *models.AddressInput {
+	return &models.AddressInput{
+		Name:     a.FirstName + " " + a.LastName,
+		Street1:  a.AddressLine1,
+		Street2:  a.AddressLine2,
+		Company:  a.Company,
+		City:     a.City,
+		State:    a.State,
+		Zip:      a.Zip,
+		Country:  a.Country,
+		Phone:    a.PhoneNumber,
+		Email:    a.Email,
+		Validate: validate,
+	}
+}
+
+// parcelInput returns the Shippo parcel input of the package.
+func parcelInput(pkg store.Package) *models.ParcelInput {
+	d := pkg.Dimensions
+	return &models.ParcelInput{
+		Length:       d.Length,
+		Width:        d.Width,
+		Height:       d.Height,
+		DistanceUnit: d.DistanceUnit,
+		Weight:       d.Weight,
+		MassUnit:     d.MassUnit,
+		Template:     pkg.Template,
+	}
+}
+
+// ValidateAddress validates the address with Shippo. The name, phone number and email of the
+// given address are kept.
+func (s *Shippo) ValidateAddress(addr store.Address) (store.Address, error) {
+	a, err := s.Client.CreateAddress(addressInput(addr, true))
+	if err != nil {
+		log.Printf("ValidateAddress failed: %v", err)
+		return store.Address{}, err
+	}
+	if a.ValidationResults == nil || !a.ValidationResults.IsValid {
+		if a.ValidationResults != nil {
+			log.Printf("ValidateAddress: invalid address: %v", a.ValidationResults.Messages)
+		}
+		return store.Address{}, fmt.Errorf(ErrInvalidAddress)
+	}
+	addr.Company = a.Company
+	addr.AddressLine1 = a.Street1
+	addr.AddressLine2 = a.Street2
+	addr.City = a.City
+	addr.State = a.State
+	addr.Zip = a.Zip
+	addr.Country = a.Country
+	return addr, nil
+}
+
+func (s *Shippo) CreateParcel(pkg store.Package) (*Parcel, error) {
+	p, err := s.Client.CreateParcel(parcelInput(pkg))
+	if err != nil {
+		log.Printf("CreateParcel failed: %v", err)
+		return &Parcel{}, err
+	}
+	return &Parcel{ID: p.ObjectID, Dimensions: pkg.Dimensions, Template: pkg.Template, obj: p}, nil
+}
+
+func (s *Shippo) Rate(from, to store.Address, parcels []*Parcel) ([]store.RateSummary, error) {
+	objs := []*models.Parcel{}
+	for _, p := range parcels {
+		obj, ok := p.obj.(*models.Parcel)
+		if !ok {
+			created, err := s.CreateParcel(store.Package{Dimensions: p.Dimensions, Template: p.Template})
+			if err != nil {
+				log.Printf("Rate failed: %v", err)
+				return nil, err
+			}
+			obj = created.obj.(*models.Parcel)
+		}
+		objs = append(objs, obj)
+	}
+	shipment, err := s.createShipment(from, to, objs)
+	if err != nil {
+		log.Printf("Rate failed: %v", err)
+		return nil, err
+	}
+	if len(shipment.Rates) == 0 {
+		return nil, fmt.Errorf(ErrNoRates)
+	}
+	return getRates(shipment.Rates), nil
+}
+
+// createShipment creates a Shippo Shipment object for the parcels.
+func (s *Shippo) createShipment(from, to store.Address, parcels []*models.Parcel) (*models.Shipment, error) {
+	// create a sending address
+	addressFrom, err := s.Client.CreateAddress(addressInput(from, false))
+	if err != nil {
+		log.Printf("createShipment failed: %v", err)
+		return &models.Shipment{}, err
+	}
+
+	// create a receiving address
+	addressTo, err := s.Client.CreateAddress(addressInput(to, false))
+	if err != nil {
+		log.Printf("createShipment failed: %v", err)
+		return &models.Shipment{}, err
+	}
+
+	// create a shipment
+	shipmentInput := &models.ShipmentInput{
+		AddressFrom: addressFrom,
+		AddressTo:   addressTo,
+		Parcels:     parcels,
+		Async:       false,
+	}
+	shipment, err := s.Client.CreateShipment(shipmentInput)
+	if err != nil {
+		log.Printf("createShipment failed: %v", err)
+		return &models.Shipment{}, err
+	}
+	return shipment, nil
+}
+
+// PurchaseLabel purchases a new shipping label for the given shipment object.
+// Label is purchased per the Shipment's 'SelectedRate' field.
+func (s *Shippo) PurchaseLabel(sh *store.Shipment) (store.ShippingLabel, error) {
+	// create shippo shipment object
+	parcels := []*models.Parcel{}
+	for _, pkg := range sh.Packages {
+		parcel, err := s.Client.CreateParcel(parcelInput(pkg))
+		if err != nil {
+			log.Printf("PurchaseLabel failed: %v", err)
+			return store.ShippingLabel{}, err
+		}
+		parcels = append(parcels, parcel)
+	}
+	shipment, err := s.createShipment(sh.AddressFrom, sh.AddressTo, parcels)
+	if err != nil {
+		log.Printf("PurchaseLabel failed: %v", err)
+		return store.ShippingLabel{}, err
+	}
+
+	// get rate
+	var rate *models.Rate
+	for _, r := range shipment.Rates {
+		if r.ServiceLevel != nil && r.ServiceLevel.Token == sh.SelectedRate.ServiceLevel.Token {
+			rate = r
+			break
+		}
+	}
+	if rate == nil {
+		return store.ShippingLabel{}, fmt.Errorf(ErrRateNotFound)
+	}
+
+	// purchase label
+	transactionInput := &models.TransactionInput{
+		Rate:          rate.ObjectID,
+		LabelFileType: models.LabelFileTypePDF,
+		Async:         false,
+	}
+	transaction, err := s.Client.PurchaseShippingLabel(transactionInput)
+	if err != nil {
+		log.Printf("PurchaseLabel failed: %v", err)
+		return store.ShippingLabel{}, err
+	}
+	if transaction.LabelURL == "" {
+		log.Printf("PurchaseLabel failed: %s: %s", ErrLabelFailed, transaction.Status)
+		return store.ShippingLabel{}, fmt.Errorf(ErrLabelFailed)
+	}
+
+	label := store.ShippingLabel{
+		OrderID:              sh.OrderID,
+		LabelID:              transaction.ObjectID,
+		Carrier:              rate.Provider,
+		Price:                rate.AmountLocal,
+		Currency:             rate.Currency,
+		PurchaseDate:         timeops.ConvertToDateString(time.Now()),
+		TrackingNumber:       transaction.TrackingNumber,
+		TrackingStatus:       transaction.TrackingStatus,
+		TrackingUrlProvider:  transaction.TrackingURLProvider,
+		Eta:                  timeops.ConvertToTimestampStringHour(transaction.Eta),
+		LabelUrl:             transaction.LabelURL,
+		CommercialInvoiceUrl: transaction.CommercialInvoiceURL,
+	}
+	return label, nil
+}
+
+// VoidLabel requests a refund of the label's transaction.
+func (s *Shippo) VoidLabel(labelID string) error {
+	refund, err := s.Client.CreateRefund(&models.RefundInput{Transaction: labelID, Async: false})
+	if err != nil {
+		log.Printf("VoidLabel failed: %v", err)
+		return err
+	}
+	if refund.Status == shippoRefundError {
+		return fmt.Errorf(ErrVoidFailed)
+	}
+	return nil
+}
+
+func (s *Shippo) Track(carrier, trackingNumber string) (*Tracking, error) {
+	ts, err := s.Client.GetTrackingUpdate(carrier, trackingNumber)
+	if err != nil {
+		log.Printf("Track failed: %v", err)
+		return &Tracking{}, err
+	}
+	if ts == nil || ts.TrackingStatus == nil {
+		return &Tracking{}, fmt.Errorf(ErrTrackingNotFound)
+	}
+	return &Tracking{
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
+		Status:         trackingStatus(ts.TrackingStatus.Status),
+		StatusDetails:  ts.TrackingStatus.StatusDetails,
+		StatusDate:     timeops.ConvertToTimestampString(ts.TrackingStatus.StatusDate.UTC()),
+	}, nil
+}
+
+// shippoTrackEvent is the payload of Shippo tracking webhooks.
+type shippoTrackEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Carrier        string `json:"carrier"`
+		TrackingNumber string `json:"tracking_number"`
+		TrackingStatus *struct {
+			ObjectID      string    `json:"object_id"`
+			Status        string    `json:"status"`
+			StatusDetails string    `json:"status_details"`
+			StatusDate    time.Time `json:"status_date"`
+			Substatus     *struct {
+				Code string `json:"code"`
+			} `json:"substatus"`
+		} `json:"tracking_status"`
+	} `json:"data"`
+}
+
+// ParseTrackingEvent verifies the webhook's token against the webhook secret and returns the
+// tracking status of 'track_updated' events. Shippo does not sign webhooks, so the secret is
+// sent as a query parameter of the webhook URL.
+func (s *Shippo) ParseTrackingEvent(payload []byte, token string) (*Tracking, error) {
+	if err := verifyToken(token, s.WebhookSecret); err != nil {
+		return &Tracking{}, err
+	}
+	event := shippoTrackEvent{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Printf("ParseTrackingEvent failed: %v", err)
+		return &Tracking{}, fmt.Errorf(ErrInvalidWebhook)
+	}
+	if event.Event != shippoTrackUpdated || event.Data.TrackingStatus == nil {
+		return &Tracking{}, fmt.Errorf(ErrIgnoredEvent)
+	}
+	if event.Data.TrackingNumber == "" {
+		return &Tracking{}, fmt.Errorf(ErrInvalidWebhook)
+	}
+	ts := event.Data.TrackingStatus
+	t := &Tracking{
+		ID:             ts.ObjectID,
+		Carrier:        event.Data.Carrier,
+		TrackingNumber: event.Data.TrackingNumber,
+		Status:         trackingStatus(ts.Status),
+		StatusDetails:  ts.StatusDetails,
+		StatusDate:     timeops.ConvertToTimestampString(ts.StatusDate.UTC()),
+	}
+	if ts.Substatus != nil {
+		t.Substatus = ts.Substatus.Code
+	}
+	return t, nil
+}
+
+// trackingStatus returns the tracking status, or TrackingUnknown if the status is not one of
+// the tracking statuses.
+func trackingStatus(status string) string {
+	switch status {
+	case TrackingPreTransit, TrackingTransit, TrackingDelivered, TrackingReturned, TrackingFailure:
+		return status
+	}
+	return TrackingUnknown
+}
+
+// getRates returns the RateSummary of each Shippo rate.
+func getRates(rates []*models.Rate) []store.RateSummary {
+	summary := []store.RateSummary{}
+	for _, rate := range rates {
+		sl := store.ServiceLevel{}
+		if rate.ServiceLevel != nil {
+			sl = store.ServiceLevel{
+				Name:  rate.ServiceLevel.Name,
+				Token: rate.ServiceLevel.Token,
+				Terms: rate.ServiceLevel.Terms,
+			}
+		}
+		rs := store.RateSummary{
+			Price:        rate.AmountLocal,
+			Currency:     rate.Currency,
+			Provider:     rate.Provider,
+			Days:         rate.Days,
+			ServiceLevel: sl,
+		}
+		summary = append(summary, rs)
+	}
+	return summary
+}
@@ -0,0 +1,47 @@
+package shipops
+
+import (
+	"testing"
+)
+
+func TestShippoParseTrackingEvent(t *testing.T) {
+	s := &Shippo{WebhookSecret: "secret"}
+	var tests = []struct {
+		payload string
+		token   string
+		want    Tracking
+		wantErr string
+	}{
+		{
+			payload: `{"event": "track_updated", "test": false, "data": {"carrier": "usps", "tracking_number": "9205590164917312751089",
+				"tracking_status": {"object_id": "ts_01", "status": "TRANSIT", "status_details": "Out for delivery",
+				"status_date": "2021-06-01T15:04:05Z", "substatus": {"code": "out_for_delivery", "text": "Package is out for delivery."}}}}`,
+			token: "secret",
+			want: Tracking{ID: "ts_01", Carrier: "usps", TrackingNumber: "9205590164917312751089", Status: TrackingTransit,
+				Substatus: "out_for_delivery", StatusDetails: "Out for delivery", StatusDate: "06-01-2021 15:04:05"},
+		},
+		{
+			payload: `{"event": "track_updated", "data": {"carrier": "usps", "tracking_number": "9205590164917312751089",
+				"tracking_status": {"object_id": "ts_02", "status": "HELD", "status_date": "2021-06-02T09:00:00-07:00"}}}`,
+			token: "secret",
+			want: Tracking{ID: "ts_02", Carrier: "usps", TrackingNumber: "9205590164917312751089", Status: TrackingUnknown,
+				StatusDate: "06-02-2021 16:00:00"},
+		},
+		{payload: `{"event": "track_updated"}`, token: "wrong", wantErr: ErrInvalidWebhook},
+		{payload: `{"event": "transaction_created", "data": {}}`, token: "secret", wantErr: ErrIgnoredEvent},
+		{payload: `{"event": "track_updated", "data": {"tracking_status": {"status": "DELIVERED"}}}`, token: "secret", wantErr: ErrInvalidWebhook},
+		{payload: `not json`, token: "secret", wantErr: ErrInvalidWebhook},
+	}
+	for _, test := range tests {
+		tracking, err := s.ParseTrackingEvent([]byte(test.payload), test.token)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %s", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || *tracking != test.want {
+			t.Errorf("FAIL: %v, %v; want: %v", err, tracking, test.want)
+		}
+	}
+}
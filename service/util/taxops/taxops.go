@@ -0,0 +1,218 @@
+// Package taxops defines the Calculator interface used to calculate the sales tax of orders by
+// shipping destination, with a rate table implementation loaded from JSON.
+package taxops
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+// EnvarTaxRateTable contains the name of the environment variable holding the path of the JSON
+// rate table file. The default rate table is used if the variable is empty.
+const EnvarTaxRateTable = "TAX_RATE_TABLE"
+
+// Error codes
+const (
+	ErrInvalidAddress   = "ERR_INVALID_TAX_ADDRESS"
+	ErrInvalidRateTable = "ERR_INVALID_RATE_TABLE"
+)
+
+// Tax categories
+const (
+	CategoryGeneral  = "general"
+	CategoryClothing = "clothing"
+	CategoryDigital  = "digital"
+)
+
+// defaultRates contains the default rate table for the states the store collects sales tax in.
+//
+//go:embed rates.json
+var defaultRates []byte
+
+// Calculator contains the operations used to calculate the sales tax of orders.
+type Calculator interface {
+	// Calculate returns the sales tax breakdown of the order shipped to the given address.
+	// Item discounts applied to the order reduce the taxable amount of the order's items.
+	Calculate(order *store.Order, to store.Address) (store.TaxBreakdown, error)
+}
+
+// RateTable contains the sales tax rates and taxability rules of each state the store collects
+// sales tax in. Orders shipped to other states or outside the US are not taxed.
+type RateTable struct {
+	Categories      map[string]string     `json:"categories"`       // subcategory: tax category
+	DefaultCategory string                `json:"default_category"` // tax category of unlisted subcategories
+	States          map[string]StateRules `json:"states"`           // state code: rules
+}
+
+// StateRules contains the sales tax rates and taxability rules of a state.
+type StateRules struct {
+	Rate             float64            `json:"rate"`              // state rate (ex: .0725)
+	ZipRates         map[string]float64 `json:"zip_rates"`         // combined state & local rates by 5 digit ZIP code
+	ShippingTaxable  bool               `json:"shipping_taxable"`  // shipping is taxed on orders with taxable items
+	ExemptCategories []string           `json:"exempt_categories"` // tax categories not taxed (ex: digital)
+}
+
+// LoadRateTable decodes a JSON rate table.
+func LoadRateTable(r io.Reader) (*RateTable, error) {
+	table := &RateTable{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(table); err != nil {
+		log.Printf("LoadRateTable failed: %v", err)
+		return &RateTable{}, fmt.Errorf(ErrInvalidRateTable)
+	}
+	for state, rules := range table.States {
+		if rules.Rate < 0 || rules.Rate >= 1 {
+			log.Printf("LoadRateTable failed: invalid rate for %s: %v", state, rules.Rate)
+			return &RateTable{}, fmt.Errorf(ErrInvalidRateTable)
+		}
+	}
+	return table, nil
+}
+
+// TableCalculator implements the Calculator interface with a RateTable.
+type TableCalculator struct {
+	Table *RateTable
+	err   error // rate table load error
+}
+
+// NewTableCalculator returns a new *TableCalculator for the given rate table.
+func NewTableCalculator(table *RateTable) *TableCalculator {
+	return &TableCalculator{Table: table}
+}
+
+// DefaultCalculator returns a new *TableCalculator with the default rate table.
+func DefaultCalculator() *TableCalculator {
+	table, err := LoadRateTable(bytes.NewReader(defaultRates))
+	return &TableCalculator{Table: table, err: err}
+}
+
+// NewCalculatorFromEnv returns a new *TableCalculator with the rate table file named by the
+// TAX_RATE_TABLE environment variable, or with the default rate table if the variable is empty.
+// If the rate table cannot be loaded, Calculate returns the load error.
+func NewCalculatorFromEnv() *TableCalculator {
+	path := os.Getenv(EnvarTaxRateTable)
+	if path == "" {
+		return DefaultCalculator()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("NewCalculatorFromEnv failed: %v", err)
+		return &TableCalculator{Table: &RateTable{}, err: err}
+	}
+	defer f.Close()
+	table, err := LoadRateTable(f)
+	return &TableCalculator{Table: table, err: err}
+}
+
+// Calculate returns the sales tax breakdown of the order shipped to the given address. The
+// combined ZIP code rate is used if listed, otherwise the state rate. Item discounts are
+// allocated to the order's items in proportion to their subtotals.
+func (c *TableCalculator) Calculate(order *store.Order, to store.Address) (store.TaxBreakdown, error) {
+	tb := store.TaxBreakdown{Lines: []store.TaxLine{}}
+	if c.err != nil {
+		return tb, c.err
+	}
+	state := strings.ToUpper(strings.TrimSpace(to.State))
+	if domestic(to.Country) && state == "" {
+		return tb, fmt.Errorf(ErrInvalidAddress)
+	}
+	tb.Jurisdiction = state
+
+	rules, collected := c.Table.States[state]
+	if !domestic(to.Country) {
+		tb.Jurisdiction = to.Country
+		rules, collected = StateRules{}, false
+	}
+	if collected {
+		tb.Rate = rules.Rate
+		zip := strings.TrimSpace(to.Zip)
+		if len(zip) > 5 {
+			zip = zip[:5] // ZIP+4
+		}
+		if rate, ok := rules.ZipRates[zip]; ok {
+			tb.Rate = rate
+			tb.Jurisdiction = state + "-" + zip
+		}
+	}
+	exempt := make(map[string]bool)
+	for _, cat := range rules.ExemptCategories {
+		exempt[cat] = true
+	}
+
+	// allocate item discounts; the last item receives the remainder
+	itemDiscount, shippingDiscount := store.DiscountTotals(order.Discounts)
+	allocated := store.Money{}
+	for i, item := range order.Items {
+		alloc := itemDiscount.MulFrac(item.ItemSubtotal.Amount, order.SalesSubtotal.Amount, store.RoundHalfUp)
+		if i == len(order.Items)-1 {
+			alloc = itemDiscount.Sub(allocated)
+		}
+		allocated = allocated.Add(alloc)
+
+		line := store.TaxLine{
+			SizeID:        item.SizeID,
+			TaxCategory:   c.category(item.Subcategory),
+			TaxableAmount: item.ItemSubtotal.Sub(alloc),
+		}
+		line.Taxable = collected && !exempt[line.TaxCategory] && line.TaxableAmount.Amount > 0
+		if !line.Taxable {
+			line.TaxableAmount = store.Money{Currency: item.ItemSubtotal.Currency}
+		}
+		tb.ItemsTaxable = tb.ItemsTaxable.Add(line.TaxableAmount)
+		tb.Lines = append(tb.Lines, line)
+	}
+	tb.ItemsTax = tb.ItemsTaxable.MulRate(tb.Rate, store.RoundHalfUp)
+
+	// shipping is taxable if the order contains taxable items
+	tb.ShippingTaxable = collected && rules.ShippingTaxable && tb.ItemsTaxable.Amount > 0
+	if tb.ShippingTaxable {
+		tb.ShippingAmount = order.ShippingCost.Sub(shippingDiscount)
+		tb.ShippingTax = tb.ShippingAmount.MulRate(tb.Rate, store.RoundHalfUp)
+	}
+	tb.TotalTax = tb.ItemsTax.Add(tb.ShippingTax)
+	return tb, nil
+}
+
+// category returns the tax category of the subcategory.
+func (c *TableCalculator) category(subcategory string) string {
+	if cat, ok := c.Table.Categories[subcategory]; ok {
+		return cat
+	}
+	if c.Table.DefaultCategory != "" {
+		return c.Table.DefaultCategory
+	}
+	return CategoryGeneral
+}
+
+// domestic returns true if the country is the United States. Addresses without a country are
+// assumed to be domestic.
+func domestic(country string) bool {
+	switch strings.ToUpper(strings.TrimSpace(country)) {
+	case "", "US", "USA", "UNITED STATES", "UNITED STATES OF AMERICA":
+		return true
+	}
+	return false
+}
+
+// ApplyTax calculates the sales tax of the order shipped to the given address and updates the
+// order's tax breakdown, sales tax and order total.
+func ApplyTax(c Calculator, order *store.Order, to store.Address) error {
+	tb, err := c.Calculate(order, to)
+	if err != nil {
+		log.Printf("ApplyTax failed: %v", err)
+		return err
+	}
+	order.TaxBreakdown = tb
+	order.SalesTax = tb.TotalTax
+	order.UpdateTotal()
+	return nil
+}
@@ -0,0 +1,154 @@
+package taxops
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tpillz-presents/service/store-api/store"
+)
+
+const testRates = `{
+	"default_category": "general",
+	"categories": {"shirts": "clothing", "beats": "digital"},
+	"states": {
+		"CA": {"rate": 0.0725, "zip_rates": {"90001": 0.095}, "shipping_taxable": false, "exempt_categories": ["digital"]},
+		"NY": {"rate": 0.04, "shipping_taxable": true, "exempt_categories": ["clothing"]}
+	}
+}`
+
+// testTaxOrder returns an order of 2 shirts, 1 poster and 1 beat with $8.00 shipping.
+func testTaxOrder() *store.Order {
+	return &store.Order{
+		Items: []*store.CartItem{
+			{SizeID: "005-L", Subcategory: "shirts", Quantity: 2, Price: store.USD(2995), ItemSubtotal: store.USD(5990)},
+			{SizeID: "003-OS", Subcategory: "posters", Quantity: 1, Price: store.USD(1500), ItemSubtotal: store.USD(1500)},
+			{SizeID: "010-WAV", Subcategory: "beats", Quantity: 1, Price: store.USD(2510), ItemSubtotal: store.USD(2510)},
+		},
+		SalesSubtotal: store.USD(10000),
+		ShippingCost:  store.USD(800),
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	table, err := LoadRateTable(strings.NewReader(testRates))
+	if err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	c := NewTableCalculator(table)
+
+	var tests = []struct {
+		addr             store.Address
+		discounts        []store.Discount
+		wantJurisdiction string
+		wantTaxable      int64
+		wantTax          int64
+		wantErr          string
+	}{
+		{addr: store.Address{State: "CA", Zip: "95355", Country: "US"}, wantJurisdiction: "CA", wantTaxable: 7490, wantTax: 543},                       // 543.03; beat exempt
+		{addr: store.Address{State: "ca", Zip: "90001-1234", Country: "United States"}, wantJurisdiction: "CA-90001", wantTaxable: 7490, wantTax: 712}, // 711.55
+		{addr: store.Address{State: "NY", Zip: "10001"}, wantJurisdiction: "NY", wantTaxable: 4010, wantTax: 192},                                      // (1500 + 2510) * .04 + 800 * .04
+		{addr: store.Address{State: "TX", Zip: "73301", Country: "US"}, wantJurisdiction: "TX", wantTaxable: 0, wantTax: 0},                            // not collected
+		{addr: store.Address{State: "ON", Country: "Canada"}, wantJurisdiction: "Canada", wantTaxable: 0, wantTax: 0},
+		{addr: store.Address{State: "", Country: "US"}, wantErr: ErrInvalidAddress},
+		{ // 10% off allocated by subtotal: shirts 599, poster 150
+			addr:             store.Address{State: "CA", Zip: "95355"},
+			discounts:        []store.Discount{{PromotionType: store.PromotionPercentOff, Amount: store.USD(1000)}},
+			wantJurisdiction: "CA",
+			wantTaxable:      6741,
+			wantTax:          489, // 488.72
+		},
+		{ // shipping discounts reduce taxable shipping
+			addr:             store.Address{State: "NY"},
+			discounts:        []store.Discount{{PromotionType: store.PromotionFreeShipping, Amount: store.USD(800)}},
+			wantJurisdiction: "NY",
+			wantTaxable:      4010,
+			wantTax:          160,
+		},
+	}
+	for _, test := range tests {
+		order := testTaxOrder()
+		order.Discounts = test.discounts
+		tb, err := c.Calculate(order, test.addr)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("FAIL: %v; want: %v", err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FAIL: %v; want: nil", err)
+			continue
+		}
+		if tb.Jurisdiction != test.wantJurisdiction {
+			t.Errorf("FAIL: %s; want: %s", tb.Jurisdiction, test.wantJurisdiction)
+		}
+		if tb.ItemsTaxable.Amount != test.wantTaxable || tb.TotalTax.Amount != test.wantTax {
+			t.Errorf("FAIL: %v, %v; want: %d, %d", tb.ItemsTaxable, tb.TotalTax, test.wantTaxable, test.wantTax)
+		}
+		if len(tb.Lines) != len(order.Items) {
+			t.Errorf("FAIL - lines: %v", tb.Lines)
+		}
+	}
+}
+
+func TestCalculateShippingTaxable(t *testing.T) {
+	table, _ := LoadRateTable(strings.NewReader(testRates))
+	c := NewTableCalculator(table)
+
+	// shipping is not taxed on orders without taxable items
+	order := testTaxOrder()
+	order.Items = order.Items[:1] // shirts only; exempt in NY
+	order.SalesSubtotal = store.USD(5990)
+	tb, err := c.Calculate(order, store.Address{State: "NY"})
+	if err != nil || tb.ShippingTaxable || !tb.TotalTax.IsZero() {
+		t.Errorf("FAIL: %v, %v", tb, err)
+	}
+}
+
+func TestApplyTax(t *testing.T) {
+	c := DefaultCalculator()
+	order := testTaxOrder()
+	order.ChargesAndFees = store.USD(0)
+	if err := ApplyTax(c, order, store.Address{State: "CA", Zip: "95355", Country: "United States"}); err != nil {
+		t.Fatalf("FAIL: %v", err)
+	}
+	// 7490 * .07875 = 589.84
+	if order.SalesTax != store.USD(590) || order.TaxBreakdown.TotalTax != store.USD(590) {
+		t.Errorf("FAIL: %v; want: %v", order.SalesTax, store.USD(590))
+	}
+	if order.OrderTotal != store.USD(10000+800+590) {
+		t.Errorf("FAIL: %v; want: %v", order.OrderTotal, store.USD(11390))
+	}
+}
+
+func TestLoadRateTable(t *testing.T) {
+	var tests = []struct {
+		js      string
+		wantErr bool
+	}{
+		{js: testRates, wantErr: false},
+		{js: `{"states": {"CA": {"rate": 7.25}}}`, wantErr: true},
+		{js: `{"states": {"CA": {"rate": 0.0725, "local_rate": 0.01}}}`, wantErr: true},
+		{js: `{"states": `, wantErr: true},
+	}
+	for _, test := range tests {
+		_, err := LoadRateTable(strings.NewReader(test.js))
+		if (err != nil) != test.wantErr {
+			t.Errorf("FAIL: %v; want err: %v", err, test.wantErr)
+		}
+	}
+}
+
+func TestNewCalculatorFromEnv(t *testing.T) {
+	t.Setenv(EnvarTaxRateTable, "missing.json")
+	c := NewCalculatorFromEnv()
+	if _, err := c.Calculate(testTaxOrder(), store.Address{State: "CA"}); err == nil {
+		t.Errorf("FAIL: %v; want: error", err)
+	}
+
+	t.Setenv(EnvarTaxRateTable, "rates.json")
+	c = NewCalculatorFromEnv()
+	if tb, err := c.Calculate(testTaxOrder(), store.Address{State: "CA"}); err != nil || tb.Rate != .0725 {
+		t.Errorf("FAIL: %v, %v", tb, err)
+	}
+}